@@ -0,0 +1,14 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package apitest provides a mock implementation of api.API for testing
+code that uses the API without an RSP device or the SDRplay API service.
+
+Signal extends the mock with a test source that makes stream callbacks
+containing a known test pattern at a fixed sample rate. It allows a
+complete application, such as one of the commands in this module, to
+run end-to-end without hardware.
+*/
+package apitest
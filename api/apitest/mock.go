@@ -0,0 +1,276 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package apitest
+
+import (
+	"sync"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// UpdateCall records the arguments of a single call to Mock.Update.
+type UpdateCall struct {
+	Dev        api.Handle
+	Tuner      api.TunerSelectT
+	Reason     api.ReasonForUpdateT
+	ReasonExt1 api.ReasonForUpdateExtension1T
+}
+
+// Mock is an implementation of api.API that keeps all state in memory.
+// The exported fields can be set before use to configure the mock and
+// inspected afterward to verify the calls that were made. All methods
+// are safe for concurrent use, but the exported fields should only be
+// accessed directly while no methods are being called.
+//
+// Like the real implementation, LoadDeviceParams returns a copy of the
+// stored parameters and changes are only visible to later calls after
+// StoreDeviceParams is called.
+type Mock struct {
+	// Devices is the list returned by GetDevices.
+	Devices []*api.DeviceT
+	// Version is the value returned by ApiVersion.
+	Version float32
+	// Params holds the device parameters. If nil, it is populated with
+	// zero-valued DevParams, RxChannelA, and RxChannelB structs on
+	// the first call to LoadDeviceParams or StoreDeviceParams.
+	Params *api.DeviceParamsT
+	// Errors maps a method name (e.g. "Init") to an error that the
+	// method will return instead of performing its function.
+	Errors map[string]error
+	// Calls records the name of every method called, in order.
+	Calls []string
+	// Updates records the arguments of every successful Update call.
+	Updates []UpdateCall
+	// Callbacks holds the callbacks registered by Init. They are
+	// cleared by Uninit.
+	Callbacks api.CallbackFnsT
+
+	mu sync.Mutex
+}
+
+// Verify that Mock implements api.API.
+var _ api.API = &Mock{}
+
+// NewMock creates a new Mock that returns the provided devices from
+// GetDevices.
+func NewMock(devs ...*api.DeviceT) *Mock {
+	return &Mock{Devices: devs}
+}
+
+// call records the method name and returns the configured error, if any.
+// The caller must hold m.mu.
+func (m *Mock) call(name string) error {
+	m.Calls = append(m.Calls, name)
+	return m.Errors[name]
+}
+
+// params initializes Params if necessary. The caller must hold m.mu.
+func (m *Mock) params() *api.DeviceParamsT {
+	if m.Params == nil {
+		m.Params = &api.DeviceParamsT{
+			DevParams:  &api.DevParamsT{},
+			RxChannelA: &api.RxChannelParamsT{},
+			RxChannelB: &api.RxChannelParamsT{},
+		}
+	}
+	return m.Params
+}
+
+// Open implements api.API.
+func (m *Mock) Open() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.call("Open")
+}
+
+// Close implements api.API.
+func (m *Mock) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.call("Close")
+}
+
+// ApiVersion implements api.API.
+func (m *Mock) ApiVersion() (float32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.call("ApiVersion"); err != nil {
+		return 0, err
+	}
+	return m.Version, nil
+}
+
+// LockDeviceApi implements api.API.
+func (m *Mock) LockDeviceApi() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.call("LockDeviceApi")
+}
+
+// UnlockDeviceApi implements api.API.
+func (m *Mock) UnlockDeviceApi() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.call("UnlockDeviceApi")
+}
+
+// GetDevices implements api.API.
+func (m *Mock) GetDevices() ([]*api.DeviceT, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.call("GetDevices"); err != nil {
+		return nil, err
+	}
+	res := make([]*api.DeviceT, len(m.Devices))
+	copy(res, m.Devices)
+	return res, nil
+}
+
+// SelectDevice implements api.API.
+func (m *Mock) SelectDevice(dev *api.DeviceT) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.call("SelectDevice")
+}
+
+// ReleaseDevice implements api.API.
+func (m *Mock) ReleaseDevice(dev *api.DeviceT) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.call("ReleaseDevice")
+}
+
+// GetLastError implements api.API.
+func (m *Mock) GetLastError(dev *api.DeviceT) api.ErrorInfoT {
+	return api.ErrorInfoT{}
+}
+
+// DisableHeartbeat implements api.API.
+func (m *Mock) DisableHeartbeat() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.call("DisableHeartbeat")
+}
+
+// DebugEnable implements api.API.
+func (m *Mock) DebugEnable(dev api.Handle, enable api.DbgLvlT) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.call("DebugEnable")
+}
+
+// LoadDeviceParams implements api.API.
+func (m *Mock) LoadDeviceParams(dev api.Handle) (*api.DeviceParamsT, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.call("LoadDeviceParams"); err != nil {
+		return nil, err
+	}
+	params := m.params()
+	res := &api.DeviceParamsT{}
+	if params.DevParams != nil {
+		cpy := *params.DevParams
+		res.DevParams = &cpy
+	}
+	if params.RxChannelA != nil {
+		cpy := *params.RxChannelA
+		res.RxChannelA = &cpy
+	}
+	if params.RxChannelB != nil {
+		cpy := *params.RxChannelB
+		res.RxChannelB = &cpy
+	}
+	return res, nil
+}
+
+// StoreDeviceParams implements api.API.
+func (m *Mock) StoreDeviceParams(dev api.Handle, newParams *api.DeviceParamsT) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.call("StoreDeviceParams"); err != nil {
+		return err
+	}
+	params := m.params()
+	if params.DevParams != nil && newParams.DevParams != nil {
+		*params.DevParams = *newParams.DevParams
+	}
+	if params.RxChannelA != nil && newParams.RxChannelA != nil {
+		*params.RxChannelA = *newParams.RxChannelA
+	}
+	if params.RxChannelB != nil && newParams.RxChannelB != nil {
+		*params.RxChannelB = *newParams.RxChannelB
+	}
+	return nil
+}
+
+// Init implements api.API.
+func (m *Mock) Init(dev api.Handle, callbacks api.CallbackFnsT) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.call("Init"); err != nil {
+		return err
+	}
+	m.Callbacks = callbacks
+	return nil
+}
+
+// Uninit implements api.API.
+func (m *Mock) Uninit(dev api.Handle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.call("Uninit"); err != nil {
+		return err
+	}
+	m.Callbacks = api.CallbackFnsT{}
+	return nil
+}
+
+// Update implements api.API.
+func (m *Mock) Update(dev api.Handle, tuner api.TunerSelectT, reasonForUpdate api.ReasonForUpdateT, reasonForUpdateExt1 api.ReasonForUpdateExtension1T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.call("Update"); err != nil {
+		return err
+	}
+	m.Updates = append(m.Updates, UpdateCall{
+		Dev:        dev,
+		Tuner:      tuner,
+		Reason:     reasonForUpdate,
+		ReasonExt1: reasonForUpdateExt1,
+	})
+	return nil
+}
+
+// SwapRspDuoActiveTuner implements api.API.
+func (m *Mock) SwapRspDuoActiveTuner(dev api.Handle, currentTuner *api.TunerSelectT, tuner1AmPortSel api.RspDuo_AmPortSelectT) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.call("SwapRspDuoActiveTuner"); err != nil {
+		return err
+	}
+	switch *currentTuner {
+	case api.Tuner_A:
+		*currentTuner = api.Tuner_B
+	case api.Tuner_B:
+		*currentTuner = api.Tuner_A
+	}
+	return nil
+}
+
+// SwapRspDuoDualTunerModeSampleRate implements api.API.
+func (m *Mock) SwapRspDuoDualTunerModeSampleRate(dev api.Handle, currentSampleRate *float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.call("SwapRspDuoDualTunerModeSampleRate"); err != nil {
+		return err
+	}
+	switch *currentSampleRate {
+	case 6e6:
+		*currentSampleRate = 8e6
+	case 8e6:
+		*currentSampleRate = 6e6
+	}
+	return nil
+}
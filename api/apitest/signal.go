@@ -0,0 +1,174 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package apitest
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// PatternPeriod is the period in samples of the test pattern generated
+// by Signal.
+const PatternPeriod = 64
+
+// PatternAmplitude is the amplitude of the test pattern generated by
+// Signal. It is -12 dBFS, which leaves room for downstream gain.
+const PatternAmplitude = 8192
+
+// SignalBlockSize is the number of samples in each stream callback made
+// by Signal.
+const SignalBlockSize = 1008
+
+// patternI and patternQ hold one period of the test pattern.
+var patternI, patternQ = func() ([PatternPeriod]int16, [PatternPeriod]int16) {
+	var xi, xq [PatternPeriod]int16
+	for n := range xi {
+		phase := 2 * math.Pi * float64(n) / PatternPeriod
+		xi[n] = int16(math.Round(PatternAmplitude * math.Cos(phase)))
+		xq[n] = int16(math.Round(PatternAmplitude * math.Sin(phase)))
+	}
+	return xi, xq
+}()
+
+// Pattern returns the I and Q components of the test pattern generated
+// by Signal for the provided sample number. The pattern is a complex
+// tone at 1/PatternPeriod of the sample rate that repeats exactly every
+// PatternPeriod samples, so any output can be compared sample for sample
+// once its first sample number is known.
+func Pattern(sampleNum uint32) (int16, int16) {
+	n := sampleNum % PatternPeriod
+	return patternI[n], patternQ[n]
+}
+
+// PatternB returns the I and Q components of the test pattern generated
+// by Signal on stream B for the provided sample number. It is the complex
+// conjugate of Pattern, a tone at -1/PatternPeriod of the sample rate, so
+// the two streams can be told apart while remaining sample aligned.
+func PatternB(sampleNum uint32) (int16, int16) {
+	n := sampleNum % PatternPeriod
+	return patternI[n], -patternQ[n]
+}
+
+// Signal is a Mock that, once initialized, makes stream A callbacks
+// containing the test pattern (see Pattern) at a fixed sample rate. If a
+// stream B callback is registered, as for an RSPduo in dual-tuner mode,
+// each stream A callback is followed by a stream B callback for the same
+// samples of PatternB. It allows a complete application to run without an
+// RSP device or the SDRplay API service, for example to validate the rest
+// of a processing pipeline. It is intended for testing only.
+//
+// The first callback has reset set to true and a FirstSampleNum of
+// zero. Callbacks are paced by the wall clock, so the average rate
+// matches the sample rate, but several callbacks may be made back to
+// back.
+type Signal struct {
+	*Mock
+	// Rate is the sample rate in samples per second. If zero, it is
+	// computed as the stored ADC sample rate divided by the stored
+	// decimation factor of channel A when Init is called.
+	Rate float64
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Verify that Signal implements api.API.
+var _ api.API = &Signal{}
+
+// NewSignal creates a new Signal that returns the provided devices from
+// GetDevices and generates samples at the provided rate.
+func NewSignal(rate float64, devs ...*api.DeviceT) *Signal {
+	return &Signal{Mock: NewMock(devs...), Rate: rate}
+}
+
+// Init implements api.API. It registers the callbacks like Mock.Init and
+// then starts generating the test pattern.
+func (s *Signal) Init(dev api.Handle, callbacks api.CallbackFnsT) error {
+	if err := s.Mock.Init(dev, callbacks); err != nil {
+		return err
+	}
+	rate := s.Rate
+	if rate <= 0 {
+		s.Mock.mu.Lock()
+		p := s.Mock.params()
+		if dec := p.RxChannelA.CtrlParams.Decimation.DecimationFactor; dec > 0 {
+			rate = p.DevParams.FsFreq.FsHz / float64(dec)
+		}
+		s.Mock.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil || rate <= 0 || callbacks.StreamACbFn == nil {
+		return nil
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.generate(rate, callbacks.StreamACbFn, callbacks.StreamBCbFn, s.stop, s.done)
+	return nil
+}
+
+// Uninit implements api.API. It stops generating the test pattern before
+// it returns, so no callback is made after Uninit returns.
+func (s *Signal) Uninit(dev api.Handle) error {
+	s.mu.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+		s.done = nil
+	}
+	s.mu.Unlock()
+	return s.Mock.Uninit(dev)
+}
+
+// generate makes stream callbacks until stop is closed. The fnB argument
+// may be nil.
+func (s *Signal) generate(rate float64, fnA, fnB api.StreamCallbackT, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	xi := make([]int16, SignalBlockSize)
+	xq := make([]int16, SignalBlockSize)
+	var sampleNum uint32
+	var sent uint64
+	start := time.Now()
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		due := uint64(time.Since(start).Seconds() * rate)
+		for sent+SignalBlockSize <= due {
+			for n := range xi {
+				xi[n], xq[n] = Pattern(sampleNum + uint32(n))
+			}
+			params := api.StreamCbParamsT{
+				FirstSampleNum: sampleNum,
+				NumSamples:     SignalBlockSize,
+			}
+			fnA(xi, xq, &params, sent == 0)
+			if fnB != nil {
+				for n := range xi {
+					xi[n], xq[n] = PatternB(sampleNum + uint32(n))
+				}
+				paramsB := params
+				fnB(xi, xq, &paramsB, sent == 0)
+			}
+			sampleNum += SignalBlockSize
+			sent += SignalBlockSize
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package apitest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestPattern(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		n    uint32
+		i, q int16
+	}{
+		{0, PatternAmplitude, 0},
+		{PatternPeriod / 4, 0, PatternAmplitude},
+		{PatternPeriod / 2, -PatternAmplitude, 0},
+		{3 * PatternPeriod / 4, 0, -PatternAmplitude},
+		{PatternPeriod, PatternAmplitude, 0},
+	}
+	for _, spec := range specs {
+		i, q := Pattern(spec.n)
+		if i != spec.i || q != spec.q {
+			t.Errorf("wrong pattern at %d: got (%d,%d), want (%d,%d)", spec.n, i, q, spec.i, spec.q)
+		}
+	}
+}
+
+func TestSignal(t *testing.T) {
+	t.Parallel()
+
+	const rate = 1e6
+	s := NewSignal(rate, &api.DeviceT{HWVer: api.RSP1A_ID})
+
+	var (
+		mu        sync.Mutex
+		callbacks int
+		resets    int
+		next      uint32
+		bad       int
+	)
+	err := s.Init(nil, api.CallbackFnsT{
+		StreamACbFn: func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			callbacks++
+			if reset {
+				resets++
+			}
+			if params.FirstSampleNum != next || int(params.NumSamples) != len(xi) {
+				bad++
+			}
+			for n := range xi {
+				i, q := Pattern(params.FirstSampleNum + uint32(n))
+				if xi[n] != i || xq[n] != q {
+					bad++
+				}
+			}
+			next = params.FirstSampleNum + params.NumSamples
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := s.Uninit(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	got := callbacks
+	mu.Unlock()
+	// Wait to verify that no callbacks are made after Uninit.
+	time.Sleep(10 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if callbacks != got {
+		t.Errorf("callbacks after Uninit: got %d, want %d", callbacks, got)
+	}
+	// 50 ms at 1 MHz is about 49 callbacks, but allow for a slow
+	// scheduler.
+	if callbacks < 5 || callbacks > 60 {
+		t.Errorf("wrong number of callbacks: got %d, want about 49", callbacks)
+	}
+	if resets != 1 {
+		t.Errorf("wrong number of resets: got %d, want 1", resets)
+	}
+	if bad != 0 {
+		t.Errorf("wrong samples or sample numbers: got %d errors", bad)
+	}
+}
+
+func TestSignalRateFromParams(t *testing.T) {
+	t.Parallel()
+
+	s := NewSignal(0)
+	p, err := s.LoadDeviceParams(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.DevParams.FsFreq.FsHz = 2e6
+	p.RxChannelA.CtrlParams.Decimation.DecimationFactor = 2
+	if err := s.StoreDeviceParams(nil, p); err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan struct{}, 1)
+	err = s.Init(nil, api.CallbackFnsT{
+		StreamACbFn: func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+			select {
+			case called <- struct{}{}:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Uninit(nil)
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Error("no callback made")
+	}
+}
+
+func TestSignalStreamB(t *testing.T) {
+	t.Parallel()
+
+	s := NewSignal(1e6, &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both})
+
+	var (
+		mu      sync.Mutex
+		nextA   uint32
+		numB    int
+		bad     int
+		pending bool
+	)
+	err := s.Init(nil, api.CallbackFnsT{
+		StreamACbFn: func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			nextA = params.FirstSampleNum
+			pending = true
+		},
+		StreamBCbFn: func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			numB++
+			// Each stream B callback follows the stream A callback for
+			// the same samples.
+			if !pending || params.FirstSampleNum != nextA {
+				bad++
+			}
+			pending = false
+			for n := range xi {
+				i, q := PatternB(params.FirstSampleNum + uint32(n))
+				if xi[n] != i || xq[n] != q {
+					bad++
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := s.Uninit(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if numB == 0 {
+		t.Error("no stream B callbacks made")
+	}
+	if bad != 0 {
+		t.Errorf("wrong stream B samples or sample numbers: got %d errors", bad)
+	}
+	if i, q := PatternB(PatternPeriod / 4); i != 0 || q != -PatternAmplitude {
+		t.Errorf("wrong pattern B at %d: got (%d,%d), want (0,%d)", PatternPeriod/4, i, q, -PatternAmplitude)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+// Capabilities describes the optional hardware features of an RSP
+// device model. It allows higher layers to query whether a feature
+// exists instead of enumerating hardware versions.
+type Capabilities struct {
+	// HasHighZ is true if the device has a High-Z antenna port.
+	HasHighZ bool
+	// HasBiasT is true if the device can supply bias-T power to an
+	// antenna port.
+	HasBiasT bool
+	// HasRfNotch is true if the device has a broadcast AM/FM notch
+	// filter, labeled "RfNotch" in the C API.
+	HasRfNotch bool
+	// HasDabNotch is true if the device has a DAB notch filter, labeled
+	// "RfDabNotch" in the C API.
+	HasDabNotch bool
+	// NumAntennas is the number of selectable 50 ohm antenna inputs,
+	// not including any High-Z port. An RSPduo reports 1 because each
+	// tuner has a single fixed 50 ohm input.
+	NumAntennas int
+	// SupportsDualTuner is true if the device can stream from two
+	// tuners simultaneously.
+	SupportsDualTuner bool
+	// HasRefClockOutput is true if the device has a reference clock
+	// output that can be enabled.
+	HasRefClockOutput bool
+}
+
+// DeviceCapabilities returns the Capabilities of the device model
+// identified by d.HWVer. It returns the zero value, which reports no
+// optional features, for an unknown hardware version.
+func DeviceCapabilities(d *DeviceT) Capabilities {
+	switch d.HWVer {
+	case RSP1_ID:
+		return Capabilities{
+			NumAntennas: 1,
+		}
+	case RSP1A_ID:
+		return Capabilities{
+			HasBiasT:    true,
+			HasRfNotch:  true,
+			HasDabNotch: true,
+			NumAntennas: 1,
+		}
+	case RSP2_ID:
+		return Capabilities{
+			HasHighZ:          true,
+			HasBiasT:          true,
+			HasRfNotch:        true,
+			NumAntennas:       2,
+			HasRefClockOutput: true,
+		}
+	case RSPduo_ID:
+		return Capabilities{
+			HasHighZ:          true,
+			HasBiasT:          true,
+			HasRfNotch:        true,
+			HasDabNotch:       true,
+			NumAntennas:       1,
+			SupportsDualTuner: true,
+			HasRefClockOutput: true,
+		}
+	case RSPdx_ID:
+		return Capabilities{
+			HasBiasT:    true,
+			HasRfNotch:  true,
+			HasDabNotch: true,
+			NumAntennas: 3,
+		}
+	default:
+		return Capabilities{}
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import "testing"
+
+func TestDeviceCapabilities(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		hwVer HWVersion
+		want  Capabilities
+	}{
+		{RSP1_ID, Capabilities{NumAntennas: 1}},
+		{RSP1A_ID, Capabilities{HasBiasT: true, HasRfNotch: true, HasDabNotch: true, NumAntennas: 1}},
+		{RSP2_ID, Capabilities{HasHighZ: true, HasBiasT: true, HasRfNotch: true, NumAntennas: 2, HasRefClockOutput: true}},
+		{RSPduo_ID, Capabilities{HasHighZ: true, HasBiasT: true, HasRfNotch: true, HasDabNotch: true, NumAntennas: 1, SupportsDualTuner: true, HasRefClockOutput: true}},
+		{RSPdx_ID, Capabilities{HasBiasT: true, HasRfNotch: true, HasDabNotch: true, NumAntennas: 3}},
+		{HWVersion(0), Capabilities{}},
+		{HWVersion(42), Capabilities{}},
+	}
+
+	for _, spec := range specs {
+		got := DeviceCapabilities(&DeviceT{HWVer: spec.hwVer})
+		if got != spec.want {
+			t.Errorf("wrong capabilities for %v: got %+v, want %+v", spec.hwVer, got, spec.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// RecordedCall holds the arguments and result of a single call recorded
+// by a Recorder.
+type RecordedCall struct {
+	// Method is the name of the API method (e.g. "Update").
+	Method string
+	// Dev is the device handle argument.
+	Dev Handle
+	// Tuner is the tuner argument of Update or the current tuner
+	// argument of SwapRspDuoActiveTuner, before the call.
+	Tuner TunerSelectT
+	// Reason is the reasonForUpdate argument of Update.
+	Reason ReasonForUpdateT
+	// ReasonExt1 is the reasonForUpdateExt1 argument of Update.
+	ReasonExt1 ReasonForUpdateExtension1T
+	// AmPort is the tuner1AmPortSel argument of SwapRspDuoActiveTuner.
+	AmPort RspDuo_AmPortSelectT
+	// SampleRate is the current sample rate argument of
+	// SwapRspDuoDualTunerModeSampleRate, before the call.
+	SampleRate float64
+	// Params is a copy of the params argument of StoreDeviceParams.
+	Params *DeviceParamsT
+	// Err is the error returned by the wrapped implementation.
+	Err error
+}
+
+// String returns a single line description of the call. Reasons for
+// update are described by the names of the set flags.
+func (c RecordedCall) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s dev=%v", c.Method, c.Dev)
+	switch c.Method {
+	case "Update":
+		fmt.Fprintf(&sb, " tuner=%v reason=%s reasonExt1=%s", c.Tuner, describeFlags(uint32(c.Reason), func(v uint32) string {
+			return ReasonForUpdateT(v).String()
+		}), describeFlags(uint32(c.ReasonExt1), func(v uint32) string {
+			return ReasonForUpdateExtension1T(v).String()
+		}))
+	case "SwapRspDuoActiveTuner":
+		fmt.Fprintf(&sb, " tuner=%v amPort=%v", c.Tuner, c.AmPort)
+	case "SwapRspDuoDualTunerModeSampleRate":
+		fmt.Fprintf(&sb, " sampleRate=%vHz", c.SampleRate)
+	}
+	if c.Err != nil {
+		fmt.Fprintf(&sb, " err=%v", c.Err)
+	}
+	return sb.String()
+}
+
+// describeFlags returns the names of the set bits of v, as returned by
+// name, separated by "|".
+func describeFlags(v uint32, name func(uint32) string) string {
+	if v == 0 {
+		return name(0)
+	}
+	var names []string
+	for bit := uint32(1); bit != 0; bit <<= 1 {
+		if v&bit != 0 {
+			names = append(names, name(bit))
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Recorder is a decorator of an API implementation that records the
+// calls that change the state of a device: StoreDeviceParams, Update,
+// SwapRspDuoActiveTuner, and SwapRspDuoDualTunerModeSampleRate. All
+// calls are delegated to the wrapped implementation. It is intended for
+// debugging runtime changes, such as a retune or gain change, by
+// comparing the exact sequence of calls to the SDRplay API
+// documentation. It can be installed in a session with
+// session.WithImplementation.
+type Recorder struct {
+	API
+
+	logf  func(format string, v ...interface{})
+	mu    sync.Mutex
+	calls []RecordedCall
+}
+
+// Verify that Recorder implements API.
+var _ API = &Recorder{}
+
+// NewRecorder creates a Recorder that wraps impl. If logf is not nil,
+// each call is also described with it (e.g. log.Printf) when it returns.
+func NewRecorder(impl API, logf func(format string, v ...interface{})) *Recorder {
+	return &Recorder{API: impl, logf: logf}
+}
+
+// record appends c to the recorded calls and logs it.
+func (r *Recorder) record(c RecordedCall) {
+	r.mu.Lock()
+	r.calls = append(r.calls, c)
+	r.mu.Unlock()
+	if r.logf != nil {
+		r.logf("%v", c)
+	}
+}
+
+// Calls returns a copy of the recorded calls in the order they were made.
+func (r *Recorder) Calls() []RecordedCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]RecordedCall, len(r.calls))
+	copy(res, r.calls)
+	return res
+}
+
+// Reset discards the recorded calls.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.calls = nil
+	r.mu.Unlock()
+}
+
+// StoreDeviceParams implements API.
+func (r *Recorder) StoreDeviceParams(dev Handle, params *DeviceParamsT) error {
+	var cpy *DeviceParamsT
+	if params != nil {
+		cpy = &DeviceParamsT{}
+		if params.DevParams != nil {
+			v := *params.DevParams
+			cpy.DevParams = &v
+		}
+		if params.RxChannelA != nil {
+			v := *params.RxChannelA
+			cpy.RxChannelA = &v
+		}
+		if params.RxChannelB != nil {
+			v := *params.RxChannelB
+			cpy.RxChannelB = &v
+		}
+	}
+	err := r.API.StoreDeviceParams(dev, params)
+	r.record(RecordedCall{Method: "StoreDeviceParams", Dev: dev, Params: cpy, Err: err})
+	return err
+}
+
+// Update implements API.
+func (r *Recorder) Update(dev Handle, tuner TunerSelectT, reasonForUpdate ReasonForUpdateT, reasonForUpdateExt1 ReasonForUpdateExtension1T) error {
+	err := r.API.Update(dev, tuner, reasonForUpdate, reasonForUpdateExt1)
+	r.record(RecordedCall{
+		Method:     "Update",
+		Dev:        dev,
+		Tuner:      tuner,
+		Reason:     reasonForUpdate,
+		ReasonExt1: reasonForUpdateExt1,
+		Err:        err,
+	})
+	return err
+}
+
+// SwapRspDuoActiveTuner implements API.
+func (r *Recorder) SwapRspDuoActiveTuner(dev Handle, currentTuner *TunerSelectT, tuner1AmPortSel RspDuo_AmPortSelectT) error {
+	c := RecordedCall{Method: "SwapRspDuoActiveTuner", Dev: dev, AmPort: tuner1AmPortSel}
+	if currentTuner != nil {
+		c.Tuner = *currentTuner
+	}
+	c.Err = r.API.SwapRspDuoActiveTuner(dev, currentTuner, tuner1AmPortSel)
+	r.record(c)
+	return c.Err
+}
+
+// SwapRspDuoDualTunerModeSampleRate implements API.
+func (r *Recorder) SwapRspDuoDualTunerModeSampleRate(dev Handle, currentSampleRate *float64) error {
+	c := RecordedCall{Method: "SwapRspDuoDualTunerModeSampleRate", Dev: dev}
+	if currentSampleRate != nil {
+		c.SampleRate = *currentSampleRate
+	}
+	c.Err = r.API.SwapRspDuoDualTunerModeSampleRate(dev, currentSampleRate)
+	r.record(c)
+	return c.Err
+}
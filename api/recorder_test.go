@@ -0,0 +1,112 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	var dev api.Handle
+	m := apitest.NewMock()
+	var logged []string
+	r := api.NewRecorder(m, func(format string, v ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	})
+
+	p, err := r.LoadDeviceParams(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RxChannelA.TunerParams.RfFreq.RfHz = 100e6
+	if err := r.StoreDeviceParams(dev, p); err != nil {
+		t.Fatal(err)
+	}
+	// The recorded params must not follow later changes.
+	p.RxChannelA.TunerParams.RfFreq.RfHz = 200e6
+	if err := r.Update(dev, api.Tuner_A, api.Update_Tuner_Frf|api.Update_Tuner_Gr, api.Update_Ext1_None); err != nil {
+		t.Fatal(err)
+	}
+	tuner := api.Tuner_A
+	if err := r.SwapRspDuoActiveTuner(dev, &tuner, api.RspDuo_AMPORT_2); err != nil {
+		t.Fatal(err)
+	}
+	if tuner != api.Tuner_B {
+		t.Errorf("wrong swapped tuner: got %v, want %v", tuner, api.Tuner_B)
+	}
+	fs := 6e6
+	if err := r.SwapRspDuoDualTunerModeSampleRate(dev, &fs); err != nil {
+		t.Fatal(err)
+	}
+	if fs != 8e6 {
+		t.Errorf("wrong swapped sample rate: got %v, want %v", fs, 8e6)
+	}
+	updateErr := errors.New("update failed")
+	m.Errors = map[string]error{"Update": updateErr}
+	if err := r.Update(dev, api.Tuner_B, api.Update_Ctrl_Agc, api.Update_RspDx_HdrEnable); err != updateErr {
+		t.Errorf("wrong error: got %v, want %v", err, updateErr)
+	}
+
+	// Every call, including the unrecorded ones, is delegated.
+	wantCalls := []string{
+		"LoadDeviceParams",
+		"StoreDeviceParams",
+		"Update",
+		"SwapRspDuoActiveTuner",
+		"SwapRspDuoDualTunerModeSampleRate",
+		"Update",
+	}
+	if !reflect.DeepEqual(m.Calls, wantCalls) {
+		t.Errorf("wrong delegated calls: got %v, want %v", m.Calls, wantCalls)
+	}
+	wantUpdates := []apitest.UpdateCall{{Tuner: api.Tuner_A, Reason: api.Update_Tuner_Frf | api.Update_Tuner_Gr}}
+	if !reflect.DeepEqual(m.Updates, wantUpdates) {
+		t.Errorf("wrong delegated updates: got %+v, want %+v", m.Updates, wantUpdates)
+	}
+	if got := m.Params.RxChannelA.TunerParams.RfFreq.RfHz; got != 100e6 {
+		t.Errorf("wrong stored frequency: got %v, want %v", got, 100e6)
+	}
+
+	calls := r.Calls()
+	if len(calls) != 5 {
+		t.Fatalf("wrong number of recorded calls: got %d, want 5", len(calls))
+	}
+	if got := calls[0].Params.RxChannelA.TunerParams.RfFreq.RfHz; got != 100e6 {
+		t.Errorf("wrong recorded frequency: got %v, want %v", got, 100e6)
+	}
+	calls[0].Params = nil
+	wantRecorded := []api.RecordedCall{
+		{Method: "StoreDeviceParams"},
+		{Method: "Update", Tuner: api.Tuner_A, Reason: api.Update_Tuner_Frf | api.Update_Tuner_Gr},
+		{Method: "SwapRspDuoActiveTuner", Tuner: api.Tuner_A, AmPort: api.RspDuo_AMPORT_2},
+		{Method: "SwapRspDuoDualTunerModeSampleRate", SampleRate: 6e6},
+		{Method: "Update", Tuner: api.Tuner_B, Reason: api.Update_Ctrl_Agc, ReasonExt1: api.Update_RspDx_HdrEnable, Err: updateErr},
+	}
+	if !reflect.DeepEqual(calls, wantRecorded) {
+		t.Errorf("wrong recorded calls: got %+v, want %+v", calls, wantRecorded)
+	}
+
+	if len(logged) != len(wantRecorded) {
+		t.Fatalf("wrong number of logged calls: got %d, want %d", len(logged), len(wantRecorded))
+	}
+	// The format of the handle depends on the implementation.
+	wantLog := fmt.Sprintf("Update dev=%v tuner=Tuner_A reason=Update_Tuner_Gr|Update_Tuner_Frf reasonExt1=Update_Ext1_None", dev)
+	if logged[1] != wantLog {
+		t.Errorf("wrong log: got %q, want %q", logged[1], wantLog)
+	}
+
+	r.Reset()
+	if got := r.Calls(); len(got) != 0 {
+		t.Errorf("wrong number of calls after reset: got %d, want 0", len(got))
+	}
+}
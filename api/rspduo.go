@@ -28,22 +28,44 @@ const (
 	RspDuoMode_Secondary    RspDuoModeT = 8
 )
 
+// HasSingle returns true if RspDuoMode_Single_Tuner is set.
+func (m RspDuoModeT) HasSingle() bool {
+	return m&RspDuoMode_Single_Tuner != 0
+}
+
+// HasDual returns true if RspDuoMode_Dual_Tuner is set.
+func (m RspDuoModeT) HasDual() bool {
+	return m&RspDuoMode_Dual_Tuner != 0
+}
+
+// HasPrimary returns true if RspDuoMode_Primary is set.
+func (m RspDuoModeT) HasPrimary() bool {
+	return m&RspDuoMode_Primary != 0
+}
+
+// HasSecondary returns true if RspDuoMode_Secondary is set.
+func (m RspDuoModeT) HasSecondary() bool {
+	return m&RspDuoMode_Secondary != 0
+}
+
+// String implements fmt.Stringer. It lists the set modes separated
+// by "|" (e.g. "Single|Dual|Primary").
 func (m RspDuoModeT) String() string {
 	if m == RspDuoMode_Unknown {
 		return "Unknown"
 	}
 
 	var words []string
-	if m&RspDuoMode_Single_Tuner != 0 {
+	if m.HasSingle() {
 		words = append(words, "Single")
 	}
-	if m&RspDuoMode_Dual_Tuner != 0 {
+	if m.HasDual() {
 		words = append(words, "Dual")
 	}
-	if m&RspDuoMode_Primary != 0 {
+	if m.HasPrimary() {
 		words = append(words, "Primary")
 	}
-	if m&RspDuoMode_Secondary != 0 {
+	if m.HasSecondary() {
 		words = append(words, "Secondary")
 	}
 	if len(words) == 0 {
@@ -0,0 +1,41 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import "testing"
+
+func TestRspDuoMode(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		m                                RspDuoModeT
+		single, dual, primary, secondary bool
+		want                             string
+	}{
+		{RspDuoMode_Unknown, false, false, false, false, "Unknown"},
+		{RspDuoMode_Single_Tuner, true, false, false, false, "Single"},
+		{RspDuoMode_Dual_Tuner, false, true, false, false, "Dual"},
+		{RspDuoMode_Primary, false, false, true, false, "Primary"},
+		{RspDuoMode_Secondary, false, false, false, true, "Secondary"},
+		{RspDuoMode_Single_Tuner | RspDuoMode_Dual_Tuner | RspDuoMode_Primary, true, true, true, false, "Single|Dual|Primary"},
+		{RspDuoMode_Primary | RspDuoMode_Secondary, false, false, true, true, "Primary|Secondary"},
+		{RspDuoMode_Secondary | 16, false, false, false, true, "Secondary"},
+		{16, false, false, false, false, "InvalidMode(16)"},
+	}
+
+	for _, spec := range specs {
+		m := spec.m
+		if m.HasSingle() != spec.single || m.HasDual() != spec.dual || m.HasPrimary() != spec.primary || m.HasSecondary() != spec.secondary {
+			t.Errorf(
+				"wrong predicates for %d: got %v,%v,%v,%v, want %v,%v,%v,%v",
+				int32(m), m.HasSingle(), m.HasDual(), m.HasPrimary(), m.HasSecondary(),
+				spec.single, spec.dual, spec.primary, spec.secondary,
+			)
+		}
+		if got := m.String(); got != spec.want {
+			t.Errorf("wrong string for %d: got %q, want %q", int32(m), got, spec.want)
+		}
+	}
+}
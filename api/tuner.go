@@ -4,6 +4,8 @@
 
 package api
 
+import "fmt"
+
 //go:generate go run golang.org/x/tools/cmd/stringer -type Bw_MHzT,If_kHzT,LoModeT,MinGainReductionT,TunerSelectT -output tuner_string.go
 
 const MAX_BB_GR = 59
@@ -30,6 +32,13 @@ func (v Bw_MHzT) Hz() float64 {
 	return float64(v) * 1000
 }
 
+// Describe returns the name of the bandwidth followed by its value in Hz
+// (e.g. "BW_1_536 (1536000 Hz)"). The String method is generated and
+// only returns the name.
+func (v Bw_MHzT) Describe() string {
+	return fmt.Sprintf("%v (%.0f Hz)", v, v.Hz())
+}
+
 type If_kHzT int32
 
 const (
@@ -40,6 +49,26 @@ const (
 	IF_2_048     If_kHzT = 2048
 )
 
+// KHz returns the IF frequency in kHz. It returns 0 for IF_Undefined.
+func (v If_kHzT) KHz() float64 {
+	if v < 0 {
+		return 0
+	}
+	return float64(v)
+}
+
+// Hz returns the IF frequency in Hz. It returns 0 for IF_Undefined.
+func (v If_kHzT) Hz() float64 {
+	return v.KHz() * 1000
+}
+
+// Describe returns the name of the IF mode followed by its frequency in
+// Hz (e.g. "IF_1_620 (1620000 Hz)"). The String method is generated and
+// only returns the name.
+func (v If_kHzT) Describe() string {
+	return fmt.Sprintf("%v (%.0f Hz)", v, v.Hz())
+}
+
 type LoModeT int32
 
 const (
@@ -0,0 +1,58 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import "testing"
+
+func TestBwDescribe(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		bw   Bw_MHzT
+		want string
+	}{
+		{BW_Undefined, "BW_Undefined (0 Hz)"},
+		{BW_0_200, "BW_0_200 (200000 Hz)"},
+		{BW_0_300, "BW_0_300 (300000 Hz)"},
+		{BW_0_600, "BW_0_600 (600000 Hz)"},
+		{BW_1_536, "BW_1_536 (1536000 Hz)"},
+		{BW_5_000, "BW_5_000 (5000000 Hz)"},
+		{BW_6_000, "BW_6_000 (6000000 Hz)"},
+		{BW_7_000, "BW_7_000 (7000000 Hz)"},
+		{BW_8_000, "BW_8_000 (8000000 Hz)"},
+	}
+	for _, spec := range specs {
+		if got := spec.bw.Describe(); got != spec.want {
+			t.Errorf("wrong description: got %q, want %q", got, spec.want)
+		}
+	}
+}
+
+func TestIfDescribe(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		ifType If_kHzT
+		kHz    float64
+		want   string
+	}{
+		{IF_Undefined, 0, "IF_Undefined (0 Hz)"},
+		{IF_Zero, 0, "IF_Zero (0 Hz)"},
+		{IF_0_450, 450, "IF_0_450 (450000 Hz)"},
+		{IF_1_620, 1620, "IF_1_620 (1620000 Hz)"},
+		{IF_2_048, 2048, "IF_2_048 (2048000 Hz)"},
+	}
+	for _, spec := range specs {
+		if got := spec.ifType.KHz(); got != spec.kHz {
+			t.Errorf("wrong kHz for %v: got %v, want %v", spec.ifType, got, spec.kHz)
+		}
+		if got := spec.ifType.Hz(); got != spec.kHz*1000 {
+			t.Errorf("wrong Hz for %v: got %v, want %v", spec.ifType, got, spec.kHz*1000)
+		}
+		if got := spec.ifType.Describe(); got != spec.want {
+			t.Errorf("wrong description: got %q, want %q", got, spec.want)
+		}
+	}
+}
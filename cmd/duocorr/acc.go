@@ -43,6 +43,9 @@ func (a *IntAccumulator) Add(x int) {
 }
 
 func (a *IntAccumulator) Analyze() IntStats {
+	if a.stats.Count == 0 {
+		return IntStats{}
+	}
 	sort.Ints(a.vals)
 	a.stats.Median = a.vals[len(a.vals)/2]
 	a.stats.Mean = a.sum / float64(a.stats.Count)
@@ -98,6 +101,9 @@ func (a *FloatAccumulator) Add(x float64) {
 }
 
 func (a *FloatAccumulator) Analyze() FloatStats {
+	if a.stats.Count == 0 {
+		return FloatStats{}
+	}
 	sort.Float64s(a.vals)
 	a.stats.Median = a.vals[len(a.vals)/2]
 	a.stats.Mean = a.sum / float64(a.stats.Count)
@@ -0,0 +1,25 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestAccumulatorEmpty(t *testing.T) {
+	t.Parallel()
+
+	// An interval in which every correlation missed adds nothing.
+	var ia IntAccumulator
+	ia.Add(3)
+	ia.Reset()
+	if got := ia.Analyze(); got != (IntStats{}) {
+		t.Errorf("wrong int stats: got %+v, want %+v", got, IntStats{})
+	}
+	var fa FloatAccumulator
+	fa.Add(3)
+	fa.Reset()
+	if got := fa.Analyze(); got != (FloatStats{}) {
+		t.Errorf("wrong float stats: got %+v, want %+v", got, FloatStats{})
+	}
+}
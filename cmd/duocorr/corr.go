@@ -5,16 +5,16 @@
 package main
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/msiner/sdrplay-go/helpers/callback"
 	"github.com/msiner/sdrplay-go/helpers/duo"
 )
 
-const (
-	corrMaxOffset = 20
-	corrWidth     = corrMaxOffset*2 + 1
-)
+// corrMinZScore is the smallest z-score of a correlation peak that is
+// reported as found.
+const corrMinZScore = 4
 
 // Correlation holds the results of a single correlation pass.
 type Correlation struct {
@@ -23,6 +23,10 @@ type Correlation struct {
 	ZScore  float64
 	Stats   FloatStats
 	Raw     []float64
+	// Found is false if the peak is not significant or if the
+	// correlation just outside of the search window is larger, which
+	// indicates that the true offset is beyond it.
+	Found bool
 }
 
 // CorrelationFn is a function type that processes a SynchroMsg
@@ -34,10 +38,29 @@ type CorrelationFn func(msg *duo.SynchroMsg) Correlation
 // is hand-written in order to avoid a module dependency on a
 // third-party DSP library. This is a naive brute-force
 // cross-correlation implementation.
-func NewCorrelationFn(cbSamples int) CorrelationFn {
-	amag := make([]float64, cbSamples)
-	bmag := make([]float64, cbSamples)
-	corr := make([]float64, corrWidth)
+//
+// The correlation is computed for offsets from -maxLag to maxLag
+// samples over messages of corrLen samples, so the cost is
+// proportional to corrLen*maxLag. Each message must have at least
+// corrLen samples. One extra offset on each side of the window is
+// computed to detect a peak beyond it.
+func NewCorrelationFn(corrLen, maxLag int) (CorrelationFn, error) {
+	if maxLag < 1 {
+		return nil, fmt.Errorf("invalid max lag: got %d, want > 0", maxLag)
+	}
+	// The window of a, excluding the guard offsets, must not be empty.
+	if corrLen <= 2*(maxLag+1) {
+		return nil, fmt.Errorf("invalid correlation length: got %d, want > %d", corrLen, 2*(maxLag+1))
+	}
+
+	var (
+		// Search one offset beyond maxLag on each side.
+		guard = maxLag + 1
+		width = maxLag*2 + 1
+	)
+	amag := make([]float64, corrLen)
+	bmag := make([]float64, corrLen)
+	corr := make([]float64, width+2)
 	aToCx := callback.NewConvertToComplex64Fn(16)
 	bToCx := callback.NewConvertToComplex64Fn(16)
 	acc := FloatAccumulator{}
@@ -60,11 +83,11 @@ func NewCorrelationFn(cbSamples int) CorrelationFn {
 			bmag[i] = math.Hypot(float64(real(b)), float64(imag(b)))
 		}
 
-		currOffset := -corrMaxOffset
-		awin := amag[corrMaxOffset : len(amag)-corrMaxOffset]
+		currOffset := -guard
+		awin := amag[guard : len(amag)-guard]
 		for i := range corr {
 			corr[i] = 0
-			bwin := bmag[corrMaxOffset+currOffset : corrMaxOffset+currOffset+len(awin)]
+			bwin := bmag[guard+currOffset : guard+currOffset+len(awin)]
 			for j, a := range awin {
 				corr[i] += a * bwin[j]
 			}
@@ -75,8 +98,9 @@ func NewCorrelationFn(cbSamples int) CorrelationFn {
 			max  float64
 			maxi int
 			sum  float64
+			win  = corr[1 : width+1]
 		)
-		for i, x := range corr {
+		for i, x := range win {
 			acc.Add(x)
 			sum += x
 			if x > max {
@@ -85,19 +109,21 @@ func NewCorrelationFn(cbSamples int) CorrelationFn {
 			}
 		}
 
-		mean := sum / float64(len(corr))
+		mean := sum / float64(len(win))
 		stats := acc.Analyze()
+		zscore := (max - mean) / stats.StdDev
 
-		pos := maxi - (corrWidth / 2)
+		pos := maxi - maxLag
 
 		res := Correlation{
 			Peak:    pos,
 			PeakRaw: max,
-			ZScore:  (max - mean) / stats.StdDev,
+			ZScore:  zscore,
 			Stats:   stats,
-			Raw:     make([]float64, corrWidth),
+			Raw:     make([]float64, width),
+			Found:   zscore >= corrMinZScore && max > corr[0] && max > corr[width+1],
 		}
-		copy(res.Raw, corr)
+		copy(res.Raw, win)
 		return res
-	}
+	}, nil
 }
@@ -16,9 +16,13 @@ func TestNewCorrelationFn(t *testing.T) {
 	const (
 		numSamples = 4096
 		numIters   = 100
+		maxLag     = 20
 	)
 
-	corr := NewCorrelationFn(numSamples)
+	corr, err := NewCorrelationFn(numSamples, maxLag)
+	if err != nil {
+		t.Fatal(err)
+	}
 	msg := &duo.SynchroMsg{
 		Xia:   make([]int16, numSamples),
 		Xqa:   make([]int16, numSamples),
@@ -72,7 +76,7 @@ func TestNewCorrelationFn(t *testing.T) {
 
 	copy(msg.Xib, msg.Xia)
 	copy(msg.Xqb, msg.Xqa)
-	for i := 0; i < corrMaxOffset; i++ {
+	for i := 0; i < maxLag; i++ {
 		res := corr(msg)
 		if res.Peak != -i {
 			t.Errorf("wrong peak offset after shift left %d: got %d, want %d", i, res.Peak, -i)
@@ -81,13 +85,13 @@ func TestNewCorrelationFn(t *testing.T) {
 		shiftLeft(msg.Xqb)
 	}
 	res := corr(msg)
-	if res.Peak != -corrMaxOffset {
-		t.Errorf("wrong peak offset after shift left %d: got %d, want %d", -corrMaxOffset, res.Peak, -corrMaxOffset)
+	if res.Peak != -maxLag {
+		t.Errorf("wrong peak offset after shift left %d: got %d, want %d", -maxLag, res.Peak, -maxLag)
 	}
 
 	copy(msg.Xib, msg.Xia)
 	copy(msg.Xqb, msg.Xqa)
-	for i := 0; i < corrMaxOffset; i++ {
+	for i := 0; i < maxLag; i++ {
 		res := corr(msg)
 		if res.Peak != i {
 			t.Errorf("wrong peak offset after shift right %d: got %d, want %d", i, res.Peak, i)
@@ -96,15 +100,106 @@ func TestNewCorrelationFn(t *testing.T) {
 		shiftRight(msg.Xqb)
 	}
 	res = corr(msg)
-	if res.Peak != corrMaxOffset {
-		t.Errorf("wrong peak offset after shift left %d: got %d, want %d", corrMaxOffset, res.Peak, corrMaxOffset)
+	if res.Peak != maxLag {
+		t.Errorf("wrong peak offset after shift left %d: got %d, want %d", maxLag, res.Peak, maxLag)
+	}
+}
+
+func TestNewCorrelationFnArgs(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		corrLen, maxLag int
+		ok              bool
+	}{
+		{4096, 20, true},
+		{4096, 1, true},
+		{43, 20, true},
+		{42, 20, false},
+		{4096, 0, false},
+		{4096, -1, false},
+	}
+	for _, spec := range specs {
+		_, err := NewCorrelationFn(spec.corrLen, spec.maxLag)
+		switch {
+		case spec.ok && err != nil:
+			t.Errorf("unexpected error for corrLen=%d maxLag=%d: %v", spec.corrLen, spec.maxLag, err)
+		case !spec.ok && err == nil:
+			t.Errorf("missing error for corrLen=%d maxLag=%d", spec.corrLen, spec.maxLag)
+		}
+	}
+}
+
+func TestNewCorrelationFnMaxLag(t *testing.T) {
+	t.Parallel()
+
+	const (
+		numSamples = 4096
+		maxLag     = 100
+	)
+
+	rng := rand.New(rand.NewSource(1))
+	a := make([]int16, 2*(numSamples+4*maxLag))
+	for i := range a {
+		a[i] = int16(rng.Intn(2*math.MaxInt16) - math.MaxInt16)
+	}
+
+	specs := []struct {
+		delay int
+		found bool
+	}{
+		{0, true},
+		{maxLag - 1, true},
+		{maxLag, true},
+		{-maxLag, true},
+		{maxLag + 1, false},
+		{-(maxLag + 1), false},
+		{maxLag + 50, false},
+		{-(maxLag + 50), false},
+	}
+	for _, spec := range specs {
+		corr, err := NewCorrelationFn(numSamples, maxLag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// B lags A by delay samples. The interleaved I/Q pairs of a are
+		// offset by twice the delay.
+		start := 2 * (2 * maxLag)
+		msg := &duo.SynchroMsg{
+			Xia: make([]int16, numSamples),
+			Xqa: make([]int16, numSamples),
+			Xib: make([]int16, numSamples),
+			Xqb: make([]int16, numSamples),
+		}
+		for i := 0; i < numSamples; i++ {
+			ai := start + 2*i
+			bi := start + 2*(i-spec.delay)
+			msg.Xia[i], msg.Xqa[i] = a[ai], a[ai+1]
+			msg.Xib[i], msg.Xqb[i] = a[bi], a[bi+1]
+		}
+		res := corr(msg)
+		if res.Found != spec.found {
+			t.Errorf(
+				"wrong found for delay %d: got %v, want %v (peak=%d zscore=%v)",
+				spec.delay, res.Found, spec.found, res.Peak, res.ZScore,
+			)
+		}
+		if spec.found && res.Peak != spec.delay {
+			t.Errorf("wrong peak offset for delay %d: got %d, want %d", spec.delay, res.Peak, spec.delay)
+		}
+		if len(res.Raw) != 2*maxLag+1 {
+			t.Errorf("wrong raw length: got %d, want %d", len(res.Raw), 2*maxLag+1)
+		}
 	}
 }
 
 func BenchmarkNewCorrelationFn(b *testing.B) {
 	const numSamples = 4096
 
-	corr := NewCorrelationFn(numSamples)
+	corr, err := NewCorrelationFn(numSamples, 20)
+	if err != nil {
+		b.Fatal(err)
+	}
 	msg := &duo.SynchroMsg{
 		Xia:   make([]int16, numSamples),
 		Xqa:   make([]int16, numSamples),
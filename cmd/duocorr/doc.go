@@ -18,12 +18,31 @@ the two streams of an RSPduo in dual-tuner mode.
 	reported at the end of each interval period reflect all measurements
 	taken during that period.
 
+	The "-format" flag selects how the results are printed. The default
+	"text" format prints them as log lines. The "csv" format prints a
+	header row followed by one row per interval with the columns
+	interval, measurements, peak_mean, peak_stddev, peak_median,
+	peak_min, peak_max, z_mean, z_stddev, z_median, z_min, z_max, and
+	missed.
+	The "json" format prints one JSON object per line per interval. With
+	"csv" or "json", only the results are printed to standard out and all
+	other messages are printed to standard error.
+
 	Since the dual tuners of the RSPduo are not phase-coherent, duocorr
 	performs a correlation of the magnitudes of samples. This is reported
 	as the offset of the correlation peak in the number of samples plus
 	or minus from the center. The z-score of the correlation peak is also
 	computed and reported.
 
+	The correlation is computed over blocks of "-corrlen" samples for
+	offsets of up to "-maxlag" samples in each direction. The processing
+	time is proportional to the product of the two. A larger "-corrlen"
+	gives more reliable peaks and a larger "-maxlag" can measure a larger
+	offset, such as when the cable runs of the two antennas differ
+	significantly in length. A measurement without a significant peak
+	within the window, including one where the offset is larger than
+	"-maxlag", is counted as missed in the report.
+
 	duocorr is a very rudimentary tool included for testing purposes. It
 	can serve the practical application of measuring or detecting a time
 	offset in an antenna and cabling setup that might make dual-antenna
@@ -49,14 +68,19 @@ the two streams of an RSPduo in dual-tuner mode.
 	-agcset int
 			dBFS: AGC Set Point
 			AGC set point in dBFS. (default -30)
+	-corrlen int
+			number of samples per correlation (default 10000)
 	-dec uint
 			1|2|4|8|16|32: Decimation factor
 			Sets the decimation factor. This will reduce the effective sample rate.
 			The analog bandwidth will be adjusted automatically to use the best fit
 			as the effective sample rate decreases. (default 1)
+	-format string
+			report format: text, csv, or json (default "text")
 	-hiz
 			Enable High-Z Port
-			If using an RSP2 or RSPduo, enable the High-Z port.
+			If using an RSP2 or RSPduo, enable the High-Z port. The RSPduo High-Z
+			port is only available on tuner A.
 	-inter string
 			measurement reporting interval (default "1s")
 	-lna string
@@ -77,6 +101,8 @@ the two streams of an RSPduo in dual-tuner mode.
 			at the widest bandwidth. The default mode is also compatible with
 			analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 			6 MHz operation should result in a slightly lower CPU load.
+	-maxlag int
+			maximum correlation offset in samples (default 20)
 	-serials string
 			serialA,serialB,...: Device Serial Numbers
 			Provide a comma-separated list of one or more device serial numbers
@@ -40,12 +40,31 @@ flag determines the interval for printing results. The results
 reported at the end of each interval period reflect all measurements
 taken during that period.
 
+The "-format" flag selects how the results are printed. The default
+"text" format prints them as log lines. The "csv" format prints a
+header row followed by one row per interval with the columns
+interval, measurements, peak_mean, peak_stddev, peak_median,
+peak_min, peak_max, z_mean, z_stddev, z_median, z_min, z_max, and
+missed.
+The "json" format prints one JSON object per line per interval. With
+"csv" or "json", only the results are printed to standard out and all
+other messages are printed to standard error.
+
 Since the dual tuners of the RSPduo are not phase-coherent, duocorr
 performs a correlation of the magnitudes of samples. This is reported
 as the offset of the correlation peak in the number of samples plus
 or minus from the center. The z-score of the correlation peak is also
 computed and reported.
 
+The correlation is computed over blocks of "-corrlen" samples for
+offsets of up to "-maxlag" samples in each direction. The processing
+time is proportional to the product of the two. A larger "-corrlen"
+gives more reliable peaks and a larger "-maxlag" can measure a larger
+offset, such as when the cable runs of the two antennas differ
+significantly in length. A measurement without a significant peak
+within the window, including one where the offset is larger than
+"-maxlag", is counted as missed in the report.
+
 duocorr is a very rudimentary tool included for testing purposes. It
 can serve the practical application of measuring or detecting a time
 offset in an antenna and cabling setup that might make dual-antenna
@@ -89,6 +108,9 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 6 MHz operation should result in a slightly lower CPU load.`,
 	))
 	interOpt := flags.String("inter", "1s", "measurement reporting interval")
+	corrLenOpt := flags.Int("corrlen", 10000, "number of samples per correlation")
+	maxLagOpt := flags.Int("maxlag", 20, "maximum correlation offset in samples")
+	formatOpt := flags.String("format", "text", "report format: text, csv, or json")
 
 	// Using ExitOnError
 	_ = flags.Parse(os.Args[1:])
@@ -107,7 +129,18 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 		return errors.New("too many arguments")
 	}
 
-	lg := log.New(os.Stdout, "", log.LstdFlags)
+	format, err := ParseReportFormat(*formatOpt)
+	if err != nil {
+		return err
+	}
+
+	// Keep standard out parseable for machine-readable formats.
+	logOut := os.Stdout
+	if format != TextFormat {
+		logOut = os.Stderr
+	}
+	lg := log.New(logOut, "", log.LstdFlags)
+	reports := NewReportWriter(format, os.Stdout, lg)
 
 	freq, err := parse.TuneFrequency(flags.Arg(0))
 	if err != nil {
@@ -173,8 +206,11 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 	}
 
 	// Setup callback and control state.
-	const cbSamples = 10000
-	corr := NewCorrelationFn(cbSamples)
+	cbSamples := *corrLenOpt
+	corr, err := NewCorrelationFn(cbSamples, *maxLagOpt)
+	if err != nil {
+		return err
+	}
 	peak := IntAccumulator{}
 	zscore := FloatAccumulator{}
 	detectDropsA := callback.NewDropDetectFn()
@@ -220,30 +256,25 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 		},
 	)
 
-	var (
-		msgNum      uint64
-		msgNumValid bool
-	)
+	detectGap := duo.NewSynchroGapFn(true)
 	go func() {
+		interval, missed := 0, 0
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case msg := <-syncChan.C:
-				switch msgNumValid {
-				case true:
-					diff := msg.MsgNum - msgNum
-					if diff != 1 {
-						lg.Printf("dropped %d messages from synchro", diff)
-					}
-				default:
-					msgNumValid = true
+				if gap := detectGap(&msg); gap != 0 {
+					lg.Printf("dropped %d messages from synchro", gap)
 				}
-				msgNum = msg.MsgNum
 
 				res := corr(&msg)
-				peak.Add(res.Peak)
-				zscore.Add(res.ZScore)
+				if res.Found {
+					peak.Add(res.Peak)
+					zscore.Add(res.ZScore)
+				} else {
+					missed++
+				}
 
 				select {
 				case <-interTkr.C:
@@ -251,15 +282,18 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 					peak.Reset()
 					zstats := zscore.Analyze()
 					zscore.Reset()
-					lg.Printf("Report: measurements=%d\n", pstats.Count)
-					lg.Printf(
-						"Report: peak_offset(mean=%0.2f stddev=%0.2f median=%d min=%d max=%d)\n",
-						pstats.Mean, pstats.StdDev, pstats.Median, pstats.Min, pstats.Max,
-					)
-					lg.Printf(
-						"Report: peak_zscore(mean=%0.2f stddev=%0.2f median=%0.2f min=%0.2f max=%0.2f)\n",
-						zstats.Mean, zstats.StdDev, zstats.Median, zstats.Min, zstats.Max,
-					)
+					interval++
+					err := reports.Write(Report{
+						Interval: interval,
+						Missed:   missed,
+						Peak:     pstats,
+						ZScore:   zstats,
+					})
+					missed = 0
+					if err != nil {
+						lg.Printf("failed to write report: %v", err)
+						cancel()
+					}
 				default:
 				}
 			}
@@ -288,6 +322,7 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 				lnaCfg,
 				session.WithAGC(agcCtl, agcSet),
 				session.WithLogChannelParams("Tuner_A", lg),
+				session.WithPreflightLog("Tuner_A", lg),
 			),
 			session.WithDuoChannelBConfig(
 				session.WithLowIF(session.LowIFMaxBits, dec),
@@ -295,6 +330,7 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 				lnaCfg,
 				session.WithAGC(agcCtl, agcSet),
 				session.WithLogChannelParams("Tuner_B", lg),
+				session.WithPreflightLog("Tuner_B", lg),
 			),
 		),
 		session.WithStreamACallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
@@ -0,0 +1,168 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+)
+
+// ReportFormat selects how interval reports are written.
+type ReportFormat int
+
+// Supported report formats.
+const (
+	// TextFormat writes human-readable lines through the logger.
+	TextFormat ReportFormat = iota
+	// CSVFormat writes a header row followed by one row per interval.
+	CSVFormat
+	// JSONFormat writes one JSON object per line per interval.
+	JSONFormat
+)
+
+// ParseReportFormat parses the value of the -format flag.
+func ParseReportFormat(s string) (ReportFormat, error) {
+	switch s {
+	case "text":
+		return TextFormat, nil
+	case "csv":
+		return CSVFormat, nil
+	case "json":
+		return JSONFormat, nil
+	}
+	return 0, fmt.Errorf("invalid format: got %q, want text|csv|json", s)
+}
+
+// Report is the result of one reporting interval.
+type Report struct {
+	// Interval is the 1-based index of the interval.
+	Interval int
+	// Missed is the number of measurements without a significant
+	// correlation peak within the search window.
+	Missed int
+	Peak   IntStats
+	ZScore FloatStats
+}
+
+// csvHeader is the first row written in CSVFormat.
+var csvHeader = []string{
+	"interval", "measurements",
+	"peak_mean", "peak_stddev", "peak_median", "peak_min", "peak_max",
+	"z_mean", "z_stddev", "z_median", "z_min", "z_max",
+	"missed",
+}
+
+// jsonIntStats and jsonFloatStats are the JSON encodings of IntStats
+// and FloatStats without the count, which is reported once as
+// measurements.
+type jsonIntStats struct {
+	Mean   jsonFloat `json:"mean"`
+	StdDev jsonFloat `json:"stddev"`
+	Median int       `json:"median"`
+	Min    int       `json:"min"`
+	Max    int       `json:"max"`
+}
+
+type jsonFloatStats struct {
+	Mean   jsonFloat `json:"mean"`
+	StdDev jsonFloat `json:"stddev"`
+	Median jsonFloat `json:"median"`
+	Min    jsonFloat `json:"min"`
+	Max    jsonFloat `json:"max"`
+}
+
+// jsonFloat is a float64 that is encoded as null if it is NaN or
+// infinite, which JSON cannot represent. For example, the z-score of a
+// flat correlation, such as between two silent inputs, is NaN.
+type jsonFloat float64
+
+// MarshalJSON implements json.Marshaler.
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(v)
+}
+
+type jsonReport struct {
+	Interval     int            `json:"interval"`
+	Measurements int            `json:"measurements"`
+	Missed       int            `json:"missed"`
+	Peak         jsonIntStats   `json:"peak_offset"`
+	ZScore       jsonFloatStats `json:"peak_zscore"`
+}
+
+// ReportWriter writes interval reports in a ReportFormat.
+type ReportWriter struct {
+	format ReportFormat
+	lg     *log.Logger
+	csv    *csv.Writer
+	json   *json.Encoder
+	header bool
+}
+
+// NewReportWriter creates a ReportWriter. In TextFormat, reports are
+// written with lg. Otherwise, they are written to out without any
+// prefix so that the output can be parsed.
+func NewReportWriter(format ReportFormat, out io.Writer, lg *log.Logger) *ReportWriter {
+	return &ReportWriter{
+		format: format,
+		lg:     lg,
+		csv:    csv.NewWriter(out),
+		json:   json.NewEncoder(out),
+	}
+}
+
+// Write writes a single report.
+func (w *ReportWriter) Write(r Report) error {
+	p, z := r.Peak, r.ZScore
+	switch w.format {
+	case CSVFormat:
+		if !w.header {
+			if err := w.csv.Write(csvHeader); err != nil {
+				return err
+			}
+			w.header = true
+		}
+		f := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+		err := w.csv.Write([]string{
+			strconv.Itoa(r.Interval), strconv.Itoa(p.Count),
+			f(p.Mean), f(p.StdDev), strconv.Itoa(p.Median), strconv.Itoa(p.Min), strconv.Itoa(p.Max),
+			f(z.Mean), f(z.StdDev), f(z.Median), f(z.Min), f(z.Max),
+			strconv.Itoa(r.Missed),
+		})
+		if err != nil {
+			return err
+		}
+		w.csv.Flush()
+		return w.csv.Error()
+	case JSONFormat:
+		return w.json.Encode(jsonReport{
+			Interval:     r.Interval,
+			Measurements: p.Count,
+			Missed:       r.Missed,
+			Peak:         jsonIntStats{jsonFloat(p.Mean), jsonFloat(p.StdDev), p.Median, p.Min, p.Max},
+			ZScore: jsonFloatStats{
+				jsonFloat(z.Mean), jsonFloat(z.StdDev), jsonFloat(z.Median), jsonFloat(z.Min), jsonFloat(z.Max),
+			},
+		})
+	}
+	w.lg.Printf("Report: measurements=%d missed=%d\n", p.Count, r.Missed)
+	w.lg.Printf(
+		"Report: peak_offset(mean=%0.2f stddev=%0.2f median=%d min=%d max=%d)\n",
+		p.Mean, p.StdDev, p.Median, p.Min, p.Max,
+	)
+	w.lg.Printf(
+		"Report: peak_zscore(mean=%0.2f stddev=%0.2f median=%0.2f min=%0.2f max=%0.2f)\n",
+		z.Mean, z.StdDev, z.Median, z.Min, z.Max,
+	)
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseReportFormat(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		in   string
+		want ReportFormat
+		ok   bool
+	}{
+		{"text", TextFormat, true},
+		{"csv", CSVFormat, true},
+		{"json", JSONFormat, true},
+		{"", 0, false},
+		{"CSV", 0, false},
+		{"xml", 0, false},
+	}
+	for _, spec := range specs {
+		got, err := ParseReportFormat(spec.in)
+		switch {
+		case spec.ok && err != nil:
+			t.Errorf("unexpected error for %q: %v", spec.in, err)
+		case !spec.ok && err == nil:
+			t.Errorf("missing error for %q", spec.in)
+		case got != spec.want:
+			t.Errorf("wrong format for %q: got %v, want %v", spec.in, got, spec.want)
+		}
+	}
+}
+
+var testReports = []Report{
+	{
+		Interval: 1,
+		Missed:   2,
+		Peak:     IntStats{Count: 10, Mean: -1.5, StdDev: 0.25, Median: -1, Min: -3, Max: 2},
+		ZScore:   FloatStats{Count: 10, Mean: 12.5, StdDev: 1.125, Median: 12, Min: 9.75, Max: 15},
+	},
+	{
+		Interval: 2,
+		Peak:     IntStats{Count: 1, Median: 0},
+		ZScore:   FloatStats{Count: 1, Mean: 3, Median: 3, Min: 3, Max: 3},
+	},
+}
+
+func TestReportWriterCSV(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := NewReportWriter(CSVFormat, &buf, log.New(&buf, "", 0))
+	for _, r := range testReports {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := strings.Join([]string{
+		"interval,measurements,peak_mean,peak_stddev,peak_median,peak_min,peak_max,z_mean,z_stddev,z_median,z_min,z_max,missed",
+		"1,10,-1.5,0.25,-1,-3,2,12.5,1.125,12,9.75,15,2",
+		"2,1,0,0,0,0,0,3,0,3,3,3,0",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("wrong CSV output: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReportWriterJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := NewReportWriter(JSONFormat, &buf, log.New(&buf, "", 0))
+	for _, r := range testReports {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(testReports) {
+		t.Fatalf("wrong number of lines: got %d, want %d", len(lines), len(testReports))
+	}
+	for i, line := range lines {
+		var got jsonReport
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("failed to decode line %d: %v", i, err)
+		}
+		r := testReports[i]
+		want := jsonReport{
+			Interval:     r.Interval,
+			Measurements: r.Peak.Count,
+			Missed:       r.Missed,
+			Peak:         jsonIntStats{jsonFloat(r.Peak.Mean), jsonFloat(r.Peak.StdDev), r.Peak.Median, r.Peak.Min, r.Peak.Max},
+			ZScore: jsonFloatStats{
+				jsonFloat(r.ZScore.Mean), jsonFloat(r.ZScore.StdDev), jsonFloat(r.ZScore.Median),
+				jsonFloat(r.ZScore.Min), jsonFloat(r.ZScore.Max),
+			},
+		}
+		if got != want {
+			t.Errorf("wrong report %d: got %+v, want %+v", i, got, want)
+		}
+	}
+	// Check the field names, which are part of the output format.
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"interval", "measurements", "missed", "peak_offset", "peak_zscore"} {
+		if _, ok := fields[k]; !ok {
+			t.Errorf("missing field %q in %s", k, lines[0])
+		}
+	}
+}
+
+func TestReportWriterNonFinite(t *testing.T) {
+	t.Parallel()
+
+	// A flat correlation window, such as between two silent inputs, has
+	// a NaN z-score.
+	nan, inf := math.NaN(), math.Inf(1)
+	r := Report{
+		Interval: 3,
+		Peak:     IntStats{Count: 2, Mean: nan},
+		ZScore:   FloatStats{Count: 2, Mean: nan, StdDev: nan, Median: nan, Min: -inf, Max: inf},
+	}
+	var buf bytes.Buffer
+	w := NewReportWriter(JSONFormat, &buf, log.New(&buf, "", 0))
+	if err := w.Write(r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got struct {
+		Peak   map[string]interface{} `json:"peak_offset"`
+		ZScore map[string]interface{} `json:"peak_zscore"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if v, ok := got.Peak["mean"]; !ok || v != nil {
+		t.Errorf("wrong peak mean: got %v, want null", v)
+	}
+	for _, k := range []string{"mean", "stddev", "median", "min", "max"} {
+		if v, ok := got.ZScore[k]; !ok || v != nil {
+			t.Errorf("wrong z-score %s: got %v, want null", k, v)
+		}
+	}
+
+	// The other formats are unaffected.
+	buf.Reset()
+	if err := NewReportWriter(CSVFormat, &buf, log.New(&buf, "", 0)).Write(r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "NaN,NaN,NaN,-Inf,+Inf") {
+		t.Errorf("wrong CSV output: got %q", buf.String())
+	}
+}
+
+func TestReportWriterText(t *testing.T) {
+	t.Parallel()
+
+	var out, logs bytes.Buffer
+	w := NewReportWriter(TextFormat, &out, log.New(&logs, "", 0))
+	if err := w.Write(testReports[0]); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"Report: measurements=10 missed=2",
+		"Report: peak_offset(mean=-1.50 stddev=0.25 median=-1 min=-3 max=2)",
+		"Report: peak_zscore(mean=12.50 stddev=1.12 median=12.00 min=9.75 max=15.00)",
+		"",
+	}, "\n")
+	if got := logs.String(); got != want {
+		t.Errorf("wrong text output: got\n%s\nwant\n%s", got, want)
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected output: got %q", out.String())
+	}
+}
@@ -14,6 +14,29 @@ the two streams of an RSPduo in dual-tuner mode.
 	are then interleaved and framed in the payload as:
 	[Ia1,Qa1,Ib1,Qb1,Ia2,Qa2,Ib2,Qb2,...,IaN,QaN,IbN,QbN]
 
+	By default, each component is a 16-bit signed integer. If -float is
+	specified, each component is instead a 32-bit IEEE 754 float scaled
+	to the range [-1.0, 1.0].
+
+	On a clean exit, any samples that did not fill a complete packet are
+	sent in a final packet that is zero-padded to the full payload size.
+
+	With -split, the samples of tuner A are sent to the -remoteA target and
+	the samples of tuner B are sent to the -remoteB target instead of both
+	being interleaved in a single stream to the -remote target. Each payload
+	is then framed as [I1,Q1,I2,Q2,...,IN,QN], so the frame size is 4 bytes
+	for int16 or 8 bytes with -float. Both targets are fed from the same
+	synchronized callback, so the Nth packet sent to each target covers the
+	same sample times. With -seq, packets with the same sequence number on
+	the two targets hold simultaneous samples.
+
+	With -testsignal, duoudp does not open an RSP device or the SDRplay API
+	service. Instead, a test source generates the apitest.Pattern test
+	pattern for tuner A and its complex conjugate, apitest.PatternB, for
+	tuner B at the configured rate and feeds them through the normal output
+	path. This is intended only for validating downstream processing
+	without hardware.
+
 	Arguments:
 	tuneHz
 			Tuner RF frequency in Hz is a mandatory argument. It can
@@ -34,9 +57,12 @@ the two streams of an RSPduo in dual-tuner mode.
 			Sets the decimation factor. This will reduce the effective sample rate.
 			The analog bandwidth will be adjusted automatically to use the best fit
 			as the effective sample rate decreases. (default 1)
+	-float
+			Write samples in floating-point format
 	-hiz
 			Enable High-Z Port
-			If using an RSP2 or RSPduo, enable the High-Z port.
+			If using an RSP2 or RSPduo, enable the High-Z port. The RSPduo High-Z
+			port is only available on tuner A.
 	-lna string
 			0-27|0%-100%: LNA State or Percent
 			Sets the LNA level. Without a % suffix, is an LNA state where 0 provides
@@ -57,10 +83,17 @@ the two streams of an RSPduo in dual-tuner mode.
 			6 MHz operation should result in a slightly lower CPU load.
 	-pay uint
 			UDP payload size in bytes. This must be small enough to fit in
-			the network MTU with IP and UDP headers. It must also be a multiple
-			of the 4 byte frame size. (default 1400)
+			the network MTU with IP and UDP headers. After the 8 bytes used
+			by -seq, if any, it must also be a multiple of the frame size, 8
+			bytes for int16 or 16 bytes with -float. With -split, the frame
+			size is 4 bytes for int16 or 8 bytes with -float. If not set, the
+			default is reduced as necessary to fit whole frames. (default 1400)
 	-remote string
 			Target host address or name and UDP port (default "127.0.0.1:1234")
+	-remoteA string
+			Target host address or name and UDP port for tuner A with -split (default "127.0.0.1:1235")
+	-remoteB string
+			Target host address or name and UDP port for tuner B with -split (default "127.0.0.1:1236")
 	-seq
 			Insert a 64-bit sequence number at the beginning of each packet.
 			This will use 8 bytes of the specified payload size.
@@ -70,6 +103,15 @@ the two streams of an RSPduo in dual-tuner mode.
 			to select from. If a device with one of the provided serial numbers
 			is not found, no device will be selected. The value "any" matches
 			any serial number. (default "any")
+	-split
+			Send the samples of tuner A to -remoteA and the samples of tuner B
+			to -remoteB instead of interleaving both in a single stream to -remote.
+	-testsignal
+			Use Test Signal (testing only)
+			Do not open an RSP device or the SDRplay API service. Instead, feed the
+			normal processing chain with the apitest.Pattern test pattern, a complex
+			tone at 1/64 of the effective sample rate, generated at the configured
+			rate. This is intended only for validating downstream processing.
 	-usb string
 			isoch|bulk: USB Transfer Mode
 			Select to configure the device in either isochronous or bulk mode. (default "isoch")
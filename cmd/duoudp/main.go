@@ -10,6 +10,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -19,6 +20,7 @@ import (
 	"time"
 
 	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
 	"github.com/msiner/sdrplay-go/helpers/callback"
 	"github.com/msiner/sdrplay-go/helpers/duo"
 	"github.com/msiner/sdrplay-go/helpers/event"
@@ -27,6 +29,34 @@ import (
 	"github.com/msiner/sdrplay-go/session"
 )
 
+// defaultPayload is the default of -pay.
+const defaultPayload = 1400
+
+// payloadSize returns the UDP payload size for the -pay value pay, where
+// set is true if -pay was given explicitly. The payload after the
+// sequence header, if seq is true, must be a whole number of frames of
+// frameSize bytes. An explicit size that does not fit is an error, but
+// the default is reduced to the largest size that fits.
+func payloadSize(pay uint, set, seq bool, frameSize uint) (uint, error) {
+	var head uint
+	if seq {
+		head = 8
+	}
+	if pay <= head {
+		return 0, fmt.Errorf("payload size has no room for samples: got %d, want > %d", pay, head)
+	}
+	if rem := (pay - head) % frameSize; rem != 0 {
+		if set {
+			return 0, fmt.Errorf(
+				"payload size must be %d plus a multiple of frame size %d: got %d",
+				head, frameSize, pay,
+			)
+		}
+		pay -= rem
+	}
+	return pay, nil
+}
+
 func duoudp() error {
 	flags := flag.NewFlagSet("duoudp", flag.ExitOnError)
 	flags.Usage = func() {
@@ -39,6 +69,29 @@ duoudp will configure both A and B channels identically. The samples
 are then interleaved and framed in the payload as:
 [Ia1,Qa1,Ib1,Qb1,Ia2,Qa2,Ib2,Qb2,...,IaN,QaN,IbN,QbN]
 
+By default, each component is a 16-bit signed integer. If -float is
+specified, each component is instead a 32-bit IEEE 754 float scaled
+to the range [-1.0, 1.0].
+
+On a clean exit, any samples that did not fill a complete packet are
+sent in a final packet that is zero-padded to the full payload size.
+
+With -split, the samples of tuner A are sent to the -remoteA target and
+the samples of tuner B are sent to the -remoteB target instead of both
+being interleaved in a single stream to the -remote target. Each payload
+is then framed as [I1,Q1,I2,Q2,...,IN,QN], so the frame size is 4 bytes
+for int16 or 8 bytes with -float. Both targets are fed from the same
+synchronized callback, so the Nth packet sent to each target covers the
+same sample times. With -seq, packets with the same sequence number on
+the two targets hold simultaneous samples.
+
+With -testsignal, duoudp does not open an RSP device or the SDRplay API
+service. Instead, a test source generates the apitest.Pattern test
+pattern for tuner A and its complex conjugate, apitest.PatternB, for
+tuner B at the configured rate and feeds them through the normal output
+path. This is intended only for validating downstream processing
+without hardware.
+
 Arguments:
   tuneHz
 	Tuner RF frequency in Hz is a mandatory argument. It can
@@ -53,10 +106,23 @@ Flags:
 	remoteOpt := flags.String("remote", "127.0.0.1:1234", strings.TrimSpace(`
 Target host address or name and UDP port`,
 	))
-	payOpt := flags.Uint("pay", 1400, strings.TrimSpace(`
+	remoteAOpt := flags.String("remoteA", "127.0.0.1:1235", strings.TrimSpace(`
+Target host address or name and UDP port for tuner A with -split`,
+	))
+	remoteBOpt := flags.String("remoteB", "127.0.0.1:1236", strings.TrimSpace(`
+Target host address or name and UDP port for tuner B with -split`,
+	))
+	splitOpt := flags.Bool("split", false, strings.TrimSpace(`
+Send the samples of tuner A to -remoteA and the samples of tuner B
+to -remoteB instead of interleaving both in a single stream to -remote.`,
+	))
+	payOpt := flags.Uint("pay", defaultPayload, strings.TrimSpace(`
 UDP payload size in bytes. This must be small enough to fit in
-the network MTU with IP and UDP headers. It must also be a multiple
-of the 4 byte frame size.`,
+the network MTU with IP and UDP headers. After the 8 bytes used
+by -seq, if any, it must also be a multiple of the frame size, 8
+bytes for int16 or 16 bytes with -float. With -split, the frame
+size is 4 bytes for int16 or 8 bytes with -float. If not set, the
+default is reduced as necessary to fit whole frames.`,
 	))
 	seqOpt := flags.Bool("seq", false, strings.TrimSpace(`
 Insert a 64-bit sequence number at the beginning of each packet.
@@ -70,6 +136,7 @@ This will use 8 bytes of the specified payload size.`,
 	serialsOpt := flags.String("serials", "any", parse.SerialsFlagHelp)
 	usbOpt := flags.String("usb", "isoch", parse.USBFlagHelp)
 	hizOpt := flags.Bool("hiz", false, parse.HiZFlagHelp)
+	testSignalOpt := flags.Bool("testsignal", false, parse.TestSignalFlagHelp)
 	maxFsOpt := flags.Bool("maxfs", false, strings.TrimSpace(`
 Use the maximum 8MHz sample rate.
 This will deliver 12-bit ADC resolution, but with slightly better
@@ -82,6 +149,7 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 6 MHz operation should result in a slightly lower CPU load.`,
 	))
 	bigOpt := flags.Bool("big", false, "Write samples with big-endian byte order")
+	floatOpt := flags.Bool("float", false, "Write samples in floating-point format")
 
 	// Using ExitOnError
 	_ = flags.Parse(os.Args[1:])
@@ -158,31 +226,74 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 		order = binary.BigEndian
 	}
 
-	addr, err := net.ResolveUDPAddr("udp", *remoteOpt)
-	if err != nil {
-		return err
+	// One target for the interleaved stream or, with -split, one
+	// target for each tuner.
+	remotes := []string{*remoteOpt}
+	numChannels := uint(2)
+	if *splitOpt {
+		remotes = []string{*remoteAOpt, *remoteBOpt}
+		numChannels = 1
 	}
+	conns := make([]*net.UDPConn, len(remotes))
+	for i, remote := range remotes {
+		addr, err := net.ResolveUDPAddr("udp", remote)
+		if err != nil {
+			return err
+		}
 
-	conn, err := net.DialUDP(addr.Network(), nil, addr)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	lg.Printf("UDP initialized: local=%v remote=%v", conn.LocalAddr(), conn.RemoteAddr())
+		conn, err := net.DialUDP(addr.Network(), nil, addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conns[i] = conn
 
-	if *payOpt%4 != 0 {
-		return fmt.Errorf("payload size must be multiple of frame size: got %d", *payOpt)
+		lg.Printf("UDP initialized: local=%v remote=%v", conn.LocalAddr(), conn.RemoteAddr())
 	}
 
-	lg.Printf("Payload Size: %d B", *payOpt)
-
-	// Setup callback and control state.
-	write, err := udp.NewPacketWriteFn(*payOpt, 4, *seqOpt, order)
+	frameSize := 4 * numChannels
+	if *floatOpt {
+		frameSize = 8 * numChannels
+	}
+	paySet := false
+	flags.Visit(func(f *flag.Flag) {
+		paySet = paySet || f.Name == "pay"
+	})
+	pay, err := payloadSize(*payOpt, paySet, *seqOpt, frameSize)
 	if err != nil {
 		return err
 	}
+
+	lg.Printf("Payload Size: %d B", pay)
+
+	// Setup callback and control state. There is one packet writer for
+	// each target.
+	var (
+		writes         = make([]udp.PacketWriteFn, len(conns))
+		writeComplexes = make([]udp.Complex64PacketWriteFn, len(conns))
+		flushes        = make([]func(out io.Writer, pad bool) (int, error), len(conns))
+	)
+	for i := range conns {
+		if *floatOpt {
+			w, err := udp.NewComplex64PacketWriter(pay, numChannels, *seqOpt, order)
+			if err != nil {
+				return err
+			}
+			writeComplexes[i], flushes[i] = w.Write, w.Flush
+		} else {
+			w, err := udp.NewPacketWriter(pay, 2*numChannels, *seqOpt, order)
+			if err != nil {
+				return err
+			}
+			writes[i], flushes[i] = w.Write, w.Flush
+		}
+	}
 	interleave := duo.NewInterleaveFn()
+	interleaveA := callback.NewInterleaveFn()
+	interleaveB := callback.NewInterleaveFn()
+	convertA := callback.NewConvertToComplex64Fn(16)
+	convertB := callback.NewConvertToComplex64Fn(16)
+	var xc []complex64
 	detectDropsA := callback.NewDropDetectFn()
 	detectDropsB := callback.NewDropDetectFn()
 
@@ -223,8 +334,34 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 
 			// At this point, we have 4 synchronized components
 			// with the same slice length.
-			x := interleave(xia, xqa, xib, xqb)
-			if _, err := write(conn, x); err != nil {
+			var err error
+			switch {
+			case *splitOpt && *floatOpt:
+				_, err = writeComplexes[0](conns[0], convertA(xia, xqa))
+				if err == nil {
+					_, err = writeComplexes[1](conns[1], convertB(xib, xqb))
+				}
+			case *splitOpt:
+				_, err = writes[0](conns[0], interleaveA(xia, xqa))
+				if err == nil {
+					_, err = writes[1](conns[1], interleaveB(xib, xqb))
+				}
+			case *floatOpt:
+				xa := convertA(xia, xqa)
+				xb := convertB(xib, xqb)
+				if cap(xc) < 2*len(xa) {
+					xc = make([]complex64, 2*len(xa))
+				}
+				xc = xc[:2*len(xa)]
+				for i := range xa {
+					xc[2*i] = xa[i]
+					xc[2*i+1] = xb[i]
+				}
+				_, err = writeComplexes[0](conns[0], xc)
+			default:
+				_, err = writes[0](conns[0], interleave(xia, xqa, xib, xqb))
+			}
+			if err != nil {
 				lg.Println(err)
 				cancel()
 				return
@@ -235,8 +372,21 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 		},
 	)
 
+	// A nil implementation selects the real API.
+	var impl api.API
+	if *testSignalOpt {
+		lg.Println("WARNING: using test signal instead of RSP device")
+		impl = apitest.NewSignal(session.LowIFSampleRate/float64(dec), &api.DeviceT{
+			SerNo:      api.ParseSerialNumber("TESTSIGNAL"),
+			HWVer:      api.RSPduo_ID,
+			Tuner:      api.Tuner_Both,
+			RspDuoMode: api.RspDuoMode_Single_Tuner | api.RspDuoMode_Dual_Tuner,
+		})
+	}
+
 	err = session.Run(
 		ctx,
+		session.WithImplementation(impl),
 		session.WithSelector(
 			serialsFilter,
 			session.WithRSPduo(),
@@ -257,6 +407,7 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 				lnaCfg,
 				session.WithAGC(agcCtl, agcSet),
 				session.WithLogChannelParams("Tuner_A", lg),
+				session.WithPreflightLog("Tuner_A", lg),
 			),
 			session.WithDuoChannelBConfig(
 				session.WithLowIF(session.LowIFMaxBits, dec),
@@ -264,6 +415,7 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 				lnaCfg,
 				session.WithAGC(agcCtl, agcSet),
 				session.WithLogChannelParams("Tuner_B", lg),
+				session.WithPreflightLog("Tuner_B", lg),
 			),
 		),
 		session.WithStreamACallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
@@ -321,6 +473,12 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 	)
 	switch err {
 	case nil, context.Canceled:
+		// Send the tail of the stream that did not fill a packet.
+		for i, flush := range flushes {
+			if _, err := flush(conns[i], true); err != nil {
+				lg.Printf("failed to flush final packet: %v", err)
+			}
+		}
 		lg.Println("clean exit")
 	default:
 		return fmt.Errorf("error during session run: %v", err)
@@ -0,0 +1,186 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api/apitest"
+	"github.com/msiner/sdrplay-go/helpers/udp"
+)
+
+func TestPayloadSize(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		pay   uint
+		set   bool
+		seq   bool
+		split bool
+		float bool
+		want  uint
+	}{
+		{defaultPayload, false, false, false, false, 1400},
+		{defaultPayload, false, true, false, false, 1400},
+		{defaultPayload, false, false, false, true, 1392},
+		{defaultPayload, false, true, false, true, 1400},
+		{defaultPayload, false, false, true, false, 1400},
+		{defaultPayload, false, true, true, false, 1400},
+		{defaultPayload, false, false, true, true, 1400},
+		{defaultPayload, false, true, true, true, 1400},
+		{1024, true, true, true, false, 1024},
+		{1032, true, true, false, true, 1032},
+		{1408, true, false, false, true, 1408},
+		{1400, true, false, false, true, 0},
+		{1408, true, true, false, true, 0},
+		{1026, true, true, true, false, 0},
+		{8, true, true, false, false, 0},
+		{8, false, true, false, false, 0},
+	}
+	for _, spec := range specs {
+		numChannels := uint(2)
+		if spec.split {
+			numChannels = 1
+		}
+		frameSize := 4 * numChannels
+		if spec.float {
+			frameSize = 8 * numChannels
+		}
+		got, err := payloadSize(spec.pay, spec.set, spec.seq, frameSize)
+		if spec.want == 0 {
+			if err == nil {
+				t.Errorf("%+v: unexpected success: got %d", spec, got)
+			}
+			continue
+		}
+		if err != nil || got != spec.want {
+			t.Errorf("%+v: wrong payload size: got %d (%v), want %d", spec, got, err, spec.want)
+			continue
+		}
+
+		// The packet writers accept the result and fill packets.
+		if spec.float {
+			w, err := udp.NewComplex64PacketWriter(got, numChannels, spec.seq, binary.LittleEndian)
+			if err != nil {
+				t.Errorf("%+v: writer rejected payload size %d: %v", spec, got, err)
+				continue
+			}
+			if _, err := w.Write(ioutil.Discard, make([]complex64, 1000*numChannels)); err != nil {
+				t.Errorf("%+v: unexpected error: %v", spec, err)
+			}
+		} else {
+			w, err := udp.NewPacketWriter(got, 2*numChannels, spec.seq, binary.LittleEndian)
+			if err != nil {
+				t.Errorf("%+v: writer rejected payload size %d: %v", spec, got, err)
+				continue
+			}
+			if _, err := w.Write(ioutil.Discard, make([]int16, 2000*numChannels)); err != nil {
+				t.Errorf("%+v: unexpected error: %v", spec, err)
+			}
+		}
+	}
+}
+
+func TestTestSignalSplit(t *testing.T) {
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var conns [2]*net.UDPConn
+	for i := range conns {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{
+		"duoudp", "-testsignal", "-split", "-warm", "0", "-dec", "32", "-pay", "1024", "-seq",
+		"-remoteA", conns[0].LocalAddr().String(),
+		"-remoteB", conns[1].LocalAddr().String(),
+		"100M",
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- duoudp()
+	}()
+
+	// Each packet has a sequence number followed by 254 samples. Packets
+	// with the same sequence number on both targets must hold the same
+	// samples of the pattern, with tuner B being the conjugate.
+	const numSamples = (1024 - 8) / 4
+	var (
+		buf      = make([]byte, 2048)
+		offset   = -1
+		firstSeq uint64
+	)
+	patterns := [2]func(uint32) (int16, int16){apitest.Pattern, apitest.PatternB}
+	for c, conn := range conns {
+		for p := 0; p < 20; p++ {
+			if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 1024 {
+				t.Fatalf("wrong packet size: got %d, want 1024", n)
+			}
+			seq := binary.LittleEndian.Uint64(buf)
+			if offset < 0 {
+				// Samples from callbacks during the warm-up are
+				// discarded, so find the offset into the pattern from
+				// the first sample.
+				xi := int16(binary.LittleEndian.Uint16(buf[8:]))
+				xq := int16(binary.LittleEndian.Uint16(buf[10:]))
+				for m := 0; m < apitest.PatternPeriod; m++ {
+					if i, q := apitest.Pattern(uint32(m)); i == xi && q == xq {
+						offset = m
+						break
+					}
+				}
+				if offset < 0 {
+					t.Fatalf("first sample not in pattern: got (%d,%d)", xi, xq)
+				}
+				firstSeq = seq
+			}
+			if seq < firstSeq {
+				t.Fatalf("wrong sequence number for target %d: got %d, want >= %d", c, seq, firstSeq)
+			}
+			start := offset + int(seq-firstSeq)*numSamples
+			for k := 0; k < numSamples; k++ {
+				xi := int16(binary.LittleEndian.Uint16(buf[8+4*k:]))
+				xq := int16(binary.LittleEndian.Uint16(buf[10+4*k:]))
+				i, q := patterns[c](uint32(start + k))
+				if xi != i || xq != q {
+					t.Fatalf("wrong sample %d of packet %d for target %d: got (%d,%d), want (%d,%d)", k, seq, c, xi, xq, i, q)
+				}
+			}
+		}
+	}
+
+	if err := self.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot interrupt duoudp: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("duoudp did not exit")
+	}
+}
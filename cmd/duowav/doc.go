@@ -17,6 +17,21 @@ the components of the two streams of an RSPduo in dual-tuner mode.
 	as 16-bit signed integers in little-endian format with the components
 	interleaved (e.g. AI1,AQ1,BI1,BQ1,...,AIn,AQn,BIn,BQn).
 
+	With -withmag, two channels are appended to each frame containing the
+	magnitude of the tuner A and tuner B complex samples respectively
+	(e.g. AI1,AQ1,BI1,BQ1,AM1,BM1,...). The magnitude uses the same scale
+	and format as the I and Q components.
+
+	With -swapctl, duowav reads commands from stdin, one per line, while
+	recording. The "swap" command swaps the ADC sample rate of both tuners
+	between 6 MHz and 8 MHz, as if -maxfs was toggled, without stopping the
+	recording. Both rates are down-converted to the same 2 MHz rate before
+	decimation, so the sample rate in the WAV header remains accurate, but
+	the stream is interrupted and the two tuners are resynchronized. Samples
+	may be lost at the swap, so each swap is marked with a point in a "cue "
+	chunk after the samples. The point is at the first frame recorded after
+	the swap and is labeled with the new ADC sample rate (e.g. "fs=8MHz").
+
 	Arguments:
 	tuneHz
 			Tuner RF frequency in Hz is a mandatory argument. It can
@@ -46,7 +61,8 @@ the components of the two streams of an RSPduo in dual-tuner mode.
 			Write samples in floating-point format
 	-hiz
 			Enable High-Z Port
-			If using an RSP2 or RSPduo, enable the High-Z port.
+			If using an RSP2 or RSPduo, enable the High-Z port. The RSPduo High-Z
+			port is only available on tuner A.
 	-lna string
 			0-27|0%-100%: LNA State or Percent
 			Sets the LNA level. Without a % suffix, is an LNA state where 0 provides
@@ -73,6 +89,10 @@ the components of the two streams of an RSPduo in dual-tuner mode.
 			to select from. If a device with one of the provided serial numbers
 			is not found, no device will be selected. The value "any" matches
 			any serial number. (default "any")
+	-swapctl
+			Read commands from stdin while recording.
+			The "swap" command swaps the ADC sample rate between 6 MHz and 8 MHz
+			and marks the first frame after the swap with a WAV cue point.
 	-usb string
 			isoch|bulk: USB Transfer Mode
 			Select to configure the device in either isochronous or bulk mode. (default "isoch")
@@ -83,5 +103,7 @@ the components of the two streams of an RSPduo in dual-tuner mode.
 			typically encountered when the stream is first starting. During
 			the warmup period, samples are discarded. The maximum value allowed
 			is 60 seconds. (default 2)
+	-withmag
+			Append a magnitude channel per tuner to each frame
 */
 package main
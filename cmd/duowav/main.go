@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"os/signal"
 	"strings"
@@ -43,6 +44,21 @@ imaginary components respectively. The samples are stored
 as 16-bit signed integers in little-endian format with the components
 interleaved (e.g. AI1,AQ1,BI1,BQ1,...,AIn,AQn,BIn,BQn).
 
+With -withmag, two channels are appended to each frame containing the
+magnitude of the tuner A and tuner B complex samples respectively
+(e.g. AI1,AQ1,BI1,BQ1,AM1,BM1,...). The magnitude uses the same scale
+and format as the I and Q components.
+
+With -swapctl, duowav reads commands from stdin, one per line, while
+recording. The "swap" command swaps the ADC sample rate of both tuners
+between 6 MHz and 8 MHz, as if -maxfs was toggled, without stopping the
+recording. Both rates are down-converted to the same 2 MHz rate before
+decimation, so the sample rate in the WAV header remains accurate, but
+the stream is interrupted and the two tuners are resynchronized. Samples
+may be lost at the swap, so each swap is marked with a point in a "cue "
+chunk after the samples. The point is at the first frame recorded after
+the swap and is labeled with the new ADC sample rate (e.g. "fs=8MHz").
+
 Arguments:
   tuneHz
 	Tuner RF frequency in Hz is a mandatory argument. It can
@@ -81,6 +97,12 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 	))
 	floatOpt := flags.Bool("float", false, "Write samples in floating-point format")
 	bigOpt := flags.Bool("big", false, "Write samples with big-endian byte order")
+	withMagOpt := flags.Bool("withmag", false, "Append a magnitude channel per tuner to each frame")
+	swapCtlOpt := flags.Bool("swapctl", false, strings.TrimSpace(`
+Read commands from stdin while recording.
+The "swap" command swaps the ADC sample rate between 6 MHz and 8 MHz
+and marks the first frame after the swap with a WAV cue point.`,
+	))
 
 	// Using ExitOnError
 	_ = flags.Parse(os.Args[1:])
@@ -168,6 +190,13 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 		order = binary.BigEndian
 	}
 
+	// Number of WAV channels, which are the I and Q components of each
+	// tuner and, optionally, the magnitude for each tuner.
+	numChannels := uint16(4)
+	if *withMagOpt {
+		numChannels = 6
+	}
+
 	// Size of WAV sample, which is one component of an IQ sample.
 	bytesPerSample := uint8(2) // sizeof(int16)
 	sampleFormat := wav.LPCM
@@ -187,7 +216,7 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 	// Write the initial WAV header with 0 samples.
 	var totalBytes uint64
 	finalFs := uint32(session.LowIFSampleRate / float64(dec))
-	head, err := wav.NewHeader(finalFs, 4, bytesPerSample, sampleFormat, order, 0)
+	head, err := wav.NewHeader(finalFs, numChannels, bytesPerSample, sampleFormat, order, 0)
 	if err != nil {
 		return err
 	}
@@ -196,14 +225,34 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 	}
 	totalBytes += uint64(binary.Size(head))
 
+	// Cue points marking sample rate swaps. They are only accessed
+	// from the stream callbacks until the session is done.
+	var (
+		cues     wav.Cues
+		cueLabel string
+	)
+
 	// Before rspwav exits, seek back to the beginning and
 	// update the WAV header with the correct number of samples and
-	// flush the buffered writer.
+	// flush the buffered writer. Any cue points are written after
+	// the samples.
 	defer func() {
 		dataBytes := totalBytes - uint64(binary.Size(head))
-		numFrames := uint32(dataBytes / uint64(bytesPerSample) / 4)
+		numFrames := uint32(dataBytes / uint64(bytesPerSample) / uint64(numChannels))
 		lg.Printf("update WAV header: dataBytes=%d dataFrames=%d", dataBytes, numFrames)
 		head.Update(numFrames)
+		if len(cues) != 0 {
+			chunk, err := cues.Chunk(order)
+			if err == nil {
+				_, err = out.Write(chunk)
+			}
+			if err != nil {
+				lg.Printf("failed to write cue points: %v", err)
+			} else {
+				lg.Printf("write WAV cue points: %d", len(cues))
+				head.Riff.ChunkSize += uint32(len(chunk))
+			}
+		}
 		out.Flush()
 		_, err = fout.Seek(0, io.SeekStart)
 		if err != nil {
@@ -216,13 +265,22 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 
 	// Setup callback and control state.
 	interleave := duo.NewInterleaveFn()
+	interleaveMag := callback.NewMultiInterleaveFn()
+	magnitudeA := callback.NewMagnitudeFn()
+	magnitudeB := callback.NewMagnitudeFn()
 	toFloats := callback.NewConvertToFloat32Fn(16)
 	writeInts := callback.NewWriteFn(order)
 	writeFloats := callback.NewFloat32WriteFn(order)
 	detectDropsA := callback.NewDropDetectFn()
 	detectDropsB := callback.NewDropDetectFn()
 
-	var isWarm uint32
+	// The control loop sets swapped after a successful sample rate swap
+	// to have the next stream A callback reset the Synchro.
+	var (
+		isWarm   uint32
+		swapped  uint32
+		swapRate uint64
+	)
 	go func() {
 		time.Sleep(warm)
 		lg.Println("warm-up complete")
@@ -253,7 +311,22 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 			default:
 			}
 
-			x := interleave(xia, xqa, xib, xqb)
+			if reset && cueLabel != "" {
+				cues = append(cues, wav.CuePoint{
+					ID:    uint32(len(cues) + 1),
+					Frame: uint32((totalBytes - uint64(binary.Size(head))) / uint64(head.Fmt.BlockAlign)),
+					Label: cueLabel,
+				})
+				cueLabel = ""
+			}
+
+			var x []int16
+			switch *withMagOpt {
+			case true:
+				x = interleaveMag(xia, xqa, xib, xqb, magnitudeA(xia, xqa), magnitudeB(xib, xqb))
+			default:
+				x = interleave(xia, xqa, xib, xqb)
+			}
 
 			var (
 				n   int
@@ -280,6 +353,21 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 		},
 	)
 
+	// With -swapctl, each line read from stdin is a command.
+	cmdChan := make(chan string)
+	if *swapCtlOpt {
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				select {
+				case cmdChan <- strings.TrimSpace(scanner.Text()):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
 	err = session.Run(
 		ctx,
 		session.WithSelector(
@@ -302,6 +390,7 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 				lnaCfg,
 				session.WithAGC(agcCtl, agcSet),
 				session.WithLogChannelParams("Tuner_A", lg),
+				session.WithPreflightLog("Tuner_A", lg),
 			),
 			session.WithDuoChannelBConfig(
 				session.WithLowIF(session.LowIFMaxBits, dec),
@@ -309,6 +398,7 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 				lnaCfg,
 				session.WithAGC(agcCtl, agcSet),
 				session.WithLogChannelParams("Tuner_B", lg),
+				session.WithPreflightLog("Tuner_B", lg),
 			),
 		),
 		session.WithStreamACallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
@@ -327,6 +417,10 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 				lg.Printf("stream A: dropped %d samples %v\n", d, reset)
 				reset = true
 			}
+			if atomic.CompareAndSwapUint32(&swapped, 1, 0) {
+				cueLabel = fmt.Sprintf("fs=%vMHz", math.Float64frombits(atomic.LoadUint64(&swapRate))/1e6)
+				reset = true
+			}
 			synchro.StreamACallback(xi, xq, params, reset)
 		}),
 		session.WithStreamBCallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
@@ -360,6 +454,22 @@ analog bandwidths of 1.536 MHz, 600 kHz, 300 kHz, and 200 kHz.
 						lg.Printf("failed to handle power overload event: %v", err)
 						cancel()
 					}
+				case cmd := <-cmdChan:
+					switch cmd {
+					case "":
+						// ignore
+					case "swap":
+						rate, err := session.SwapDualTunerSampleRate(d, a)
+						if err != nil {
+							lg.Printf("failed to swap sample rate: %v", err)
+							continue
+						}
+						lg.Printf("swapped sample rate: fs=%vHz", rate)
+						atomic.StoreUint64(&swapRate, math.Float64bits(rate))
+						atomic.StoreUint32(&swapped, 1)
+					default:
+						lg.Printf("unknown command: got %q, want swap", cmd)
+					}
 				}
 			}
 		}),
@@ -0,0 +1,469 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/helpers/dsp"
+	"github.com/msiner/sdrplay-go/helpers/event"
+	"github.com/msiner/sdrplay-go/helpers/parse"
+	"github.com/msiner/sdrplay-go/session"
+)
+
+// levelMeter accumulates the mean power of the received samples
+// between reads. It is safe for concurrent use by a stream callback
+// and a reader.
+type levelMeter struct {
+	mu    sync.Mutex
+	sum   float64
+	count uint64
+}
+
+// add accumulates the power of each complex sample.
+func (m *levelMeter) add(xi, xq []int16) {
+	var sum float64
+	for i := range xi {
+		vi, vq := float64(xi[i]), float64(xq[i])
+		sum += vi*vi + vq*vq
+	}
+	m.mu.Lock()
+	m.sum += sum
+	m.count += uint64(len(xi))
+	m.mu.Unlock()
+}
+
+// read returns the mean power since the last read in dBFS, where 0 dBFS
+// is a full-scale complex sinusoid, and restarts the accumulation. It
+// returns -Inf if no samples were received.
+func (m *levelMeter) read() float64 {
+	m.mu.Lock()
+	sum, count := m.sum, m.count
+	m.sum, m.count = 0, 0
+	m.mu.Unlock()
+	if count == 0 || sum == 0 {
+		return math.Inf(-1)
+	}
+	const fullScale = 32768.0 * 32768.0
+	return 10 * math.Log10(sum/float64(count)/fullScale)
+}
+
+// peakLen is the number of the most recent samples used by peakMeter.
+const peakLen = 4096
+
+// peakMeter keeps the most recent samples to locate the strongest signal
+// with dsp.FindPeak. It is safe for concurrent use by a stream callback
+// and a reader.
+type peakMeter struct {
+	mu    sync.Mutex
+	fs    float64
+	buf   [peakLen]complex64
+	pos   int
+	count int
+}
+
+// setRate sets the effective sample rate of the samples.
+func (m *peakMeter) setRate(fs float64) {
+	m.mu.Lock()
+	m.fs = fs
+	m.mu.Unlock()
+}
+
+// add keeps the provided samples, scaled to [-1,1], replacing the
+// oldest samples.
+func (m *peakMeter) add(xi, xq []int16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range xi {
+		m.buf[m.pos] = complex(float32(xi[i])/32768, float32(xq[i])/32768)
+		m.pos = (m.pos + 1) % peakLen
+	}
+	m.count += len(xi)
+}
+
+// read returns the frequency offset and level in dBFS of the strongest
+// signal in the most recent samples and restarts the accumulation. It
+// returns false if no samples were received since the last read.
+func (m *peakMeter) read() (float64, float64, bool) {
+	m.mu.Lock()
+	n, fs := m.count, m.fs
+	if n > peakLen {
+		n = peakLen
+	}
+	x := make([]complex64, n)
+	for i := range x {
+		x[i] = m.buf[(m.pos-n+i+peakLen)%peakLen]
+	}
+	m.count = 0
+	m.mu.Unlock()
+	if n == 0 {
+		return 0, 0, false
+	}
+	offset, level := dsp.FindPeak(x, fs)
+	return offset, level, true
+}
+
+// status holds the most recent state reported by events. It is only
+// accessed from the control loop.
+type status struct {
+	gainKnown    bool
+	grdB         uint32
+	lnaGRdB      uint32
+	currGain     float64
+	overloaded   bool
+	lastOverload time.Time
+}
+
+// line formats the one-line status display.
+func (s *status) line(level, dropsPerSec, dropPct float64, now time.Time) string {
+	var overload string
+	switch {
+	case s.overloaded:
+		overload = "ACTIVE"
+	case s.lastOverload.IsZero():
+		overload = "none"
+	default:
+		overload = fmt.Sprintf("%s ago", now.Sub(s.lastOverload).Truncate(time.Second))
+	}
+	gain := "GR - dB"
+	if s.gainKnown {
+		gain = fmt.Sprintf("GR %d dB (LNA %d dB) gain %.1f dB", s.grdB, s.lnaGRdB, s.currGain)
+	}
+	return fmt.Sprintf(
+		"level %6.1f dBFS | drops %.0f/s (%.3f%%) | overload %s | %s",
+		level, dropsPerSec, dropPct, overload, gain,
+	)
+}
+
+func rspmon() error {
+	flags := flag.NewFlagSet("rspmon", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), strings.TrimSpace(`
+Usage: rspmon [FLAGS] <tuneHz>
+
+rspmon connects to an available RSP device, configures a single stream
+the same way as rspwav and rspudp, and prints a refreshing one-line
+status instead of writing the samples anywhere. It is intended for
+quick diagnostics, such as aiming an antenna or checking a USB link.
+
+The status line shows the mean signal level in dBFS, the rate and
+percentage of dropped samples, the power overload state, and the gain
+reduction reported by the most recent gain change event. The level and
+drop rate are measured over the last update interval, while the drop
+percentage covers the whole run. Power overload events are
+acknowledged, but the gain is never adjusted.
+
+With -peak, the status line also shows the frequency offset from the
+tuner frequency and the level of the strongest signal in the most recent
+4096 samples of each update interval.
+
+Arguments:
+  tuneHz
+	Tuner RF frequency in Hz is a mandatory argument. It can
+	be specified with k, K, m, M, g, or G suffix to indicate the
+	value is in kHz, MHz, or GHz respectively (e.g. 1.42G).
+
+Flags:
+`,
+		))
+		flags.PrintDefaults()
+	}
+	lifOpt := flags.Bool("lif", false, strings.TrimSpace(`
+Use low-IF mode. In low-IF mode, the effective sample rate, before decimation
+is 2 MHz. When -lif is specified, the -fs option cannot be used to configure
+the sample rate.`,
+	))
+	intervalOpt := flags.Duration("interval", time.Second, "Status update interval.")
+	lnaOpt := flags.String("lna", "50%", parse.LNAFlagHelp)
+	fsOpt := flags.String("fs", "6M", parse.FsFlagHelp)
+	decOpt := flags.Uint("dec", 1, parse.DecFlagHelp)
+	warmOpt := flags.Uint("warm", 2, parse.WarmFlagHelp)
+	agcCtlOpt := flags.String("agcctl", "enable", parse.AGCCtlFlagHelp)
+	agcSetOpt := flags.Int("agcset", -30, parse.AGCSetFlagHelp)
+	duoTunerOpt := flags.String("duotuner", "either", parse.DuoTunerFlagHelp)
+	serialsOpt := flags.String("serials", "any", parse.SerialsFlagHelp)
+	usbOpt := flags.String("usb", "isoch", parse.USBFlagHelp)
+	hizOpt := flags.Bool("hiz", false, parse.HiZFlagHelp)
+	dxAntOpt := flags.String("dxant", "a", parse.DxAntFlagHelp)
+	rsp2AntOpt := flags.String("rsp2ant", "a", parse.Rsp2AntFlagHelp)
+	peakOpt := flags.Bool("peak", false, "Show the frequency offset and level of the strongest signal.")
+
+	// Using ExitOnError
+	_ = flags.Parse(os.Args[1:])
+
+	switch flags.NArg() {
+	case 0:
+		flags.Usage()
+		return errors.New("missing tune frequency")
+	case 1:
+		// good
+	default:
+		flags.Usage()
+		return errors.New("too many arguments")
+	}
+
+	freq, err := parse.TuneFrequency(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *intervalOpt <= 0 {
+		return fmt.Errorf("invalid interval: got %v, want > 0", *intervalOpt)
+	}
+
+	fs, err := parse.FsFlag(*fsOpt)
+	if err != nil {
+		return err
+	}
+
+	dec, err := parse.DecFlag(*decOpt)
+	if err != nil {
+		return err
+	}
+
+	warm, err := parse.WarmFlag(*warmOpt)
+	if err != nil {
+		return err
+	}
+
+	agcCtl, err := parse.AGCCtlFlag(*agcCtlOpt)
+	if err != nil {
+		return err
+	}
+
+	agcSet, err := parse.AGCSetFlag(*agcSetOpt)
+	if err != nil {
+		return err
+	}
+
+	usb, err := parse.USBFlag(*usbOpt)
+	if err != nil {
+		return err
+	}
+
+	serials, err := parse.SerialsFlag(*serialsOpt)
+	if err != nil {
+		return err
+	}
+
+	duoTuner, err := parse.DuoTunerFlag(*duoTunerOpt)
+	if err != nil {
+		return err
+	}
+	duoTuner, err = parse.HiZDuoTuner(*hizOpt, duoTuner)
+	if err != nil {
+		return err
+	}
+
+	dxAnt, err := parse.DxAntFlag(*dxAntOpt)
+	if err != nil {
+		return err
+	}
+
+	rsp2Ant, err := parse.Rsp2AntFlag(*rsp2AntOpt)
+	if err != nil {
+		return err
+	}
+
+	lnaState, lnaPct, err := parse.LNAFlag(*lnaOpt)
+	lnaCfg := session.NoopChanConfig
+	switch {
+	case err != nil:
+		return err
+	case lnaState != nil:
+		lnaCfg = session.WithLNAState(*lnaState)
+	case lnaPct != nil:
+		lnaCfg = session.WithLNAPercent(*lnaPct)
+	}
+
+	// Check the options and get the correct function to configure
+	// the IF mode as zero or low.
+	var ifModeCfg session.ChanConfigFn
+	switch *lifOpt {
+	case true:
+		ifModeCfg = session.WithLowIF(session.LowIFMaxBits, dec)
+	default:
+		ifModeCfg = session.WithZeroIF(fs, dec)
+	}
+
+	var serialsFilter session.DevFilterFn
+	switch serials {
+	case nil:
+		serialsFilter = session.NoopDevFilter
+	default:
+		serialsFilter = session.WithSerials(serials...)
+	}
+
+	var duoTunerFilter session.DevFilterFn
+	switch duoTuner {
+	case parse.DuoTunerFlagA:
+		duoTunerFilter = session.WithDuoTunerA()
+	case parse.DuoTunerFlagB:
+		duoTunerFilter = session.WithDuoTunerB()
+	default:
+		duoTunerFilter = session.WithDuoTunerEither()
+	}
+
+	// Setup callback and control state.
+	var (
+		level levelMeter
+		peak  peakMeter
+	)
+	stats := session.NewTransferStats(0)
+	evtChan := event.NewChan(10)
+	defer evtChan.Close()
+
+	var isWarm uint32
+	go func() {
+		time.Sleep(warm)
+		log.Println("warm-up complete")
+		atomic.StoreUint32(&isWarm, 1)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		v, ok := <-sig
+		if ok {
+			log.Printf("signal: got %v", v)
+			cancel()
+		}
+	}()
+
+	err = session.Run(
+		ctx,
+		session.WithSelector(
+			serialsFilter,
+			duoTunerFilter,
+			session.WithDuoModeSingle(),
+		),
+		session.WithDeviceConfig(
+			func(d *api.DeviceT, p *api.DeviceParamsT) error {
+				switch d.HWVer {
+				case api.RSPduo_ID:
+					log.Printf("Device: %v,%v,%v,%v,%v\n", d.HWVer, d.SerNo, d.Tuner, d.RspDuoMode, d.RspDuoSampleFreq)
+				default:
+					log.Printf("Device: %v,%v\n", d.HWVer, d.SerNo)
+				}
+				return nil
+			},
+			session.WithTransferMode(usb),
+			session.WithHighZPortEnabled(*hizOpt),
+			session.WithDxAntennaSelect(dxAnt),
+			session.WithRsp2AntennaSelect(rsp2Ant),
+			session.WithSingleChannelConfig(
+				ifModeCfg,
+				session.WithTuneFreq(freq),
+				session.WithAGC(agcCtl, agcSet),
+				lnaCfg,
+				func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+					rate, err := session.GetEffectiveSampleRate(d, p, c)
+					if err != nil {
+						return err
+					}
+					log.Printf("RF Frequency: %v Hz\n", c.TunerParams.RfFreq.RfHz)
+					log.Printf("Effective Sample Rate: %v Hz\n", rate)
+					peak.setRate(rate)
+					for _, w := range session.Preflight(d, p, c) {
+						log.Printf("WARNING: %s\n", w)
+					}
+					return nil
+				},
+			),
+		),
+		session.WithStreamACallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			if atomic.LoadUint32(&isWarm) == 0 {
+				return
+			}
+
+			stats.StreamCallback(xi, xq, params, reset)
+			level.add(xi, xq)
+			if *peakOpt {
+				peak.add(xi, xq)
+			}
+		}),
+		session.WithEventCallback(evtChan.Callback),
+		session.WithControlLoop(func(_ context.Context, d *api.DeviceT, a api.API) error {
+			var (
+				st          status
+				lastDropped uint64
+			)
+			ticker := time.NewTicker(*intervalOpt)
+			defer ticker.Stop()
+			// Finish the status line before any following output.
+			defer fmt.Println()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case evt := <-evtChan.C:
+					switch evt.EventID {
+					case api.GainChange:
+						p := evt.Params.GainParams
+						st.gainKnown = true
+						st.grdB, st.lnaGRdB, st.currGain = p.GRdB, p.LnaGRdB, p.CurrGain
+					case api.PowerOverloadChange:
+						st.lastOverload = time.Now()
+						st.overloaded = evt.Params.PowerOverloadParams.PowerOverloadChangeType == api.Overload_Detected
+						if err := event.HandlePowerOverloadChangeMsg(d, a, evt, nil, false); err != nil {
+							return fmt.Errorf("failed to acknowledge power overload: %v", err)
+						}
+					case api.DeviceRemoved:
+						return errors.New("device removed")
+					}
+				case t := <-ticker.C:
+					r := stats.Report()
+					dropsPerSec := float64(r.Dropped-lastDropped) / intervalOpt.Seconds()
+					lastDropped = r.Dropped
+					line := st.line(level.read(), dropsPerSec, r.DropPercent, t)
+					if *peakOpt {
+						switch offset, peakLevel, ok := peak.read(); ok {
+						case true:
+							line += fmt.Sprintf(" | peak %+.3f kHz %.1f dBFS", offset/1e3, peakLevel)
+						default:
+							line += " | peak -"
+						}
+					}
+					fmt.Printf("\r%-130s", line)
+				}
+			}
+		}),
+	)
+	switch err {
+	case nil, context.Canceled:
+		log.Println("clean exit")
+	default:
+		return fmt.Errorf("error during session run: %v", err)
+	}
+
+	return nil
+}
+
+func main() {
+	err := rspmon()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -13,6 +13,19 @@ the a single stream from any RSP device.
 	are interleaved and framed in the payload as:
 	[I1,Q1,I2,Q2,...,IN,QN]
 
+	By default, each component is a 16-bit signed integer. If -float is
+	specified, each component is instead a 32-bit IEEE 754 float scaled
+	to the range [-1.0, 1.0].
+
+	On a clean exit, any samples that did not fill a complete packet are
+	sent in a final packet that is zero-padded to the full payload size.
+
+	With -testsignal, rspudp does not open an RSP device or the SDRplay API
+	service. Instead, a test source generates the apitest.Pattern test
+	pattern, a complex tone at 1/64 of the effective sample rate, at the
+	configured rate and feeds it through the normal output path. This is
+	intended only for validating downstream processing without hardware.
+
 	Arguments:
 	tuneHz
 			Tuner RF frequency in Hz is a mandatory argument. It can
@@ -37,11 +50,14 @@ the a single stream from any RSP device.
 			a|1|b|2|either: RSPDuo Tuner Selection
 			Select which RSPDuo tuner to use if the selected device is an RSPduo. If
 			"either" is specified, tuner A will be used if available. Otherwise, tuner
-			B will be used if available. If the selected device is not an RSPduo, this
-			option will have no effect. (default "either")
+			B will be used if available. If the High-Z port is enabled, "either"
+			selects only tuner A and "b" is an error. If the selected device is not
+			an RSPduo, this option will have no effect. (default "either")
 	-dxant string
 			a|b|c: RSPdx Antenna
 			Select RSPdx antenna input. (default "a")
+	-float
+			Write samples in floating-point format
 	-fs string
 			FsHz: Sample Rate
 			Sample rate between 2 MHz and 10 MHz specified in Hz. Can be specified
@@ -49,7 +65,8 @@ the a single stream from any RSP device.
 			or GHz respectively (e.g. 2.1M is equal to 2100000) (default "6M")
 	-hiz
 			Enable High-Z Port
-			If using an RSP2 or RSPduo, enable the High-Z port.
+			If using an RSP2 or RSPduo, enable the High-Z port. The RSPduo High-Z
+			port is only available on tuner A.
 	-lif
 			Use low-IF mode. In low-IF mode, the effective sample rate, before decimation
 			is 2 MHz. When -lif is specified, the -fs option cannot be used to configure
@@ -65,7 +82,7 @@ the a single stream from any RSP device.
 	-pay uint
 			UDP payload size in bytes. This must be small enough to fit in
 			the network MTU with IP and UDP headers. It must also be a multiple
-			of the 4 byte frame size. (default 1400)
+			of the frame size, 4 bytes for int16 or 8 bytes with -float. (default 1400)
 	-remote string
 			Target host address or name and UDP port (default "127.0.0.1:1234")
 	-rsp2ant string
@@ -80,6 +97,12 @@ the a single stream from any RSP device.
 			to select from. If a device with one of the provided serial numbers
 			is not found, no device will be selected. The value "any" matches
 			any serial number. (default "any")
+	-testsignal
+			Use Test Signal (testing only)
+			Do not open an RSP device or the SDRplay API service. Instead, feed the
+			normal processing chain with the apitest.Pattern test pattern, a complex
+			tone at 1/64 of the effective sample rate, generated at the configured
+			rate. This is intended only for validating downstream processing.
 	-usb string
 			isoch|bulk: USB Transfer Mode
 			Select to configure the device in either isochronous or bulk mode. (default "isoch")
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -19,6 +20,7 @@ import (
 	"time"
 
 	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
 	"github.com/msiner/sdrplay-go/helpers/callback"
 	"github.com/msiner/sdrplay-go/helpers/parse"
 	"github.com/msiner/sdrplay-go/helpers/udp"
@@ -36,6 +38,19 @@ sends the received samples to the specified UDP target. The samples
 are interleaved and framed in the payload as:
 [I1,Q1,I2,Q2,...,IN,QN]
 
+By default, each component is a 16-bit signed integer. If -float is
+specified, each component is instead a 32-bit IEEE 754 float scaled
+to the range [-1.0, 1.0].
+
+On a clean exit, any samples that did not fill a complete packet are
+sent in a final packet that is zero-padded to the full payload size.
+
+With -testsignal, rspudp does not open an RSP device or the SDRplay API
+service. Instead, a test source generates the apitest.Pattern test
+pattern, a complex tone at 1/64 of the effective sample rate, at the
+configured rate and feeds it through the normal output path. This is
+intended only for validating downstream processing without hardware.
+
 Arguments:
   tuneHz
 	Tuner RF frequency in Hz is a mandatory argument. It can
@@ -58,7 +73,7 @@ Target host address or name and UDP port`,
 	payOpt := flags.Uint("pay", 1400, strings.TrimSpace(`
 UDP payload size in bytes. This must be small enough to fit in
 the network MTU with IP and UDP headers. It must also be a multiple
-of the 4 byte frame size.`,
+of the frame size, 4 bytes for int16 or 8 bytes with -float.`,
 	))
 	seqOpt := flags.Bool("seq", false, strings.TrimSpace(`
 Insert a 64-bit sequence number at the beginning of each packet.
@@ -76,7 +91,9 @@ This will use 8 bytes of the specified payload size.`,
 	hizOpt := flags.Bool("hiz", false, parse.HiZFlagHelp)
 	dxAntOpt := flags.String("dxant", "a", parse.DxAntFlagHelp)
 	rsp2AntOpt := flags.String("rsp2ant", "a", parse.Rsp2AntFlagHelp)
+	testSignalOpt := flags.Bool("testsignal", false, parse.TestSignalFlagHelp)
 	bigOpt := flags.Bool("big", false, "Write samples with big-endian byte order")
+	floatOpt := flags.Bool("float", false, "Write samples in floating-point format")
 
 	// Using ExitOnError
 	_ = flags.Parse(os.Args[1:])
@@ -136,6 +153,10 @@ This will use 8 bytes of the specified payload size.`,
 	if err != nil {
 		return err
 	}
+	duoTuner, err = parse.HiZDuoTuner(*hizOpt, duoTuner)
+	if err != nil {
+		return err
+	}
 
 	dxAnt, err := parse.DxAntFlag(*dxAntOpt)
 	if err != nil {
@@ -168,6 +189,12 @@ This will use 8 bytes of the specified payload size.`,
 		ifModeCfg = session.WithZeroIF(fs, dec)
 	}
 
+	// Effective sample rate, which is only used by the test signal.
+	rate := fs / float64(dec)
+	if *lifOpt {
+		rate = session.LowIFSampleRate / float64(dec)
+	}
+
 	var serialsFilter session.DevFilterFn
 	switch serials {
 	case nil:
@@ -204,18 +231,37 @@ This will use 8 bytes of the specified payload size.`,
 
 	log.Printf("UDP initialized: local=%v remote=%v", conn.LocalAddr(), conn.RemoteAddr())
 
-	if *payOpt%4 != 0 {
+	frameSize := uint(4)
+	if *floatOpt {
+		frameSize = 8
+	}
+	if *payOpt%frameSize != 0 {
 		return fmt.Errorf("payload size must be multiple of frame size: got %d", *payOpt)
 	}
 
 	log.Printf("Payload Size: %d B", *payOpt)
 
 	// Setup callback and control state.
-	write, err := udp.NewPacketWriteFn(*payOpt, 2, *seqOpt, order)
-	if err != nil {
-		return err
+	var (
+		write        udp.PacketWriteFn
+		writeComplex udp.Complex64PacketWriteFn
+		flush        func(out io.Writer, pad bool) (int, error)
+	)
+	if *floatOpt {
+		w, err := udp.NewComplex64PacketWriter(*payOpt, 1, *seqOpt, order)
+		if err != nil {
+			return err
+		}
+		writeComplex, flush = w.Write, w.Flush
+	} else {
+		w, err := udp.NewPacketWriter(*payOpt, 2, *seqOpt, order)
+		if err != nil {
+			return err
+		}
+		write, flush = w.Write, w.Flush
 	}
 	interleave := callback.NewInterleaveFn()
+	convert := callback.NewConvertToComplex64Fn(16)
 	detectDrops := callback.NewDropDetectFn()
 	var isWarm uint32
 	go func() {
@@ -236,8 +282,19 @@ This will use 8 bytes of the specified payload size.`,
 		}
 	}()
 
+	// A nil implementation selects the real API.
+	var impl api.API
+	if *testSignalOpt {
+		log.Println("WARNING: using test signal instead of RSP device")
+		impl = apitest.NewSignal(rate, &api.DeviceT{
+			SerNo: api.ParseSerialNumber("TESTSIGNAL"),
+			HWVer: api.RSP1A_ID,
+		})
+	}
+
 	err = session.Run(
 		ctx,
+		session.WithImplementation(impl),
 		session.WithSelector(
 			serialsFilter,
 			duoTunerFilter,
@@ -272,14 +329,17 @@ This will use 8 bytes of the specified payload size.`,
 					if err != nil {
 						return err
 					}
-					log.Printf("IF Mode: %v", c.TunerParams.IfType)
+					log.Printf("IF Mode: %s", c.TunerParams.IfType.Describe())
 					log.Printf("RF Frequency: %v Hz\n", c.TunerParams.RfFreq.RfHz)
 					log.Printf("ADC Sample Rate: %v Hz\n", p.DevParams.FsFreq.FsHz)
 					log.Printf("Effective Sample Rate: %v Hz\n", rate)
-					log.Printf("IF Filter Bandwidth: %v Hz\n", c.TunerParams.BwType.Hz())
+					log.Printf("IF Filter Bandwidth: %s\n", c.TunerParams.BwType.Describe())
 					log.Printf("AGC Control: %v\n", c.CtrlParams.Agc.Enable)
 					log.Printf("LNA State: %v\n", c.TunerParams.Gain.LNAstate)
 					log.Printf("LNA Percent: %d%%\n", int(pct*100))
+					for _, w := range session.Preflight(d, p, c) {
+						log.Printf("WARNING: %s\n", w)
+					}
 					return nil
 				},
 			),
@@ -300,7 +360,11 @@ This will use 8 bytes of the specified payload size.`,
 				log.Printf("dropped %d samples\n", d)
 			}
 
-			_, err = write(conn, interleave(xi, xq))
+			if *floatOpt {
+				_, err = writeComplex(conn, convert(xi, xq))
+			} else {
+				_, err = write(conn, interleave(xi, xq))
+			}
 			if err != nil {
 				log.Println(err)
 				cancel()
@@ -330,6 +394,10 @@ This will use 8 bytes of the specified payload size.`,
 	)
 	switch err {
 	case nil, context.Canceled:
+		// Send the tail of the stream that did not fill a packet.
+		if _, err := flush(conn, true); err != nil {
+			log.Printf("failed to flush final packet: %v", err)
+		}
 		log.Println("clean exit")
 	default:
 		return fmt.Errorf("error during session run: %v", err)
@@ -0,0 +1,95 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestTestSignal(t *testing.T) {
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"rspudp", "-testsignal", "-warm", "0", "-fs", "2M", "-dec", "32", "-pay", "1024", "-seq", "-remote", conn.LocalAddr().String(), "100M"}
+	done := make(chan error, 1)
+	go func() {
+		done <- rspudp()
+	}()
+
+	// Each packet has a sequence number followed by 254 samples.
+	const numSamples = (1024 - 8) / 4
+	var (
+		buf    = make([]byte, 2048)
+		offset = -1
+		seq    uint64
+	)
+	for p := 0; p < 20; p++ {
+		if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1024 {
+			t.Fatalf("wrong packet size: got %d, want 1024", n)
+		}
+		gotSeq := binary.LittleEndian.Uint64(buf)
+		if p > 0 && gotSeq != seq+1 {
+			t.Fatalf("wrong sequence number: got %d, want %d", gotSeq, seq+1)
+		}
+		seq = gotSeq
+		for k := 0; k < numSamples; k++ {
+			xi := int16(binary.LittleEndian.Uint16(buf[8+4*k:]))
+			xq := int16(binary.LittleEndian.Uint16(buf[10+4*k:]))
+			if offset < 0 {
+				// Samples from callbacks during the warm-up are
+				// discarded, so find the offset into the pattern from
+				// the first sample.
+				for m := 0; m < apitest.PatternPeriod; m++ {
+					if i, q := apitest.Pattern(uint32(m)); i == xi && q == xq {
+						offset = m
+						break
+					}
+				}
+				if offset < 0 {
+					t.Fatalf("first sample not in pattern: got (%d,%d)", xi, xq)
+				}
+			}
+			i, q := apitest.Pattern(uint32(offset + p*numSamples + k))
+			if xi != i || xq != q {
+				t.Fatalf("wrong sample %d of packet %d: got (%d,%d), want (%d,%d)", k, p, xi, xq, i, q)
+			}
+		}
+	}
+
+	if err := self.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot interrupt rspudp: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rspudp did not exit")
+	}
+}
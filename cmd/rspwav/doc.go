@@ -16,6 +16,60 @@ the components of the a single stream of any RSP device.
 	as 16-bit signed integers in little-endian format with the components
 	interleaved (e.g. I1,Q1,I2,Q2,...,In,Qn).
 
+	With -withmag, a third channel is appended to each frame containing the
+	magnitude of the complex sample (e.g. I1,Q1,M1,I2,Q2,M2,...). The
+	magnitude uses the same scale and format as the I and Q components.
+
+	With -stdout or -pipe, rspwav never seeks back to update the WAV header
+	when it exits. This allows the output to be piped directly into another
+	program (e.g. sox) or written to a named pipe (FIFO). Because the final
+	size is not known when the header is written, the WAV size fields are set
+	to the streaming sentinel 0xFFFFFFFF. A named pipe given with -out is
+	detected and handled the same as -pipe. With -raw, no header is written
+	at all and the output contains only the interleaved samples.
+
+	With -rotate, a new WAV file is started each time the wall clock crosses
+	a UTC hour or day boundary. The -out path, without any .wav extension,
+	is used as a prefix and each file is named with the UTC time of its
+	first sample (e.g. rsp_20210304T060000Z.wav). The first and last files
+	cover a partial period. The fileBytes limit applies to the total of all
+	files and each file must remain under 4 GiB. Rotation cannot be combined
+	with -stdout, -pipe, or -raw.
+
+	With -ci8, samples are scaled to signed 8-bit integers with saturation
+	and written as headerless raw interleaved IQ (ci8, also known as cs8).
+	This halves the output size compared to 16-bit samples. Unlike the
+	unsigned 8-bit output of rtl_sdr, each component is a two's complement
+	byte centered on zero. Tools such as inspectrum recognize the format
+	from a .cs8 file extension. Because only the 8 most significant bits are
+	kept, a higher AGC set point (e.g. -agcset -10) makes better use of the
+	reduced range. -ci8 implies -raw and cannot be combined with -float,
+	-big, -withmag, or -rotate.
+
+	With -lat and -lon, and optionally -alt, the receiver location is
+	recorded in the metadata along with the time of the first sample. A WAV
+	file gets a LIST chunk with the ISFT, ICRD (start time), and IGPS (ISO
+	6709 location) INFO fields. With -sigmf, a SigMF .sigmf-meta file is
+	written next to the raw output (e.g. rsp.sigmf-data and rsp.sigmf-meta)
+	with the location in core:geolocation and the start time in the
+	core:datetime of the capture segment. A location cannot be combined
+	with -rotate.
+
+	With -testsignal, rspwav does not open an RSP device or the SDRplay API
+	service. Instead, a test source generates the apitest.Pattern test
+	pattern, a complex tone at 1/64 of the effective sample rate, at the
+	configured rate and feeds it through the normal output path. This is
+	intended only for validating downstream processing without hardware.
+
+	With -npy, the output is a NumPy .npy file that can be loaded directly
+	with numpy.load instead of a WAV file. With -float, and without
+	-withmag, the array is one-dimensional with one complex64 element per
+	sample. Otherwise, it has one row per sample and one column per
+	component (e.g. int16 with shape (N, 2)). Like a WAV header, the shape
+	is updated when rspwav exits. If rspwav is interrupted before then, the
+	shape can be repaired with npy.Finalize. -npy cannot be combined with
+	-stdout, -pipe, -raw, -ci8, -rotate, or -sigmf.
+
 	Arguments:
 	tuneHz
 			Tuner RF frequency in Hz is a mandatory argument. It can
@@ -25,7 +79,8 @@ the components of the a single stream of any RSP device.
 			Maximum output file size in bytes. It can be specified with
 			k, K, m, M, g, or G suffix to indicate the value is in KiB, MiB,
 			or GiB respectively (e.g. 10M)
-			NOTE: WAV files cannot exceed 4 GiB.
+			NOTE: WAV files cannot exceed 4 GiB unless streaming, raw,
+			rotating, or NumPy.
 
 	Flags:
 	-agcctl string
@@ -34,8 +89,14 @@ the components of the a single stream of any RSP device.
 	-agcset int
 			dBFS: AGC Set Point
 			AGC set point in dBFS. (default -30)
+	-alt string
+			meters: Receiver Altitude
+			Altitude of the receiver in meters above the WGS 84 ellipsoid. Requires
+			a latitude and longitude. If not provided, the altitude is recorded as 0.
 	-big
 			Write samples with big-endian byte order
+	-ci8
+			Write only raw samples as signed 8-bit integers (ci8). Implies -raw.
 	-dec uint
 			1|2|4|8|16|32: Decimation factor
 			Sets the decimation factor. This will reduce the effective sample rate.
@@ -45,8 +106,9 @@ the components of the a single stream of any RSP device.
 			a|1|b|2|either: RSPDuo Tuner Selection
 			Select which RSPDuo tuner to use if the selected device is an RSPduo. If
 			"either" is specified, tuner A will be used if available. Otherwise, tuner
-			B will be used if available. If the selected device is not an RSPduo, this
-			option will have no effect. (default "either")
+			B will be used if available. If the High-Z port is enabled, "either"
+			selects only tuner A and "b" is an error. If the selected device is not
+			an RSPduo, this option will have no effect. (default "either")
 	-dxant string
 			a|b|c: RSPdx Antenna
 			Select RSPdx antenna input. (default "a")
@@ -59,7 +121,12 @@ the components of the a single stream of any RSP device.
 			or GHz respectively (e.g. 2.1M is equal to 2100000) (default "6M")
 	-hiz
 			Enable High-Z Port
-			If using an RSP2 or RSPduo, enable the High-Z port.
+			If using an RSP2 or RSPduo, enable the High-Z port. The RSPduo High-Z
+			port is only available on tuner A.
+	-lat string
+			degrees: Receiver Latitude
+			Latitude of the receiver in decimal degrees north. Must be provided
+			together with a longitude to record the location in the metadata.
 	-lif
 			Use low-IF mode. In low-IF mode, the effective sample rate, before decimation
 			is 2 MHz. When -lif is specified, the -fs option cannot be used to configure
@@ -72,8 +139,23 @@ the components of the a single stream of any RSP device.
 			as a percent of the maximum where 0% is the minimum amount of gain and
 			100% is the maximum amount of gain. Specifying as a percent allows
 			automatic determination of LNA state based on the dependent variables. (default "50%")
+	-lon string
+			degrees: Receiver Longitude
+			Longitude of the receiver in decimal degrees east. Must be provided
+			together with a latitude to record the location in the metadata.
+	-npy
+			Write a NumPy .npy file instead of a WAV file.
 	-out string
 			Write WAV file to specified path. (default "rsp.wav")
+	-pipe
+			Write a streaming WAV header and never seek (e.g. for a named pipe).
+	-raw
+			Write only raw samples without a WAV header.
+	-rotate string
+			none|hourly|daily: File Rotation
+			Start a new output file each time the wall clock crosses the top of a
+			UTC hour or UTC midnight. Each file is named with the output path prefix
+			and the UTC time of its first sample. (default "none")
 	-rsp2ant string
 			a|b: RSP2 Antenna
 			Select RSP2 antenna input. (default "a")
@@ -83,6 +165,16 @@ the components of the a single stream of any RSP device.
 			to select from. If a device with one of the provided serial numbers
 			is not found, no device will be selected. The value "any" matches
 			any serial number. (default "any")
+	-sigmf
+			Write a SigMF .sigmf-meta file next to the raw output. Requires -raw or -ci8.
+	-stdout
+			Write to stdout instead of a file. Implies -pipe and ignores -out.
+	-testsignal
+			Use Test Signal (testing only)
+			Do not open an RSP device or the SDRplay API service. Instead, feed the
+			normal processing chain with the apitest.Pattern test pattern, a complex
+			tone at 1/64 of the effective sample rate, generated at the configured
+			rate. This is intended only for validating downstream processing.
 	-usb string
 			isoch|bulk: USB Transfer Mode
 			Select to configure the device in either isochronous or bulk mode. (default "isoch")
@@ -93,5 +185,7 @@ the components of the a single stream of any RSP device.
 			typically encountered when the stream is first starting. During
 			the warmup period, samples are discarded. The maximum value allowed
 			is 60 seconds. (default 2)
+	-withmag
+			Append a magnitude channel to each frame
 */
 package main
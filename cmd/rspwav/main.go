@@ -20,8 +20,11 @@ import (
 	"time"
 
 	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
 	"github.com/msiner/sdrplay-go/helpers/callback"
+	"github.com/msiner/sdrplay-go/helpers/npy"
 	"github.com/msiner/sdrplay-go/helpers/parse"
+	"github.com/msiner/sdrplay-go/helpers/sigmf"
 	"github.com/msiner/sdrplay-go/helpers/wav"
 	"github.com/msiner/sdrplay-go/session"
 )
@@ -40,6 +43,60 @@ which the first (left) channel is the real or I component and the second
 as 16-bit signed integers in little-endian format with the components
 interleaved (e.g. I1,Q1,I2,Q2,...,In,Qn).
 
+With -withmag, a third channel is appended to each frame containing the
+magnitude of the complex sample (e.g. I1,Q1,M1,I2,Q2,M2,...). The
+magnitude uses the same scale and format as the I and Q components.
+
+With -stdout or -pipe, rspwav never seeks back to update the WAV header
+when it exits. This allows the output to be piped directly into another
+program (e.g. sox) or written to a named pipe (FIFO). Because the final
+size is not known when the header is written, the WAV size fields are set
+to the streaming sentinel 0xFFFFFFFF. A named pipe given with -out is
+detected and handled the same as -pipe. With -raw, no header is written
+at all and the output contains only the interleaved samples.
+
+With -rotate, a new WAV file is started each time the wall clock crosses
+a UTC hour or day boundary. The -out path, without any .wav extension,
+is used as a prefix and each file is named with the UTC time of its
+first sample (e.g. rsp_20210304T060000Z.wav). The first and last files
+cover a partial period. The fileBytes limit applies to the total of all
+files and each file must remain under 4 GiB. Rotation cannot be combined
+with -stdout, -pipe, or -raw.
+
+With -ci8, samples are scaled to signed 8-bit integers with saturation
+and written as headerless raw interleaved IQ (ci8, also known as cs8).
+This halves the output size compared to 16-bit samples. Unlike the
+unsigned 8-bit output of rtl_sdr, each component is a two's complement
+byte centered on zero. Tools such as inspectrum recognize the format
+from a .cs8 file extension. Because only the 8 most significant bits are
+kept, a higher AGC set point (e.g. -agcset -10) makes better use of the
+reduced range. -ci8 implies -raw and cannot be combined with -float,
+-big, -withmag, or -rotate.
+
+With -lat and -lon, and optionally -alt, the receiver location is
+recorded in the metadata along with the time of the first sample. A WAV
+file gets a LIST chunk with the ISFT, ICRD (start time), and IGPS (ISO
+6709 location) INFO fields. With -sigmf, a SigMF .sigmf-meta file is
+written next to the raw output (e.g. rsp.sigmf-data and rsp.sigmf-meta)
+with the location in core:geolocation and the start time in the
+core:datetime of the capture segment. A location cannot be combined
+with -rotate.
+
+With -testsignal, rspwav does not open an RSP device or the SDRplay API
+service. Instead, a test source generates the apitest.Pattern test
+pattern, a complex tone at 1/64 of the effective sample rate, at the
+configured rate and feeds it through the normal output path. This is
+intended only for validating downstream processing without hardware.
+
+With -npy, the output is a NumPy .npy file that can be loaded directly
+with numpy.load instead of a WAV file. With -float, and without
+-withmag, the array is one-dimensional with one complex64 element per
+sample. Otherwise, it has one row per sample and one column per
+component (e.g. int16 with shape (N, 2)). Like a WAV header, the shape
+is updated when rspwav exits. If rspwav is interrupted before then, the
+shape can be repaired with npy.Finalize. -npy cannot be combined with
+-stdout, -pipe, -raw, -ci8, -rotate, or -sigmf.
+
 Arguments:
   tuneHz
 	Tuner RF frequency in Hz is a mandatory argument. It can
@@ -49,7 +106,8 @@ Arguments:
 	Maximum output file size in bytes. It can be specified with
 	k, K, m, M, g, or G suffix to indicate the value is in KiB, MiB,
 	or GiB respectively (e.g. 10M)
-	NOTE: WAV files cannot exceed 4 GiB.
+	NOTE: WAV files cannot exceed 4 GiB unless streaming, raw,
+	rotating, or NumPy.
 
 Flags:
 `,
@@ -74,8 +132,20 @@ the sample rate.`,
 	hizOpt := flags.Bool("hiz", false, parse.HiZFlagHelp)
 	dxAntOpt := flags.String("dxant", "a", parse.DxAntFlagHelp)
 	rsp2AntOpt := flags.String("rsp2ant", "a", parse.Rsp2AntFlagHelp)
+	testSignalOpt := flags.Bool("testsignal", false, parse.TestSignalFlagHelp)
 	floatOpt := flags.Bool("float", false, "Write samples in floating-point format")
 	bigOpt := flags.Bool("big", false, "Write samples with big-endian byte order")
+	withMagOpt := flags.Bool("withmag", false, "Append a magnitude channel to each frame")
+	stdoutOpt := flags.Bool("stdout", false, "Write to stdout instead of a file. Implies -pipe and ignores -out.")
+	pipeOpt := flags.Bool("pipe", false, "Write a streaming WAV header and never seek (e.g. for a named pipe).")
+	rawOpt := flags.Bool("raw", false, "Write only raw samples without a WAV header.")
+	rotateOpt := flags.String("rotate", "none", parse.RotateFlagHelp)
+	ci8Opt := flags.Bool("ci8", false, "Write only raw samples as signed 8-bit integers (ci8). Implies -raw.")
+	latOpt := flags.String("lat", "", parse.LatFlagHelp)
+	lonOpt := flags.String("lon", "", parse.LonFlagHelp)
+	altOpt := flags.String("alt", "", parse.AltFlagHelp)
+	sigmfOpt := flags.Bool("sigmf", false, "Write a SigMF .sigmf-meta file next to the raw output. Requires -raw or -ci8.")
+	npyOpt := flags.Bool("npy", false, "Write a NumPy .npy file instead of a WAV file.")
 
 	// Using ExitOnError
 	_ = flags.Parse(os.Args[1:])
@@ -102,11 +172,41 @@ the sample rate.`,
 	if err != nil {
 		return err
 	}
-	// Limitation of standard WAV header format.
-	if numBytes > 4*1024*1024*1024 {
+	rotate, err := parse.RotateFlag(*rotateOpt)
+	if err != nil {
+		return err
+	}
+	// Limitation of standard WAV header format. A streaming header
+	// uses the sentinel size and raw output has no header. Rotated
+	// files are each checked as they are written.
+	stream := *stdoutOpt || *pipeOpt
+	if *ci8Opt {
+		if *floatOpt || *bigOpt || *withMagOpt || rotate != wav.NoBoundary {
+			return errors.New("-ci8 cannot be combined with -float, -big, -withmag, or -rotate")
+		}
+		*rawOpt = true
+	}
+	if rotate != wav.NoBoundary && (stream || *rawOpt) {
+		return errors.New("-rotate cannot be combined with -stdout, -pipe, or -raw")
+	}
+	if *sigmfOpt && (!*rawOpt || *stdoutOpt || *withMagOpt) {
+		return errors.New("-sigmf requires -raw or -ci8 and cannot be combined with -stdout or -withmag")
+	}
+	if *npyOpt && (stream || *rawOpt || rotate != wav.NoBoundary || *sigmfOpt) {
+		return errors.New("-npy cannot be combined with -stdout, -pipe, -raw, -ci8, -rotate, or -sigmf")
+	}
+	if !stream && !*rawOpt && !*npyOpt && rotate == wav.NoBoundary && numBytes > 4*1024*1024*1024 {
 		return fmt.Errorf("invalid file size: got %d bytes, but WAV has a maximum of 4 GiB", numBytes)
 	}
 
+	loc, err := parse.LocationFlag(*latOpt, *lonOpt, *altOpt)
+	if err != nil {
+		return err
+	}
+	if loc != nil && rotate != wav.NoBoundary {
+		return errors.New("-lat, -lon, and -alt cannot be combined with -rotate")
+	}
+
 	fs, err := parse.FsFlag(*fsOpt)
 	if err != nil {
 		return err
@@ -146,6 +246,10 @@ the sample rate.`,
 	if err != nil {
 		return err
 	}
+	duoTuner, err = parse.HiZDuoTuner(*hizOpt, duoTuner)
+	if err != nil {
+		return err
+	}
 
 	dxAnt, err := parse.DxAntFlag(*dxAntOpt)
 	if err != nil {
@@ -201,6 +305,13 @@ the sample rate.`,
 		order = binary.BigEndian
 	}
 
+	// Number of WAV channels, which are the I and Q components and,
+	// optionally, the magnitude.
+	numChannels := uint16(2)
+	if *withMagOpt {
+		numChannels = 3
+	}
+
 	// Size of WAV sample, which is one component of an IQ sample.
 	var bytesPerSample uint8 = uint8(2) // sizeof(int16)
 	sampleFormat := wav.LPCM
@@ -209,54 +320,171 @@ the sample rate.`,
 		sampleFormat = wav.IEEEFloatingPoint
 	}
 
-	// Setup buffered file output.
-	fout, err := os.Create(*outOpt)
-	if err != nil {
-		return err
-	}
-	defer fout.Close()
-	out := bufio.NewWriterSize(fout, 1024*1024)
-
-	// Write the initial WAV header with 0 samples.
-	var totalBytes uint64
 	finalFs := uint32(fs / float64(dec))
 	if *lifOpt {
 		finalFs = uint32(session.LowIFSampleRate / float64(dec))
 	}
 
-	head, err := wav.NewHeader(finalFs, 2, bytesPerSample, sampleFormat, order, 0)
+	head, err := wav.NewHeader(finalFs, numChannels, bytesPerSample, sampleFormat, order, 0)
 	if err != nil {
 		return err
 	}
 
-	if err := binary.Write(out, order, head); err != nil {
+	// The NumPy array has the same layout as the WAV data. Interleaved
+	// float32 I and Q components are exactly complex64 elements.
+	npyHead := &npy.Header{Shape: []uint64{0, uint64(numChannels)}}
+	switch {
+	case *floatOpt && numChannels == 2:
+		npyHead.Descr, err = npy.Descr('c', 2*int(bytesPerSample), order)
+		npyHead.Shape = npyHead.Shape[:1]
+	case *floatOpt:
+		npyHead.Descr, err = npy.Descr('f', int(bytesPerSample), order)
+	default:
+		npyHead.Descr, err = npy.Descr('i', int(bytesPerSample), order)
+	}
+	if err != nil {
 		return err
 	}
-	totalBytes += uint64(binary.Size(head))
-
-	// Before rspwav exits, seek back to the beginning and
-	// update the WAV header with the correct number of samples and
-	// flush the buffered writer.
-	defer func() {
-		dataBytes := totalBytes - uint64(binary.Size(head))
-		numFrames := uint32(dataBytes / uint64(bytesPerSample) / 2)
-		log.Printf("update WAV header: dataBytes=%d dataFrames=%d", dataBytes, numFrames)
-		head.Update(numFrames)
-		out.Flush()
-		_, err = fout.Seek(0, io.SeekStart)
+
+	// The start time is first approximated by the current time and,
+	// once the first sample is written, replaced by the time of that
+	// sample as estimated from the time of its callback.
+	var (
+		out        io.Writer
+		totalBytes uint64
+		start      = time.Now()
+		started    bool
+	)
+	switch rotate {
+	case wav.NoBoundary:
+		// Setup buffered output.
+		fout := os.Stdout
+		if !*stdoutOpt {
+			fout, err = os.Create(*outOpt)
+			if err != nil {
+				return err
+			}
+			// A named pipe cannot seek, so treat it as a stream.
+			info, err := fout.Stat()
+			if err != nil {
+				return err
+			}
+			if info.Mode()&os.ModeNamedPipe != 0 {
+				if *npyOpt {
+					return errors.New("-npy cannot be written to a named pipe")
+				}
+				stream = true
+			}
+		}
+		defer fout.Close()
+
+		// Buffer about one second of output to ride out storage stalls.
+		outBytesPerSample := uint(bytesPerSample)
+		if *ci8Opt {
+			outBytesPerSample = 1
+		}
+		byteRate, err := expectedByteRate(ifModeCfg, outBytesPerSample, uint(numChannels))
 		if err != nil {
-			log.Printf("failed to seek back to header: %v", err)
+			return err
 		}
-		if err := binary.Write(fout, order, head); err != nil {
-			log.Printf("failed to update header: %v", err)
+		bufSize := outputBufferSize(byteRate)
+		log.Printf("Output Buffer: %d B for %d B/s", bufSize, byteRate)
+		bout := bufio.NewWriterSize(fout, bufSize)
+		out = bout
+
+		// Write the initial WAV header with 0 samples or, if streaming,
+		// the sentinel size.
+		var headBytes uint64
+		if stream {
+			head.SetStreaming()
 		}
-	}()
+		switch {
+		case *npyOpt:
+			n, err := npy.WriteHeader(bout, npyHead)
+			if err != nil {
+				return err
+			}
+			headBytes = uint64(n)
+			totalBytes += headBytes
+		case !*rawOpt:
+			n, err := wav.WriteHeader(bout, order, head, captureInfo(start, loc))
+			if err != nil {
+				return err
+			}
+			headBytes = uint64(n)
+			totalBytes += headBytes
+		}
+
+		// Before rspwav exits, seek back to the beginning and
+		// update the WAV header with the correct number of samples and
+		// flush the buffered writer. Streaming and raw output only needs
+		// to be flushed.
+		defer func() {
+			dataBytes := totalBytes - headBytes
+			if stream || *rawOpt {
+				log.Printf("flush output: dataBytes=%d", dataBytes)
+				if err := bout.Flush(); err != nil {
+					log.Printf("failed to flush output: %v", err)
+				}
+				return
+			}
+			if *npyOpt {
+				numRows := dataBytes / uint64(bytesPerSample) / uint64(numChannels)
+				log.Printf("update NumPy header: dataBytes=%d rows=%d", dataBytes, numRows)
+				npyHead.Update(numRows)
+				bout.Flush()
+				if _, err := fout.Seek(0, io.SeekStart); err != nil {
+					log.Printf("failed to seek back to header: %v", err)
+				}
+				if _, err := npy.WriteHeader(fout, npyHead); err != nil {
+					log.Printf("failed to update header: %v", err)
+				}
+				return
+			}
+			numFrames := uint32(dataBytes / uint64(bytesPerSample) / uint64(numChannels))
+			log.Printf("update WAV header: dataBytes=%d dataFrames=%d", dataBytes, numFrames)
+			head.Update(numFrames)
+			bout.Flush()
+			_, err = fout.Seek(0, io.SeekStart)
+			if err != nil {
+				log.Printf("failed to seek back to header: %v", err)
+			}
+			if _, err := wav.WriteHeader(fout, order, head, captureInfo(start, loc)); err != nil {
+				log.Printf("failed to update header: %v", err)
+			}
+		}()
+	default:
+		// Each rotated file gets its own header, which the
+		// RotatingWriter updates as each file is closed.
+		prefix := strings.TrimSuffix(*outOpt, ".wav") + "_"
+		rot, err := wav.NewRotatingWriter(head, order, rotate, wav.TimestampName(prefix))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			log.Printf("close rotated output: dataBytes=%d", totalBytes)
+			if err := rot.Close(); err != nil {
+				log.Printf("failed to close rotated output: %v", err)
+			}
+		}()
+		out = rot
+	}
 
 	// Setup callback and control state.
-	interleave := callback.NewInterleaveFn()
-	toFloats := callback.NewConvertToFloat32Fn(16)
-	writeInts := callback.NewWriteFn(order)
-	writeFloats := callback.NewFloat32WriteFn(order)
+	// Allocate the buffers for the expected callback size now instead
+	// of in the first callbacks.
+	hint, err := expectedCapacity(ifModeCfg)
+	if err != nil {
+		return err
+	}
+	interleave := callback.NewInterleaveFn(hint)
+	interleaveMag := callback.NewMultiInterleaveFn(hint)
+	magnitude := callback.NewMagnitudeFn(hint)
+	toFloats := callback.NewConvertToFloat32Fn(16, hint)
+	writeInts := callback.NewWriteFn(order, hint)
+	writeFloats := callback.NewFloat32WriteFn(order, hint)
+	toInt8s := callback.NewConvertToInt8Fn(16, hint)
+	writeInt8s := callback.NewInt8WriteFn(hint)
 	detectDrops := callback.NewDropDetectFn()
 
 	var isWarm uint32
@@ -278,8 +506,19 @@ the sample rate.`,
 		}
 	}()
 
+	// A nil implementation selects the real API.
+	var impl api.API
+	if *testSignalOpt {
+		log.Println("WARNING: using test signal instead of RSP device")
+		impl = apitest.NewSignal(float64(finalFs), &api.DeviceT{
+			SerNo: api.ParseSerialNumber("TESTSIGNAL"),
+			HWVer: api.RSP1A_ID,
+		})
+	}
+
 	err = session.Run(
 		ctx,
+		session.WithImplementation(impl),
 		session.WithSelector(
 			serialsFilter,
 			duoTunerFilter,
@@ -314,14 +553,17 @@ the sample rate.`,
 					if err != nil {
 						return err
 					}
-					log.Printf("IF Mode: %v", c.TunerParams.IfType)
+					log.Printf("IF Mode: %s", c.TunerParams.IfType.Describe())
 					log.Printf("RF Frequency: %v Hz\n", c.TunerParams.RfFreq.RfHz)
 					log.Printf("ADC Sample Rate: %v Hz\n", p.DevParams.FsFreq.FsHz)
 					log.Printf("Effective Sample Rate: %v Hz\n", rate)
-					log.Printf("IF Filter Bandwidth: %v Hz\n", c.TunerParams.BwType.Hz())
+					log.Printf("IF Filter Bandwidth: %s\n", c.TunerParams.BwType.Describe())
 					log.Printf("AGC Control: %v\n", c.CtrlParams.Agc.Enable)
 					log.Printf("LNA State: %v\n", c.TunerParams.Gain.LNAstate)
 					log.Printf("LNA Percent: %d%%\n", int(pct*100))
+					for _, w := range session.Preflight(d, p, c) {
+						log.Printf("WARNING: %s\n", w)
+					}
 					return nil
 				},
 			),
@@ -337,6 +579,12 @@ the sample rate.`,
 				return
 			}
 
+			if !started {
+				// Estimate the time of the first sample in this callback.
+				started = true
+				start = time.Now().Add(-time.Duration(float64(params.NumSamples) / float64(finalFs) * float64(time.Second)))
+			}
+
 			d := detectDrops(params, reset)
 			if d != 0 {
 				log.Printf("dropped %d samples: %d\n", d, totalBytes)
@@ -345,11 +593,20 @@ the sample rate.`,
 				n   int
 				err error
 			)
-			switch *floatOpt {
+			var x []int16
+			switch *withMagOpt {
 			case true:
-				n, err = writeFloats(out, toFloats(interleave(xi, xq)))
+				x = interleaveMag(xi, xq, magnitude(xi, xq))
+			default:
+				x = interleave(xi, xq)
+			}
+			switch {
+			case *floatOpt:
+				n, err = writeFloats(out, toFloats(x))
+			case *ci8Opt:
+				n, err = writeInt8s(out, toInt8s(x))
 			default:
-				n, err = writeInts(out, interleave(xi, xq))
+				n, err = writeInts(out, x)
 			}
 			totalBytes += uint64(n)
 			switch {
@@ -381,6 +638,12 @@ the sample rate.`,
 			}
 		}),
 	)
+	if *sigmfOpt {
+		if err := writeSigMF(*outOpt, *floatOpt, *ci8Opt, order, finalFs, freq, start, loc); err != nil {
+			log.Printf("failed to write SigMF metadata: %v", err)
+		}
+	}
+
 	switch err {
 	case nil, context.Canceled:
 		log.Println("clean exit")
@@ -391,6 +654,112 @@ the sample rate.`,
 	return nil
 }
 
+// Limits of the output buffer size. See outputBufferSize.
+const (
+	minOutputBuffer = 64 * 1024
+	maxOutputBuffer = 64 * 1024 * 1024
+)
+
+// outputBufferSize returns the size of the output buffer for the
+// provided data rate in bytes per second. It is enough for about one
+// second of data, rounded up to a multiple of 4 KiB, so that writes can
+// continue through a storage stall without blocking the stream callback
+// and dropping samples. It is limited to the range from minOutputBuffer
+// to maxOutputBuffer.
+func outputBufferSize(byteRate uint64) int {
+	size := (byteRate + 4095) / 4096 * 4096
+	switch {
+	case size < minOutputBuffer:
+		return minOutputBuffer
+	case size > maxOutputBuffer:
+		return maxOutputBuffer
+	default:
+		return int(size)
+	}
+}
+
+// scratchParams applies the provided channel configuration to scratch
+// params, so that rates are known before a device is selected.
+func scratchParams(cfg session.ChanConfigFn) (*api.DeviceT, *api.DeviceParamsT, *api.RxChannelParamsT, error) {
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	c := &api.RxChannelParamsT{}
+	p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: c}
+	if err := cfg(d, p, c); err != nil {
+		return nil, nil, nil, err
+	}
+	return d, p, c, nil
+}
+
+// expectedByteRate returns the data rate of the output with the
+// provided channel configuration using session.ExpectedByteRate.
+func expectedByteRate(cfg session.ChanConfigFn, bytesPerSample, numChannels uint) (uint64, error) {
+	d, p, c, err := scratchParams(cfg)
+	if err != nil {
+		return 0, err
+	}
+	return session.ExpectedByteRate(d, p, c, bytesPerSample, numChannels)
+}
+
+// expectedCapacity returns a hint of the largest stream callback with
+// the provided channel configuration.
+func expectedCapacity(cfg session.ChanConfigFn) (callback.Capacity, error) {
+	d, p, c, err := scratchParams(cfg)
+	if err != nil {
+		return 0, err
+	}
+	fs, err := session.GetEffectiveSampleRate(d, p, c)
+	if err != nil {
+		return 0, err
+	}
+	return callback.CapacityForRate(fs), nil
+}
+
+// captureInfo returns the INFO fields of a capture starting at start
+// by a receiver at loc. Without a location, it returns no fields, so the
+// WAV header has no LIST chunk.
+func captureInfo(start time.Time, loc *wav.Location) wav.Info {
+	if loc == nil {
+		return nil
+	}
+	return wav.NewCaptureInfo("rspwav", start, loc)
+}
+
+// writeSigMF writes the .sigmf-meta file that describes the raw output
+// written to path.
+func writeSigMF(path string, isFloat, isInt8 bool, order binary.ByteOrder, fs uint32, freq float64, start time.Time, loc *wav.Location) error {
+	bytesPerScalar := 2
+	switch {
+	case isFloat:
+		bytesPerScalar = 4
+	case isInt8:
+		bytesPerScalar = 1
+	}
+	datatype, err := sigmf.ComplexDatatype(isFloat, bytesPerScalar, order)
+	if err != nil {
+		return err
+	}
+	meta := sigmf.Meta{
+		Global: sigmf.Global{
+			Datatype:   datatype,
+			SampleRate: float64(fs),
+			Version:    sigmf.Version,
+			Recorder:   "rspwav",
+		},
+		Captures: []sigmf.Capture{
+			{
+				Frequency: freq,
+				Datetime:  sigmf.FormatDatetime(start),
+			},
+		},
+	}
+	if loc != nil {
+		meta.Global.Geolocation = sigmf.NewPoint(loc.Latitude, loc.Longitude, loc.Altitude)
+	}
+	metaPath := sigmf.MetaPath(path)
+	log.Printf("write SigMF metadata: %s", metaPath)
+	return meta.WriteFile(metaPath)
+}
+
 func main() {
 	err := rspwav()
 	if err != nil {
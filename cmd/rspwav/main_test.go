@@ -0,0 +1,196 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api/apitest"
+	"github.com/msiner/sdrplay-go/helpers/npy"
+	"github.com/msiner/sdrplay-go/helpers/wav"
+	"github.com/msiner/sdrplay-go/session"
+)
+
+func TestTestSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rspwav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.wav")
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"rspwav", "-testsignal", "-warm", "0", "-fs", "2M", "-dec", "8", "-out", path, "100M", "64k"}
+	if err := rspwav(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(b)
+	head, info, order, err := wav.ReadHeaderInfo(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without a location, the header has no LIST chunk.
+	if len(info) != 0 {
+		t.Errorf("unexpected INFO fields: got %v", info)
+	}
+	if head.Fmt.SampleRate != 250000 {
+		t.Errorf("wrong sample rate: got %d, want 250000", head.Fmt.SampleRate)
+	}
+	x := make([]int16, head.Data.ChunkSize/2)
+	if err := binary.Read(r, order, x); err != nil {
+		t.Fatal(err)
+	}
+	if len(x) < 64*1024/2 {
+		t.Fatalf("wrong number of scalars: got %d, want >= %d", len(x), 64*1024/2)
+	}
+
+	// Samples from callbacks during the warm-up are discarded, so find
+	// the offset into the pattern from the first sample.
+	offset := -1
+	for n := uint32(0); n < apitest.PatternPeriod; n++ {
+		if i, q := apitest.Pattern(n); i == x[0] && q == x[1] {
+			offset = int(n)
+			break
+		}
+	}
+	if offset < 0 {
+		t.Fatalf("first sample not in pattern: got (%d,%d)", x[0], x[1])
+	}
+	for k := 0; k < len(x)/2; k++ {
+		i, q := apitest.Pattern(uint32(offset + k))
+		if x[2*k] != i || x[2*k+1] != q {
+			t.Fatalf("wrong sample %d: got (%d,%d), want (%d,%d)", k, x[2*k], x[2*k+1], i, q)
+		}
+	}
+}
+
+func TestTestSignalNpy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rspwav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	specs := []struct {
+		name  string
+		float bool
+		descr string
+		cols  int
+	}{
+		{"int16", false, "<i2", 2},
+		{"complex64", true, "<c8", 0},
+	}
+	args := os.Args
+	defer func() { os.Args = args }()
+	for _, spec := range specs {
+		path := filepath.Join(dir, spec.name+".npy")
+		os.Args = []string{"rspwav", "-testsignal", "-npy", "-warm", "0", "-fs", "2M", "-dec", "8", "-out", path}
+		if spec.float {
+			os.Args = append(os.Args, "-float")
+		}
+		os.Args = append(os.Args, "100M", "64k")
+		if err := rspwav(); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := bytes.NewReader(b)
+		head, err := npy.ReadHeader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head.Descr != spec.descr || head.FortranOrder {
+			t.Errorf("wrong %s type: got %+v, want Descr=%s", spec.name, head, spec.descr)
+		}
+		wantDims := 1
+		if spec.cols != 0 {
+			wantDims = 2
+		}
+		if len(head.Shape) != wantDims || (spec.cols != 0 && head.Shape[1] != uint64(spec.cols)) {
+			t.Fatalf("wrong %s shape: got %v", spec.name, head.Shape)
+		}
+		// Every sample is 4 bytes as int16 or 8 bytes as complex64.
+		sampleBytes := 4
+		if spec.float {
+			sampleBytes = 8
+		}
+		if got, want := uint64(r.Len()), head.Shape[0]*uint64(sampleBytes); got != want || got == 0 {
+			t.Fatalf("wrong %s data size: got %d, want %d", spec.name, got, want)
+		}
+		if spec.float {
+			continue
+		}
+
+		x := make([]int16, r.Len()/2)
+		if err := binary.Read(r, binary.LittleEndian, x); err != nil {
+			t.Fatal(err)
+		}
+		offset := -1
+		for n := uint32(0); n < apitest.PatternPeriod; n++ {
+			if i, q := apitest.Pattern(n); i == x[0] && q == x[1] {
+				offset = int(n)
+				break
+			}
+		}
+		if offset < 0 {
+			t.Fatalf("first sample not in pattern: got (%d,%d)", x[0], x[1])
+		}
+		for k := 0; k < len(x)/2; k++ {
+			i, q := apitest.Pattern(uint32(offset + k))
+			if x[2*k] != i || x[2*k+1] != q {
+				t.Fatalf("wrong sample %d: got (%d,%d), want (%d,%d)", k, x[2*k], x[2*k+1], i, q)
+			}
+		}
+	}
+}
+
+func TestOutputBufferSize(t *testing.T) {
+	specs := []struct {
+		cfg            session.ChanConfigFn
+		bytesPerSample uint
+		numChannels    uint
+		min, max       int
+	}{
+		// 62.5 kHz of int16 IQ is 250 kB/s.
+		{session.WithZeroIF(2e6, 32), 2, 2, 250000, 250000 + 4096},
+		{session.WithLowIF(session.LowIFMaxBits, 32), 2, 2, 250000, 250000 + 4096},
+		// 2 MHz of int16 IQ and magnitude is 12 MB/s.
+		{session.WithLowIF(session.LowIFMaxBits, 1), 2, 3, 12e6, 12e6 + 4096},
+		// 8 MHz of int16 IQ is 32 MB/s.
+		{session.WithZeroIF(8e6, 1), 2, 2, 32e6, 32e6 + 4096},
+		// 10 MHz of float32 IQ is 80 MB/s, which is capped.
+		{session.WithZeroIF(10e6, 1), 4, 2, maxOutputBuffer, maxOutputBuffer},
+		// 62.5 kHz of ci8 is 125 kB/s.
+		{session.WithZeroIF(2e6, 32), 1, 2, 125000, 125000 + 4096},
+	}
+	for i, spec := range specs {
+		rate, err := expectedByteRate(spec.cfg, spec.bytesPerSample, spec.numChannels)
+		if err != nil {
+			t.Fatalf("unexpected error for spec %d: %v", i, err)
+		}
+		got := outputBufferSize(rate)
+		if got < spec.min || got > spec.max || got%4096 != 0 {
+			t.Errorf("wrong buffer size for spec %d at %d B/s: got %d, want 4 KiB multiple in [%d,%d]", i, rate, got, spec.min, spec.max)
+		}
+	}
+
+	if got := outputBufferSize(1000); got != minOutputBuffer {
+		t.Errorf("wrong buffer size for low rate: got %d, want %d", got, minOutputBuffer)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// AsyncWriter is an io.WriteCloser that moves the blocking Write of an
+// underlying io.Writer to a separate goroutine. Each Write copies the
+// bytes into one of a fixed number of buffers and queues it, so the
+// caller, such as a stream callback using a WriteFn, can convert the
+// next samples while the previous ones are written to a file or socket.
+//
+// Unlike StreamChan, which moves all processing off of the callback
+// thread and drops messages when its queue is full, an AsyncWriter only
+// moves the I/O and never drops data. When all buffers are queued,
+// Write blocks until the oldest is written. The bytes are written to
+// the underlying io.Writer in the order they were passed to Write.
+type AsyncWriter struct {
+	out   io.Writer
+	queue chan []byte
+	free  chan []byte
+	done  chan struct{}
+
+	// wmu serializes Write and Close so that buffers are queued in
+	// order.
+	wmu    sync.Mutex
+	closed bool
+
+	// mu protects err, which is written by the worker.
+	mu  sync.Mutex
+	err error
+}
+
+// NewAsyncWriter creates an AsyncWriter that writes to out from a new
+// goroutine with a queue of depth buffers. A deeper queue absorbs longer
+// stalls of out (e.g. disk flushes), at the cost of one buffer of memory
+// per entry. Each buffer grows to the largest Write and is reused, so
+// there are no allocations in steady state. The optional Capacity hint
+// preallocates each buffer for that many samples as written by a WriteFn
+// (i.e. 4 bytes per sample). It returns an error if depth is less
+// than 1.
+//
+// Close must be called to write the remaining queued buffers and stop
+// the goroutine. The underlying io.Writer is not closed.
+func NewAsyncWriter(out io.Writer, depth int, hint ...Capacity) (*AsyncWriter, error) {
+	if depth < 1 {
+		return nil, fmt.Errorf("invalid depth: got %d, want >= 1", depth)
+	}
+	w := &AsyncWriter{
+		out:   out,
+		queue: make(chan []byte, depth),
+		free:  make(chan []byte, depth),
+		done:  make(chan struct{}),
+	}
+	size := bufferLen(hint, 4, 0)
+	for i := 0; i < depth; i++ {
+		w.free <- make([]byte, 0, size)
+	}
+	go w.run()
+	return w, nil
+}
+
+// run writes queued buffers until the queue is closed. After the first
+// error, buffers are discarded.
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	for buf := range w.queue {
+		if w.Err() == nil {
+			if _, err := w.out.Write(buf); err != nil {
+				w.mu.Lock()
+				w.err = err
+				w.mu.Unlock()
+			}
+		}
+		w.free <- buf
+	}
+}
+
+// Err returns the first error returned by the underlying io.Writer, if
+// any.
+func (w *AsyncWriter) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Write implements io.Writer. It copies p into a free buffer, waiting
+// for one if necessary, and queues it. It always returns len(p) unless
+// an error is returned. Because the bytes are written later, an error
+// from the underlying io.Writer is returned by the next call to Write or
+// Close. The bytes of that Write and all later ones are discarded.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+	if w.closed {
+		return 0, errors.New("write to closed AsyncWriter")
+	}
+	if err := w.Err(); err != nil {
+		return 0, err
+	}
+	buf := <-w.free
+	buf = append(buf[:0], p...)
+	w.queue <- buf
+	return len(p), nil
+}
+
+// Close implements io.Closer. It waits for all queued buffers to be
+// written and returns the first error returned by the underlying
+// io.Writer, if any. It returns an error if already closed.
+func (w *AsyncWriter) Close() error {
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+	if w.closed {
+		return errors.New("already closed")
+	}
+	w.closed = true
+	close(w.queue)
+	<-w.done
+	return w.Err()
+}
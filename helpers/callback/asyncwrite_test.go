@@ -0,0 +1,194 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// gatedWriter is an io.Writer that blocks each Write until it receives
+// from gate.
+type gatedWriter struct {
+	gate chan struct{}
+	buf  bytes.Buffer
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	<-g.gate
+	return g.buf.Write(p)
+}
+
+func TestAsyncWriter(t *testing.T) {
+	t.Parallel()
+
+	const (
+		depth    = 4
+		numCalls = 50
+	)
+	if _, err := NewAsyncWriter(ioutil.Discard, 0); err == nil {
+		t.Error("unexpected success for depth 0")
+	}
+
+	out := &gatedWriter{gate: make(chan struct{})}
+	w, err := NewAsyncWriter(out, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The caller reuses its buffer, like a WriteFn, so each Write must
+	// be copied.
+	var want bytes.Buffer
+	accepted := make(chan int, numCalls)
+	go func() {
+		p := make([]byte, 100)
+		for i := 0; i < numCalls; i++ {
+			for j := range p {
+				p[j] = byte(i + j)
+			}
+			want.Write(p)
+			if _, err := w.Write(p); err != nil {
+				t.Error(err)
+			}
+			accepted <- i
+		}
+		close(accepted)
+	}()
+
+	// With the writer stalled, all buffers are soon in use, one being
+	// written and the rest queued, so the producer is blocked by
+	// back-pressure after depth writes instead of dropping data.
+	time.Sleep(50 * time.Millisecond)
+	if got := len(accepted); got != depth {
+		t.Errorf("wrong number of accepted writes while stalled: got %d, want %d", got, depth)
+	}
+
+	// Release the writer and let everything through.
+	go func() {
+		for i := 0; i < numCalls; i++ {
+			out.gate <- struct{}{}
+		}
+	}()
+	for range accepted {
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.buf.Bytes(), want.Bytes()) {
+		t.Errorf("wrong output: got %d bytes, want %d bytes in order", out.buf.Len(), want.Len())
+	}
+	if err := w.Close(); err == nil {
+		t.Error("unexpected success for second close")
+	}
+	if _, err := w.Write([]byte{1}); err == nil {
+		t.Error("unexpected success for write after close")
+	}
+}
+
+// stopWriter is an io.Writer that fails after n writes.
+type stopWriter struct {
+	n int
+}
+
+func (f *stopWriter) Write(p []byte) (int, error) {
+	if f.n == 0 {
+		return 0, errors.New("disk full")
+	}
+	f.n--
+	return len(p), nil
+}
+
+func TestAsyncWriterError(t *testing.T) {
+	t.Parallel()
+
+	w, err := NewAsyncWriter(&stopWriter{n: 2}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The error is reported by a later Write, once the worker sees it.
+	var werr error
+	for i := 0; i < 100 && werr == nil; i++ {
+		_, werr = w.Write([]byte{1, 2, 3})
+		time.Sleep(time.Millisecond)
+	}
+	if werr == nil || werr.Error() != "disk full" {
+		t.Errorf("wrong write error: got %v, want disk full", werr)
+	}
+	if err := w.Close(); err == nil || err.Error() != "disk full" {
+		t.Errorf("wrong close error: got %v, want disk full", err)
+	}
+}
+
+// latencyWriter is an io.Writer with a fixed latency per Write, like a
+// disk or network.
+type latencyWriter struct {
+	latency time.Duration
+}
+
+func (l latencyWriter) Write(p []byte) (int, error) {
+	time.Sleep(l.latency)
+	return len(p), nil
+}
+
+// benchmarkWritePath measures the processing, conversion, and write of
+// callbacks of 8192 samples to a writer with a latency of 1 ms, either
+// directly or through an AsyncWriter with the provided depth. With the
+// AsyncWriter, the time per callback is the longer of the processing
+// and the write instead of their sum.
+func benchmarkWritePath(b *testing.B, depth int) {
+	const (
+		numSamples = 8192
+		numTaps    = 128
+	)
+	xi := make([]int16, numSamples)
+	xq := make([]int16, numSamples)
+	hint := Capacity(numSamples)
+	interleave := NewInterleaveFn(hint)
+	write := NewWriteFn(binary.LittleEndian, hint)
+	var out io.Writer = latencyWriter{latency: time.Millisecond}
+	var aw *AsyncWriter
+	if depth > 0 {
+		var err error
+		aw, err = NewAsyncWriter(out, depth, hint)
+		if err != nil {
+			b.Fatal(err)
+		}
+		out = aw
+	}
+	b.SetBytes(numSamples * 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Stand-in for filtering in the callback before the write.
+		var acc int32
+		for j := range xi {
+			for k := 0; k < numTaps; k++ {
+				acc += int32(j*k) ^ int32(i)
+			}
+			xi[j] = int16(acc)
+			xq[j] = int16(acc >> 16)
+		}
+		if _, err := write(out, interleave(xi, xq)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if aw != nil {
+		if err := aw.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWritePathSync(b *testing.B) {
+	benchmarkWritePath(b, 0)
+}
+
+func BenchmarkWritePathAsync(b *testing.B) {
+	benchmarkWritePath(b, 8)
+}
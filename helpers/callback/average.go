@@ -0,0 +1,84 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import "fmt"
+
+// CoherentAverageFn is a function type that accumulates the provided
+// complex samples into a coherent time-domain average of a periodic
+// signal. The xi slice contains the real component and the xq slice
+// contains the imaginary component. If the lengths differ, the trailing
+// samples of the longer slice are discarded.
+//
+// It returns nil until a complete average is available. When the last
+// period of an average is completed during a call, the averaged period
+// is returned and accumulation of the next average starts with the
+// following sample. If more than one average completes during a single
+// call, only the last one is returned.
+type CoherentAverageFn func(xi, xq []int16) []complex64
+
+// NewCoherentAverageFn creates a new CoherentAverageFn that averages
+// count consecutive periods of periodSamples samples each. Sample k of
+// the returned period is the mean of sample k of each of the count
+// periods. For a signal that repeats exactly every periodSamples
+// samples, the signal adds coherently while uncorrelated noise does not,
+// so the SNR improves by a factor of sqrt(count) in amplitude.
+//
+// The period position is kept between calls, so periods may span any
+// number of callbacks. The first sample provided after creation is
+// taken as the start of a period. The returned values have the same
+// scale as the input.
+//
+// The function uses internal persistent buffers to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewCoherentAverageFn(periodSamples int, count int) (CoherentAverageFn, error) {
+	if periodSamples < 1 {
+		return nil, fmt.Errorf("invalid period: got %d samples, want > 0", periodSamples)
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("invalid count: got %d, want > 0", count)
+	}
+	// Sums are kept in float64 so that long averages of int16 values
+	// are exact.
+	sumI := make([]float64, periodSamples)
+	sumQ := make([]float64, periodSamples)
+	buf := make([]complex64, periodSamples)
+	var pos, periods int
+	return func(xi, xq []int16) []complex64 {
+		minLen := len(xi)
+		if len(xq) < minLen {
+			minLen = len(xq)
+		}
+		done := false
+		for i := 0; i < minLen; i++ {
+			sumI[pos] += float64(xi[i])
+			sumQ[pos] += float64(xq[i])
+			pos++
+			if pos < periodSamples {
+				continue
+			}
+			pos = 0
+			periods++
+			if periods < count {
+				continue
+			}
+			for k := range buf {
+				buf[k] = complex(
+					float32(sumI[k]/float64(count)),
+					float32(sumQ[k]/float64(count)),
+				)
+				sumI[k] = 0
+				sumQ[k] = 0
+			}
+			periods = 0
+			done = true
+		}
+		if !done {
+			return nil
+		}
+		return buf
+	}, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCoherentAverage(t *testing.T) {
+	t.Parallel()
+
+	const (
+		period = 250
+		sigma  = 1000
+	)
+	// A pulse that repeats every period samples.
+	clean := func(k int) (float64, float64) {
+		k %= period
+		if k < 20 {
+			return 4000, -2000
+		}
+		return 0, 0
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	for _, count := range []int{1, 4, 16, 64} {
+		avg, err := NewCoherentAverageFn(period, count)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Feed the periods in chunks that do not line up with the
+		// period boundaries.
+		total := count * period
+		xi := make([]int16, total)
+		xq := make([]int16, total)
+		for k := range xi {
+			ci, cq := clean(k)
+			xi[k] = int16(ci + rng.NormFloat64()*sigma)
+			xq[k] = int16(cq + rng.NormFloat64()*sigma)
+		}
+		var got []complex64
+		for start := 0; start < total; {
+			end := start + 1 + rng.Intn(3*period)
+			if end > total {
+				end = total
+			}
+			res := avg(xi[start:end], xq[start:end])
+			switch {
+			case end < total && res != nil:
+				t.Fatalf("unexpected average with count=%d after %d samples", count, end)
+			case end == total:
+				got = res
+			}
+			start = end
+		}
+		if len(got) != period {
+			t.Fatalf("wrong average length with count=%d: got %d, want %d", count, len(got), period)
+		}
+
+		// The residual noise falls with the square root of count.
+		var sumSq float64
+		for k, v := range got {
+			ci, cq := clean(k)
+			di := float64(real(v)) - ci
+			dq := float64(imag(v)) - cq
+			sumSq += di*di + dq*dq
+		}
+		rms := math.Sqrt(sumSq / (2 * period))
+		want := sigma / math.Sqrt(float64(count))
+		if rms < 0.85*want || rms > 1.15*want {
+			t.Errorf("wrong residual noise with count=%d: got %.1f, want %.1f", count, rms, want)
+		}
+	}
+}
+
+func TestCoherentAverageRepeat(t *testing.T) {
+	t.Parallel()
+
+	avg, err := NewCoherentAverageFn(3, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Two complete averages in one call only return the last.
+	xi := []int16{1, 2, 3, 3, 4, 5, 10, 20, 30, 30, 40, 50, 7}
+	got := avg(xi, xi)
+	want := []complex64{20 + 20i, 30 + 30i, 40 + 40i}
+	if len(got) != len(want) {
+		t.Fatalf("wrong length: got %d, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("wrong value at %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+	// The trailing sample starts the next average.
+	if got := avg([]int16{9, 9, 9, 9, 9}, []int16{9, 9, 9, 9, 9}); got == nil || got[0] != 8+8i {
+		t.Errorf("wrong average after carry: got %v", got)
+	}
+
+	for _, spec := range [][2]int{{0, 1}, {1, 0}, {-1, 1}} {
+		if _, err := NewCoherentAverageFn(spec[0], spec[1]); err == nil {
+			t.Errorf("unexpected success for period=%d count=%d", spec[0], spec[1])
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"math"
+	"time"
+)
+
+// Capacity is an optional hint, accepted by the constructors of the
+// functions in this package that use internal buffers, of the largest
+// number of samples expected in a single call. A sample is an I/Q pair,
+// so the hint for a stream callback is params.NumSamples (i.e.
+// len(xi)). Functions that accept a single interleaved slice, such as
+// WriteFn, are sized for two scalars per sample.
+//
+// Without a hint, the buffers start small and grow on the first larger
+// call. The resulting allocation happens in the callback path right at
+// the start of streaming, and can cause a drop. With a hint, the buffers
+// are allocated for that size by the constructor, so calls with up to
+// that many samples do not allocate. If more than one hint is provided,
+// the largest is used.
+type Capacity int
+
+// CapacityPeriod is the callback period assumed by CapacityForRate. It
+// is deliberately much longer than the period at which the API delivers
+// stream callbacks, so that the hint covers the largest callback.
+const CapacityPeriod = 10 * time.Millisecond
+
+// CapacityForRate returns a Capacity hint for a stream with an
+// effective sample rate of fs (e.g. from session.GetEffectiveSampleRate).
+// It is the number of samples in CapacityPeriod.
+func CapacityForRate(fs float64) Capacity {
+	if math.IsNaN(fs) || fs <= 0 {
+		return 0
+	}
+	return Capacity(math.Ceil(fs * CapacityPeriod.Seconds()))
+}
+
+// bufferLen returns the initial length of a buffer that holds perSample
+// elements for each sample of the largest hint, or def if it is larger.
+func bufferLen(hints []Capacity, perSample, def int) int {
+	res := def
+	for _, h := range hints {
+		if n := int(h) * perSample; n > res {
+			res = n
+		}
+	}
+	return res
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCapacityForRate(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		fs   float64
+		want Capacity
+	}{
+		{2e6, 20000},
+		{62.5e3, 625},
+		{1234.5, 13},
+		{0, 0},
+		{-1, 0},
+	}
+	for _, spec := range specs {
+		if got := CapacityForRate(spec.fs); got != spec.want {
+			t.Errorf("wrong capacity for %v: got %d, want %d", spec.fs, got, spec.want)
+		}
+	}
+}
+
+// firstCallAllocs returns the average number of allocations of the
+// first call of functions created by newFn.
+func firstCallAllocs(newFn func() func()) float64 {
+	const runs = 10
+	// AllocsPerRun makes one extra warm-up call.
+	fns := make([]func(), runs+1)
+	for i := range fns {
+		fns[i] = newFn()
+	}
+	i := 0
+	return testing.AllocsPerRun(runs, func() {
+		fns[i]()
+		i++
+	})
+}
+
+func TestCapacityAllocs(t *testing.T) {
+	// Allocation counts are affected by parallel tests.
+	const n = 100000
+	xi := make([]int16, n)
+	xq := make([]int16, n)
+	x := make([]int16, 2*n)
+	xf := make([]float32, 2*n)
+	xc := make([]complex64, n)
+	x8 := make([]int8, 2*n)
+	b := make([]byte, 8*n)
+
+	specs := []struct {
+		name  string
+		newFn func(hint ...Capacity) func()
+	}{
+		{"Write", func(hint ...Capacity) func() {
+			fn := NewWriteFn(binary.LittleEndian, hint...)
+			return func() { _, _ = fn(ioutil.Discard, x) }
+		}},
+		{"Float32Write", func(hint ...Capacity) func() {
+			fn := NewFloat32WriteFn(binary.LittleEndian, hint...)
+			return func() { _, _ = fn(ioutil.Discard, xf) }
+		}},
+		{"Complex64Write", func(hint ...Capacity) func() {
+			fn := NewComplex64WriteFn(binary.LittleEndian, hint...)
+			return func() { _, _ = fn(ioutil.Discard, xc) }
+		}},
+		{"Int8Write", func(hint ...Capacity) func() {
+			fn := NewInt8WriteFn(hint...)
+			return func() { _, _ = fn(ioutil.Discard, x8) }
+		}},
+		{"ConvertToFloat32", func(hint ...Capacity) func() {
+			fn := NewConvertToFloat32Fn(16, hint...)
+			return func() { fn(x) }
+		}},
+		{"ConvertToFloat32Table", func(hint ...Capacity) func() {
+			fn := NewConvertToFloat32TableFn(16, hint...)
+			return func() { fn(x) }
+		}},
+		{"ConvertToComplex64", func(hint ...Capacity) func() {
+			fn := NewConvertToComplex64Fn(16, hint...)
+			return func() { fn(xi, xq) }
+		}},
+		{"ConvertFromFloat32", func(hint ...Capacity) func() {
+			fn := NewConvertFromFloat32Fn(16, true, hint...)
+			return func() { fn(xf) }
+		}},
+		{"Shift", func(hint ...Capacity) func() {
+			fn := NewShiftFn(2, true, hint...)
+			return func() { fn(x) }
+		}},
+		{"ConvertToInt8", func(hint ...Capacity) func() {
+			fn := NewConvertToInt8Fn(16, hint...)
+			return func() { fn(x) }
+		}},
+		{"Interleave", func(hint ...Capacity) func() {
+			fn := NewInterleaveFn(hint...)
+			return func() { fn(xi, xq) }
+		}},
+		{"MultiInterleave", func(hint ...Capacity) func() {
+			fn := NewMultiInterleaveFn(hint...)
+			return func() { fn(xi, xq, xi, xq, xi, xq) }
+		}},
+		{"Conjugate", func(hint ...Capacity) func() {
+			fn := NewConjugateFn(hint...)
+			return func() { fn(xi, xq) }
+		}},
+		{"Magnitude", func(hint ...Capacity) func() {
+			fn := NewMagnitudeFn(hint...)
+			return func() { fn(xi, xq) }
+		}},
+		{"Pack12", func(hint ...Capacity) func() {
+			fn := NewPack12Fn(hint...)
+			return func() { fn(x) }
+		}},
+		{"Unpack12", func(hint ...Capacity) func() {
+			fn := NewUnpack12Fn(hint...)
+			return func() { fn(b[:Packed12Len(2*n)]) }
+		}},
+		{"Read", func(hint ...Capacity) func() {
+			fn := NewReadFn(binary.LittleEndian, hint...)
+			return func() { fn(b[:2*2*n]) }
+		}},
+		{"Float32Read", func(hint ...Capacity) func() {
+			fn := NewFloat32ReadFn(binary.LittleEndian, hint...)
+			return func() { fn(b[:4*2*n]) }
+		}},
+		{"Int8Read", func(hint ...Capacity) func() {
+			fn := NewInt8ReadFn(hint...)
+			return func() { fn(b[:2*n]) }
+		}},
+		{"Decimate", func(hint ...Capacity) func() {
+			fn, _ := NewCascadeDecimateFn([]int{2, 4}, hint...)
+			return func() { fn(xi, xq) }
+		}},
+		{"Resample", func(hint ...Capacity) func() {
+			fn, _ := NewResampleFn(3, 2, hint...)
+			return func() { fn(xi, xq) }
+		}},
+	}
+	for _, spec := range specs {
+		newFn := spec.newFn
+		if got := firstCallAllocs(func() func() { return newFn() }); got == 0 {
+			t.Errorf("missing allocation without hint for %s", spec.name)
+		}
+		if got := firstCallAllocs(func() func() { return newFn(n) }); got != 0 {
+			t.Errorf("wrong allocations with hint for %s: got %v, want 0", spec.name, got)
+		}
+		// The largest of several hints is used.
+		if got := firstCallAllocs(func() func() { return newFn(n, 1) }); got != 0 {
+			t.Errorf("wrong allocations with hints for %s: got %v, want 0", spec.name, got)
+		}
+	}
+}
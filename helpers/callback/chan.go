@@ -6,6 +6,7 @@ package callback
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/msiner/sdrplay-go/api"
 )
@@ -33,7 +34,8 @@ type StreamMsg struct {
 type StreamChan struct {
 	C      <-chan StreamMsg
 	c      chan<- StreamMsg
-	done   chan struct{}
+	mu     sync.Mutex
+	closed bool
 	msgNum uint32
 }
 
@@ -47,37 +49,40 @@ type StreamChan struct {
 func NewStreamChan(depth uint) *StreamChan {
 	cbChan := make(chan StreamMsg, depth)
 	return &StreamChan{
-		C:    cbChan,
-		c:    cbChan,
-		done: make(chan struct{}, 1),
+		C: cbChan,
+		c: cbChan,
 	}
 }
 
-// Close implements io.Closer. It will stop any more messages from being sent
-// on the C chan. However, the C chan will not be closed until the next
-// call to Callback.
+// Close implements io.Closer. It stops the production of messages and
+// closes the C chan. Any messages already buffered in the C chan are
+// not discarded. The receiver can continue to receive them and will
+// only see the C chan as closed after it has received all of them.
+// That is, Close means "stop producing" and the receiver decides when
+// to stop consuming. A receiver that ranges over the C chan will drain
+// all remaining messages and then exit its loop.
+//
+// Close waits for any concurrent call to Callback to return, so it is
+// safe to call from any goroutine.
 func (s *StreamChan) Close() error {
-	select {
-	case <-s.done:
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
 		return errors.New("already closed")
-	default:
-		close(s.done)
-		return nil
 	}
+	s.closed = true
+	close(s.c)
+	return nil
 }
 
 // Callback is a bound implementation of api.StreamCallbackT. It can be
 // passed to the API as the stream callback or used directly. Valid calls
 // to call back will generate a message on the C chan.
 func (s *StreamChan) Callback(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
-	select {
-	case <-s.done:
-		if s.c != nil {
-			close(s.c)
-			s.c = nil
-		}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
 		return
-	default:
 	}
 
 	// Create one big buffer for a single allocation and
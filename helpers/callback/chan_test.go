@@ -112,3 +112,31 @@ func BenchmarkStreamChan(b *testing.B) {
 		sc.Callback(xi, xq, &params, false)
 	}
 }
+
+func TestStreamChanDrain(t *testing.T) {
+	t.Parallel()
+
+	const numMsgs = 10
+	xi := make([]int16, 100)
+	xq := make([]int16, 100)
+
+	sc := NewStreamChan(numMsgs)
+	for i := 0; i < numMsgs; i++ {
+		sc.Callback(xi, xq, nil, false)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatalf("unexpected Close failure: %v", err)
+	}
+	sc.Callback(xi, xq, nil, false)
+
+	var got int
+	for msg := range sc.C {
+		if msg.MsgNum != uint32(got) {
+			t.Errorf("wrong MsgNum: got %d, want %d", msg.MsgNum, got)
+		}
+		got++
+	}
+	if got != numMsgs {
+		t.Errorf("wrong number of drained messages: got %d, want %d", got, numMsgs)
+	}
+}
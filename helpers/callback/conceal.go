@@ -0,0 +1,153 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"fmt"
+	"math"
+)
+
+// ConcealMode selects how a ConcealFn fills a gap left by dropped
+// samples.
+type ConcealMode int
+
+const (
+	// ConcealRepeat fills the gap by repeating the last good callback.
+	ConcealRepeat ConcealMode = iota
+	// ConcealFade fills the gap by fading the last good sample to
+	// silence.
+	ConcealFade
+)
+
+// String implements fmt.Stringer.
+func (m ConcealMode) String() string {
+	switch m {
+	case ConcealRepeat:
+		return "repeat"
+	case ConcealFade:
+		return "fade"
+	default:
+		return fmt.Sprintf("ConcealMode(%d)", int(m))
+	}
+}
+
+const (
+	// ConcealFadeLen is the number of samples over which a ConcealFn
+	// crossfades at each splice.
+	ConcealFadeLen = 64
+	// MaxConcealGap is the largest number of samples a ConcealFn inserts
+	// for a single drop. A larger reported drop is only partially
+	// filled, because it is more likely a reset of the sample counter
+	// than a real gap. See NewDropDetectFnWithThreshold.
+	MaxConcealGap = 1 << 20
+)
+
+// ConcealFn is a function type that conceals dropped samples in a
+// stream. The dropped argument is the number of samples missing before
+// xi and xq, as reported by a DropDetectFn. If it is zero, the samples
+// are returned unchanged. Otherwise, the gap is filled with generated
+// samples followed by the samples of xi and xq, so the timing of the
+// stream is kept. The length of the resulting slices is the shortest of
+// the lengths of xi and xq plus the length of the filled gap.
+type ConcealFn func(xi, xq []int16, dropped uint32) (yi, yq []int16)
+
+// NewConcealFn creates a new ConcealFn that fills gaps as selected by
+// mode. A drop is normally a hard discontinuity that is heard as a click
+// when the stream is demodulated to audio. Instead, each splice between
+// real and generated samples is crossfaded over ConcealFadeLen samples,
+// starting from the last output sample, so the output has no step.
+//
+// With ConcealRepeat, the gap is filled by repeating the last good
+// callback as many times as necessary. With ConcealFade, the last good
+// sample fades to silence and the samples after the gap fade in.
+// Nothing is concealed until the first callback with samples, so the
+// dropped argument of a DropDetectFn can be passed on directly. It
+// returns an error if mode is not valid.
+//
+// The function uses internal persistent buffers to minimize allocations.
+// The returned slices are either xi and xq or slices of those internal
+// buffers and should not be modified or stored.
+func NewConcealFn(mode ConcealMode, hint ...Capacity) (ConcealFn, error) {
+	switch mode {
+	case ConcealRepeat, ConcealFade:
+		// good
+	default:
+		return nil, fmt.Errorf("invalid conceal mode: got %v, want repeat|fade", mode)
+	}
+
+	var (
+		histI = make([]int16, 0, bufferLen(hint, 1, 4096))
+		histQ = make([]int16, 0, cap(histI))
+		bufI  []int16
+		bufQ  []int16
+	)
+	return func(xi, xq []int16, dropped uint32) ([]int16, []int16) {
+		minLen := len(xi)
+		if len(xq) < minLen {
+			minLen = len(xq)
+		}
+		xi, xq = xi[:minLen], xq[:minLen]
+
+		yi, yq := xi, xq
+		if dropped != 0 && len(histI) != 0 {
+			gap := int(dropped)
+			if gap > MaxConcealGap {
+				gap = MaxConcealGap
+			}
+			size := gap + minLen
+			if len(bufI) < size {
+				next := len(bufI) * 2
+				if next < size {
+					next = size
+				}
+				bufI = make([]int16, next)
+				bufQ = make([]int16, next)
+			}
+			yi, yq = bufI[:size], bufQ[:size]
+			last := len(histI) - 1
+			heldI, heldQ := histI[last], histQ[last]
+			switch mode {
+			case ConcealRepeat:
+				for start := 0; start < gap; start += len(histI) {
+					ni := copy(yi[start:gap], histI)
+					copy(yq[start:gap], histQ)
+					crossfade(yi[start:start+ni], yq[start:start+ni], heldI, heldQ)
+					heldI, heldQ = yi[start+ni-1], yq[start+ni-1]
+				}
+			case ConcealFade:
+				for j := 0; j < gap; j++ {
+					yi[j], yq[j] = 0, 0
+				}
+				crossfade(yi[:gap], yq[:gap], heldI, heldQ)
+				if gap != 0 {
+					heldI, heldQ = yi[gap-1], yq[gap-1]
+				}
+			}
+			copy(yi[gap:], xi)
+			copy(yq[gap:], xq)
+			crossfade(yi[gap:], yq[gap:], heldI, heldQ)
+		}
+
+		if minLen != 0 {
+			histI = append(histI[:0], xi...)
+			histQ = append(histQ[:0], xq...)
+		}
+		return yi, yq
+	}, nil
+}
+
+// crossfade blends the first ConcealFadeLen samples of yi and yq from
+// the held sample (heldI, heldQ) to their own values in place.
+func crossfade(yi, yq []int16, heldI, heldQ int16) {
+	n := len(yi)
+	if n > ConcealFadeLen {
+		n = ConcealFadeLen
+	}
+	for j := 0; j < n; j++ {
+		w := float64(j+1) / (ConcealFadeLen + 1)
+		yi[j] = int16(math.Round(w*float64(yi[j]) + (1-w)*float64(heldI)))
+		yq[j] = int16(math.Round(w*float64(yq[j]) + (1-w)*float64(heldQ)))
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"math"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// maxStep returns the largest absolute difference between consecutive
+// values of x.
+func maxStep(x []int16) int32 {
+	var res int32
+	for n := 1; n < len(x); n++ {
+		d := int32(x[n]) - int32(x[n-1])
+		if d < 0 {
+			d = -d
+		}
+		if d > res {
+			res = d
+		}
+	}
+	return res
+}
+
+func TestConcealFn(t *testing.T) {
+	t.Parallel()
+
+	const (
+		amp     = 10000
+		period  = 100
+		cbLen   = 500
+		numCb   = 6
+		dropCb  = 3
+		dropLen = 325
+	)
+	// A tone that is continuous across callbacks, with dropLen samples
+	// missing before callback dropCb. The drop is not a whole number of
+	// periods, so splicing the callbacks directly would make a step.
+	tone := func(n int) (int16, int16) {
+		p := 2 * math.Pi * float64(n) / period
+		return int16(math.Round(amp * math.Cos(p))), int16(math.Round(amp * math.Sin(p)))
+	}
+	// The largest step of the tone itself, plus the crossfade ramp.
+	limit := int32(math.Ceil(2*math.Pi*amp/period)) + 2*amp/ConcealFadeLen
+
+	for _, mode := range []ConcealMode{ConcealRepeat, ConcealFade} {
+		fn, err := NewConcealFn(mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		detect := NewDropDetectFn()
+		var (
+			outI, outQ, hardI []int16
+			first             uint32
+		)
+		for k := 0; k < numCb; k++ {
+			if k == dropCb {
+				first += dropLen
+			}
+			xi := make([]int16, cbLen)
+			xq := make([]int16, cbLen)
+			for n := range xi {
+				xi[n], xq[n] = tone(int(first) + n)
+			}
+			dropped := detect(&api.StreamCbParamsT{FirstSampleNum: first, NumSamples: cbLen}, false)
+			first += cbLen
+			hardI = append(hardI, xi...)
+			yi, yq := fn(xi, xq, dropped)
+			outI = append(outI, yi...)
+			outQ = append(outQ, yq...)
+		}
+
+		if got := maxStep(hardI); got <= limit {
+			t.Fatalf("%v: step without concealment too small for test: got %d, want > %d", mode, got, limit)
+		}
+		if got, want := len(outI), numCb*cbLen+dropLen; got != want {
+			t.Errorf("%v: wrong output length: got %d, want %d", mode, got, want)
+		}
+		if got := maxStep(outI); got > limit {
+			t.Errorf("%v: wrong max in-phase step: got %d, want <= %d", mode, got, limit)
+		}
+		if got := maxStep(outQ); got > limit {
+			t.Errorf("%v: wrong max quadrature step: got %d, want <= %d", mode, got, limit)
+		}
+
+		// Outside of the crossfades, the samples before and after the
+		// gap are unchanged and the gap has the selected content.
+		gapStart := dropCb * cbLen
+		gapEnd := gapStart + dropLen
+		for n := 0; n < len(outI); n++ {
+			var wantI, wantQ int16
+			switch {
+			case n < gapStart:
+				wantI, wantQ = tone(n)
+			case n < gapStart+ConcealFadeLen, n >= gapEnd && n < gapEnd+ConcealFadeLen:
+				continue
+			case n >= gapEnd:
+				wantI, wantQ = tone(n)
+			case mode == ConcealRepeat && (n-gapStart)%cbLen < ConcealFadeLen:
+				continue
+			case mode == ConcealRepeat:
+				// The last good callback starts at (dropCb-1)*cbLen.
+				wantI, wantQ = tone((dropCb-1)*cbLen + (n-gapStart)%cbLen)
+			}
+			if outI[n] != wantI || outQ[n] != wantQ {
+				t.Fatalf("%v: wrong sample %d: got (%d,%d), want (%d,%d)", mode, n, outI[n], outQ[n], wantI, wantQ)
+			}
+		}
+	}
+}
+
+func TestConcealFnPassThrough(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewConcealFn(ConcealMode(5)); err == nil {
+		t.Error("unexpected success for invalid mode")
+	}
+
+	fn, err := NewConcealFn(ConcealFade)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A drop before the first samples cannot be concealed.
+	xi := []int16{1, 2, 3, 4}
+	xq := []int16{5, 6, 7}
+	yi, yq := fn(xi, xq, 10)
+	if len(yi) != 3 || len(yq) != 3 || &yi[0] != &xi[0] || &yq[0] != &xq[0] {
+		t.Errorf("wrong output without history: got %v %v, want %v %v", yi, yq, xi[:3], xq)
+	}
+	yi, _ = fn(xi, xq, 0)
+	if len(yi) != 3 || &yi[0] != &xi[0] {
+		t.Errorf("wrong output without drop: got %v, want %v", yi, xi[:3])
+	}
+	// A gap shorter than the fade is still filled.
+	yi, yq = fn([]int16{100, 100}, []int16{100, 100}, 2)
+	if len(yi) != 4 || len(yq) != 4 {
+		t.Errorf("wrong output length for short gap: got %d, want 4", len(yi))
+	}
+}
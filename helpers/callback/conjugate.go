@@ -0,0 +1,79 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// ConjugateFn is a function type that returns the complex conjugate of
+// the complex samples formed by the provided component sample scalars.
+// The xi slice contains the real component and the xq slice contains the
+// imaginary component. Conjugation mirrors the spectrum around 0 Hz, so
+// it corrects a spectrally inverted stream (e.g. one with I and Q
+// swapped, up to a constant phase rotation). The length of the resulting
+// slices is the shortest of the lengths of xi and xq.
+type ConjugateFn func(xi, xq []int16) (yi, yq []int16)
+
+// NewConjugateFn creates a new ConjugateFn. The real component is
+// returned unchanged as a slice of xi. The imaginary component is
+// negated and saturated with SaturateInt16, so math.MinInt16 becomes
+// MaxSaturatedInt16. Because of that one value, conjugating twice is only
+// an identity for input within the saturated range.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned yq slice is a slice of that internal buffer and should not
+// be modified or stored.
+func NewConjugateFn(hint ...Capacity) ConjugateFn {
+	buf := make([]int16, bufferLen(hint, 1, 4096))
+	return func(xi, xq []int16) ([]int16, []int16) {
+		minLen := len(xi)
+		if len(xq) < minLen {
+			minLen = len(xq)
+		}
+		if len(buf) < minLen {
+			next := len(buf) * 2
+			if next < minLen {
+				next = minLen
+			}
+			buf = make([]int16, next)
+		}
+		for i := 0; i < minLen; i++ {
+			buf[i] = SaturateInt16(-int32(xq[i]))
+		}
+		return xi[:minLen], buf[:minLen]
+	}
+}
+
+// DetectInversion checks whether a stream is spectrally inverted using a
+// pilot tone known to be at the positive frequency pilot, in cycles per
+// sample (i.e. the pilot offset in Hz divided by the sample rate). It
+// measures the power of the provided samples at +pilot and -pilot and
+// reports the stream as inverted if the power at -pilot is greater. The
+// ratio is the power at +pilot relative to -pilot in dB, so a large
+// positive value is a confident normal result and a large negative value
+// is a confident inverted result. A ratio near 0 dB means the pilot was
+// not found and the result should not be trusted. Use a pilot frequency
+// that is well away from 0 and the Nyquist frequency.
+func DetectInversion(xi, xq []int16, pilot float64) (inverted bool, ratio float64) {
+	minLen := len(xi)
+	if len(xq) < minLen {
+		minLen = len(xq)
+	}
+	var pos, neg complex128
+	for n := 0; n < minLen; n++ {
+		x := complex(float64(xi[n]), float64(xq[n]))
+		rot := cmplx.Exp(complex(0, -2*math.Pi*pilot*float64(n)))
+		pos += x * rot
+		neg += x * cmplx.Conj(rot)
+	}
+	pp := real(pos)*real(pos) + imag(pos)*imag(pos)
+	pn := real(neg)*real(neg) + imag(neg)*imag(neg)
+	// Avoid infinities when either bin is exactly empty.
+	const floor = 1e-12
+	ratio = 10 * math.Log10((pp+floor)/(pn+floor))
+	return pn > pp, ratio
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestConjugate(t *testing.T) {
+	t.Parallel()
+
+	const num = 10000
+	xi := make([]int16, num)
+	xq := make([]int16, num+3)
+	for i := range xi {
+		xi[i] = int16(rand.Intn(2*MaxSaturatedInt16+1) + MinSaturatedInt16)
+		xq[i] = int16(rand.Intn(2*MaxSaturatedInt16+1) + MinSaturatedInt16)
+	}
+
+	conj := NewConjugateFn()
+	yi, yq := conj(xi, xq)
+	if len(yi) != num || len(yq) != num {
+		t.Fatalf("wrong lengths: got %d,%d, want %d", len(yi), len(yq), num)
+	}
+	for i := range yi {
+		if yi[i] != xi[i] || yq[i] != -xq[i] {
+			t.Fatalf("wrong conjugate at %d: got (%d,%d), want (%d,%d)", i, yi[i], yq[i], xi[i], -xq[i])
+		}
+	}
+
+	// Conjugating twice is the identity.
+	yq = append([]int16(nil), yq...)
+	zi, zq := NewConjugateFn()(yi, yq)
+	for i := range zi {
+		if zi[i] != xi[i] || zq[i] != xq[i] {
+			t.Fatalf("wrong double conjugate at %d: got (%d,%d), want (%d,%d)", i, zi[i], zq[i], xi[i], xq[i])
+		}
+	}
+
+	if _, yq := conj([]int16{0}, []int16{math.MinInt16}); yq[0] != MaxSaturatedInt16 {
+		t.Errorf("wrong saturation: got %d, want %d", yq[0], MaxSaturatedInt16)
+	}
+}
+
+func TestDetectInversion(t *testing.T) {
+	t.Parallel()
+
+	const (
+		num   = 4096
+		pilot = 0.05
+		amp   = 1000
+	)
+	xi := make([]int16, num)
+	xq := make([]int16, num)
+	for n := range xi {
+		p := 2 * math.Pi * pilot * float64(n)
+		// Add noise so neither bin is empty.
+		xi[n] = int16(math.Round(amp*math.Cos(p))) + int16(rand.Intn(21)-10)
+		xq[n] = int16(math.Round(amp*math.Sin(p))) + int16(rand.Intn(21)-10)
+	}
+
+	inverted, ratio := DetectInversion(xi, xq, pilot)
+	if inverted || ratio < 30 {
+		t.Errorf("wrong result for positive tone: got inverted=%v ratio=%.1f dB", inverted, ratio)
+	}
+
+	// After conjugation, the positive-frequency tone is at the
+	// negative frequency.
+	yi, yq := NewConjugateFn()(xi, xq)
+	if got := toneAmplitude(yi, yq, -pilot); math.Abs(got-amp) > amp*0.01 {
+		t.Errorf("wrong negative tone amplitude: got %.1f, want %v", got, amp)
+	}
+	if got := toneAmplitude(yi, yq, pilot); got > amp*0.01 {
+		t.Errorf("wrong positive tone amplitude: got %.1f, want ~0", got)
+	}
+	inverted, ratio = DetectInversion(yi, yq, pilot)
+	if !inverted || ratio > -30 {
+		t.Errorf("wrong result for conjugated tone: got inverted=%v ratio=%.1f dB", inverted, ratio)
+	}
+
+	// I/Q swap is also detected as inversion.
+	inverted, _ = DetectInversion(xq, xi, pilot)
+	if !inverted {
+		t.Error("swapped I/Q not detected as inverted")
+	}
+}
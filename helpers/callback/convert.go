@@ -22,12 +22,12 @@ type ConvertToFloat32Fn func(x []int16) []float32
 // The function uses an internal persistent buffer to minimize allocations.
 // The returned slice is a slice of that internal buffer and should not be
 // modified or stored.
-func NewConvertToFloat32Fn(numBits uint) ConvertToFloat32Fn {
+func NewConvertToFloat32Fn(numBits uint, hint ...Capacity) ConvertToFloat32Fn {
 	if numBits > 16 {
 		numBits = 16
 	}
 	maxMag := float32(math.Pow(2, float64(numBits-1)))
-	buf := make([]float32, 4096)
+	buf := make([]float32, bufferLen(hint, 2, 4096))
 	return func(x []int16) []float32 {
 		if len(buf) < len(x) {
 			next := len(buf) * 2
@@ -43,6 +43,41 @@ func NewConvertToFloat32Fn(numBits uint) ConvertToFloat32Fn {
 	}
 }
 
+// NewConvertToFloat32TableFn creates a new ConvertToFloat32Fn that
+// produces exactly the same output as NewConvertToFloat32Fn with the same
+// numBits argument. Instead of dividing each sample, it indexes a lookup
+// table with an entry for each of the 65536 possible int16 values. The
+// table is 256 KiB and is built once when the function is created.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewConvertToFloat32TableFn(numBits uint, hint ...Capacity) ConvertToFloat32Fn {
+	if numBits > 16 {
+		numBits = 16
+	}
+	maxMag := float32(math.Pow(2, float64(numBits-1)))
+	table := make([]float32, 1<<16)
+	for i := range table {
+		table[i] = float32(int16(uint16(i))) / maxMag
+	}
+	buf := make([]float32, bufferLen(hint, 2, 4096))
+	return func(x []int16) []float32 {
+		if len(buf) < len(x) {
+			next := len(buf) * 2
+			if next < len(x) {
+				next = len(x)
+			}
+			buf = make([]float32, next)
+		}
+		out := buf[:len(x)]
+		for i, v := range x {
+			out[i] = table[uint16(v)]
+		}
+		return out
+	}
+}
+
 // ConvertToComplex64Fn is a function type that returns a slice with the
 // provided indepedent signal component sample scalars converted to complex64.
 // The xi slice contains the real component and the xq slice contains the
@@ -63,12 +98,12 @@ type ConvertToComplex64Fn func(xi, xq []int16) []complex64
 // The function uses an internal persistent buffer to minimize allocations.
 // The returned slice is a slice of that internal buffer and should not be
 // modified or stored.
-func NewConvertToComplex64Fn(numBits uint) ConvertToComplex64Fn {
+func NewConvertToComplex64Fn(numBits uint, hint ...Capacity) ConvertToComplex64Fn {
 	if numBits > 16 {
 		numBits = 16
 	}
 	maxMag := float32(math.Pow(2, float64(numBits-1)))
-	buf := make([]complex64, 2048)
+	buf := make([]complex64, bufferLen(hint, 1, 2048))
 	return func(xi, xq []int16) []complex64 {
 		minLen := len(xi)
 		if len(xq) < minLen {
@@ -100,3 +135,147 @@ func NewConvertToComplex64Fn(numBits uint) ConvertToComplex64Fn {
 		return buf[:minLen]
 	}
 }
+
+// ConvertFromFloat32Fn is a function type that returns a slice with the
+// provided floating-point sample scalars converted to int16.
+type ConvertFromFloat32Fn func(x []float32) []int16
+
+// NewConvertFromFloat32Fn creates a new ConvertFromFloat32Fn. It is the
+// inverse of NewConvertToFloat32Fn.
+//
+// The numBits argument determines the scaling factor. When numBits is 16 or
+// greater, a value in the [-1,1] range is scaled to the full int16 domain.
+// Any smaller number scales the same range to a smaller domain.
+//
+// The saturate argument determines how values outside of the [-1,1] range
+// are handled. When saturate is true, the scaled values are clamped with
+// SaturateInt16. When saturate is false, the scaled values wrap around
+// to the opposite end of the int16 domain. Saturation is the safe choice
+// for most consumers because wrapping turns a small overshoot into a
+// full-scale discontinuity.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewConvertFromFloat32Fn(numBits uint, saturate bool, hint ...Capacity) ConvertFromFloat32Fn {
+	if numBits > 16 {
+		numBits = 16
+	}
+	if numBits < 1 {
+		numBits = 1
+	}
+	maxMag := float32(math.Pow(2, float64(numBits-1)))
+	buf := make([]int16, bufferLen(hint, 2, 4096))
+	return func(x []float32) []int16 {
+		if len(buf) < len(x) {
+			next := len(buf) * 2
+			if next < len(x) {
+				next = len(x)
+			}
+			buf = make([]int16, next)
+		}
+		if saturate {
+			for i := range x {
+				buf[i] = saturateFloat32(x[i] * maxMag)
+			}
+		} else {
+			for i := range x {
+				buf[i] = wrapFloat32(x[i] * maxMag)
+			}
+		}
+		return buf[:len(x)]
+	}
+}
+
+// ShiftFn is a function type that returns a slice with the provided
+// sample scalars arithmetically shifted by a fixed number of bits.
+type ShiftFn func(x []int16) []int16
+
+// NewShiftFn creates a new ShiftFn. A positive bits argument shifts
+// left (e.g. to scale 12-bit samples to the full 16-bit domain) and a
+// negative bits argument shifts right.
+//
+// The saturate argument determines how a left shift that overflows
+// the int16 domain is handled. When saturate is true, the shifted
+// values are clamped with SaturateInt16. When saturate is false, the
+// shifted values wrap around. A right shift never overflows, so
+// saturate has no effect on it.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewShiftFn(bits int, saturate bool, hint ...Capacity) ShiftFn {
+	buf := make([]int16, bufferLen(hint, 2, 4096))
+	return func(x []int16) []int16 {
+		if len(buf) < len(x) {
+			next := len(buf) * 2
+			if next < len(x) {
+				next = len(x)
+			}
+			buf = make([]int16, next)
+		}
+		switch {
+		case bits < 0:
+			shift := uint(-bits)
+			for i := range x {
+				buf[i] = x[i] >> shift
+			}
+		case saturate:
+			shift := uint(bits)
+			for i := range x {
+				buf[i] = SaturateInt16(int32(x[i]) << shift)
+			}
+		default:
+			shift := uint(bits)
+			for i := range x {
+				buf[i] = x[i] << shift
+			}
+		}
+		return buf[:len(x)]
+	}
+}
+
+// ConvertToInt8Fn is a function type that returns a slice with the
+// provided sample scalars scaled and converted to int8.
+type ConvertToInt8Fn func(x []int16) []int8
+
+// NewConvertToInt8Fn creates a new ConvertToInt8Fn. It is intended for
+// compact archival formats (e.g. ci8/cs8) where 8 bits of resolution
+// are sufficient. The output is signed, unlike the unsigned offset
+// mapping used by rtl_sdr.
+//
+// The numBits argument determines the scaling factor. It is the number
+// of significant bits in the input domain, so a value in the numBits
+// domain is scaled to the int8 domain by an arithmetic right shift of
+// numBits-8 bits with rounding to the nearest value (ties round up).
+// When numBits is 8 or less, no scaling is applied. Values that fall
+// outside the int8 domain after scaling are clamped to the symmetric
+// range [-127,127], for the same reason as SaturateInt16.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewConvertToInt8Fn(numBits uint, hint ...Capacity) ConvertToInt8Fn {
+	if numBits > 16 {
+		numBits = 16
+	}
+	var shift, half uint
+	if numBits > 8 {
+		shift = numBits - 8
+		half = 1 << (shift - 1)
+	}
+	buf := make([]int8, bufferLen(hint, 2, 4096))
+	return func(x []int16) []int8 {
+		if len(buf) < len(x) {
+			next := len(buf) * 2
+			if next < len(x) {
+				next = len(x)
+			}
+			buf = make([]int8, next)
+		}
+		for i := range x {
+			buf[i] = saturateInt8((int32(x[i]) + int32(half)) >> shift)
+		}
+		return buf[:len(x)]
+	}
+}
@@ -43,3 +43,15 @@ func ExampleConvertToComplex64Fn() {
 	// Output:
 	// [(0-0.5i) (0.49996948-1i) (0.9999695-0.5i) (0.49996948+0i)]
 }
+
+func ExampleConvertFromFloat32Fn() {
+	floats := []float32{0, 0.5, 1, 1.5, -1.5}
+
+	saturate := callback.NewConvertFromFloat32Fn(16, true)
+	fmt.Println(saturate(floats))
+	wrap := callback.NewConvertFromFloat32Fn(16, false)
+	fmt.Println(wrap(floats))
+	// Output:
+	// [0 16384 32767 32767 -32767]
+	// [0 16384 -32768 -16384 16384]
+}
@@ -93,6 +93,55 @@ func BenchmarkConvertToFloat32(b *testing.B) {
 	}
 }
 
+func TestConvertToFloat32Table(t *testing.T) {
+	t.Parallel()
+
+	x := make([]int16, 1<<16)
+	for i := range x {
+		x[i] = int16(uint16(i))
+	}
+	for _, numBits := range []uint{8, 12, 14, 16, 17} {
+		want := NewConvertToFloat32Fn(numBits)(x)
+		got := NewConvertToFloat32TableFn(numBits)(x)
+		if len(got) != len(want) {
+			t.Fatalf("wrong length with numBits=%d: got %d, want %d", numBits, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("wrong value for %d with numBits=%d: got %v, want %v", x[i], numBits, got[i], want[i])
+			}
+		}
+	}
+}
+
+// benchmarkConvertToFloat32Rate converts one second of interleaved
+// samples at the provided sample rate per iteration in callback-sized
+// blocks of random values.
+func benchmarkConvertToFloat32Rate(b *testing.B, conv ConvertToFloat32Fn, rate int) {
+	const blockLen = 2 * 1008
+	x := make([]int16, blockLen)
+	for i := range x {
+		x[i] = int16(rand.Intn(1 << 16))
+	}
+	numBlocks := 2 * rate / blockLen
+	b.SetBytes(int64(numBlocks * blockLen * 2))
+	// Exclude building the conversion table and the samples.
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < numBlocks; j++ {
+			conv(x)
+		}
+	}
+}
+
+func BenchmarkConvertToFloat32Divide8MHz(b *testing.B) {
+	benchmarkConvertToFloat32Rate(b, NewConvertToFloat32Fn(14), 8e6)
+}
+
+func BenchmarkConvertToFloat32Table8MHz(b *testing.B) {
+	benchmarkConvertToFloat32Rate(b, NewConvertToFloat32TableFn(14), 8e6)
+}
+
 func BenchmarkConvertToComplex64(b *testing.B) {
 	xi := make([]int16, 2048)
 	xq := make([]int16, 2048)
@@ -102,3 +151,133 @@ func BenchmarkConvertToComplex64(b *testing.B) {
 		conv(xi, xq)
 	}
 }
+
+func TestSaturateInt16(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		in   int32
+		want int16
+	}{
+		{0, 0},
+		{32767, 32767},
+		{32768, 32767},
+		{-32767, -32767},
+		{-32768, -32767},
+		{1 << 30, 32767},
+		{-(1 << 30), -32767},
+	}
+	for _, spec := range specs {
+		if got := SaturateInt16(spec.in); got != spec.want {
+			t.Errorf("wrong value for %d: got %d, want %d", spec.in, got, spec.want)
+		}
+	}
+}
+
+func TestConvertFromFloat32(t *testing.T) {
+	t.Parallel()
+
+	in := []float32{0, 0.5, -0.5, 32767.0 / 32768, 1, -1, 1.5, -1.5}
+	specs := []struct {
+		saturate bool
+		want     []int16
+	}{
+		{true, []int16{0, 16384, -16384, 32767, 32767, -32767, 32767, -32767}},
+		{false, []int16{0, 16384, -16384, 32767, -32768, -32768, -16384, 16384}},
+	}
+	for _, spec := range specs {
+		convert := NewConvertFromFloat32Fn(16, spec.saturate)
+		got := convert(in)
+		if len(got) != len(spec.want) {
+			t.Fatalf("wrong length: got %d, want %d", len(got), len(spec.want))
+		}
+		for i := range got {
+			if got[i] != spec.want[i] {
+				t.Errorf("wrong value for %f with saturate=%v: got %d, want %d", in[i], spec.saturate, got[i], spec.want[i])
+			}
+		}
+	}
+
+	convert := NewConvertFromFloat32Fn(16, true)
+	back := NewConvertToFloat32Fn(16)
+	for i := 0; i < 100; i++ {
+		samples := make([]int16, rand.Int31n(20000))
+		for j := range samples {
+			samples[j] = int16(j)
+		}
+		res := convert(back(samples))
+		if len(res) != len(samples) {
+			t.Fatalf("wrong round-trip length: got %d, want %d", len(res), len(samples))
+		}
+		for j := range samples {
+			if res[j] != samples[j] {
+				t.Fatalf("wrong round-trip value: got %d, want %d", res[j], samples[j])
+			}
+		}
+	}
+}
+
+func TestShift(t *testing.T) {
+	t.Parallel()
+
+	in := []int16{0, 1, -1, 2047, -2048, 4096, -4097}
+	specs := []struct {
+		bits     int
+		saturate bool
+		want     []int16
+	}{
+		{4, true, []int16{0, 16, -16, 32752, -32767, 32767, -32767}},
+		{4, false, []int16{0, 16, -16, 32752, -32768, 0, -16}},
+		{-4, true, []int16{0, 0, -1, 127, -128, 256, -257}},
+		{-4, false, []int16{0, 0, -1, 127, -128, 256, -257}},
+		{0, true, in},
+	}
+	for _, spec := range specs {
+		shift := NewShiftFn(spec.bits, spec.saturate)
+		got := shift(in)
+		if len(got) != len(spec.want) {
+			t.Fatalf("wrong length: got %d, want %d", len(got), len(spec.want))
+		}
+		for i := range got {
+			if got[i] != spec.want[i] {
+				t.Errorf("wrong value for %d with bits=%d saturate=%v: got %d, want %d", in[i], spec.bits, spec.saturate, got[i], spec.want[i])
+			}
+		}
+	}
+}
+
+func BenchmarkConvertFromFloat32(b *testing.B) {
+	x := make([]float32, 4096)
+	conv := NewConvertFromFloat32Fn(16, true)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		conv(x)
+	}
+}
+
+func TestConvertToInt8(t *testing.T) {
+	t.Parallel()
+
+	in := []int16{0, 127, 128, -128, -129, 256, -256, 32639, 32640, 32767, -32768}
+	specs := []struct {
+		numBits uint
+		want    []int8
+	}{
+		{16, []int8{0, 0, 1, 0, -1, 1, -1, 127, 127, 127, -127}},
+		{12, []int8{0, 8, 8, -8, -8, 16, -16, 127, 127, 127, -127}},
+		{8, []int8{0, 127, 127, -127, -127, 127, -127, 127, 127, 127, -127}},
+		{4, []int8{0, 127, 127, -127, -127, 127, -127, 127, 127, 127, -127}},
+	}
+	for _, spec := range specs {
+		convert := NewConvertToInt8Fn(spec.numBits)
+		got := convert(in)
+		if len(got) != len(spec.want) {
+			t.Fatalf("wrong length: got %d, want %d", len(got), len(spec.want))
+		}
+		for i := range got {
+			if got[i] != spec.want[i] {
+				t.Errorf("wrong value for %d with numBits=%d: got %d, want %d", in[i], spec.numBits, got[i], spec.want[i])
+			}
+		}
+	}
+}
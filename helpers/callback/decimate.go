@@ -0,0 +1,208 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/msiner/sdrplay-go/helpers/dsp"
+)
+
+// DecimateFn is a function type that low-pass filters the provided
+// complex samples and returns only every Nth filtered sample, where N is
+// the total decimation factor. The xi slice contains the real component
+// and the xq slice contains the imaginary component. If the lengths
+// differ, the trailing samples of the longer slice are discarded.
+//
+// A DecimateFn keeps its filter history and output phase between calls,
+// so the output is continuous across callbacks regardless of how the
+// input is split. A call may return fewer samples than len(xi)/N, or
+// none at all, with the remainder carried into the next call.
+type DecimateFn func(xi, xq []int16) (yi, yq []int16)
+
+// decimateTapsPerFactor is the number of FIR taps used per unit of
+// decimation factor in each stage. It sets the width of the transition
+// band relative to the output sample rate of the stage.
+const decimateTapsPerFactor = 16
+
+// decimateCutoff is the cutoff frequency of each stage as a fraction of
+// the output Nyquist frequency of that stage. The space between the
+// cutoff and the output Nyquist frequency is used by the transition band.
+const decimateCutoff = 0.8
+
+// decimateStage is a single FIR low-pass filter and downsampler
+// operating on complex float32 samples.
+type decimateStage struct {
+	factor int
+	taps   []float32
+	// hi and hq hold the last len(taps)-1 input samples followed by
+	// the current input samples.
+	hi    []float32
+	hq    []float32
+	phase int
+}
+
+// newDecimateStage creates a decimateStage with a Blackman-windowed
+// sinc low-pass filter normalized to unity gain at DC.
+func newDecimateStage(factor, capacity int) *decimateStage {
+	numTaps := decimateTapsPerFactor*factor + 1
+	vals := lowPassTaps(numTaps, decimateCutoff*0.5/float64(factor))
+	taps := make([]float32, numTaps)
+	for i := range vals {
+		taps[i] = float32(vals[i])
+	}
+	return &decimateStage{
+		factor: factor,
+		taps:   taps,
+		hi:     make([]float32, numTaps-1, numTaps-1+capacity),
+		hq:     make([]float32, numTaps-1, numTaps-1+capacity),
+	}
+}
+
+// lowPassTaps designs a Blackman-windowed sinc low-pass filter with
+// numTaps taps and a cutoff of fc cycles per sample. The taps are
+// normalized to unity gain at DC.
+func lowPassTaps(numTaps int, fc float64) []float64 {
+	mid := float64(numTaps-1) / 2
+	w := dsp.Window(dsp.Blackman, numTaps)
+	var sum float64
+	vals := make([]float64, numTaps)
+	for i := range vals {
+		n := float64(i) - mid
+		v := 2 * fc
+		if n != 0 {
+			v = math.Sin(2*math.Pi*fc*n) / (math.Pi * n)
+		}
+		vals[i] = v * float64(w[i])
+		sum += vals[i]
+	}
+	for i := range vals {
+		vals[i] /= sum
+	}
+	return vals
+}
+
+// process filters and downsamples xi and xq, appending the output
+// to yi and yq and returning the extended slices.
+func (s *decimateStage) process(xi, xq, yi, yq []float32) ([]float32, []float32) {
+	numHist := len(s.taps) - 1
+	s.hi = append(s.hi, xi...)
+	s.hq = append(s.hq, xq...)
+
+	i := s.phase
+	for ; i < len(xi); i += s.factor {
+		// Output for input index i uses the numTaps samples ending at
+		// position numHist+i of the history buffer.
+		wi := s.hi[i : i+len(s.taps)]
+		wq := s.hq[i : i+len(s.taps)]
+		var accI, accQ float32
+		for j, tap := range s.taps {
+			accI += tap * wi[j]
+			accQ += tap * wq[j]
+		}
+		yi = append(yi, accI)
+		yq = append(yq, accQ)
+	}
+	s.phase = i - len(xi)
+
+	// Retain only the history needed for the next call.
+	n := copy(s.hi, s.hi[len(s.hi)-numHist:])
+	s.hi = s.hi[:n]
+	n = copy(s.hq, s.hq[len(s.hq)-numHist:])
+	s.hq = s.hq[:n]
+	return yi, yq
+}
+
+// NewDecimateFn creates a new DecimateFn with a single stage that
+// decimates by the provided factor. The stage uses a windowed-sinc FIR
+// anti-alias filter with a cutoff at 80% of the output Nyquist
+// frequency. A factor of 1 returns the input unfiltered.
+//
+// The function uses internal persistent buffers to minimize allocations.
+// The returned slices are slices of those internal buffers and should
+// not be modified or stored.
+func NewDecimateFn(factor int, hint ...Capacity) (DecimateFn, error) {
+	return NewCascadeDecimateFn([]int{factor}, hint...)
+}
+
+// NewCascadeDecimateFn creates a new DecimateFn that chains one
+// decimation stage for each of the provided factors. The total
+// decimation factor is the product of the factors. Each stage has its
+// own anti-alias filter, identical to the one used by NewDecimateFn,
+// sized relative to its own factor. A stage has about 16 taps per unit
+// of its factor but only computes every factor-th output, so it costs
+// about 16 multiply-accumulates per input sample of that stage,
+// regardless of its factor. A cascade therefore costs somewhat more than
+// a single stage with the same total ratio, with each later stage adding
+// the cost of its lower input rate. To keep that addition small, put the
+// largest factors first.
+//
+// Samples are carried between stages as float32 and only rounded to
+// int16 at the output, so cascading does not accumulate quantization
+// error.
+//
+// The function uses internal persistent buffers to minimize allocations.
+// The returned slices are slices of those internal buffers and should
+// not be modified or stored.
+func NewCascadeDecimateFn(factors []int, hint ...Capacity) (DecimateFn, error) {
+	capacity := bufferLen(hint, 1, 4096)
+	var stages []*decimateStage
+	for _, factor := range factors {
+		switch {
+		case factor < 1:
+			return nil, fmt.Errorf("invalid decimation factor: got %d, want >= 1", factor)
+		case factor == 1:
+			continue
+		}
+		stages = append(stages, newDecimateStage(factor, capacity))
+	}
+
+	// No stage output is longer than its input, so every buffer is
+	// sized for the input.
+	var (
+		bufI = make([]float32, 0, capacity)
+		bufQ = make([]float32, 0, capacity)
+		tmpI = make([]float32, 0, capacity)
+		tmpQ = make([]float32, 0, capacity)
+		outI = make([]int16, capacity)
+		outQ = make([]int16, capacity)
+	)
+	return func(xi, xq []int16) ([]int16, []int16) {
+		minLen := len(xi)
+		if len(xq) < minLen {
+			minLen = len(xq)
+		}
+		if len(stages) == 0 {
+			return xi[:minLen], xq[:minLen]
+		}
+
+		bufI = bufI[:0]
+		bufQ = bufQ[:0]
+		for i := 0; i < minLen; i++ {
+			bufI = append(bufI, float32(xi[i]))
+			bufQ = append(bufQ, float32(xq[i]))
+		}
+		for _, s := range stages {
+			tmpI, tmpQ = s.process(bufI, bufQ, tmpI[:0], tmpQ[:0])
+			bufI, bufQ, tmpI, tmpQ = tmpI, tmpQ, bufI, bufQ
+		}
+
+		n := len(bufI)
+		if len(outI) < n {
+			next := len(outI) * 2
+			if next < n {
+				next = n
+			}
+			outI = make([]int16, next)
+			outQ = make([]int16, next)
+		}
+		for i := 0; i < n; i++ {
+			outI[i] = SaturateInt16(int32(math.Floor(float64(bufI[i]) + 0.5)))
+			outQ[i] = SaturateInt16(int32(math.Floor(float64(bufQ[i]) + 0.5)))
+		}
+		return outI[:n], outQ[:n]
+	}, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// toneAmplitude returns the magnitude of the projection of the provided
+// complex samples onto a complex tone with frequency f in cycles per
+// sample.
+func toneAmplitude(xi, xq []int16, f float64) float64 {
+	var acc complex128
+	for n := range xi {
+		x := complex(float64(xi[n]), float64(xq[n]))
+		acc += x * cmplx.Exp(complex(0, -2*math.Pi*f*float64(n)))
+	}
+	return cmplx.Abs(acc) / float64(len(xi))
+}
+
+func TestCascadeDecimate(t *testing.T) {
+	t.Parallel()
+
+	const (
+		ratio     = 128
+		numOut    = 2048
+		numIn     = numOut * ratio
+		amp       = 10000
+		toneOut   = 0.1  // tone frequency relative to output rate
+		aliasOut  = 1.2  // alias frequency relative to output rate
+		aliasedTo = 0.2  // frequency the alias would fold to
+		settle    = 64   // output samples to skip for filter transient
+		maxAlias  = 0.01 // max alias amplitude relative to amp
+	)
+
+	decimate, err := NewCascadeDecimateFn([]int{2, 4, 4, 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	xi := make([]int16, numIn)
+	xq := make([]int16, numIn)
+	for n := range xi {
+		p1 := 2 * math.Pi * toneOut / ratio * float64(n)
+		p2 := 2 * math.Pi * aliasOut / ratio * float64(n)
+		xi[n] = int16(math.Round(amp/2*math.Cos(p1) + amp/2*math.Cos(p2)))
+		xq[n] = int16(math.Round(amp/2*math.Sin(p1) + amp/2*math.Sin(p2)))
+	}
+
+	var yi, yq []int16
+	// Feed uneven chunks to exercise state carried across callbacks.
+	for start := 0; start < numIn; {
+		end := start + rand.Intn(5000) + 1
+		if end > numIn {
+			end = numIn
+		}
+		gi, gq := decimate(xi[start:end], xq[start:end])
+		yi = append(yi, gi...)
+		yq = append(yq, gq...)
+		start = end
+	}
+	if len(yi) != numOut {
+		t.Fatalf("wrong output length: got %d, want %d", len(yi), numOut)
+	}
+
+	yi = yi[settle:]
+	yq = yq[settle:]
+	if got := toneAmplitude(yi, yq, toneOut); math.Abs(got-amp/2) > amp/2*0.01 {
+		t.Errorf("wrong tone amplitude: got %.1f, want %.1f", got, float64(amp/2))
+	}
+	if got := toneAmplitude(yi, yq, aliasedTo); got > amp/2*maxAlias {
+		t.Errorf("alias not rejected: got %.1f, want < %.1f", got, amp/2*maxAlias)
+	}
+}
+
+func TestDecimateChunking(t *testing.T) {
+	t.Parallel()
+
+	const numIn = 10000
+	xi := make([]int16, numIn)
+	xq := make([]int16, numIn)
+	for n := range xi {
+		xi[n] = int16(rand.Intn(20000) - 10000)
+		xq[n] = int16(rand.Intn(20000) - 10000)
+	}
+
+	whole, err := NewDecimateFn(8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantI, wantQ := whole(xi, xq)
+	wantI = append([]int16(nil), wantI...)
+	wantQ = append([]int16(nil), wantQ...)
+	if len(wantI) != numIn/8 {
+		t.Fatalf("wrong output length: got %d, want %d", len(wantI), numIn/8)
+	}
+
+	chunked, err := NewDecimateFn(8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var gotI, gotQ []int16
+	for start := 0; start < numIn; {
+		end := start + rand.Intn(13) + 1
+		if end > numIn {
+			end = numIn
+		}
+		gi, gq := chunked(xi[start:end], xq[start:end])
+		gotI = append(gotI, gi...)
+		gotQ = append(gotQ, gq...)
+		start = end
+	}
+	if len(gotI) != len(wantI) {
+		t.Fatalf("wrong chunked output length: got %d, want %d", len(gotI), len(wantI))
+	}
+	for i := range wantI {
+		if gotI[i] != wantI[i] || gotQ[i] != wantQ[i] {
+			t.Fatalf("wrong sample %d: got (%d,%d), want (%d,%d)", i, gotI[i], gotQ[i], wantI[i], wantQ[i])
+		}
+	}
+}
+
+func TestDecimateInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, factors := range [][]int{{0}, {-2}, {2, 0, 4}} {
+		if _, err := NewCascadeDecimateFn(factors); err == nil {
+			t.Errorf("unexpected success for factors=%v", factors)
+		}
+	}
+
+	decimate, err := NewDecimateFn(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	xi := []int16{1, 2, 3}
+	xq := []int16{4, 5}
+	yi, yq := decimate(xi, xq)
+	if len(yi) != 2 || len(yq) != 2 || yi[1] != 2 || yq[1] != 5 {
+		t.Errorf("wrong pass-through output: got %v %v", yi, yq)
+	}
+}
@@ -22,5 +22,31 @@ sized buffer as an input argument.
 The buffers returned by such functions must not be stored, reused, or
 otherwise escape the callback. Copy samples out of the buffer in they
 need be used later.
+
+The internal buffers grow on the first call with more samples than
+they can hold. To keep that allocation out of the first stream
+callbacks, the constructors accept an optional Capacity hint of the
+largest expected callback, such as one from CapacityForRate.
+
+	fs, _ := session.GetEffectiveSampleRate(d, p, p.RxChannelA)
+	hint := CapacityForRate(fs)
+	write := NewWriteFn(binary.LittleEndian, hint)
+	interleave := NewInterleaveFn(hint)
+
+A WriteFn blocks the callback for the duration of the Write. To
+overlap the I/O with the processing of the next callback, write
+through an AsyncWriter, which queues a copy of each Write for a
+separate goroutine.
+
+	aw, _ := NewAsyncWriter(out, 8, hint)
+	defer aw.Close()
+	...
+	n, err := write(aw, interleave(xi, xq))
+
+Functions that produce int16 output from a wider intermediate value,
+such as NewConvertFromFloat32Fn and NewShiftFn, take a saturate argument.
+With saturation, out-of-range values are clamped by SaturateInt16 to the
+symmetric range [-32767, 32767]. Without it, values wrap around. Prefer
+saturation unless the consumer specifically expects modular arithmetic.
 */
 package callback
@@ -19,7 +19,28 @@ type DropDetectFn func(params *api.StreamCbParamsT, reset bool) uint32
 // FirstSampleNum and NumSamples fields in the callback params. To work,
 // it must be called every callback to keep the internal state valid.
 // When reset is true, it resets all internal state and reports 0 drops.
+// The first call after creation is treated the same as a reset.
+//
+// Every discontinuity in the sample number is reported as a drop. Use
+// NewDropDetectFnWithThreshold to ignore discontinuities that are more
+// likely to be a reset of the sample counter than a gap in the stream.
 func NewDropDetectFn() DropDetectFn {
+	return NewDropDetectFnWithThreshold(math.MaxUint32)
+}
+
+// NewDropDetectFnWithThreshold creates a new DropDetectFn that behaves
+// the same as one created by NewDropDetectFn except that any apparent
+// gap larger than threshold samples is treated as a reset of the sample
+// counter. In that case, it resynchronizes its internal state to the
+// new sample number and reports 0 drops.
+//
+// The API resets the sample counter when streaming starts and after
+// some reconfigurations (e.g. a retune) and does not always signal it
+// with the reset flag. A counter that restarts at a lower number looks
+// like a gap of nearly 2^32 samples and would otherwise be reported as
+// a huge drop. A threshold of about one second worth of samples at the
+// stream sample rate is a reasonable choice.
+func NewDropDetectFnWithThreshold(threshold uint32) DropDetectFn {
 	var (
 		valid         bool
 		lastSampleNum uint32
@@ -54,13 +75,21 @@ func NewDropDetectFn() DropDetectFn {
 		}
 
 		// Compute packet count gap while compensating for an apparent wrap.
+		var gap uint32
 		switch first < last {
 		case true:
 			// possible wrap
-			return (math.MaxUint32 - last) + first
+			gap = (math.MaxUint32 - last) + first
 		default:
 			// possible normal increase
-			return first - last
+			gap = first - last
+		}
+
+		// The internal state was already resynchronized to the new
+		// sample number above.
+		if gap > threshold {
+			return 0
 		}
+		return gap
 	}
 }
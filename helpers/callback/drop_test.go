@@ -79,3 +79,58 @@ func TestDropDetect(t *testing.T) {
 		t.Errorf("wrong number of drops: got %d, want %d", n, uint32(math.MaxUint32-1))
 	}
 }
+
+func TestDropDetectThreshold(t *testing.T) {
+	t.Parallel()
+
+	const (
+		numSamples = 1000
+		threshold  = 100 * numSamples
+	)
+
+	params := &api.StreamCbParamsT{
+		FirstSampleNum: 50000,
+		NumSamples:     numSamples,
+	}
+
+	specs := []struct {
+		desc  string
+		next  func(first uint32) uint32
+		reset bool
+		want  uint32
+	}{
+		// The first call is always treated as a reset.
+		{"first call", func(f uint32) uint32 { return f }, false, 0},
+		{"normal increment", func(f uint32) uint32 { return f + numSamples }, false, 0},
+		{"genuine gap", func(f uint32) uint32 { return f + numSamples + 500 }, false, 500},
+		{"gap at threshold", func(f uint32) uint32 { return f + numSamples + threshold }, false, threshold},
+		{"counter reset to zero", func(f uint32) uint32 { return 0 }, false, 0},
+		{"increment after counter reset", func(f uint32) uint32 { return f + numSamples }, false, 0},
+		{"huge forward jump", func(f uint32) uint32 { return f + numSamples + threshold + 1 }, false, 0},
+		{"increment after jump", func(f uint32) uint32 { return f + numSamples }, false, 0},
+		{"gap after jump", func(f uint32) uint32 { return f + numSamples + 7 }, false, 7},
+		{"reset flag", func(f uint32) uint32 { return 12345 }, true, 0},
+		{"increment after reset flag", func(f uint32) uint32 { return f + numSamples }, false, 0},
+	}
+
+	detect := NewDropDetectFnWithThreshold(threshold)
+	first := true
+	for _, spec := range specs {
+		if !first {
+			params.FirstSampleNum = spec.next(params.FirstSampleNum)
+		}
+		first = false
+		if n := detect(params, spec.reset); n != spec.want {
+			t.Errorf("wrong number of drops for %s: got %d, want %d", spec.desc, n, spec.want)
+		}
+	}
+
+	// Without a threshold, the counter reset is reported as a huge drop.
+	detect = NewDropDetectFn()
+	params.FirstSampleNum = 50000
+	detect(params, false)
+	params.FirstSampleNum = 0
+	if n := detect(params, false); n < threshold {
+		t.Errorf("wrong number of drops without threshold: got %d, want > %d", n, threshold)
+	}
+}
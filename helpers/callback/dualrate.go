@@ -0,0 +1,108 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DualRateSink is a function type that receives the samples of one of
+// the outputs of a DualRateFn. The first argument is the number of the
+// first sample in xi and xq at the rate of that output, counted from the
+// first sample passed to the DualRateFn. The slices must not be stored.
+type DualRateSink func(xi, xq []int16, first uint64) error
+
+// DualRateFn is a function type that passes the provided samples to a
+// raw sink unchanged and to a decimated sink after decimation. It
+// returns a non-nil error if either sink fails.
+type DualRateFn func(xi, xq []int16) error
+
+// DualRate describes the outputs of a DualRateFn.
+type DualRate struct {
+	// Rate is the sample rate of the input and the raw output.
+	Rate float64
+	// Factor is the total decimation factor.
+	Factor int
+	// DecimatedRate is the sample rate of the decimated output, which
+	// is Rate divided by Factor.
+	DecimatedRate float64
+	// Delay is the group delay of the decimation filters in raw
+	// samples. Decimated sample k corresponds to raw sample
+	// k*Factor-Delay.
+	Delay float64
+}
+
+// RawSample returns the raw sample number that corresponds to the
+// decimated sample number k. It is negative for the first decimated
+// samples, which are computed from the initial zero filter history.
+func (r DualRate) RawSample(k uint64) float64 {
+	return float64(k)*float64(r.Factor) - r.Delay
+}
+
+// NewDualRateFn creates a new DualRateFn for an input at sample rate fs
+// that delivers the samples to raw at the full rate and to dec after
+// decimation with a DecimateFn created by NewCascadeDecimateFn with the
+// provided factors. This provides, for example, a full-rate stream for
+// a spectrum display and a narrowband stream for a demodulator from the
+// same capture. Use a TeeFn in a sink to deliver an output to more than
+// one destination.
+//
+// Because the decimator may return fewer samples than len(xi)/Factor
+// for a call, the sinks receive the number of the first sample of each
+// call, and the returned DualRate relates the sample numbers of the two
+// outputs. Both sinks are called for every call, even if the decimator
+// returns no samples or the raw sink fails. The returned error
+// identifies the first failed sink.
+//
+// The DualRateFn keeps the decimator state between calls, so the input
+// must be continuous. After a discontinuity (e.g. a stream reset),
+// create a new one.
+func NewDualRateFn(fs float64, factors []int, raw, dec DualRateSink, hint ...Capacity) (DualRateFn, DualRate, error) {
+	switch {
+	case math.IsNaN(fs) || fs <= 0:
+		return nil, DualRate{}, fmt.Errorf("invalid sample rate: got %v, want > 0", fs)
+	case raw == nil || dec == nil:
+		return nil, DualRate{}, errors.New("missing dual rate sink")
+	}
+	decimate, err := NewCascadeDecimateFn(factors, hint...)
+	if err != nil {
+		return nil, DualRate{}, err
+	}
+
+	// Each stage delays its input by half of its filter length.
+	rate := DualRate{Rate: fs, Factor: 1}
+	for _, f := range factors {
+		if f == 1 {
+			continue
+		}
+		rate.Delay += float64(decimateTapsPerFactor*f) / 2 * float64(rate.Factor)
+		rate.Factor *= f
+	}
+	rate.DecimatedRate = fs / float64(rate.Factor)
+
+	var numRaw, numDec uint64
+	return func(xi, xq []int16) error {
+		minLen := len(xi)
+		if len(xq) < minLen {
+			minLen = len(xq)
+		}
+		xi, xq = xi[:minLen], xq[:minLen]
+
+		var first error
+		if err := raw(xi, xq, numRaw); err != nil {
+			first = fmt.Errorf("raw sink failed: %v", err)
+		}
+		numRaw += uint64(minLen)
+
+		yi, yq := decimate(xi, xq)
+		if err := dec(yi, yq, numDec); err != nil && first == nil {
+			first = fmt.Errorf("decimated sink failed: %v", err)
+		}
+		numDec += uint64(len(yi))
+		return first
+	}, rate, nil
+}
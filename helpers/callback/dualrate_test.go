@@ -0,0 +1,146 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDualRateFn(t *testing.T) {
+	t.Parallel()
+
+	const (
+		fs     = 2e6
+		numIn  = 1 << 16
+		amp    = 10000
+		tone   = 0.01 // cycles per raw sample
+		settle = 64   // decimated samples to skip for filter transient
+	)
+
+	xi := make([]int16, numIn)
+	xq := make([]int16, numIn)
+	for n := range xi {
+		p := 2 * math.Pi * tone * float64(n)
+		xi[n] = int16(math.Round(amp * math.Cos(p)))
+		xq[n] = int16(math.Round(amp * math.Sin(p)))
+	}
+
+	var (
+		rawI, rawQ, decI, decQ []int16
+		rawNext, decNext       uint64
+	)
+	rawSink := func(yi, yq []int16, first uint64) error {
+		if first != rawNext {
+			t.Errorf("wrong raw first sample: got %d, want %d", first, rawNext)
+		}
+		rawNext += uint64(len(yi))
+		rawI = append(rawI, yi...)
+		rawQ = append(rawQ, yq...)
+		return nil
+	}
+	decSink := func(yi, yq []int16, first uint64) error {
+		if first != decNext {
+			t.Errorf("wrong decimated first sample: got %d, want %d", first, decNext)
+		}
+		decNext += uint64(len(yi))
+		decI = append(decI, yi...)
+		decQ = append(decQ, yq...)
+		return nil
+	}
+	fn, rate, err := NewDualRateFn(fs, []int{2, 1, 4}, rawSink, decSink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DualRate{Rate: fs, Factor: 8, DecimatedRate: fs / 8, Delay: 16 + 32*2}
+	if rate != want {
+		t.Errorf("wrong rate: got %+v, want %+v", rate, want)
+	}
+
+	// Feed uneven chunks to exercise state carried across callbacks.
+	rng := rand.New(rand.NewSource(1))
+	for start := 0; start < numIn; {
+		end := start + rng.Intn(3000) + 1
+		if end > numIn {
+			end = numIn
+		}
+		if err := fn(xi[start:end], xq[start:end]); err != nil {
+			t.Fatal(err)
+		}
+		start = end
+	}
+
+	if len(rawI) != numIn {
+		t.Fatalf("wrong raw length: got %d, want %d", len(rawI), numIn)
+	}
+	for n := range rawI {
+		if rawI[n] != xi[n] || rawQ[n] != xq[n] {
+			t.Fatalf("wrong raw sample %d: got (%d,%d), want (%d,%d)", n, rawI[n], rawQ[n], xi[n], xq[n])
+		}
+	}
+	if want := numIn / rate.Factor; len(decI) != want {
+		t.Fatalf("wrong decimated length: got %d, want %d", len(decI), want)
+	}
+
+	// Each decimated sample matches the tone at the corresponding raw
+	// sample.
+	for k := settle; k < len(decI); k++ {
+		got := complex(float64(decI[k]), float64(decQ[k]))
+		want := cmplx.Rect(amp, 2*math.Pi*tone*rate.RawSample(uint64(k)))
+		if d := cmplx.Abs(got - want); d > amp*0.01 {
+			t.Fatalf("wrong decimated sample %d: got %v, want %v", k, got, want)
+		}
+	}
+}
+
+func TestDualRateFnErrors(t *testing.T) {
+	t.Parallel()
+
+	ok := func(xi, xq []int16, first uint64) error { return nil }
+	fail := func(xi, xq []int16, first uint64) error { return errors.New("full") }
+
+	if _, _, err := NewDualRateFn(0, []int{2}, ok, ok); err == nil {
+		t.Error("missing error for invalid rate")
+	}
+	if _, _, err := NewDualRateFn(1e6, []int{0}, ok, ok); err == nil {
+		t.Error("missing error for invalid factor")
+	}
+	if _, _, err := NewDualRateFn(1e6, []int{2}, nil, ok); err == nil {
+		t.Error("missing error for missing sink")
+	}
+
+	// Both sinks are called and the first failure is reported.
+	var calls int
+	count := func(xi, xq []int16, first uint64) error {
+		calls++
+		return nil
+	}
+	specs := []struct {
+		raw, dec DualRateSink
+		want     string
+	}{
+		{fail, count, "raw sink failed"},
+		{count, fail, "decimated sink failed"},
+	}
+	for _, spec := range specs {
+		calls = 0
+		fn, _, err := NewDualRateFn(1e6, []int{2}, spec.raw, spec.dec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x := make([]int16, 100)
+		err = fn(x, x)
+		if err == nil || !strings.Contains(err.Error(), spec.want) {
+			t.Errorf("wrong error: got %v, want %q", err, spec.want)
+		}
+		if calls != 1 {
+			t.Errorf("wrong number of other sink calls: got %d, want 1", calls)
+		}
+	}
+}
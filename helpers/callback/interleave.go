@@ -10,6 +10,11 @@ package callback
 // contains the imaginary component. The lengths of xi and xq should be
 // equal. The length of the resulting slice is twice the length of the
 // shortest of the lengths of xi and xq.
+//
+// The lengths can differ if a buffer is truncated, for example around
+// a stream reset. In that case, the trailing scalars of the longer slice
+// are discarded so that the result only contains complete I/Q frames.
+// A nil or empty slice produces an empty result.
 type InterleaveFn func(xi, xq []int16) []int16
 
 // NewInterleaveFn creates a new InterleaveFn.
@@ -17,9 +22,9 @@ type InterleaveFn func(xi, xq []int16) []int16
 // The function uses an internal persistent buffer to minimize allocations.
 // The returned slice is a slice of that internal buffer and should not be
 // modified or stored.
-func NewInterleaveFn() InterleaveFn {
+func NewInterleaveFn(hint ...Capacity) InterleaveFn {
 	const scalarsPerFrame = 2
-	buf := make([]int16, 4096)
+	buf := make([]int16, bufferLen(hint, 2, 4096))
 	return func(xi, xq []int16) []int16 {
 		minLen := len(xi)
 		if len(xq) < minLen {
@@ -52,3 +57,59 @@ func NewInterleaveFn() InterleaveFn {
 		return buf[:numScalars]
 	}
 }
+
+// multiInterleaveScalars is the number of slices that the buffer of a
+// MultiInterleaveFn is sized for with a Capacity hint.
+const multiInterleaveScalars = 6
+
+// MultiInterleaveFn is a function type that returns a slice with the
+// provided sample scalar slices interleaved into a single slice. The
+// resulting frames contain one scalar from each slice in the order the
+// slices are provided (e.g. xs[0][0],xs[1][0],...,xs[0][1],xs[1][1],...).
+// The length of the resulting slice is the number of slices times the
+// length of the shortest slice. As with InterleaveFn, trailing scalars
+// of longer slices are discarded.
+type MultiInterleaveFn func(xs ...[]int16) []int16
+
+// NewMultiInterleaveFn creates a new MultiInterleaveFn. It is useful for
+// interleaving a number of channels that is not fixed at compile time,
+// such as I/Q plus derived channels like magnitude. For interleaving
+// only I/Q, InterleaveFn is more efficient.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored. Because the number of slices is only known when
+// the function is called, a Capacity hint sizes the buffer for up to
+// multiInterleaveScalars (6) slices, such as I/Q and magnitude of both
+// tuners of an RSPduo.
+func NewMultiInterleaveFn(hint ...Capacity) MultiInterleaveFn {
+	buf := make([]int16, bufferLen(hint, multiInterleaveScalars, 4096))
+	return func(xs ...[]int16) []int16 {
+		if len(xs) == 0 {
+			return buf[:0]
+		}
+		minLen := len(xs[0])
+		for _, x := range xs[1:] {
+			if len(x) < minLen {
+				minLen = len(x)
+			}
+		}
+		scalarsPerFrame := len(xs)
+		numScalars := minLen * scalarsPerFrame
+		if len(buf) < numScalars {
+			next := len(buf) * 2
+			if next < numScalars {
+				next = numScalars
+			}
+			buf = make([]int16, next)
+		}
+		for c, x := range xs {
+			bi := c
+			for i := 0; i < minLen; i++ {
+				buf[bi] = x[i]
+				bi += scalarsPerFrame
+			}
+		}
+		return buf[:numScalars]
+	}
+}
@@ -55,3 +55,34 @@ func BenchmarkInterleave(b *testing.B) {
 		inter(xi, xq)
 	}
 }
+
+func TestInterleaveMismatched(t *testing.T) {
+	t.Parallel()
+
+	xi := []int16{1, 2, 3, 4, 5}
+	xq := []int16{-1, -2, -3}
+	specs := []struct {
+		xi, xq []int16
+		want   []int16
+	}{
+		{xi, xq, []int16{1, -1, 2, -2, 3, -3}},
+		{xq, xi, []int16{-1, 1, -2, 2, -3, 3}},
+		{xi[:1], xq, []int16{1, -1}},
+		{xi, nil, []int16{}},
+		{nil, xq, []int16{}},
+		{nil, nil, []int16{}},
+	}
+	inter := NewInterleaveFn()
+	for i, spec := range specs {
+		x := inter(spec.xi, spec.xq)
+		if len(x) != len(spec.want) {
+			t.Errorf("%d: wrong length: got %d, want %d", i, len(x), len(spec.want))
+			continue
+		}
+		for j := range x {
+			if x[j] != spec.want[j] {
+				t.Errorf("%d: wrong value at %d: got %d, want %d", i, j, x[j], spec.want[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// JitterStats holds the callback timing statistics reported by a
+// JitterReportFn. The jitter of a callback is the wall-clock time since
+// the previous callback minus the time expected for its NumSamples
+// samples at the stream sample rate. A positive jitter means the
+// callback was late. All durations are zero if Intervals is zero.
+type JitterStats struct {
+	// Intervals is the number of measured callback intervals.
+	Intervals uint64
+	// Mean is the mean jitter. Over a long period, it is close to zero
+	// unless the sample rate is wrong or samples are being dropped.
+	Mean time.Duration
+	// Max is the jitter with the largest magnitude, with its sign.
+	Max time.Duration
+	// StdDev is the standard deviation of the jitter.
+	StdDev time.Duration
+}
+
+// JitterMonitorFn is a function type that records the wall-clock time
+// of a stream callback using the NumSamples field of the callback
+// params.
+type JitterMonitorFn func(params *api.StreamCbParamsT, reset bool)
+
+// JitterReportFn is a function type that returns the statistics of the
+// callback intervals recorded since the previous report and restarts
+// the accumulation.
+type JitterReportFn func() JitterStats
+
+// NewJitterMonitorFn creates a new JitterMonitorFn for a stream with
+// the effective sample rate fs and the JitterReportFn to read its
+// statistics. The monitor must be called every callback. The first call
+// after creation, or a call with reset set, only records the time,
+// because there is no previous callback to measure from. The two
+// functions are safe to call concurrently, so the report function can
+// be called, for example, periodically from a control loop.
+//
+// The SDRplay API delivers samples in bursts, so some jitter is
+// normal. Sustained large positive jitter means callbacks are being
+// delayed (e.g. by a slow callback or USB scheduling), which often
+// precedes dropped samples.
+func NewJitterMonitorFn(fs float64) (JitterMonitorFn, JitterReportFn) {
+	return newJitterMonitorFn(fs, time.Now)
+}
+
+// newJitterMonitorFn implements NewJitterMonitorFn with the provided
+// clock.
+func newJitterMonitorFn(fs float64, now func() time.Time) (JitterMonitorFn, JitterReportFn) {
+	var (
+		mu    sync.Mutex
+		valid bool
+		last  time.Time
+		count uint64
+		sum   float64
+		sumSq float64
+		max   float64
+	)
+	monitor := func(params *api.StreamCbParamsT, reset bool) {
+		t := now()
+		mu.Lock()
+		defer mu.Unlock()
+		prev := last
+		last = t
+		if reset || !valid {
+			valid = true
+			return
+		}
+		jitter := t.Sub(prev).Seconds() - float64(params.NumSamples)/fs
+		count++
+		sum += jitter
+		sumSq += jitter * jitter
+		if math.Abs(jitter) > math.Abs(max) {
+			max = jitter
+		}
+	}
+	report := func() JitterStats {
+		mu.Lock()
+		n, s, sq, m := count, sum, sumSq, max
+		count, sum, sumSq, max = 0, 0, 0, 0
+		mu.Unlock()
+		if n == 0 {
+			return JitterStats{}
+		}
+		mean := s / float64(n)
+		variance := sq/float64(n) - mean*mean
+		if variance < 0 {
+			variance = 0
+		}
+		return JitterStats{
+			Intervals: n,
+			Mean:      seconds(mean),
+			Max:       seconds(m),
+			StdDev:    seconds(math.Sqrt(variance)),
+		}
+	}
+	return monitor, report
+}
+
+// seconds converts a number of seconds to a time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestJitterMonitor(t *testing.T) {
+	t.Parallel()
+
+	// At 1 MHz, 1000 samples are expected every millisecond. The
+	// simulated callbacks are late or early by the offsets below.
+	const fs = 1e6
+	params := &api.StreamCbParamsT{NumSamples: 1000}
+	clock := time.Unix(1000, 0)
+	monitor, report := newJitterMonitorFn(fs, func() time.Time { return clock })
+
+	if got := report(); got != (JitterStats{}) {
+		t.Errorf("wrong stats without callbacks: got %+v, want zero", got)
+	}
+
+	// The first callback only records the time.
+	monitor(params, false)
+	offsets := []time.Duration{0, 200 * time.Microsecond, -200 * time.Microsecond, 600 * time.Microsecond, -600 * time.Microsecond}
+	for _, off := range offsets {
+		clock = clock.Add(time.Millisecond + off)
+		monitor(params, false)
+	}
+	// The jitter of each interval is the offset, so the mean is 0 and
+	// the variance is (2*0.2^2 + 2*0.6^2)/5 ms^2 = 0.16 ms^2.
+	want := JitterStats{
+		Intervals: 5,
+		Mean:      0,
+		Max:       600 * time.Microsecond,
+		StdDev:    400 * time.Microsecond,
+	}
+	if got := report(); got != want {
+		t.Errorf("wrong stats: got %+v, want %+v", got, want)
+	}
+
+	// The report restarts the accumulation, but not the timing.
+	clock = clock.Add(3 * time.Millisecond)
+	monitor(params, false)
+	want = JitterStats{Intervals: 1, Mean: 2 * time.Millisecond, Max: 2 * time.Millisecond}
+	if got := report(); got != want {
+		t.Errorf("wrong stats after report: got %+v, want %+v", got, want)
+	}
+
+	// A reset restarts the timing, so a long gap is not measured.
+	clock = clock.Add(time.Second)
+	monitor(params, true)
+	clock = clock.Add(500 * time.Microsecond)
+	params.NumSamples = 500
+	monitor(params, false)
+	want = JitterStats{Intervals: 1}
+	if got := report(); got != want {
+		t.Errorf("wrong stats after reset: got %+v, want %+v", got, want)
+	}
+}
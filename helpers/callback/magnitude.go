@@ -0,0 +1,46 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import "math"
+
+// MagnitudeFn is a function type that returns a slice with the magnitude
+// of each complex sample formed by the provided component sample scalars.
+// The xi slice contains the real component and the xq slice contains the
+// imaginary component. The length of the resulting slice is the shortest
+// of the lengths of xi and xq.
+type MagnitudeFn func(xi, xq []int16) []int16
+
+// NewMagnitudeFn creates a new MagnitudeFn. The magnitude is rounded to
+// the nearest integer and, because the magnitude of a full-scale complex
+// sample can exceed the int16 domain, saturated with SaturateInt16. The
+// result uses the same scale as the input components, so it can be
+// stored or converted alongside them (e.g. as an extra WAV channel).
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewMagnitudeFn(hint ...Capacity) MagnitudeFn {
+	buf := make([]int16, bufferLen(hint, 1, 4096))
+	return func(xi, xq []int16) []int16 {
+		minLen := len(xi)
+		if len(xq) < minLen {
+			minLen = len(xq)
+		}
+		if len(buf) < minLen {
+			next := len(buf) * 2
+			if next < minLen {
+				next = minLen
+			}
+			buf = make([]int16, next)
+		}
+		for i := 0; i < minLen; i++ {
+			re := float64(xi[i])
+			im := float64(xq[i])
+			buf[i] = SaturateInt16(int32(math.Sqrt(re*re+im*im) + 0.5))
+		}
+		return buf[:minLen]
+	}
+}
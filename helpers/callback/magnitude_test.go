@@ -0,0 +1,82 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMagnitude(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		xi, xq int16
+		want   int16
+	}{
+		{0, 0, 0},
+		{3, 4, 5},
+		{-3, 4, 5},
+		{-3, -4, 5},
+		{1, 1, 1},
+		{1000, 0, 1000},
+		{0, -1000, 1000},
+		{math.MaxInt16, 0, math.MaxInt16},
+		{math.MinInt16, 0, MaxSaturatedInt16},
+		{math.MinInt16, math.MinInt16, MaxSaturatedInt16},
+	}
+
+	xi := make([]int16, len(specs))
+	xq := make([]int16, len(specs))
+	for i, spec := range specs {
+		xi[i] = spec.xi
+		xq[i] = spec.xq
+	}
+	mag := NewMagnitudeFn()
+	got := mag(xi, xq)
+	if len(got) != len(specs) {
+		t.Fatalf("wrong length: got %d, want %d", len(got), len(specs))
+	}
+	for i, spec := range specs {
+		if got[i] != spec.want {
+			t.Errorf("wrong magnitude for (%d,%d): got %d, want %d", spec.xi, spec.xq, got[i], spec.want)
+		}
+	}
+
+	if got := mag(xi[:2], xq); len(got) != 2 {
+		t.Errorf("wrong length on unbalanced magnitude: got %d, want 2", len(got))
+	}
+}
+
+func TestMultiInterleaveMagnitude(t *testing.T) {
+	t.Parallel()
+
+	const scalarsPerFrame = 3
+	xi := []int16{3, -6, 0, 8}
+	xq := []int16{4, 8, -2, 15}
+	wantMag := []int16{5, 10, 2, 17}
+
+	mag := NewMagnitudeFn()
+	inter := NewMultiInterleaveFn()
+	x := inter(xi, xq, mag(xi, xq))
+	if len(x) != len(xi)*scalarsPerFrame {
+		t.Fatalf("wrong length: got %d, want %d", len(x), len(xi)*scalarsPerFrame)
+	}
+	for i := range xi {
+		frame := x[i*scalarsPerFrame : (i+1)*scalarsPerFrame]
+		if frame[0] != xi[i] || frame[1] != xq[i] || frame[2] != wantMag[i] {
+			t.Errorf("wrong frame %d: got %v, want [%d %d %d]", i, frame, xi[i], xq[i], wantMag[i])
+		}
+	}
+
+	// Mismatched lengths truncate to the shortest slice.
+	x = inter(xi, xq[:2], xi)
+	if len(x) != 2*scalarsPerFrame {
+		t.Errorf("wrong length on unbalanced interleave: got %d, want %d", len(x), 2*scalarsPerFrame)
+	}
+	if x = inter(); len(x) != 0 {
+		t.Errorf("wrong length on empty interleave: got %d, want 0", len(x))
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestPack12(t *testing.T) {
+	t.Parallel()
+
+	pack := NewPack12Fn()
+	unpack := NewUnpack12Fn()
+
+	for i := 0; i < 100; i++ {
+		// Include odd and small lengths.
+		samples := make([]int16, rand.Int31n(20000))
+		if i < 4 {
+			samples = samples[:i]
+		}
+		for j := range samples {
+			samples[j] = int16(rand.Intn(4096) - 2048)
+		}
+
+		packed := pack(samples)
+		if want := Packed12Len(len(samples)); len(packed) != want {
+			t.Fatalf("wrong packed length for %d samples: got %d, want %d", len(samples), len(packed), want)
+		}
+		if want := (len(samples)*3 + 1) / 2; len(packed) != want {
+			t.Fatalf("wrong packed size for %d samples: got %d, want %d", len(samples), len(packed), want)
+		}
+
+		got := unpack(packed)
+		if len(got) != len(samples) {
+			t.Fatalf("wrong number of samples from unpack: got %d, want %d", len(got), len(samples))
+		}
+		for j := range got {
+			if got[j] != samples[j] {
+				t.Fatalf("wrong sample %d after round-trip: got %d, want %d", j, got[j], samples[j])
+			}
+		}
+
+		// An incomplete trailing byte must be ignored.
+		if len(samples)%2 == 0 {
+			got = unpack(append(append([]byte(nil), packed...), 0xFF))
+			if len(got) != len(samples) {
+				t.Fatalf("wrong number of samples with trailing byte: got %d, want %d", len(got), len(samples))
+			}
+		}
+	}
+}
+
+func TestPack12Layout(t *testing.T) {
+	t.Parallel()
+
+	pack := NewPack12Fn()
+	specs := []struct {
+		x    []int16
+		want []byte
+	}{
+		{[]int16{0x123, 0x456}, []byte{0x23, 0x61, 0x45}},
+		{[]int16{-1, -2048}, []byte{0xFF, 0x0F, 0x80}},
+		{[]int16{0x7FF}, []byte{0xFF, 0x07}},
+		{[]int16{-1}, []byte{0xFF, 0x0F}},
+		// Out of range values saturate.
+		{[]int16{5000, -5000}, []byte{0xFF, 0x07, 0x80}},
+	}
+
+	for _, spec := range specs {
+		if got := pack(spec.x); !bytes.Equal(got, spec.want) {
+			t.Errorf("wrong packing for %v: got % x, want % x", spec.x, got, spec.want)
+		}
+	}
+}
+
+func BenchmarkPack12(b *testing.B) {
+	samples := make([]int16, 2048)
+	for i := range samples {
+		samples[i] = int16(rand.Intn(4096) - 2048)
+	}
+	pack := NewPack12Fn()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		pack(samples)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// ReadFn is a function type that returns a slice with the provided
+// packed bytes decoded into int16 sample scalars. It is the inverse of
+// WriteFn. Any trailing bytes that do not make up a complete scalar
+// are ignored.
+type ReadFn func(b []byte) []int16
+
+// NewReadFn creates a new ReadFn that decodes samples using the provided
+// ByteOrder. It decodes data written by a WriteFn created with the same
+// ByteOrder.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewReadFn(order binary.ByteOrder, hint ...Capacity) ReadFn {
+	const sizeOfScalar = 2
+	buf := make([]int16, bufferLen(hint, 2, 4096))
+	return func(b []byte) []int16 {
+		numScalars := len(b) / sizeOfScalar
+		if len(buf) < numScalars {
+			next := len(buf) * 2
+			if next < numScalars {
+				next = numScalars
+			}
+			buf = make([]int16, next)
+		}
+		switch order {
+		case binary.LittleEndian:
+			bi := 0
+			for i := 0; i < numScalars; i++ {
+				buf[i] = int16(binary.LittleEndian.Uint16(b[bi:]))
+				bi += sizeOfScalar
+			}
+		case binary.BigEndian:
+			bi := 0
+			for i := 0; i < numScalars; i++ {
+				buf[i] = int16(binary.BigEndian.Uint16(b[bi:]))
+				bi += sizeOfScalar
+			}
+		default:
+			bi := 0
+			for i := 0; i < numScalars; i++ {
+				buf[i] = int16(order.Uint16(b[bi:]))
+				bi += sizeOfScalar
+			}
+		}
+		return buf[:numScalars]
+	}
+}
+
+// Float32ReadFn is a function type that returns a slice with the provided
+// packed bytes decoded into float32 sample scalars. It is the inverse of
+// Float32WriteFn. Any trailing bytes that do not make up a complete scalar
+// are ignored.
+type Float32ReadFn func(b []byte) []float32
+
+// NewFloat32ReadFn creates a new Float32ReadFn that decodes samples using
+// the provided ByteOrder. It decodes data written by a Float32WriteFn
+// created with the same ByteOrder.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewFloat32ReadFn(order binary.ByteOrder, hint ...Capacity) Float32ReadFn {
+	const sizeOfScalar = 4
+	buf := make([]float32, bufferLen(hint, 2, 4096))
+	return func(b []byte) []float32 {
+		numScalars := len(b) / sizeOfScalar
+		if len(buf) < numScalars {
+			next := len(buf) * 2
+			if next < numScalars {
+				next = numScalars
+			}
+			buf = make([]float32, next)
+		}
+		switch order {
+		case binary.LittleEndian:
+			bi := 0
+			for i := 0; i < numScalars; i++ {
+				buf[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[bi:]))
+				bi += sizeOfScalar
+			}
+		case binary.BigEndian:
+			bi := 0
+			for i := 0; i < numScalars; i++ {
+				buf[i] = math.Float32frombits(binary.BigEndian.Uint32(b[bi:]))
+				bi += sizeOfScalar
+			}
+		default:
+			bi := 0
+			for i := 0; i < numScalars; i++ {
+				buf[i] = math.Float32frombits(order.Uint32(b[bi:]))
+				bi += sizeOfScalar
+			}
+		}
+		return buf[:numScalars]
+	}
+}
+
+// Int8ReadFn is a function type that returns a slice with the provided
+// bytes decoded into int8 sample scalars. It is the inverse of
+// Int8WriteFn.
+type Int8ReadFn func(b []byte) []int8
+
+// NewInt8ReadFn creates a new Int8ReadFn that decodes each byte as a
+// signed sample.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewInt8ReadFn(hint ...Capacity) Int8ReadFn {
+	buf := make([]int8, bufferLen(hint, 2, 4096))
+	return func(b []byte) []int8 {
+		if len(buf) < len(b) {
+			next := len(buf) * 2
+			if next < len(b) {
+				next = len(b)
+			}
+			buf = make([]int8, next)
+		}
+		for i := range b {
+			buf[i] = int8(b[i])
+		}
+		return buf[:len(b)]
+	}
+}
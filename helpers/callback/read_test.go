@@ -0,0 +1,124 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	testByteOrders(func(order binary.ByteOrder) {
+		write := NewWriteFn(order)
+		read := NewReadFn(order)
+
+		for i := 0; i < 100; i++ {
+			samples := make([]int16, rand.Int31n(100000))
+			for j := range samples {
+				samples[j] = int16(rand.Int())
+			}
+			buf := bytes.NewBuffer(nil)
+			if _, err := write(buf, samples); err != nil {
+				t.Fatal(err)
+			}
+			// Add a partial scalar that must be ignored.
+			buf.WriteByte(0xFF)
+
+			got := read(buf.Bytes())
+			if len(got) != len(samples) {
+				t.Fatalf("wrong number of samples from read: got %d, want %d", len(got), len(samples))
+			}
+			for j := range got {
+				if got[j] != samples[j] {
+					t.Fatalf("wrong sample %d after round-trip: got %d, want %d", j, got[j], samples[j])
+				}
+			}
+		}
+	})
+}
+
+func TestFloat32Read(t *testing.T) {
+	t.Parallel()
+
+	testByteOrders(func(order binary.ByteOrder) {
+		write := NewFloat32WriteFn(order)
+		read := NewFloat32ReadFn(order)
+
+		for i := 0; i < 100; i++ {
+			samples := make([]float32, rand.Int31n(100000))
+			for j := range samples {
+				samples[j] = rand.Float32()*2 - 1
+			}
+			buf := bytes.NewBuffer(nil)
+			if _, err := write(buf, samples); err != nil {
+				t.Fatal(err)
+			}
+			// Add a partial scalar that must be ignored.
+			buf.Write([]byte{0xFF, 0xFF, 0xFF})
+
+			got := read(buf.Bytes())
+			if len(got) != len(samples) {
+				t.Fatalf("wrong number of samples from read: got %d, want %d", len(got), len(samples))
+			}
+			for j := range got {
+				if got[j] != samples[j] {
+					t.Fatalf("wrong sample %d after round-trip: got %f, want %f", j, got[j], samples[j])
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkRead(b *testing.B) {
+	x := make([]byte, 8192)
+	read := NewReadFn(binary.LittleEndian)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		read(x)
+	}
+}
+
+func TestInt8Read(t *testing.T) {
+	t.Parallel()
+
+	convert := NewConvertToInt8Fn(16)
+	write := NewInt8WriteFn()
+	read := NewInt8ReadFn()
+
+	for i := 0; i < 100; i++ {
+		samples := make([]int16, rand.Int31n(100000))
+		for j := range samples {
+			samples[j] = int16(rand.Int())
+		}
+		want := convert(samples)
+		buf := bytes.NewBuffer(nil)
+		n, err := write(buf, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(samples) {
+			t.Fatalf("wrong number of bytes written: got %d, want %d", n, len(samples))
+		}
+
+		got := read(buf.Bytes())
+		if len(got) != len(want) {
+			t.Fatalf("wrong number of samples from read: got %d, want %d", len(got), len(want))
+		}
+		for j := range got {
+			if got[j] != want[j] {
+				t.Fatalf("wrong sample %d after round-trip: got %d, want %d", j, got[j], want[j])
+			}
+			// Scaling back up must be within half of an 8-bit step,
+			// except where the value was clamped.
+			if d := int32(samples[j]) - int32(got[j])<<8; got[j] != 127 && got[j] != -127 && (d > 128 || d < -128) {
+				t.Fatalf("wrong scaled value for %d: got %d", samples[j], got[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"fmt"
+	"math"
+)
+
+// ResampleFn is a function type that changes the sample rate of the
+// provided complex samples by the rational factor up/down. The xi slice
+// contains the real component and the xq slice contains the imaginary
+// component. If the lengths differ, the trailing samples of the longer
+// slice are discarded.
+//
+// A ResampleFn keeps its filter history and output phase between calls,
+// so the output is continuous across callbacks regardless of how the
+// input is split. Over any run of input samples whose length times up
+// is a multiple of down, it returns exactly len(xi)*up/down samples.
+type ResampleFn func(xi, xq []int16) (yi, yq []int16)
+
+// NewResampleFn creates a new ResampleFn that resamples by the factor
+// up/down. It is implemented as a polyphase FIR filter that is
+// equivalent to inserting up-1 zeros after every input sample, applying
+// a windowed-sinc low-pass filter, and keeping every down-th sample. The
+// filter is the same as the one used by NewDecimateFn, with a cutoff at
+// 80% of the lower of the input and output Nyquist frequencies. The
+// factors do not need to be reduced, but the filter is longer than
+// necessary if they share a common divisor. A factor of 1/1 returns the
+// input unfiltered.
+//
+// The function uses internal persistent buffers to minimize allocations.
+// The returned slices are slices of those internal buffers and should
+// not be modified or stored.
+func NewResampleFn(up, down int, hint ...Capacity) (ResampleFn, error) {
+	switch {
+	case up < 1:
+		return nil, fmt.Errorf("invalid interpolation factor: got %d, want >= 1", up)
+	case down < 1:
+		return nil, fmt.Errorf("invalid decimation factor: got %d, want >= 1", down)
+	}
+	if up == 1 && down == 1 {
+		return func(xi, xq []int16) ([]int16, []int16) {
+			minLen := len(xi)
+			if len(xq) < minLen {
+				minLen = len(xq)
+			}
+			return xi[:minLen], xq[:minLen]
+		}, nil
+	}
+
+	factor := up
+	if down > factor {
+		factor = down
+	}
+	numTaps := decimateTapsPerFactor*factor + 1
+	proto := lowPassTaps(numTaps, decimateCutoff*0.5/float64(factor))
+
+	// Split the prototype filter into up phases of tapsPerPhase taps.
+	// Each phase is reversed so that it can be applied as a dot product
+	// with the history buffer in increasing sample order. Zero stuffing
+	// reduces the gain by a factor of up, so it is restored here.
+	tapsPerPhase := (numTaps + up - 1) / up
+	phases := make([][]float32, up)
+	for p := range phases {
+		phases[p] = make([]float32, tapsPerPhase)
+		for k := 0; k < tapsPerPhase; k++ {
+			if i := p + k*up; i < numTaps {
+				phases[p][tapsPerPhase-1-k] = float32(proto[i] * float64(up))
+			}
+		}
+	}
+
+	var (
+		capacity = bufferLen(hint, 1, 4096)
+		numHist  = tapsPerPhase - 1
+		// hi and hq hold the last numHist input samples followed by
+		// the current input samples.
+		hi = make([]float32, numHist, numHist+capacity)
+		hq = make([]float32, numHist, numHist+capacity)
+		// pos is the index of the next output sample on the
+		// upsampled time axis relative to the first current input
+		// sample.
+		pos int
+		// Up to one more than capacity*up/down samples are output.
+		outI = make([]int16, capacity*up/down+1)
+		outQ = make([]int16, capacity*up/down+1)
+	)
+	return func(xi, xq []int16) ([]int16, []int16) {
+		minLen := len(xi)
+		if len(xq) < minLen {
+			minLen = len(xq)
+		}
+		for i := 0; i < minLen; i++ {
+			hi = append(hi, float32(xi[i]))
+			hq = append(hq, float32(xq[i]))
+		}
+
+		n := 0
+		for ; pos < minLen*up; pos += down {
+			if n == len(outI) {
+				next := make([]int16, len(outI)*2)
+				copy(next, outI)
+				outI = next
+				next = make([]int16, len(outQ)*2)
+				copy(next, outQ)
+				outQ = next
+			}
+			taps := phases[pos%up]
+			wi := hi[pos/up : pos/up+tapsPerPhase]
+			wq := hq[pos/up : pos/up+tapsPerPhase]
+			var accI, accQ float32
+			for j, tap := range taps {
+				accI += tap * wi[j]
+				accQ += tap * wq[j]
+			}
+			outI[n] = SaturateInt16(int32(math.Floor(float64(accI) + 0.5)))
+			outQ[n] = SaturateInt16(int32(math.Floor(float64(accQ) + 0.5)))
+			n++
+		}
+		pos -= minLen * up
+
+		// Retain only the history needed for the next call.
+		m := copy(hi, hi[len(hi)-numHist:])
+		hi = hi[:m]
+		m = copy(hq, hq[len(hq)-numHist:])
+		hq = hq[:m]
+		return outI[:n], outQ[:n]
+	}, nil
+}
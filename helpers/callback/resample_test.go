@@ -0,0 +1,108 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestResample(t *testing.T) {
+	t.Parallel()
+
+	const (
+		amp      = 10000
+		settle   = 64   // output samples to skip for filter transient
+		maxAlias = 0.01 // max alias amplitude relative to amp
+	)
+
+	specs := []struct {
+		name     string
+		up, down int
+		// toneIn is the tone frequency relative to the input rate.
+		toneIn float64
+		// aliasIn is an out-of-band frequency relative to the input
+		// rate, or zero for none.
+		aliasIn float64
+	}{
+		{"down 16/125", 16, 125, 0.01, 0.1},
+		{"down 1/8", 1, 8, 0.02, 0.2},
+		{"up 3/2", 3, 2, 0.1, 0},
+		{"up 4/1", 4, 1, 0.1, 0},
+	}
+
+	for _, spec := range specs {
+		spec := spec
+		t.Run(spec.name, func(t *testing.T) {
+			t.Parallel()
+
+			numIn := 2000 * spec.down
+			numOut := numIn * spec.up / spec.down
+			ratio := float64(spec.up) / float64(spec.down)
+
+			resample, err := NewResampleFn(spec.up, spec.down)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			xi := make([]int16, numIn)
+			xq := make([]int16, numIn)
+			for n := range xi {
+				p1 := 2 * math.Pi * spec.toneIn * float64(n)
+				p2 := 2 * math.Pi * spec.aliasIn * float64(n)
+				xi[n] = int16(math.Round(amp/2*math.Cos(p1) + amp/2*math.Cos(p2)))
+				xq[n] = int16(math.Round(amp/2*math.Sin(p1) + amp/2*math.Sin(p2)))
+			}
+
+			var yi, yq []int16
+			// Feed uneven chunks to exercise state carried across callbacks.
+			for start := 0; start < numIn; {
+				end := start + rand.Intn(5000) + 1
+				if end > numIn {
+					end = numIn
+				}
+				gi, gq := resample(xi[start:end], xq[start:end])
+				yi = append(yi, gi...)
+				yq = append(yq, gq...)
+				start = end
+			}
+			if len(yi) != numOut {
+				t.Fatalf("wrong output length: got %d, want %d", len(yi), numOut)
+			}
+
+			yi = yi[settle:]
+			yq = yq[settle:]
+			if got := toneAmplitude(yi, yq, spec.toneIn/ratio); math.Abs(got-amp/2) > amp/2*0.01 {
+				t.Errorf("wrong tone amplitude: got %.1f, want %.1f", got, float64(amp/2))
+			}
+			if spec.aliasIn != 0 {
+				aliased := math.Mod(spec.aliasIn/ratio, 1)
+				if got := toneAmplitude(yi, yq, aliased); got > amp/2*maxAlias {
+					t.Errorf("alias not rejected: got %.1f, want < %.1f", got, amp/2*maxAlias)
+				}
+			}
+		})
+	}
+}
+
+func TestResampleInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, f := range [][2]int{{0, 1}, {1, 0}, {-1, 3}} {
+		if _, err := NewResampleFn(f[0], f[1]); err == nil {
+			t.Errorf("unexpected success for up=%d down=%d", f[0], f[1])
+		}
+	}
+
+	resample, err := NewResampleFn(1, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	yi, yq := resample([]int16{1, 2, 3}, []int16{4, 5})
+	if len(yi) != 2 || len(yq) != 2 || yi[1] != 2 || yq[1] != 5 {
+		t.Errorf("wrong pass-through output: got %v %v", yi, yq)
+	}
+}
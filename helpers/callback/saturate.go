@@ -0,0 +1,64 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+const (
+	// MaxSaturatedInt16 is the largest value produced by SaturateInt16.
+	MaxSaturatedInt16 = 32767
+	// MinSaturatedInt16 is the smallest value produced by SaturateInt16.
+	// The range is symmetric so that negating a saturated value never
+	// overflows.
+	MinSaturatedInt16 = -32767
+)
+
+// SaturateInt16 converts x to an int16 by clamping it to the symmetric
+// range [MinSaturatedInt16, MaxSaturatedInt16]. All converters in this
+// package that produce int16 output with saturation enabled use this
+// function, so they all clip identically.
+func SaturateInt16(x int32) int16 {
+	switch {
+	case x > MaxSaturatedInt16:
+		return MaxSaturatedInt16
+	case x < MinSaturatedInt16:
+		return MinSaturatedInt16
+	default:
+		return int16(x)
+	}
+}
+
+// saturateFloat32 clamps x to the same range as SaturateInt16 before
+// converting. The clamp must happen in the floating-point domain
+// because converting an out-of-range float to an integer type is
+// implementation-specific in Go.
+func saturateFloat32(x float32) int16 {
+	switch {
+	case x > MaxSaturatedInt16:
+		return MaxSaturatedInt16
+	case x < MinSaturatedInt16:
+		return MinSaturatedInt16
+	default:
+		return int16(x)
+	}
+}
+
+// wrapFloat32 converts x to an int16 by discarding all but the low
+// 16 bits of the integer value. Values beyond the int32 range have
+// undefined results.
+func wrapFloat32(x float32) int16 {
+	return int16(int32(x))
+}
+
+// saturateInt8 converts x to an int8 by clamping it to the symmetric
+// range [-127,127].
+func saturateInt8(x int32) int8 {
+	switch {
+	case x > 127:
+		return 127
+	case x < -127:
+		return -127
+	default:
+		return int8(x)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// TeeSink is a single destination of a TeeFn. The Write function is
+// called with Out as its io.Writer argument. Any function with the same
+// signature as WriteFn can be used, including a udp.PacketWriteFn, so a
+// stateful packetizing writer keeps its own packet buffer and sequence
+// number regardless of the other sinks.
+type TeeSink struct {
+	Out   io.Writer
+	Write func(out io.Writer, x []int16) (int, error)
+}
+
+// TeeFn is a function type that writes the provided samples to
+// multiple sinks. It returns a non-nil error if writing to any sink
+// fails.
+type TeeFn func(x []int16) error
+
+// NewTeeFn creates a new TeeFn that writes the same samples to each of
+// the provided sinks in order. A naive io.MultiWriter cannot be used to
+// duplicate a stream to sinks with different framing, such as a WAV
+// file and a packetized UDP stream, because the framing is applied by
+// the sample-level write function, not the io.Writer. A TeeFn instead
+// duplicates the stream at the []int16 level, before any encoding or
+// framing.
+//
+// A failure in one sink does not prevent the samples from being written
+// to the remaining sinks. The returned error describes the first failure
+// and identifies the index of the failed sink. It returns an error if no
+// sinks are provided or any sink has a nil Out or Write.
+func NewTeeFn(sinks ...TeeSink) (TeeFn, error) {
+	if len(sinks) == 0 {
+		return nil, errors.New("no tee sinks provided")
+	}
+	for i, sink := range sinks {
+		if sink.Out == nil || sink.Write == nil {
+			return nil, fmt.Errorf("invalid tee sink %d: missing Out or Write", i)
+		}
+	}
+	sinks = append([]TeeSink(nil), sinks...)
+	return func(x []int16) error {
+		var first error
+		for i, sink := range sinks {
+			if _, err := sink.Write(sink.Out, x); err != nil && first == nil {
+				first = fmt.Errorf("tee sink %d failed: %v", i, err)
+			}
+		}
+		return first
+	}, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package callback
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/helpers/udp"
+	"github.com/msiner/sdrplay-go/helpers/wav"
+)
+
+// packetSink is an io.Writer that stores a copy of each write as a
+// separate packet.
+type packetSink struct {
+	packets [][]byte
+}
+
+func (s *packetSink) Write(b []byte) (int, error) {
+	s.packets = append(s.packets, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func TestTee(t *testing.T) {
+	t.Parallel()
+
+	const (
+		payloadLen = 1032
+		numPackets = 20
+		numScalars = (payloadLen - 8) / 2 * numPackets
+	)
+	order := binary.LittleEndian
+
+	head, err := wav.NewHeader(2e6, 2, 2, wav.LPCM, order, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wavOut := bytes.NewBuffer(nil)
+	if err := binary.Write(wavOut, order, head); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	headSize := wavOut.Len()
+
+	packetWrite, err := udp.NewPacketWriteFn(payloadLen, 2, true, order)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	udpOut := &packetSink{}
+
+	tee, err := NewTeeFn(
+		TeeSink{Out: wavOut, Write: NewWriteFn(order)},
+		TeeSink{Out: udpOut, Write: packetWrite},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	samples := make([]int16, numScalars)
+	for i := range samples {
+		samples[i] = int16(rand.Int())
+	}
+	// Feed uneven, even-length chunks like interleaved callbacks.
+	for start := 0; start < len(samples); {
+		end := start + 2*(rand.Intn(700)+1)
+		if end > len(samples) {
+			end = len(samples)
+		}
+		if err := tee(samples[start:end]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		start = end
+	}
+
+	read := NewReadFn(order)
+	check := func(name string, got []int16, offset int) {
+		for i := range got {
+			if got[i] != samples[offset+i] {
+				t.Fatalf("wrong %s sample %d: got %d, want %d", name, offset+i, got[i], samples[offset+i])
+			}
+		}
+	}
+
+	gotWav := read(wavOut.Bytes()[headSize:])
+	if len(gotWav) != len(samples) {
+		t.Fatalf("wrong number of WAV samples: got %d, want %d", len(gotWav), len(samples))
+	}
+	check("WAV", gotWav, 0)
+
+	if len(udpOut.packets) != numPackets {
+		t.Fatalf("wrong number of UDP packets: got %d, want %d", len(udpOut.packets), numPackets)
+	}
+	offset := 0
+	for i, pkt := range udpOut.packets {
+		if seq := order.Uint64(pkt); seq != uint64(i) {
+			t.Errorf("wrong sequence number: got %d, want %d", seq, i)
+		}
+		got := read(pkt[8:])
+		check("UDP", got, offset)
+		offset += len(got)
+	}
+	if offset != len(samples) {
+		t.Errorf("wrong number of UDP samples: got %d, want %d", offset, len(samples))
+	}
+}
+
+// failWriter is an io.Writer that always fails.
+type failWriter struct{}
+
+func (failWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("boom")
+}
+
+func TestTeeError(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewTeeFn(); err == nil {
+		t.Error("unexpected success with no sinks")
+	}
+	if _, err := NewTeeFn(TeeSink{Write: NewWriteFn(binary.LittleEndian)}); err == nil {
+		t.Error("unexpected success with nil Out")
+	}
+	if _, err := NewTeeFn(TeeSink{Out: ioutil.Discard}); err == nil {
+		t.Error("unexpected success with nil Write")
+	}
+
+	out := bytes.NewBuffer(nil)
+	tee, err := NewTeeFn(
+		TeeSink{Out: failWriter{}, Write: NewWriteFn(binary.LittleEndian)},
+		TeeSink{Out: out, Write: NewWriteFn(binary.LittleEndian)},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tee([]int16{1, 2, 3}); err == nil {
+		t.Error("unexpected success with failing sink")
+	}
+	if out.Len() != 6 {
+		t.Errorf("wrong number of bytes in second sink: got %d, want 6", out.Len())
+	}
+}
@@ -19,9 +19,9 @@ type WriteFn func(out io.Writer, x []int16) (int, error)
 // ByteOrder. The function uses an internal persistent buffer to avoid
 // allocations. In comparison, calling encoding/binary on a slice will
 // allocate a new []byte on each call.
-func NewWriteFn(order binary.ByteOrder) WriteFn {
+func NewWriteFn(order binary.ByteOrder, hint ...Capacity) WriteFn {
 	const sizeOfScalar = 2
-	buf := make([]byte, 4096)
+	buf := make([]byte, bufferLen(hint, 2*sizeOfScalar, 4096))
 	return func(out io.Writer, x []int16) (int, error) {
 		numBytes := len(x) * sizeOfScalar
 		if len(buf) < numBytes {
@@ -64,9 +64,9 @@ type Float32WriteFn func(out io.Writer, x []float32) (int, error)
 // the provided ByteOrder. The function uses an internal persistent buffer
 // to avoid allocations. In comparison, calling encoding/binary on a slice
 // will allocate a new []byte on each call.
-func NewFloat32WriteFn(order binary.ByteOrder) Float32WriteFn {
+func NewFloat32WriteFn(order binary.ByteOrder, hint ...Capacity) Float32WriteFn {
 	const sizeOfScalar = 4
-	buf := make([]byte, 4096)
+	buf := make([]byte, bufferLen(hint, 2*sizeOfScalar, 4096))
 	return func(out io.Writer, x []float32) (int, error) {
 		numBytes := len(x) * sizeOfScalar
 		if len(buf) < numBytes {
@@ -109,13 +109,13 @@ type Complex64WriteFn func(out io.Writer, x []complex64) (int, error)
 // the provided ByteOrder. The function uses an internal persistent buffer
 // to avoid allocations. In comparison, calling encoding/binary on a slice
 // will allocate a new []byte on each call.
-func NewComplex64WriteFn(order binary.ByteOrder) Complex64WriteFn {
+func NewComplex64WriteFn(order binary.ByteOrder, hint ...Capacity) Complex64WriteFn {
 	const (
 		sizeOfScalar    = 4
 		scalarsPerFrame = 2
 		sizeOfFrame     = sizeOfScalar * scalarsPerFrame
 	)
-	buf := make([]byte, 4096)
+	buf := make([]byte, bufferLen(hint, sizeOfFrame, 4096))
 	return func(out io.Writer, x []complex64) (int, error) {
 		numBytes := len(x) * sizeOfFrame
 		if len(buf) < numBytes {
@@ -151,3 +151,28 @@ func NewComplex64WriteFn(order binary.ByteOrder) Complex64WriteFn {
 		return out.Write(buf[:numBytes])
 	}
 }
+
+// Int8WriteFn is a function type that writes the provided samples to the
+// specified io.Writer. It returns the number of bytes written and a non-nil
+// error if an error is encountered during write.
+type Int8WriteFn func(out io.Writer, x []int8) (int, error)
+
+// NewInt8WriteFn creates a new Int8WriteFn that writes each sample as a
+// single signed byte. Byte order does not apply to 8-bit samples. The
+// function uses an internal persistent buffer to avoid allocations.
+func NewInt8WriteFn(hint ...Capacity) Int8WriteFn {
+	buf := make([]byte, bufferLen(hint, 2, 4096))
+	return func(out io.Writer, x []int8) (int, error) {
+		if len(buf) < len(x) {
+			next := len(buf) * 2
+			if next < len(x) {
+				next = len(x)
+			}
+			buf = make([]byte, next)
+		}
+		for i := range x {
+			buf[i] = byte(x[i])
+		}
+		return out.Write(buf[:len(x)])
+	}
+}
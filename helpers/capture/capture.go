@@ -0,0 +1,387 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/helpers/callback"
+	"github.com/msiner/sdrplay-go/helpers/event"
+	"github.com/msiner/sdrplay-go/session"
+)
+
+// DefaultWarmUp is the warm-up period of a Builder, which matches the
+// default of the commands in this module.
+const DefaultWarmUp = 2 * time.Second
+
+// eventDepth is the depth of the event channel.
+const eventDepth = 10
+
+// Builder collects the configuration of a Capture. Its methods return
+// the Builder so that calls can be chained. Errors are reported by
+// Build.
+type Builder struct {
+	fns       []session.ConfigFn
+	out       io.Writer
+	write     func(out io.Writer, x []int16) (int, error)
+	warm      time.Duration
+	limit     uint64
+	lg        event.Logger
+	adjust    bool
+	interrupt bool
+	flush     []func() error
+	finalize  []func(Stats) error
+	release   []io.Closer
+}
+
+// NewBuilder creates a Builder for a Capture of a Session configured by
+// fns. The defaults are a warm-up of DefaultWarmUp, no size limit,
+// logging with the standard logger, automatic gain adjustment on power
+// overload, and stopping on os.Interrupt.
+func NewBuilder(fns ...session.ConfigFn) *Builder {
+	return &Builder{
+		fns:       fns,
+		warm:      DefaultWarmUp,
+		lg:        log.New(os.Stderr, "", log.LstdFlags),
+		adjust:    true,
+		interrupt: true,
+	}
+}
+
+// WithSink sets the function that writes the interleaved I/Q samples of
+// each stream A callback to out. Both callback.WriteFn and
+// udp.PacketWriteFn can be used. A sink is required.
+func (b *Builder) WithSink(out io.Writer, write func(out io.Writer, x []int16) (int, error)) *Builder {
+	b.out = out
+	b.write = write
+	return b
+}
+
+// WithWarmUp sets the time after the start of Run during which samples
+// are discarded, so that the output does not include the transients of
+// the device startup.
+func (b *Builder) WithWarmUp(warm time.Duration) *Builder {
+	b.warm = warm
+	return b
+}
+
+// WithLimit stops the capture once at least numBytes have been written
+// to the sink. The last callback is written completely, so the total
+// can be larger, but nothing is written after it. A limit of 0 captures
+// until Run is stopped otherwise.
+func (b *Builder) WithLimit(numBytes uint64) *Builder {
+	b.limit = numBytes
+	return b
+}
+
+// WithLogger sets the logger for status messages, dropped samples, and
+// events.
+func (b *Builder) WithLogger(lg event.Logger) *Builder {
+	b.lg = lg
+	return b
+}
+
+// WithOverloadAdjust sets whether the gain is adjusted when a power
+// overload is detected. See event.HandlePowerOverloadChange. Overloads
+// are always acknowledged.
+func (b *Builder) WithOverloadAdjust(adjust bool) *Builder {
+	b.adjust = adjust
+	return b
+}
+
+// WithInterrupt sets whether receiving os.Interrupt stops Run.
+func (b *Builder) WithInterrupt(en bool) *Builder {
+	b.interrupt = en
+	return b
+}
+
+// WithFlush adds a function that writes the data held by a stateful
+// writer between the sink and its destination, such as the partial
+// packet of a udp.PacketWriter or the buffer of a bufio.Writer. Flush
+// functions are called in the order added, after the Session stops.
+// See Capture.Run.
+func (b *Builder) WithFlush(fn func() error) *Builder {
+	b.flush = append(b.flush, fn)
+	return b
+}
+
+// WithFinalize adds a function that completes the output once all of it
+// has been flushed, such as updating the size in a WAV header. It is
+// passed the final Stats, so it can derive the size from Stats.Bytes.
+// Finalize functions are called in the order added, after all flush
+// functions. See Capture.Run.
+func (b *Builder) WithFinalize(fn func(Stats) error) *Builder {
+	b.finalize = append(b.finalize, fn)
+	return b
+}
+
+// WithRelease adds a resource, such as a file or a network connection,
+// that is closed at the end of Run. Resources are closed in the order
+// added, after the final Stats are logged. See Capture.Run.
+func (b *Builder) WithRelease(c io.Closer) *Builder {
+	b.release = append(b.release, c)
+	return b
+}
+
+// Build creates the Capture. It returns an error if no sink is set, the
+// warm-up is negative, or the Session configuration fails, including
+// when it already sets a stream A callback, an event callback, or a
+// control loop.
+func (b *Builder) Build() (*Capture, error) {
+	switch {
+	case b.out == nil || b.write == nil:
+		return nil, errors.New("missing sink")
+	case b.warm < 0:
+		return nil, fmt.Errorf("invalid warm-up: got %v, want >= 0", b.warm)
+	case b.lg == nil:
+		return nil, errors.New("missing logger")
+	}
+	for _, fn := range b.flush {
+		if fn == nil {
+			return nil, errors.New("missing flush function")
+		}
+	}
+	for _, fn := range b.finalize {
+		if fn == nil {
+			return nil, errors.New("missing finalize function")
+		}
+	}
+	for _, r := range b.release {
+		if r == nil {
+			return nil, errors.New("missing release")
+		}
+	}
+
+	c := &Capture{
+		out:         b.out,
+		write:       b.write,
+		warm:        b.warm,
+		limit:       b.limit,
+		lg:          b.lg,
+		adjust:      b.adjust,
+		interrupt:   b.interrupt,
+		flush:       append([]func() error{}, b.flush...),
+		finalize:    append([]func(Stats) error{}, b.finalize...),
+		release:     append([]io.Closer{}, b.release...),
+		interleave:  callback.NewInterleaveFn(),
+		detectDrops: callback.NewDropDetectFn(),
+		events:      event.NewChan(eventDepth),
+	}
+	fns := append([]session.ConfigFn{}, b.fns...)
+	fns = append(fns,
+		session.WithStreamACallback(c.stream),
+		session.WithEventCallback(c.events.Callback),
+		session.WithControlLoop(c.control),
+	)
+	sess, err := session.NewSession(fns...)
+	if err != nil {
+		return nil, err
+	}
+	c.sess = sess
+	return c, nil
+}
+
+// Stats are the totals of a Capture.
+type Stats struct {
+	// Bytes is the number of bytes written to the sink.
+	Bytes uint64
+	// Callbacks is the number of stream A callbacks after the warm-up.
+	Callbacks uint64
+	// Dropped is the number of samples detected as dropped after the
+	// warm-up.
+	Dropped uint64
+}
+
+// Capture is a Session with the callbacks and control loop of a
+// capture. Create one with a Builder.
+type Capture struct {
+	sess      *session.Session
+	out       io.Writer
+	write     func(out io.Writer, x []int16) (int, error)
+	warm      time.Duration
+	limit     uint64
+	lg        event.Logger
+	adjust    bool
+	interrupt bool
+	flush     []func() error
+	finalize  []func(Stats) error
+	release   []io.Closer
+
+	interleave  callback.InterleaveFn
+	detectDrops callback.DropDetectFn
+	events      *event.Chan
+
+	ran      uint32
+	isWarm   uint32
+	cancel   context.CancelFunc
+	stats    Stats
+	mu       sync.Mutex
+	writeErr error
+}
+
+// Session returns the Session, e.g. to inspect it or to call DryRun.
+func (c *Capture) Session() *session.Session {
+	return c.sess
+}
+
+// Stats returns the totals. It is safe to call while Run is running.
+func (c *Capture) Stats() Stats {
+	return Stats{
+		Bytes:     atomic.LoadUint64(&c.stats.Bytes),
+		Callbacks: atomic.LoadUint64(&c.stats.Callbacks),
+		Dropped:   atomic.LoadUint64(&c.stats.Dropped),
+	}
+}
+
+// Run runs the Session until ctx is canceled, the size limit is
+// reached, os.Interrupt is received (if enabled), or an error occurs.
+// It returns nil if the capture stopped for any of the first three
+// reasons. A Capture can only be run once.
+//
+// However the capture stops, Run then shuts down in a fixed sequence:
+//
+//  1. The Session stops, so there are no more callbacks.
+//  2. The flush functions are called (see Builder.WithFlush).
+//  3. The finalize functions are called (see Builder.WithFinalize).
+//  4. The final Stats are logged.
+//  5. The resources are closed (see Builder.WithRelease).
+//
+// Every step is taken even if an earlier one fails, so that as much of
+// the output as possible is kept. Failures are logged, and the first one
+// is returned if the capture itself did not fail.
+func (c *Capture) Run(ctx context.Context) error {
+	if !atomic.CompareAndSwapUint32(&c.ran, 0, 1) {
+		return errors.New("capture already run")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.cancel = cancel
+
+	if c.interrupt {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		defer signal.Stop(sig)
+		go func() {
+			select {
+			case v := <-sig:
+				c.lg.Printf("signal: got %v", v)
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	warm := time.AfterFunc(c.warm, func() {
+		c.lg.Printf("warm-up complete")
+		atomic.StoreUint32(&c.isWarm, 1)
+	})
+	defer warm.Stop()
+
+	err := c.sess.Run(ctx)
+	c.events.Close()
+	shutdownErr := c.shutdown()
+
+	c.mu.Lock()
+	writeErr := c.writeErr
+	c.mu.Unlock()
+	switch {
+	case writeErr != nil:
+		return fmt.Errorf("write failed: %v", writeErr)
+	case err != nil && err != context.Canceled:
+		return err
+	default:
+		return shutdownErr
+	}
+}
+
+// shutdown flushes, finalizes, logs the final Stats, and releases, in
+// that order, after the Session has stopped. It returns the first
+// error.
+func (c *Capture) shutdown() error {
+	var first error
+	fail := func(format string, err error) {
+		c.lg.Printf(format, err)
+		if first == nil {
+			first = fmt.Errorf(format, err)
+		}
+	}
+	for _, fn := range c.flush {
+		if err := fn(); err != nil {
+			fail("flush failed: %v", err)
+		}
+	}
+	stats := c.Stats()
+	for _, fn := range c.finalize {
+		if err := fn(stats); err != nil {
+			fail("finalize failed: %v", err)
+		}
+	}
+	c.lg.Printf("capture stats: bytes=%d callbacks=%d dropped=%d", stats.Bytes, stats.Callbacks, stats.Dropped)
+	for _, r := range c.release {
+		if err := r.Close(); err != nil {
+			fail("release failed: %v", err)
+		}
+	}
+	return first
+}
+
+// stream is the stream A callback.
+func (c *Capture) stream(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+	if atomic.LoadUint32(&c.isWarm) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Callbacks continue until the Session stops after cancel.
+	if c.writeErr != nil || c.limit != 0 && atomic.LoadUint64(&c.stats.Bytes) >= c.limit {
+		return
+	}
+
+	atomic.AddUint64(&c.stats.Callbacks, 1)
+	if d := c.detectDrops(params, reset); d != 0 {
+		atomic.AddUint64(&c.stats.Dropped, uint64(d))
+		c.lg.Printf("dropped %d samples", d)
+	}
+
+	n, err := c.write(c.out, c.interleave(xi, xq))
+	total := atomic.AddUint64(&c.stats.Bytes, uint64(n))
+	switch {
+	case err != nil:
+		c.writeErr = err
+		c.cancel()
+	case c.limit != 0 && total >= c.limit:
+		c.cancel()
+	}
+}
+
+// control is the control loop. It logs events and handles power
+// overload changes.
+func (c *Capture) control(ctx context.Context, d *api.DeviceT, a api.API) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case evt, ok := <-c.events.C:
+			if !ok {
+				return nil
+			}
+			event.LogMsg(evt, c.lg)
+			if err := event.HandlePowerOverloadChangeMsg(d, a, evt, c.lg, c.adjust); err != nil {
+				return fmt.Errorf("failed to handle power overload event: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+	"github.com/msiner/sdrplay-go/helpers/callback"
+	"github.com/msiner/sdrplay-go/session"
+)
+
+const testRate = 1e6
+
+var discard = log.New(ioutil.Discard, "", 0)
+
+func newTestSignal() *apitest.Signal {
+	return apitest.NewSignal(testRate, &api.DeviceT{HWVer: api.RSP1A_ID})
+}
+
+func TestCapture(t *testing.T) {
+	t.Parallel()
+
+	const limit = 1 << 16
+	sig := newTestSignal()
+	var buf bytes.Buffer
+	write := callback.NewWriteFn(binary.LittleEndian)
+	var c *Capture
+	overloaded := false
+	c, err := NewBuilder(session.WithImplementation(sig)).
+		WithSink(&buf, func(out io.Writer, x []int16) (int, error) {
+			if !overloaded {
+				// Inject an overload event while streaming.
+				overloaded = true
+				params := &api.EventParamsT{}
+				params.PowerOverloadParams.PowerOverloadChangeType = api.Overload_Detected
+				c.events.Callback(api.PowerOverloadChange, api.Tuner_A, params)
+			}
+			return write(out, x)
+		}).
+		WithWarmUp(50 * time.Millisecond).
+		WithLimit(limit).
+		WithLogger(discard).
+		WithInterrupt(false).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.Run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("limit not reached before timeout")
+	}
+
+	stats := c.Stats()
+	if stats.Bytes < limit {
+		t.Errorf("wrong number of bytes: got %d, want >= %d", stats.Bytes, limit)
+	}
+	if got := uint64(buf.Len()); got != stats.Bytes {
+		t.Errorf("wrong output length: got %d, want %d", got, stats.Bytes)
+	}
+	if want := stats.Bytes / (4 * apitest.SignalBlockSize); stats.Callbacks != want {
+		t.Errorf("wrong number of callbacks: got %d, want %d", stats.Callbacks, want)
+	}
+	if stats.Dropped != 0 {
+		t.Errorf("unexpected drops: got %d", stats.Dropped)
+	}
+
+	// The output is continuous, but the warm-up discarded an unknown
+	// number of samples.
+	x := make([]int16, buf.Len()/2)
+	if err := binary.Read(&buf, binary.LittleEndian, x); err != nil {
+		t.Fatal(err)
+	}
+	var first uint32
+	for ; first < apitest.PatternPeriod; first++ {
+		if i, q := apitest.Pattern(first); i == x[0] && q == x[1] {
+			break
+		}
+	}
+	for n := 0; n < len(x)/2; n++ {
+		wantI, wantQ := apitest.Pattern(first + uint32(n))
+		if x[2*n] != wantI || x[2*n+1] != wantQ {
+			t.Fatalf("wrong sample %d: got (%d,%d), want (%d,%d)", n, x[2*n], x[2*n+1], wantI, wantQ)
+		}
+	}
+
+	// The overload was acknowledged and the gain was reduced.
+	var ack, gain bool
+	for _, u := range sig.Updates {
+		ack = ack || u.Reason == api.Update_Ctrl_OverloadMsgAck
+		gain = gain || u.Reason == api.Update_Tuner_Gr
+	}
+	if !ack || !gain {
+		t.Errorf("wrong overload handling: got %+v, want ack and gain updates", sig.Updates)
+	}
+
+	if err := c.Run(ctx); err == nil {
+		t.Error("missing error for second run")
+	}
+}
+
+func TestCaptureWarmUp(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	c, err := NewBuilder(session.WithImplementation(newTestSignal())).
+		WithSink(&buf, callback.NewWriteFn(binary.LittleEndian)).
+		WithWarmUp(time.Hour).
+		WithLogger(discard).
+		WithInterrupt(false).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := c.Run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() != 0 || c.Stats().Callbacks != 0 {
+		t.Errorf("unexpected output during warm-up: got %d B", buf.Len())
+	}
+}
+
+func TestCaptureWriteError(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewBuilder(session.WithImplementation(newTestSignal())).
+		WithSink(ioutil.Discard, func(out io.Writer, x []int16) (int, error) {
+			return 0, errors.New("disk full")
+		}).
+		WithWarmUp(0).
+		WithLogger(discard).
+		WithInterrupt(false).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = c.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("wrong error: got %v, want write failure", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("capture not stopped by write error")
+	}
+}
+
+// stepLog records the order of the shutdown steps. Consecutive equal
+// steps are recorded once.
+type stepLog struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (l *stepLog) add(step string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n := len(l.steps); n == 0 || l.steps[n-1] != step {
+		l.steps = append(l.steps, step)
+	}
+}
+
+func (l *stepLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string{}, l.steps...)
+}
+
+// Printf implements event.Logger. It records the final stats.
+func (l *stepLog) Printf(format string, v ...interface{}) {
+	if strings.HasPrefix(format, "capture stats") {
+		l.add("stats")
+	}
+}
+
+// stepFn returns a function that records step and returns err.
+func (l *stepLog) stepFn(step string, err error) func() error {
+	return func() error {
+		l.add(step)
+		return err
+	}
+}
+
+// stepCloser is an io.Closer that records its step.
+type stepCloser struct {
+	log  *stepLog
+	step string
+}
+
+func (c stepCloser) Close() error {
+	c.log.add(c.step)
+	return nil
+}
+
+func TestCaptureShutdown(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		name    string
+		limit   uint64
+		timeout time.Duration
+		failErr error
+	}{
+		{"limit", 1 << 16, 10 * time.Second, nil},
+		{"cancel", 0, 200 * time.Millisecond, nil},
+		{"flush error", 1 << 16, 10 * time.Second, errors.New("network down")},
+	}
+	for _, spec := range specs {
+		steps := &stepLog{}
+		var c *Capture
+		var final Stats
+		c, err := NewBuilder(session.WithImplementation(newTestSignal())).
+			WithSink(ioutil.Discard, func(out io.Writer, x []int16) (int, error) {
+				steps.add("write")
+				return 2 * len(x), nil
+			}).
+			WithWarmUp(0).
+			WithLimit(spec.limit).
+			WithLogger(steps).
+			WithInterrupt(false).
+			WithFlush(steps.stepFn("flush udp", spec.failErr)).
+			WithFlush(steps.stepFn("flush bufio", nil)).
+			WithFinalize(func(s Stats) error {
+				steps.add("finalize wav")
+				final = s
+				return nil
+			}).
+			WithRelease(stepCloser{steps, "close conn"}).
+			WithRelease(stepCloser{steps, "close file"}).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), spec.timeout)
+		err = c.Run(ctx)
+		cancel()
+		switch {
+		case spec.failErr == nil && err != nil:
+			t.Errorf("%s: unexpected error: %v", spec.name, err)
+		case spec.failErr != nil && (err == nil || !strings.Contains(err.Error(), spec.failErr.Error())):
+			t.Errorf("%s: wrong error: got %v, want flush failure", spec.name, err)
+		}
+
+		// Every step is taken once, in order, after the last write, even
+		// if a flush fails.
+		want := []string{"write", "flush udp", "flush bufio", "finalize wav", "stats", "close conn", "close file"}
+		if got := steps.get(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: wrong shutdown steps: got %v, want %v", spec.name, got, want)
+		}
+		if got := c.Stats(); final != got || final.Bytes == 0 {
+			t.Errorf("%s: wrong final stats: got %+v, want %+v", spec.name, final, got)
+		}
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	t.Parallel()
+
+	sink := callback.NewWriteFn(binary.LittleEndian)
+	specs := []struct {
+		name string
+		b    *Builder
+	}{
+		{"no sink", NewBuilder()},
+		{"negative warm-up", NewBuilder().WithSink(ioutil.Discard, sink).WithWarmUp(-time.Second)},
+		{"nil logger", NewBuilder().WithSink(ioutil.Discard, sink).WithLogger(nil)},
+		{"stream callback", NewBuilder(
+			session.WithStreamACallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {}),
+		).WithSink(ioutil.Discard, sink)},
+		{"nil flush", NewBuilder().WithSink(ioutil.Discard, sink).WithFlush(nil)},
+		{"nil finalize", NewBuilder().WithSink(ioutil.Discard, sink).WithFinalize(nil)},
+		{"nil release", NewBuilder().WithSink(ioutil.Discard, sink).WithRelease(nil)},
+		{"control loop", NewBuilder(
+			session.WithControlLoop(func(ctx context.Context, d *api.DeviceT, a api.API) error { return nil }),
+		).WithSink(ioutil.Discard, sink)},
+	}
+	for _, spec := range specs {
+		if _, err := spec.b.Build(); err == nil {
+			t.Errorf("missing error for %s", spec.name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package capture wires the pieces that every capture tool needs around a
+session.Session: a warm-up period during which samples are discarded,
+drop detection and logging, event logging with power overload handling,
+an optional size limit, and stopping on an interrupt signal. The
+samples of stream A are interleaved and passed to a sink, such as a
+callback.WriteFn or a udp.PacketWriteFn.
+
+	c, err := capture.NewBuilder(
+		session.WithSelector(session.WithDuoModeSingle()),
+		session.WithDeviceConfig(
+			session.WithSingleChannelConfig(
+				session.WithZeroIF(2e6, 1),
+				session.WithTuneFreq(100e6),
+			),
+		),
+	).WithSink(out, callback.NewWriteFn(binary.LittleEndian)).Build()
+	if err != nil {
+		return err
+	}
+	err = c.Run(context.Background())
+
+The Session configuration must not include a stream A callback, an
+event callback, or a control loop, because the Capture provides them.
+
+Output that is held by stateful writers, such as a partial UDP packet,
+a bufio.Writer, or a WAV header that is written with a placeholder
+size, is completed by the flush and finalize functions of the Builder
+once the Session stops. The Capture then logs its final Stats and
+closes the released resources, always in that order.
+*/
+package capture
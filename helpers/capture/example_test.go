@@ -0,0 +1,97 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package capture_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+	"github.com/msiner/sdrplay-go/helpers/callback"
+	"github.com/msiner/sdrplay-go/helpers/capture"
+	"github.com/msiner/sdrplay-go/helpers/wav"
+	"github.com/msiner/sdrplay-go/session"
+)
+
+// This example is a minimal version of the rspwav command. It records
+// 1 MiB of 16-bit I/Q samples to a WAV file. Remove the test signal
+// implementation to record from an RSP device.
+func Example_rspwav() {
+	const (
+		fs       = 2e6
+		freq     = 100e6
+		numBytes = 1 << 20
+	)
+	order := binary.LittleEndian
+
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.wav")
+	fout, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out := bufio.NewWriter(fout)
+
+	// Write a header for 0 frames and fix it when the capture stops.
+	head, err := wav.NewHeader(fs, 2, 2, wav.LPCM, order, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := wav.WriteHeader(out, order, head, nil); err != nil {
+		log.Fatal(err)
+	}
+
+	c, err := capture.NewBuilder(
+		session.WithImplementation(apitest.NewSignal(fs, &api.DeviceT{HWVer: api.RSP1A_ID})),
+		session.WithSelector(session.WithDuoModeSingle()),
+		session.WithDeviceConfig(
+			session.WithSingleChannelConfig(
+				session.WithZeroIF(fs, 1),
+				session.WithTuneFreq(freq),
+			),
+		),
+	).
+		WithSink(out, callback.NewWriteFn(order)).
+		WithWarmUp(100 * time.Millisecond).
+		WithLimit(numBytes).
+		WithLogger(log.New(ioutil.Discard, "", 0)).
+		WithFlush(out.Flush).
+		WithFinalize(func(s capture.Stats) error {
+			numFrames := uint32(s.Bytes / 4)
+			fmt.Printf("Frames: %d\n", numFrames)
+			head.Update(numFrames)
+			if _, err := fout.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			_, err := wav.WriteHeader(fout, order, head, nil)
+			return err
+		}).
+		WithRelease(fout).
+		Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Dropped: %d\n", c.Stats().Dropped)
+
+	// Output:
+	// Frames: 263088
+	// Dropped: 0
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// ConstellationFeatures holds simple statistics of a block of symbols
+// that hint at the modulation, as computed by AnalyzeConstellation.
+//
+// The moments are the magnitudes of the mean of exp(j*m*phase) for m of
+// 2, 4, and 8. Raising a symbol to the power m maps all points of an
+// m-ary PSK constellation to the same phase, so the moment of order m
+// is close to 1 for that constellation and any constellation with m
+// dividing it, while it is close to 0 for the others and for noise. The
+// moments do not depend on the carrier phase, but a residual frequency
+// offset rotates the constellation during the block and reduces them.
+type ConstellationFeatures struct {
+	// Count is the number of symbols with a non-zero magnitude.
+	Count int
+	// MagMean is the mean magnitude of the symbols.
+	MagMean float64
+	// MagVariance is the variance of the magnitude divided by the square
+	// of MagMean. It is 0 for a PSK constellation without noise and
+	// (4-pi)/pi, about 0.27, for complex Gaussian noise.
+	MagVariance float64
+	// Moment2, Moment4, and Moment8 are the phase moments of order 2,
+	// 4, and 8.
+	Moment2, Moment4, Moment8 float64
+	// PhaseHist is the fraction of the symbols with a phase in each of
+	// equal width bins covering [-pi,pi).
+	PhaseHist []float64
+}
+
+// Modulation hint thresholds. See Hint.
+const (
+	hintMaxMagVariance = 0.1
+	hintMinMoment      = 0.5
+)
+
+// Hint returns a guess of the modulation from the features: "BPSK",
+// "QPSK", or "8PSK" if the magnitude is nearly constant and the moment
+// of the corresponding order is strong, or "unknown" otherwise, which
+// includes noise and amplitude modulations.
+func (f ConstellationFeatures) Hint() string {
+	switch {
+	case f.Count == 0, f.MagVariance > hintMaxMagVariance:
+		return "unknown"
+	case f.Moment2 >= hintMinMoment:
+		return "BPSK"
+	case f.Moment4 >= hintMinMoment:
+		return "QPSK"
+	case f.Moment8 >= hintMinMoment:
+		return "8PSK"
+	default:
+		return "unknown"
+	}
+}
+
+// AnalyzeConstellation computes the ConstellationFeatures of symbols,
+// which should be sampled once per symbol (e.g. decimated to the symbol
+// rate). The phase histogram has phaseBins bins. Symbols with a
+// magnitude of 0 have no phase and are ignored. If there are no other
+// symbols, the result only has the empty histogram.
+func AnalyzeConstellation(symbols []complex64, phaseBins int) ConstellationFeatures {
+	if phaseBins < 0 {
+		phaseBins = 0
+	}
+	res := ConstellationFeatures{PhaseHist: make([]float64, phaseBins)}
+
+	var (
+		sum, sumSq float64
+		m2, m4, m8 complex128
+	)
+	for _, v := range symbols {
+		z := complex128(v)
+		mag := cmplx.Abs(z)
+		if mag == 0 {
+			continue
+		}
+		res.Count++
+		sum += mag
+		sumSq += mag * mag
+
+		u := z / complex(mag, 0)
+		u2 := u * u
+		u4 := u2 * u2
+		m2 += u2
+		m4 += u4
+		m8 += u4 * u4
+
+		if phaseBins > 0 {
+			// Map [-pi,pi] to [0,phaseBins], folding pi onto -pi.
+			bin := int(float64(phaseBins) * (cmplx.Phase(z) + math.Pi) / (2 * math.Pi))
+			if bin >= phaseBins {
+				bin = 0
+			}
+			res.PhaseHist[bin]++
+		}
+	}
+	if res.Count == 0 {
+		return res
+	}
+
+	n := float64(res.Count)
+	res.MagMean = sum / n
+	res.MagVariance = math.Max(sumSq/n-res.MagMean*res.MagMean, 0) / (res.MagMean * res.MagMean)
+	res.Moment2 = cmplx.Abs(m2) / n
+	res.Moment4 = cmplx.Abs(m4) / n
+	res.Moment8 = cmplx.Abs(m8) / n
+	for i := range res.PhaseHist {
+		res.PhaseHist[i] /= n
+	}
+	return res
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestAnalyzeConstellation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		n     = 4096
+		bins  = 8
+		snrDB = 20
+	)
+	rng := rand.New(rand.NewSource(1))
+	noise := func(sigma float64) complex128 {
+		return complex(rng.NormFloat64()*sigma, rng.NormFloat64()*sigma)
+	}
+	// psk generates m-ary PSK symbols with a carrier phase offset and
+	// noise at snrDB.
+	psk := func(m int) []complex64 {
+		sigma := math.Sqrt(math.Pow(10, -snrDB/10.0) / 2)
+		x := make([]complex64, n)
+		for i := range x {
+			phase := 0.3 + 2*math.Pi*float64(rng.Intn(m))/float64(m)
+			x[i] = complex64(cmplx.Rect(0.5, phase) + noise(0.5*sigma))
+		}
+		return x
+	}
+
+	specs := []struct {
+		name    string
+		x       []complex64
+		want    string
+		psk     bool
+		moments [3]bool
+	}{
+		{"BPSK", psk(2), "BPSK", true, [3]bool{true, true, true}},
+		{"QPSK", psk(4), "QPSK", true, [3]bool{false, true, true}},
+		{"8PSK", psk(8), "8PSK", true, [3]bool{false, false, true}},
+		{"noise", func() []complex64 {
+			x := make([]complex64, n)
+			for i := range x {
+				x[i] = complex64(noise(0.1))
+			}
+			return x
+		}(), "unknown", false, [3]bool{}},
+	}
+	for _, spec := range specs {
+		f := AnalyzeConstellation(spec.x, bins)
+		if f.Count != n {
+			t.Errorf("wrong count for %s: got %d, want %d", spec.name, f.Count, n)
+		}
+		if got := f.Hint(); got != spec.want {
+			t.Errorf("wrong hint for %s: got %s, want %s (%+v)", spec.name, got, spec.want, f)
+		}
+		switch {
+		case spec.psk && f.MagVariance > 0.05:
+			t.Errorf("magnitude variance too large for %s: got %v, want < 0.05", spec.name, f.MagVariance)
+		case !spec.psk && math.Abs(f.MagVariance-(4-math.Pi)/math.Pi) > 0.05:
+			t.Errorf("wrong magnitude variance for %s: got %v, want about %v", spec.name, f.MagVariance, (4-math.Pi)/math.Pi)
+		}
+		for i, got := range []float64{f.Moment2, f.Moment4, f.Moment8} {
+			strong := got > 0.8
+			weak := got < 0.1
+			if spec.moments[i] && !strong || !spec.moments[i] && !weak {
+				t.Errorf("wrong moment %d for %s: got %v, want strong %v", 2<<uint(i), spec.name, got, spec.moments[i])
+			}
+		}
+
+		var total float64
+		for _, v := range f.PhaseHist {
+			total += v
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Errorf("wrong histogram total for %s: got %v, want 1", spec.name, total)
+		}
+	}
+
+	// The phases 0.3 and 0.3+pi of BPSK fall in bins 4 and 0 of 8.
+	f := AnalyzeConstellation(psk(2), bins)
+	if got := f.PhaseHist[0] + f.PhaseHist[4]; got < 0.99 {
+		t.Errorf("wrong BPSK histogram: got %v, want bins 0 and 4", f.PhaseHist)
+	}
+
+	f = AnalyzeConstellation([]complex64{0, 0}, bins)
+	if f.Count != 0 || f.Hint() != "unknown" || len(f.PhaseHist) != bins {
+		t.Errorf("wrong features for zero symbols: got %+v", f)
+	}
+}
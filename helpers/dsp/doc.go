@@ -0,0 +1,14 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package dsp provides small, dependency-free signal processing building
+blocks, such as window functions and a radix-2 FFT, for use by spectral
+tools operating on sample data. FindPeak builds on them to locate the
+strongest signal in a block of samples. AnalyzeConstellation computes
+simple features of a block of symbols that hint at the modulation.
+NewOverlapSaveFilter applies a long FIR filter, such as a channel
+filter from BandPass, to a complex stream using the FFT.
+*/
+package dsp
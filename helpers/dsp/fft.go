@@ -0,0 +1,51 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// FFT computes the forward discrete Fourier transform of x in place
+// using an iterative radix-2 algorithm. The result is in the usual order
+// with the DC bin first and the negative frequencies in the second half.
+// It is not normalized. It returns an error if the length of x is not a
+// power of two.
+func FFT(x []complex128) error {
+	n := len(x)
+	if n == 0 || n&(n-1) != 0 {
+		return fmt.Errorf("invalid FFT length: got %d, want power of two", n)
+	}
+	if n == 1 {
+		return nil
+	}
+
+	// Bit-reversal permutation.
+	shift := uint(64 - bits.TrailingZeros(uint(n)))
+	for i := range x {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	// Butterflies.
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := -2 * math.Pi / float64(size)
+		for k := 0; k < half; k++ {
+			sin, cos := math.Sincos(step * float64(k))
+			w := complex(cos, sin)
+			for i := k; i < n; i += size {
+				t := w * x[i+half]
+				x[i+half] = x[i] - t
+				x[i] += t
+			}
+		}
+	}
+	return nil
+}
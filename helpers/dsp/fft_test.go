@@ -0,0 +1,43 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestFFT(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{1, 2, 8, 64, 1024} {
+		x := make([]complex128, n)
+		for i := range x {
+			x[i] = complex(math.Sin(float64(i)*0.37)+0.1*float64(i%5), math.Cos(float64(i)*1.3))
+		}
+		// Compare with a direct evaluation of the DFT.
+		want := make([]complex128, n)
+		for k := range want {
+			for i, v := range x {
+				want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*i)/float64(n)))
+			}
+		}
+		if err := FFT(x); err != nil {
+			t.Fatalf("unexpected error for n=%d: %v", n, err)
+		}
+		for k := range x {
+			if cmplx.Abs(x[k]-want[k]) > 1e-9*float64(n) {
+				t.Fatalf("wrong bin %d for n=%d: got %v, want %v", k, n, x[k], want[k])
+			}
+		}
+	}
+
+	for _, n := range []int{0, 3, 100} {
+		if err := FFT(make([]complex128, n)); err == nil {
+			t.Errorf("unexpected success for n=%d", n)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"fmt"
+	"math"
+)
+
+// BandPass returns the numTaps complex coefficients of a linear-phase
+// FIR filter that passes the band from lowHz to highHz, which may be
+// negative, of a complex stream at sample rate fs. It is a windowed
+// sinc low-pass filter with a cutoff of half the bandwidth shifted to
+// the center of the band, so the gain is close to 1 in the band. The
+// transition width is inversely proportional to numTaps and depends on
+// the window, with kind Blackman having the deepest stopband. It
+// returns an error if numTaps is less than 1, kind is not a known
+// WindowKind, or the band is not within -fs/2 to fs/2.
+func BandPass(lowHz, highHz, fs float64, numTaps int, kind WindowKind) ([]complex64, error) {
+	switch {
+	case numTaps < 1:
+		return nil, fmt.Errorf("invalid number of taps: got %d, want >= 1", numTaps)
+	case math.IsNaN(fs) || fs <= 0:
+		return nil, fmt.Errorf("invalid sample rate: got %v Hz, want > 0", fs)
+	case !(lowHz >= -fs/2 && lowHz < highHz && highHz <= fs/2):
+		return nil, fmt.Errorf("invalid band: got %v Hz to %v Hz, want -fs/2 <= low < high <= fs/2", lowHz, highHz)
+	}
+	w := Window(kind, numTaps)
+	if w == nil {
+		return nil, fmt.Errorf("invalid window: got %v", kind)
+	}
+	bw := (highHz - lowHz) / fs
+	fc := (highHz + lowHz) / 2 / fs
+	mid := float64(numTaps-1) / 2
+	taps := make([]complex64, numTaps)
+	for i := range taps {
+		t := float64(i) - mid
+		v := bw
+		if t != 0 {
+			v = math.Sin(math.Pi*bw*t) / (math.Pi * t)
+		}
+		sin, cos := math.Sincos(2 * math.Pi * fc * t)
+		v *= float64(w[i])
+		taps[i] = complex(float32(v*cos), float32(v*sin))
+	}
+	return taps, nil
+}
+
+// OverlapSaveFilter applies a FIR filter to a complex stream in the
+// frequency domain using the overlap-save method. For a filter with
+// many taps, such as a sharp channel filter, it is far cheaper than
+// direct convolution, because each block of samples costs two FFTs
+// regardless of the number of taps.
+type OverlapSaveFilter struct {
+	numTaps int
+	fftSize int
+	// resp is the FFT of the zero-padded taps.
+	resp []complex128
+	// block holds the last numTaps-1 input samples followed by the
+	// new samples of the current block.
+	block []complex128
+	pos   int
+	work  []complex128
+	out   []complex64
+}
+
+// NewOverlapSaveFilter creates an OverlapSaveFilter for the FIR filter
+// with the coefficients response (i.e. its impulse response, such as
+// from BandPass) using FFTs of length fftSize. Each FFT produces
+// fftSize-len(response)+1 output samples, so an fftSize of at least
+// twice the number of taps is usually the most efficient. It returns an
+// error if response is empty or fftSize is not a power of two that is
+// at least len(response).
+func NewOverlapSaveFilter(response []complex64, fftSize int) (*OverlapSaveFilter, error) {
+	if len(response) == 0 {
+		return nil, fmt.Errorf("invalid response length: got 0, want >= 1")
+	}
+	if fftSize < len(response) || fftSize&(fftSize-1) != 0 {
+		return nil, fmt.Errorf("invalid FFT size: got %d, want power of two >= %d", fftSize, len(response))
+	}
+	resp := make([]complex128, fftSize)
+	for i, v := range response {
+		resp[i] = complex128(v)
+	}
+	if err := FFT(resp); err != nil {
+		return nil, err
+	}
+	f := &OverlapSaveFilter{
+		numTaps: len(response),
+		fftSize: fftSize,
+		resp:    resp,
+		block:   make([]complex128, fftSize),
+		work:    make([]complex128, fftSize),
+	}
+	f.Reset()
+	return f, nil
+}
+
+// Reset clears the filter state, as if no samples had been filtered
+// (e.g. after the reset flag of a stream callback).
+func (f *OverlapSaveFilter) Reset() {
+	for i := range f.block {
+		f.block[i] = 0
+	}
+	f.pos = f.numTaps - 1
+}
+
+// BlockSize returns the number of samples produced by each FFT.
+func (f *OverlapSaveFilter) BlockSize() int {
+	return f.fftSize - f.numTaps + 1
+}
+
+// Filter filters the samples in x and returns the output samples that
+// are complete. Samples are filtered in blocks of BlockSize, so up to
+// BlockSize-1 samples are held until a later call completes their
+// block. Over all calls, output sample n is the convolution of the
+// response with the input up to input sample n, with all samples before
+// the first (or before a Reset) taken as zero. For a linear-phase
+// response, such as from BandPass, the output is delayed by
+// (len(response)-1)/2 samples relative to the input.
+//
+// The returned slice is a slice of an internal buffer that is
+// overwritten by the next call and should not be modified or stored.
+func (f *OverlapSaveFilter) Filter(x []complex64) []complex64 {
+	overlap := f.numTaps - 1
+	step := f.BlockSize()
+	if want := (f.pos - overlap + len(x)) / step * step; cap(f.out) < want {
+		f.out = make([]complex64, 0, want)
+	}
+	f.out = f.out[:0]
+	for len(x) > 0 {
+		n := f.fftSize - f.pos
+		if len(x) < n {
+			n = len(x)
+		}
+		for i, v := range x[:n] {
+			f.block[f.pos+i] = complex128(v)
+		}
+		x = x[n:]
+		f.pos += n
+		if f.pos < f.fftSize {
+			break
+		}
+
+		// Circular convolution of the block, of which the last
+		// step samples are the same as a linear convolution.
+		copy(f.work, f.block)
+		_ = FFT(f.work)
+		for i, h := range f.resp {
+			// Conjugate for the inverse transform.
+			v := f.work[i] * h
+			f.work[i] = complex(real(v), -imag(v))
+		}
+		_ = FFT(f.work)
+		scale := 1 / float64(f.fftSize)
+		for _, v := range f.work[overlap:] {
+			f.out = append(f.out, complex64(complex(real(v)*scale, -imag(v)*scale)))
+		}
+
+		copy(f.block, f.block[step:])
+		f.pos = overlap
+	}
+	return f.out
+}
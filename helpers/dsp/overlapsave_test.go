@@ -0,0 +1,179 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestOverlapSaveFilter(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(1))
+	x := make([]complex64, 3000)
+	for i := range x {
+		x[i] = complex(float32(rng.NormFloat64()), float32(rng.NormFloat64()))
+	}
+
+	specs := []struct {
+		numTaps int
+		fftSize int
+	}{
+		{1, 1},
+		{1, 16},
+		{7, 8},
+		{31, 64},
+		{64, 256},
+	}
+	for _, s := range specs {
+		h := make([]complex64, s.numTaps)
+		for i := range h {
+			h[i] = complex(float32(rng.NormFloat64()), float32(rng.NormFloat64()))
+		}
+		// Compare with a direct linear convolution.
+		want := make([]complex128, len(x))
+		for n := range want {
+			for k, v := range h {
+				if n-k >= 0 {
+					want[n] += complex128(v) * complex128(x[n-k])
+				}
+			}
+		}
+
+		f, err := NewOverlapSaveFilter(h, s.fftSize)
+		if err != nil {
+			t.Fatalf("unexpected error for %d taps, size %d: %v", s.numTaps, s.fftSize, err)
+		}
+		if got, want := f.BlockSize(), s.fftSize-s.numTaps+1; got != want {
+			t.Errorf("wrong block size: got %d, want %d", got, want)
+		}
+		// Uneven chunks, including empty ones, exercise the bookkeeping
+		// across calls.
+		var got []complex64
+		for start, k := 0, 0; start < len(x); k++ {
+			end := start + (k*37)%101
+			if end > len(x) {
+				end = len(x)
+			}
+			got = append(got, f.Filter(x[start:end])...)
+			start = end
+		}
+		if n := len(x) / f.BlockSize() * f.BlockSize(); len(got) != n {
+			t.Fatalf("wrong output length for %d taps, size %d: got %d, want %d", s.numTaps, s.fftSize, len(got), n)
+		}
+		for n := range got {
+			if d := cmplx.Abs(complex128(got[n]) - want[n]); d > 1e-4*math.Sqrt(float64(s.numTaps)) {
+				t.Fatalf("wrong sample %d for %d taps, size %d: got %v, want %v", n, s.numTaps, s.fftSize, got[n], want[n])
+			}
+		}
+
+		// After a Reset, the output starts over.
+		f.Reset()
+		got = f.Filter(x[:f.BlockSize()])
+		for n := range got {
+			if d := cmplx.Abs(complex128(got[n]) - want[n]); d > 1e-4*math.Sqrt(float64(s.numTaps)) {
+				t.Fatalf("wrong sample %d after reset for %d taps, size %d: got %v, want %v", n, s.numTaps, s.fftSize, got[n], want[n])
+			}
+		}
+	}
+}
+
+func TestOverlapSaveFilterBandPass(t *testing.T) {
+	t.Parallel()
+
+	const (
+		fs      = 2e6
+		numTaps = 255
+		fftSize = 1024
+		low     = 100e3
+		high    = 300e3
+		num     = 16384
+	)
+	h, err := BandPass(low, high, fs, numTaps, Blackman)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs := []struct {
+		freq float64
+		pass bool
+	}{
+		{150e3, true},
+		{200e3, true},
+		{250e3, true},
+		{-200e3, false},
+		{0, false},
+		{-700e3, false},
+		{450e3, false},
+		{800e3, false},
+	}
+	for _, s := range specs {
+		f, err := NewOverlapSaveFilter(h, fftSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x := make([]complex64, num)
+		for i := range x {
+			sin, cos := math.Sincos(2 * math.Pi * s.freq * float64(i) / fs)
+			x[i] = complex(float32(cos), float32(sin))
+		}
+		var y []complex64
+		for start := 0; start < len(x); start += 1000 {
+			end := start + 1000
+			if end > len(x) {
+				end = len(x)
+			}
+			y = append(y, f.Filter(x[start:end])...)
+		}
+		// Skip the filter startup and measure the steady-state gain.
+		var power float64
+		for _, v := range y[numTaps:] {
+			a := cmplx.Abs(complex128(v))
+			power += a * a
+		}
+		gainDB := 10 * math.Log10(power/float64(len(y)-numTaps))
+		if s.pass && math.Abs(gainDB) > 0.1 {
+			t.Errorf("wrong gain at %v Hz: got %.3f dB, want 0 +/- 0.1 dB", s.freq, gainDB)
+		}
+		if !s.pass && gainDB > -70 {
+			t.Errorf("wrong gain at %v Hz: got %.1f dB, want <= -70 dB", s.freq, gainDB)
+		}
+	}
+}
+
+func TestOverlapSaveFilterInvalid(t *testing.T) {
+	t.Parallel()
+
+	h := make([]complex64, 10)
+	for _, n := range []int{0, 8, 12, 100} {
+		if _, err := NewOverlapSaveFilter(h, n); err == nil {
+			t.Errorf("unexpected success for FFT size %d", n)
+		}
+	}
+	if _, err := NewOverlapSaveFilter(nil, 16); err == nil {
+		t.Error("unexpected success for empty response")
+	}
+
+	specs := []struct {
+		low, high, fs float64
+		numTaps       int
+		kind          WindowKind
+	}{
+		{-1, 1, 10, 0, Hann},
+		{-1, 1, 0, 11, Hann},
+		{1, -1, 10, 11, Hann},
+		{-6, 1, 10, 11, Hann},
+		{-1, 6, 10, 11, Hann},
+		{-1, 1, 10, 11, WindowKind(99)},
+	}
+	for _, s := range specs {
+		if _, err := BandPass(s.low, s.high, s.fs, s.numTaps, s.kind); err == nil {
+			t.Errorf("unexpected success for %+v", s)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// FindPeak locates the strongest signal in samples, which are complex
+// baseband samples at sample rate fs, and returns its frequency offset
+// from the center in Hz and its power in dB relative to a complex
+// sinusoid with an amplitude of 1 (i.e. dBFS for samples scaled to
+// [-1,1], such as those from callback.NewConvertToComplex64Fn).
+//
+// The samples are windowed with a Hann window, zero-padded to a power
+// of two, and transformed with FFT. The offset and power are then
+// refined by fitting a parabola to the log magnitude of the strongest
+// bin and its two neighbors, which reduces the error to a small fraction
+// of a bin (fs divided by the padded length) and corrects most of the
+// scalloping loss of an off-bin signal. The result is only meaningful
+// for a signal that is narrow compared to a bin. It returns an offset of
+// 0 and a power of -Inf if samples is empty or has no energy.
+func FindPeak(samples []complex64, fs float64) (offsetHz, powerDB float64) {
+	return FindPeakInBand(samples, fs, -fs/2, fs/2)
+}
+
+// FindPeakInBand is like FindPeak, but it only considers signals with a
+// frequency offset from lowHz to highHz, inclusive. It returns an offset
+// of 0 and a power of -Inf if no bin is in the band.
+func FindPeakInBand(samples []complex64, fs, lowHz, highHz float64) (offsetHz, powerDB float64) {
+	if len(samples) == 0 || fs <= 0 {
+		return 0, math.Inf(-1)
+	}
+	n := 1
+	for n < len(samples) {
+		n <<= 1
+	}
+	if n < 4 {
+		n = 4
+	}
+
+	w := Window(Hann, len(samples))
+	if len(samples) < 3 {
+		// Hann windows of length 1 and 2 are all ones and all zeros.
+		w = Window(Rectangular, len(samples))
+	}
+	var gain float64
+	x := make([]complex128, n)
+	for i, v := range samples {
+		x[i] = complex128(v) * complex(float64(w[i]), 0)
+		gain += float64(w[i])
+	}
+	_ = FFT(x)
+
+	peak := -1
+	for k := range x {
+		f := float64(k)
+		if k >= n/2 {
+			f -= float64(n)
+		}
+		if f *= fs / float64(n); f < lowHz || f > highHz {
+			continue
+		}
+		if peak < 0 || cmplx.Abs(x[k]) > cmplx.Abs(x[peak]) {
+			peak = k
+		}
+	}
+	if peak < 0 {
+		return 0, math.Inf(-1)
+	}
+	mag := func(k int) float64 {
+		return 20 * math.Log10(cmplx.Abs(x[(k+n)%n])/gain)
+	}
+	b := mag(peak)
+	if math.IsInf(b, -1) {
+		return 0, b
+	}
+
+	// The neighbors wrap around because the spectrum is periodic.
+	a, c := mag(peak-1), mag(peak+1)
+	var delta float64
+	if den := a - 2*b + c; den < 0 && !math.IsInf(a, -1) && !math.IsInf(c, -1) {
+		delta = 0.5 * (a - c) / den
+		powerDB = b - 0.25*(a-c)*delta
+	} else {
+		powerDB = b
+	}
+
+	bin := float64(peak) + delta
+	if bin >= float64(n)/2 {
+		bin -= float64(n)
+	}
+	return bin * fs / float64(n), powerDB
+}
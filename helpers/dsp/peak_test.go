@@ -0,0 +1,97 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFindPeak(t *testing.T) {
+	t.Parallel()
+
+	const (
+		fs = 2e6
+		n  = 4096
+	)
+	// The interpolation must be accurate to a small fraction of a bin.
+	const binHz = fs / n
+	rng := rand.New(rand.NewSource(1))
+
+	specs := []struct {
+		freq float64
+		amp  float64
+	}{
+		{0, 1},
+		{100e3, 0.5},
+		{100e3 + binHz/2, 0.5},
+		{-123456.7, 0.25},
+		{-fs/2 + 10*binHz + 0.3*binHz, 0.1},
+		{876543.2, 0.01},
+	}
+	for _, spec := range specs {
+		x := make([]complex64, n)
+		for i := range x {
+			phase := 2 * math.Pi * spec.freq * float64(i) / fs
+			// A weaker interferer and some noise.
+			phase2 := 2 * math.Pi * (spec.freq + 50e3) * float64(i) / fs
+			x[i] = complex64(complex(
+				spec.amp*math.Cos(phase)+0.1*spec.amp*math.Cos(phase2)+0.001*rng.NormFloat64(),
+				spec.amp*math.Sin(phase)+0.1*spec.amp*math.Sin(phase2)+0.001*rng.NormFloat64(),
+			))
+		}
+		offset, power := FindPeak(x, fs)
+		if math.Abs(offset-spec.freq) > 0.05*binHz {
+			t.Errorf("wrong offset: got %v Hz, want %v Hz +/- %v Hz", offset, spec.freq, 0.05*binHz)
+		}
+		want := 20 * math.Log10(spec.amp)
+		if math.Abs(power-want) > 0.2 {
+			t.Errorf("wrong power at %v Hz: got %v dB, want %v dB +/- 0.2 dB", spec.freq, power, want)
+		}
+	}
+
+	// A length that is not a power of two is zero-padded.
+	x := make([]complex64, 3000)
+	for i := range x {
+		phase := 2 * math.Pi * 250e3 * float64(i) / fs
+		x[i] = complex64(complex(math.Cos(phase), math.Sin(phase)))
+	}
+	if offset, _ := FindPeak(x, fs); math.Abs(offset-250e3) > 0.05*fs/n {
+		t.Errorf("wrong padded offset: got %v Hz, want 250000 Hz", offset)
+	}
+
+	for _, x := range [][]complex64{nil, make([]complex64, 100)} {
+		if offset, power := FindPeak(x, fs); offset != 0 || !math.IsInf(power, -1) {
+			t.Errorf("wrong peak for %d zeros: got %v Hz %v dB, want 0 Hz -Inf dB", len(x), offset, power)
+		}
+	}
+}
+
+func TestFindPeakInBand(t *testing.T) {
+	t.Parallel()
+
+	const (
+		fs = 1e6
+		n  = 2048
+	)
+	// A strong tone outside of the band and a weaker one inside.
+	x := make([]complex64, n)
+	for i := range x {
+		p1 := 2 * math.Pi * 300e3 * float64(i) / fs
+		p2 := 2 * math.Pi * -40e3 * float64(i) / fs
+		x[i] = complex64(complex(0.9*math.Cos(p1)+0.1*math.Cos(p2), 0.9*math.Sin(p1)+0.1*math.Sin(p2)))
+	}
+	if offset, _ := FindPeak(x, fs); math.Abs(offset-300e3) > 0.05*fs/n {
+		t.Errorf("wrong offset: got %v Hz, want 300000 Hz", offset)
+	}
+	offset, power := FindPeakInBand(x, fs, -50e3, 50e3)
+	if math.Abs(offset+40e3) > 0.05*fs/n || math.Abs(power-20*math.Log10(0.1)) > 0.2 {
+		t.Errorf("wrong in-band peak: got %v Hz %v dB, want -40000 Hz -20 dB", offset, power)
+	}
+	if offset, power := FindPeakInBand(x, fs, 100, 200); offset != 0 || !math.IsInf(power, -1) {
+		t.Errorf("wrong peak for empty band: got %v Hz %v dB, want 0 Hz -Inf dB", offset, power)
+	}
+}
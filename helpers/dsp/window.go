@@ -0,0 +1,94 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"fmt"
+	"math"
+)
+
+// WindowKind is an enum type that identifies a window function.
+type WindowKind int
+
+const (
+	// Rectangular is a window of all ones (i.e. no window).
+	Rectangular WindowKind = iota
+	// Hann is the raised cosine window 0.5 - 0.5*cos(2*pi*i/(n-1)).
+	Hann
+	// Hamming is the raised cosine window 0.54 - 0.46*cos(2*pi*i/(n-1)).
+	Hamming
+	// Blackman is the window
+	// 0.42 - 0.5*cos(2*pi*i/(n-1)) + 0.08*cos(4*pi*i/(n-1)).
+	Blackman
+)
+
+// String implements fmt.Stringer.
+func (k WindowKind) String() string {
+	switch k {
+	case Rectangular:
+		return "Rectangular"
+	case Hann:
+		return "Hann"
+	case Hamming:
+		return "Hamming"
+	case Blackman:
+		return "Blackman"
+	default:
+		return fmt.Sprintf("WindowKind(%d)", int(k))
+	}
+}
+
+// cosineCoefs returns the coefficients a0, a1, and a2 of the
+// generalized cosine window
+// a0 - a1*cos(2*pi*i/(n-1)) + a2*cos(4*pi*i/(n-1)).
+func cosineCoefs(kind WindowKind) (a0, a1, a2 float64, ok bool) {
+	switch kind {
+	case Rectangular:
+		return 1, 0, 0, true
+	case Hann:
+		return 0.5, 0.5, 0, true
+	case Hamming:
+		return 0.54, 0.46, 0, true
+	case Blackman:
+		return 0.42, 0.5, 0.08, true
+	default:
+		return 0, 0, 0, false
+	}
+}
+
+// Window returns a new slice of length n containing the specified
+// window. The windows are symmetric, so the first and last values are
+// equal, as is appropriate for filter design. It returns nil if n is
+// less than 1 or kind is not a known WindowKind. A window of length 1
+// is a single value of 1.
+func Window(kind WindowKind, n int) []float32 {
+	a0, a1, a2, ok := cosineCoefs(kind)
+	if !ok || n < 1 {
+		return nil
+	}
+	w := make([]float32, n)
+	if n == 1 {
+		w[0] = 1
+		return w
+	}
+	den := float64(n - 1)
+	for i := range w {
+		x := 2 * math.Pi * float64(i) / den
+		w[i] = float32(a0 - a1*math.Cos(x) + a2*math.Cos(2*x))
+	}
+	return w
+}
+
+// ApplyWindow multiplies each value in x by the corresponding value
+// in w, in place. If the lengths differ, only the first
+// min(len(x), len(w)) values of x are modified.
+func ApplyWindow(x []float32, w []float32) {
+	if len(w) < len(x) {
+		x = x[:len(w)]
+	}
+	for i := range x {
+		x[i] *= w[i]
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWindow(t *testing.T) {
+	t.Parallel()
+
+	// The coherent gain of a symmetric cosine window of length n is
+	// (a0*n - a1 + a2) / n, because the cosine terms sum to 1 over
+	// one full period plus the repeated endpoint (for n > 3).
+	specs := []struct {
+		kind       WindowKind
+		a0, a1, a2 float64
+		end        float64
+	}{
+		{Rectangular, 1, 0, 0, 1},
+		{Hann, 0.5, 0.5, 0, 0},
+		{Hamming, 0.54, 0.46, 0, 0.08},
+		{Blackman, 0.42, 0.5, 0.08, 0},
+	}
+
+	for _, spec := range specs {
+		for _, n := range []int{7, 64, 1001} {
+			w := Window(spec.kind, n)
+			if len(w) != n {
+				t.Fatalf("wrong length for %v: got %d, want %d", spec.kind, len(w), n)
+			}
+			if math.Abs(float64(w[0])-spec.end) > 1e-6 || math.Abs(float64(w[n-1])-spec.end) > 1e-6 {
+				t.Errorf("wrong %v endpoints for n=%d: got %v,%v, want %v", spec.kind, n, w[0], w[n-1], spec.end)
+			}
+			for i := 0; i < n/2; i++ {
+				if math.Abs(float64(w[i]-w[n-1-i])) > 1e-6 {
+					t.Errorf("asymmetric %v window for n=%d at %d: got %v, want %v", spec.kind, n, i, w[i], w[n-1-i])
+					break
+				}
+			}
+			var sum float64
+			for _, v := range w {
+				sum += float64(v)
+			}
+			got := sum / float64(n)
+			want := (spec.a0*float64(n) - spec.a1 + spec.a2) / float64(n)
+			if math.Abs(got-want) > 1e-6 {
+				t.Errorf("wrong %v coherent gain for n=%d: got %v, want %v", spec.kind, n, got, want)
+			}
+		}
+		if odd := Window(spec.kind, 65); math.Abs(float64(odd[32])-(spec.a0+spec.a1+spec.a2)) > 1e-6 {
+			t.Errorf("wrong %v peak: got %v, want %v", spec.kind, odd[32], spec.a0+spec.a1+spec.a2)
+		}
+	}
+}
+
+func TestWindowInvalid(t *testing.T) {
+	t.Parallel()
+
+	if w := Window(Hann, 0); w != nil {
+		t.Errorf("wrong window for n=0: got %v, want nil", w)
+	}
+	if w := Window(WindowKind(99), 8); w != nil {
+		t.Errorf("wrong window for invalid kind: got %v, want nil", w)
+	}
+	if w := Window(Blackman, 1); len(w) != 1 || w[0] != 1 {
+		t.Errorf("wrong window for n=1: got %v, want [1]", w)
+	}
+}
+
+func TestApplyWindow(t *testing.T) {
+	t.Parallel()
+
+	x := []float32{1, 2, 3, 4}
+	ApplyWindow(x, []float32{0.5, 0.5, 2})
+	want := []float32{0.5, 1, 6, 4}
+	for i := range want {
+		if x[i] != want[i] {
+			t.Errorf("wrong value at %d: got %v, want %v", i, x[i], want[i])
+		}
+	}
+}
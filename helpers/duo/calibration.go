@@ -0,0 +1,153 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package duo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Calibration is a fixed complex gain correction applied to the stream B
+// samples so that they match the amplitude and phase of stream A. It is
+// useful for diversity and direction-finding setups where the imbalance
+// between the two channels is measured once and must then be removed
+// from every capture.
+//
+// If stream B is measured to be g times the amplitude of stream A and
+// leads it by phi radians, the correction is AmpB=1/g and PhaseRadB=-phi.
+//
+// The correction is derived from the exported fields each time it is
+// applied, so a Calibration can also be decoded with json.Unmarshal or
+// modified in place. Note that the zero value has an AmpB of 0, which
+// mutes stream B. Use NewCalibration or ReadCalibration to get a
+// validated Calibration.
+type Calibration struct {
+	// AmpB is the amplitude factor applied to stream B.
+	AmpB float32 `json:"ampB"`
+	// PhaseRadB is the phase rotation in radians applied to stream B.
+	PhaseRadB float32 `json:"phaseRadB"`
+}
+
+// NewCalibration creates a new Calibration that scales stream B samples
+// by ampB and rotates them by phaseRadB radians. It returns an error if
+// ampB is not a positive finite number or phaseRadB is not finite.
+func NewCalibration(ampB, phaseRadB float32) (*Calibration, error) {
+	a, p := float64(ampB), float64(phaseRadB)
+	if math.IsNaN(a) || math.IsInf(a, 0) || a <= 0 {
+		return nil, fmt.Errorf("invalid amplitude: got %v, want > 0", ampB)
+	}
+	if math.IsNaN(p) || math.IsInf(p, 0) {
+		return nil, fmt.Errorf("invalid phase: got %v, want finite", phaseRadB)
+	}
+	return &Calibration{AmpB: ampB, PhaseRadB: phaseRadB}, nil
+}
+
+// gain returns the complex gain of the correction.
+func (c *Calibration) gain() complex64 {
+	a := float64(c.AmpB)
+	sin, cos := math.Sincos(float64(c.PhaseRadB))
+	return complex(float32(a*cos), float32(a*sin))
+}
+
+// Apply applies the correction to the provided stream B samples in
+// place.
+func (c *Calibration) Apply(xb []complex64) {
+	g := c.gain()
+	for i, v := range xb {
+		xb[i] = v * g
+	}
+}
+
+// Wrap returns a ComplexSynchroCbFn that applies the correction to the
+// stream B samples and then calls cb. The result can be passed to
+// NewComplexSynchro.
+func (c *Calibration) Wrap(cb ComplexSynchroCbFn) ComplexSynchroCbFn {
+	return func(xa, xb []complex64, reset bool) {
+		c.Apply(xb)
+		cb(xa, xb, reset)
+	}
+}
+
+// ReadCalibration reads a Calibration from r. The input is either a JSON
+// object with "ampB" and "phaseRadB" members or a CSV record with the
+// amplitude and the phase in radians. The CSV record may be preceded by
+// a header record.
+//
+//	{"ampB": 1.02, "phaseRadB": -0.35}
+//
+//	ampB,phaseRadB
+//	1.02,-0.35
+func ReadCalibration(r io.Reader) (*Calibration, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ampB, phaseRadB float32
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		var raw struct {
+			AmpB      *float32 `json:"ampB"`
+			PhaseRadB *float32 `json:"phaseRadB"`
+		}
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse calibration: %v", err)
+		}
+		if raw.AmpB == nil || raw.PhaseRadB == nil {
+			return nil, errors.New("missing calibration field: want ampB and phaseRadB")
+		}
+		ampB, phaseRadB = *raw.AmpB, *raw.PhaseRadB
+	} else {
+		cr := csv.NewReader(bytes.NewReader(b))
+		cr.FieldsPerRecord = 2
+		cr.TrimLeadingSpace = true
+		records, err := cr.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse calibration: %v", err)
+		}
+		if len(records) > 0 {
+			if _, err := strconv.ParseFloat(records[0][0], 32); err != nil {
+				// Skip the header.
+				records = records[1:]
+			}
+		}
+		if len(records) != 1 {
+			return nil, fmt.Errorf("invalid number of calibration records: got %d, want 1", len(records))
+		}
+		vals := make([]float32, 2)
+		for i, s := range records[0] {
+			v, err := strconv.ParseFloat(strings.TrimSpace(s), 32)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse calibration: %v", err)
+			}
+			vals[i] = float32(v)
+		}
+		ampB, phaseRadB = vals[0], vals[1]
+	}
+	return NewCalibration(ampB, phaseRadB)
+}
+
+// LoadCalibration reads a Calibration from the file at path. See
+// ReadCalibration for the supported formats.
+func LoadCalibration(path string) (*Calibration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := ReadCalibration(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
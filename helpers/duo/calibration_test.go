@@ -0,0 +1,151 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package duo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCalibration(t *testing.T) {
+	t.Parallel()
+
+	const (
+		gain  = 0.8
+		phase = 0.6
+		n     = 1000
+	)
+	xa := make([]complex64, n)
+	xb := make([]complex64, n)
+	imbalance := cmplx.Rect(gain, phase)
+	for i := range xa {
+		v := cmplx.Rect(0.5, 2*math.Pi*0.013*float64(i))
+		xa[i] = complex64(v)
+		xb[i] = complex64(v * imbalance)
+	}
+
+	c, err := NewCalibration(1/gain, -phase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var calls int
+	cb := c.Wrap(func(ga, gb []complex64, reset bool) {
+		calls++
+		for i := range ga {
+			if d := cmplx.Abs(complex128(gb[i] - ga[i])); d > 1e-5 {
+				t.Fatalf("wrong sample %d: got %v, want %v", i, gb[i], ga[i])
+			}
+		}
+	})
+	cb(xa, xb, false)
+	if calls != 1 {
+		t.Errorf("wrong number of calls: got %d, want 1", calls)
+	}
+
+	for _, spec := range [][2]float32{{0, 0}, {-1, 0}, {float32(math.NaN()), 0}, {1, float32(math.Inf(1))}} {
+		if _, err := NewCalibration(spec[0], spec[1]); err == nil {
+			t.Errorf("unexpected success for %v", spec)
+		}
+	}
+}
+
+func TestCalibrationJSON(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCalibration(1.25, -0.35)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got Calibration
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != *c {
+		t.Errorf("wrong decoded calibration: got %+v, want %+v", got, *c)
+	}
+
+	// The decoded Calibration applies the same correction.
+	want := []complex64{1, 1i, complex(0.3, -0.7)}
+	x := append([]complex64{}, want...)
+	c.Apply(want)
+	got.Apply(x)
+	for i := range x {
+		if d := cmplx.Abs(complex128(x[i] - want[i])); d > 1e-6 || x[i] == 0 {
+			t.Errorf("wrong sample %d: got %v, want %v", i, x[i], want[i])
+		}
+	}
+}
+
+func TestReadCalibration(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		in    string
+		amp   float32
+		phase float32
+		ok    bool
+	}{
+		{`{"ampB": 1.25, "phaseRadB": -0.5}`, 1.25, -0.5, true},
+		{"  {\"phaseRadB\": 0.1, \"ampB\": 2}\n", 2, 0.1, true},
+		{"1.25,-0.5\n", 1.25, -0.5, true},
+		{"ampB,phaseRadB\n1.25, -0.5\n", 1.25, -0.5, true},
+		{`{"ampB": 1.25}`, 0, 0, false},
+		{`{"ampB": 0, "phaseRadB": 0}`, 0, 0, false},
+		{"1.25\n", 0, 0, false},
+		{"1,2\n3,4\n", 0, 0, false},
+		{"ampB,phaseRadB\n", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, spec := range specs {
+		c, err := ReadCalibration(strings.NewReader(spec.in))
+		switch {
+		case !spec.ok && err == nil:
+			t.Errorf("unexpected success for %q", spec.in)
+		case spec.ok && err != nil:
+			t.Errorf("unexpected error for %q: %v", spec.in, err)
+		case spec.ok && (c.AmpB != spec.amp || c.PhaseRadB != spec.phase):
+			t.Errorf("wrong calibration for %q: got %v/%v, want %v/%v", spec.in, c.AmpB, c.PhaseRadB, spec.amp, spec.phase)
+		}
+	}
+}
+
+func TestLoadCalibration(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "duocal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cal.json")
+	if err := ioutil.WriteFile(path, []byte(`{"ampB": 0.5, "phaseRadB": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadCalibration(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	xb := []complex64{1}
+	c.Apply(xb)
+	want := cmplx.Rect(0.5, 1)
+	if cmplx.Abs(complex128(xb[0])-want) > 1e-6 {
+		t.Errorf("wrong corrected sample: got %v, want %v", xb[0], want)
+	}
+
+	if _, err := LoadCalibration(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("unexpected success for missing file")
+	}
+}
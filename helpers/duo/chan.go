@@ -7,6 +7,7 @@ package duo
 import (
 	"errors"
 	"fmt"
+	"sync"
 )
 
 // SynchroMsg is a type for storing or transferring the parameters
@@ -31,7 +32,8 @@ type SynchroMsg struct {
 type SynchroChan struct {
 	C      <-chan SynchroMsg
 	c      chan<- SynchroMsg
-	done   chan struct{}
+	mu     sync.Mutex
+	closed bool
 	msgNum uint64
 }
 
@@ -45,37 +47,48 @@ type SynchroChan struct {
 func NewSynchroChan(depth uint) *SynchroChan {
 	cbChan := make(chan SynchroMsg, depth)
 	return &SynchroChan{
-		C:    cbChan,
-		c:    cbChan,
-		done: make(chan struct{}, 1),
+		C: cbChan,
+		c: cbChan,
 	}
 }
 
-// Close implements io.Closer. It will stop any more messages from being sent
-// on the C chan. However, the C chan will not be closed until the next
-// call to Callback.
+// Close implements io.Closer. It stops the production of messages and
+// closes the C chan. Any messages already buffered in the C chan are
+// not discarded. The receiver can continue to receive them and will
+// only see the C chan as closed after it has received all of them.
+// That is, Close means "stop producing" and the receiver decides when
+// to stop consuming. A receiver that ranges over the C chan will drain
+// all remaining messages and then exit its loop.
+//
+// Close waits for any concurrent call to Callback to return, so it is
+// safe to call from any goroutine.
 func (s *SynchroChan) Close() error {
-	select {
-	case <-s.done:
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
 		return errors.New("already closed")
-	default:
-		close(s.done)
-		return nil
 	}
+	s.closed = true
+	close(s.c)
+	return nil
+}
+
+// Len returns the number of messages buffered in the C chan that have
+// not yet been received. A value that grows toward the depth provided
+// to NewSynchroChan indicates a receiver that is not keeping up and
+// messages will soon be dropped. It is safe to call from any goroutine.
+func (s *SynchroChan) Len() int {
+	return len(s.c)
 }
 
 // Callback is a bound implementation of api.SynchroCbFn. It can be
 // passed to the API as the stream callback or used directly. Valid calls
 // to call back will generate a message on the C chan.
 func (s *SynchroChan) Callback(xia, xqa, xib, xqb []int16, reset bool) {
-	select {
-	case <-s.done:
-		if s.c != nil {
-			close(s.c)
-			s.c = nil
-		}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
 		return
-	default:
 	}
 
 	numSamples := len(xia)
@@ -108,3 +121,38 @@ func (s *SynchroChan) Callback(xia, xqa, xib, xqb []int16, reset bool) {
 		// Receiver not ready or channel full; drop the payload.
 	}
 }
+
+// SynchroGapFn is a function type that is used by the receiver of
+// SynchroChan messages to detect messages dropped by the SynchroChan.
+// It returns the number of messages dropped immediately before msg.
+//
+// It is important to create a new SynchroGapFn for each SynchroChan.
+type SynchroGapFn func(msg *SynchroMsg) uint64
+
+// NewSynchroGapFn creates a new SynchroGapFn that detects gaps in the
+// MsgNum sequence of the received messages. The first message received
+// only establishes the sequence.
+//
+// If resetOnGap is true, the Reset field of the first message after a
+// gap is set to true. This allows stateful processing downstream of the
+// receiver (e.g. filters or accumulators) to clear its state on the same
+// signal used for a reset of the stream, instead of combining samples
+// from either side of the missing messages.
+func NewSynchroGapFn(resetOnGap bool) SynchroGapFn {
+	var (
+		valid bool
+		last  uint64
+	)
+	return func(msg *SynchroMsg) uint64 {
+		var gap uint64
+		if valid {
+			gap = msg.MsgNum - last - 1
+		}
+		valid = true
+		last = msg.MsgNum
+		if gap != 0 && resetOnGap {
+			msg.Reset = true
+		}
+		return gap
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package duo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSynchroChanDrain(t *testing.T) {
+	t.Parallel()
+
+	const (
+		numMsgs    = 10
+		numSamples = 100
+	)
+	x := make([]int16, numSamples)
+
+	sc := NewSynchroChan(numMsgs)
+	for i := 0; i < numMsgs; i++ {
+		x[0] = int16(i)
+		sc.Callback(x, x, x, x, false)
+	}
+
+	if err := sc.Close(); err != nil {
+		t.Fatalf("unexpected Close failure: %v", err)
+	}
+
+	// Callbacks after Close must not produce messages or panic.
+	sc.Callback(x, x, x, x, false)
+
+	var got int
+	for msg := range sc.C {
+		if msg.MsgNum != uint64(got) {
+			t.Errorf("wrong MsgNum: got %d, want %d", msg.MsgNum, got)
+		}
+		if msg.Xia[0] != int16(got) {
+			t.Errorf("wrong payload: got %d, want %d", msg.Xia[0], got)
+		}
+		got++
+	}
+	if got != numMsgs {
+		t.Errorf("wrong number of drained messages: got %d, want %d", got, numMsgs)
+	}
+
+	err := sc.Close()
+	if err == nil {
+		t.Fatal("unexpected double Close success")
+	}
+	if !strings.Contains(err.Error(), "already closed") {
+		t.Fatalf("wrong error message: got '%s', want 'already closed'", err.Error())
+	}
+}
+
+func TestSynchroChanConcurrentClose(t *testing.T) {
+	t.Parallel()
+
+	x := make([]int16, 10)
+	sc := NewSynchroChan(4)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10000; i++ {
+			sc.Callback(x, x, x, x, false)
+		}
+	}()
+	go func() {
+		for range sc.C {
+		}
+	}()
+	if err := sc.Close(); err != nil {
+		t.Fatalf("unexpected Close failure: %v", err)
+	}
+	<-done
+}
+
+func TestSynchroChanLen(t *testing.T) {
+	t.Parallel()
+
+	const depth = 3
+	x := make([]int16, 10)
+	sc := NewSynchroChan(depth)
+	defer sc.Close()
+
+	for i := 1; i <= depth+1; i++ {
+		sc.Callback(x, x, x, x, false)
+		want := i
+		if want > depth {
+			want = depth
+		}
+		if got := sc.Len(); got != want {
+			t.Fatalf("wrong length after %d callbacks: got %d, want %d", i, got, want)
+		}
+	}
+	for i := depth - 1; i >= 0; i-- {
+		<-sc.C
+		if got := sc.Len(); got != i {
+			t.Fatalf("wrong length after receive: got %d, want %d", got, i)
+		}
+	}
+}
+
+func TestSynchroGapFn(t *testing.T) {
+	t.Parallel()
+
+	x := make([]int16, 10)
+	for _, resetOnGap := range []bool{false, true} {
+		// A depth of 2 drops the third message while the receiver is
+		// not receiving.
+		sc := NewSynchroChan(2)
+		sc.Callback(x, x, x, x, true)
+		sc.Callback(x, x, x, x, false)
+		sc.Callback(x, x, x, x, false)
+		detectGap := NewSynchroGapFn(resetOnGap)
+		for i := 0; i < 2; i++ {
+			msg := <-sc.C
+			if gap := detectGap(&msg); gap != 0 {
+				t.Errorf("%v: wrong gap at message %d: got %d, want 0", resetOnGap, msg.MsgNum, gap)
+			}
+			if want := msg.MsgNum == 0; msg.Reset != want {
+				t.Errorf("%v: wrong reset at message %d: got %v, want %v", resetOnGap, msg.MsgNum, msg.Reset, want)
+			}
+		}
+		sc.Callback(x, x, x, x, false)
+		sc.Callback(x, x, x, x, false)
+
+		msg := <-sc.C
+		if msg.MsgNum != 3 {
+			t.Fatalf("%v: wrong MsgNum: got %d, want 3", resetOnGap, msg.MsgNum)
+		}
+		if gap := detectGap(&msg); gap != 1 {
+			t.Errorf("%v: wrong gap: got %d, want 1", resetOnGap, gap)
+		}
+		if msg.Reset != resetOnGap {
+			t.Errorf("%v: wrong reset after gap: got %v, want %v", resetOnGap, msg.Reset, resetOnGap)
+		}
+
+		// Only the first message after the gap is reset.
+		msg = <-sc.C
+		if gap := detectGap(&msg); gap != 0 || msg.Reset {
+			t.Errorf("%v: wrong gap or reset after gap: got %d and %v, want 0 and false", resetOnGap, gap, msg.Reset)
+		}
+		sc.Close()
+	}
+}
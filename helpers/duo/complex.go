@@ -0,0 +1,33 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package duo
+
+import "github.com/msiner/sdrplay-go/helpers/callback"
+
+// ComplexSynchroCbFn is the function type for user-provided stream
+// callback functions provided to NewComplexSynchro. It provides
+// time-aligned complex samples for stream A in xa and stream B in xb.
+// The slices always have the same length.
+type ComplexSynchroCbFn func(xa, xb []complex64, reset bool)
+
+// NewComplexSynchro creates a new Synchro that converts the synchronized
+// int16 samples to complex64 before calling cb. The numBits argument
+// determines the scaling factor as described in
+// callback.NewConvertToComplex64Fn. See NewSynchro for a description
+// of the remaining arguments.
+//
+// The xa and xb slices passed to cb are slices of internal persistent
+// buffers and should not be modified or stored.
+func NewComplexSynchro(cbSamples int, numBits uint, cb ComplexSynchroCbFn, evtCb SynchroEventCbFn) *Synchro {
+	convertA := callback.NewConvertToComplex64Fn(numBits)
+	convertB := callback.NewConvertToComplex64Fn(numBits)
+	var fn SynchroCbFn
+	if cb != nil {
+		fn = func(xia, xqa, xib, xqb []int16, reset bool) {
+			cb(convertA(xia, xqa), convertB(xib, xqb), reset)
+		}
+	}
+	return NewSynchro(cbSamples, fn, evtCb)
+}
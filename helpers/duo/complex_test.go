@@ -0,0 +1,77 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package duo
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/helpers/callback"
+)
+
+func TestComplexSynchro(t *testing.T) {
+	t.Parallel()
+
+	const (
+		cbSamples    = 1000
+		numSamples   = 384
+		numCallbacks = 50
+		numBits      = 14
+	)
+
+	var want [][]complex64
+	convA := callback.NewConvertToComplex64Fn(numBits)
+	convB := callback.NewConvertToComplex64Fn(numBits)
+	ref := NewSynchro(cbSamples, func(xia, xqa, xib, xqb []int16, reset bool) {
+		want = append(want, append([]complex64(nil), convA(xia, xqa)...))
+		want = append(want, append([]complex64(nil), convB(xib, xqb)...))
+	}, nil)
+
+	var (
+		got    [][]complex64
+		resets []bool
+	)
+	cs := NewComplexSynchro(cbSamples, numBits, func(xa, xb []complex64, reset bool) {
+		if len(xa) != len(xb) {
+			t.Fatalf("mismatched lengths: got %d and %d", len(xa), len(xb))
+		}
+		got = append(got, append([]complex64(nil), xa...))
+		got = append(got, append([]complex64(nil), xb...))
+		resets = append(resets, reset)
+	}, nil)
+
+	xia := make([]int16, numSamples)
+	xqa := make([]int16, numSamples)
+	xib := make([]int16, numSamples)
+	xqb := make([]int16, numSamples)
+	for i := 0; i < numCallbacks; i++ {
+		for _, x := range [][]int16{xia, xqa, xib, xqb} {
+			for j := range x {
+				x[j] = int16(rand.Intn(1<<14) - 1<<13)
+			}
+		}
+		for _, s := range []*Synchro{ref, cs} {
+			s.StreamACallback(xia, xqa, nil, false)
+			s.StreamBCallback(xib, xqb, nil, false)
+		}
+	}
+
+	if len(got) != len(want) || len(got) == 0 {
+		t.Fatalf("wrong number of buffers: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if len(got[i]) != cbSamples {
+			t.Fatalf("wrong buffer length: got %d, want %d", len(got[i]), cbSamples)
+		}
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				t.Fatalf("wrong sample %d in buffer %d: got %v, want %v", j, i, got[i][j], want[i][j])
+			}
+		}
+	}
+	if !resets[0] {
+		t.Error("first callback did not report reset")
+	}
+}
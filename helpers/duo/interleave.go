@@ -12,6 +12,11 @@ package duo
 // the imaginary component of stream B. The lengths of xia, xqa, xib,
 // and xqb should be equal. The length of the resulting slice is four
 // times the length of the shortest of the lengths of all provided slices.
+//
+// The lengths can differ if a buffer is truncated, for example around
+// a stream reset. In that case, the trailing scalars of the longer slices
+// are discarded so that the result only contains complete frames with
+// all four scalars. A nil or empty slice produces an empty result.
 type InterleaveFn func(xia, xqa, xib, xqb []int16) []int16
 
 // NewInterleaveFn creates a new InterleaveFn.
@@ -77,3 +77,35 @@ func BenchmarkInterleave(b *testing.B) {
 		inter(xia, xqa, xib, xqb)
 	}
 }
+
+func TestInterleaveMismatched(t *testing.T) {
+	t.Parallel()
+
+	xia := []int16{1, 2, 3}
+	xqa := []int16{-1, -2}
+	xib := []int16{10, 20, 30, 40}
+	xqb := []int16{-10, -20, -30}
+	specs := []struct {
+		xia, xqa, xib, xqb []int16
+		want               []int16
+	}{
+		{xia, xqa, xib, xqb, []int16{1, -1, 10, -10, 2, -2, 20, -20}},
+		{xia, xia, xib, xqb, []int16{1, 1, 10, -10, 2, 2, 20, -20, 3, 3, 30, -30}},
+		{xia[:1], xqa, xib, xqb, []int16{1, -1, 10, -10}},
+		{xia, xqa, nil, xqb, []int16{}},
+		{nil, nil, nil, nil, []int16{}},
+	}
+	inter := NewInterleaveFn()
+	for i, spec := range specs {
+		x := inter(spec.xia, spec.xqa, spec.xib, spec.xqb)
+		if len(x) != len(spec.want) {
+			t.Errorf("%d: wrong length: got %d, want %d", i, len(x), len(spec.want))
+			continue
+		}
+		for j := range x {
+			if x[j] != spec.want[j] {
+				t.Errorf("%d: wrong value at %d: got %d, want %d", i, j, x[j], spec.want[j])
+			}
+		}
+	}
+}
@@ -118,6 +118,15 @@ func (f *Synchro) Reset() {
 	f.sync = false
 }
 
+// Lag returns the number of samples per channel that have been received
+// from both streams but not yet delivered to the user-provided callback.
+// It is the distance between the internal receive and transfer indices.
+// Like Reset, it should only be called from either the stream or event
+// callback function.
+func (f *Synchro) Lag() int {
+	return (f.rxIdx - f.txIdx + len(f.xia)) % len(f.xia)
+}
+
 // doCallback creates sub-slices of the internal buffers and
 // uses them to call the stream callback function. It advances
 // the internal transfer index.
@@ -285,3 +285,60 @@ func BenchmarkSynchro(b *testing.B) {
 		f.StreamBCallback(xib, xqb, nil, true)
 	}
 }
+
+func TestSynchroLag(t *testing.T) {
+	t.Parallel()
+
+	const (
+		cbSamples  = 1000
+		numSamples = 300
+	)
+	var numCallbacks int
+	f := NewSynchro(
+		cbSamples,
+		func(xia, xqa, xib, xqb []int16, reset bool) {
+			numCallbacks++
+		},
+		nil,
+	)
+	x := make([]int16, numSamples)
+
+	if got := f.Lag(); got != 0 {
+		t.Fatalf("wrong initial lag: got %d, want 0", got)
+	}
+
+	// Stream A alone does not complete any samples.
+	f.StreamACallback(x, x, nil, false)
+	if got := f.Lag(); got != 0 {
+		t.Fatalf("wrong lag after stream A: got %d, want 0", got)
+	}
+
+	// Lag grows while callbacks arrive without a delivery.
+	for i := 1; i <= 3; i++ {
+		if i > 1 {
+			f.StreamACallback(x, x, nil, false)
+		}
+		f.StreamBCallback(x, x, nil, false)
+		if numCallbacks != 0 {
+			t.Fatalf("unexpected delivery after %d callbacks", i)
+		}
+		if got, want := f.Lag(), i*numSamples; got != want {
+			t.Fatalf("wrong lag after %d callbacks: got %d, want %d", i, got, want)
+		}
+	}
+
+	// The fourth pair crosses cbSamples and delivers, shrinking the lag.
+	f.StreamACallback(x, x, nil, false)
+	f.StreamBCallback(x, x, nil, false)
+	if numCallbacks != 1 {
+		t.Fatalf("wrong number of callbacks: got %d, want 1", numCallbacks)
+	}
+	if got, want := f.Lag(), 4*numSamples-cbSamples; got != want {
+		t.Fatalf("wrong lag after delivery: got %d, want %d", got, want)
+	}
+
+	f.Reset()
+	if got := f.Lag(); got != 0 {
+		t.Fatalf("wrong lag after reset: got %d, want 0", got)
+	}
+}
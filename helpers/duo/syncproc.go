@@ -0,0 +1,127 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package duo
+
+import (
+	"fmt"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// SyncProcessor is an alternative to Synchro for consumers that process
+// samples synchronously inside the callback. It makes the same
+// synchronization assumptions as Synchro and emits the same events.
+//
+// When stream callbacks deliver exactly cbSamples samples, which is the
+// common case when cbSamples is chosen to match the callback size of the
+// API, the stream A samples are copied once and the user callback is
+// called directly with that copy and the stream B slices provided by the
+// API. The stream B samples are not copied at all. Otherwise, SyncProcessor
+// falls back to an internal Synchro, which buffers both streams in ring
+// buffers and delivers blocks of exactly cbSamples samples. Since the
+// stream B slices may be provided directly to the user callback, they
+// must not be retained after the callback returns.
+//
+// Use Synchro for the general case where the callback size does not
+// match the desired block size.
+type SyncProcessor struct {
+	s   *Synchro
+	xia []int16
+	xqa []int16
+	// haveA indicates that xia and xqa hold samples from a stream A
+	// callback that have not yet been matched with stream B.
+	haveA bool
+}
+
+// NewSyncProcessor creates a new SyncProcessor. The arguments have the
+// same meaning as for NewSynchro.
+func NewSyncProcessor(cbSamples int, cb SynchroCbFn, evtCb SynchroEventCbFn) *SyncProcessor {
+	return &SyncProcessor{
+		s:   NewSynchro(cbSamples, cb, evtCb),
+		xia: make([]int16, cbSamples),
+		xqa: make([]int16, cbSamples),
+	}
+}
+
+// Reset resets the state of the SyncProcessor. It should only be called
+// from either the stream or event callback function.
+func (p *SyncProcessor) Reset() {
+	p.s.Reset()
+	p.haveA = false
+}
+
+// Lag returns the number of samples per channel that have been received
+// from both streams but not yet delivered to the user-provided callback.
+// It is always zero while stream callbacks deliver exactly cbSamples
+// samples. Like Reset, it should only be called from either the stream
+// or event callback function.
+func (p *SyncProcessor) Lag() int {
+	return p.s.Lag()
+}
+
+// StreamACallback is an implementation of StreamCallbackT bound to a
+// SyncProcessor instance. It copies the provided samples for use by the
+// next call to StreamBCallback. If reset is true, the internal state of
+// the SyncProcessor is reset and an event is issued.
+func (p *SyncProcessor) StreamACallback(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+	if reset {
+		p.Reset()
+	}
+	switch {
+	case len(xi) != len(xq):
+		if p.s.sync {
+			p.s.doEvent(SynchroOutOfSync, fmt.Sprintf("len(xia)=%d len(xqa)=%d", len(xi), len(xq)))
+		}
+		return
+	case p.haveA:
+		if p.s.sync {
+			p.s.doEvent(SynchroOutOfSync, "stream B has not been handled")
+		}
+		return
+	}
+
+	if cap(p.xia) < len(xi) {
+		p.xia = make([]int16, len(xi))
+		p.xqa = make([]int16, len(xq))
+	}
+	p.xia = p.xia[:len(xi)]
+	p.xqa = p.xqa[:len(xq)]
+	copy(p.xia, xi)
+	copy(p.xqa, xq)
+	p.haveA = true
+}
+
+// StreamBCallback is an implementation of StreamCallbackT bound to a
+// SyncProcessor instance. If the provided samples match the length of
+// the last stream A samples and cbSamples, and the fallback Synchro
+// holds no buffered samples, the user-provided callback is called
+// directly. Otherwise, both streams are passed to the fallback Synchro.
+func (p *SyncProcessor) StreamBCallback(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+	f := p.s
+	if !p.haveA {
+		// Let Synchro report the missing stream A samples.
+		f.StreamBCallback(xi, xq, params, reset)
+		return
+	}
+	p.haveA = false
+
+	if len(xi) != len(xq) || len(xi) != len(p.xia) || len(xi) != f.cbScalars || f.Lag() != 0 || f.numSamplesA != 0 {
+		f.StreamACallback(p.xia, p.xqa, params, false)
+		f.StreamBCallback(xi, xq, params, reset)
+		return
+	}
+
+	if !f.sync {
+		f.doEvent(SynchroSync, fmt.Sprintf("synchronized: numSamples=%d", len(xi)))
+	}
+	f.sync = true
+
+	reset = f.reset || reset
+	f.reset = false
+	if f.cb == nil {
+		return
+	}
+	f.cb(p.xia, p.xqa, xi, xq, reset)
+}
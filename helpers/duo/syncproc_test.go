@@ -0,0 +1,137 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package duo
+
+import (
+	"reflect"
+	"testing"
+)
+
+// syncRecorder records the blocks and events delivered by a Synchro or
+// SyncProcessor so the two can be compared.
+type syncRecorder struct {
+	blocks [][4][]int16
+	resets []bool
+	events []SynchroEvent
+}
+
+func (r *syncRecorder) cb(xia, xqa, xib, xqb []int16, reset bool) {
+	var block [4][]int16
+	for i, x := range [][]int16{xia, xqa, xib, xqb} {
+		block[i] = append([]int16(nil), x...)
+	}
+	r.blocks = append(r.blocks, block)
+	r.resets = append(r.resets, reset)
+}
+
+func (r *syncRecorder) evtCb(evt SynchroEvent, msg string) {
+	r.events = append(r.events, evt)
+}
+
+func TestSyncProcessor(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		cbSamples int
+		lens      []int
+	}{
+		// Direct path only.
+		{100, []int{100, 100, 100, 100}},
+		// Fallback path only.
+		{100, []int{30, 30, 30, 30, 30, 30, 30}},
+		{100, []int{250, 250, 250, 250}},
+		// Switch from direct to fallback and back once the ring drains.
+		{100, []int{100, 50, 50, 100, 100, 70, 100, 30, 100}},
+		// Growing callback sizes, including blocks longer than
+		// cbSamples, but no longer than twice that.
+		{4, []int{4, 6, 8, 12, 20, 4, 4}},
+		// Lost synchronization with unmatched lengths.
+		{100, []int{100, -1, 100, 100}},
+	}
+
+	for _, spec := range specs {
+		var want, got syncRecorder
+		f := NewSynchro(spec.cbSamples, want.cb, want.evtCb)
+		p := NewSyncProcessor(spec.cbSamples, got.cb, got.evtCb)
+		var val int16
+		for i, n := range spec.lens {
+			reset := i == 0
+			if n < 0 {
+				// Stream B is shorter than stream A.
+				xa := make([]int16, 10)
+				xb := make([]int16, 5)
+				f.StreamACallback(xa, xa, nil, reset)
+				p.StreamACallback(xa, xa, nil, reset)
+				f.StreamBCallback(xb, xb, nil, reset)
+				p.StreamBCallback(xb, xb, nil, reset)
+				continue
+			}
+			x := make([][]int16, 4)
+			for j := range x {
+				x[j] = make([]int16, n)
+			}
+			for k := 0; k < n; k++ {
+				for j := range x {
+					x[j][k] = val
+					val++
+				}
+			}
+			f.StreamACallback(x[0], x[1], nil, reset)
+			p.StreamACallback(x[0], x[1], nil, reset)
+			// The A samples must be copied by the SyncProcessor.
+			for k := range x[0] {
+				x[0][k], x[1][k] = -1, -1
+			}
+			f.StreamBCallback(x[2], x[3], nil, reset)
+			p.StreamBCallback(x[2], x[3], nil, reset)
+			if p.Lag() != f.Lag() {
+				t.Errorf("wrong lag for %v at %d: got %d, want %d", spec.lens, i, p.Lag(), f.Lag())
+			}
+		}
+		if !reflect.DeepEqual(got.blocks, want.blocks) {
+			t.Errorf("wrong blocks for %v", spec.lens)
+		}
+		if !reflect.DeepEqual(got.resets, want.resets) {
+			t.Errorf("wrong resets for %v: got %v, want %v", spec.lens, got.resets, want.resets)
+		}
+		if !reflect.DeepEqual(got.events, want.events) {
+			t.Errorf("wrong events for %v: got %v, want %v", spec.lens, got.events, want.events)
+		}
+	}
+}
+
+func BenchmarkSyncProcessor(b *testing.B) {
+	const numSamples = 1008
+	p := NewSyncProcessor(
+		numSamples,
+		func(xia, xqa, xib, xqb []int16, reset bool) {
+		},
+		nil,
+	)
+	x := make([]int16, numSamples)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		p.StreamACallback(x, x, nil, false)
+		p.StreamBCallback(x, x, nil, false)
+	}
+}
+
+// BenchmarkSynchroEqualLength is the equivalent of BenchmarkSyncProcessor
+// for Synchro.
+func BenchmarkSynchroEqualLength(b *testing.B) {
+	const numSamples = 1008
+	f := NewSynchro(
+		numSamples,
+		func(xia, xqa, xib, xqb []int16, reset bool) {
+		},
+		nil,
+	)
+	x := make([]int16, numSamples)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		f.StreamACallback(x, x, nil, false)
+		f.StreamBCallback(x, x, nil, false)
+	}
+}
@@ -48,9 +48,20 @@ func GetRelatedParamsMsg(d *api.DeviceT, a api.API, evt Msg) (*api.DeviceParamsT
 	return GetRelatedParams(d, a, evt.EventID, evt.Tuner, &evt.Params)
 }
 
+// AcknowledgeOverload is a helper function to acknowledge a power
+// overload message on the specified tuner without changing any gain
+// settings. The API will keep sending the same message until it is
+// acknowledged. This is useful when the AGC is enabled and should be
+// left in control of the gain.
+func AcknowledgeOverload(d *api.DeviceT, a api.API, t api.TunerSelectT) error {
+	return a.Update(d.Dev, t, api.Update_Ctrl_OverloadMsgAck, api.Update_Ext1_None)
+}
+
 // HandlePowerOverloadChange is a helper function to automatically handle
-// power overload events by reducing gain, if possible, on the affected
-// channel.
+// power overload events. It always acknowledges the message with
+// AcknowledgeOverload. If adjust is true and an overload was detected,
+// it also reduces gain, if possible, on the affected channel by
+// incrementing the LNA state.
 func HandlePowerOverloadChange(d *api.DeviceT, a api.API, e api.EventT, t api.TunerSelectT, p *api.EventParamsT, lg Logger, adjust bool) error {
 	if e != api.PowerOverloadChange {
 		return nil
@@ -61,11 +72,11 @@ func HandlePowerOverloadChange(d *api.DeviceT, a api.API, e api.EventT, t api.Tu
 	if lg != nil {
 		lg.Printf("Acknowledge ID=%v Tuner=%v Type=%v", e, t, powParams.PowerOverloadChangeType)
 	}
-	if err := a.Update(d.Dev, t, api.Update_Ctrl_OverloadMsgAck, api.Update_Ext1_None); err != nil {
+	if err := AcknowledgeOverload(d, a, t); err != nil {
 		return err
 	}
 
-	if powParams.PowerOverloadChangeType != api.Overload_Detected {
+	if !adjust || powParams.PowerOverloadChangeType != api.Overload_Detected {
 		return nil
 	}
 
@@ -74,6 +85,7 @@ func HandlePowerOverloadChange(d *api.DeviceT, a api.API, e api.EventT, t api.Tu
 		return err
 	}
 
+	changed := false
 	for _, rx := range rxParams {
 		currState := rx.TunerParams.Gain.LNAstate
 		maxState := session.GetMaxLNAState(d, devParams, rx)
@@ -84,6 +96,12 @@ func HandlePowerOverloadChange(d *api.DeviceT, a api.API, e api.EventT, t api.Tu
 			lg.Printf("Adjust LNA Tuner=%v CurrState=%d NewState=%d", t, currState, currState+1)
 		}
 		rx.TunerParams.Gain.LNAstate++
+		changed = true
+	}
+	// Nothing to update if every channel is already at the maximum
+	// LNA state (i.e. minimum gain).
+	if !changed {
+		return nil
 	}
 	if err := a.StoreDeviceParams(d.Dev, devParams); err != nil {
 		return err
@@ -0,0 +1,118 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+	"github.com/msiner/sdrplay-go/session"
+)
+
+func TestAcknowledgeOverload(t *testing.T) {
+	t.Parallel()
+
+	dev := &api.DeviceT{HWVer: api.RSP1A_ID}
+	mock := apitest.NewMock(dev)
+	mock.Params = &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelA: &api.RxChannelParamsT{},
+	}
+	mock.Params.RxChannelA.TunerParams.Gain.LNAstate = 2
+
+	if err := AcknowledgeOverload(dev, mock, api.Tuner_A); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mock.Updates) != 1 {
+		t.Fatalf("wrong number of updates: got %d, want 1", len(mock.Updates))
+	}
+	if got := mock.Updates[0].Reason; got != api.Update_Ctrl_OverloadMsgAck {
+		t.Errorf("wrong update reason: got %v, want %v", got, api.Update_Ctrl_OverloadMsgAck)
+	}
+	if got := mock.Params.RxChannelA.TunerParams.Gain.LNAstate; got != 2 {
+		t.Errorf("wrong LNA state: got %d, want 2", got)
+	}
+}
+
+func TestHandlePowerOverloadChange(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		adjust  bool
+		typ     api.PowerOverloadCbEventIdT
+		lna     uint8
+		reasons []api.ReasonForUpdateT
+	}{
+		{false, api.Overload_Detected, 2, []api.ReasonForUpdateT{api.Update_Ctrl_OverloadMsgAck}},
+		{true, api.Overload_Detected, 3, []api.ReasonForUpdateT{api.Update_Ctrl_OverloadMsgAck, api.Update_Tuner_Gr}},
+		{true, api.Overload_Corrected, 2, []api.ReasonForUpdateT{api.Update_Ctrl_OverloadMsgAck}},
+		{false, api.Overload_Corrected, 2, []api.ReasonForUpdateT{api.Update_Ctrl_OverloadMsgAck}},
+	}
+
+	for _, spec := range specs {
+		dev := &api.DeviceT{HWVer: api.RSP1A_ID}
+		mock := apitest.NewMock(dev)
+		mock.Params = &api.DeviceParamsT{
+			DevParams:  &api.DevParamsT{},
+			RxChannelA: &api.RxChannelParamsT{},
+		}
+		mock.Params.RxChannelA.TunerParams.RfFreq.RfHz = 100e6
+		mock.Params.RxChannelA.TunerParams.Gain.LNAstate = 2
+
+		params := &api.EventParamsT{}
+		params.PowerOverloadParams.PowerOverloadChangeType = spec.typ
+		err := HandlePowerOverloadChange(dev, mock, api.PowerOverloadChange, api.Tuner_A, params, nil, spec.adjust)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := mock.Params.RxChannelA.TunerParams.Gain.LNAstate; got != spec.lna {
+			t.Errorf("wrong LNA state with adjust=%v type=%v: got %d, want %d", spec.adjust, spec.typ, got, spec.lna)
+		}
+		if len(mock.Updates) != len(spec.reasons) {
+			t.Fatalf("wrong number of updates with adjust=%v type=%v: got %d, want %d", spec.adjust, spec.typ, len(mock.Updates), len(spec.reasons))
+		}
+		for i, want := range spec.reasons {
+			if got := mock.Updates[i].Reason; got != want {
+				t.Errorf("wrong update reason %d: got %v, want %v", i, got, want)
+			}
+		}
+	}
+}
+
+func TestHandlePowerOverloadChangeMaxLNA(t *testing.T) {
+	t.Parallel()
+
+	dev := &api.DeviceT{HWVer: api.RSP1A_ID}
+	mock := apitest.NewMock(dev)
+	mock.Params = &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelA: &api.RxChannelParamsT{},
+	}
+	rx := mock.Params.RxChannelA
+	rx.TunerParams.RfFreq.RfHz = 100e6
+	maxState := session.GetMaxLNAState(dev, mock.Params, rx)
+	rx.TunerParams.Gain.LNAstate = maxState
+
+	params := &api.EventParamsT{}
+	params.PowerOverloadParams.PowerOverloadChangeType = api.Overload_Detected
+	err := HandlePowerOverloadChange(dev, mock, api.PowerOverloadChange, api.Tuner_A, params, nil, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := rx.TunerParams.Gain.LNAstate; got != maxState {
+		t.Errorf("wrong LNA state: got %d, want %d", got, maxState)
+	}
+	// Only the acknowledgement should be sent when gain cannot be reduced.
+	if len(mock.Updates) != 1 {
+		t.Fatalf("wrong number of updates: got %d, want 1", len(mock.Updates))
+	}
+	for _, call := range mock.Calls {
+		if call == "StoreDeviceParams" {
+			t.Error("unexpected StoreDeviceParams call")
+		}
+	}
+}
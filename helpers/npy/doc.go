@@ -0,0 +1,29 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package npy implements creation of NumPy .npy file headers so that
+captures can be loaded directly with numpy.load.
+
+A .npy file is a short header followed by the raw array data. The header
+holds the magic string, the format version, and a Python dict literal
+describing the data type (descr), the memory order (fortran_order), and
+the shape of the array. This package writes the version 1.0 format with
+C (row-major) order, so interleaved IQ samples are either a 1-D array of
+complex values (e.g. complex64) or a 2-D array with one row per sample
+and one column per component (e.g. int16 with shape (N, 2)).
+
+Like the size fields of a WAV header, the first dimension of the shape
+is only known once writing is complete. Every header is padded to the
+fixed HeaderSize, so it can be rewritten in place with the final shape.
+For a seekable file, write a header with zero rows, write the samples,
+and then seek back and write the header again after calling
+Header.Update. If a capture is interrupted before the header is
+rewritten, Finalize repairs the header by measuring the length of the
+data.
+
+See https://numpy.org/doc/stable/reference/generated/numpy.lib.format.html
+for the specification.
+*/
+package npy
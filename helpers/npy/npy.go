@@ -0,0 +1,414 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package npy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Magic is the magic string at the beginning of every .npy file.
+const Magic = "\x93NUMPY"
+
+// HeaderSize is the size in bytes of every header written by this
+// package, including the magic string, version, and length fields. It
+// is a multiple of 64, as recommended by the format, and leaves enough
+// padding that the shape can grow to several 20 digit dimensions
+// without changing the size of the header.
+const HeaderSize = 128
+
+// Header describes the array stored in a .npy file.
+type Header struct {
+	// Descr is the NumPy array-protocol type string of each element
+	// (e.g. "<c8" for little-endian complex64). See Descr.
+	Descr string
+	// FortranOrder is true if the array is stored in column-major
+	// order. It is false for interleaved samples.
+	FortranOrder bool
+	// Shape is the size of each dimension. The first dimension is the
+	// one that grows as samples are written.
+	Shape []uint64
+}
+
+// Descr returns the array-protocol type string for elements of the
+// provided kind and size in bytes. The kind is 'i' for signed integers,
+// 'u' for unsigned integers, 'f' for floating-point, or 'c' for complex
+// floating-point, in which case the size covers both components (e.g. 8
+// for complex64). The order argument is ignored for 1-byte elements.
+func Descr(kind byte, size int, order binary.ByteOrder) (string, error) {
+	var valid bool
+	switch kind {
+	case 'i', 'u':
+		valid = size == 1 || size == 2 || size == 4 || size == 8
+	case 'f':
+		valid = size == 2 || size == 4 || size == 8
+	case 'c':
+		valid = size == 8 || size == 16
+	default:
+		return "", fmt.Errorf("invalid kind: got %q, want 'i', 'u', 'f', or 'c'", kind)
+	}
+	if !valid {
+		return "", fmt.Errorf("invalid size for kind %q: got %d bytes", kind, size)
+	}
+	if size == 1 {
+		return fmt.Sprintf("|%c%d", kind, size), nil
+	}
+	switch order {
+	case binary.LittleEndian:
+		return fmt.Sprintf("<%c%d", kind, size), nil
+	case binary.BigEndian:
+		return fmt.Sprintf(">%c%d", kind, size), nil
+	default:
+		return "", fmt.Errorf("invalid byte order: got %v, want big or little endian", order)
+	}
+}
+
+// itemSize returns the size in bytes of an element described by the
+// provided type string.
+func itemSize(descr string) (int, error) {
+	if len(descr) < 3 || !strings.ContainsRune("<>|=", rune(descr[0])) {
+		return 0, fmt.Errorf("invalid descr: got %q, want byte order, kind, and size", descr)
+	}
+	size, err := strconv.Atoi(descr[2:])
+	if err != nil || size < 1 {
+		return 0, fmt.Errorf("invalid descr: got %q, want byte order, kind, and size", descr)
+	}
+	return size, nil
+}
+
+// Update sets the first dimension of the shape to the provided number
+// of rows. If the shape is empty, it becomes one dimensional.
+func (h *Header) Update(numRows uint64) {
+	if len(h.Shape) == 0 {
+		h.Shape = []uint64{numRows}
+		return
+	}
+	h.Shape[0] = numRows
+}
+
+// Bytes serializes h as a version 1.0 header padded to HeaderSize
+// bytes. It returns an error if the header does not fit.
+func (h *Header) Bytes() ([]byte, error) {
+	if strings.ContainsAny(h.Descr, `'\`) {
+		return nil, fmt.Errorf("invalid descr: got %q", h.Descr)
+	}
+	fortran := "False"
+	if h.FortranOrder {
+		fortran = "True"
+	}
+	dims := make([]string, len(h.Shape))
+	for i, dim := range h.Shape {
+		dims[i] = strconv.FormatUint(dim, 10)
+	}
+	shape := strings.Join(dims, ", ")
+	if len(dims) == 1 {
+		// A Python tuple with one element needs a trailing comma.
+		shape += ","
+	}
+	dict := fmt.Sprintf("{'descr': '%s', 'fortran_order': %s, 'shape': (%s), }", h.Descr, fortran, shape)
+
+	// The dict is padded with spaces and terminated with a newline.
+	dictSize := HeaderSize - len(Magic) - 4
+	if len(dict)+1 > dictSize {
+		return nil, fmt.Errorf("invalid header size: got %d bytes, want <= %d", len(dict)+1, dictSize)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(Magic)
+	buf.Write([]byte{1, 0})
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(dictSize))
+	buf.WriteString(dict)
+	buf.WriteString(strings.Repeat(" ", dictSize-len(dict)-1))
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// WriteHeader writes h to w as serialized by Header.Bytes. It returns
+// the number of bytes written, which is the offset of the first element.
+func WriteHeader(w io.Writer, h *Header) (int, error) {
+	b, err := h.Bytes()
+	if err != nil {
+		return 0, err
+	}
+	return w.Write(b)
+}
+
+// ReadHeader reads a Header from r. It accepts headers of version 1.0,
+// 2.0, and 3.0, as written by numpy.save, as long as they describe a
+// simple (not structured) data type. It returns an error if the header
+// is not valid.
+func ReadHeader(r io.Reader) (*Header, error) {
+	h, _, err := readHeader(r)
+	return h, err
+}
+
+// readHeader implements ReadHeader. It also returns the offset of the
+// first element.
+func readHeader(r io.Reader) (*Header, int64, error) {
+	pre := make([]byte, len(Magic)+2)
+	if _, err := io.ReadFull(r, pre); err != nil {
+		return nil, 0, err
+	}
+	if string(pre[:len(Magic)]) != Magic {
+		return nil, 0, fmt.Errorf("invalid magic: got %q, want %q", pre[:len(Magic)], Magic)
+	}
+	var lenField []byte
+	switch major := pre[len(Magic)]; major {
+	case 1:
+		lenField = make([]byte, 2)
+	case 2, 3:
+		lenField = make([]byte, 4)
+	default:
+		return nil, 0, fmt.Errorf("invalid version: got %d.%d, want 1.0, 2.0, or 3.0", major, pre[len(Magic)+1])
+	}
+	if _, err := io.ReadFull(r, lenField); err != nil {
+		return nil, 0, err
+	}
+	var dictSize uint32
+	if len(lenField) == 2 {
+		dictSize = uint32(binary.LittleEndian.Uint16(lenField))
+	} else {
+		dictSize = binary.LittleEndian.Uint32(lenField)
+	}
+	dict := make([]byte, dictSize)
+	if _, err := io.ReadFull(r, dict); err != nil {
+		return nil, 0, err
+	}
+	h, err := parseHeader(string(dict))
+	if err != nil {
+		return nil, 0, err
+	}
+	return h, int64(len(pre) + len(lenField) + len(dict)), nil
+}
+
+// parseHeader parses the dict literal of a header.
+func parseHeader(s string) (*Header, error) {
+	p := &literalParser{s: s}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("invalid header: unexpected %q at offset %d", p.s[p.pos:], p.pos)
+	}
+	dict, ok := v.(map[string]interface{})
+	if !ok || len(dict) != 3 {
+		return nil, errors.New("invalid header: want dict with descr, fortran_order, and shape")
+	}
+
+	var h Header
+	if h.Descr, ok = dict["descr"].(string); !ok {
+		return nil, errors.New("invalid header: descr is not a string")
+	}
+	if h.FortranOrder, ok = dict["fortran_order"].(bool); !ok {
+		return nil, errors.New("invalid header: fortran_order is not a bool")
+	}
+	shape, ok := dict["shape"].([]interface{})
+	if !ok {
+		return nil, errors.New("invalid header: shape is not a tuple")
+	}
+	h.Shape = make([]uint64, len(shape))
+	for i, dim := range shape {
+		if h.Shape[i], ok = dim.(uint64); !ok {
+			return nil, fmt.Errorf("invalid header: shape[%d] is not an integer", i)
+		}
+	}
+	return &h, nil
+}
+
+// literalParser parses the subset of Python literal syntax used by
+// .npy headers: dicts, tuples, strings, non-negative integers, True,
+// and False.
+type literalParser struct {
+	s   string
+	pos int
+}
+
+func (p *literalParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// value parses the next value.
+func (p *literalParser) value() (interface{}, error) {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return nil, errors.New("invalid header: unexpected end")
+	}
+	switch c := p.s[p.pos]; {
+	case c == '{':
+		return p.dict()
+	case c == '(':
+		return p.tuple()
+	case c == '\'' || c == '"':
+		return p.str()
+	case c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		return strconv.ParseUint(p.s[start:p.pos], 10, 64)
+	case strings.HasPrefix(p.s[p.pos:], "True"):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(p.s[p.pos:], "False"):
+		p.pos += 5
+		return false, nil
+	default:
+		return nil, fmt.Errorf("invalid header: unexpected %q at offset %d", c, p.pos)
+	}
+}
+
+// str parses a quoted string without escape sequences.
+func (p *literalParser) str() (string, error) {
+	quote := p.s[p.pos]
+	end := strings.IndexByte(p.s[p.pos+1:], quote)
+	if end < 0 {
+		return "", errors.New("invalid header: unterminated string")
+	}
+	res := p.s[p.pos+1 : p.pos+1+end]
+	if strings.IndexByte(res, '\\') >= 0 {
+		return "", errors.New("invalid header: unsupported escape in string")
+	}
+	p.pos += end + 2
+	return res, nil
+}
+
+// items parses comma separated items, with an optional trailing comma,
+// until the close byte. The opening byte has already been consumed.
+func (p *literalParser) items(close byte, item func() error) error {
+	for {
+		p.skipSpace()
+		if p.pos < len(p.s) && p.s[p.pos] == close {
+			p.pos++
+			return nil
+		}
+		if err := item(); err != nil {
+			return err
+		}
+		p.skipSpace()
+		if p.pos == len(p.s) {
+			return errors.New("invalid header: unexpected end")
+		}
+		switch p.s[p.pos] {
+		case ',':
+			p.pos++
+		case close:
+		default:
+			return fmt.Errorf("invalid header: unexpected %q at offset %d", p.s[p.pos], p.pos)
+		}
+	}
+}
+
+func (p *literalParser) dict() (map[string]interface{}, error) {
+	p.pos++
+	res := map[string]interface{}{}
+	err := p.items('}', func() error {
+		p.skipSpace()
+		if p.pos == len(p.s) || (p.s[p.pos] != '\'' && p.s[p.pos] != '"') {
+			return errors.New("invalid header: dict key is not a string")
+		}
+		key, err := p.str()
+		if err != nil {
+			return err
+		}
+		p.skipSpace()
+		if p.pos == len(p.s) || p.s[p.pos] != ':' {
+			return errors.New("invalid header: missing ':' after dict key")
+		}
+		p.pos++
+		val, err := p.value()
+		if err != nil {
+			return err
+		}
+		res[key] = val
+		return nil
+	})
+	return res, err
+}
+
+func (p *literalParser) tuple() ([]interface{}, error) {
+	p.pos++
+	res := []interface{}{}
+	err := p.items(')', func() error {
+		val, err := p.value()
+		if err != nil {
+			return err
+		}
+		res = append(res, val)
+		return nil
+	})
+	return res, err
+}
+
+// Finalize repairs the header of an existing .npy file, created with a
+// Header from this package, so that the first dimension of the shape
+// matches the length of the file. It is intended for files whose header
+// was never updated because the capture was interrupted. The file must
+// be seekable and writable. Any trailing partial row is left in the
+// file, but is not counted.
+//
+// It returns the number of rows in the finalized file.
+func Finalize(path string) (uint64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	numRows, err := finalize(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to finalize %s: %v", path, err)
+	}
+	return numRows, nil
+}
+
+// finalize rewrites the header of the .npy file provided as f.
+func finalize(f *os.File) (uint64, error) {
+	head, offset, err := readHeader(f)
+	if err != nil {
+		return 0, err
+	}
+	if head.FortranOrder || len(head.Shape) == 0 {
+		return 0, errors.New("cannot finalize array without a C order first dimension")
+	}
+	rowSize, err := itemSize(head.Descr)
+	if err != nil {
+		return 0, err
+	}
+	for _, dim := range head.Shape[1:] {
+		rowSize *= int(dim)
+	}
+	if rowSize == 0 {
+		return 0, errors.New("cannot finalize array with empty rows")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	numRows := uint64(info.Size()-offset) / uint64(rowSize)
+	head.Update(numRows)
+	b, err := head.Bytes()
+	if err != nil {
+		return 0, err
+	}
+	if int64(len(b)) != offset {
+		return 0, fmt.Errorf("invalid header size: got %d bytes, want %d", offset, len(b))
+	}
+	if _, err := f.WriteAt(b, 0); err != nil {
+		return 0, err
+	}
+	return numRows, nil
+}
@@ -0,0 +1,221 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package npy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDescr(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		kind  byte
+		size  int
+		order binary.ByteOrder
+		want  string
+	}{
+		{'c', 8, binary.LittleEndian, "<c8"},
+		{'c', 16, binary.BigEndian, ">c16"},
+		{'i', 2, binary.LittleEndian, "<i2"},
+		{'i', 1, binary.BigEndian, "|i1"},
+		{'f', 4, binary.BigEndian, ">f4"},
+		{'u', 8, binary.LittleEndian, "<u8"},
+	}
+	for _, spec := range specs {
+		got, err := Descr(spec.kind, spec.size, spec.order)
+		if err != nil {
+			t.Errorf("unexpected error for %c%d: %v", spec.kind, spec.size, err)
+			continue
+		}
+		if got != spec.want {
+			t.Errorf("wrong descr: got %q, want %q", got, spec.want)
+		}
+	}
+
+	for _, spec := range []struct {
+		kind byte
+		size int
+	}{{'c', 4}, {'f', 1}, {'i', 3}, {'x', 2}} {
+		if got, err := Descr(spec.kind, spec.size, binary.LittleEndian); err == nil {
+			t.Errorf("unexpected success for %c%d: got %q", spec.kind, spec.size, got)
+		}
+	}
+}
+
+func TestHeaderBytes(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		head *Header
+		dict string
+	}{
+		{&Header{Descr: "<c8", Shape: []uint64{0}}, "{'descr': '<c8', 'fortran_order': False, 'shape': (0,), }"},
+		{&Header{Descr: "<i2", Shape: []uint64{1000, 2}}, "{'descr': '<i2', 'fortran_order': False, 'shape': (1000, 2), }"},
+		{&Header{Descr: ">f4", FortranOrder: true}, "{'descr': '>f4', 'fortran_order': True, 'shape': (), }"},
+		{&Header{Descr: "<i2", Shape: []uint64{math.MaxUint64, 3}}, "{'descr': '<i2', 'fortran_order': False, 'shape': (18446744073709551615, 3), }"},
+	}
+	for _, spec := range specs {
+		b, err := spec.head.Bytes()
+		if err != nil {
+			t.Errorf("unexpected error for %+v: %v", spec.head, err)
+			continue
+		}
+		// These are the checks made by numpy.lib.format when reading
+		// a version 1.0 header.
+		if len(b) != HeaderSize || len(b)%64 != 0 {
+			t.Errorf("wrong header size: got %d, want %d", len(b), HeaderSize)
+		}
+		if !bytes.HasPrefix(b, []byte(Magic+"\x01\x00")) {
+			t.Errorf("wrong magic and version: got %q", b[:8])
+		}
+		if got := int(binary.LittleEndian.Uint16(b[8:10])); got != len(b)-10 {
+			t.Errorf("wrong header length field: got %d, want %d", got, len(b)-10)
+		}
+		if b[len(b)-1] != '\n' {
+			t.Errorf("wrong header terminator: got %q, want newline", b[len(b)-1])
+		}
+		if got := strings.TrimRight(string(b[10:]), " \n"); got != spec.dict {
+			t.Errorf("wrong header dict: got %q, want %q", got, spec.dict)
+		}
+
+		got, err := ReadHeader(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("unexpected read error for %+v: %v", spec.head, err)
+			continue
+		}
+		want := *spec.head
+		if want.Shape == nil {
+			want.Shape = []uint64{}
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("wrong header: got %+v, want %+v", *got, want)
+		}
+	}
+
+	long := &Header{Descr: "<i2", Shape: []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24}}
+	if _, err := long.Bytes(); err == nil {
+		t.Error("unexpected success for oversized header")
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	t.Parallel()
+
+	// Headers as written by numpy.save for versions 1.0 and 2.0.
+	v1 := "{'descr': '<c8', 'fortran_order': False, 'shape': (3,), }"
+	v1 = Magic + "\x01\x00\x76\x00" + v1 + strings.Repeat(" ", 0x76-len(v1)-1) + "\n"
+	v2 := `{"shape": (2, 3), "fortran_order": True, "descr": "<f8"}` + "\n"
+	v2 = Magic + "\x02\x00" + string([]byte{byte(len(v2)), 0, 0, 0}) + v2
+
+	specs := []struct {
+		in   string
+		want Header
+	}{
+		{v1, Header{Descr: "<c8", Shape: []uint64{3}}},
+		{v2, Header{Descr: "<f8", FortranOrder: true, Shape: []uint64{2, 3}}},
+	}
+	for _, spec := range specs {
+		got, err := ReadHeader(strings.NewReader(spec.in))
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", spec.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, spec.want) {
+			t.Errorf("wrong header: got %+v, want %+v", *got, spec.want)
+		}
+	}
+
+	// withLen prefixes a dict with the magic, version 1.0, and length.
+	withLen := func(dict string) string {
+		return Magic + "\x01\x00" + string([]byte{byte(len(dict)), 0}) + dict
+	}
+	invalid := []string{
+		"\x93NUMPX\x01\x00\x02\x00{}",
+		Magic + "\x04\x00\x02\x00{}",
+		Magic + "\x01\x00\x40\x00{}",
+		withLen("{}"),
+		withLen("{'descr': '<c8', 'shape': (3,), }"),
+		withLen("{'descr': '<c8', 'fortran_order': 0, 'shape': (3,), }"),
+		withLen("{'descr': '<c8', 'fortran_order': False, 'shape': [3], }"),
+		withLen("{'descr': [('a', '<i2')], 'fortran_order': False, 'shape': (3,)}"),
+		withLen("{'descr': '<c8', 'fortran_order': False, 'shape': (3,)"),
+		withLen("{'descr': '<c8', 'fortran_order': False, 'shape': (3,)} x"),
+	}
+	for _, in := range invalid {
+		if got, err := ReadHeader(strings.NewReader(in)); err == nil {
+			t.Errorf("unexpected success for %q: got %+v", in, got)
+		}
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "npy")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Write 1000 int16 IQ samples plus a partial sample without ever
+	// updating the header, as if the capture was interrupted.
+	const numRows = 1000
+	path := filepath.Join(dir, "capture.npy")
+	head := &Header{Descr: "<i2", Shape: []uint64{0, 2}}
+	var buf bytes.Buffer
+	if _, err := WriteHeader(&buf, head); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := make([]int16, numRows*2+1)
+	for i := range data {
+		data[i] = int16(i)
+	}
+	_ = binary.Write(&buf, binary.LittleEndian, data)
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := Finalize(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != numRows {
+		t.Errorf("wrong number of rows: got %d, want %d", got, numRows)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	final, err := ReadHeader(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []uint64{numRows, 2}; final.Descr != "<i2" || !reflect.DeepEqual(final.Shape, want) {
+		t.Errorf("wrong header: got %+v, want Descr=<i2 Shape=%v", final, want)
+	}
+	// The data must be untouched.
+	var first [4]int16
+	if err := binary.Read(f, binary.LittleEndian, &first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != [4]int16{0, 1, 2, 3} {
+		t.Errorf("wrong data: got %v, want [0 1 2 3]", first)
+	}
+
+	if _, err := Finalize(filepath.Join(dir, "missing.npy")); err == nil {
+		t.Error("unexpected success for missing file")
+	}
+}
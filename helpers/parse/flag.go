@@ -5,12 +5,14 @@
 package parse
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/helpers/wav"
 )
 
 // LNAFlagHelp contains a flag help message for a flag that accepts an
@@ -154,8 +156,9 @@ func AGCSetFlag(val int) (int32, error) {
 const DuoTunerFlagHelp = `a|1|b|2|either: RSPDuo Tuner Selection
 Select which RSPDuo tuner to use if the selected device is an RSPduo. If
 "either" is specified, tuner A will be used if available. Otherwise, tuner
-B will be used if available. If the selected device is not an RSPduo, this
-option will have no effect.`
+B will be used if available. If the High-Z port is enabled, "either"
+selects only tuner A and "b" is an error. If the selected device is not
+an RSPduo, this option will have no effect.`
 
 // DuoTunerSelect is an enum type returned from DuoTunerFlag to convey
 // the parsed tuner selection. This type has been created because there
@@ -187,6 +190,26 @@ func DuoTunerFlag(arg string) (DuoTunerSelect, error) {
 	}
 }
 
+// HiZDuoTuner reconciles an RSPduo tuner selection with a request to
+// enable the High-Z port. The RSPduo High-Z port is only available on
+// tuner A, so if hiz is true, DuoTunerFlagEither resolves to
+// DuoTunerFlagA and DuoTunerFlagB is an error. This allows the conflict
+// to be reported before device selection instead of during device
+// configuration. If hiz is false, sel is returned unchanged.
+func HiZDuoTuner(hiz bool, sel DuoTunerSelect) (DuoTunerSelect, error) {
+	if !hiz {
+		return sel, nil
+	}
+	switch sel {
+	case DuoTunerFlagEither, DuoTunerFlagA:
+		return DuoTunerFlagA, nil
+	case DuoTunerFlagB:
+		return "", errors.New("invalid duotuner value with hiz: RSPduo High-Z port is only available on tuner A")
+	default:
+		return "", fmt.Errorf("invalid duotuner value: got %s, want a|b|either", sel)
+	}
+}
+
 // SerialsFlagHelp contains a flag help message for a flag that accepts a
 // comma-separated list of device serial numbers and has a value that is
 // parsed and validated by SerialsFlag.
@@ -260,7 +283,8 @@ func USBFlag(arg string) (api.TransferModeT, error) {
 // HiZFlagHelp contains a flag help message for a boolean flag that
 // requests use of a High-Z port when true.
 const HiZFlagHelp = `Enable High-Z Port
-If using an RSP2 or RSPduo, enable the High-Z port.`
+If using an RSP2 or RSPduo, enable the High-Z port. The RSPduo High-Z
+port is only available on tuner A.`
 
 // DxAntFlagHelp contains a flag help message for a flag that accepts an
 // RSPdx antenna selection and has a value that is parsed and validated by
@@ -301,3 +325,87 @@ func Rsp2AntFlag(arg string) (api.Rsp2_AntennaSelectT, error) {
 		return 0, fmt.Errorf("invalid RSP2 antenna: got %s, want a|b", arg)
 	}
 }
+
+// RotateFlagHelp contains a flag help message for a flag that accepts a
+// file rotation boundary and has a value that is parsed and validated by
+// RotateFlag.
+const RotateFlagHelp = `none|hourly|daily: File Rotation
+Start a new output file each time the wall clock crosses the top of a
+UTC hour or UTC midnight. Each file is named with the output path prefix
+and the UTC time of its first sample.`
+
+// RotateFlag parses and validates a file rotation boundary. Valid
+// values are "none", "hourly", or "daily".
+func RotateFlag(arg string) (wav.Boundary, error) {
+	switch strings.ToLower(arg) {
+	case "none", "":
+		return wav.NoBoundary, nil
+	case "hourly":
+		return wav.Hourly, nil
+	case "daily":
+		return wav.Daily, nil
+	default:
+		return 0, fmt.Errorf("invalid rotation: got %s, want none|hourly|daily", arg)
+	}
+}
+
+// LatFlagHelp contains a flag help message for a flag that accepts a
+// receiver latitude. It is parsed and validated by LocationFlag.
+const LatFlagHelp = `degrees: Receiver Latitude
+Latitude of the receiver in decimal degrees north. Must be provided
+together with a longitude to record the location in the metadata.`
+
+// LonFlagHelp contains a flag help message for a flag that accepts a
+// receiver longitude. It is parsed and validated by LocationFlag.
+const LonFlagHelp = `degrees: Receiver Longitude
+Longitude of the receiver in decimal degrees east. Must be provided
+together with a latitude to record the location in the metadata.`
+
+// AltFlagHelp contains a flag help message for a flag that accepts a
+// receiver altitude. It is parsed and validated by LocationFlag.
+const AltFlagHelp = `meters: Receiver Altitude
+Altitude of the receiver in meters above the WGS 84 ellipsoid. Requires
+a latitude and longitude. If not provided, the altitude is recorded as 0.`
+
+// LocationFlag parses and validates the latitude, longitude, and
+// altitude flags. Empty values mean the flag was not provided. It
+// returns nil if no location was provided. Latitude and longitude must
+// be provided together and altitude requires both.
+func LocationFlag(lat, lon, alt string) (*wav.Location, error) {
+	if lat == "" && lon == "" {
+		if alt != "" {
+			return nil, errors.New("altitude requires a latitude and longitude")
+		}
+		return nil, nil
+	}
+	if lat == "" || lon == "" {
+		return nil, errors.New("latitude and longitude must be provided together")
+	}
+	var (
+		loc wav.Location
+		err error
+	)
+	if loc.Latitude, err = strconv.ParseFloat(lat, 64); err != nil {
+		return nil, fmt.Errorf("invalid latitude: %v", err)
+	}
+	if loc.Longitude, err = strconv.ParseFloat(lon, 64); err != nil {
+		return nil, fmt.Errorf("invalid longitude: %v", err)
+	}
+	if alt != "" {
+		if loc.Altitude, err = strconv.ParseFloat(alt, 64); err != nil {
+			return nil, fmt.Errorf("invalid altitude: %v", err)
+		}
+	}
+	if err := loc.Validate(); err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+// TestSignalFlagHelp contains a flag help message for a boolean flag that
+// replaces the RSP device with a generated test pattern when true.
+const TestSignalFlagHelp = `Use Test Signal (testing only)
+Do not open an RSP device or the SDRplay API service. Instead, feed the
+normal processing chain with the apitest.Pattern test pattern, a complex
+tone at 1/64 of the effective sample rate, generated at the configured
+rate. This is intended only for validating downstream processing.`
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/helpers/wav"
 )
 
 func TestLNAFlag(t *testing.T) {
@@ -122,3 +123,69 @@ func TestSerialsFlag(t *testing.T) {
 		}
 	}
 }
+
+func TestHiZDuoTuner(t *testing.T) {
+	specs := []struct {
+		hiz   bool
+		sel   DuoTunerSelect
+		valid bool
+		want  DuoTunerSelect
+	}{
+		{false, DuoTunerFlagEither, true, DuoTunerFlagEither},
+		{false, DuoTunerFlagA, true, DuoTunerFlagA},
+		{false, DuoTunerFlagB, true, DuoTunerFlagB},
+		{true, DuoTunerFlagEither, true, DuoTunerFlagA},
+		{true, DuoTunerFlagA, true, DuoTunerFlagA},
+		{true, DuoTunerFlagB, false, ""},
+		{true, DuoTunerSelect("c"), false, ""},
+	}
+
+	for i, spec := range specs {
+		got, err := HiZDuoTuner(spec.hiz, spec.sel)
+		switch {
+		case !spec.valid && err == nil:
+			t.Errorf("%d: unexpected success", i)
+		case spec.valid && err != nil:
+			t.Errorf("%d: unexpected error: %v", i, err)
+		case got != spec.want:
+			t.Errorf("%d: wrong tuner: got %v, want %v", i, got, spec.want)
+		}
+	}
+}
+
+func TestLocationFlag(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		lat, lon, alt string
+		valid         bool
+		want          *wav.Location
+	}{
+		{"", "", "", true, nil},
+		{"40.5", "-79.25", "", true, &wav.Location{Latitude: 40.5, Longitude: -79.25}},
+		{"-33.9", "151.2", "58", true, &wav.Location{Latitude: -33.9, Longitude: 151.2, Altitude: 58}},
+		{"40.5", "", "", false, nil},
+		{"", "-79.25", "", false, nil},
+		{"", "", "100", false, nil},
+		{"91", "0", "", false, nil},
+		{"0", "-181", "", false, nil},
+		{"north", "0", "", false, nil},
+		{"0", "0", "NaN", false, nil},
+	}
+
+	for i, spec := range specs {
+		got, err := LocationFlag(spec.lat, spec.lon, spec.alt)
+		switch {
+		case !spec.valid && err == nil:
+			t.Errorf("%d: unexpected success", i)
+		case !spec.valid:
+			// expected error
+		case err != nil:
+			t.Errorf("%d: unexpected error: %v", i, err)
+		case spec.want == nil && got != nil:
+			t.Errorf("%d: wrong location: got %v, want nil", i, *got)
+		case spec.want != nil && (got == nil || *got != *spec.want):
+			t.Errorf("%d: wrong location: got %v, want %v", i, got, *spec.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package replay
+
+import (
+	"fmt"
+	"math"
+)
+
+// DecodeFn is a function type that decodes the provided bytes of
+// interleaved IQ samples to complex samples. Integer scalars are scaled
+// to the [-1,1) range. Floating-point scalars are not scaled. Any
+// trailing partial frame is ignored.
+type DecodeFn func(b []byte) []complex64
+
+// NewDecodeFn creates a new DecodeFn for the provided format.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not be
+// modified or stored.
+func NewDecodeFn(f Format) (DecodeFn, error) {
+	frame := f.FrameSize()
+	if frame == 0 {
+		return nil, fmt.Errorf("invalid scalar type: got %v, want Int8|Int16|Float32", f.Scalar)
+	}
+	if f.Scalar != Int8 && f.Order == nil {
+		return nil, fmt.Errorf("missing byte order for %v", f.Scalar)
+	}
+	order := f.Order
+	buf := make([]complex64, 4096)
+	return func(b []byte) []complex64 {
+		n := len(b) / frame
+		if len(buf) < n {
+			next := len(buf) * 2
+			if next < n {
+				next = n
+			}
+			buf = make([]complex64, next)
+		}
+		out := buf[:n]
+		switch f.Scalar {
+		case Int8:
+			for i := range out {
+				out[i] = complex(float32(int8(b[2*i]))/128, float32(int8(b[2*i+1]))/128)
+			}
+		case Int16:
+			for i := range out {
+				out[i] = complex(
+					float32(int16(order.Uint16(b[4*i:])))/32768,
+					float32(int16(order.Uint16(b[4*i+2:])))/32768,
+				)
+			}
+		case Float32:
+			for i := range out {
+				out[i] = complex(
+					math.Float32frombits(order.Uint32(b[8*i:])),
+					math.Float32frombits(order.Uint32(b[8*i+4:])),
+				)
+			}
+		}
+		return out
+	}, nil
+}
+
+// NewDecodeFn creates a new DecodeFn for the detected format.
+func (d Detection) NewDecodeFn() (DecodeFn, error) {
+	return NewDecodeFn(d.Format)
+}
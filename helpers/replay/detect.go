@@ -0,0 +1,283 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package replay
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// DetectWindow is the maximum number of bytes read by Detect.
+const DetectWindow = 1 << 20
+
+// ScalarType is an enum type that represents the type of each I or Q
+// scalar in a file.
+type ScalarType int
+
+const (
+	// Int8 is an 8-bit signed integer scalar.
+	Int8 ScalarType = iota + 1
+	// Int16 is a 16-bit signed integer scalar.
+	Int16
+	// Float32 is a 32-bit IEEE floating-point scalar.
+	Float32
+)
+
+// Size returns the number of bytes per scalar.
+func (s ScalarType) Size() int {
+	switch s {
+	case Int8:
+		return 1
+	case Int16:
+		return 2
+	case Float32:
+		return 4
+	default:
+		return 0
+	}
+}
+
+func (s ScalarType) String() string {
+	switch s {
+	case Int8:
+		return "Int8"
+	case Int16:
+		return "Int16"
+	case Float32:
+		return "Float32"
+	default:
+		return fmt.Sprintf("ScalarType(%d)", int(s))
+	}
+}
+
+// Format describes the layout of a file of interleaved IQ samples.
+type Format struct {
+	Scalar ScalarType
+	// Order is the byte order of the scalars. It is irrelevant for
+	// Int8, but is always set.
+	Order binary.ByteOrder
+}
+
+// FrameSize returns the number of bytes per complex sample.
+func (f Format) FrameSize() int {
+	return 2 * f.Scalar.Size()
+}
+
+func (f Format) String() string {
+	var name string
+	switch f.Scalar {
+	case Int8:
+		return "cs8"
+	case Int16:
+		name = "cs16"
+	case Float32:
+		name = "cf32"
+	default:
+		return f.Scalar.String()
+	}
+	switch f.Order {
+	case binary.BigEndian:
+		return name + "be"
+	default:
+		return name + "le"
+	}
+}
+
+// Hints holds optional information about a file that helps Detect.
+// Zero values mean unknown.
+type Hints struct {
+	// SampleRate is the claimed sample rate in complex samples per
+	// second.
+	SampleRate float64
+	// Duration is the claimed duration of the capture.
+	Duration time.Duration
+}
+
+// Score is the score of a single candidate format.
+type Score struct {
+	Format Format
+	// Score is in the range [0,1], where larger is more likely.
+	Score float64
+}
+
+// Detection is the result of Detect.
+type Detection struct {
+	// Format is the most likely format.
+	Format Format
+	// Confidence is in the range [0,1]. See the package documentation.
+	Confidence float64
+	// Scores holds the score of every candidate format, ordered from
+	// most to least likely.
+	Scores []Score
+}
+
+// Detect guesses the format of a file of interleaved IQ samples with the
+// provided size by reading up to DetectWindow bytes from the beginning
+// of r. The hints argument provides optional information that improves
+// the guess. See the package documentation for the heuristics.
+//
+// It returns an error if r cannot be read or contains too little data,
+// even if fileSize is larger.
+// Otherwise, the result always names a format, even when the confidence
+// is low.
+func Detect(r io.ReaderAt, fileSize int64, hints Hints) (Detection, error) {
+	const minBytes = 64
+	var res Detection
+	n := fileSize
+	if n > DetectWindow {
+		n = DetectWindow
+	}
+	// Only analyze whole float32 frames.
+	n -= n % 8
+	if n < minBytes {
+		return res, fmt.Errorf("invalid file size: got %d bytes, want >= %d", fileSize, minBytes)
+	}
+	buf := make([]byte, n)
+	got, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return res, err
+	}
+	// The file may be shorter than fileSize claims, so only analyze
+	// the whole frames that were read.
+	buf = buf[:got-got%8]
+	if len(buf) < minBytes {
+		return res, fmt.Errorf("invalid read size: got %d bytes, want >= %d", got, minBytes)
+	}
+
+	var h [4]float64
+	for k := range h {
+		h[k] = byteEntropy(buf, k, 4)
+	}
+
+	candidates := []Format{
+		{Float32, binary.LittleEndian},
+		{Float32, binary.BigEndian},
+		{Int16, binary.LittleEndian},
+		{Int16, binary.BigEndian},
+		{Int8, binary.LittleEndian},
+	}
+	for _, f := range candidates {
+		var s float64
+		switch f.Scalar {
+		case Float32:
+			s = floatScore(buf, f.Order, h)
+		case Int16:
+			s = int16Score(h, f.Order)
+		case Int8:
+			s = int8Score(h)
+		}
+		s *= hintFactor(f, fileSize, hints)
+		res.Scores = append(res.Scores, Score{Format: f, Score: s})
+	}
+	sort.SliceStable(res.Scores, func(i, j int) bool {
+		return res.Scores[i].Score > res.Scores[j].Score
+	})
+
+	res.Format = res.Scores[0].Format
+	res.Confidence = res.Scores[0].Score - res.Scores[1].Score
+	return res, nil
+}
+
+// byteEntropy returns the Shannon entropy in bits of the bytes in b at
+// offsets that are equal to k modulo stride.
+func byteEntropy(b []byte, k, stride int) float64 {
+	var hist [256]int
+	var total int
+	for i := k; i < len(b); i += stride {
+		hist[b[i]]++
+		total++
+	}
+	var res float64
+	for _, c := range hist {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(total)
+		res -= p * math.Log2(p)
+	}
+	return res
+}
+
+// floatScore returns the fraction of plausible float32 values in b when
+// decoded with the provided byte order. A value is plausible if it is
+// zero or finite with a magnitude in a range that covers any reasonable
+// scaling of sample data. The fraction is reduced if the byte holding
+// the sign and most of the exponent has nearly the entropy of random
+// bytes, which is not the case for real sample data.
+func floatScore(b []byte, order binary.ByteOrder, h [4]float64) float64 {
+	var good int
+	num := len(b) / 4
+	for i := 0; i < num; i++ {
+		v := math.Abs(float64(math.Float32frombits(order.Uint32(b[i*4:]))))
+		if v == 0 || (v > 1e-20 && v < 1e9) {
+			good++
+		}
+	}
+	exp := h[3]
+	if order == binary.BigEndian {
+		exp = h[0]
+	}
+	return float64(good) / float64(num) * clamp01((8-exp)/1.5)
+}
+
+// clamp01 limits x to the range [0,1].
+func clamp01(x float64) float64 {
+	switch {
+	case x < 0:
+		return 0
+	case x > 1:
+		return 1
+	default:
+		return x
+	}
+}
+
+// int16Score scores the byte entropies at offsets modulo 4 for 16-bit
+// scalars. The I and Q scalars must have similar statistics and the most
+// significant bytes must have a lower entropy than the least significant
+// bytes.
+func int16Score(h [4]float64, order binary.ByteOrder) float64 {
+	consistency := clamp01(1 - (math.Abs(h[0]-h[2])+math.Abs(h[1]-h[3]))/2)
+	diff := (h[0] + h[2] - h[1] - h[3]) / 2
+	if order == binary.BigEndian {
+		diff = -diff
+	}
+	return consistency * clamp01(diff)
+}
+
+// int8Score scores the byte entropies at offsets modulo 4 for 8-bit
+// scalars. All offsets must have a similar entropy that is less than
+// that of uniformly random bytes.
+func int8Score(h [4]float64) float64 {
+	lo, hi, sum := h[0], h[0], 0.0
+	for _, v := range h {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+		sum += v
+	}
+	mean := sum / 4
+	return clamp01(1-(hi-lo)) * clamp01((7.9-mean)/0.5)
+}
+
+// hintFactor returns a factor in the range (0,1] that scales the score
+// of format f based on the file size and hints.
+func hintFactor(f Format, fileSize int64, hints Hints) float64 {
+	res := 1.0
+	frame := int64(f.FrameSize())
+	if fileSize%frame != 0 {
+		res *= 0.5
+	}
+	if hints.SampleRate > 0 && hints.Duration > 0 {
+		want := hints.SampleRate * hints.Duration.Seconds() * float64(frame)
+		if math.Abs(float64(fileSize)/want-1) > 0.05 {
+			res *= 0.25
+		}
+	}
+	return res
+}
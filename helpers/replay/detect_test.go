@@ -0,0 +1,183 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package replay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// testSignal returns numSamples of a tone plus noise with unit peak
+// amplitude.
+func testSignal(numSamples int) []complex128 {
+	rng := rand.New(rand.NewSource(1))
+	x := make([]complex128, numSamples)
+	for i := range x {
+		tone := cmplx.Rect(0.7, 2*math.Pi*0.01*float64(i))
+		noise := complex(rng.NormFloat64(), rng.NormFloat64()) * 0.1
+		x[i] = tone + noise
+	}
+	return x
+}
+
+// encode encodes x in the provided format with peak scaled to scale.
+func encode(x []complex128, f Format, scale float64) []byte {
+	var buf bytes.Buffer
+	for _, v := range x {
+		re, im := real(v)*scale, imag(v)*scale
+		switch f.Scalar {
+		case Int8:
+			buf.WriteByte(byte(int8(re)))
+			buf.WriteByte(byte(int8(im)))
+		case Int16:
+			binary.Write(&buf, f.Order, [2]int16{int16(re), int16(im)})
+		case Float32:
+			binary.Write(&buf, f.Order, [2]float32{float32(re), float32(im)})
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+
+	x := testSignal(100000)
+	specs := []struct {
+		f     Format
+		scale float64
+	}{
+		{Format{Float32, binary.LittleEndian}, 1},
+		{Format{Float32, binary.BigEndian}, 1},
+		{Format{Float32, binary.LittleEndian}, 2000},
+		{Format{Int16, binary.LittleEndian}, 2000},
+		{Format{Int16, binary.BigEndian}, 2000},
+		{Format{Int16, binary.LittleEndian}, 16000},
+		{Format{Int8, binary.LittleEndian}, 60},
+	}
+
+	for _, spec := range specs {
+		b := encode(x, spec.f, spec.scale)
+		d, err := Detect(bytes.NewReader(b), int64(len(b)), Hints{})
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %v", spec.f, err)
+		}
+		if d.Format != spec.f {
+			t.Errorf("wrong format for %v with scale %v: got %v, want %v (scores %v)", spec.f, spec.scale, d.Format, spec.f, d.Scores)
+		}
+		if d.Confidence < 0.5 {
+			t.Errorf("wrong confidence for %v with scale %v: got %.2f, want >= 0.5 (scores %v)", spec.f, spec.scale, d.Confidence, d.Scores)
+		}
+		if len(d.Scores) != 5 {
+			t.Errorf("wrong number of scores: got %d, want 5", len(d.Scores))
+		}
+
+		decode, err := d.NewDecodeFn()
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %v", spec.f, err)
+		}
+		y := decode(b)
+		if len(y) != len(x) {
+			t.Fatalf("wrong number of samples for %v: got %d, want %d", spec.f, len(y), len(x))
+		}
+		full := 1.0
+		switch spec.f.Scalar {
+		case Int8:
+			full = 128
+		case Int16:
+			full = 32768
+		}
+		for i := range x[:100] {
+			want := x[i] * complex(spec.scale/full, 0)
+			if cmplx.Abs(complex128(y[i])-want) > 2/full+1e-6*spec.scale {
+				t.Fatalf("wrong sample %d for %v: got %v, want %v", i, spec.f, y[i], want)
+			}
+		}
+	}
+}
+
+func TestDetectAmbiguous(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(2))
+	random := make([]byte, 1<<16)
+	rng.Read(random)
+
+	specs := []struct {
+		name string
+		b    []byte
+	}{
+		{"zeros", make([]byte, 1<<16)},
+		{"random", random},
+	}
+	for _, spec := range specs {
+		d, err := Detect(bytes.NewReader(spec.b), int64(len(spec.b)), Hints{})
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", spec.name, err)
+		}
+		if d.Confidence > 0.3 {
+			t.Errorf("wrong confidence for %s: got %.2f (%v), want <= 0.3", spec.name, d.Confidence, d.Format)
+		}
+	}
+
+	if _, err := Detect(bytes.NewReader(nil), 10, Hints{}); err == nil {
+		t.Error("unexpected success for short file")
+	}
+}
+
+func TestDetectHints(t *testing.T) {
+	t.Parallel()
+
+	x := testSignal(10000)
+	b := encode(x, Format{Int8, binary.LittleEndian}, 60)
+	plain, err := Detect(bytes.NewReader(b), int64(len(b)), Hints{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Matching hints do not change the result.
+	hints := Hints{SampleRate: 10000, Duration: time.Second}
+	d, err := Detect(bytes.NewReader(b), int64(len(b)), hints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Format != plain.Format || d.Confidence < plain.Confidence {
+		t.Errorf("wrong detection with matching hints: got %v (%.2f), want %v (>= %.2f)", d.Format, d.Confidence, plain.Format, plain.Confidence)
+	}
+
+	// Hints claiming twice the frame size reduce the confidence.
+	hints = Hints{SampleRate: 5000, Duration: time.Second}
+	d, err = Detect(bytes.NewReader(b), int64(len(b)), hints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Confidence >= plain.Confidence {
+		t.Errorf("wrong confidence with contradicting hints: got %.2f, want < %.2f", d.Confidence, plain.Confidence)
+	}
+}
+
+func TestDetectShortRead(t *testing.T) {
+	t.Parallel()
+
+	// The file is shorter than its claimed size (e.g. still being
+	// written), so only the data that was read is analyzed.
+	f := Format{Int16, binary.LittleEndian}
+	b := encode(testSignal(1000), f, 2000)
+	d, err := Detect(bytes.NewReader(b), 100*int64(len(b)), Hints{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Format != f || d.Confidence < 0.5 {
+		t.Errorf("wrong format: got %v with confidence %.2f, want %v (scores %v)", d.Format, d.Confidence, f, d.Scores)
+	}
+
+	if _, err := Detect(bytes.NewReader(b[:32]), int64(len(b)), Hints{}); err == nil {
+		t.Error("unexpected success for short read")
+	}
+}
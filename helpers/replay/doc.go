@@ -0,0 +1,41 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package replay provides tools for reading previously captured IQ sample
+files of unknown or undocumented format.
+
+Detect guesses the format of a headerless file of interleaved IQ
+samples. The supported formats are 8-bit and 16-bit signed integers and
+32-bit IEEE floating-point, in either byte order. Planar (non-interleaved)
+files are not detected. The guess is based on the following heuristics,
+applied to a window at the beginning of the file.
+
+Floating-point: real sample data interpreted with the correct byte order
+consists almost entirely of finite values of moderate magnitude. Any
+other data, including floating-point data with the wrong byte order,
+places effectively random bits in the exponent field and produces NaN,
+Inf, and extremely large or small values. The score of a floating-point
+format is the fraction of plausible values, reduced if the byte holding
+the exponent is nearly as random as the other bytes.
+
+Integers: the entropy of the bytes at each offset modulo 4 is measured.
+For 16-bit samples that do not span the full range, the most significant
+bytes are concentrated near 0x00 and 0xFF and have a lower entropy than
+the least significant bytes, which reveals the byte order. For 8-bit
+samples, every byte is a sample, so all offsets have a similar entropy
+that is below that of uniformly random bytes.
+
+Hints: a file size that is not a whole number of frames reduces the score
+of a format. If both a sample rate and a duration are known, a frame size
+that matches the file size is strongly preferred.
+
+The Confidence of a Detection is the difference between the scores of
+the best and the second best formats. It is close to one for clear cases
+and close to zero when two formats explain the data equally well (e.g.
+a file of all zeros or of uniformly random bytes). A confidence below
+0.5 should be treated as a guess to be confirmed by other means, such
+as inspecting a spectrum of the decoded samples.
+*/
+package replay
@@ -0,0 +1,18 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package sigmf implements creation of SigMF metadata files that describe
+raw IQ captures.
+
+A SigMF recording is a pair of files with the same base name: the raw
+samples in a .sigmf-data file and a JSON description in a .sigmf-meta
+file. This package only writes the small subset of the core namespace
+needed to describe a single continuous capture: the sample format and
+rate, the receiver location, and one capture segment with the center
+frequency and the time of the first sample.
+
+See https://github.com/sigmf/SigMF for the specification.
+*/
+package sigmf
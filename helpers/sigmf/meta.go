@@ -0,0 +1,140 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sigmf
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Version is the version of the SigMF specification written by this
+// package.
+const Version = "1.0.0"
+
+// DatetimeFormat is the ISO 8601 format of the core:datetime field.
+const DatetimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// Meta is the top level object of a .sigmf-meta file.
+type Meta struct {
+	Global      Global       `json:"global"`
+	Captures    []Capture    `json:"captures"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+// Global is the global object of a .sigmf-meta file.
+type Global struct {
+	Datatype    string   `json:"core:datatype"`
+	SampleRate  float64  `json:"core:sample_rate,omitempty"`
+	Version     string   `json:"core:version"`
+	Recorder    string   `json:"core:recorder,omitempty"`
+	HW          string   `json:"core:hw,omitempty"`
+	Geolocation *GeoJSON `json:"core:geolocation,omitempty"`
+}
+
+// GeoJSON is a GeoJSON point as used by the core:geolocation field.
+type GeoJSON struct {
+	Type string `json:"type"`
+	// Coordinates are the longitude and latitude in decimal degrees
+	// followed by the altitude in meters above the WGS 84 ellipsoid.
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// NewPoint creates a GeoJSON point from a latitude, longitude, and
+// altitude. Note that GeoJSON puts the longitude first.
+func NewPoint(lat, lon, alt float64) *GeoJSON {
+	return &GeoJSON{
+		Type:        "Point",
+		Coordinates: []float64{lon, lat, alt},
+	}
+}
+
+// Capture is a capture segment of a .sigmf-meta file.
+type Capture struct {
+	SampleStart uint64  `json:"core:sample_start"`
+	Frequency   float64 `json:"core:frequency,omitempty"`
+	// Datetime is the time of the sample at SampleStart formatted
+	// with DatetimeFormat.
+	Datetime string `json:"core:datetime,omitempty"`
+}
+
+// Annotation is an annotation segment of a .sigmf-meta file.
+type Annotation struct {
+	SampleStart uint64 `json:"core:sample_start"`
+	SampleCount uint64 `json:"core:sample_count,omitempty"`
+	Comment     string `json:"core:comment,omitempty"`
+}
+
+// FormatDatetime formats t in UTC for the core:datetime field.
+func FormatDatetime(t time.Time) string {
+	return t.UTC().Format(DatetimeFormat)
+}
+
+// ComplexDatatype returns the core:datatype value for complex samples
+// with the provided scalar type and size. The order argument is ignored
+// for 8-bit scalars.
+func ComplexDatatype(float bool, bytesPerScalar int, order binary.ByteOrder) (string, error) {
+	var kind string
+	switch {
+	case float && (bytesPerScalar == 4 || bytesPerScalar == 8):
+		kind = "cf"
+	case !float && (bytesPerScalar == 1 || bytesPerScalar == 2 || bytesPerScalar == 4):
+		kind = "ci"
+	default:
+		return "", fmt.Errorf("invalid scalar size: got %d bytes, want 1, 2, 4, or 8", bytesPerScalar)
+	}
+	res := fmt.Sprintf("%s%d", kind, bytesPerScalar*8)
+	if bytesPerScalar == 1 {
+		return res, nil
+	}
+	switch order {
+	case binary.BigEndian:
+		return res + "_be", nil
+	case binary.LittleEndian:
+		return res + "_le", nil
+	default:
+		return "", fmt.Errorf("invalid byte order: got %v, want big or little endian", order)
+	}
+}
+
+// MetaPath returns the path of the .sigmf-meta file that describes the
+// data file at dataPath. The extension of dataPath, if any, is replaced.
+func MetaPath(dataPath string) string {
+	return strings.TrimSuffix(dataPath, filepath.Ext(dataPath)) + ".sigmf-meta"
+}
+
+// Write writes m to w as indented JSON.
+func (m *Meta) Write(w io.Writer) error {
+	if m.Captures == nil {
+		m.Captures = []Capture{}
+	}
+	if m.Annotations == nil {
+		m.Annotations = []Annotation{}
+	}
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteFile creates or truncates the file at path and writes m to it.
+func (m *Meta) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = m.Write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sigmf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMetaWrite(t *testing.T) {
+	t.Parallel()
+
+	m := Meta{
+		Global: Global{
+			Datatype:    "ci16_le",
+			SampleRate:  2e6,
+			Version:     Version,
+			Geolocation: NewPoint(40.446195, -79.948862, 300),
+		},
+		Captures: []Capture{
+			{
+				Frequency: 100e6,
+				Datetime:  FormatDatetime(time.Date(2021, 3, 4, 5, 6, 7, 890, time.FixedZone("EST", -5*3600))),
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	global := got["global"].(map[string]interface{})
+	geo := global["core:geolocation"].(map[string]interface{})
+	if geo["type"] != "Point" {
+		t.Errorf("wrong geolocation type: got %v, want Point", geo["type"])
+	}
+	coords := geo["coordinates"].([]interface{})
+	want := []float64{-79.948862, 40.446195, 300}
+	if len(coords) != len(want) {
+		t.Fatalf("wrong number of coordinates: got %d, want %d", len(coords), len(want))
+	}
+	for i := range want {
+		if coords[i].(float64) != want[i] {
+			t.Errorf("wrong coordinate %d: got %v, want %v", i, coords[i], want[i])
+		}
+	}
+
+	captures := got["captures"].([]interface{})
+	capture := captures[0].(map[string]interface{})
+	if dt := capture["core:datetime"]; dt != "2021-03-04T10:06:07.000000890Z" {
+		t.Errorf("wrong datetime: got %v", dt)
+	}
+	if start, ok := capture["core:sample_start"]; !ok || start.(float64) != 0 {
+		t.Errorf("wrong sample start: got %v", start)
+	}
+	if ann, ok := got["annotations"].([]interface{}); !ok || len(ann) != 0 {
+		t.Errorf("wrong annotations: got %v, want []", got["annotations"])
+	}
+}
+
+func TestMetaNoGeolocation(t *testing.T) {
+	t.Parallel()
+
+	m := Meta{Global: Global{Datatype: "cf32_le", Version: Version}}
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("core:geolocation")) {
+		t.Errorf("unexpected geolocation: %s", buf.Bytes())
+	}
+}
+
+func TestComplexDatatype(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		float bool
+		size  int
+		order binary.ByteOrder
+		want  string
+	}{
+		{false, 1, binary.LittleEndian, "ci8"},
+		{false, 2, binary.LittleEndian, "ci16_le"},
+		{false, 2, binary.BigEndian, "ci16_be"},
+		{true, 4, binary.LittleEndian, "cf32_le"},
+		{true, 2, binary.LittleEndian, ""},
+		{false, 3, binary.LittleEndian, ""},
+	}
+	for i, spec := range specs {
+		got, err := ComplexDatatype(spec.float, spec.size, spec.order)
+		switch {
+		case spec.want == "" && err == nil:
+			t.Errorf("%d: unexpected success: %s", i, got)
+		case spec.want != "" && err != nil:
+			t.Errorf("%d: unexpected error: %v", i, err)
+		case got != spec.want:
+			t.Errorf("%d: wrong datatype: got %q, want %q", i, got, spec.want)
+		}
+	}
+}
+
+func TestMetaPath(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		path, want string
+	}{
+		{"rsp.sigmf-data", "rsp.sigmf-meta"},
+		{"dir/rsp.cs8", "dir/rsp.sigmf-meta"},
+		{"rsp", "rsp.sigmf-meta"},
+	}
+	for _, spec := range specs {
+		if got := MetaPath(spec.path); got != spec.want {
+			t.Errorf("wrong path for %s: got %s, want %s", spec.path, got, spec.want)
+		}
+	}
+}
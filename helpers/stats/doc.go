@@ -0,0 +1,10 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package stats provides streaming statistics estimators with constant
+memory use, suitable for long-running monitors of sample levels or SNR
+where storing and sorting every observation does not scale.
+*/
+package stats
@@ -0,0 +1,145 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PSquare estimates a single quantile of a stream of observations with
+// the P-square algorithm of Jain and Chlamtac. It keeps five markers
+// whose heights approximate the minimum, the p/2, p, and (1+p)/2
+// quantiles, and the maximum. Each observation adjusts the markers with
+// a piecewise-parabolic interpolation, so memory use and the cost per
+// observation are constant regardless of the number of observations.
+//
+// The estimate is exact for the first five observations. After that, it
+// is approximate, with an error that is typically a small fraction of
+// the spread of the distribution for smooth distributions.
+//
+// A PSquare is not safe for concurrent use.
+type PSquare struct {
+	p     float64
+	count int
+	// q is the marker heights.
+	q [5]float64
+	// n is the actual marker positions.
+	n [5]float64
+	// np is the desired marker positions.
+	np [5]float64
+	// dn is the increment of the desired marker positions.
+	dn [5]float64
+}
+
+// NewPSquare creates a new PSquare that estimates the p quantile, where
+// p is in the range (0,1) (e.g. 0.5 for the median).
+func NewPSquare(p float64) (*PSquare, error) {
+	if !(p > 0 && p < 1) {
+		return nil, fmt.Errorf("invalid quantile: got %v, want 0 < p < 1", p)
+	}
+	e := &PSquare{p: p}
+	e.Reset()
+	return e, nil
+}
+
+// Reset discards all observations.
+func (e *PSquare) Reset() {
+	p := e.p
+	e.count = 0
+	e.n = [5]float64{0, 1, 2, 3, 4}
+	e.np = [5]float64{0, 2 * p, 4 * p, 2 + 2*p, 4}
+	e.dn = [5]float64{0, p / 2, p, (1 + p) / 2, 1}
+}
+
+// Count returns the number of observations since creation or the last
+// call to Reset.
+func (e *PSquare) Count() int {
+	return e.count
+}
+
+// Add adds an observation.
+func (e *PSquare) Add(x float64) {
+	if e.count < 5 {
+		e.q[e.count] = x
+		e.count++
+		if e.count == 5 {
+			sort.Float64s(e.q[:])
+		}
+		return
+	}
+	e.count++
+
+	// Find the cell containing x and update the extreme markers.
+	var k int
+	switch {
+	case x < e.q[0]:
+		e.q[0] = x
+		k = 0
+	case x >= e.q[4]:
+		e.q[4] = x
+		k = 3
+	default:
+		for k = 0; k < 3; k++ {
+			if x < e.q[k+1] {
+				break
+			}
+		}
+	}
+	for i := k + 1; i < 5; i++ {
+		e.n[i]++
+	}
+	for i := range e.np {
+		e.np[i] += e.dn[i]
+	}
+
+	// Adjust the heights of the middle markers if necessary.
+	for i := 1; i < 4; i++ {
+		d := e.np[i] - e.n[i]
+		if (d >= 1 && e.n[i+1]-e.n[i] > 1) || (d <= -1 && e.n[i-1]-e.n[i] < -1) {
+			ds := math.Copysign(1, d)
+			q := e.parabolic(i, ds)
+			if !(e.q[i-1] < q && q < e.q[i+1]) {
+				q = e.linear(i, ds)
+			}
+			e.q[i] = q
+			e.n[i] += ds
+		}
+	}
+}
+
+// parabolic returns the piecewise-parabolic prediction of the height of
+// marker i after moving it by d.
+func (e *PSquare) parabolic(i int, d float64) float64 {
+	q, n := &e.q, &e.n
+	return q[i] + d/(n[i+1]-n[i-1])*
+		((n[i]-n[i-1]+d)*(q[i+1]-q[i])/(n[i+1]-n[i])+
+			(n[i+1]-n[i]-d)*(q[i]-q[i-1])/(n[i]-n[i-1]))
+}
+
+// linear returns the linear prediction of the height of marker i after
+// moving it by d.
+func (e *PSquare) linear(i int, d float64) float64 {
+	j := i + int(d)
+	return e.q[i] + d*(e.q[j]-e.q[i])/(e.n[j]-e.n[i])
+}
+
+// Value returns the current estimate of the quantile. It returns NaN if
+// there are no observations.
+func (e *PSquare) Value() float64 {
+	switch {
+	case e.count == 0:
+		return math.NaN()
+	case e.count < 5:
+		// Exact quantile of the few observations so far.
+		vals := make([]float64, e.count)
+		copy(vals, e.q[:e.count])
+		sort.Float64s(vals)
+		return vals[int(e.p*float64(e.count-1)+0.5)]
+	default:
+		return e.q[2]
+	}
+}
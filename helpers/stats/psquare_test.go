@@ -0,0 +1,167 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// exactQuantile returns the p quantile of sorted vals.
+func exactQuantile(sorted []float64, p float64) float64 {
+	return sorted[int(p*float64(len(sorted)-1)+0.5)]
+}
+
+func TestPSquare(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(1))
+	dists := []struct {
+		name string
+		gen  func() float64
+		// tol is the tolerance relative to the standard deviation.
+		tol float64
+	}{
+		{"normal", rng.NormFloat64, 0.02},
+		{"uniform", rng.Float64, 0.02},
+		// Level-like values in dB with a heavy tail.
+		{"exponential", rng.ExpFloat64, 0.03},
+	}
+
+	for _, dist := range dists {
+		vals := make([]float64, 100000)
+		for i := range vals {
+			vals[i] = dist.gen()
+		}
+		var mean, variance float64
+		for _, v := range vals {
+			mean += v
+		}
+		mean /= float64(len(vals))
+		for _, v := range vals {
+			variance += (v - mean) * (v - mean)
+		}
+		std := math.Sqrt(variance / float64(len(vals)))
+		sorted := append([]float64(nil), vals...)
+		sort.Float64s(sorted)
+
+		for _, p := range []float64{0.05, 0.25, 0.5, 0.75, 0.95, 0.99} {
+			e, err := NewPSquare(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range vals {
+				e.Add(v)
+			}
+			want := exactQuantile(sorted, p)
+			if got := e.Value(); math.Abs(got-want) > dist.tol*std {
+				t.Errorf("wrong %s p=%v: got %v, want %v±%v", dist.name, p, got, want, dist.tol*std)
+			}
+			if e.Count() != len(vals) {
+				t.Errorf("wrong count: got %d, want %d", e.Count(), len(vals))
+			}
+		}
+	}
+}
+
+func TestPSquareFew(t *testing.T) {
+	t.Parallel()
+
+	e, err := NewPSquare(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := e.Value(); !math.IsNaN(v) {
+		t.Errorf("wrong empty value: got %v, want NaN", v)
+	}
+	for i, want := range []float64{5, 5, 3, 5, 3} {
+		e.Add([]float64{5, 1, 3, 9, 2}[i])
+		if got := e.Value(); got != want {
+			t.Errorf("wrong value after %d: got %v, want %v", i+1, got, want)
+		}
+	}
+	e.Reset()
+	if e.Count() != 0 || !math.IsNaN(e.Value()) {
+		t.Errorf("wrong state after reset: count=%d value=%v", e.Count(), e.Value())
+	}
+
+	for _, p := range []float64{0, 1, -0.5, math.NaN()} {
+		if _, err := NewPSquare(p); err == nil {
+			t.Errorf("unexpected success for %v", p)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(2))
+	const window = 10000
+
+	w, err := NewPercentiles(window, 0.1, 0.5, 0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := NewPercentiles(0, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The level jumps from 0 to 10 after 5 windows.
+	for i := 0; i < 10*window; i++ {
+		v := rng.NormFloat64()
+		if i >= 5*window {
+			v += 10
+		}
+		w.Add(v)
+		all.Add(v)
+
+		if i == 5*window+window-1 {
+			// One window after the jump, the estimates only cover
+			// the new level.
+			want := []float64{10 - 1.2816, 10, 10 + 1.2816}
+			for j, got := range w.Values() {
+				if math.Abs(got-want[j]) > 0.1 {
+					t.Errorf("wrong quantile %v after jump: got %v, want %v", w.Quantiles()[j], got, want[j])
+				}
+			}
+		}
+		if n := w.Count(); i >= window && (n < window/2 || n > window) {
+			t.Fatalf("wrong count at %d: got %d, want %d..%d", i, n, window/2, window)
+		}
+	}
+
+	// The cumulative median is between the levels.
+	if got := all.Value(0); got < 1 || got > 9 {
+		t.Errorf("wrong cumulative median: got %v, want between levels", got)
+	}
+	if all.Count() != 10*window {
+		t.Errorf("wrong cumulative count: got %d, want %d", all.Count(), 10*window)
+	}
+	if !math.IsNaN(w.Value(3)) {
+		t.Error("wrong value for out of range index")
+	}
+
+	w.Reset()
+	if w.Count() != 0 {
+		t.Errorf("wrong count after reset: got %d", w.Count())
+	}
+
+	specs := []struct {
+		window int
+		ps     []float64
+	}{
+		{100, nil},
+		{5, []float64{0.5}},
+		{100, []float64{0.5, 1}},
+	}
+	for _, spec := range specs {
+		if _, err := NewPercentiles(spec.window, spec.ps...); err == nil {
+			t.Errorf("unexpected success for window=%d ps=%v", spec.window, spec.ps)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Percentiles estimates several quantiles of a stream of observations
+// over an approximate sliding window with a set of PSquare estimators.
+//
+// A true sliding window would require storing every observation in the
+// window. Instead, two sets of estimators run staggered by half of the
+// window and each set is reset after it has seen a full window of
+// observations. The estimates are taken from the set with more
+// observations, so they always cover between the most recent half
+// window and full window of observations. This follows changes in the
+// distribution within one window while the memory use stays constant.
+//
+// A Percentiles is not safe for concurrent use.
+type Percentiles struct {
+	ps     []float64
+	window int
+	sets   [2][]*PSquare
+	total  int
+}
+
+// NewPercentiles creates a new Percentiles for the quantiles ps, each
+// in the range (0,1). The window argument is the number of observations
+// in the sliding window. If window is zero, the estimates cover all
+// observations since creation or the last call to Reset. Otherwise, it
+// must be at least 10.
+func NewPercentiles(window int, ps ...float64) (*Percentiles, error) {
+	if len(ps) == 0 {
+		return nil, errors.New("missing quantiles")
+	}
+	if window != 0 && window < 10 {
+		return nil, fmt.Errorf("invalid window: got %d, want 0 or >= 10", window)
+	}
+	res := &Percentiles{
+		ps:     append([]float64(nil), ps...),
+		window: window,
+	}
+	for s := range res.sets {
+		for _, p := range ps {
+			e, err := NewPSquare(p)
+			if err != nil {
+				return nil, err
+			}
+			res.sets[s] = append(res.sets[s], e)
+		}
+	}
+	return res, nil
+}
+
+// Quantiles returns the quantiles provided to NewPercentiles.
+func (w *Percentiles) Quantiles() []float64 {
+	return append([]float64(nil), w.ps...)
+}
+
+// Add adds an observation.
+func (w *Percentiles) Add(x float64) {
+	for s, set := range w.sets {
+		if s == 1 && (w.window == 0 || w.total < w.window/2) {
+			// The second set is only used with a window and starts
+			// half a window late.
+			continue
+		}
+		if w.window > 0 && set[0].Count() == w.window {
+			for _, e := range set {
+				e.Reset()
+			}
+		}
+		for _, e := range set {
+			e.Add(x)
+		}
+	}
+	w.total++
+}
+
+// current returns the set with the most observations.
+func (w *Percentiles) current() []*PSquare {
+	if w.sets[1][0].Count() > w.sets[0][0].Count() {
+		return w.sets[1]
+	}
+	return w.sets[0]
+}
+
+// Count returns the number of observations covered by the estimates.
+func (w *Percentiles) Count() int {
+	return w.current()[0].Count()
+}
+
+// Value returns the estimate of the i-th quantile provided to
+// NewPercentiles. It returns NaN if there are no observations or i is
+// out of range.
+func (w *Percentiles) Value(i int) float64 {
+	set := w.current()
+	if i < 0 || i >= len(set) {
+		return math.NaN()
+	}
+	return set[i].Value()
+}
+
+// Values returns the estimates of all quantiles in the order they were
+// provided to NewPercentiles.
+func (w *Percentiles) Values() []float64 {
+	set := w.current()
+	res := make([]float64, len(set))
+	for i, e := range set {
+		res[i] = e.Value()
+	}
+	return res
+}
+
+// Reset discards all observations.
+func (w *Percentiles) Reset() {
+	for _, set := range w.sets {
+		for _, e := range set {
+			e.Reset()
+		}
+	}
+	w.total = 0
+}
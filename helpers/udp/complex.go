@@ -0,0 +1,162 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package udp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Complex64PacketWriteFn is a function type that writes the provided
+// complex samples to a packet buffer and, when the packet is filled,
+// writes the packet to the provided io.Writer. Each complex sample is
+// encoded as two IEEE 754 float32 values, real then imaginary. It returns
+// the number of bytes written to the packet buffer. Like PacketWriteFn,
+// it may write zero, one, or multiple packets to the io.Writer.
+//
+// samplesPerFrame is the number of complex samples per frame. That is,
+// a single channel is 1 sample per frame and interleaved samples from
+// both RSPduo channels is 2 samples per frame.
+//
+// See PacketWriteFn for a description of the remaining arguments.
+type Complex64PacketWriteFn func(out io.Writer, x []complex64) (int, error)
+
+// NewComplex64PacketWriteFn creates a new Complex64PacketWriteFn. It is
+// a shortcut for creating a Complex64PacketWriter and using its Write
+// method. Use a Complex64PacketWriter directly to be able to call Flush.
+func NewComplex64PacketWriteFn(payloadLen, samplesPerFrame uint, seqHeader bool, order binary.ByteOrder) (Complex64PacketWriteFn, error) {
+	w, err := NewComplex64PacketWriter(payloadLen, samplesPerFrame, seqHeader, order)
+	if err != nil {
+		return nil, err
+	}
+	return w.Write, nil
+}
+
+// Complex64PacketWriter is a stateful packetizer for complex samples.
+// Its Write method implements Complex64PacketWriteFn. Like PacketWriter,
+// it can Flush a partially filled packet.
+type Complex64PacketWriter struct {
+	packetBuffer
+	samplesPerFrame int
+}
+
+// NewComplex64PacketWriter creates a new Complex64PacketWriter. See
+// Complex64PacketWriteFn for a description of the arguments.
+func NewComplex64PacketWriter(payloadLen, samplesPerFrame uint, seqHeader bool, order binary.ByteOrder) (*Complex64PacketWriter, error) {
+	const (
+		sizeofSample = 8
+		sizeofHeader = 8
+	)
+	if samplesPerFrame == 0 {
+		return nil, fmt.Errorf("invalid samplesPerFrame: got %d, want > 0", samplesPerFrame)
+	}
+	dataBytes := int(payloadLen)
+	if seqHeader {
+		dataBytes -= sizeofHeader
+	}
+	if dataBytes <= 0 {
+		return nil, fmt.Errorf("payload has no room for samples: payloadLen=%d seqHeader=%v", payloadLen, seqHeader)
+	}
+	if dataBytes%(sizeofSample*int(samplesPerFrame)) != 0 {
+		return nil, fmt.Errorf(
+			"frames will not fit evenly in payload: payloadLen=%d seqHeader=%v samplesPerFrame=%d",
+			payloadLen, seqHeader, samplesPerFrame,
+		)
+	}
+	return &Complex64PacketWriter{
+		packetBuffer:    newPacketBuffer(payloadLen, seqHeader, order),
+		samplesPerFrame: int(samplesPerFrame),
+	}, nil
+}
+
+// Write implements Complex64PacketWriteFn.
+func (w *Complex64PacketWriter) Write(out io.Writer, x []complex64) (int, error) {
+	const (
+		sizeofScalar = 4
+		sizeofSample = 2 * sizeofScalar
+	)
+	if len(x)%w.samplesPerFrame != 0 {
+		return 0, fmt.Errorf("invalid number of samples: got %d, want multiple of %d", len(x), w.samplesPerFrame)
+	}
+	var total int
+	for i := range x {
+		w.order.PutUint32(w.buf[w.bi:], math.Float32bits(real(x[i])))
+		w.order.PutUint32(w.buf[w.bi+sizeofScalar:], math.Float32bits(imag(x[i])))
+		total += sizeofSample
+		w.bi += sizeofSample
+		if w.bi == len(w.buf) {
+			if err := w.emit(out); err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// Flush writes the partially filled packet, if any, to out. See
+// PacketWriter.Flush.
+func (w *Complex64PacketWriter) Flush(out io.Writer, pad bool) (int, error) {
+	return w.flush(out, pad)
+}
+
+// Complex64PacketReadFn is a function type that decodes a single packet
+// written by a Complex64PacketWriteFn. It returns the sequence number,
+// or zero if the packet has no sequence header, and the complex samples.
+// It returns a non-nil error if the packet is not the expected length.
+type Complex64PacketReadFn func(packet []byte) (seq uint64, x []complex64, err error)
+
+// NewComplex64PacketReadFn creates a new Complex64PacketReadFn that
+// decodes packets created by a Complex64PacketWriteFn with the same
+// payloadLen, seqHeader, and order arguments.
+//
+// The function uses an internal persistent buffer to minimize allocations.
+// The returned slice is a slice of that internal buffer and should not
+// be modified or stored.
+func NewComplex64PacketReadFn(payloadLen uint, seqHeader bool, order binary.ByteOrder) (Complex64PacketReadFn, error) {
+	const (
+		sizeofScalar = 4
+		sizeofSample = 2 * sizeofScalar
+		sizeofHeader = 8
+	)
+	dataBytes := int(payloadLen)
+	off := 0
+	if seqHeader {
+		dataBytes -= sizeofHeader
+		off = sizeofHeader
+	}
+	if dataBytes <= 0 {
+		return nil, fmt.Errorf("payload has no room for samples: payloadLen=%d seqHeader=%v", payloadLen, seqHeader)
+	}
+	if dataBytes%sizeofSample != 0 {
+		return nil, fmt.Errorf(
+			"samples will not fit evenly in payload: payloadLen=%d seqHeader=%v",
+			payloadLen, seqHeader,
+		)
+	}
+
+	buf := make([]complex64, dataBytes/sizeofSample)
+
+	read := func(packet []byte) (uint64, []complex64, error) {
+		if len(packet) != int(payloadLen) {
+			return 0, nil, fmt.Errorf("wrong packet length: got %d, want %d", len(packet), payloadLen)
+		}
+		var seq uint64
+		if seqHeader {
+			seq = order.Uint64(packet)
+		}
+		for i := range buf {
+			bi := off + i*sizeofSample
+			buf[i] = complex(
+				math.Float32frombits(order.Uint32(packet[bi:])),
+				math.Float32frombits(order.Uint32(packet[bi+sizeofScalar:])),
+			)
+		}
+		return seq, buf, nil
+	}
+
+	return read, nil
+}
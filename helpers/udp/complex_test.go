@@ -0,0 +1,135 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package udp
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestComplex64PacketRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		payloadLen      uint
+		samplesPerFrame uint
+		seqHeader       bool
+		order           binary.ByteOrder
+	}{
+		{1024, 1, false, binary.LittleEndian},
+		{1032, 1, true, binary.LittleEndian},
+		{1032, 2, true, binary.BigEndian},
+		{16, 1, true, binary.BigEndian},
+	}
+
+	for _, spec := range specs {
+		write, err := NewComplex64PacketWriteFn(spec.payloadLen, spec.samplesPerFrame, spec.seqHeader, spec.order)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		read, err := NewComplex64PacketReadFn(spec.payloadLen, spec.seqHeader, spec.order)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		const numSamples = 10000
+		x := make([]complex64, numSamples)
+		for i := range x {
+			x[i] = complex(rand.Float32()*2-1, rand.Float32()*2-1)
+		}
+
+		rec := &packetRecorder{}
+		// Write in uneven chunks to exercise partial packets.
+		step := int(spec.samplesPerFrame)
+		for start := 0; start < numSamples; {
+			end := start + (rand.Intn(700)+1)*step
+			if end > numSamples {
+				end = numSamples
+			}
+			n, err := write(rec, x[start:end])
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n != (end-start)*8 {
+				t.Fatalf("wrong number of bytes written: got %d, want %d", n, (end-start)*8)
+			}
+			start = end
+		}
+
+		off := 0
+		if spec.seqHeader {
+			off = 8
+		}
+		samplesPerPacket := (int(spec.payloadLen) - off) / 8
+		if want := numSamples / samplesPerPacket; len(rec.packets) != want {
+			t.Fatalf("wrong number of packets: got %d, want %d", len(rec.packets), want)
+		}
+		for p, packet := range rec.packets {
+			if spec.seqHeader {
+				if seq := spec.order.Uint64(packet); seq != uint64(p) {
+					t.Errorf("wrong raw sequence number: got %d, want %d", seq, p)
+				}
+			}
+			// Verify the real component precedes the imaginary component.
+			first := x[p*samplesPerPacket]
+			if v := math.Float32frombits(spec.order.Uint32(packet[off:])); v != real(first) {
+				t.Errorf("wrong first real scalar: got %v, want %v", v, real(first))
+			}
+			if v := math.Float32frombits(spec.order.Uint32(packet[off+4:])); v != imag(first) {
+				t.Errorf("wrong first imag scalar: got %v, want %v", v, imag(first))
+			}
+
+			seq, got, err := read(packet)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if spec.seqHeader && seq != uint64(p) {
+				t.Errorf("wrong sequence number: got %d, want %d", seq, p)
+			}
+			for j := range got {
+				k := p*samplesPerPacket + j
+				if got[j] != x[k] {
+					t.Fatalf("wrong sample %d: got %v, want %v", k, got[j], x[k])
+				}
+			}
+		}
+
+		if _, _, err := read(make([]byte, spec.payloadLen-1)); err == nil {
+			t.Error("unexpected success on short packet")
+		}
+	}
+}
+
+func TestComplex64PacketInvalid(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		payloadLen      uint
+		samplesPerFrame uint
+		seqHeader       bool
+	}{
+		{0, 1, false},
+		{8, 1, true},
+		{4, 1, true},
+		{1028, 1, false},
+		{1032, 2, false},
+		{1024, 0, false},
+	}
+	for _, spec := range specs {
+		if _, err := NewComplex64PacketWriteFn(spec.payloadLen, spec.samplesPerFrame, spec.seqHeader, binary.LittleEndian); err == nil {
+			t.Errorf("unexpected success for %+v", spec)
+		}
+	}
+
+	write, err := NewComplex64PacketWriteFn(1024, 2, false, binary.LittleEndian)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := write(&packetRecorder{}, make([]complex64, 3)); err == nil {
+		t.Error("unexpected success on partial frame")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package udp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// PlanarPacketWriteFn is a function type that writes the provided
+// component samples to a packet buffer in planar (non-interleaved) form
+// and, when the packet is filled, writes the packet to the provided
+// io.Writer. The xi slice contains the real component and the xq slice
+// contains the imaginary component. If the lengths differ, the trailing
+// samples of the longer slice are discarded. It returns the number of
+// sample bytes written to the packet buffer. Like PacketWriteFn, it may
+// write zero, one, or multiple packets to the io.Writer. If writing a
+// packet fails, it returns the error and the packet is dropped, so the
+// next call starts a new packet.
+//
+// Each packet carries a block of N complex samples laid out as:
+//
+// 	[seq uint64 (optional)][I1 ... IN int16][Q1 ... QN int16]
+//
+// where N is the payload length, less the optional 8-byte sequence
+// header, divided by 4 bytes per complex sample. All values are encoded
+// with the same byte order.
+type PlanarPacketWriteFn func(out io.Writer, xi, xq []int16) (int, error)
+
+// planarFramesPerPacket validates the payload length and returns the
+// number of complex samples in each planar packet.
+func planarFramesPerPacket(payloadLen uint, seqHeader bool) (int, error) {
+	const (
+		sizeofScalar = 2
+		sizeofHeader = 8
+		sizeofFrame  = 2 * sizeofScalar
+	)
+	dataBytes := int(payloadLen)
+	if seqHeader {
+		dataBytes -= sizeofHeader
+	}
+	if dataBytes <= 0 {
+		return 0, fmt.Errorf("payload has no room for samples: payloadLen=%d seqHeader=%v", payloadLen, seqHeader)
+	}
+	if dataBytes%sizeofFrame != 0 {
+		return 0, fmt.Errorf(
+			"planar blocks will not fit evenly in payload: payloadLen=%d seqHeader=%v",
+			payloadLen, seqHeader,
+		)
+	}
+	return dataBytes / sizeofFrame, nil
+}
+
+// NewPlanarPacketWriteFn creates a new PlanarPacketWriteFn. See
+// NewPacketWriteFn for a description of the arguments.
+func NewPlanarPacketWriteFn(payloadLen uint, seqHeader bool, order binary.ByteOrder) (PlanarPacketWriteFn, error) {
+	const (
+		sizeofScalar = 2
+		sizeofHeader = 8
+	)
+	numFrames, err := planarFramesPerPacket(payloadLen, seqHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		seq  uint64
+		buf  = make([]byte, int(payloadLen))
+		iOff int
+		qOff int
+		fi   int
+	)
+	if seqHeader {
+		iOff = sizeofHeader
+	}
+	qOff = iOff + numFrames*sizeofScalar
+
+	write := func(out io.Writer, xi, xq []int16) (int, error) {
+		minLen := len(xi)
+		if len(xq) < minLen {
+			minLen = len(xq)
+		}
+		var total int
+		for i := 0; i < minLen; i++ {
+			order.PutUint16(buf[iOff+fi*sizeofScalar:], uint16(xi[i]))
+			order.PutUint16(buf[qOff+fi*sizeofScalar:], uint16(xq[i]))
+			total += 2 * sizeofScalar
+			fi++
+			if fi == numFrames {
+				if seqHeader {
+					order.PutUint64(buf, seq)
+					seq++
+				}
+				// Like packetBuffer.emit, start the next packet even if
+				// the write fails, so the failed packet is dropped.
+				_, err := out.Write(buf)
+				fi = 0
+				if err != nil {
+					return total, err
+				}
+			}
+		}
+		return total, nil
+	}
+
+	return write, nil
+}
+
+// PlanarPacketReadFn is a function type that decodes a single packet
+// written by a PlanarPacketWriteFn. It returns the sequence number, or
+// zero if the packet has no sequence header, and the component samples.
+// It returns a non-nil error if the packet is not the expected length.
+type PlanarPacketReadFn func(packet []byte) (seq uint64, xi, xq []int16, err error)
+
+// NewPlanarPacketReadFn creates a new PlanarPacketReadFn that decodes
+// packets created by a PlanarPacketWriteFn with the same arguments.
+//
+// The function uses internal persistent buffers to minimize allocations.
+// The returned slices are slices of those internal buffers and should
+// not be modified or stored.
+func NewPlanarPacketReadFn(payloadLen uint, seqHeader bool, order binary.ByteOrder) (PlanarPacketReadFn, error) {
+	const (
+		sizeofScalar = 2
+		sizeofHeader = 8
+	)
+	numFrames, err := planarFramesPerPacket(payloadLen, seqHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		bufI = make([]int16, numFrames)
+		bufQ = make([]int16, numFrames)
+		iOff int
+	)
+	if seqHeader {
+		iOff = sizeofHeader
+	}
+	qOff := iOff + numFrames*sizeofScalar
+
+	read := func(packet []byte) (uint64, []int16, []int16, error) {
+		if len(packet) != int(payloadLen) {
+			return 0, nil, nil, fmt.Errorf("wrong packet length: got %d, want %d", len(packet), payloadLen)
+		}
+		var seq uint64
+		if seqHeader {
+			seq = order.Uint64(packet)
+		}
+		for i := 0; i < numFrames; i++ {
+			bufI[i] = int16(order.Uint16(packet[iOff+i*sizeofScalar:]))
+			bufQ[i] = int16(order.Uint16(packet[qOff+i*sizeofScalar:]))
+		}
+		return seq, bufI, bufQ, nil
+	}
+
+	return read, nil
+}
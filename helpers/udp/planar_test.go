@@ -0,0 +1,173 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package udp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// packetRecorder is an io.Writer that stores a copy of each write
+// as a separate packet.
+type packetRecorder struct {
+	packets [][]byte
+}
+
+func (r *packetRecorder) Write(b []byte) (int, error) {
+	r.packets = append(r.packets, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func TestPlanarPacketRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		payloadLen uint
+		seqHeader  bool
+		order      binary.ByteOrder
+	}{
+		{1024, false, binary.LittleEndian},
+		{1032, true, binary.LittleEndian},
+		{1032, true, binary.BigEndian},
+		{12, true, binary.BigEndian},
+	}
+
+	for _, spec := range specs {
+		write, err := NewPlanarPacketWriteFn(spec.payloadLen, spec.seqHeader, spec.order)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		read, err := NewPlanarPacketReadFn(spec.payloadLen, spec.seqHeader, spec.order)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		const numSamples = 10000
+		xi := make([]int16, numSamples)
+		xq := make([]int16, numSamples)
+		for i := range xi {
+			xi[i] = int16(rand.Int())
+			xq[i] = int16(rand.Int())
+		}
+
+		rec := &packetRecorder{}
+		// Write in uneven chunks to exercise partial packets.
+		for start := 0; start < numSamples; {
+			end := start + rand.Intn(700) + 1
+			if end > numSamples {
+				end = numSamples
+			}
+			n, err := write(rec, xi[start:end], xq[start:end])
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n != (end-start)*4 {
+				t.Fatalf("wrong number of bytes written: got %d, want %d", n, (end-start)*4)
+			}
+			start = end
+		}
+
+		framesPerPacket, _ := planarFramesPerPacket(spec.payloadLen, spec.seqHeader)
+		if want := numSamples / framesPerPacket; len(rec.packets) != want {
+			t.Fatalf("wrong number of packets: got %d, want %d", len(rec.packets), want)
+		}
+		for p, packet := range rec.packets {
+			seq, gi, gq, err := read(packet)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if spec.seqHeader && seq != uint64(p) {
+				t.Errorf("wrong sequence number: got %d, want %d", seq, p)
+			}
+			// Verify the I block precedes the Q block in the raw bytes.
+			off := 0
+			if spec.seqHeader {
+				off = 8
+			}
+			if v := int16(spec.order.Uint16(packet[off:])); v != xi[p*framesPerPacket] {
+				t.Errorf("wrong first I scalar: got %d, want %d", v, xi[p*framesPerPacket])
+			}
+			for j := range gi {
+				k := p*framesPerPacket + j
+				if gi[j] != xi[k] || gq[j] != xq[k] {
+					t.Fatalf("wrong sample %d: got (%d,%d), want (%d,%d)", k, gi[j], gq[j], xi[k], xq[k])
+				}
+			}
+		}
+
+		if _, _, _, err := read(make([]byte, spec.payloadLen-1)); err == nil {
+			t.Error("unexpected success on short packet")
+		}
+	}
+}
+
+// failWriter is an io.Writer that fails every write.
+type failWriter struct{}
+
+func (failWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestPlanarPacketWriteError(t *testing.T) {
+	t.Parallel()
+
+	const payloadLen = 16
+	write, err := NewPlanarPacketWriteFn(payloadLen, false, binary.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := NewPlanarPacketReadFn(payloadLen, false, binary.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed packet is dropped and the next write starts a new one.
+	xi := []int16{1, 2, 3, 4, 5, 6, 7, 8}
+	xq := []int16{-1, -2, -3, -4, -5, -6, -7, -8}
+	if _, err := write(failWriter{}, xi[:4], xq[:4]); err == nil {
+		t.Fatal("unexpected success with failing writer")
+	}
+	var rec packetRecorder
+	n, err := write(&rec, xi[4:], xq[4:])
+	if err != nil {
+		t.Fatalf("unexpected error after failed write: %v", err)
+	}
+	if n != 16 {
+		t.Errorf("wrong number of bytes: got %d, want 16", n)
+	}
+	if len(rec.packets) != 1 {
+		t.Fatalf("wrong number of packets: got %d, want 1", len(rec.packets))
+	}
+	_, gotI, gotQ, err := read(rec.packets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range gotI {
+		if gotI[i] != xi[4+i] || gotQ[i] != xq[4+i] {
+			t.Errorf("wrong sample %d: got (%d,%d), want (%d,%d)", i, gotI[i], gotQ[i], xi[4+i], xq[4+i])
+		}
+	}
+}
+
+func TestPlanarPacketInvalid(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		payloadLen uint
+		seqHeader  bool
+	}{
+		{0, false},
+		{8, true},
+		{4, true},
+		{1026, false},
+	}
+	for _, spec := range specs {
+		if _, err := NewPlanarPacketWriteFn(spec.payloadLen, spec.seqHeader, binary.LittleEndian); err == nil {
+			t.Errorf("unexpected success for payloadLen=%d seqHeader=%v", spec.payloadLen, spec.seqHeader)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package udp
+
+// SeqStats holds the counters of a SeqTracker.
+type SeqStats struct {
+	// Received is the number of distinct packets received.
+	Received uint64
+	// Lost is the number of packets declared lost because they did not
+	// arrive within the reordering window.
+	Lost uint64
+	// Reordered is the number of packets that arrived after a packet
+	// with a higher sequence number, but within the reordering window.
+	Reordered uint64
+	// Duplicates is the number of packets received more than once
+	// within the reordering window.
+	Duplicates uint64
+	// Late is the number of packets that arrived after the reordering
+	// window passed. These are either duplicates or packets that have
+	// already been counted as lost.
+	Late uint64
+}
+
+// SeqTracker detects packet loss from the sequence numbers returned by
+// the packet read functions (e.g. PlanarPacketReadFn). A naive gap count
+// assumes in-order delivery and over-reports loss on links that reorder
+// packets, such as wireless networks. A SeqTracker instead tolerates
+// packets that arrive up to window packets late. A missing packet is
+// only declared lost once a packet with a sequence number more than
+// window higher has arrived.
+//
+// A SeqTracker is not safe for concurrent use.
+type SeqTracker struct {
+	window   uint64
+	received []bool
+	started  bool
+	next     uint64
+	highest  uint64
+	stats    SeqStats
+}
+
+// NewSeqTracker creates a new SeqTracker with the provided reordering
+// window in packets. A window of zero assumes in-order delivery and
+// reports every gap as loss immediately.
+func NewSeqTracker(window uint) *SeqTracker {
+	return &SeqTracker{
+		window:   uint64(window),
+		received: make([]bool, window+1),
+	}
+}
+
+// Add records the arrival of the packet with sequence number seq. It
+// returns the number of packets newly declared lost as a result. The
+// first call to Add after creation or Reset establishes the start of
+// the sequence.
+func (t *SeqTracker) Add(seq uint64) uint64 {
+	if !t.started {
+		t.started = true
+		t.next = seq + 1
+		t.highest = seq
+		t.stats.Received++
+		return 0
+	}
+	if seq < t.next {
+		t.stats.Late++
+		return 0
+	}
+
+	// Declare lost every missing packet that is now more than window
+	// packets behind seq.
+	var lost uint64
+	size := uint64(len(t.received))
+	if seq-t.next > t.window {
+		target := seq - t.window
+		if target-t.next > size {
+			// Large jump, every slot in the ring is passed.
+			var n uint64
+			for i, r := range t.received {
+				if r {
+					n++
+					t.received[i] = false
+				}
+			}
+			lost = target - t.next - n
+			t.next = target
+		}
+		for ; t.next < target; t.next++ {
+			i := t.next % size
+			switch t.received[i] {
+			case true:
+				t.received[i] = false
+			default:
+				lost++
+			}
+		}
+	}
+
+	i := seq % size
+	switch {
+	case t.received[i]:
+		t.stats.Duplicates++
+	default:
+		t.received[i] = true
+		t.stats.Received++
+		if seq < t.highest {
+			t.stats.Reordered++
+		}
+	}
+	if seq > t.highest {
+		t.highest = seq
+	}
+	t.drain()
+	t.stats.Lost += lost
+	return lost
+}
+
+// drain advances past all consecutive received packets.
+func (t *SeqTracker) drain() {
+	size := uint64(len(t.received))
+	for t.received[t.next%size] {
+		t.received[t.next%size] = false
+		t.next++
+	}
+}
+
+// Flush declares lost every packet missing up to the highest sequence
+// number received without waiting for the reordering window to pass. It
+// returns the number of packets newly declared lost. It should be called
+// when the stream ends.
+func (t *SeqTracker) Flush() uint64 {
+	if !t.started {
+		return 0
+	}
+	var lost uint64
+	size := uint64(len(t.received))
+	for ; t.next <= t.highest; t.next++ {
+		i := t.next % size
+		switch t.received[i] {
+		case true:
+			t.received[i] = false
+		default:
+			lost++
+		}
+	}
+	t.stats.Lost += lost
+	return lost
+}
+
+// Stats returns the current counters.
+func (t *SeqTracker) Stats() SeqStats {
+	return t.stats
+}
+
+// Reset clears the counters and the state so that the next call to Add
+// starts a new sequence.
+func (t *SeqTracker) Reset() {
+	for i := range t.received {
+		t.received[i] = false
+	}
+	t.started = false
+	t.stats = SeqStats{}
+}
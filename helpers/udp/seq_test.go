@@ -0,0 +1,97 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package udp
+
+import (
+	"testing"
+)
+
+func TestSeqTracker(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		name   string
+		window uint
+		seqs   []uint64
+		// lost is the total reported by Add before Flush.
+		lost uint64
+		want SeqStats
+	}{
+		{"in order", 0, []uint64{5, 6, 7, 8}, 0, SeqStats{Received: 4}},
+		{"in order with window", 4, []uint64{5, 6, 7, 8}, 0, SeqStats{Received: 4}},
+		// Reordered but complete, so no loss with a window.
+		{
+			"reordered", 3,
+			[]uint64{0, 2, 1, 3, 6, 4, 5, 7, 10, 9, 8, 11, 12, 13, 14},
+			0,
+			SeqStats{Received: 15, Reordered: 5},
+		},
+		// The same sequence over-reports loss without a window.
+		{
+			"reordered no window", 0,
+			[]uint64{0, 2, 1, 3, 6, 4, 5, 7, 10, 9, 8, 11, 12, 13, 14},
+			5,
+			SeqStats{Received: 10, Lost: 5, Late: 5},
+		},
+		// Genuine drops are reported once the window passes.
+		{
+			"dropped", 2,
+			[]uint64{0, 1, 3, 4, 5, 6, 8, 9, 10, 11},
+			2,
+			SeqStats{Received: 10, Lost: 2},
+		},
+		{
+			"dropped and reordered", 2,
+			[]uint64{0, 2, 1, 5, 4, 6, 7, 8},
+			1,
+			SeqStats{Received: 8, Lost: 1, Reordered: 2},
+		},
+		{
+			"duplicates", 2,
+			[]uint64{0, 1, 3, 3, 2, 1, 4},
+			0,
+			SeqStats{Received: 5, Reordered: 1, Duplicates: 1, Late: 1},
+		},
+		{
+			"jump", 2,
+			[]uint64{0, 2, 1000, 1001, 1002},
+			998,
+			SeqStats{Received: 5, Lost: 998},
+		},
+		// Missing at the end of the stream are reported by Flush.
+		{
+			"flush", 8,
+			[]uint64{0, 1, 3, 5},
+			0,
+			SeqStats{Received: 4, Lost: 2},
+		},
+	}
+
+	for _, spec := range specs {
+		tr := NewSeqTracker(spec.window)
+		var lost uint64
+		for _, seq := range spec.seqs {
+			lost += tr.Add(seq)
+		}
+		if lost != spec.lost {
+			t.Errorf("%s: wrong lost before flush: got %d, want %d", spec.name, lost, spec.lost)
+		}
+		lost += tr.Flush()
+		if lost != spec.want.Lost {
+			t.Errorf("%s: wrong lost after flush: got %d, want %d", spec.name, lost, spec.want.Lost)
+		}
+		if got := tr.Stats(); got != spec.want {
+			t.Errorf("%s: wrong stats: got %+v, want %+v", spec.name, got, spec.want)
+		}
+
+		tr.Reset()
+		if got := tr.Stats(); got != (SeqStats{}) {
+			t.Errorf("%s: wrong stats after reset: got %+v", spec.name, got)
+		}
+		if n := tr.Add(100); n != 0 {
+			t.Errorf("%s: wrong lost after reset: got %d, want 0", spec.name, n)
+		}
+	}
+}
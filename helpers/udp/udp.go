@@ -35,98 +35,181 @@ import (
 // and false for little-endian.
 type PacketWriteFn func(out io.Writer, x []int16) (int, error)
 
-// NewPacketWriteFn creates a new UDPPacketWriteFn.
+// NewPacketWriteFn creates a new UDPPacketWriteFn. It is a shortcut
+// for creating a PacketWriter with NewPacketWriter and using its Write
+// method. Use a PacketWriter directly to be able to call Flush.
 func NewPacketWriteFn(payloadLen, scalarsPerFrame uint, seqHeader bool, order binary.ByteOrder) (PacketWriteFn, error) {
+	w, err := NewPacketWriter(payloadLen, scalarsPerFrame, seqHeader, order)
+	if err != nil {
+		return nil, err
+	}
+	return w.Write, nil
+}
+
+// packetBuffer holds the state of a packet that is being filled and
+// the sequence number of the next packet. It is shared by the packet
+// writer types.
+type packetBuffer struct {
+	buf       []byte
+	bi        int
+	seq       uint64
+	seqHeader bool
+	order     binary.ByteOrder
+}
+
+// newPacketBuffer creates a packetBuffer ready for the first packet.
+func newPacketBuffer(payloadLen uint, seqHeader bool, order binary.ByteOrder) packetBuffer {
+	p := packetBuffer{
+		buf:       make([]byte, int(payloadLen)),
+		seqHeader: seqHeader,
+		order:     order,
+	}
+	p.reset()
+	return p
+}
+
+// reset discards the contents of the buffer and starts the next packet
+// by writing its sequence header, if enabled.
+func (p *packetBuffer) reset() {
+	const sizeofHeader = 8
+	p.bi = 0
+	if p.seqHeader {
+		p.order.PutUint64(p.buf, p.seq)
+		p.bi = sizeofHeader
+		p.seq++
+	}
+}
+
+// emit writes the full packet buffer to out and starts the next packet.
+// The next packet is started even if the write fails, so the failed
+// packet is dropped instead of being sent again.
+func (p *packetBuffer) emit(out io.Writer) error {
+	_, err := out.Write(p.buf)
+	p.reset()
+	return err
+}
+
+// flush implements Flush for the packet writer types.
+func (p *packetBuffer) flush(out io.Writer, pad bool) (int, error) {
+	const sizeofHeader = 8
+	if p.bi == 0 || (p.seqHeader && p.bi == sizeofHeader) {
+		return 0, nil
+	}
+	n := p.bi
+	if pad {
+		for i := p.bi; i < len(p.buf); i++ {
+			p.buf[i] = 0
+		}
+		n = len(p.buf)
+	}
+	_, err := out.Write(p.buf[:n])
+	p.reset()
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// PacketWriter is a stateful packetizer for int16 sample scalars. Its
+// Write method implements PacketWriteFn. In addition, it can Flush a
+// partially filled packet, which would otherwise be lost when the
+// stream ends.
+type PacketWriter struct {
+	packetBuffer
+	scalarsPerFrame int
+}
+
+// NewPacketWriter creates a new PacketWriter. See PacketWriteFn for a
+// description of the arguments.
+func NewPacketWriter(payloadLen, scalarsPerFrame uint, seqHeader bool, order binary.ByteOrder) (*PacketWriter, error) {
 	const (
 		sizeofScalar = 2
 		sizeofHeader = 8
 	)
+	if scalarsPerFrame == 0 {
+		return nil, fmt.Errorf("invalid scalarsPerFrame: got %d, want > 0", scalarsPerFrame)
+	}
+	if seqHeader && payloadLen <= sizeofHeader {
+		return nil, fmt.Errorf(
+			"payload has no room for samples after sequence header: got payloadLen=%d, want > %d",
+			payloadLen, sizeofHeader,
+		)
+	}
 	dataBytes := payloadLen
 	if seqHeader {
 		dataBytes -= sizeofHeader
 	}
-	if dataBytes%scalarsPerFrame != 0 {
+	if dataBytes == 0 {
+		return nil, fmt.Errorf("payload has no room for samples: payloadLen=%d seqHeader=%v", payloadLen, seqHeader)
+	}
+	if dataBytes%(sizeofScalar*scalarsPerFrame) != 0 {
 		return nil, fmt.Errorf(
-			"frames will not fit evenly in payload: payloadLen=%d seqHeader=%v scalarsPerFrame=%d",
-			payloadLen, seqHeader, scalarsPerFrame,
+			"frames will not fit evenly in payload: payloadLen=%d seqHeader=%v dataBytes=%d scalarsPerFrame=%d",
+			payloadLen, seqHeader, dataBytes, scalarsPerFrame,
 		)
 	}
+	return &PacketWriter{
+		packetBuffer:    newPacketBuffer(payloadLen, seqHeader, order),
+		scalarsPerFrame: int(scalarsPerFrame),
+	}, nil
+}
 
-	var (
-		seq uint64
-		buf = make([]byte, int(payloadLen))
-		bi  int
-	)
-
-	if seqHeader {
-		// buf is already zero-initialized, so seq zero is already encoded.
-		// Just move the buf index to skip it.
-		seq++
-		bi = sizeofHeader
+// Write implements PacketWriteFn.
+//
+// Rather than checking for a full packet after every scalar, it encodes
+// as many scalars as fit in the remainder of the packet in a single
+// tight loop per byte order and then emits the packet if it is full.
+func (w *PacketWriter) Write(out io.Writer, x []int16) (int, error) {
+	const sizeofScalar = 2
+	if len(x)%w.scalarsPerFrame != 0 {
+		return 0, fmt.Errorf("invalid number of scalars: got %d, want multiple of %d", len(x), w.scalarsPerFrame)
 	}
-	write := func(out io.Writer, x []int16) (int, error) {
-		if len(x)%int(scalarsPerFrame) != 0 {
-			return 0, fmt.Errorf("invalid number of scalars: got %d, want multiple of %d", len(x), scalarsPerFrame)
+	var total int
+	for len(x) > 0 {
+		n := (len(w.buf) - w.bi) / sizeofScalar
+		if n > len(x) {
+			n = len(x)
 		}
-		var total int
-		switch order {
-		case binary.BigEndian:
-			for i := range x {
-				binary.BigEndian.PutUint16(buf[bi:], uint16(x[i]))
-				total += sizeofScalar
-				bi += sizeofScalar
-				if bi == int(payloadLen) {
-					_, err := out.Write(buf)
-					if err != nil {
-						return total, err
-					}
-					bi = 0
-					if seqHeader {
-						binary.BigEndian.PutUint64(buf, seq)
-						bi += sizeofHeader
-						seq++
-					}
-				}
-			}
+		src := x[:n]
+		dst := w.buf[w.bi : w.bi+n*sizeofScalar]
+		switch w.order {
 		case binary.LittleEndian:
-			for i := range x {
-				binary.LittleEndian.PutUint16(buf[bi:], uint16(x[i]))
-				total += sizeofScalar
-				bi += sizeofScalar
-				if bi == int(payloadLen) {
-					_, err := out.Write(buf)
-					if err != nil {
-						return total, err
-					}
-					bi = 0
-					if seqHeader {
-						binary.LittleEndian.PutUint64(buf, seq)
-						bi += sizeofHeader
-						seq++
-					}
-				}
+			for i, v := range src {
+				d := dst[i*sizeofScalar : i*sizeofScalar+sizeofScalar]
+				d[0] = byte(v)
+				d[1] = byte(v >> 8)
+			}
+		case binary.BigEndian:
+			for i, v := range src {
+				d := dst[i*sizeofScalar : i*sizeofScalar+sizeofScalar]
+				d[0] = byte(v >> 8)
+				d[1] = byte(v)
 			}
 		default:
-			for i := range x {
-				order.PutUint16(buf[bi:], uint16(x[i]))
-				total += sizeofScalar
-				bi += sizeofScalar
-				if bi == int(payloadLen) {
-					_, err := out.Write(buf)
-					if err != nil {
-						return total, err
-					}
-					bi = 0
-					if seqHeader {
-						order.PutUint64(buf, seq)
-						bi += sizeofHeader
-						seq++
-					}
-				}
+			for i, v := range src {
+				w.order.PutUint16(dst[i*sizeofScalar:], uint16(v))
+			}
+		}
+		w.bi += len(dst)
+		total += len(dst)
+		x = x[n:]
+		if w.bi == len(w.buf) {
+			if err := w.emit(out); err != nil {
+				return total, err
 			}
 		}
-		return total, nil
 	}
+	return total, nil
+}
 
-	return write, nil
+// Flush writes the partially filled packet, if any, to out and starts
+// a new packet with the next sequence number. If pad is true, the
+// unused remainder of the payload is filled with zeros so that the
+// packet has the full payload length. Otherwise, the packet is
+// truncated after the last frame. It returns the number of bytes
+// written to out, which is zero if the packet buffer holds no frames.
+// It should be called once when the stream ends (e.g. after Run
+// returns) so that the tail of the stream is not lost.
+func (w *PacketWriter) Flush(out io.Writer, pad bool) (int, error) {
+	return w.flush(out, pad)
 }
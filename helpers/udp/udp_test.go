@@ -0,0 +1,316 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package udp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func TestPacketWriteFnPayloadLen(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		payloadLen      uint
+		scalarsPerFrame uint
+		seqHeader       bool
+		valid           bool
+	}{
+		{0, 2, true, false},
+		{4, 2, true, false},
+		{8, 2, true, false},
+		{0, 2, false, false},
+		{1400, 0, false, false},
+		{1400, 2, true, true},
+		{1400, 4, true, true},
+		{1400, 2, false, true},
+		{7, 1, false, false},
+		{6, 2, false, false},
+		{1402, 4, false, false},
+		{15, 1, true, false},
+		{1404, 4, true, false},
+		{1404, 4, false, false},
+		{1400, 4, false, true},
+	}
+
+	for _, spec := range specs {
+		_, err := NewPacketWriteFn(spec.payloadLen, spec.scalarsPerFrame, spec.seqHeader, binary.LittleEndian)
+		if got := err == nil; got != spec.valid {
+			t.Errorf(
+				"wrong validity for payloadLen=%d scalarsPerFrame=%d seqHeader=%v: got %v, want %v (err=%v)",
+				spec.payloadLen, spec.scalarsPerFrame, spec.seqHeader, got, spec.valid, err,
+			)
+		}
+	}
+}
+
+func TestPacketWriteFnSeqHeader(t *testing.T) {
+	t.Parallel()
+
+	const payloadLen = 16
+	write, err := NewPacketWriteFn(payloadLen, 2, true, binary.BigEndian)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rec := &packetRecorder{}
+	x := make([]int16, 4*3)
+	for i := range x {
+		x[i] = int16(i)
+	}
+	if _, err := write(rec, x); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rec.packets) != 3 {
+		t.Fatalf("wrong number of packets: got %d, want 3", len(rec.packets))
+	}
+	for p, packet := range rec.packets {
+		if seq := binary.BigEndian.Uint64(packet); seq != uint64(p) {
+			t.Errorf("wrong sequence number: got %d, want %d", seq, p)
+		}
+		if v := int16(binary.BigEndian.Uint16(packet[8:])); v != x[p*4] {
+			t.Errorf("wrong first scalar: got %d, want %d", v, x[p*4])
+		}
+	}
+}
+
+func TestPacketWriterFlush(t *testing.T) {
+	t.Parallel()
+
+	const payloadLen = 24
+	for _, pad := range []bool{false, true} {
+		w, err := NewPacketWriter(payloadLen, 2, true, binary.LittleEndian)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rec := &packetRecorder{}
+
+		if n, err := w.Flush(rec, pad); n != 0 || err != nil || len(rec.packets) != 0 {
+			t.Fatalf("wrong flush of empty buffer: got %d, %v, %d packets", n, err, len(rec.packets))
+		}
+
+		// 8 scalars fill one packet. Write 10 in partial writes.
+		x := make([]int16, 10)
+		for i := range x {
+			x[i] = int16(i + 1)
+		}
+		for _, chunk := range [][]int16{x[:2], x[2:4], x[4:10]} {
+			if _, err := w.Write(rec, chunk); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if len(rec.packets) != 1 {
+			t.Fatalf("wrong number of packets before flush: got %d, want 1", len(rec.packets))
+		}
+
+		wantLen := 8 + 2*2
+		if pad {
+			wantLen = payloadLen
+		}
+		n, err := w.Flush(rec, pad)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != wantLen || len(rec.packets) != 2 || len(rec.packets[1]) != wantLen {
+			t.Fatalf("wrong flush with pad=%v: got n=%d packets=%d, want n=%d packets=2", pad, n, len(rec.packets), wantLen)
+		}
+		tail := rec.packets[1]
+		if seq := binary.LittleEndian.Uint64(tail); seq != 1 {
+			t.Errorf("wrong flushed sequence number: got %d, want 1", seq)
+		}
+		for i := 8; i < len(tail); i += 2 {
+			want := int16(0)
+			if j := 8 + (i-8)/2; j < len(x) {
+				want = x[j]
+			}
+			if got := int16(binary.LittleEndian.Uint16(tail[i:])); got != want {
+				t.Errorf("wrong flushed scalar at byte %d: got %d, want %d", i, got, want)
+			}
+		}
+
+		// A second flush has nothing to emit.
+		if n, err := w.Flush(rec, pad); n != 0 || err != nil || len(rec.packets) != 2 {
+			t.Errorf("wrong second flush: got %d, %v, %d packets", n, err, len(rec.packets))
+		}
+
+		// The buffer was reset, so the next packet is full and continues
+		// the sequence.
+		if _, err := w.Write(rec, x[:8]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rec.packets) != 3 || len(rec.packets[2]) != payloadLen {
+			t.Fatalf("wrong packet after flush: got %d packets", len(rec.packets))
+		}
+		if seq := binary.LittleEndian.Uint64(rec.packets[2]); seq != 2 {
+			t.Errorf("wrong sequence number after flush: got %d, want 2", seq)
+		}
+		if got := int16(binary.LittleEndian.Uint16(rec.packets[2][8:])); got != x[0] {
+			t.Errorf("wrong first scalar after flush: got %d, want %d", got, x[0])
+		}
+	}
+}
+
+// referencePacketWriteFn is the original per-scalar implementation of
+// NewPacketWriteFn. It is kept to verify that the optimized PacketWriter
+// produces identical output and to measure the speedup.
+func referencePacketWriteFn(payloadLen, scalarsPerFrame uint, seqHeader bool, order binary.ByteOrder) PacketWriteFn {
+	const (
+		sizeofScalar = 2
+		sizeofHeader = 8
+	)
+	var (
+		seq uint64
+		buf = make([]byte, int(payloadLen))
+		bi  int
+	)
+	if seqHeader {
+		seq++
+		bi = sizeofHeader
+	}
+	return func(out io.Writer, x []int16) (int, error) {
+		var total int
+		switch order {
+		case binary.BigEndian:
+			for i := range x {
+				binary.BigEndian.PutUint16(buf[bi:], uint16(x[i]))
+				total += sizeofScalar
+				bi += sizeofScalar
+				if bi == int(payloadLen) {
+					if _, err := out.Write(buf); err != nil {
+						return total, err
+					}
+					bi = 0
+					if seqHeader {
+						binary.BigEndian.PutUint64(buf, seq)
+						bi += sizeofHeader
+						seq++
+					}
+				}
+			}
+		case binary.LittleEndian:
+			for i := range x {
+				binary.LittleEndian.PutUint16(buf[bi:], uint16(x[i]))
+				total += sizeofScalar
+				bi += sizeofScalar
+				if bi == int(payloadLen) {
+					if _, err := out.Write(buf); err != nil {
+						return total, err
+					}
+					bi = 0
+					if seqHeader {
+						binary.LittleEndian.PutUint64(buf, seq)
+						bi += sizeofHeader
+						seq++
+					}
+				}
+			}
+		default:
+			for i := range x {
+				order.PutUint16(buf[bi:], uint16(x[i]))
+				total += sizeofScalar
+				bi += sizeofScalar
+				if bi == int(payloadLen) {
+					if _, err := out.Write(buf); err != nil {
+						return total, err
+					}
+					bi = 0
+					if seqHeader {
+						order.PutUint64(buf, seq)
+						bi += sizeofHeader
+						seq++
+					}
+				}
+			}
+		}
+		return total, nil
+	}
+}
+
+// byteOrder is a binary.ByteOrder that is not one of the two standard
+// values, so it exercises the generic path.
+type byteOrder struct {
+	binary.ByteOrder
+}
+
+func TestPacketWriterReference(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		payloadLen      uint
+		scalarsPerFrame uint
+		seqHeader       bool
+		order           binary.ByteOrder
+	}{
+		{1024, 2, false, binary.LittleEndian},
+		{1032, 2, true, binary.LittleEndian},
+		{1032, 4, true, binary.BigEndian},
+		{1440, 2, false, binary.BigEndian},
+		{16, 2, true, byteOrder{binary.LittleEndian}},
+	}
+
+	for _, spec := range specs {
+		w, err := NewPacketWriter(spec.payloadLen, spec.scalarsPerFrame, spec.seqHeader, spec.order)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ref := referencePacketWriteFn(spec.payloadLen, spec.scalarsPerFrame, spec.seqHeader, spec.order)
+		got := &packetRecorder{}
+		want := &packetRecorder{}
+
+		for i := 0; i < 200; i++ {
+			x := make([]int16, int(spec.scalarsPerFrame)*rand.Intn(2000))
+			for j := range x {
+				x[j] = int16(rand.Int())
+			}
+			gotN, err := w.Write(got, x)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wantN, _ := ref(want, x)
+			if gotN != wantN {
+				t.Fatalf("wrong byte count: got %d, want %d", gotN, wantN)
+			}
+		}
+
+		if len(got.packets) != len(want.packets) {
+			t.Fatalf("wrong number of packets: got %d, want %d", len(got.packets), len(want.packets))
+		}
+		for i := range want.packets {
+			if !bytes.Equal(got.packets[i], want.packets[i]) {
+				t.Fatalf("packet %d differs from reference for %+v", i, spec)
+			}
+		}
+	}
+}
+
+func benchmarkPacketWrite(b *testing.B, write PacketWriteFn) {
+	// One callback worth of interleaved samples at the maximum
+	// number of samples per callback.
+	x := make([]int16, 2*1008)
+	for i := range x {
+		x[i] = int16(rand.Int())
+	}
+	b.SetBytes(int64(2 * len(x)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = write(ioutil.Discard, x)
+	}
+}
+
+func BenchmarkPacketWriter(b *testing.B) {
+	w, err := NewPacketWriter(1032, 2, true, binary.LittleEndian)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkPacketWrite(b, w.Write)
+}
+
+func BenchmarkPacketWriterReference(b *testing.B) {
+	benchmarkPacketWrite(b, referencePacketWriteFn(1032, 2, true, binary.LittleEndian))
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// CuePoint marks a position in the data chunk, such as a change in
+// configuration during a capture.
+type CuePoint struct {
+	// ID uniquely identifies the point within the file.
+	ID uint32
+	// Frame is the offset of the marked frame from the first frame.
+	Frame uint32
+	// Label is an optional description of the point.
+	Label string
+}
+
+// Cues is the ordered collection of points of a "cue " chunk. Because
+// the points of a capture are usually only known once writing is
+// complete, the chunk is normally written after the samples. The data
+// chunk must then have an even size and the RIFF chunk size must be
+// increased by the size of the chunk. Finalize assumes the samples
+// extend to the end of the file, so it must not be used on a file with
+// a trailing "cue " chunk.
+type Cues []CuePoint
+
+// Chunk serializes the points as a complete "cue " chunk using the
+// provided byte order. If any point has a label, the "cue " chunk is
+// followed by a LIST chunk of type "adtl" with a "labl" field for each
+// labeled point. It returns an error if two points have the same ID.
+func (c Cues) Chunk(order binary.ByteOrder) ([]byte, error) {
+	ids := make(map[uint32]bool, len(c))
+	for _, p := range c {
+		if ids[p.ID] {
+			return nil, fmt.Errorf("invalid cue point ID: got duplicate %d", p.ID)
+		}
+		ids[p.ID] = true
+	}
+
+	var res bytes.Buffer
+	res.WriteString("cue ")
+	_ = binary.Write(&res, order, uint32(4+24*len(c)))
+	_ = binary.Write(&res, order, uint32(len(c)))
+	var labels bytes.Buffer
+	for _, p := range c {
+		_ = binary.Write(&res, order, p.ID)
+		_ = binary.Write(&res, order, p.Frame) // position
+		res.WriteString("data")
+		_ = binary.Write(&res, order, [2]uint32{}) // chunk and block start
+		_ = binary.Write(&res, order, p.Frame)     // sample offset
+		if p.Label == "" {
+			continue
+		}
+		// Each text is NUL terminated and padded to an even size.
+		size := uint32(4 + len(p.Label) + 1)
+		labels.WriteString("labl")
+		_ = binary.Write(&labels, order, size)
+		_ = binary.Write(&labels, order, p.ID)
+		labels.WriteString(p.Label)
+		labels.WriteByte(0)
+		if size%2 != 0 {
+			labels.WriteByte(0)
+		}
+	}
+	if labels.Len() != 0 {
+		res.WriteString("LIST")
+		_ = binary.Write(&res, order, uint32(4+labels.Len()))
+		res.WriteString("adtl")
+		res.Write(labels.Bytes())
+	}
+	return res.Bytes(), nil
+}
+
+// ReadCues reads a WAV file, as created by WriteHeader, from r, skips the
+// samples, and returns the points of the "cue " chunk that follows them,
+// including any labels from a LIST chunk of type "adtl". The size of the
+// data chunk must be set (e.g. with Header.Update). It returns nil if
+// there is no "cue " chunk.
+func ReadCues(r io.Reader) (Cues, error) {
+	head, _, order, _, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if head.Data.ChunkSize == StreamingSize {
+		return nil, fmt.Errorf("invalid data size: got %#x, want set", head.Data.ChunkSize)
+	}
+	size := int64(head.Data.ChunkSize) + int64(head.Data.ChunkSize%2)
+	if _, err := io.CopyN(ioutil.Discard, r, size); err != nil {
+		return nil, fmt.Errorf("failed to skip samples: %v", err)
+	}
+
+	var (
+		res    Cues
+		labels = map[uint32]string{}
+		chunk  DataChunk
+	)
+	for {
+		if err := binary.Read(r, order, &chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to read chunk: %v", err)
+		}
+		body := make([]byte, int64(chunk.ChunkSize)+int64(chunk.ChunkSize%2))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, fmt.Errorf("failed to read %q chunk: %v", chunk.ChunkID[:], err)
+		}
+		body = body[:chunk.ChunkSize]
+		switch string(chunk.ChunkID[:]) {
+		case "cue ":
+			if len(body) < 4 {
+				return nil, fmt.Errorf("invalid cue chunk size: got %d, want >= 4", len(body))
+			}
+			num := order.Uint32(body)
+			if uint64(len(body)) != 4+24*uint64(num) {
+				return nil, fmt.Errorf("invalid cue chunk size: got %d, want %d", len(body), 4+24*uint64(num))
+			}
+			res = Cues{}
+			for b := body[4:]; len(b) > 0; b = b[24:] {
+				res = append(res, CuePoint{ID: order.Uint32(b), Frame: order.Uint32(b[20:])})
+			}
+		case "LIST":
+			if len(body) < 4 || string(body[:4]) != "adtl" {
+				continue
+			}
+			for b := body[4:]; len(b) >= 8; {
+				id := string(b[:4])
+				size := order.Uint32(b[4:8])
+				b = b[8:]
+				if uint64(size) > uint64(len(b)) {
+					return nil, fmt.Errorf("invalid adtl field size: got %d, want <= %d", size, len(b))
+				}
+				if id == "labl" && size >= 4 {
+					labels[order.Uint32(b)] = strings.TrimRight(string(b[4:size]), "\x00")
+				}
+				if size%2 != 0 && size < uint32(len(b)) {
+					size++
+				}
+				b = b[size:]
+			}
+		}
+	}
+	for i := range res {
+		res[i].Label = labels[res[i].ID]
+	}
+	return res, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCues(t *testing.T) {
+	t.Parallel()
+
+	cues := Cues{
+		{ID: 1, Frame: 10, Label: "fs=8MHz"},
+		{ID: 2, Frame: 25},
+		{ID: 3, Frame: 40, Label: "fs=6MHz!"},
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		const numFrames = 50
+		head, err := NewHeader(20000, 2, 2, LPCM, order, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk, err := cues.Chunk(order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk)%2 != 0 {
+			t.Errorf("wrong chunk size: got %d, want even", len(chunk))
+		}
+		head.Update(numFrames)
+		head.Riff.ChunkSize += uint32(len(chunk))
+
+		var buf bytes.Buffer
+		if _, err := WriteHeader(&buf, order, head, NewCaptureInfo("cue", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), nil)); err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(make([]byte, numFrames*4))
+		buf.Write(chunk)
+
+		got, err := ReadCues(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, cues) {
+			t.Errorf("wrong cues: got %+v, want %+v", got, cues)
+		}
+	}
+
+	// A file without cues.
+	head, _ := NewHeader(20000, 2, 2, LPCM, binary.LittleEndian, 3)
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, head)
+	buf.Write(make([]byte, 12))
+	if got, err := ReadCues(bytes.NewReader(buf.Bytes())); err != nil || got != nil {
+		t.Errorf("wrong cues without chunk: got %+v (%v), want nil", got, err)
+	}
+
+	if _, err := (Cues{{ID: 1}, {ID: 1, Frame: 2}}).Chunk(binary.LittleEndian); err == nil {
+		t.Error("unexpected success for duplicate ID")
+	}
+}
@@ -5,5 +5,25 @@
 /*
 Package wav implements basic WAV file header creation useful
 for writing WAV files.
+
+The data size dependent fields of a WAV header are only known once
+writing is complete. For a seekable file, write a header with zero
+frames, write the samples, and then seek back and write the header again
+after calling Header.Update. For output that cannot seek, such as stdout,
+a pipe, or an upload to cloud storage, call Header.SetStreaming before
+writing the header. The resulting StreamingSize sentinel tells most
+readers to read until the end of the stream.
+
+If a capture is interrupted before the header is rewritten, or a
+streamed file is later stored somewhere it can be seeked, Finalize
+repairs the header in place by measuring the length of the data.
+
+WriteHeader can add a LIST chunk of INFO fields, such as the software,
+the start time, and the location of the receiver (see NewCaptureInfo),
+between the "fact" and "data" chunks. ReadHeaderInfo reads them back.
+
+Cues marks positions in the samples, such as a change in configuration
+during a capture. Its "cue " chunk is written after the samples, once
+all of the points are known, and ReadCues reads it back.
 */
 package wav
@@ -0,0 +1,165 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// ReadHeader reads a Header, as created by NewHeader, from r. It returns
+// the header and the byte order indicated by its RIFF chunk ID. Any LIST
+// or other chunks between the "fact" and "data" chunks, such as those
+// written by WriteHeader, are skipped. It returns an error if the chunk
+// IDs do not match the layout of Header.
+func ReadHeader(r io.Reader) (*Header, binary.ByteOrder, error) {
+	head, _, order, _, err := readHeader(r)
+	return head, order, err
+}
+
+// ReadHeaderInfo is like ReadHeader, but it also returns the fields of
+// the first LIST chunk of type INFO. The returned Info is nil if the
+// header has no such chunk.
+func ReadHeaderInfo(r io.Reader) (*Header, Info, binary.ByteOrder, error) {
+	head, info, order, _, err := readHeader(r)
+	return head, info, order, err
+}
+
+// readHeader implements ReadHeaderInfo. It also returns the offset of
+// the first sample.
+func readHeader(r io.Reader) (*Header, Info, binary.ByteOrder, int64, error) {
+	var pre headerPrefix
+	buf := make([]byte, binary.Size(&pre))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, nil, nil, 0, fmt.Errorf("failed to read header: %v", err)
+	}
+	var order binary.ByteOrder
+	switch id := string(buf[:4]); id {
+	case "RIFF":
+		order = binary.LittleEndian
+	case "RIFX":
+		order = binary.BigEndian
+	default:
+		return nil, nil, nil, 0, fmt.Errorf("invalid RIFF chunk ID: got %q, want RIFF or RIFX", id)
+	}
+	if err := binary.Read(bytes.NewReader(buf), order, &pre); err != nil {
+		return nil, nil, nil, 0, fmt.Errorf("failed to read header: %v", err)
+	}
+	head := Header{Riff: pre.Riff, Fmt: pre.Fmt, Fact: pre.Fact}
+	offset := int64(len(buf))
+
+	// Skip or parse chunks until the data chunk.
+	var info Info
+	for {
+		if err := binary.Read(r, order, &head.Data); err != nil {
+			return nil, nil, nil, 0, fmt.Errorf("failed to read header: %v", err)
+		}
+		offset += int64(binary.Size(&head.Data))
+		if string(head.Data.ChunkID[:]) == "data" {
+			break
+		}
+		if string(head.Data.ChunkID[:]) != "LIST" || head.Data.ChunkSize == StreamingSize {
+			return nil, nil, nil, 0, fmt.Errorf("invalid chunk ID: got %q, want %q", head.Data.ChunkID[:], "data")
+		}
+		size := int64(head.Data.ChunkSize) + int64(head.Data.ChunkSize%2)
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, nil, nil, 0, fmt.Errorf("failed to read LIST chunk: %v", err)
+		}
+		offset += size
+		if info == nil {
+			var err error
+			info, err = parseInfo(body[:head.Data.ChunkSize], order)
+			if err != nil {
+				return nil, nil, nil, 0, err
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		got  [4]byte
+		want string
+	}{
+		{head.Riff.Format, "WAVE"},
+		{head.Fmt.ChunkID, "fmt "},
+		{head.Fact.ChunkID, "fact"},
+	} {
+		if string(c.got[:]) != c.want {
+			return nil, nil, nil, 0, fmt.Errorf("invalid chunk ID: got %q, want %q", c.got[:], c.want)
+		}
+	}
+	if head.Fmt.BlockAlign == 0 {
+		return nil, nil, nil, 0, fmt.Errorf("invalid block align: got %d, want > 0", head.Fmt.BlockAlign)
+	}
+	return &head, info, order, offset, nil
+}
+
+// Finalize repairs the header of an existing WAV file, created with a
+// Header from this package, so that the data size dependent fields match
+// the length of the file. It is intended for files whose header was
+// never updated, either because the capture was interrupted (e.g. a
+// crash or power loss) and the header still says zero frames, or
+// because the file was written as a stream with the StreamingSize
+// sentinel and later copied somewhere it can be seeked. The file must
+// be seekable and writable. Any trailing partial frame is left in the
+// file, but is not counted.
+//
+// It returns the number of frames in the finalized file.
+func Finalize(path string) (uint32, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	numFrames, err := finalize(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to finalize %s: %v", path, err)
+	}
+	return numFrames, nil
+}
+
+// finalize rewrites the header of the WAV file provided as f. Only the
+// size fields are rewritten, so any LIST chunk is preserved as is.
+func finalize(f *os.File) (uint32, error) {
+	head, _, order, offset, err := readHeader(f)
+	if err != nil {
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	dataBytes := info.Size() - offset
+	numFrames := dataBytes / int64(head.Fmt.BlockAlign)
+	extra := offset - int64(binary.Size(head))
+	if numFrames*int64(head.Fmt.BlockAlign)+4+extra > math.MaxUint32 {
+		return 0, fmt.Errorf("invalid data size: got %d bytes, want <= %d", dataBytes, math.MaxUint32-4-extra)
+	}
+
+	head.Update(uint32(numFrames))
+	head.Riff.ChunkSize += uint32(extra)
+	pre := headerPrefix{Riff: head.Riff, Fmt: head.Fmt, Fact: head.Fact}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(f, order, &pre); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(offset-int64(binary.Size(&head.Data)), io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(f, order, &head.Data); err != nil {
+		return 0, err
+	}
+	return uint32(numFrames), nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFinalize(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "wavfinalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	specs := []struct {
+		name      string
+		order     binary.ByteOrder
+		streaming bool
+		numBytes  int
+		want      uint32
+	}{
+		// Header never updated after a crash.
+		{"crash.wav", binary.LittleEndian, false, 400, 100},
+		{"crashbig.wav", binary.BigEndian, false, 400, 100},
+		// Header written with the streaming sentinel.
+		{"stream.wav", binary.LittleEndian, true, 400, 100},
+		// Partial trailing frame is not counted.
+		{"partial.wav", binary.LittleEndian, false, 402, 100},
+		{"empty.wav", binary.LittleEndian, false, 0, 0},
+	}
+
+	for _, spec := range specs {
+		head, err := NewHeader(20000, 2, 2, LPCM, spec.order, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spec.streaming {
+			head.SetStreaming()
+		}
+		var buf bytes.Buffer
+		if err := binary.Write(&buf, spec.order, head); err != nil {
+			t.Fatal(err)
+		}
+		samples := make([]byte, spec.numBytes)
+		for i := range samples {
+			samples[i] = byte(i)
+		}
+		buf.Write(samples)
+		path := filepath.Join(dir, spec.name)
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := Finalize(path)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", spec.name, err)
+			continue
+		}
+		if got != spec.want {
+			t.Errorf("wrong number of frames for %s: got %d, want %d", spec.name, got, spec.want)
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) != buf.Len() {
+			t.Errorf("wrong file size for %s: got %d, want %d", spec.name, len(b), buf.Len())
+		}
+		h, order, err := ReadHeader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("failed to read header for %s: %v", spec.name, err)
+		}
+		if order != spec.order {
+			t.Errorf("wrong byte order for %s: got %v, want %v", spec.name, order, spec.order)
+		}
+		want, err := NewHeader(20000, 2, 2, LPCM, spec.order, spec.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *h != *want {
+			t.Errorf("wrong header for %s: got %+v, want %+v", spec.name, *h, *want)
+		}
+		if !bytes.Equal(b[binary.Size(h):], samples) {
+			t.Errorf("wrong samples for %s", spec.name)
+		}
+	}
+}
+
+func TestFinalizeInvalid(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "wavfinalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := Finalize(filepath.Join(dir, "missing.wav")); err == nil {
+		t.Error("unexpected success for missing file")
+	}
+
+	specs := map[string][]byte{
+		"short.wav":  []byte("RIFF"),
+		"notwav.wav": bytes.Repeat([]byte("x"), 64),
+	}
+	for name, b := range specs {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Finalize(path); err == nil {
+			t.Errorf("unexpected success for %s", name)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// IDs of the INFO fields written by NewCaptureInfo. All but InfoLocation
+// are standard RIFF INFO fields.
+const (
+	// InfoSoftware is the name of the software that created the file.
+	InfoSoftware = "ISFT"
+	// InfoCreationDate is the time of the first sample of the file
+	// formatted with InfoTimeFormat.
+	InfoCreationDate = "ICRD"
+	// InfoComment is a free-form comment.
+	InfoComment = "ICMT"
+	// InfoLocation is the location of the receiver formatted as an
+	// ISO 6709 string by Location.String. It is not a standard field,
+	// so most readers ignore it.
+	InfoLocation = "IGPS"
+)
+
+// InfoTimeFormat is the time format of the InfoCreationDate field. Unlike
+// time.RFC3339Nano, it always has the same length, so a header can be
+// rewritten with a more precise time without changing its size.
+const InfoTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// InfoField is a single text field of a LIST chunk of type INFO.
+type InfoField struct {
+	// ID is the four character field ID (e.g. InfoSoftware).
+	ID   string
+	Text string
+}
+
+// Info is the ordered collection of fields of a LIST chunk of type
+// INFO. When provided to WriteHeader, it is written between the "fact"
+// and "data" chunks.
+type Info []InfoField
+
+// Get returns the text of the first field with the provided ID and
+// whether such a field exists.
+func (i Info) Get(id string) (string, bool) {
+	for _, f := range i {
+		if f.ID == id {
+			return f.Text, true
+		}
+	}
+	return "", false
+}
+
+// Chunk serializes the fields as a complete LIST chunk using the
+// provided byte order for the size fields. It returns an error if a
+// field ID is not four bytes long.
+func (i Info) Chunk(order binary.ByteOrder) ([]byte, error) {
+	var body bytes.Buffer
+	body.WriteString("INFO")
+	for _, f := range i {
+		if len(f.ID) != 4 {
+			return nil, fmt.Errorf("invalid INFO field ID: got %q, want 4 bytes", f.ID)
+		}
+		// Each text is NUL terminated and padded to an even size.
+		size := uint32(len(f.Text) + 1)
+		body.WriteString(f.ID)
+		_ = binary.Write(&body, order, size)
+		body.WriteString(f.Text)
+		body.WriteByte(0)
+		if size%2 != 0 {
+			body.WriteByte(0)
+		}
+	}
+	var res bytes.Buffer
+	res.WriteString("LIST")
+	_ = binary.Write(&res, order, uint32(body.Len()))
+	res.Write(body.Bytes())
+	return res.Bytes(), nil
+}
+
+// parseInfo parses the body of a LIST chunk. It returns nil if the list
+// type is not INFO.
+func parseInfo(b []byte, order binary.ByteOrder) (Info, error) {
+	if len(b) < 4 || string(b[:4]) != "INFO" {
+		return nil, nil
+	}
+	res := Info{}
+	b = b[4:]
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, fmt.Errorf("invalid INFO field: got %d bytes, want >= 8", len(b))
+		}
+		id := string(b[:4])
+		size := order.Uint32(b[4:8])
+		b = b[8:]
+		if uint64(size) > uint64(len(b)) {
+			return nil, fmt.Errorf("invalid INFO field size: got %d, want <= %d", size, len(b))
+		}
+		res = append(res, InfoField{
+			ID:   id,
+			Text: strings.TrimRight(string(b[:size]), "\x00"),
+		})
+		if size%2 != 0 && size < uint32(len(b)) {
+			size++
+		}
+		b = b[size:]
+	}
+	return res, nil
+}
+
+// Location is the geographic location of a receiver.
+type Location struct {
+	// Latitude is in decimal degrees north in the range [-90,90].
+	Latitude float64
+	// Longitude is in decimal degrees east in the range [-180,180].
+	Longitude float64
+	// Altitude is in meters above the WGS 84 ellipsoid.
+	Altitude float64
+}
+
+// Validate returns a non-nil error if any coordinate is out of range.
+func (l Location) Validate() error {
+	switch {
+	case !(l.Latitude >= -90 && l.Latitude <= 90):
+		return fmt.Errorf("invalid latitude: got %v, want -90 to 90", l.Latitude)
+	case !(l.Longitude >= -180 && l.Longitude <= 180):
+		return fmt.Errorf("invalid longitude: got %v, want -180 to 180", l.Longitude)
+	case math.IsNaN(l.Altitude) || math.IsInf(l.Altitude, 0):
+		return fmt.Errorf("invalid altitude: got %v, want finite value", l.Altitude)
+	}
+	return nil
+}
+
+// String returns the location as an ISO 6709 string with micro-degree
+// resolution and the altitude in meters (e.g. +40.446195-079.948862+300.0/).
+func (l Location) String() string {
+	return fmt.Sprintf("%+010.6f%+011.6f%+.1f/", l.Latitude, l.Longitude, l.Altitude)
+}
+
+// NewCaptureInfo creates the INFO fields that describe a capture made by
+// the named software starting at the provided time. The location is
+// optional and only included if loc is not nil.
+func NewCaptureInfo(software string, start time.Time, loc *Location) Info {
+	res := Info{
+		{ID: InfoSoftware, Text: software},
+		{ID: InfoCreationDate, Text: start.UTC().Format(InfoTimeFormat)},
+	}
+	if loc != nil {
+		res = append(res, InfoField{ID: InfoLocation, Text: loc.String()})
+	}
+	return res
+}
+
+// WriteHeader writes head to w followed by a LIST chunk holding info
+// between the "fact" and "data" chunks. If info is empty, no LIST chunk
+// is written and the result is identical to binary.Write(w, order, head).
+// The RIFF chunk size is increased by the size of the LIST chunk unless
+// it holds the StreamingSize sentinel. The head argument is not modified.
+//
+// It returns the number of bytes written, which is the offset of the
+// first sample.
+func WriteHeader(w io.Writer, order binary.ByteOrder, head *Header, info Info) (int, error) {
+	var list []byte
+	if len(info) != 0 {
+		var err error
+		list, err = info.Chunk(order)
+		if err != nil {
+			return 0, err
+		}
+	}
+	pre := headerPrefix{Riff: head.Riff, Fmt: head.Fmt, Fact: head.Fact}
+	if pre.Riff.ChunkSize != StreamingSize {
+		pre.Riff.ChunkSize += uint32(len(list))
+	}
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, order, &pre)
+	buf.Write(list)
+	_ = binary.Write(&buf, order, &head.Data)
+	return w.Write(buf.Bytes())
+}
+
+// headerPrefix holds the chunks of a Header that precede any LIST chunk.
+type headerPrefix struct {
+	Riff RiffChunk
+	Fmt  FmtChunk
+	Fact FactChunk
+}
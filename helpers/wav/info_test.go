@@ -0,0 +1,140 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteHeaderInfo(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2021, 3, 4, 5, 6, 7, 890, time.UTC)
+	loc := &Location{Latitude: 40.446195, Longitude: -79.948862, Altitude: 300}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		head, err := NewHeader(20000, 2, 2, LPCM, order, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		n, err := WriteHeader(&buf, order, head, NewCaptureInfo("odd", start, loc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != buf.Len() || n%2 != 0 || n <= binary.Size(head) {
+			t.Fatalf("wrong header size: got %d, buffer %d", n, buf.Len())
+		}
+
+		gotHead, info, gotOrder, err := ReadHeaderInfo(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotOrder != order {
+			t.Errorf("wrong order: got %v, want %v", gotOrder, order)
+		}
+		if gotHead.Riff.ChunkSize != head.Riff.ChunkSize+uint32(n-binary.Size(head)) {
+			t.Errorf("wrong RIFF size: got %d", gotHead.Riff.ChunkSize)
+		}
+		for _, want := range []InfoField{
+			{InfoSoftware, "odd"},
+			{InfoCreationDate, "2021-03-04T05:06:07.000000890Z"},
+			{InfoLocation, "+40.446195-079.948862+300.0/"},
+		} {
+			got, ok := info.Get(want.ID)
+			if !ok || got != want.Text {
+				t.Errorf("wrong %s: got %q, want %q", want.ID, got, want.Text)
+			}
+		}
+	}
+}
+
+func TestWriteHeaderNoInfo(t *testing.T) {
+	t.Parallel()
+
+	head, err := NewHeader(20000, 2, 4, IEEEFloatingPoint, binary.LittleEndian, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want bytes.Buffer
+	if _, err := WriteHeader(&got, binary.LittleEndian, head, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(&want, binary.LittleEndian, head); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("wrong header: got %x, want %x", got.Bytes(), want.Bytes())
+	}
+	_, info, _, err := ReadHeaderInfo(bytes.NewReader(got.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info != nil {
+		t.Errorf("wrong info: got %v, want nil", info)
+	}
+}
+
+func TestFinalizeInfo(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "wavinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	head, err := NewHeader(20000, 2, 2, LPCM, binary.LittleEndian, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head.SetStreaming()
+	info := NewCaptureInfo("rspwav", time.Unix(0, 0), &Location{Latitude: -33.9, Longitude: 151.2})
+	var buf bytes.Buffer
+	n, err := WriteHeader(&buf, binary.LittleEndian, head, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(make([]byte, 400))
+	path := filepath.Join(dir, "info.wav")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	numFrames, err := Finalize(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numFrames != 100 {
+		t.Errorf("wrong number of frames: got %d, want 100", numFrames)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotHead, gotInfo, _, err := ReadHeaderInfo(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint32(4 + 400 + n - binary.Size(head)); gotHead.Riff.ChunkSize != want {
+		t.Errorf("wrong RIFF size: got %d, want %d", gotHead.Riff.ChunkSize, want)
+	}
+	if gotHead.Data.ChunkSize != 400 {
+		t.Errorf("wrong data size: got %d, want 400", gotHead.Data.ChunkSize)
+	}
+	if got, _ := gotInfo.Get(InfoLocation); got != "-33.900000+151.200000+0.0/" {
+		t.Errorf("wrong location: got %q", got)
+	}
+	if len(b) != n+400 {
+		t.Errorf("wrong file size: got %d, want %d", len(b), n+400)
+	}
+}
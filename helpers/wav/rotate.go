@@ -0,0 +1,209 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package wav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Boundary is an enum type that represents a calendar-aligned UTC
+// boundary at which a RotatingWriter starts a new file.
+type Boundary int
+
+const (
+	// NoBoundary specifies that files are never rotated.
+	NoBoundary Boundary = iota
+	// Hourly specifies rotation at the top of each UTC hour.
+	Hourly
+	// Daily specifies rotation at midnight UTC.
+	Daily
+)
+
+// String implements fmt.Stringer.
+func (b Boundary) String() string {
+	switch b {
+	case NoBoundary:
+		return "none"
+	case Hourly:
+		return "hourly"
+	case Daily:
+		return "daily"
+	default:
+		return fmt.Sprintf("Boundary(%d)", int(b))
+	}
+}
+
+// Next returns the first boundary strictly after t in UTC. For
+// NoBoundary, or any invalid value, it returns the zero time.
+func (b Boundary) Next(t time.Time) time.Time {
+	t = t.UTC()
+	switch b {
+	case Hourly:
+		return t.Truncate(time.Hour).Add(time.Hour)
+	case Daily:
+		y, m, d := t.Date()
+		return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Time{}
+	}
+}
+
+// TimestampName returns a function, suitable for use with
+// NewRotatingWriter, that names each file with the provided prefix
+// followed by the UTC start time of the file and a .wav extension
+// (e.g. prefix20210102T150000Z.wav).
+func TimestampName(prefix string) func(t time.Time) string {
+	return func(t time.Time) string {
+		return prefix + t.UTC().Format("20060102T150405Z") + ".wav"
+	}
+}
+
+// maxDataBytes is the largest data chunk that can be described by
+// the 32-bit size fields of a WAV header. Header.Update sets the RIFF
+// chunk size to the data size plus 4.
+const maxDataBytes = 0xFFFFFFFF - 4
+
+// RotatingWriter is an io.WriteCloser that writes sample data to a
+// sequence of WAV files, starting a new file whenever the wall clock
+// crosses a UTC Boundary. Every file starts with a copy of the header
+// provided to NewRotatingWriter, and the header of each file is updated
+// with the number of frames it contains when the file is closed. The
+// first and last files are generally partial, covering only the time
+// from the first Write to the first boundary and from the last boundary
+// to Close respectively.
+//
+// The boundary is checked at the beginning of each call to Write, so
+// a file is only closed between calls to Write. Each call should contain
+// a whole number of frames (e.g. the samples from a single callback) so
+// that frames are never split across files.
+type RotatingWriter struct {
+	head     Header
+	order    binary.ByteOrder
+	boundary Boundary
+	name     func(t time.Time) string
+	now      func() time.Time
+
+	f         *os.File
+	out       *bufio.Writer
+	path      string
+	dataBytes uint64
+	next      time.Time
+}
+
+// NewRotatingWriter creates a new RotatingWriter. The head argument is
+// the template header written at the start of each file. The order
+// argument must match the order used to create head. The name function
+// is called with the time of the first Write to a new file and returns
+// the path of that file (e.g. TimestampName). No file is created until
+// the first call to Write.
+func NewRotatingWriter(head *Header, order binary.ByteOrder, boundary Boundary, name func(t time.Time) string) (*RotatingWriter, error) {
+	switch boundary {
+	case NoBoundary, Hourly, Daily:
+		// good
+	default:
+		return nil, fmt.Errorf("invalid boundary: got %v, want none, hourly, or daily", boundary)
+	}
+	if head == nil || head.Fmt.BlockAlign == 0 {
+		return nil, errors.New("missing or invalid template header")
+	}
+	if name == nil {
+		return nil, errors.New("missing name function")
+	}
+	return &RotatingWriter{
+		head:     *head,
+		order:    order,
+		boundary: boundary,
+		name:     name,
+		now:      time.Now,
+	}, nil
+}
+
+// Path returns the path of the current file or the empty string if
+// no file is open.
+func (w *RotatingWriter) Path() string {
+	return w.path
+}
+
+// Write implements io.Writer. If no file is open or the wall clock has
+// reached the next boundary, it finishes the current file and starts a
+// new one before writing. It returns an error without writing if the
+// data would exceed the maximum size of a WAV file.
+func (w *RotatingWriter) Write(b []byte) (int, error) {
+	t := w.now()
+	if w.f == nil || (w.boundary != NoBoundary && !t.Before(w.next)) {
+		if err := w.rotate(t); err != nil {
+			return 0, err
+		}
+	}
+	if w.dataBytes+uint64(len(b)) > maxDataBytes {
+		return 0, fmt.Errorf("WAV file size limit exceeded: %s", w.path)
+	}
+	n, err := w.out.Write(b)
+	w.dataBytes += uint64(n)
+	return n, err
+}
+
+// Close implements io.Closer. It finishes the current file, if any,
+// by updating its header with the final number of frames.
+func (w *RotatingWriter) Close() error {
+	return w.finish()
+}
+
+// rotate finishes the current file, if any, and starts a new file
+// named for time t.
+func (w *RotatingWriter) rotate(t time.Time) error {
+	if err := w.finish(); err != nil {
+		return err
+	}
+	path := w.name(t)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	head := w.head
+	head.Update(0)
+	out := bufio.NewWriterSize(f, 1024*1024)
+	if err := binary.Write(out, w.order, &head); err != nil {
+		f.Close()
+		return err
+	}
+	w.f = f
+	w.out = out
+	w.path = path
+	w.dataBytes = 0
+	w.next = w.boundary.Next(t)
+	return nil
+}
+
+// finish flushes the current file, rewrites its header with the
+// number of frames written, and closes it.
+func (w *RotatingWriter) finish() error {
+	if w.f == nil {
+		return nil
+	}
+	f := w.f
+	w.f = nil
+	w.path = ""
+
+	err := w.out.Flush()
+	if err == nil {
+		head := w.head
+		head.Update(uint32(w.dataBytes / uint64(head.Fmt.BlockAlign)))
+		_, err = f.Seek(0, io.SeekStart)
+		if err == nil {
+			err = binary.Write(f, w.order, &head)
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoundaryNext(t *testing.T) {
+	t.Parallel()
+
+	est := time.FixedZone("EST", -5*3600)
+	specs := []struct {
+		b    Boundary
+		t    time.Time
+		want time.Time
+	}{
+		{Hourly, time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC), time.Date(2021, 3, 4, 6, 0, 0, 0, time.UTC)},
+		{Hourly, time.Date(2021, 3, 4, 5, 0, 0, 0, time.UTC), time.Date(2021, 3, 4, 6, 0, 0, 0, time.UTC)},
+		{Hourly, time.Date(2021, 12, 31, 23, 59, 59, 0, time.UTC), time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Daily, time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC), time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{Daily, time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+		// 21:30 EST is 02:30 UTC the next day.
+		{Daily, time.Date(2021, 3, 4, 21, 30, 0, 0, est), time.Date(2021, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{NoBoundary, time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC), time.Time{}},
+	}
+
+	for _, spec := range specs {
+		if got := spec.b.Next(spec.t); !got.Equal(spec.want) {
+			t.Errorf("wrong next %v boundary after %v: got %v, want %v", spec.b, spec.t, got, spec.want)
+		}
+	}
+}
+
+func TestRotatingWriter(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "wavrotate")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const numChannels = 2
+	head, err := NewHeader(1000, numChannels, 2, LPCM, binary.LittleEndian, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w, err := NewRotatingWriter(head, binary.LittleEndian, Hourly, TimestampName(filepath.Join(dir, "cap_")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var now time.Time
+	w.now = func() time.Time { return now }
+
+	// Each write is 10 frames of 4 bytes.
+	frame := bytes.Repeat([]byte{1, 2, 3, 4}, 10)
+	start := time.Date(2021, 3, 4, 5, 58, 30, 0, time.UTC)
+	writes := []time.Duration{
+		0,
+		30 * time.Second,
+		90 * time.Second,                // 06:00:00 exactly, rolls
+		91 * time.Second,                // same file
+		time.Hour + 90*time.Second - 1,  // 06:59:59.999999999, same file
+		time.Hour + 90*time.Second,      // 07:00:00, rolls
+		2*time.Hour + 3*time.Minute + 5, // 08:01:30, rolls
+	}
+	for _, d := range writes {
+		now = start.Add(d)
+		if _, err := w.Write(frame); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Path() != "" {
+		t.Errorf("wrong path after close: got %q, want empty", w.Path())
+	}
+
+	want := []struct {
+		name      string
+		numFrames uint32
+	}{
+		{"cap_20210304T055830Z.wav", 20},
+		{"cap_20210304T060000Z.wav", 30},
+		{"cap_20210304T070000Z.wav", 10},
+		{"cap_20210304T080130Z.wav", 10},
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(infos) != len(want) {
+		t.Fatalf("wrong number of files: got %d, want %d", len(infos), len(want))
+	}
+	headSize := binary.Size(head)
+	for i, spec := range want {
+		if infos[i].Name() != spec.name {
+			t.Errorf("wrong file name: got %s, want %s", infos[i].Name(), spec.name)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, infos[i].Name()))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var got Header
+		if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wantHead := *head
+		wantHead.Update(spec.numFrames)
+		if got != wantHead {
+			t.Errorf("wrong header in %s: got %+v, want %+v", spec.name, got, wantHead)
+		}
+		if wantLen := headSize + int(spec.numFrames)*numChannels*2; len(b) != wantLen {
+			t.Errorf("wrong file length for %s: got %d, want %d", spec.name, len(b), wantLen)
+		}
+	}
+}
@@ -181,3 +181,20 @@ func (h *Header) Update(numFrames uint32) {
 	h.Fact.SampleLength = numFrames
 	h.Data.ChunkSize = numBytes
 }
+
+// StreamingSize is the sentinel value used for all data size
+// dependent fields in a header written to a stream whose final length
+// is unknown. Most readers (e.g. sox, ffmpeg) interpret it as "read
+// until end of stream".
+const StreamingSize = 0xFFFFFFFF
+
+// SetStreaming sets all of the data size dependent fields in the
+// header struct to StreamingSize. It is intended for output that
+// cannot seek back to update the header on completion, such as
+// stdout or a named pipe. A later call to Update will replace the
+// sentinel values.
+func (h *Header) SetStreaming() {
+	h.Riff.ChunkSize = StreamingSize
+	h.Fact.SampleLength = StreamingSize
+	h.Data.ChunkSize = StreamingSize
+}
@@ -7,6 +7,8 @@ package wav
 import (
 	"bytes"
 	"encoding/binary"
+	"io"
+	"io/ioutil"
 	"strings"
 	"testing"
 )
@@ -102,3 +104,58 @@ func TestHeader(t *testing.T) {
 		t.Errorf("wrong error message: got '%s', want 'sample format'", err.Error())
 	}
 }
+
+func TestHeaderStreaming(t *testing.T) {
+	t.Parallel()
+
+	h, err := NewHeader(20000, 2, 2, LPCM, binary.LittleEndian, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h.SetStreaming()
+
+	samples := []int16{1, -1, 2, -2, 3, -3}
+	r, w := io.Pipe()
+	go func() {
+		if err := binary.Write(w, binary.LittleEndian, h); err != nil {
+			w.CloseWithError(err)
+			return
+		}
+		w.CloseWithError(binary.Write(w, binary.LittleEndian, samples))
+	}()
+
+	var got Header
+	if err := binary.Read(r, binary.LittleEndian, &got); err != nil {
+		t.Fatalf("failed to read header: %v", err)
+	}
+	if string(got.Riff.ChunkID[:]) != "RIFF" {
+		t.Errorf("wrong magic number: got %s, want RIFF", got.Riff.ChunkID[:])
+	}
+	if got.Riff.ChunkSize != StreamingSize {
+		t.Errorf("wrong RIFF chunk size: got %#x, want %#x", got.Riff.ChunkSize, uint32(StreamingSize))
+	}
+	if got.Fact.SampleLength != StreamingSize {
+		t.Errorf("wrong fact sample length: got %#x, want %#x", got.Fact.SampleLength, uint32(StreamingSize))
+	}
+	if got.Data.ChunkSize != StreamingSize {
+		t.Errorf("wrong data chunk size: got %#x, want %#x", got.Data.ChunkSize, uint32(StreamingSize))
+	}
+
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read samples: %v", err)
+	}
+	if len(rest) != len(samples)*2 {
+		t.Fatalf("wrong number of sample bytes: got %d, want %d", len(rest), len(samples)*2)
+	}
+	for i, want := range samples {
+		if v := int16(binary.LittleEndian.Uint16(rest[i*2:])); v != want {
+			t.Errorf("wrong sample %d: got %d, want %d", i, v, want)
+		}
+	}
+
+	h.Update(3)
+	if h.Data.ChunkSize != 12 {
+		t.Errorf("wrong data chunk size after update: got %d, want 12", h.Data.ChunkSize)
+	}
+}
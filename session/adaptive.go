@@ -0,0 +1,169 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// AdaptiveDecimation is the configuration for automatic adjustment of
+// the decimation factor based on the rate of dropped samples. See
+// WithAdaptiveDecimation.
+//
+// To avoid oscillating between two decimation factors, the controller
+// uses hysteresis in both level and time. Decimation is only increased
+// after the drop rate exceeds MaxDropPercent for UpIntervals consecutive
+// intervals. It is only decreased after the drop rate is at or below
+// RestorePercent, which is lower than MaxDropPercent, for DownIntervals
+// consecutive intervals, which should be much longer than UpIntervals.
+// Drop rates between the two thresholds reset both counts. Each change
+// also resets both counts, so the effect of a change is measured over
+// whole intervals before the next change.
+type AdaptiveDecimation struct {
+	// MaxDropPercent is the percentage of dropped samples above which
+	// the load is considered too high.
+	MaxDropPercent float64
+	// RestorePercent is the percentage of dropped samples at or below
+	// which the load is considered low enough to decrease decimation.
+	RestorePercent float64
+	// Interval is the period over which the drop rate is measured.
+	Interval time.Duration
+	// UpIntervals is the number of consecutive intervals with a high
+	// drop rate before decimation is increased.
+	UpIntervals int
+	// DownIntervals is the number of consecutive intervals with a low
+	// drop rate before decimation is decreased.
+	DownIntervals int
+	// MaxDec is the largest decimation factor that will be used.
+	// Because the factor is only doubled, a MaxDec that is not a power
+	// of two times the configured decimation is not reached.
+	MaxDec uint8
+}
+
+// WithAdaptiveDecimation creates a function that configures the Session
+// to double the decimation factor of a running device when the
+// percentage of dropped samples on stream A is sustained above
+// maxDropPercent (e.g. because the CPU cannot keep up) and to halve it
+// again, but never below the configured decimation, when the load allows.
+// The change is made with SetDecimationRuntime, so streaming continues.
+//
+// The defaults are a one second interval, three intervals to increase,
+// thirty intervals to decrease, a RestorePercent of one tenth of
+// maxDropPercent, and a MaxDec of 32. They can be changed through the
+// AdaptiveDec member of the Session. See AdaptiveDecimation for a
+// description of the hysteresis.
+//
+// The effective sample rate seen by the stream callbacks changes with
+// each adjustment. The NumSamples of the callbacks changes accordingly
+// and the current decimation factor can be read with LoadDeviceParams.
+// The controller runs concurrently with the control loop, if any.
+func WithAdaptiveDecimation(maxDropPercent float64) ConfigFn {
+	return func(o *Session) error {
+		if o.AdaptiveDec != nil {
+			return errors.New("adaptive decimation already set")
+		}
+		if maxDropPercent <= 0 || maxDropPercent >= 100 {
+			return fmt.Errorf("invalid max drop percent: got %v, want 0 < pct < 100", maxDropPercent)
+		}
+		o.AdaptiveDec = &AdaptiveDecimation{
+			MaxDropPercent: maxDropPercent,
+			RestorePercent: maxDropPercent / 10,
+			Interval:       time.Second,
+			UpIntervals:    3,
+			DownIntervals:  30,
+			MaxDec:         32,
+		}
+		return nil
+	}
+}
+
+// decimationController implements the control logic of
+// AdaptiveDecimation independently of the device.
+type decimationController struct {
+	cfg  AdaptiveDecimation
+	base uint8
+	dec  uint8
+	high int
+	low  int
+}
+
+// newDecimationController creates a decimationController that starts
+// at, and never goes below, the base decimation factor.
+func newDecimationController(cfg AdaptiveDecimation, base uint8) *decimationController {
+	if base == 0 {
+		base = 1
+	}
+	return &decimationController{cfg: cfg, base: base, dec: base}
+}
+
+// next updates the state with the report for one interval and returns
+// the decimation factor to use for the next interval.
+func (c *decimationController) next(r TransferReport) uint8 {
+	switch {
+	case r.Callbacks < 2:
+		// Nothing measured, so no evidence either way.
+		c.high, c.low = 0, 0
+	case r.DropPercent > c.cfg.MaxDropPercent:
+		c.high++
+		c.low = 0
+		if c.high >= c.cfg.UpIntervals && c.dec <= c.cfg.MaxDec/2 {
+			c.dec *= 2
+			c.high = 0
+		}
+	case r.DropPercent <= c.cfg.RestorePercent:
+		c.low++
+		c.high = 0
+		if c.low >= c.cfg.DownIntervals && c.dec > c.base {
+			c.dec /= 2
+			c.low = 0
+		}
+	default:
+		c.high, c.low = 0, 0
+	}
+	return c.dec
+}
+
+// run measures the drop rate with stats every interval and adjusts the
+// decimation of the device until ctx is canceled or an update fails.
+func (ad *AdaptiveDecimation) run(ctx context.Context, d *api.DeviceT, a api.API, stats *TransferStats) error {
+	tuner := runtimeTuner(d)
+	p, err := a.LoadDeviceParams(d.Dev)
+	if err != nil {
+		return fmt.Errorf("failed to load device params: %v", a.GetLastError(d))
+	}
+	chans := runtimeChannels(d, p, tuner)
+	if len(chans) == 0 {
+		return fmt.Errorf("invalid tuner selection: got %v", tuner)
+	}
+	if err := checkChannels(d, p, tuner); err != nil {
+		return err
+	}
+	ctl := newDecimationController(*ad, chans[0].CtrlParams.Decimation.DecimationFactor)
+
+	ticker := time.NewTicker(ad.Interval)
+	defer ticker.Stop()
+	stats.Reset()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		prev := ctl.dec
+		dec := ctl.next(stats.Report())
+		stats.Reset()
+		if dec == prev {
+			continue
+		}
+		if err := SetDecimationRuntime(d, a, tuner, dec); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestDecimationController(t *testing.T) {
+	t.Parallel()
+
+	cfg := AdaptiveDecimation{
+		MaxDropPercent: 1,
+		RestorePercent: 0.1,
+		UpIntervals:    2,
+		DownIntervals:  3,
+		MaxDec:         8,
+	}
+
+	specs := []struct {
+		name  string
+		base  uint8
+		drops []float64
+		want  []uint8
+	}{
+		{
+			"sustained drops",
+			1,
+			[]float64{5, 5, 5, 5, 5, 5, 5, 5},
+			[]uint8{1, 2, 2, 4, 4, 8, 8, 8},
+		},
+		{
+			"isolated spikes",
+			2,
+			[]float64{5, 0, 5, 0.5, 5, 0},
+			[]uint8{2, 2, 2, 2, 2, 2},
+		},
+		{
+			"restore after load",
+			2,
+			[]float64{5, 5, 0, 0, 0, 0, 0, 0, 0},
+			[]uint8{2, 4, 4, 4, 2, 2, 2, 2, 2},
+		},
+		{
+			// Drops between the thresholds hold the current factor.
+			"dead band",
+			1,
+			[]float64{5, 5, 0.5, 0.5, 0.5, 0.5, 0, 0, 0},
+			[]uint8{1, 2, 2, 2, 2, 2, 2, 2, 1},
+		},
+		{
+			"zero base",
+			0,
+			[]float64{0, 0, 0},
+			[]uint8{1, 1, 1},
+		},
+	}
+
+	for _, spec := range specs {
+		c := newDecimationController(cfg, spec.base)
+		var got []uint8
+		for _, pct := range spec.drops {
+			got = append(got, c.next(TransferReport{Callbacks: 100, DropPercent: pct}))
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("%s: wrong decimation: got %v, want %v", spec.name, got, spec.want)
+		}
+	}
+
+	// Intervals without callbacks reset the counts.
+	c := newDecimationController(cfg, 1)
+	for _, r := range []TransferReport{{Callbacks: 100, DropPercent: 5}, {}, {Callbacks: 100, DropPercent: 5}} {
+		if dec := c.next(r); dec != 1 {
+			t.Errorf("wrong decimation after empty interval: got %d, want 1", dec)
+		}
+	}
+
+	// Doubling never exceeds MaxDec, even if it is not a power of two.
+	maxSpecs := []struct {
+		max  uint8
+		want uint8
+	}{
+		{6, 4},
+		{7, 4},
+		{255, 128},
+	}
+	for _, spec := range maxSpecs {
+		cfg := cfg
+		cfg.MaxDec = spec.max
+		c := newDecimationController(cfg, 1)
+		var dec uint8
+		for i := 0; i < 40; i++ {
+			dec = c.next(TransferReport{Callbacks: 100, DropPercent: 5})
+		}
+		if dec != spec.want {
+			t.Errorf("wrong decimation for MaxDec %d: got %d, want %d", spec.max, dec, spec.want)
+		}
+	}
+}
+
+func TestWithAdaptiveDecimation(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	m := apitest.NewMock(d)
+	s, err := NewSession(
+		WithImplementation(m),
+		WithDeviceConfig(WithSingleChannelConfig(WithZeroIF(8e6, 1))),
+		WithAdaptiveDecimation(1),
+		WithControlLoop(func(ctx context.Context, d *api.DeviceT, a api.API) error {
+			// Deliver callbacks that drop every other block until the
+			// decimation is increased.
+			const numSamples = 100
+			var sampleNum uint32
+			deadline := time.Now().Add(10 * time.Second)
+			for time.Now().Before(deadline) {
+				p, err := a.LoadDeviceParams(d.Dev)
+				if err != nil {
+					return err
+				}
+				if p.RxChannelA.CtrlParams.Decimation.DecimationFactor > 1 {
+					return nil
+				}
+				m.Callbacks.StreamACbFn(nil, nil, &api.StreamCbParamsT{FirstSampleNum: sampleNum, NumSamples: numSamples}, sampleNum == 0)
+				sampleNum += 2 * numSamples
+				time.Sleep(100 * time.Microsecond)
+			}
+			t.Error("decimation was not increased")
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AdaptiveDec.Interval = 5 * time.Millisecond
+	s.AdaptiveDec.UpIntervals = 2
+
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := m.Params.RxChannelA.CtrlParams.Decimation.DecimationFactor; got != 2 {
+		t.Errorf("wrong decimation: got %d, want 2", got)
+	}
+	if len(m.Updates) != 1 || m.Updates[0].Reason != api.Update_Ctrl_Decimation {
+		t.Errorf("wrong updates: got %+v", m.Updates)
+	}
+}
+
+func TestAdaptiveDecimationChannels(t *testing.T) {
+	t.Parallel()
+
+	cfg := AdaptiveDecimation{Interval: time.Millisecond, MaxDec: 32}
+	specs := []struct {
+		name    string
+		d       *api.DeviceT
+		params  *api.DeviceParamsT
+		missing bool
+	}{
+		{
+			// A single selected tuner B is configured through
+			// RxChannelA, so RxChannelB is not needed.
+			"single duo B",
+			&api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_B, RspDuoMode: api.RspDuoMode_Single_Tuner},
+			&api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}},
+			false,
+		},
+		{
+			"dual duo without B",
+			&api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner},
+			&api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}},
+			true,
+		},
+		{
+			"RSP1A without A",
+			&api.DeviceT{HWVer: api.RSP1A_ID},
+			&api.DeviceParamsT{DevParams: &api.DevParamsT{}},
+			true,
+		},
+	}
+	for _, spec := range specs {
+		m := apitest.NewMock(spec.d)
+		m.Params = spec.params
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := cfg.run(ctx, spec.d, m, NewTransferStats(1e6))
+		cancel()
+		switch {
+		case spec.missing && !errors.Is(err, ErrMissingChannel):
+			t.Errorf("%s: wrong error: got %v, want %v", spec.name, err, ErrMissingChannel)
+		case !spec.missing && err != nil:
+			t.Errorf("%s: unexpected error: %v", spec.name, err)
+		}
+	}
+}
+
+func TestWithAdaptiveDecimationInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, pct := range []float64{0, -1, 100} {
+		if _, err := NewSession(WithAdaptiveDecimation(pct)); err == nil {
+			t.Errorf("unexpected success for %v", pct)
+		}
+	}
+	if _, err := NewSession(WithAdaptiveDecimation(1), WithAdaptiveDecimation(1)); err == nil {
+		t.Error("unexpected success for repeated option")
+	}
+}
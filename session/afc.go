@@ -0,0 +1,223 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/helpers/dsp"
+)
+
+// AFC is the configuration for automatic frequency control. See
+// WithAFC.
+type AFC struct {
+	// CaptureBW is the width in Hz of the band, centered on the tuner
+	// frequency, that is searched for the dominant signal.
+	CaptureBW float64
+	// DeadbandHz is the largest offset of the dominant signal from the
+	// tuner frequency that does not cause a retune.
+	DeadbandHz float64
+	// Interval is the period between measurements.
+	Interval time.Duration
+	// NumSamples is the number of the most recent stream A samples that
+	// are searched for the dominant signal at each measurement.
+	NumSamples int
+	// MinLevelDB is the level in dBFS below which the dominant signal
+	// is ignored, so that the tuner does not follow the noise when the
+	// signal is absent.
+	MinLevelDB float64
+}
+
+// WithAFC creates a function that configures the Session to keep a
+// drifting signal centered by automatic frequency control. Every
+// interval, the most recent stream A samples are searched with
+// dsp.FindPeakInBand for the dominant signal within captureBW/2 of the
+// tuner frequency. If the offset of that signal is larger than
+// deadbandHz, the tuner is retuned to it with Retune, so streaming
+// continues. After a retune, the samples from before it are discarded
+// and the next measurement only uses samples received after it. The
+// same applies when the decimation, and with it the effective sample
+// rate, is changed at runtime, such as by WithAdaptiveDecimation.
+//
+// The defaults are a one second interval, 4096 samples, and a MinLevelDB
+// of -Inf, which follows the strongest signal regardless of its level.
+// They can be changed through the AFC member of the Session. The
+// captureBW should be smaller than the effective sample rate, because
+// the ends of the band are attenuated by the anti-aliasing filters, and
+// the deadband should be much larger than the effective sample rate
+// divided by NumSamples, which is the resolution of the search.
+//
+// On an RSPduo with both tuners selected, both are retuned, but only
+// stream A is measured. The current frequency can be read with
+// LoadDeviceParams. The controller runs concurrently with the control
+// loop, if any.
+func WithAFC(captureBW, deadbandHz float64) ConfigFn {
+	return func(o *Session) error {
+		if o.AFC != nil {
+			return errors.New("AFC already set")
+		}
+		if math.IsNaN(captureBW) || captureBW <= 0 {
+			return fmt.Errorf("invalid capture bandwidth: got %v Hz, want > 0", captureBW)
+		}
+		if math.IsNaN(deadbandHz) || deadbandHz < 0 || deadbandHz >= captureBW/2 {
+			return fmt.Errorf("invalid deadband: got %v Hz, want 0 <= deadband < %v", deadbandHz, captureBW/2)
+		}
+		o.AFC = &AFC{
+			CaptureBW:  captureBW,
+			DeadbandHz: deadbandHz,
+			Interval:   time.Second,
+			NumSamples: 4096,
+			MinLevelDB: math.Inf(-1),
+		}
+		return nil
+	}
+}
+
+// afcController implements the control logic of AFC independently of
+// the device.
+type afcController struct {
+	cfg  AFC
+	freq float64
+}
+
+// next returns the tuner frequency to use for the measured offset and
+// level of the dominant signal and whether it is different from the
+// current frequency.
+func (c *afcController) next(offsetHz, levelDB float64) (float64, bool) {
+	switch {
+	case math.IsInf(levelDB, -1), levelDB < c.cfg.MinLevelDB:
+		// No signal to follow.
+		return c.freq, false
+	case math.Abs(offsetHz) <= c.cfg.DeadbandHz:
+		return c.freq, false
+	}
+	c.freq += offsetHz
+	return c.freq, true
+}
+
+// sampleRing keeps the most recent stream samples scaled to [-1,1].
+type sampleRing struct {
+	mu    sync.Mutex
+	buf   []complex64
+	pos   int
+	count int
+}
+
+// newSampleRing creates a sampleRing that keeps the last n samples.
+func newSampleRing(n int) *sampleRing {
+	return &sampleRing{buf: make([]complex64, n)}
+}
+
+// wrap returns a api.StreamCallbackT that keeps the samples and then
+// calls next, if not nil.
+func (r *sampleRing) wrap(next api.StreamCallbackT) api.StreamCallbackT {
+	return func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		r.mu.Lock()
+		for i := range xi {
+			r.buf[r.pos] = complex(float32(xi[i])/32768, float32(xq[i])/32768)
+			r.pos = (r.pos + 1) % len(r.buf)
+		}
+		r.count += len(xi)
+		r.mu.Unlock()
+		if next != nil {
+			next(xi, xq, params, reset)
+		}
+	}
+}
+
+// read returns a copy of the samples in order if the ring is full.
+func (r *sampleRing) read() ([]complex64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count < len(r.buf) {
+		return nil, false
+	}
+	x := make([]complex64, len(r.buf))
+	n := copy(x, r.buf[r.pos:])
+	copy(x[n:], r.buf[:r.pos])
+	return x, true
+}
+
+// reset discards the samples.
+func (r *sampleRing) reset() {
+	r.mu.Lock()
+	r.count = 0
+	r.mu.Unlock()
+}
+
+// afcSampleRate returns the current effective sample rate of the first
+// channel selected by tuner.
+func afcSampleRate(d *api.DeviceT, a api.API, tuner api.TunerSelectT) (float64, error) {
+	p, err := a.LoadDeviceParams(d.Dev)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load device params: %v", a.GetLastError(d))
+	}
+	chans := runtimeChannels(d, p, tuner)
+	if len(chans) == 0 {
+		return 0, fmt.Errorf("invalid tuner selection: got %v", tuner)
+	}
+	return GetEffectiveSampleRate(d, p, chans[0])
+}
+
+// run measures the dominant signal in ring every interval and retunes
+// the device until ctx is canceled or a retune fails.
+func (afc *AFC) run(ctx context.Context, d *api.DeviceT, a api.API, ring *sampleRing) error {
+	tuner := runtimeTuner(d)
+	p, err := a.LoadDeviceParams(d.Dev)
+	if err != nil {
+		return fmt.Errorf("failed to load device params: %v", a.GetLastError(d))
+	}
+	chans := runtimeChannels(d, p, tuner)
+	if len(chans) == 0 {
+		return fmt.Errorf("invalid tuner selection: got %v", tuner)
+	}
+	fs, err := GetEffectiveSampleRate(d, p, chans[0])
+	if err != nil {
+		return err
+	}
+	ctl := &afcController{cfg: *afc, freq: chans[0].TunerParams.RfFreq.RfHz}
+
+	ticker := time.NewTicker(afc.Interval)
+	defer ticker.Stop()
+	ring.reset()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		x, ok := ring.read()
+		if !ok {
+			continue
+		}
+		// The sample rate changes if the decimation is changed at
+		// runtime (e.g. by AdaptiveDecimation). The samples in the ring
+		// may then span both rates, so start over.
+		rate, err := afcSampleRate(d, a, tuner)
+		if err != nil {
+			return err
+		}
+		if rate != fs {
+			fs = rate
+			ring.reset()
+			continue
+		}
+		offset, level := dsp.FindPeakInBand(x, fs, -afc.CaptureBW/2, afc.CaptureBW/2)
+		freq, retune := ctl.next(offset, level)
+		if !retune {
+			continue
+		}
+		if err := Retune(d, a, tuner, freq); err != nil {
+			return err
+		}
+		ring.reset()
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestWithAFC(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		bw, deadband float64
+	}{
+		{0, 0},
+		{-10e3, 1e3},
+		{math.NaN(), 1e3},
+		{10e3, -1},
+		{10e3, 5e3},
+		{10e3, math.NaN()},
+	}
+	for _, spec := range specs {
+		if _, err := NewSession(WithAFC(spec.bw, spec.deadband)); err == nil {
+			t.Errorf("unexpected success for bw=%v deadband=%v", spec.bw, spec.deadband)
+		}
+	}
+	if _, err := NewSession(WithAFC(10e3, 1e3), WithAFC(10e3, 1e3)); err == nil {
+		t.Error("unexpected success for duplicate AFC")
+	}
+}
+
+func TestAFCInvalid(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		name string
+		edit func(a *AFC)
+	}{
+		{"zero samples", func(a *AFC) { a.NumSamples = 0 }},
+		{"negative samples", func(a *AFC) { a.NumSamples = -1 }},
+		{"zero interval", func(a *AFC) { a.Interval = 0 }},
+		{"negative interval", func(a *AFC) { a.Interval = -time.Second }},
+	}
+	for _, spec := range specs {
+		m := apitest.NewMock(&api.DeviceT{HWVer: api.RSP1A_ID})
+		sess, err := NewSession(
+			WithImplementation(m),
+			WithDeviceConfig(WithSingleChannelConfig(WithZeroIF(2e6, 1))),
+			WithAFC(20e3, 1e3),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		spec.edit(sess.AFC)
+		if err := sess.Run(context.Background()); err == nil {
+			t.Errorf("%s: unexpected success", spec.name)
+		}
+		for _, c := range m.Calls {
+			if c == "Init" {
+				t.Errorf("%s: unexpected Init", spec.name)
+			}
+		}
+	}
+}
+
+func TestAFCController(t *testing.T) {
+	t.Parallel()
+
+	// A tone drifting 3 kHz per interval from 100 MHz with a deadband of
+	// 5 kHz is followed every other interval.
+	c := &afcController{
+		cfg:  AFC{CaptureBW: 50e3, DeadbandHz: 5e3, MinLevelDB: -60},
+		freq: 100e6,
+	}
+	var got []float64
+	for k := 1; k <= 8; k++ {
+		tone := 100e6 + 3e3*float64(k)
+		if freq, ok := c.next(tone-c.freq, -20); ok {
+			got = append(got, freq)
+		}
+	}
+	want := []float64{100.006e6, 100.012e6, 100.018e6, 100.024e6}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong retunes: got %v, want %v", got, want)
+	}
+
+	// A signal that is too weak, or absent, is not followed.
+	for _, level := range []float64{-61, math.Inf(-1)} {
+		if freq, ok := c.next(20e3, level); ok || freq != 100.024e6 {
+			t.Errorf("unexpected retune for %v dB: got %v", level, freq)
+		}
+	}
+}
+
+// toneMock is an apitest.Mock that, once initialized, makes stream A
+// callbacks with a complex tone at RF frequency toneHz(n), where n is
+// the number of samples generated so far, as received by a tuner at
+// the current RF frequency and sample rate. If rate is 0, the sample
+// rate is the ADC rate divided by the current decimation factor. While
+// the decimation factor is below dropBelow, every other block of
+// samples is reported as dropped.
+type toneMock struct {
+	*apitest.Mock
+	rate      float64
+	toneHz    func(n uint64) float64
+	dropBelow uint8
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	last    float64
+	retunes []float64
+}
+
+func (m *toneMock) Init(dev api.Handle, callbacks api.CallbackFnsT) error {
+	if err := m.Mock.Init(dev, callbacks); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		xi := make([]int16, 256)
+		xq := make([]int16, 256)
+		var (
+			n     uint64
+			phase float64
+			first uint32
+		)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			p, err := m.LoadDeviceParams(dev)
+			if err != nil {
+				return
+			}
+			rate := m.rate
+			dec := p.RxChannelA.CtrlParams.Decimation.DecimationFactor
+			if rate == 0 {
+				rate = p.DevParams.FsFreq.FsHz / float64(dec)
+			}
+			for i := range xi {
+				tone := m.toneHz(n)
+				offset := tone - p.RxChannelA.TunerParams.RfFreq.RfHz
+				phase = math.Mod(phase+2*math.Pi*offset/rate, 2*math.Pi)
+				xi[i] = int16(16384 * math.Cos(phase))
+				xq[i] = int16(16384 * math.Sin(phase))
+				n++
+			}
+			m.mu.Lock()
+			m.last = m.toneHz(n)
+			m.mu.Unlock()
+			params := &api.StreamCbParamsT{FirstSampleNum: first, NumSamples: uint32(len(xi))}
+			callbacks.StreamACbFn(xi, xq, params, first == 0)
+			first += uint32(len(xi))
+			if dec < m.dropBelow {
+				first += uint32(len(xi))
+			}
+		}
+	}(m.stop, m.done)
+	return nil
+}
+
+// Update records the RF frequency after each update.
+func (m *toneMock) Update(dev api.Handle, tuner api.TunerSelectT, reasonForUpdate api.ReasonForUpdateT, reasonForUpdateExt1 api.ReasonForUpdateExtension1T) error {
+	if err := m.Mock.Update(dev, tuner, reasonForUpdate, reasonForUpdateExt1); err != nil {
+		return err
+	}
+	p, err := m.LoadDeviceParams(dev)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.retunes = append(m.retunes, p.RxChannelA.TunerParams.RfFreq.RfHz)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *toneMock) Uninit(dev api.Handle) error {
+	// The generator also locks m.mu, so wait for it without holding it.
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop = nil
+	m.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return m.Mock.Uninit(dev)
+}
+
+func TestAFC(t *testing.T) {
+	t.Parallel()
+
+	const (
+		rate     = 2e6 / 32
+		tuneHz   = 100e6
+		deadband = 1e3
+	)
+	specs := []struct {
+		name   string
+		toneHz func(n uint64) float64
+		check  func(freqs []float64, tone float64) bool
+	}{
+		{
+			// A fixed offset outside of the deadband is corrected once.
+			"fixed",
+			func(n uint64) float64 { return tuneHz + 5e3 },
+			func(freqs []float64, tone float64) bool {
+				return len(freqs) == 1 && math.Abs(freqs[0]-tone) < 100
+			},
+		},
+		{
+			// A drift of 10 Hz per 256 samples is followed in steps.
+			"drifting",
+			func(n uint64) float64 { return tuneHz + 2e3 + 10*float64(n/256) },
+			func(freqs []float64, tone float64) bool {
+				for i := 1; i < len(freqs); i++ {
+					if freqs[i] <= freqs[i-1] {
+						return false
+					}
+				}
+				return len(freqs) >= 3 && math.Abs(freqs[len(freqs)-1]-tone) < 2*deadband
+			},
+		},
+		{
+			// An offset outside of the capture band is ignored, because
+			// only its leakage, which is below MinLevelDB, is in band.
+			"outside",
+			func(n uint64) float64 { return tuneHz + 15e3 },
+			func(freqs []float64, tone float64) bool { return len(freqs) == 0 },
+		},
+	}
+
+	for _, spec := range specs {
+		d := &api.DeviceT{HWVer: api.RSP1A_ID}
+		m := &toneMock{Mock: apitest.NewMock(d), rate: rate, toneHz: spec.toneHz}
+
+		sess, err := NewSession(
+			WithImplementation(m),
+			WithSelector(),
+			WithDeviceConfig(WithSingleChannelConfig(WithZeroIF(2e6, 32), WithTuneFreq(tuneHz))),
+			WithAFC(20e3, deadband),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sess.AFC.Interval = 20 * time.Millisecond
+		sess.AFC.NumSamples = 1024
+		sess.AFC.MinLevelDB = -60
+		sess.Control = func(ctx context.Context, d *api.DeviceT, a api.API) error {
+			time.Sleep(500 * time.Millisecond)
+			return nil
+		}
+		if err := sess.Run(context.Background()); err != nil {
+			t.Fatalf("%s: unexpected error: %v", spec.name, err)
+		}
+
+		for _, u := range m.Updates {
+			if u.Reason != api.Update_Tuner_Frf {
+				t.Errorf("%s: wrong update reason: got %v, want %v", spec.name, u.Reason, api.Update_Tuner_Frf)
+			}
+		}
+		if !spec.check(m.retunes, m.last) {
+			t.Errorf("%s: wrong retunes for final tone at %v Hz: got %v", spec.name, m.last, m.retunes)
+		}
+	}
+}
+
+func TestAFCWithAdaptiveDecimation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		tuneHz = 100e6
+		toneHz = tuneHz + 5e3
+	)
+	// Samples are dropped until the decimation is doubled, which halves
+	// the sample rate while the AFC is running.
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	m := &toneMock{
+		Mock:      apitest.NewMock(d),
+		toneHz:    func(n uint64) float64 { return toneHz },
+		dropBelow: 32,
+	}
+	sess, err := NewSession(
+		WithImplementation(m),
+		WithSelector(),
+		WithDeviceConfig(WithSingleChannelConfig(WithZeroIF(2e6, 16), WithTuneFreq(tuneHz))),
+		WithAFC(40e3, 1e3),
+		WithAdaptiveDecimation(10),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sess.AFC.Interval = 20 * time.Millisecond
+	sess.AFC.NumSamples = 1024
+	sess.AdaptiveDec.Interval = 5 * time.Millisecond
+	sess.AdaptiveDec.UpIntervals = 2
+	sess.Control = func(ctx context.Context, d *api.DeviceT, a api.API) error {
+		time.Sleep(500 * time.Millisecond)
+		return nil
+	}
+	if err := sess.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := m.Params.RxChannelA.CtrlParams.Decimation.DecimationFactor; got != 32 {
+		t.Fatalf("wrong decimation: got %d, want 32", got)
+	}
+	// Offsets measured at the wrong rate would be scaled by the change
+	// of decimation and overshoot the tone.
+	if len(m.retunes) == 0 {
+		t.Fatal("tone was not followed")
+	}
+	var freqs []float64
+	for i, u := range m.Updates {
+		if u.Reason == api.Update_Tuner_Frf {
+			freqs = append(freqs, m.retunes[i])
+		}
+	}
+	if len(freqs) != 1 || math.Abs(freqs[0]-toneHz) > 100 {
+		t.Errorf("wrong retunes for tone at %v Hz: got %v", toneHz, freqs)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// maxGRdB is the largest IF gain reduction accepted by the API.
+const maxGRdB = 59
+
+// AGCClamp is the configuration for the supervisory limit on the
+// hardware AGC. See WithAGCClamp.
+type AGCClamp struct {
+	// ClipDBFS is the level in dBFS at or above which a sample
+	// component is counted as clipped.
+	ClipDBFS float64
+	// StepDB is the gain reduction in dB added to the current value each
+	// time clipping is detected.
+	StepDB int32
+	// Hold is how long no clipping must be detected before control is
+	// handed back to the AGC.
+	Hold time.Duration
+	// Interval is the period between checks of the clip detector.
+	Interval time.Duration
+}
+
+// WithAGCClamp creates a function that configures the Session to
+// supervise the hardware AGC (see WithAGC) with a software limit, so
+// that a slow AGC loop or a fixed set point does not leave the device in
+// overload on a crowded band. Every interval, the clip detector is
+// checked. It fires if any stream A sample component reached ClipDBFS or
+// a power overload was reported since the last check. When it fires,
+// the AGC is disabled and the IF gain reduction is set to stepDB more
+// than the last value reported in a GainChange event, or configured if
+// there was none. While the clamp is engaged, each further detection
+// adds another stepDB, up to 59 dB. Once no clipping has been detected
+// for hold, the AGC setting from before the intervention is restored
+// and the AGC resumes control from the raised gain reduction. If the
+// AGC was disabled, the gain reduction from before the intervention is
+// restored instead.
+//
+// The defaults are a ClipDBFS of -0.1 dBFS and an interval of 100 ms.
+// They can be changed through the AGCClamp member of the Session. On an
+// RSPduo with both tuners selected, both are changed, but only stream A
+// is measured. The controller runs concurrently with the control loop,
+// if any.
+func WithAGCClamp(stepDB int32, hold time.Duration) ConfigFn {
+	return func(o *Session) error {
+		if o.AGCClamp != nil {
+			return errors.New("AGC clamp already set")
+		}
+		if stepDB < 1 || stepDB > maxGRdB {
+			return fmt.Errorf("invalid step: got %d dB, want 1<=step<=%d", stepDB, maxGRdB)
+		}
+		if hold <= 0 {
+			return fmt.Errorf("invalid hold: got %v, want > 0", hold)
+		}
+		o.AGCClamp = &AGCClamp{
+			ClipDBFS: -0.1,
+			StepDB:   stepDB,
+			Hold:     hold,
+			Interval: 100 * time.Millisecond,
+		}
+		return nil
+	}
+}
+
+// clampAction is the change requested by agcClampController.
+type clampAction int
+
+const (
+	clampNone clampAction = iota
+	clampRaise
+	clampRelease
+)
+
+// agcClampController implements the control logic of AGCClamp
+// independently of the device.
+type agcClampController struct {
+	cfg     AGCClamp
+	engaged bool
+	grdb    int32
+	clear   time.Duration
+}
+
+// next returns the action to take after a check at which clipping was
+// or was not detected, elapsed time after the previous check. For
+// clampRaise, it also returns the new gain reduction, starting from grdb
+// if the clamp is not already engaged.
+func (c *agcClampController) next(clipped bool, elapsed time.Duration, grdb int32) (clampAction, int32) {
+	switch {
+	case clipped:
+		if !c.engaged {
+			c.engaged = true
+			c.grdb = grdb
+		}
+		c.clear = 0
+		if c.grdb >= maxGRdB {
+			return clampNone, c.grdb
+		}
+		c.grdb += c.cfg.StepDB
+		if c.grdb > maxGRdB {
+			c.grdb = maxGRdB
+		}
+		return clampRaise, c.grdb
+	case c.engaged:
+		c.clear += elapsed
+		if c.clear < c.cfg.Hold {
+			return clampNone, c.grdb
+		}
+		c.engaged = false
+		return clampRelease, c.grdb
+	default:
+		return clampNone, grdb
+	}
+}
+
+// clipDetector watches stream A for clipped samples and the event
+// callback for power overloads and gain changes.
+type clipDetector struct {
+	mu       sync.Mutex
+	level    int32
+	clipped  bool
+	grdb     int32
+	reported bool
+}
+
+// newClipDetector creates a clipDetector that counts sample components
+// at or above clipDBFS as clipped.
+func newClipDetector(clipDBFS float64) *clipDetector {
+	level := int32(math.Ceil(32768 * math.Pow(10, clipDBFS/20)))
+	if level > 32767 {
+		level = 32767
+	}
+	return &clipDetector{level: level}
+}
+
+// wrapStream returns a api.StreamCallbackT that checks the samples and
+// then calls next, if not nil.
+func (cd *clipDetector) wrapStream(next api.StreamCallbackT) api.StreamCallbackT {
+	return func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		var clipped bool
+		for i := range xi {
+			if abs16(xi[i]) >= cd.level || abs16(xq[i]) >= cd.level {
+				clipped = true
+				break
+			}
+		}
+		if clipped {
+			cd.mu.Lock()
+			cd.clipped = true
+			cd.mu.Unlock()
+		}
+		if next != nil {
+			next(xi, xq, params, reset)
+		}
+	}
+}
+
+// wrapEvent returns a api.EventCallbackT that records power overloads
+// and gain changes and then calls next, if not nil.
+func (cd *clipDetector) wrapEvent(next api.EventCallbackT) api.EventCallbackT {
+	return func(eventId api.EventT, tuner api.TunerSelectT, params *api.EventParamsT) {
+		switch eventId {
+		case api.PowerOverloadChange:
+			if params.PowerOverloadParams.PowerOverloadChangeType == api.Overload_Detected {
+				cd.mu.Lock()
+				cd.clipped = true
+				cd.mu.Unlock()
+			}
+		case api.GainChange:
+			cd.mu.Lock()
+			cd.grdb = int32(params.GainParams.GRdB)
+			cd.reported = true
+			cd.mu.Unlock()
+		}
+		if next != nil {
+			next(eventId, tuner, params)
+		}
+	}
+}
+
+// read returns whether clipping was detected since the last call and
+// the last reported gain reduction, if any.
+func (cd *clipDetector) read() (clipped bool, grdb int32, reported bool) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	clipped = cd.clipped
+	cd.clipped = false
+	return clipped, cd.grdb, cd.reported
+}
+
+// run checks det every interval and raises the gain reduction or hands
+// control back to the AGC until ctx is canceled or an update fails.
+func (ac *AGCClamp) run(ctx context.Context, d *api.DeviceT, a api.API, det *clipDetector) error {
+	tuner := runtimeTuner(d)
+	p, err := a.LoadDeviceParams(d.Dev)
+	if err != nil {
+		return fmt.Errorf("failed to load device params: %v", a.GetLastError(d))
+	}
+	if len(runtimeChannels(d, p, tuner)) == 0 {
+		return fmt.Errorf("invalid tuner selection: got %v", tuner)
+	}
+	if err := checkChannels(d, p, tuner); err != nil {
+		return err
+	}
+	ctl := &agcClampController{cfg: *ac}
+
+	var (
+		origAgc  api.AgcControlT
+		origGRdB int32
+	)
+	ticker := time.NewTicker(ac.Interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return nil
+		case now = <-ticker.C:
+		}
+		elapsed := now.Sub(last)
+		last = now
+
+		clipped, grdb, reported := det.read()
+		engaged := ctl.engaged
+		if clipped && !engaged {
+			// Save the settings to restore and find the starting point.
+			p, err := a.LoadDeviceParams(d.Dev)
+			if err != nil {
+				return fmt.Errorf("failed to load device params: %v", a.GetLastError(d))
+			}
+			if err := checkChannels(d, p, tuner); err != nil {
+				return err
+			}
+			chans := runtimeChannels(d, p, tuner)
+			origAgc = chans[0].CtrlParams.Agc.Enable
+			origGRdB = chans[0].TunerParams.Gain.GRdB
+			if !reported || origAgc == api.AGC_DISABLE {
+				grdb = origGRdB
+			}
+		}
+
+		action, grdb := ctl.next(clipped, elapsed, grdb)
+		switch action {
+		case clampRaise:
+			err = updateRuntime(
+				d, a, tuner, api.Update_Ctrl_Agc|api.Update_Tuner_Gr, api.Update_Ext1_None,
+				func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+					c.CtrlParams.Agc.Enable = api.AGC_DISABLE
+					c.TunerParams.Gain.GRdB = grdb
+					return nil
+				},
+			)
+		case clampRelease:
+			reason := api.Update_Ctrl_Agc
+			if origAgc == api.AGC_DISABLE {
+				reason |= api.Update_Tuner_Gr
+			}
+			err = updateRuntime(
+				d, a, tuner, reason, api.Update_Ext1_None,
+				func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+					c.CtrlParams.Agc.Enable = origAgc
+					if origAgc == api.AGC_DISABLE {
+						c.TunerParams.Gain.GRdB = origGRdB
+					}
+					return nil
+				},
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestWithAGCClamp(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		step int32
+		hold time.Duration
+	}{
+		{0, time.Second},
+		{-6, time.Second},
+		{60, time.Second},
+		{6, 0},
+		{6, -time.Second},
+	}
+	for _, spec := range specs {
+		if _, err := NewSession(WithAGCClamp(spec.step, spec.hold)); err == nil {
+			t.Errorf("unexpected success for step=%d hold=%v", spec.step, spec.hold)
+		}
+	}
+	if _, err := NewSession(WithAGCClamp(6, time.Second), WithAGCClamp(6, time.Second)); err == nil {
+		t.Error("unexpected success for duplicate AGC clamp")
+	}
+}
+
+func TestAGCClampController(t *testing.T) {
+	t.Parallel()
+
+	c := &agcClampController{cfg: AGCClamp{StepDB: 10, Hold: 3 * time.Second}}
+	type step struct {
+		clipped bool
+		grdb    int32
+		action  clampAction
+		want    int32
+	}
+	steps := []step{
+		// No clipping, no intervention.
+		{false, 30, clampNone, 30},
+		// Clipping raises the gain reduction from the current value and
+		// then from the clamped value, up to the limit.
+		{true, 30, clampRaise, 40},
+		{true, 0, clampRaise, 50},
+		{true, 0, clampRaise, 59},
+		{true, 0, clampNone, 59},
+		// Control is handed back after the hold without clipping. A
+		// detection during the hold restarts it.
+		{false, 0, clampNone, 59},
+		{false, 0, clampNone, 59},
+		{true, 0, clampNone, 59},
+		{false, 0, clampNone, 59},
+		{false, 0, clampNone, 59},
+		{false, 0, clampRelease, 59},
+		// A new intervention starts from the new current value.
+		{false, 25, clampNone, 25},
+		{true, 25, clampRaise, 35},
+	}
+	for i, s := range steps {
+		action, grdb := c.next(s.clipped, time.Second, s.grdb)
+		if action != s.action || grdb != s.want {
+			t.Errorf("wrong result at step %d: got (%v, %d), want (%v, %d)", i, action, grdb, s.action, s.want)
+		}
+	}
+}
+
+func TestClipDetector(t *testing.T) {
+	t.Parallel()
+
+	det := newClipDetector(-0.1)
+	var calls int
+	fn := det.wrapStream(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		calls++
+	})
+	ev := det.wrapEvent(nil)
+
+	specs := []struct {
+		xi, xq  []int16
+		clipped bool
+	}{
+		{[]int16{0, 1000, -1000}, []int16{0, -30000, 30000}, false},
+		{[]int16{0, 1000, -32768}, []int16{0, 0, 0}, true},
+		{[]int16{0, 0, 0}, []int16{0, 32767, 0}, true},
+		{nil, nil, false},
+	}
+	for i, spec := range specs {
+		fn(spec.xi, spec.xq, &api.StreamCbParamsT{}, false)
+		if clipped, _, _ := det.read(); clipped != spec.clipped {
+			t.Errorf("wrong clip detection for %d: got %v, want %v", i, clipped, spec.clipped)
+		}
+	}
+	if calls != len(specs) {
+		t.Errorf("wrong number of calls: got %d, want %d", calls, len(specs))
+	}
+
+	params := &api.EventParamsT{}
+	params.GainParams.GRdB = 42
+	ev(api.GainChange, api.Tuner_A, params)
+	params.PowerOverloadParams.PowerOverloadChangeType = api.Overload_Detected
+	ev(api.PowerOverloadChange, api.Tuner_A, params)
+	clipped, grdb, reported := det.read()
+	if !clipped || grdb != 42 || !reported {
+		t.Errorf("wrong events: got (%v, %d, %v), want (true, 42, true)", clipped, grdb, reported)
+	}
+}
+
+// clipMock is an apitest.Mock that, once initialized, makes stream A
+// callbacks with full-scale samples while the gain reduction is less
+// than 50 dB. Otherwise, the samples are well below full scale.
+type clipMock struct {
+	*apitest.Mock
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (m *clipMock) Init(dev api.Handle, callbacks api.CallbackFnsT) error {
+	if err := m.Mock.Init(dev, callbacks); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		xi := make([]int16, 256)
+		xq := make([]int16, 256)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			p, err := m.LoadDeviceParams(dev)
+			if err != nil {
+				return
+			}
+			val := int16(1000)
+			if p.RxChannelA.TunerParams.Gain.GRdB < 50 {
+				val = 32767
+			}
+			for i := range xi {
+				xi[i] = val
+				xq[i] = -val
+			}
+			callbacks.StreamACbFn(xi, xq, &api.StreamCbParamsT{NumSamples: uint32(len(xi))}, false)
+		}
+	}(m.stop, m.done)
+	return nil
+}
+
+func (m *clipMock) Uninit(dev api.Handle) error {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop = nil
+	m.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return m.Mock.Uninit(dev)
+}
+
+func TestAGCClamp(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	m := &clipMock{Mock: apitest.NewMock(d)}
+
+	sess, err := NewSession(
+		WithImplementation(m),
+		WithSelector(),
+		WithDeviceConfig(WithSingleChannelConfig(
+			WithZeroIF(2e6, 32),
+			WithGainReduction(30),
+			WithAGC(api.AGC_50HZ, -30),
+		)),
+		WithAGCClamp(10, 250*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sess.AGCClamp.Interval = 10 * time.Millisecond
+	sess.Control = func(ctx context.Context, d *api.DeviceT, a api.API) error {
+		// Clipping stops once the gain reduction reaches 50 dB, so
+		// the clamp is released a hold after that.
+		time.Sleep(100 * time.Millisecond)
+		p, err := a.LoadDeviceParams(d.Dev)
+		if err != nil {
+			return err
+		}
+		if got := p.RxChannelA.CtrlParams.Agc.Enable; got != api.AGC_DISABLE {
+			t.Errorf("wrong AGC during intervention: got %v, want %v", got, api.AGC_DISABLE)
+		}
+		time.Sleep(400 * time.Millisecond)
+		return nil
+	}
+	if err := sess.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var reasons []api.ReasonForUpdateT
+	for _, u := range m.Updates {
+		reasons = append(reasons, u.Reason)
+	}
+	raise := api.Update_Ctrl_Agc | api.Update_Tuner_Gr
+	want := []api.ReasonForUpdateT{raise, raise, api.Update_Ctrl_Agc}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("wrong update reasons: got %v, want %v", reasons, want)
+	}
+
+	// The AGC is back in control, starting from the clamped value.
+	c := m.Params.RxChannelA
+	if got := c.CtrlParams.Agc.Enable; got != api.AGC_50HZ {
+		t.Errorf("wrong AGC after release: got %v, want %v", got, api.AGC_50HZ)
+	}
+	if got := c.TunerParams.Gain.GRdB; got != 50 {
+		t.Errorf("wrong gain reduction after release: got %d, want 50", got)
+	}
+}
+
+func TestAGCClampChannels(t *testing.T) {
+	t.Parallel()
+
+	ac := AGCClamp{ClipDBFS: -1, StepDB: 10, Hold: time.Second, Interval: time.Millisecond}
+
+	d := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner}
+	m := apitest.NewMock(d)
+	m.Params = &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+	if err := ac.run(context.Background(), d, m, newClipDetector(-1)); !errors.Is(err, ErrMissingChannel) {
+		t.Errorf("wrong error: got %v, want %v", err, ErrMissingChannel)
+	}
+
+	// A single selected tuner B is configured through RxChannelA, so a
+	// clip event does not need RxChannelB.
+	d = &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_B, RspDuoMode: api.RspDuoMode_Single_Tuner}
+	m = apitest.NewMock(d)
+	m.Params = &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+	det := newClipDetector(-1)
+	det.wrapStream(nil)([]int16{32767}, []int16{0}, &api.StreamCbParamsT{NumSamples: 1}, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := ac.run(ctx, d, m, det); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(m.Updates) == 0 {
+		t.Error("missing update after clip")
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// AutoGain is the configuration for the automatic gain calibration
+// sweep made at startup. See WithAutoGain.
+type AutoGain struct {
+	// HeadroomDB is the minimum margin in dB between the peak sample
+	// component and full scale. A step with a peak above -HeadroomDB
+	// dBFS is rejected.
+	HeadroomDB float64
+	// Settle is how long to wait after each LNA state change before
+	// measuring, so that samples from before the change are flushed.
+	Settle time.Duration
+	// Dwell is how long to measure the level at each LNA state.
+	Dwell time.Duration
+}
+
+// WithAutoGain creates a function that configures the Session to
+// select the LNA state automatically when streaming starts. Starting
+// from the maximum gain (LNA state 0), the LNA state is increased one
+// step at a time with SetLNAStateRuntime. At each step, the peak level
+// of stream A is measured over the dwell time. The sweep stops at the
+// first state, and therefore the highest gain, whose peak is at least
+// targetHeadroomDB below full scale and during which no power overload
+// was reported. If no state meets the target, the state with the least
+// gain is used. If no samples are received at all, the configured
+// state is restored.
+//
+// The defaults are a settle time of 100 ms and a dwell time of 250 ms.
+// They can be changed through the AutoGain member of the Session. The
+// IF gain reduction is not changed, so AGC should normally be disabled
+// for the sweep to be meaningful.
+//
+// The stream callbacks are not called during the sweep. With both
+// tuners of an RSPduo selected, the LNA state of both is changed, but
+// only stream A is measured. The first callback of each stream after
+// the sweep has the reset flag set, just as after Init. The
+// event callback is called as usual. The sweep runs after the transfer
+// mode is selected, if WithAutoTransferMode is used, and before the
+// control loop is started. The selected state can be read with
+// LoadDeviceParams.
+func WithAutoGain(targetHeadroomDB float64) ConfigFn {
+	return func(o *Session) error {
+		if o.AutoGain != nil {
+			return errors.New("auto gain already set")
+		}
+		if math.IsNaN(targetHeadroomDB) || targetHeadroomDB < 0 {
+			return fmt.Errorf("invalid headroom: got %v dB, want >= 0", targetHeadroomDB)
+		}
+		o.AutoGain = &AutoGain{
+			HeadroomDB: targetHeadroomDB,
+			Settle:     100 * time.Millisecond,
+			Dwell:      250 * time.Millisecond,
+		}
+		return nil
+	}
+}
+
+// gainStep is the level measured at one LNA state during the sweep.
+type gainStep struct {
+	state    uint8
+	peakDBFS float64
+	overload bool
+	samples  uint64
+}
+
+// fits returns true if the step has a measured peak with at least the
+// provided headroom and no overload.
+func (s gainStep) fits(headroomDB float64) bool {
+	return s.samples != 0 && !s.overload && s.peakDBFS <= -headroomDB
+}
+
+// selectLNAState returns the LNA state with the most gain, which is the
+// lowest state, among the steps that fit the provided headroom. If no
+// step fits, it returns the highest state measured. It returns false
+// if no step has any samples, because there is nothing to measure.
+func selectLNAState(steps []gainStep, headroomDB float64) (uint8, bool) {
+	var (
+		measured bool
+		found    bool
+		best     uint8
+		highest  uint8
+	)
+	for _, s := range steps {
+		if s.samples == 0 {
+			continue
+		}
+		if !measured || s.state > highest {
+			highest = s.state
+		}
+		measured = true
+		if s.fits(headroomDB) && (!found || s.state < best) {
+			best = s.state
+			found = true
+		}
+	}
+	switch {
+	case found:
+		return best, true
+	case measured:
+		return highest, true
+	default:
+		return 0, false
+	}
+}
+
+// levelMeter measures the peak level of stream callbacks and watches for
+// power overload events. It also holds back the callbacks from the next
+// stream callback while the sweep is running.
+type levelMeter struct {
+	mu       sync.Mutex
+	sweeping bool
+	reset    bool
+	resetB   bool
+	peak     int32
+	samples  uint64
+	overload bool
+}
+
+// wrapStream returns a api.StreamCallbackT that measures the samples
+// and calls next, if not nil, once the sweep is done.
+func (m *levelMeter) wrapStream(next api.StreamCallbackT) api.StreamCallbackT {
+	return func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		m.mu.Lock()
+		if m.sweeping {
+			for i := range xi {
+				if v := abs16(xi[i]); v > m.peak {
+					m.peak = v
+				}
+			}
+			for i := range xq {
+				if v := abs16(xq[i]); v > m.peak {
+					m.peak = v
+				}
+			}
+			m.samples += uint64(len(xi))
+			m.mu.Unlock()
+			return
+		}
+		reset = reset || m.reset
+		m.reset = false
+		m.mu.Unlock()
+		if next != nil {
+			next(xi, xq, params, reset)
+		}
+	}
+}
+
+// holdStream returns a api.StreamCallbackT that drops the samples of
+// stream B, whose level is not measured, while the sweep is running and
+// calls next, if not nil, once the sweep is done.
+func (m *levelMeter) holdStream(next api.StreamCallbackT) api.StreamCallbackT {
+	return func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		m.mu.Lock()
+		if m.sweeping {
+			m.mu.Unlock()
+			return
+		}
+		reset = reset || m.resetB
+		m.resetB = false
+		m.mu.Unlock()
+		if next != nil {
+			next(xi, xq, params, reset)
+		}
+	}
+}
+
+// wrapEvent returns a api.EventCallbackT that records power overloads
+// and calls next, if not nil.
+func (m *levelMeter) wrapEvent(next api.EventCallbackT) api.EventCallbackT {
+	return func(eventId api.EventT, tuner api.TunerSelectT, params *api.EventParamsT) {
+		if eventId == api.PowerOverloadChange && params.PowerOverloadParams.PowerOverloadChangeType == api.Overload_Detected {
+			m.mu.Lock()
+			m.overload = true
+			m.mu.Unlock()
+		}
+		if next != nil {
+			next(eventId, tuner, params)
+		}
+	}
+}
+
+// start clears the measurements.
+func (m *levelMeter) start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.peak = 0
+	m.samples = 0
+	m.overload = false
+}
+
+// read returns the measurements since the last call to start.
+func (m *levelMeter) read(state uint8) gainStep {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return gainStep{
+		state:    state,
+		peakDBFS: 20 * math.Log10(float64(m.peak)/32768),
+		overload: m.overload,
+		samples:  m.samples,
+	}
+}
+
+// setSweeping holds back the next stream callbacks while en is true.
+// Once it is set back to false, the next callback of each stream has
+// reset set.
+func (m *levelMeter) setSweeping(en bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweeping = en
+	if !en {
+		m.reset = true
+		m.resetB = true
+	}
+}
+
+// abs16 returns the absolute value of v without overflow.
+func abs16(v int16) int32 {
+	if v < 0 {
+		return -int32(v)
+	}
+	return int32(v)
+}
+
+// sweep steps through the LNA states, measuring each with meter, and
+// sets the selected state.
+func (ag *AutoGain) sweep(ctx context.Context, d *api.DeviceT, a api.API, meter *levelMeter) error {
+	tuner := runtimeTuner(d)
+	p, err := a.LoadDeviceParams(d.Dev)
+	if err != nil {
+		return fmt.Errorf("failed to load device params: %v", a.GetLastError(d))
+	}
+	chans := runtimeChannels(d, p, tuner)
+	if len(chans) == 0 {
+		return fmt.Errorf("invalid tuner selection: got %v", tuner)
+	}
+	if err := checkChannels(d, p, tuner); err != nil {
+		return err
+	}
+	orig := chans[0].TunerParams.Gain.LNAstate
+	max := GetMaxLNAState(d, p, chans[0])
+
+	meter.setSweeping(true)
+	defer meter.setSweeping(false)
+
+	var steps []gainStep
+	for state := uint8(0); state <= max; state++ {
+		if err := SetLNAStateRuntime(d, a, tuner, state); err != nil {
+			return err
+		}
+		if err := sleepContext(ctx, ag.Settle); err != nil {
+			return err
+		}
+		meter.start()
+		if err := sleepContext(ctx, ag.Dwell); err != nil {
+			return err
+		}
+		step := meter.read(state)
+		steps = append(steps, step)
+		if step.fits(ag.HeadroomDB) {
+			break
+		}
+	}
+
+	state, ok := selectLNAState(steps, ag.HeadroomDB)
+	if !ok {
+		state = orig
+	}
+	if state == steps[len(steps)-1].state {
+		return nil
+	}
+	return SetLNAStateRuntime(d, a, tuner, state)
+}
+
+// sleepContext waits for the provided duration or until ctx is canceled.
+func sleepContext(ctx context.Context, dur time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(dur):
+		return nil
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+// lnaStepDB is the gain reduction per LNA state of the synthetic
+// receiver used by the tests.
+const lnaStepDB = 6
+
+// syntheticStep returns the step measured by a synthetic receiver whose
+// peak level at LNA state 0 is inputDBFS. Levels above full scale are
+// clipped and reported as an overload.
+func syntheticStep(inputDBFS float64, state uint8) gainStep {
+	level := inputDBFS - lnaStepDB*float64(state)
+	return gainStep{
+		state:    state,
+		peakDBFS: math.Min(level, 0),
+		overload: level > 0,
+		samples:  1000,
+	}
+}
+
+func TestSelectLNAState(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		input    float64
+		headroom float64
+		max      uint8
+		want     uint8
+	}{
+		{-20, 6, 9, 0},
+		{-6, 6, 9, 0},
+		{-5, 6, 9, 1},
+		{0, 6, 9, 1},
+		{10, 6, 9, 3},
+		{10, 0, 9, 2},
+		{10, 20, 9, 5},
+		{50, 6, 6, 6}, // nothing fits, so least gain
+	}
+
+	for _, spec := range specs {
+		var steps []gainStep
+		for s := uint8(0); s <= spec.max; s++ {
+			steps = append(steps, syntheticStep(spec.input, s))
+		}
+		got, ok := selectLNAState(steps, spec.headroom)
+		if !ok || got != spec.want {
+			t.Errorf(
+				"wrong state for %v dBFS with %v dB headroom: got %d (%v), want %d",
+				spec.input, spec.headroom, got, ok, spec.want,
+			)
+		}
+	}
+
+	// An overload rejects a step regardless of the measured peak.
+	steps := []gainStep{
+		{state: 0, peakDBFS: -10, overload: true, samples: 100},
+		{state: 1, peakDBFS: -16, samples: 100},
+	}
+	if got, ok := selectLNAState(steps, 6); !ok || got != 1 {
+		t.Errorf("wrong state with overload: got %d (%v), want 1", got, ok)
+	}
+
+	// Steps without samples are ignored.
+	steps = []gainStep{
+		{state: 0, peakDBFS: math.Inf(-1)},
+		{state: 1, peakDBFS: -3, samples: 100},
+		{state: 2, peakDBFS: math.Inf(-1)},
+	}
+	if got, ok := selectLNAState(steps, 6); !ok || got != 1 {
+		t.Errorf("wrong state with missing samples: got %d (%v), want 1", got, ok)
+	}
+	if _, ok := selectLNAState(steps[:1], 6); ok {
+		t.Error("unexpected selection without samples")
+	}
+	if _, ok := selectLNAState(nil, 6); ok {
+		t.Error("unexpected selection without steps")
+	}
+}
+
+func TestWithAutoGain(t *testing.T) {
+	t.Parallel()
+
+	for _, headroom := range []float64{-1, math.NaN()} {
+		if _, err := NewSession(WithAutoGain(headroom)); err == nil {
+			t.Errorf("unexpected success for headroom %v", headroom)
+		}
+	}
+	if _, err := NewSession(WithAutoGain(6), WithAutoGain(6)); err == nil {
+		t.Error("unexpected success for duplicate auto gain")
+	}
+}
+
+// levelMock is an apitest.Mock that, once initialized, makes stream
+// callbacks with the level of a synthetic receiver for the current LNA
+// state and reports a power overload when the level is clipped.
+type levelMock struct {
+	*apitest.Mock
+	inputDBFS float64
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (m *levelMock) Init(dev api.Handle, callbacks api.CallbackFnsT) error {
+	if err := m.Mock.Init(dev, callbacks); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		xi := make([]int16, 100)
+		xq := make([]int16, 100)
+		var sampleNum uint32
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			p, err := m.LoadDeviceParams(dev)
+			if err != nil {
+				return
+			}
+			step := syntheticStep(m.inputDBFS, p.RxChannelA.TunerParams.Gain.LNAstate)
+			amp := int16(math.Min(32767, 32768*math.Pow(10, step.peakDBFS/20)))
+			for n := range xi {
+				xi[n] = amp
+				xq[n] = -amp
+			}
+			if step.overload && callbacks.EventCbFn != nil {
+				callbacks.EventCbFn(api.PowerOverloadChange, api.Tuner_A, &api.EventParamsT{
+					PowerOverloadParams: api.PowerOverloadCbParamT{PowerOverloadChangeType: api.Overload_Detected},
+				})
+			}
+			callbacks.StreamACbFn(xi, xq, &api.StreamCbParamsT{FirstSampleNum: sampleNum, NumSamples: uint32(len(xi))}, i == 0)
+			sampleNum += uint32(len(xi))
+		}
+	}(m.stop, m.done)
+	return nil
+}
+
+func (m *levelMock) Uninit(dev api.Handle) error {
+	m.mu.Lock()
+	if m.stop != nil {
+		close(m.stop)
+		<-m.done
+		m.stop = nil
+	}
+	m.mu.Unlock()
+	return m.Mock.Uninit(dev)
+}
+
+func TestAutoGainSweep(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		input       float64
+		want        uint8
+		wantUpdates int
+	}{
+		{-20, 0, 1},
+		{10, 3, 4},
+		{60, 6, 7}, // nothing fits, so least gain
+	}
+
+	for _, spec := range specs {
+		d := &api.DeviceT{HWVer: api.RSP1A_ID}
+		m := &levelMock{Mock: apitest.NewMock(d), inputDBFS: spec.input}
+
+		var (
+			mu     sync.Mutex
+			calls  int
+			first  bool
+			peak   int16
+			states []uint8
+		)
+		sess, err := NewSession(
+			WithImplementation(m),
+			WithSelector(),
+			WithAutoGain(6),
+			WithStreamACallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+				mu.Lock()
+				defer mu.Unlock()
+				if calls == 0 {
+					first = reset
+					peak = xi[0]
+				}
+				calls++
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sess.AutoGain.Settle = 5 * time.Millisecond
+		sess.AutoGain.Dwell = 20 * time.Millisecond
+		sess.Control = func(ctx context.Context, d *api.DeviceT, a api.API) error {
+			p, err := a.LoadDeviceParams(d.Dev)
+			if err != nil {
+				return err
+			}
+			states = append(states, p.RxChannelA.TunerParams.Gain.LNAstate)
+			// Wait for callbacks after the sweep.
+			for i := 0; i < 1000; i++ {
+				mu.Lock()
+				n := calls
+				mu.Unlock()
+				if n > 0 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			return nil
+		}
+
+		if err := sess.Run(context.Background()); err != nil {
+			t.Fatalf("unexpected error for %v dBFS: %v", spec.input, err)
+		}
+		if len(states) != 1 || states[0] != spec.want {
+			t.Errorf("wrong LNA state for %v dBFS: got %v, want %d", spec.input, states, spec.want)
+		}
+		if got := len(m.Updates); got != spec.wantUpdates {
+			t.Errorf("wrong number of updates for %v dBFS: got %d, want %d", spec.input, got, spec.wantUpdates)
+		}
+		mu.Lock()
+		if calls == 0 || !first {
+			t.Errorf("wrong first callback for %v dBFS: got calls=%d reset=%v, want reset", spec.input, calls, first)
+		}
+		// The first callback after the sweep has the selected gain.
+		if want := syntheticStep(spec.input, spec.want); calls > 0 && peak != int16(math.Min(32767, 32768*math.Pow(10, want.peakDBFS/20))) {
+			t.Errorf("wrong first callback level for %v dBFS: got %d, want state %d level", spec.input, peak, spec.want)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestLevelMeterHoldStream(t *testing.T) {
+	t.Parallel()
+
+	var (
+		m      levelMeter
+		calls  int
+		resets []bool
+	)
+	fn := m.holdStream(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		calls++
+		resets = append(resets, reset)
+	})
+	x := make([]int16, 4)
+
+	m.setSweeping(true)
+	fn(x, x, &api.StreamCbParamsT{}, false)
+	if calls != 0 {
+		t.Fatalf("wrong number of calls during sweep: got %d, want 0", calls)
+	}
+
+	// Stream B has the reset flag after the sweep, just like stream A.
+	m.setSweeping(false)
+	fn(x, x, &api.StreamCbParamsT{}, false)
+	fn(x, x, &api.StreamCbParamsT{}, false)
+	if want := []bool{true, false}; len(resets) != len(want) || resets[0] != want[0] || resets[1] != want[1] {
+		t.Errorf("wrong reset flags: got %v, want %v", resets, want)
+	}
+}
+
+func TestAutoGainSweepMissingChannel(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner}
+	m := apitest.NewMock(d)
+	m.Params = &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelB: &api.RxChannelParamsT{}}
+	ag := AutoGain{Settle: time.Millisecond, Dwell: time.Millisecond}
+	if err := ag.sweep(context.Background(), d, m, &levelMeter{}); !errors.Is(err, ErrMissingChannel) {
+		t.Errorf("wrong error: got %v, want %v", err, ErrMissingChannel)
+	}
+	if len(m.Updates) != 0 {
+		t.Errorf("unexpected updates: %+v", m.Updates)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// AutoTransfer is the configuration for automatic USB transfer mode
+// selection. See WithAutoTransferMode.
+type AutoTransfer struct {
+	// Warmup is how long to stream in isochronous mode before measuring
+	// the drop rate.
+	Warmup time.Duration
+	// MaxDropPercent is the largest percentage of dropped samples during
+	// warm-up that is tolerated before falling back to bulk mode.
+	MaxDropPercent float64
+}
+
+// WithAutoTransferMode creates a function that configures the Session
+// to select the USB transfer mode automatically. The device is first
+// initialized in isochronous mode, overriding any mode set by the device
+// configuration. After streaming for the warmup duration, the percentage
+// of dropped samples on stream A is measured with a TransferStats. If it
+// exceeds maxDropPercent, the device is uninitialized, reconfigured for
+// bulk mode, and initialized again with the same callbacks. The first
+// callback after the reinitialization has the reset flag set, just as
+// after the initial Init, so callbacks that track stream continuity (e.g.
+// DropDetectFn) recover without extra handling.
+//
+// The control loop, if any, is only started after the mode has been
+// selected. The selected mode can be read from DevParams.Mode with
+// LoadDeviceParams. It is an error to use this with an RSPduo in slave
+// mode, because the transfer mode is controlled by the master.
+func WithAutoTransferMode(warmup time.Duration, maxDropPercent float64) ConfigFn {
+	return func(o *Session) error {
+		if o.AutoTransfer != nil {
+			return errors.New("auto transfer mode already set")
+		}
+		if warmup <= 0 {
+			return fmt.Errorf("invalid warmup duration: got %v, want > 0", warmup)
+		}
+		if maxDropPercent < 0 || maxDropPercent >= 100 {
+			return fmt.Errorf("invalid max drop percent: got %v, want 0 <= pct < 100", maxDropPercent)
+		}
+		o.AutoTransfer = &AutoTransfer{
+			Warmup:         warmup,
+			MaxDropPercent: maxDropPercent,
+		}
+		return nil
+	}
+}
+
+// selectTransferMode returns the transfer mode to use given the report
+// collected during warm-up in isochronous mode. A drop rate above the
+// threshold selects bulk mode. Without at least two callbacks there is
+// nothing to measure, so the report is treated as drop-free.
+func selectTransferMode(r TransferReport, maxDropPercent float64) api.TransferModeT {
+	if r.Callbacks < 2 || r.DropPercent <= maxDropPercent {
+		return api.ISOCH
+	}
+	return api.BULK
+}
+
+// warmup waits for the warm-up duration or until ctx is canceled and
+// then returns the transfer mode selected from the collected stats.
+func (at *AutoTransfer) warmup(ctx context.Context, stats *TransferStats) (api.TransferModeT, error) {
+	select {
+	case <-ctx.Done():
+		return api.ISOCH, ctx.Err()
+	case <-time.After(at.Warmup):
+	}
+	return selectTransferMode(stats.Report(), at.MaxDropPercent), nil
+}
+
+// initTransferMode stores the provided transfer mode and initializes
+// the device with callbacks. The device must be uninitialized.
+func initTransferMode(d *api.DeviceT, a api.API, callbacks api.CallbackFnsT, mode api.TransferModeT) error {
+	p, err := a.LoadDeviceParams(d.Dev)
+	if err != nil {
+		return fmt.Errorf("failed to load device params: %v", a.GetLastError(d))
+	}
+	p.DevParams.Mode = mode
+	if err := a.StoreDeviceParams(d.Dev, p); err != nil {
+		return fmt.Errorf("failed to store device params: %v", a.GetLastError(d))
+	}
+	if err := a.Init(d.Dev, callbacks); err != nil {
+		return fmt.Errorf("init failed: %v", a.GetLastError(d))
+	}
+	return nil
+}
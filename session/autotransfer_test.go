@@ -0,0 +1,187 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestSelectTransferMode(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		r    TransferReport
+		max  float64
+		want api.TransferModeT
+	}{
+		{TransferReport{Callbacks: 100, DropPercent: 0}, 1, api.ISOCH},
+		{TransferReport{Callbacks: 100, DropPercent: 0.5}, 1, api.ISOCH},
+		{TransferReport{Callbacks: 100, DropPercent: 1}, 1, api.ISOCH},
+		{TransferReport{Callbacks: 100, DropPercent: 1.01}, 1, api.BULK},
+		{TransferReport{Callbacks: 100, DropPercent: 20}, 1, api.BULK},
+		{TransferReport{Callbacks: 100, DropPercent: 0.001}, 0, api.BULK},
+		{TransferReport{Callbacks: 1, DropPercent: 50}, 1, api.ISOCH},
+		{TransferReport{}, 1, api.ISOCH},
+	}
+
+	for _, spec := range specs {
+		if got := selectTransferMode(spec.r, spec.max); got != spec.want {
+			t.Errorf("wrong mode for %+v with max=%v: got %v, want %v", spec.r, spec.max, got, spec.want)
+		}
+	}
+}
+
+// dropMock is an apitest.Mock that delivers a burst of stream callbacks
+// from Init, dropping every dropEvery-th callback worth of samples while
+// in isochronous mode.
+type dropMock struct {
+	*apitest.Mock
+	dropEvery int
+}
+
+func (m *dropMock) Init(dev api.Handle, callbacks api.CallbackFnsT) error {
+	if err := m.Mock.Init(dev, callbacks); err != nil {
+		return err
+	}
+	p, err := m.LoadDeviceParams(dev)
+	if err != nil {
+		return err
+	}
+	const numSamples = 100
+	var sampleNum uint32
+	for i := 0; i < 100; i++ {
+		if p.DevParams.Mode == api.ISOCH && m.dropEvery > 0 && i > 0 && i%m.dropEvery == 0 {
+			sampleNum += numSamples
+		}
+		callbacks.StreamACbFn(nil, nil, &api.StreamCbParamsT{FirstSampleNum: sampleNum, NumSamples: numSamples}, i == 0)
+		sampleNum += numSamples
+	}
+	return nil
+}
+
+func TestWithAutoTransferMode(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		dropEvery int
+		want      api.TransferModeT
+		wantInits int
+	}{
+		{0, api.ISOCH, 1},
+		{50, api.ISOCH, 1}, // 1% dropped
+		{5, api.BULK, 2},   // 16% dropped
+	}
+
+	for _, spec := range specs {
+		m := &dropMock{Mock: apitest.NewMock(&api.DeviceT{HWVer: api.RSP1A_ID}), dropEvery: spec.dropEvery}
+		var resets int
+		var mode api.TransferModeT
+		err := Run(
+			context.Background(),
+			WithImplementation(m),
+			// Ask for bulk to check that warm-up always starts in isoch.
+			WithDeviceConfig(WithTransferMode(api.BULK)),
+			WithAutoTransferMode(time.Millisecond, 2),
+			WithStreamACallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+				if reset {
+					resets++
+				}
+			}),
+			WithControlLoop(func(ctx context.Context, d *api.DeviceT, a api.API) error {
+				p, err := a.LoadDeviceParams(d.Dev)
+				if err != nil {
+					return err
+				}
+				mode = p.DevParams.Mode
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if mode != spec.want {
+			t.Errorf("wrong mode with dropEvery=%d: got %v, want %v", spec.dropEvery, mode, spec.want)
+		}
+
+		var numInit, numUninit int
+		for _, call := range m.Calls {
+			switch call {
+			case "Init":
+				numInit++
+			case "Uninit":
+				numUninit++
+			}
+		}
+		if numInit != spec.wantInits || numUninit != spec.wantInits {
+			t.Errorf("wrong number of Init/Uninit with dropEvery=%d: got %d/%d, want %d/%d", spec.dropEvery, numInit, numUninit, spec.wantInits, spec.wantInits)
+		}
+		// Each Init delivers a reset to the user callback.
+		if resets != spec.wantInits {
+			t.Errorf("wrong number of resets with dropEvery=%d: got %d, want %d", spec.dropEvery, resets, spec.wantInits)
+		}
+	}
+}
+
+func TestAutoTransferModeUninitFailure(t *testing.T) {
+	t.Parallel()
+
+	// If the Uninit before the switch to bulk fails, the device is still
+	// initialized and Uninit is tried again on the way out.
+	m := &dropMock{Mock: apitest.NewMock(&api.DeviceT{HWVer: api.RSP1A_ID}), dropEvery: 5}
+	m.Errors = map[string]error{"Uninit": errors.New("uninit failed")}
+	err := Run(
+		context.Background(),
+		WithImplementation(m),
+		WithAutoTransferMode(time.Millisecond, 2),
+		WithControlLoop(func(ctx context.Context, d *api.DeviceT, a api.API) error {
+			t.Error("unexpected control loop")
+			return nil
+		}),
+	)
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	var numInit, numUninit int
+	for _, call := range m.Calls {
+		switch call {
+		case "Init":
+			numInit++
+		case "Uninit":
+			numUninit++
+		}
+	}
+	if numInit != 1 || numUninit != 2 {
+		t.Errorf("wrong number of Init/Uninit: got %d/%d, want 1/2", numInit, numUninit)
+	}
+}
+
+func TestWithAutoTransferModeInvalid(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		warmup time.Duration
+		max    float64
+	}{
+		{0, 1},
+		{-time.Second, 1},
+		{time.Second, -1},
+		{time.Second, 100},
+	}
+	for _, spec := range specs {
+		if _, err := NewSession(WithAutoTransferMode(spec.warmup, spec.max)); err == nil {
+			t.Errorf("unexpected success for warmup=%v max=%v", spec.warmup, spec.max)
+		}
+	}
+	if _, err := NewSession(WithAutoTransferMode(time.Second, 1), WithAutoTransferMode(time.Second, 1)); err == nil {
+		t.Error("unexpected success for repeated option")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// AvailableDevices opens the API, lists the devices with the device API
+// locked, and returns only the entries that can currently be selected,
+// in the order produced by SortDevices. If impl is nil, api.GetAPI() is
+// used. It is intended to populate a device picker without offering
+// devices that would fail in SelectDevice.
+//
+// The API service only lists devices that are not held by another
+// process, with one exception. An RSPduo that is streaming in another
+// process can still be listed, with the RspDuoMode and Tuner fields
+// reduced to the modes and tuners that are still free (e.g. only
+// RspDuoMode_Secondary on the unused tuner). An RSPduo entry with no
+// free mode or no free tuner is not selectable and is omitted. All other
+// entries are returned as listed.
+func AvailableDevices(impl api.API) ([]*api.DeviceT, error) {
+	devs, err := listDevices(impl)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*api.DeviceT
+	for _, dev := range SortDevices(devs) {
+		if Selectable(dev) {
+			res = append(res, dev)
+		}
+	}
+	return res, nil
+}
+
+// listDevices opens the API, calls GetDevices with the device API locked,
+// and closes the API. If impl is nil, api.GetAPI() is used.
+func listDevices(impl api.API) ([]*api.DeviceT, error) {
+	if impl == nil {
+		impl = api.GetAPI()
+	}
+	if err := openAPI(impl); err != nil {
+		return nil, err
+	}
+	defer impl.Close()
+
+	if err := impl.LockDeviceApi(); err != nil {
+		return nil, fmt.Errorf("failed to lock API: %v", impl.GetLastError(nil))
+	}
+	defer func() {
+		if err := impl.UnlockDeviceApi(); err != nil {
+			fmt.Fprintf(os.Stderr, "UnlockDeviceApi failed: %v", err)
+		}
+	}()
+
+	devs, err := impl.GetDevices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device list: %v", impl.GetLastError(nil))
+	}
+	return devs, nil
+}
+
+// Selectable returns true if the provided device entry, as returned by
+// GetDevices, describes a device that can be selected. Any non-nil entry
+// for a device other than an RSPduo is selectable. An RSPduo entry is
+// selectable only if it has at least one free mode and one free tuner.
+func Selectable(d *api.DeviceT) bool {
+	if d == nil {
+		return false
+	}
+	if d.HWVer != api.RSPduo_ID {
+		return true
+	}
+	const allModes = api.RspDuoMode_Single_Tuner |
+		api.RspDuoMode_Dual_Tuner |
+		api.RspDuoMode_Primary |
+		api.RspDuoMode_Secondary
+	return d.RspDuoMode&allModes != 0 && d.Tuner&api.Tuner_Both != 0
+}
+
+// DescribeDevice returns a short, single-line description of a device
+// entry suitable for a device picker (e.g. "RSPduo_ID 1234567890 Tuner_B
+// Secondary@6MHz"). For an RSPduo, the description includes the free
+// tuners and modes and, for a secondary entry, the sample rate fixed by
+// the primary.
+func DescribeDevice(d *api.DeviceT) string {
+	if d == nil {
+		return "none"
+	}
+	if d.HWVer != api.RSPduo_ID {
+		return fmt.Sprintf("%v %v", d.HWVer, d.SerNo)
+	}
+	desc := fmt.Sprintf("%v %v %v %v", d.HWVer, d.SerNo, d.Tuner, d.RspDuoMode)
+	if d.RspDuoMode.HasSecondary() && d.RspDuoSampleFreq > 0 {
+		desc += fmt.Sprintf("@%vMHz", d.RspDuoSampleFreq/1e6)
+	}
+	return desc
+}
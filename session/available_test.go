@@ -0,0 +1,89 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestAvailableDevices(t *testing.T) {
+	t.Parallel()
+
+	var (
+		a    = api.ParseSerialNumber("1000000001")
+		free = api.ParseSerialNumber("1500000001")
+		busy = api.ParseSerialNumber("1500000002")
+		half = api.ParseSerialNumber("1500000003")
+		all  = api.RspDuoMode_Single_Tuner | api.RspDuoMode_Dual_Tuner | api.RspDuoMode_Primary
+	)
+
+	devs := []*api.DeviceT{
+		{SerNo: a, HWVer: api.RSP1A_ID},
+		// Idle RSPduo with every mode available.
+		{SerNo: free, HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: all},
+		// RSPduo in dual tuner mode in another process.
+		{SerNo: busy, HWVer: api.RSPduo_ID, Tuner: api.Tuner_Neither, RspDuoMode: api.RspDuoMode_Unknown},
+		// RSPduo with no free tuner, but a stale mode.
+		{SerNo: busy, HWVer: api.RSPduo_ID, Tuner: api.Tuner_Neither, RspDuoMode: api.RspDuoMode_Secondary},
+		// RSPduo primary on tuner A in another process.
+		{SerNo: half, HWVer: api.RSPduo_ID, Tuner: api.Tuner_B, RspDuoMode: api.RspDuoMode_Secondary, RspDuoSampleFreq: 8e6},
+		// RSPduo with a free tuner but no free mode.
+		{SerNo: half, HWVer: api.RSPduo_ID, Tuner: api.Tuner_A, RspDuoMode: api.RspDuoMode_Unknown},
+		nil,
+	}
+	m := apitest.NewMock(devs...)
+
+	got, err := AvailableDevices(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*api.DeviceT{devs[1], devs[4], devs[0]}
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of devices: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != *want[i] {
+			t.Errorf("wrong device at %d: got %+v, want %+v", i, *got[i], *want[i])
+		}
+	}
+
+	wantCalls := []string{"Open", "LockDeviceApi", "GetDevices", "UnlockDeviceApi", "Close"}
+	if len(m.Calls) != len(wantCalls) {
+		t.Fatalf("wrong calls: got %v, want %v", m.Calls, wantCalls)
+	}
+	for i := range wantCalls {
+		if m.Calls[i] != wantCalls[i] {
+			t.Errorf("wrong call at %d: got %s, want %s", i, m.Calls[i], wantCalls[i])
+		}
+	}
+
+	wantDesc := []string{
+		"RSPduo_ID 1500000001 Tuner_Both Single|Dual|Primary",
+		"RSPduo_ID 1500000003 Tuner_B Secondary@8MHz",
+		"RSP1A_ID 1000000001",
+	}
+	for i := range wantDesc {
+		if desc := DescribeDevice(got[i]); desc != wantDesc[i] {
+			t.Errorf("wrong description at %d: got %q, want %q", i, desc, wantDesc[i])
+		}
+	}
+}
+
+func TestAvailableDevicesError(t *testing.T) {
+	t.Parallel()
+
+	m := apitest.NewMock(&api.DeviceT{HWVer: api.RSP1A_ID})
+	m.Errors = map[string]error{"GetDevices": errors.New("boom")}
+	if _, err := AvailableDevices(m); err == nil {
+		t.Fatal("unexpected success")
+	}
+	if last := m.Calls[len(m.Calls)-1]; last != "Close" {
+		t.Errorf("wrong last call: got %s, want Close", last)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// BiasTStatus is the bias-T state of a channel as reported by
+// GetBiasTStatus.
+type BiasTStatus struct {
+	// Enabled is true if bias-T power is enabled.
+	Enabled bool
+}
+
+// GetBiasTStatus returns the bias-T state of the specified channel. If
+// the device does not have a bias-T, the returned status is not enabled.
+//
+// The API version supported by this module does not report bias-T
+// faults, so a tripped over-current protection (e.g. due to a shorted
+// feedline) can only be inferred indirectly, such as from a sudden loss
+// of signal level.
+func GetBiasTStatus(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) (BiasTStatus, error) {
+	var res BiasTStatus
+	if c == nil {
+		return res, errors.New("cannot inspect nil channel")
+	}
+	if !api.DeviceCapabilities(d).HasBiasT {
+		return res, nil
+	}
+	switch d.HWVer {
+	case api.RSP1A_ID:
+		res.Enabled = c.Rsp1aTunerParams.BiasTEnable != 0
+	case api.RSP2_ID:
+		res.Enabled = c.Rsp2TunerParams.BiasTEnable != 0
+	case api.RSPduo_ID:
+		res.Enabled = c.RspDuoTunerParams.BiasTEnable != 0
+	case api.RSPdx_ID:
+		if p.DevParams == nil {
+			return res, errors.New("cannot inspect RSPdx bias-T without device params")
+		}
+		res.Enabled = p.DevParams.RspDxParams.BiasTEnable != 0
+	}
+	return res, nil
+}
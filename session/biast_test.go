@@ -0,0 +1,67 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestGetBiasTStatus(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		hw  api.HWVersion
+		set func(p *api.DeviceParamsT)
+	}{
+		{api.RSP1A_ID, func(p *api.DeviceParamsT) { p.RxChannelA.Rsp1aTunerParams.BiasTEnable = 1 }},
+		{api.RSP2_ID, func(p *api.DeviceParamsT) { p.RxChannelA.Rsp2TunerParams.BiasTEnable = 1 }},
+		{api.RSPduo_ID, func(p *api.DeviceParamsT) { p.RxChannelA.RspDuoTunerParams.BiasTEnable = 1 }},
+		{api.RSPdx_ID, func(p *api.DeviceParamsT) { p.DevParams.RspDxParams.BiasTEnable = 1 }},
+	}
+
+	for _, spec := range specs {
+		d := &api.DeviceT{HWVer: spec.hw}
+		m := apitest.NewMock(d)
+		p, err := m.LoadDeviceParams(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := GetBiasTStatus(d, p, p.RxChannelA)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", spec.hw, err)
+		}
+		if got != (BiasTStatus{}) {
+			t.Errorf("%v: wrong default status: got %+v, want %+v", spec.hw, got, BiasTStatus{})
+		}
+
+		spec.set(p)
+		if err := m.StoreDeviceParams(nil, p); err != nil {
+			t.Fatal(err)
+		}
+		p, err = m.LoadDeviceParams(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = GetBiasTStatus(d, p, p.RxChannelA)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", spec.hw, err)
+		}
+		if want := (BiasTStatus{Enabled: true}); got != want {
+			t.Errorf("%v: wrong status: got %+v, want %+v", spec.hw, got, want)
+		}
+	}
+
+	d := &api.DeviceT{HWVer: api.RSP1_ID}
+	if got, err := GetBiasTStatus(d, &api.DeviceParamsT{}, &api.RxChannelParamsT{}); err != nil || got.Enabled {
+		t.Errorf("wrong RSP1 status: got %+v, %v", got, err)
+	}
+	if _, err := GetBiasTStatus(d, &api.DeviceParamsT{}, nil); err == nil {
+		t.Error("unexpected success with nil channel")
+	}
+}
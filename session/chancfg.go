@@ -7,6 +7,7 @@ package session
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/msiner/sdrplay-go/api"
 )
@@ -29,35 +30,41 @@ func NoopChanConfig(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParams
 	return nil
 }
 
+// applyChanConfig applies fns to the channel params c, named name in
+// p. It returns an error matching ErrMissingChannel if c is nil.
+func applyChanConfig(d *api.DeviceT, p *api.DeviceParamsT, name string, c *api.RxChannelParamsT, fns []ChanConfigFn) error {
+	if c == nil {
+		return wrapError(fmt.Sprintf("%v: %s", ErrMissingChannel, name), ErrMissingChannel)
+	}
+	for _, fn := range fns {
+		if err := fn(d, p, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // WithSingleChannelConfig creates a DevConfigFn that applies the given
 // ChanConfigFn function list to the selected device and channel. The function
 // returns an error if the device is an RSPduo with neither or both channels
 // selected. Otherwise, it applies the channel configuration to RxChannelA.
+// It returns an error matching ErrMissingChannel if RxChannelA is nil.
 func WithSingleChannelConfig(fns ...ChanConfigFn) DevConfigFn {
 	return func(d *api.DeviceT, p *api.DeviceParamsT) error {
-		apply := func(c *api.RxChannelParamsT) error {
-			for _, fn := range fns {
-				if err := fn(d, p, c); err != nil {
-					return err
-				}
-			}
-			return nil
-		}
-
 		switch d.HWVer {
 		case api.RSPduo_ID:
 			switch d.Tuner {
 			case api.Tuner_A:
-				return apply(p.RxChannelA)
+				return applyChanConfig(d, p, "RxChannelA", p.RxChannelA, fns)
 			case api.Tuner_B:
-				return apply(p.RxChannelA)
+				return applyChanConfig(d, p, "RxChannelA", p.RxChannelA, fns)
 			case api.Tuner_Both:
-				return errors.New("attempting single channel config in dual-tuner mode")
+				return ErrSingleConfigInDual
 			default:
 				return errors.New("no tuner selected")
 			}
 		default:
-			return apply(p.RxChannelA)
+			return applyChanConfig(d, p, "RxChannelA", p.RxChannelA, fns)
 		}
 	}
 }
@@ -66,28 +73,19 @@ func WithSingleChannelConfig(fns ...ChanConfigFn) DevConfigFn {
 // ChanConfigFn function list to the selected device and channel if the
 // device is an RSPduo and tuner A or both tuners are selected. The
 // function will return an error if the device is an RSPduo with only
-// tuner B selected. If the device is not an RSPduo, the function does
-// nothing.
+// tuner B selected or if RxChannelA is nil. If the device is not an
+// RSPduo, the function does nothing.
 func WithDuoChannelAConfig(fns ...ChanConfigFn) DevConfigFn {
 	return func(d *api.DeviceT, p *api.DeviceParamsT) error {
-		apply := func(c *api.RxChannelParamsT) error {
-			for _, fn := range fns {
-				if err := fn(d, p, c); err != nil {
-					return err
-				}
-			}
-			return nil
-		}
-
 		switch d.HWVer {
 		case api.RSPduo_ID:
 			switch d.Tuner {
 			case api.Tuner_A:
-				return apply(p.RxChannelA)
+				return applyChanConfig(d, p, "RxChannelA", p.RxChannelA, fns)
 			case api.Tuner_B:
 				return errors.New("attempting channel A config when tuner B is selected")
 			case api.Tuner_Both:
-				return apply(p.RxChannelA)
+				return applyChanConfig(d, p, "RxChannelA", p.RxChannelA, fns)
 			default:
 				return errors.New("no tuner selected")
 			}
@@ -101,28 +99,19 @@ func WithDuoChannelAConfig(fns ...ChanConfigFn) DevConfigFn {
 // ChanConfigFn function list to the selected device and channel if the
 // device is an RSPduo and tuner B or both tuners are selected. The
 // function will return an error if the device is an RSPduo with only
-// tuner A selected. If the device is not an RSPduo, the function does
-// nothing.
+// tuner A selected or if RxChannelB is nil. If the device is not an
+// RSPduo, the function does nothing.
 func WithDuoChannelBConfig(fns ...ChanConfigFn) DevConfigFn {
 	return func(d *api.DeviceT, p *api.DeviceParamsT) error {
-		apply := func(c *api.RxChannelParamsT) error {
-			for _, fn := range fns {
-				if err := fn(d, p, c); err != nil {
-					return err
-				}
-			}
-			return nil
-		}
-
 		switch d.HWVer {
 		case api.RSPduo_ID:
 			switch d.Tuner {
 			case api.Tuner_A:
 				return errors.New("attempting channel B config when tuner A is selected")
 			case api.Tuner_B:
-				return apply(p.RxChannelB)
+				return applyChanConfig(d, p, "RxChannelB", p.RxChannelB, fns)
 			case api.Tuner_Both:
-				return apply(p.RxChannelB)
+				return applyChanConfig(d, p, "RxChannelB", p.RxChannelB, fns)
 			default:
 				return errors.New("no tuner selected")
 			}
@@ -152,6 +141,10 @@ func WithTuneFreq(freq float64) ChanConfigFn {
 }
 
 // SetGainReduction configures the gain reduction for the specified channel.
+// It also selects the minimum gain reduction mode: extended
+// (api.EXTENDED_MIN_GR) if grdb is less than 20 and normal
+// (api.NORMAL_MIN_GR) otherwise. Use SetExtendedGainReduction after it
+// to choose the mode explicitly.
 func SetGainReduction(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, grdb int32) error {
 	if c == nil {
 		return errors.New("cannot configure nil channel")
@@ -178,6 +171,56 @@ func WithGainReduction(grdb int32) ChanConfigFn {
 	}
 }
 
+// SetExtendedGainReduction selects the minimum gain reduction mode of
+// the specified channel independently of the GRdB value. If en is true,
+// the extended mode (api.EXTENDED_MIN_GR) is selected, which allows a
+// gain reduction below 20 dB. Otherwise, the normal mode
+// (api.NORMAL_MIN_GR) is selected. Because SetGainReduction also selects
+// the mode from its GRdB value, this function must be applied after it
+// for the choice to take effect. SetGainReduction selects the extended
+// mode for a GRdB below 20, which the normal mode cannot provide, so it
+// returns an error if en is false and the GRdB is below 20.
+func SetExtendedGainReduction(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, en bool) error {
+	if c == nil {
+		return errors.New("cannot configure nil channel")
+	}
+	if grdb := c.TunerParams.Gain.GRdB; !en && grdb < 20 {
+		return fmt.Errorf("invalid gain reduction for normal mode: got %d dB, want >= 20 dB", grdb)
+	}
+	c.TunerParams.Gain.MinGr = api.NORMAL_MIN_GR
+	if en {
+		c.TunerParams.Gain.MinGr = api.EXTENDED_MIN_GR
+	}
+	return nil
+}
+
+// WithExtendedGainReduction creates a function that uses
+// SetExtendedGainReduction to select the minimum gain reduction mode.
+// It must come after WithGainReduction in the list of ChanConfigFn
+// functions, because WithGainReduction selects the mode automatically.
+// Disabling the extended mode fails for a gain reduction below 20 dB.
+func WithExtendedGainReduction(en bool) ChanConfigFn {
+	return func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+		return SetExtendedGainReduction(d, p, c, en)
+	}
+}
+
+// GetExtendedGainReduction returns true if the specified channel uses
+// the extended minimum gain reduction mode (api.EXTENDED_MIN_GR).
+func GetExtendedGainReduction(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) (bool, error) {
+	if c == nil {
+		return false, errors.New("cannot read nil channel")
+	}
+	switch mode := c.TunerParams.Gain.MinGr; mode {
+	case api.EXTENDED_MIN_GR:
+		return true, nil
+	case api.NORMAL_MIN_GR:
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid minimum gain reduction: got %v", mode)
+	}
+}
+
 // SetBandwidth sets the IF bandwidth. It does not do any checking for
 // validity
 func SetBandwidth(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, bw api.Bw_MHzT) error {
@@ -316,6 +359,54 @@ func WithRfDabNotchEnabled(en bool) ChanConfigFn {
 	}
 }
 
+// DCOffsetTrackUnit is the resolution of the DC offset tracking period
+// of the tuner. The API specifies the period as a count of 72*3 cycles
+// of the 24 MHz clock.
+const DCOffsetTrackUnit = 9 * time.Microsecond
+
+// MaxDCOffsetTrackTime is the longest DC offset tracking period that
+// can be configured.
+const MaxDCOffsetTrackTime = 63 * DCOffsetTrackUnit
+
+// SetDCOffsetTuning configures how aggressively the tuner tracks and
+// removes the DC offset that appears as a spike at the center of the
+// spectrum. The trackingPeriod is rounded to the nearest multiple of
+// DCOffsetTrackUnit and must be between DCOffsetTrackUnit (the API
+// default) and MaxDCOffsetTrackTime. A shorter period follows a
+// changing offset (e.g. after a gain change) more quickly, but the
+// faster correction loop can itself create transient artifacts near DC.
+// A longer period gives a steadier correction that is slower to settle.
+// If speedUp is true, the tuner uses a faster initial convergence after
+// a change instead of waiting for the normal tracking to settle.
+//
+// The DC offset tuner parameters are present in all versions of the
+// API supported by this package. They only have an effect when DC
+// offset correction is enabled in CtrlParams.DcOffset.
+func SetDCOffsetTuning(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, trackingPeriod time.Duration, speedUp bool) error {
+	if c == nil {
+		return errors.New("cannot configure nil channel")
+	}
+	units := (trackingPeriod + DCOffsetTrackUnit/2) / DCOffsetTrackUnit
+	if units < 1 || units > 63 {
+		return fmt.Errorf("invalid DC offset tracking period: got %v, want %v<=period<=%v", trackingPeriod, DCOffsetTrackUnit, MaxDCOffsetTrackTime)
+	}
+	var val uint8
+	if speedUp {
+		val = 1
+	}
+	c.TunerParams.DcOffsetTuner.TrackTime = int32(units)
+	c.TunerParams.DcOffsetTuner.SpeedUp = val
+	return nil
+}
+
+// WithDCOffsetTuning creates a function that uses SetDCOffsetTuning to
+// configure the DC offset tracking of the tuner.
+func WithDCOffsetTuning(trackingPeriod time.Duration, speedUp bool) ChanConfigFn {
+	return func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+		return SetDCOffsetTuning(d, p, c, trackingPeriod, speedUp)
+	}
+}
+
 // Logger is compatible with standard library and logrus.
 type Logger interface {
 	Printf(format string, v ...interface{})
@@ -324,6 +415,9 @@ type Logger interface {
 // WithLogChannelParams creates a function that prints channel configuration
 // values to the specified Logger.
 func WithLogChannelParams(prefix string, lg Logger) ChanConfigFn {
+	if prefix != "" {
+		prefix += " "
+	}
 	return func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
 		rate, err := GetEffectiveSampleRate(d, p, c)
 		if err != nil {
@@ -333,10 +427,7 @@ func WithLogChannelParams(prefix string, lg Logger) ChanConfigFn {
 		if err != nil {
 			return err
 		}
-		if prefix != "" {
-			prefix += " "
-		}
-		lg.Printf("%sIFMode=%v", prefix, c.TunerParams.IfType)
+		lg.Printf("%sIFMode=%s", prefix, c.TunerParams.IfType.Describe())
 		lg.Printf("%sRFFrequency=%vHz\n", prefix, c.TunerParams.RfFreq.RfHz)
 		lg.Printf("%sADCSampleRate=%vHz\n", prefix, p.DevParams.FsFreq.FsHz)
 		lg.Printf("%sEffectiveSampleRate=%vHz\n", prefix, rate)
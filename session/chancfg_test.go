@@ -0,0 +1,171 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestSetDCOffsetTuning(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		period    time.Duration
+		speedUp   bool
+		valid     bool
+		trackTime int32
+		wantSpeed uint8
+	}{
+		{9 * time.Microsecond, false, true, 1, 0},
+		{9 * time.Microsecond, true, true, 1, 1},
+		{13 * time.Microsecond, false, true, 1, 0},
+		{14 * time.Microsecond, false, true, 2, 0},
+		{100 * time.Microsecond, true, true, 11, 1},
+		{MaxDCOffsetTrackTime, false, true, 63, 0},
+		{4 * time.Microsecond, false, false, 0, 0},
+		{0, false, false, 0, 0},
+		{-time.Millisecond, false, false, 0, 0},
+		{MaxDCOffsetTrackTime + DCOffsetTrackUnit, false, false, 0, 0},
+	}
+
+	for _, spec := range specs {
+		d := &api.DeviceT{HWVer: api.RSP1A_ID}
+		p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+		c := p.RxChannelA
+		c.TunerParams.DcOffsetTuner = api.DcOffsetTunerT{DcCal: 3, RefreshRateTime: 2048}
+		err := WithDCOffsetTuning(spec.period, spec.speedUp)(d, p, c)
+		switch {
+		case spec.valid && err != nil:
+			t.Errorf("unexpected error for %v: %v", spec.period, err)
+			continue
+		case !spec.valid && err == nil:
+			t.Errorf("unexpected success for %v", spec.period)
+			continue
+		case !spec.valid:
+			continue
+		}
+		want := api.DcOffsetTunerT{DcCal: 3, SpeedUp: spec.wantSpeed, TrackTime: spec.trackTime, RefreshRateTime: 2048}
+		if got := c.TunerParams.DcOffsetTuner; got != want {
+			t.Errorf("wrong DC offset tuner params for %v: got %+v, want %+v", spec.period, got, want)
+		}
+	}
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	if err := SetDCOffsetTuning(d, &api.DeviceParamsT{}, nil, DCOffsetTrackUnit, false); err == nil {
+		t.Error("unexpected success for nil channel")
+	}
+}
+
+func TestChannelConfigMissing(t *testing.T) {
+	t.Parallel()
+
+	duo := func(tuner api.TunerSelectT) *api.DeviceT {
+		return &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: tuner, RspDuoMode: api.RspDuoMode_Dual_Tuner}
+	}
+	specs := []struct {
+		name string
+		d    *api.DeviceT
+		p    *api.DeviceParamsT
+		fn   func(fns ...ChanConfigFn) DevConfigFn
+	}{
+		{"single", &api.DeviceT{HWVer: api.RSP1A_ID}, &api.DeviceParamsT{}, WithSingleChannelConfig},
+		{"single duo", duo(api.Tuner_A), &api.DeviceParamsT{RxChannelB: &api.RxChannelParamsT{}}, WithSingleChannelConfig},
+		{"duo A", duo(api.Tuner_Both), &api.DeviceParamsT{RxChannelB: &api.RxChannelParamsT{}}, WithDuoChannelAConfig},
+		{"duo B", duo(api.Tuner_Both), &api.DeviceParamsT{RxChannelA: &api.RxChannelParamsT{}}, WithDuoChannelBConfig},
+		{"duo B only", duo(api.Tuner_B), &api.DeviceParamsT{}, WithDuoChannelBConfig},
+		{
+			"single duo B",
+			&api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_B, RspDuoMode: api.RspDuoMode_Single_Tuner},
+			&api.DeviceParamsT{RxChannelB: &api.RxChannelParamsT{}},
+			WithSingleChannelConfig,
+		},
+	}
+	for _, spec := range specs {
+		called := false
+		err := spec.fn(func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+			called = true
+			return nil
+		})(spec.d, spec.p)
+		if !errors.Is(err, ErrMissingChannel) {
+			t.Errorf("wrong error for %s: got %v, want %v", spec.name, err, ErrMissingChannel)
+		}
+		if called {
+			t.Errorf("unexpected channel config for %s", spec.name)
+		}
+	}
+}
+
+func TestWithExtendedGainReduction(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		grdb int32
+		en   bool
+		want api.MinGainReductionT
+		ok   bool
+	}{
+		{0, true, api.EXTENDED_MIN_GR, true},
+		{19, true, api.EXTENDED_MIN_GR, true},
+		{20, true, api.EXTENDED_MIN_GR, true},
+		{40, true, api.EXTENDED_MIN_GR, true},
+		{59, true, api.EXTENDED_MIN_GR, true},
+		{20, false, api.NORMAL_MIN_GR, true},
+		{40, false, api.NORMAL_MIN_GR, true},
+		// The normal mode cannot provide less than 20 dB.
+		{0, false, 0, false},
+		{19, false, 0, false},
+	}
+
+	for _, spec := range specs {
+		d := &api.DeviceT{HWVer: api.RSP1A_ID}
+		p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+		fn := WithSingleChannelConfig(
+			WithGainReduction(spec.grdb),
+			WithExtendedGainReduction(spec.en),
+		)
+		err := fn(d, p)
+		switch {
+		case !spec.ok && err == nil:
+			t.Errorf("unexpected success for %d dB with extended %v", spec.grdb, spec.en)
+			continue
+		case !spec.ok:
+			continue
+		case err != nil:
+			t.Fatal(err)
+		}
+		c := p.RxChannelA
+		if got := c.TunerParams.Gain.MinGr; got != spec.want {
+			t.Errorf("wrong MinGr for %d dB: got %v, want %v", spec.grdb, got, spec.want)
+		}
+		if got := c.TunerParams.Gain.GRdB; got != spec.grdb {
+			t.Errorf("wrong GRdB: got %d, want %d", got, spec.grdb)
+		}
+		got, err := GetExtendedGainReduction(d, p, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != spec.en {
+			t.Errorf("wrong extended gain reduction for %d dB: got %v, want %v", spec.grdb, got, spec.en)
+		}
+	}
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	p := &api.DeviceParamsT{}
+	if err := SetExtendedGainReduction(d, p, nil, true); err == nil {
+		t.Error("unexpected success for nil channel")
+	}
+	if _, err := GetExtendedGainReduction(d, p, nil); err == nil {
+		t.Error("unexpected success for nil channel")
+	}
+	c := &api.RxChannelParamsT{}
+	c.TunerParams.Gain.MinGr = 7
+	if _, err := GetExtendedGainReduction(d, p, c); err == nil {
+		t.Error("unexpected success for invalid MinGr")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// SwapActiveTuner swaps the active tuner of a running RSPduo in
+// single-tuner mode using SwapRspDuoActiveTuner. The API reports the new
+// active tuner through its currentTuner out-param, which this function
+// points at d.Tuner, so d stays the source of truth for CurrentTuner.
+// The tuner1AmPortSel argument selects the AM port of tuner 1 if tuner 1
+// becomes active. It returns the new active tuner. On failure, d is not
+// changed.
+//
+// Like the other runtime helpers, it should be called from the control
+// loop. The controllers of a Session (e.g. AFC) read d.Tuner only when
+// they start. It returns an error if d is not an RSPduo in single-tuner
+// mode.
+func SwapActiveTuner(d *api.DeviceT, a api.API, tuner1AmPortSel api.RspDuo_AmPortSelectT) (api.TunerSelectT, error) {
+	if d.HWVer != api.RSPduo_ID || d.RspDuoMode != api.RspDuoMode_Single_Tuner {
+		return d.Tuner, fmt.Errorf("invalid device: got %v in %v mode, want RSPduo in single-tuner mode", d.HWVer, d.RspDuoMode)
+	}
+	tuner := d.Tuner
+	if err := a.SwapRspDuoActiveTuner(d.Dev, &tuner, tuner1AmPortSel); err != nil {
+		return d.Tuner, fmt.Errorf("failed to swap active tuner: %v", a.GetLastError(d))
+	}
+	d.Tuner = tuner
+	return tuner, nil
+}
+
+// CurrentTuner returns the active tuner of the device d. It is intended
+// for a ControlFn, which may swap the active tuner of an RSPduo. The
+// DeviceT is the source of truth: a swap must update d.Tuner, either
+// with SwapActiveTuner or by passing &d.Tuner as the currentTuner
+// out-param of SwapRspDuoActiveTuner. A swap that reports into a copy
+// leaves d stale. Devices other than the RSPduo only have a single
+// tuner, which the API refers to as tuner A. It returns an error if no
+// valid tuner is selected.
+//
+// Unlike a query of the live state, it takes no api.API. The API has no
+// call that reports the active tuner or mode of a running device, and
+// the device params do not record them, so the out-params of the swap
+// functions are the only live source and d is where they are kept.
+func CurrentTuner(d *api.DeviceT) (api.TunerSelectT, error) {
+	if d.HWVer != api.RSPduo_ID {
+		return api.Tuner_A, nil
+	}
+	switch d.Tuner {
+	case api.Tuner_A, api.Tuner_B, api.Tuner_Both:
+		return d.Tuner, nil
+	default:
+		return d.Tuner, fmt.Errorf("invalid tuner selection: got %v", d.Tuner)
+	}
+}
+
+// CurrentMode returns the operating mode and the ADC sample rate of the
+// RSPduo d. Like CurrentTuner, it reads d, so the sample rate reflects a
+// swap only if the swap updated d.RspDuoSampleFreq, as
+// SwapDualTunerSampleRate does. It returns an error if d is not an
+// RSPduo.
+func CurrentMode(d *api.DeviceT) (api.RspDuoModeT, float64, error) {
+	if d.HWVer != api.RSPduo_ID {
+		return api.RspDuoMode_Unknown, 0, fmt.Errorf("invalid device: got %v, want RSPduo", d.HWVer)
+	}
+	return d.RspDuoMode, d.RspDuoSampleFreq, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestCurrentTuner(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{
+		HWVer:            api.RSPduo_ID,
+		Tuner:            api.Tuner_A,
+		RspDuoMode:       api.RspDuoMode_Single_Tuner,
+		RspDuoSampleFreq: 6e6,
+	}
+	m := apitest.NewMock(d)
+	sess, err := NewSession(
+		WithImplementation(m),
+		WithSelector(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Control = func(ctx context.Context, d *api.DeviceT, a api.API) error {
+		if got, err := CurrentTuner(d); err != nil || got != api.Tuner_A {
+			t.Errorf("wrong tuner before swap: got %v, %v, want %v", got, err, api.Tuner_A)
+		}
+		if got, err := SwapActiveTuner(d, a, api.RspDuo_AMPORT_1); err != nil || got != api.Tuner_B {
+			t.Errorf("wrong swapped tuner: got %v, %v, want %v", got, err, api.Tuner_B)
+		}
+		if got, err := CurrentTuner(d); err != nil || got != api.Tuner_B {
+			t.Errorf("wrong tuner after swap: got %v, %v, want %v", got, err, api.Tuner_B)
+		}
+		// A failed swap changes nothing.
+		m.Errors = map[string]error{"SwapRspDuoActiveTuner": api.Fail}
+		if _, err := SwapActiveTuner(d, a, api.RspDuo_AMPORT_1); err == nil {
+			t.Error("unexpected success for failed swap")
+		}
+		if got, _ := CurrentTuner(d); got != api.Tuner_B {
+			t.Errorf("wrong tuner after failed swap: got %v, want %v", got, api.Tuner_B)
+		}
+		m.Errors = nil
+		// A swap through the API that reports into d also counts.
+		if err := a.SwapRspDuoActiveTuner(d.Dev, &d.Tuner, api.RspDuo_AMPORT_1); err != nil {
+			return err
+		}
+		if got, _ := CurrentTuner(d); got != api.Tuner_A {
+			t.Errorf("wrong tuner after second swap: got %v, want %v", got, api.Tuner_A)
+		}
+
+		if err := a.SwapRspDuoDualTunerModeSampleRate(d.Dev, &d.RspDuoSampleFreq); err != nil {
+			return err
+		}
+		mode, fs, err := CurrentMode(d)
+		if err != nil {
+			return err
+		}
+		if mode != api.RspDuoMode_Single_Tuner || fs != 8e6 {
+			t.Errorf("wrong mode: got %v at %v Hz, want %v at %v Hz", mode, fs, api.RspDuoMode_Single_Tuner, 8e6)
+		}
+		return nil
+	}
+	if err := sess.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Swapping the active tuner needs single-tuner mode.
+	dual := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner}
+	m = apitest.NewMock(dual)
+	if _, err := SwapActiveTuner(dual, m, api.RspDuo_AMPORT_1); err == nil {
+		t.Error("unexpected success in dual-tuner mode")
+	}
+	if len(m.Calls) != 0 || dual.Tuner != api.Tuner_Both {
+		t.Errorf("unexpected swap in dual-tuner mode: calls %v, tuner %v", m.Calls, dual.Tuner)
+	}
+
+	// Other devices only have tuner A and no mode.
+	d = &api.DeviceT{HWVer: api.RSP1A_ID}
+	if got, err := CurrentTuner(d); err != nil || got != api.Tuner_A {
+		t.Errorf("wrong tuner for %v: got %v, %v, want %v", d.HWVer, got, err, api.Tuner_A)
+	}
+	if _, _, err := CurrentMode(d); err == nil {
+		t.Errorf("unexpected success for %v", d.HWVer)
+	}
+	if _, err := SwapActiveTuner(d, m, api.RspDuo_AMPORT_1); err == nil {
+		t.Errorf("unexpected swap for %v", d.HWVer)
+	}
+	d = &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Neither}
+	if _, err := CurrentTuner(d); err == nil {
+		t.Error("unexpected success for no tuner")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// DescribeConfig returns a human-readable, multi-line summary of the
+// configuration in the provided device params. It covers the device and
+// mode, sample rates, and, for each active channel, the tuner, gain,
+// filter, notch, bias-T, and antenna settings relevant to the device type.
+// The result is intended for logging or for inclusion in a bug report.
+//
+// Unlike WithLogChannelParams, which logs a single channel line by line,
+// DescribeConfig describes every channel in use by the device. For an
+// RSPduo, the channels described are determined by DeviceT.Tuner.
+func DescribeConfig(d *api.DeviceT, p *api.DeviceParamsT) string {
+	var sb strings.Builder
+
+	switch d.HWVer {
+	case api.RSPduo_ID:
+		fmt.Fprintf(&sb, "Device: HWVer=%v SerNo=%v Tuner=%v Mode=%v ModeSampleFreq=%vHz\n", d.HWVer, d.SerNo, d.Tuner, d.RspDuoMode, d.RspDuoSampleFreq)
+	default:
+		fmt.Fprintf(&sb, "Device: HWVer=%v SerNo=%v\n", d.HWVer, d.SerNo)
+	}
+
+	if p == nil {
+		sb.WriteString("Params: none\n")
+		return sb.String()
+	}
+
+	if dp := p.DevParams; dp != nil {
+		fmt.Fprintf(&sb, "DevParams: ADCSampleRate=%vHz Ppm=%v TransferMode=%v", dp.FsFreq.FsHz, dp.Ppm, dp.Mode)
+		switch d.HWVer {
+		case api.RSP1A_ID:
+			fmt.Fprintf(&sb, " RfNotch=%v DabNotch=%v", dp.Rsp1aParams.RfNotchEnable != 0, dp.Rsp1aParams.RfDabNotchEnable != 0)
+		case api.RSP2_ID:
+			fmt.Fprintf(&sb, " ExtRefOutput=%v", dp.Rsp2Params.ExtRefOutputEn != 0)
+		case api.RSPduo_ID:
+			fmt.Fprintf(&sb, " ExtRefOutput=%v", dp.RspDuoParams.ExtRefOutputEn != 0)
+		case api.RSPdx_ID:
+			dx := dp.RspDxParams
+			fmt.Fprintf(
+				&sb, " Antenna=%v BiasT=%v RfNotch=%v DabNotch=%v HDR=%v",
+				dx.AntennaSel, dx.BiasTEnable != 0, dx.RfNotchEnable != 0, dx.RfDabNotchEnable != 0, dx.HdrEnable != 0,
+			)
+		}
+		sb.WriteString("\n")
+	}
+
+	type namedChan struct {
+		name string
+		c    *api.RxChannelParamsT
+	}
+	var chans []namedChan
+	switch {
+	case d.HWVer == api.RSPduo_ID && d.Tuner == api.Tuner_B:
+		// Like WithSingleChannelConfig, a single selected tuner B is
+		// configured through RxChannelA.
+		chans = append(chans, namedChan{"B", p.RxChannelA})
+	case d.HWVer == api.RSPduo_ID && d.Tuner == api.Tuner_Both:
+		chans = append(chans, namedChan{"A", p.RxChannelA}, namedChan{"B", p.RxChannelB})
+	default:
+		chans = append(chans, namedChan{"A", p.RxChannelA})
+	}
+
+	for _, ch := range chans {
+		c := ch.c
+		if c == nil {
+			fmt.Fprintf(&sb, "Channel %s: none\n", ch.name)
+			continue
+		}
+		tp := c.TunerParams
+		fmt.Fprintf(
+			&sb, "Channel %s: RFFrequency=%vHz IFMode=%v IFFilterBandwidth=%vHz LoMode=%v",
+			ch.name, tp.RfFreq.RfHz, tp.IfType, tp.BwType.Hz(), tp.LoMode,
+		)
+		if p.DevParams != nil {
+			if rate, err := GetEffectiveSampleRate(d, p, c); err == nil {
+				fmt.Fprintf(&sb, " EffectiveSampleRate=%vHz", rate)
+			}
+		}
+		fmt.Fprintf(&sb, " Decimation=%d", c.CtrlParams.Decimation.DecimationFactor)
+		if c.CtrlParams.Decimation.Enable == 0 {
+			sb.WriteString("(disabled)")
+		}
+		fmt.Fprintf(&sb, " GRdB=%d MinGR=%v LNAState=%d", tp.Gain.GRdB, tp.Gain.MinGr, tp.Gain.LNAstate)
+		if p.DevParams != nil {
+			if pct, err := GetLNAPercent(d, p, c); err == nil {
+				fmt.Fprintf(&sb, " LNAPercent=%d%%", int(pct*100))
+			}
+		}
+		fmt.Fprintf(&sb, " AGC=%v AGCSetPoint=%vdBFS", c.CtrlParams.Agc.Enable, c.CtrlParams.Agc.SetPoint_dBfs)
+		switch d.HWVer {
+		case api.RSP1A_ID:
+			fmt.Fprintf(&sb, " BiasT=%v", c.Rsp1aTunerParams.BiasTEnable != 0)
+		case api.RSP2_ID:
+			r := c.Rsp2TunerParams
+			fmt.Fprintf(
+				&sb, " Antenna=%v AmPort=%v BiasT=%v RfNotch=%v",
+				r.AntennaSel, r.AmPortSel, r.BiasTEnable != 0, r.RfNotchEnable != 0,
+			)
+		case api.RSPduo_ID:
+			r := c.RspDuoTunerParams
+			fmt.Fprintf(&sb, " BiasT=%v RfNotch=%v DabNotch=%v", r.BiasTEnable != 0, r.RfNotchEnable != 0, r.RfDabNotchEnable != 0)
+			// The AM port and AM notch only exist on tuner A.
+			if ch.name == "A" {
+				fmt.Fprintf(&sb, " AmPort=%v AmNotch=%v", r.Tuner1AmPortSel, r.Tuner1AmNotchEnable != 0)
+			}
+		case api.RSPdx_ID:
+			fmt.Fprintf(&sb, " HDRBandwidth=%v", c.RspDxTunerParams.HdrBw)
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestDescribeConfig(t *testing.T) {
+	t.Parallel()
+
+	dev := &api.DeviceT{
+		SerNo:            api.ParseSerialNumber("1234567890"),
+		HWVer:            api.RSPduo_ID,
+		Tuner:            api.Tuner_Both,
+		RspDuoMode:       api.RspDuoMode_Dual_Tuner,
+		RspDuoSampleFreq: 6e6,
+	}
+	params := &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelA: &api.RxChannelParamsT{},
+		RxChannelB: &api.RxChannelParamsT{},
+	}
+	params.DevParams.FsFreq.FsHz = 6e6
+	params.DevParams.Mode = api.BULK
+	for _, c := range []*api.RxChannelParamsT{params.RxChannelA, params.RxChannelB} {
+		c.TunerParams.IfType = api.IF_1_620
+		c.TunerParams.BwType = api.BW_1_536
+		c.TunerParams.RfFreq.RfHz = 100e6
+		c.TunerParams.Gain.GRdB = 40
+		c.TunerParams.Gain.LNAstate = 3
+		c.CtrlParams.Decimation.DecimationFactor = 2
+		c.CtrlParams.Decimation.Enable = 1
+		c.CtrlParams.Agc.Enable = api.AGC_DISABLE
+		c.RspDuoTunerParams.RfNotchEnable = 1
+	}
+	params.RxChannelB.TunerParams.RfFreq.RfHz = 200e6
+	params.RxChannelA.RspDuoTunerParams.BiasTEnable = 1
+	params.RxChannelA.RspDuoTunerParams.Tuner1AmPortSel = api.RspDuo_AMPORT_1
+
+	desc := DescribeConfig(dev, params)
+	lines := strings.Split(strings.TrimSpace(desc), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("wrong number of lines: got %d, want 4\n%s", len(lines), desc)
+	}
+
+	specs := []struct {
+		line int
+		want []string
+	}{
+		{0, []string{"HWVer=RSPduo_ID", "SerNo=1234567890", "Tuner=Tuner_Both", "Mode=Dual", "ModeSampleFreq=6e+06Hz"}},
+		{1, []string{"ADCSampleRate=6e+06Hz", "TransferMode=BULK"}},
+		{2, []string{
+			"Channel A:", "RFFrequency=1e+08Hz", "IFMode=IF_1_620", "IFFilterBandwidth=1.536e+06Hz",
+			"EffectiveSampleRate=1e+06Hz", "Decimation=2 ", "GRdB=40", "LNAState=3", "AGC=AGC_DISABLE",
+			"BiasT=true", "RfNotch=true", "DabNotch=false", "AmPort=RspDuo_AMPORT_1",
+		}},
+		{3, []string{"Channel B:", "RFFrequency=2e+08Hz", "BiasT=false", "RfNotch=true"}},
+	}
+	for _, spec := range specs {
+		for _, want := range spec.want {
+			if !strings.Contains(lines[spec.line], want) {
+				t.Errorf("line %d missing %q: got %q", spec.line, want, lines[spec.line])
+			}
+		}
+	}
+	if strings.Contains(lines[3], "AmPort") {
+		t.Errorf("unexpected AmPort for tuner B: got %q", lines[3])
+	}
+
+	dev.Tuner = api.Tuner_B
+	desc = DescribeConfig(dev, params)
+	if strings.Contains(desc, "Channel A") || !strings.Contains(desc, "Channel B") {
+		t.Errorf("wrong channels for tuner B:\n%s", desc)
+	}
+	// A single selected tuner B is described from RxChannelA.
+	if want := fmt.Sprintf("RFFrequency=%vHz", params.RxChannelA.TunerParams.RfFreq.RfHz); !strings.Contains(desc, want) {
+		t.Errorf("wrong channel params for tuner B: got\n%s\nwant %q", desc, want)
+	}
+}
@@ -37,26 +37,22 @@ func WithTransferMode(mode api.TransferModeT) DevConfigFn {
 }
 
 // WithRefClockOutput creates a function that enables or disables the
-// reference clock output on an RSP2 or RSPduo device. It will have no
-// effect on other device types.
+// reference clock output on devices that have one (see
+// api.Capabilities). It will have no effect on other device types.
 func WithRefClockOutput(enabled bool) DevConfigFn {
 	return func(d *api.DeviceT, p *api.DeviceParamsT) error {
+		if !api.DeviceCapabilities(d).HasRefClockOutput {
+			return nil
+		}
 		var val uint8
 		if enabled {
 			val = 1
 		}
-		switch d.HWVer {
-		case api.RSP1_ID:
-			// not available
-		case api.RSP1A_ID:
-			// not available
-		case api.RSP2_ID:
-			p.DevParams.Rsp2Params.ExtRefOutputEn = val
-		case api.RSPduo_ID:
+		if d.HWVer == api.RSPduo_ID {
 			p.DevParams.RspDuoParams.ExtRefOutputEn = int32(val)
-		case api.RSPdx_ID:
-			// not available
+			return nil
 		}
+		p.DevParams.Rsp2Params.ExtRefOutputEn = val
 		return nil
 	}
 }
@@ -64,18 +60,75 @@ func WithRefClockOutput(enabled bool) DevConfigFn {
 // GetHighZPortEnabled returns true if the High-Z port is enabled or false
 // if it is not enabled or if the device does not have a High-Z port.
 func GetHighZPortEnabled(d *api.DeviceT, p *api.DeviceParamsT) bool {
-	switch d.HWVer {
-	case api.RSP2_ID:
-		return p.RxChannelA.Rsp2TunerParams.AmPortSel == api.Rsp2_AMPORT_1
-	case api.RSPduo_ID:
-		switch d.Tuner {
-		case api.Tuner_A, api.Tuner_Both:
-			return p.RxChannelA.RspDuoTunerParams.Tuner1AmPortSel == api.RspDuo_AMPORT_1
-		default:
-			return false
+	if !api.DeviceCapabilities(d).HasHighZ {
+		return false
+	}
+	if d.HWVer == api.RSPduo_ID {
+		port, err := GetRspDuoAmPort(d, p)
+		return err == nil && port == api.RspDuo_AMPORT_1
+	}
+	return p.RxChannelA.Rsp2TunerParams.AmPortSel == api.Rsp2_AMPORT_1
+}
+
+// GetRspDuoAmPort returns the AM port used by tuner A of an RSPduo.
+// RspDuo_AMPORT_1 is the High-Z port and RspDuo_AMPORT_2 is the 50 ohm
+// port. Tuner B only has a 50 ohm port, so RspDuo_AMPORT_2 is returned
+// if only tuner B is selected. It returns an error if the device is not
+// an RSPduo or no tuner is selected.
+func GetRspDuoAmPort(d *api.DeviceT, p *api.DeviceParamsT) (api.RspDuo_AmPortSelectT, error) {
+	if d.HWVer != api.RSPduo_ID {
+		return 0, fmt.Errorf("invalid device for AM port selection: got %v, want %v", d.HWVer, api.RSPduo_ID)
+	}
+	switch d.Tuner {
+	case api.Tuner_A, api.Tuner_Both:
+		if p.RxChannelA == nil {
+			return 0, wrapError(fmt.Sprintf("%v: RxChannelA", ErrMissingChannel), ErrMissingChannel)
 		}
+		return p.RxChannelA.RspDuoTunerParams.Tuner1AmPortSel, nil
+	case api.Tuner_B:
+		return api.RspDuo_AMPORT_2, nil
 	default:
-		return false
+		return 0, errors.New("no tuner selected")
+	}
+}
+
+// SetRspDuoAmPort configures the AM port used by tuner A of an RSPduo
+// when tuner A or both tuners are selected. If only tuner B is
+// selected, RspDuo_AMPORT_2 is accepted without any change, because
+// tuner B only has a 50 ohm port, and RspDuo_AMPORT_1 returns an error.
+// It also returns an error if the device is not an RSPduo, no tuner is
+// selected, or port is not a defined value.
+func SetRspDuoAmPort(d *api.DeviceT, p *api.DeviceParamsT, port api.RspDuo_AmPortSelectT) error {
+	if d.HWVer != api.RSPduo_ID {
+		return fmt.Errorf("invalid device for AM port selection: got %v, want %v", d.HWVer, api.RSPduo_ID)
+	}
+	switch port {
+	case api.RspDuo_AMPORT_1, api.RspDuo_AMPORT_2:
+	default:
+		return fmt.Errorf("invalid RSPduo AM port: got %v, want %v or %v", port, api.RspDuo_AMPORT_1, api.RspDuo_AMPORT_2)
+	}
+	switch d.Tuner {
+	case api.Tuner_A, api.Tuner_Both:
+		if p.RxChannelA == nil {
+			return wrapError(fmt.Sprintf("%v: RxChannelA", ErrMissingChannel), ErrMissingChannel)
+		}
+		p.RxChannelA.RspDuoTunerParams.Tuner1AmPortSel = port
+	case api.Tuner_B:
+		if port == api.RspDuo_AMPORT_1 {
+			return errors.New("cannot select High-Z port for tuner B")
+		}
+	default:
+		return errors.New("no tuner selected")
+	}
+	return nil
+}
+
+// WithRspDuoAmPort creates a function that selects the AM port used by
+// tuner A of an RSPduo. See SetRspDuoAmPort. Unlike WithHighZPortEnabled,
+// it returns an error if the device is not an RSPduo.
+func WithRspDuoAmPort(port api.RspDuo_AmPortSelectT) DevConfigFn {
+	return func(d *api.DeviceT, p *api.DeviceParamsT) error {
+		return SetRspDuoAmPort(d, p, port)
 	}
 }
 
@@ -83,36 +136,24 @@ func GetHighZPortEnabled(d *api.DeviceT, p *api.DeviceParamsT) bool {
 // disabled as specified by the en argument. If the device does not have
 // a High-Z port, this function does nothing. If the device is an RSPduo
 // and tuner A or both tuners are selected, it will configure tuner A to
-// use the High-Z port. If the device is an RSPduo, but only tuner B is
-// selected, it returns an error.
+// use the High-Z port with SetRspDuoAmPort. If the device is an RSPduo,
+// but only tuner B is selected, it returns an error.
 func SetHighZPortEnabled(d *api.DeviceT, p *api.DeviceParamsT, en bool) error {
-	switch d.HWVer {
-	case api.RSP1_ID:
-		// not available
-	case api.RSP1A_ID:
-		// not available
-	case api.RSP2_ID:
-		port := api.Rsp2_AMPORT_2
-		if en {
-			port = api.Rsp2_AMPORT_1
-		}
-		p.RxChannelA.Rsp2TunerParams.AmPortSel = port
-	case api.RSPduo_ID:
+	if !api.DeviceCapabilities(d).HasHighZ {
+		return nil
+	}
+	if d.HWVer == api.RSPduo_ID {
 		port := api.RspDuo_AMPORT_2
 		if en {
 			port = api.RspDuo_AMPORT_1
 		}
-		switch d.Tuner {
-		case api.Tuner_A, api.Tuner_Both:
-			p.RxChannelA.RspDuoTunerParams.Tuner1AmPortSel = port
-		case api.Tuner_B:
-			if port == api.RspDuo_AMPORT_1 {
-				return errors.New("cannot select High-Z port for tuner B")
-			}
-		}
-	case api.RSPdx_ID:
-		// not available
+		return SetRspDuoAmPort(d, p, port)
+	}
+	port := api.Rsp2_AMPORT_2
+	if en {
+		port = api.Rsp2_AMPORT_1
 	}
+	p.RxChannelA.Rsp2TunerParams.AmPortSel = port
 	return nil
 }
 
@@ -143,6 +184,72 @@ func WithDxAntennaSelect(ant api.RspDx_AntennaSelectT) DevConfigFn {
 	}
 }
 
+// rspDxMaxHDRFreq is the highest RF frequency at which the RSPdx HDR
+// mode is available.
+const rspDxMaxHDRFreq = 2e6
+
+// SetRSPdxHFConfig validates and applies a coherent combination of the
+// RSPdx HF-related settings: antenna C, HDR mode, and bias-T. The rules
+// are as follows.
+//
+// HDR mode requires antenna C and a tune frequency below 2 MHz. The
+// frequency is checked against the channel A params only if it has
+// already been set (i.e. it is not 0), so this should be applied after
+// the channel configuration to get the full validation.
+//
+// The bias-T is only available on antenna B, so enabling it with
+// antenna C is an error and enabling it without antenna C selects
+// antenna B. If neither antenna C nor the bias-T is requested, an
+// antenna A or B selection is left unchanged and antenna C is replaced
+// with antenna A.
+//
+// The combination is validated on any device, but the params are only
+// modified on the RSPdx.
+func SetRSPdxHFConfig(d *api.DeviceT, p *api.DeviceParamsT, antennaC, hdr, biasT bool) error {
+	switch {
+	case hdr && !antennaC:
+		return errors.New("invalid RSPdx config: HDR mode requires antenna C")
+	case biasT && antennaC:
+		return errors.New("invalid RSPdx config: bias-T is only available on antenna B")
+	}
+	if d.HWVer != api.RSPdx_ID {
+		return nil
+	}
+	if hdr {
+		if freq := p.RxChannelA.TunerParams.RfFreq.RfHz; freq >= rspDxMaxHDRFreq {
+			return fmt.Errorf("invalid RSPdx config: HDR mode requires tune frequency < 2 MHz: got %v Hz", freq)
+		}
+	}
+
+	dx := &p.DevParams.RspDxParams
+	switch {
+	case antennaC:
+		dx.AntennaSel = api.RspDx_ANTENNA_C
+	case biasT:
+		dx.AntennaSel = api.RspDx_ANTENNA_B
+	case dx.AntennaSel == api.RspDx_ANTENNA_C:
+		dx.AntennaSel = api.RspDx_ANTENNA_A
+	}
+	dx.HdrEnable = 0
+	if hdr {
+		dx.HdrEnable = 1
+	}
+	dx.BiasTEnable = 0
+	if biasT {
+		dx.BiasTEnable = 1
+	}
+	return nil
+}
+
+// WithRSPdxHFConfig creates a function that configures the RSPdx
+// antenna C, HDR mode, and bias-T settings together. See
+// SetRSPdxHFConfig. The function has no effect on other devices.
+func WithRSPdxHFConfig(antennaC, hdr, biasT bool) DevConfigFn {
+	return func(d *api.DeviceT, p *api.DeviceParamsT) error {
+		return SetRSPdxHFConfig(d, p, antennaC, hdr, biasT)
+	}
+}
+
 // WithRsp2AntennaSelect creates a function that configures the antenna
 // used on RSP2 devices. The function has no effect on other devices.
 func WithRsp2AntennaSelect(ant api.Rsp2_AntennaSelectT) DevConfigFn {
@@ -0,0 +1,158 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestWithRSPdxHFConfig(t *testing.T) {
+	t.Parallel()
+
+	const (
+		antA = api.RspDx_ANTENNA_A
+		antB = api.RspDx_ANTENNA_B
+		antC = api.RspDx_ANTENNA_C
+	)
+	specs := []struct {
+		antennaC, hdr, biasT bool
+		freq                 float64
+		startAnt             api.RspDx_AntennaSelectT
+		valid                bool
+		wantAnt              api.RspDx_AntennaSelectT
+	}{
+		{false, false, false, 100e6, antB, true, antB},
+		{false, false, false, 100e6, antC, true, antA},
+		{true, false, false, 100e6, antA, true, antC},
+		{true, true, false, 1e6, antA, true, antC},
+		{true, true, false, 0, antA, true, antC},
+		{false, false, true, 100e6, antA, true, antB},
+		{true, true, false, 2e6, antA, false, 0},
+		{true, true, false, 100e6, antA, false, 0},
+		{false, true, false, 1e6, antA, false, 0},
+		{true, false, true, 1e6, antA, false, 0},
+		{true, true, true, 1e6, antA, false, 0},
+	}
+
+	for i, spec := range specs {
+		d := &api.DeviceT{HWVer: api.RSPdx_ID}
+		p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+		p.RxChannelA.TunerParams.RfFreq.RfHz = spec.freq
+		p.DevParams.RspDxParams.AntennaSel = spec.startAnt
+		p.DevParams.RspDxParams.HdrEnable = 1
+		p.DevParams.RspDxParams.BiasTEnable = 1
+
+		err := WithRSPdxHFConfig(spec.antennaC, spec.hdr, spec.biasT)(d, p)
+		switch {
+		case !spec.valid && err == nil:
+			t.Errorf("%d: unexpected success", i)
+			continue
+		case !spec.valid:
+			continue
+		case err != nil:
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+
+		dx := p.DevParams.RspDxParams
+		if dx.AntennaSel != spec.wantAnt {
+			t.Errorf("%d: wrong antenna: got %v, want %v", i, dx.AntennaSel, spec.wantAnt)
+		}
+		if got := dx.HdrEnable != 0; got != spec.hdr {
+			t.Errorf("%d: wrong HDR: got %v, want %v", i, got, spec.hdr)
+		}
+		if got := dx.BiasTEnable != 0; got != spec.biasT {
+			t.Errorf("%d: wrong bias-T: got %v, want %v", i, got, spec.biasT)
+		}
+	}
+}
+
+func TestWithRSPdxHFConfigOtherDevice(t *testing.T) {
+	t.Parallel()
+
+	for _, hwVer := range []api.HWVersion{api.RSP1_ID, api.RSP1A_ID, api.RSP2_ID, api.RSPduo_ID} {
+		d := &api.DeviceT{HWVer: hwVer}
+		p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+		p.RxChannelA.TunerParams.RfFreq.RfHz = 100e6
+		want := *p.DevParams
+		if err := WithRSPdxHFConfig(true, true, false)(d, p); err != nil {
+			t.Errorf("unexpected error for %v: %v", hwVer, err)
+		}
+		if *p.DevParams != want {
+			t.Errorf("params modified for %v: got %+v, want %+v", hwVer, *p.DevParams, want)
+		}
+		if err := WithRSPdxHFConfig(false, true, false)(d, p); err == nil {
+			t.Errorf("unexpected success for invalid combination on %v", hwVer)
+		}
+	}
+}
+
+func TestWithRspDuoAmPort(t *testing.T) {
+	t.Parallel()
+
+	newParams := func() *api.DeviceParamsT {
+		return &api.DeviceParamsT{
+			DevParams:  &api.DevParamsT{},
+			RxChannelA: &api.RxChannelParamsT{},
+			RxChannelB: &api.RxChannelParamsT{},
+		}
+	}
+
+	for _, tuner := range []api.TunerSelectT{api.Tuner_A, api.Tuner_B, api.Tuner_Both} {
+		d := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: tuner}
+		for _, en := range []bool{true, false} {
+			port := api.RspDuo_AMPORT_2
+			if en {
+				port = api.RspDuo_AMPORT_1
+			}
+
+			pPort, pHighZ := newParams(), newParams()
+			// Start from the opposite port to detect missing updates.
+			pPort.RxChannelA.RspDuoTunerParams.Tuner1AmPortSel = 1 - port
+			pHighZ.RxChannelA.RspDuoTunerParams.Tuner1AmPortSel = 1 - port
+			errPort := WithRspDuoAmPort(port)(d, pPort)
+			errHighZ := WithHighZPortEnabled(en)(d, pHighZ)
+			if (errPort == nil) != (errHighZ == nil) {
+				t.Errorf("inconsistent errors for %v %v: got %v and %v", tuner, port, errPort, errHighZ)
+			}
+			wantErr := tuner == api.Tuner_B && en
+			if (errPort != nil) != wantErr {
+				t.Errorf("wrong error for %v %v: got %v, want error %v", tuner, port, errPort, wantErr)
+			}
+			if errPort != nil {
+				continue
+			}
+			got := pPort.RxChannelA.RspDuoTunerParams.Tuner1AmPortSel
+			if want := pHighZ.RxChannelA.RspDuoTunerParams.Tuner1AmPortSel; got != want {
+				t.Errorf("inconsistent port for %v %v: got %v, want %v", tuner, port, got, want)
+			}
+
+			gotPort, err := GetRspDuoAmPort(d, pPort)
+			if err != nil {
+				t.Errorf("unexpected error for %v %v: %v", tuner, port, err)
+			}
+			if gotPort != port {
+				t.Errorf("wrong port for %v: got %v, want %v", tuner, gotPort, port)
+			}
+			if got := GetHighZPortEnabled(d, pPort); got != en {
+				t.Errorf("wrong High-Z enabled for %v %v: got %v, want %v", tuner, port, got, en)
+			}
+		}
+	}
+
+	d := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_A}
+	if err := WithRspDuoAmPort(2)(d, newParams()); err == nil {
+		t.Error("missing error for invalid port")
+	}
+	d = &api.DeviceT{HWVer: api.RSP2_ID}
+	if err := WithRspDuoAmPort(api.RspDuo_AMPORT_1)(d, newParams()); err == nil {
+		t.Error("missing error for RSP2")
+	}
+	if _, err := GetRspDuoAmPort(d, newParams()); err == nil {
+		t.Error("missing error for RSP2 readback")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import "errors"
+
+// Sentinel errors returned, possibly wrapped with more context, by
+// functions in this package. Use errors.Is to test for them.
+var (
+	// ErrNoDevices is returned by Run when the API lists no devices.
+	ErrNoDevices = errors.New("no RSP devices found")
+	// ErrNoMatchingDevice is returned by Run when devices are listed,
+	// but the selector does not select any of them.
+	ErrNoMatchingDevice = errors.New("no matching devices selected")
+	// ErrDualModeUnavailable is returned by Run, in addition to
+	// ErrNoMatchingDevice, when no device is selected because the
+	// selector requires dual-tuner mode (e.g. with WithDuoModeDual) and
+	// none of the listed RSPduo devices has it available (e.g. because
+	// one of its tuners is in use by another process).
+	ErrDualModeUnavailable = errors.New("RSPduo dual-tuner mode unavailable")
+	// ErrSingleConfigInDual is returned by WithSingleChannelConfig when
+	// the selected RSPduo is in dual-tuner mode.
+	ErrSingleConfigInDual = errors.New("attempting single channel config in dual-tuner mode")
+	// ErrMissingChannel is returned when the device params loaded from
+	// the API do not include the channel params of a channel that is
+	// configured or that is used by the selected tuner.
+	ErrMissingChannel = errors.New("missing channel params")
+)
+
+// wrappedError is an error with a message that adds context to one or
+// more sentinel errors. It implements the Unwrap and Is methods used by
+// errors.Is.
+type wrappedError struct {
+	msg  string
+	errs []error
+}
+
+// wrapError creates an error with the provided message that matches
+// each of the provided errors with errors.Is.
+func wrapError(msg string, errs ...error) error {
+	return &wrappedError{msg: msg, errs: errs}
+}
+
+func (e *wrappedError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the first wrapped error.
+func (e *wrappedError) Unwrap() error {
+	return e.errs[0]
+}
+
+// Is returns true if target is any of the wrapped errors.
+func (e *wrappedError) Is(target error) bool {
+	for _, err := range e.errs {
+		if err == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	t.Parallel()
+
+	var (
+		rsp1a = func() *api.DeviceT {
+			return &api.DeviceT{SerNo: api.ParseSerialNumber("1000000001"), HWVer: api.RSP1A_ID}
+		}
+		// RSPduo with tuner A in use as primary by another process.
+		halfDuo = func() *api.DeviceT {
+			return &api.DeviceT{SerNo: api.ParseSerialNumber("1500000001"), HWVer: api.RSPduo_ID, Tuner: api.Tuner_B, RspDuoMode: api.RspDuoMode_Secondary}
+		}
+		idleDuo = func() *api.DeviceT {
+			return &api.DeviceT{
+				SerNo:      api.ParseSerialNumber("1500000002"),
+				HWVer:      api.RSPduo_ID,
+				Tuner:      api.Tuner_Both,
+				RspDuoMode: api.RspDuoMode_Single_Tuner | api.RspDuoMode_Dual_Tuner | api.RspDuoMode_Primary,
+			}
+		}
+		sentinels = []error{ErrNoDevices, ErrNoMatchingDevice, ErrDualModeUnavailable, ErrSingleConfigInDual}
+	)
+
+	specs := []struct {
+		name string
+		devs []*api.DeviceT
+		fns  []ConfigFn
+		want []error
+	}{
+		{"no devices", nil, nil, []error{ErrNoDevices}},
+		{
+			"no match",
+			[]*api.DeviceT{rsp1a()},
+			[]ConfigFn{WithSelector(WithSerials(api.ParseSerialNumber("1000000002")))},
+			[]error{ErrNoMatchingDevice},
+		},
+		{
+			"no dual mode",
+			[]*api.DeviceT{rsp1a(), halfDuo()},
+			[]ConfigFn{WithSelector(WithDuoModeDual(false), WithModels(api.RSPduo_ID))},
+			[]error{ErrNoMatchingDevice, ErrDualModeUnavailable},
+		},
+		{
+			// Dual mode is available, but filtered by serial number.
+			"dual mode but no match",
+			[]*api.DeviceT{idleDuo()},
+			[]ConfigFn{WithSelector(WithDuoModeSingle(), WithSerials(api.ParseSerialNumber("1500000003")))},
+			[]error{ErrNoMatchingDevice},
+		},
+		{
+			"single config in dual",
+			[]*api.DeviceT{idleDuo()},
+			[]ConfigFn{
+				WithSelector(WithDuoModeDual(false)),
+				WithDeviceConfig(WithSingleChannelConfig(WithTuneFreq(100e6))),
+			},
+			[]error{ErrSingleConfigInDual},
+		},
+	}
+
+	for _, spec := range specs {
+		m := apitest.NewMock(spec.devs...)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := Run(ctx, append([]ConfigFn{WithImplementation(m)}, spec.fns...)...)
+		if err == nil {
+			t.Errorf("unexpected success for %s", spec.name)
+			continue
+		}
+		for _, sentinel := range sentinels {
+			want := false
+			for _, w := range spec.want {
+				want = want || w == sentinel
+			}
+			if got := errors.Is(err, sentinel); got != want {
+				t.Errorf("wrong errors.Is(%q, %q) for %s: got %v, want %v", err, sentinel, spec.name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/helpers/callback"
+)
+
+// maxResampleFactor is the largest interpolation or decimation factor
+// used by PlanForExactRate. It bounds the length of the resampling
+// filter, which grows linearly with the factors.
+const maxResampleFactor = 4096
+
+// ExactRatePlan is the result of PlanForExactRate. It describes the
+// capture settings and the software resampling needed to produce an
+// exact output sample rate.
+type ExactRatePlan struct {
+	// Capture is the plan for the effective sample rate of the device.
+	Capture RatePlan
+	// Up is the interpolation factor of the software resampler.
+	Up int
+	// Down is the decimation factor of the software resampler.
+	Down int
+	// Rate is the resulting output sample rate in Hz. It is exactly
+	// Capture.Rate * Up / Down.
+	Rate float64
+}
+
+// PlanForExactRate finds the capture settings and resampling factors
+// that produce exactly targetRateHz for the provided device, including
+// rates below the minimum effective sample rate of the hardware, such
+// as the 8 kHz and 16 kHz used by voice codecs.
+//
+// If the device supports zero-IF mode, the capture rate is the smallest
+// integer multiple of the target rate that the hardware can produce, so
+// the resampler only decimates. For example, 8 kHz is captured at 64 kHz
+// and decimated by 8. An RSPduo in dual-tuner, primary, or secondary mode
+// only supports the low-IF rates, so the capture rate is the smallest
+// low-IF rate that is at least the target, and the resampler uses the
+// reduced rational ratio between them. In that case, the target rate
+// must be a whole number of Hz. For example, 8 kHz is captured at
+// 62.5 kHz and resampled by 16/125.
+//
+// It returns an error if no plan exists with factors of at most 4096.
+func PlanForExactRate(d *api.DeviceT, targetRateHz float64) (ExactRatePlan, error) {
+	var res ExactRatePlan
+	if math.IsNaN(targetRateHz) || math.IsInf(targetRateHz, 0) || targetRateHz <= 0 {
+		return res, fmt.Errorf("invalid target rate: got %v Hz, want > 0", targetRateHz)
+	}
+
+	minRate := MinZeroIFSampleRate / maxDecimation
+	down := int(math.Ceil(minRate / targetRateHz))
+	if down > maxResampleFactor {
+		return res, fmt.Errorf("invalid target rate: got %v Hz, want >= %v Hz", targetRateHz, minRate/maxResampleFactor)
+	}
+	if down < 1 {
+		down = 1
+	}
+	capture, err := PlanForOutputRate(d, targetRateHz*float64(down))
+	if err != nil {
+		return res, err
+	}
+	if capture.Rate == targetRateHz*float64(down) {
+		return ExactRatePlan{
+			Capture: capture,
+			Up:      1,
+			Down:    down,
+			Rate:    targetRateHz,
+		}, nil
+	}
+
+	// The device cannot produce a multiple of the target rate, so use
+	// the rational ratio from the capture rate chosen for the target.
+	capture, err = PlanForOutputRate(d, targetRateHz)
+	if err != nil {
+		return res, err
+	}
+	if targetRateHz != math.Trunc(targetRateHz) || capture.Rate != math.Trunc(capture.Rate) {
+		return res, fmt.Errorf("invalid target rate for %v Hz capture: got %v Hz, want whole number", capture.Rate, targetRateHz)
+	}
+	up, dn := int64(targetRateHz), int64(capture.Rate)
+	g := gcd(up, dn)
+	up /= g
+	dn /= g
+	if up > maxResampleFactor || dn > maxResampleFactor {
+		return res, fmt.Errorf("invalid target rate for %v Hz capture: got %v Hz, want ratio with factors <= %d", capture.Rate, targetRateHz, maxResampleFactor)
+	}
+	return ExactRatePlan{
+		Capture: capture,
+		Up:      int(up),
+		Down:    int(dn),
+		Rate:    targetRateHz,
+	}, nil
+}
+
+// gcd returns the greatest common divisor of two positive integers.
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// ExactRateCallbackT is the type of the function called by ExactRate
+// with samples at the exact target rate. The reset argument is true for
+// the first samples after a stream reset.
+type ExactRateCallbackT func(xi, xq []int16, reset bool)
+
+// ExactRate ties together rate planning, capture, and resampling to
+// present a stream at an exact sample rate that the hardware cannot
+// produce directly. Use Config to configure the channel and
+// StreamCallback as the stream callback of that channel. Each stream
+// needs its own ExactRate.
+//
+//	er := session.NewExactRate(8000, fn)
+//	sess, err := session.NewSession(
+//		session.WithDeviceConfig(
+//			session.WithSingleChannelConfig(er.Config(), ...),
+//		),
+//		session.WithStreamACallback(er.StreamCallback),
+//	)
+//
+// An ExactRate is safe for concurrent use by the configuration and the
+// stream callback.
+type ExactRate struct {
+	mu       sync.Mutex
+	target   float64
+	fn       ExactRateCallbackT
+	plan     ExactRatePlan
+	planned  bool
+	resample callback.ResampleFn
+	reset    bool
+}
+
+// NewExactRate creates a new ExactRate that calls fn with samples at
+// exactly targetRateHz.
+func NewExactRate(targetRateHz float64, fn ExactRateCallbackT) *ExactRate {
+	return &ExactRate{target: targetRateHz, fn: fn}
+}
+
+// Config creates a function to configure the selected channel with the
+// capture settings determined by PlanForExactRate. It also creates the
+// resampler used by StreamCallback.
+func (e *ExactRate) Config() ChanConfigFn {
+	return func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+		if c == nil {
+			return errors.New("cannot configure nil channel")
+		}
+		plan, err := PlanForExactRate(d, e.target)
+		if err != nil {
+			return err
+		}
+		if err := applyRatePlan(d, p, c, plan.Capture); err != nil {
+			return err
+		}
+		resample, err := callback.NewResampleFn(plan.Up, plan.Down)
+		if err != nil {
+			return err
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.plan = plan
+		e.planned = true
+		e.resample = resample
+		return nil
+	}
+}
+
+// Plan returns the plan used by the last call of the function created by
+// Config and whether such a call was made.
+func (e *ExactRate) Plan() (ExactRatePlan, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.plan, e.planned
+}
+
+// StreamCallback implements api.StreamCallbackT. It resamples the
+// provided samples and passes any output to the ExactRateCallbackT. A
+// reset indicated by the API clears the resampler history, so samples
+// from before and after the reset are never mixed, and is passed on with
+// the next output. Samples received before the channel is configured are
+// discarded.
+func (e *ExactRate) StreamCallback(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.planned {
+		return
+	}
+	if reset {
+		resample, err := callback.NewResampleFn(e.plan.Up, e.plan.Down)
+		if err == nil {
+			e.resample = resample
+		}
+		e.reset = true
+	}
+	yi, yq := e.resample(xi, xq)
+	if len(yi) == 0 {
+		return
+	}
+	e.fn(yi, yq, e.reset)
+	e.reset = false
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestPlanForExactRate(t *testing.T) {
+	t.Parallel()
+
+	rsp1a := &api.DeviceT{HWVer: api.RSP1A_ID}
+	dualDuo := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner, RspDuoSampleFreq: 6e6}
+
+	specs := []struct {
+		d        *api.DeviceT
+		target   float64
+		lowIF    bool
+		capture  float64
+		up, down int
+	}{
+		{rsp1a, 8e3, false, 64e3, 1, 8},
+		{rsp1a, 16e3, false, 64e3, 1, 4},
+		{rsp1a, 44.1e3, false, 88.2e3, 1, 2},
+		{rsp1a, 48e3, false, 96e3, 1, 2},
+		{rsp1a, 62.5e3, true, 62.5e3, 1, 1},
+		{rsp1a, 100e3, false, 100e3, 1, 1},
+		{dualDuo, 8e3, true, 62.5e3, 16, 125},
+		{dualDuo, 16e3, true, 62.5e3, 32, 125},
+		{dualDuo, 48e3, true, 62.5e3, 96, 125},
+		{dualDuo, 250e3, true, 250e3, 1, 1},
+	}
+
+	for _, spec := range specs {
+		plan, err := PlanForExactRate(spec.d, spec.target)
+		if err != nil {
+			t.Errorf("unexpected error for %v Hz: %v", spec.target, err)
+			continue
+		}
+		if plan.Capture.LowIF != spec.lowIF || plan.Capture.Rate != spec.capture || plan.Up != spec.up || plan.Down != spec.down {
+			t.Errorf(
+				"wrong plan for %v Hz: got %+v, want LowIF=%v Capture=%v Up=%v Down=%v",
+				spec.target, plan, spec.lowIF, spec.capture, spec.up, spec.down,
+			)
+		}
+		if got := plan.Capture.Rate * float64(plan.Up) / float64(plan.Down); got != spec.target || plan.Rate != spec.target {
+			t.Errorf("wrong rate for %v Hz: got %v (Rate=%v)", spec.target, got, plan.Rate)
+		}
+	}
+
+	invalid := []struct {
+		d      *api.DeviceT
+		target float64
+	}{
+		{rsp1a, 0},
+		{rsp1a, -8e3},
+		{rsp1a, math.NaN()},
+		{rsp1a, 1},
+		{rsp1a, 20e6},
+		{dualDuo, 8000.5},
+		{dualDuo, 8001},
+		{dualDuo, 3e6},
+	}
+	for _, spec := range invalid {
+		if plan, err := PlanForExactRate(spec.d, spec.target); err == nil {
+			t.Errorf("unexpected success for %v Hz on %v: got %+v", spec.target, spec.d.HWVer, plan)
+		}
+	}
+}
+
+func TestExactRate(t *testing.T) {
+	t.Parallel()
+
+	const (
+		amp    = 10000
+		toneHz = 1000
+		target = 8e3
+		numOut = 4000
+		settle = 64 // output samples to skip for filter transient
+	)
+
+	specs := []struct {
+		name string
+		d    *api.DeviceT
+	}{
+		{"zero-IF", &api.DeviceT{HWVer: api.RSP1A_ID}},
+		{"low-IF", &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner, RspDuoSampleFreq: 8e6}},
+	}
+
+	for _, spec := range specs {
+		spec := spec
+		t.Run(spec.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				yi, yq []int16
+				resets int
+			)
+			er := NewExactRate(target, func(xi, xq []int16, reset bool) {
+				if reset {
+					resets++
+				}
+				yi = append(yi, xi...)
+				yq = append(yq, xq...)
+			})
+			if _, ok := er.Plan(); ok {
+				t.Fatal("unexpected plan before config")
+			}
+
+			p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+			if err := er.Config()(spec.d, p, p.RxChannelA); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			plan, ok := er.Plan()
+			if !ok {
+				t.Fatal("missing plan after config")
+			}
+			rate, err := GetEffectiveSampleRate(spec.d, p, p.RxChannelA)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rate != plan.Capture.Rate {
+				t.Fatalf("wrong capture rate: got %v, want %v", rate, plan.Capture.Rate)
+			}
+
+			// Feed a tone at the configured capture rate in blocks the
+			// size of a typical stream callback.
+			numIn := numOut * plan.Down / plan.Up
+			xi := make([]int16, numIn)
+			xq := make([]int16, numIn)
+			for n := range xi {
+				phase := 2 * math.Pi * toneHz / rate * float64(n)
+				xi[n] = int16(math.Round(amp * math.Cos(phase)))
+				xq[n] = int16(math.Round(amp * math.Sin(phase)))
+			}
+			params := &api.StreamCbParamsT{}
+			for start := 0; start < numIn; start += 1008 {
+				end := start + 1008
+				if end > numIn {
+					end = numIn
+				}
+				er.StreamCallback(xi[start:end], xq[start:end], params, start == 0)
+			}
+
+			if len(yi) != numOut {
+				t.Fatalf("wrong output length: got %d, want %d", len(yi), numOut)
+			}
+			if got := float64(len(yi)) / (float64(numIn) / rate); got != target {
+				t.Errorf("wrong output rate: got %v, want %v", got, target)
+			}
+			if resets != 1 {
+				t.Errorf("wrong number of resets: got %d, want 1", resets)
+			}
+
+			// Project the output onto the expected tone.
+			var acc complex128
+			for n := settle; n < numOut; n++ {
+				x := complex(float64(yi[n]), float64(yq[n]))
+				acc += x * cmplx.Exp(complex(0, -2*math.Pi*toneHz/target*float64(n)))
+			}
+			if got := cmplx.Abs(acc) / (numOut - settle); math.Abs(got-amp) > amp*0.01 {
+				t.Errorf("wrong tone amplitude: got %.1f, want %.1f", got, float64(amp))
+			}
+		})
+	}
+}
+
+func TestExactRateNilChannel(t *testing.T) {
+	t.Parallel()
+
+	er := NewExactRate(8e3, func(xi, xq []int16, reset bool) {})
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	if err := er.Config()(d, &api.DeviceParamsT{DevParams: &api.DevParamsT{}}, nil); err == nil {
+		t.Error("unexpected success for nil channel")
+	}
+	// Samples before configuration are discarded without panicking.
+	er.StreamCallback([]int16{1}, []int16{1}, &api.StreamCbParamsT{}, true)
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// GainTableEntry is a single entry of a gain table used to select gain
+// settings based on the tuned RF frequency. The entry matches
+// frequencies from FreqLow, inclusive, to FreqHigh, exclusive.
+type GainTableEntry struct {
+	// FreqLow is the lowest matching frequency in Hz.
+	FreqLow float64
+	// FreqHigh is the first frequency in Hz above FreqLow that does
+	// not match.
+	FreqHigh float64
+	// LNAState is the LNA state applied with SetLNAState.
+	LNAState uint8
+	// GRdB is the gain reduction applied with SetGainReduction.
+	GRdB int32
+}
+
+// checkGainTable returns a non-nil error if any entry in entries has
+// an empty or inverted frequency range.
+func checkGainTable(entries []GainTableEntry) error {
+	for i, e := range entries {
+		if e.FreqLow >= e.FreqHigh {
+			return fmt.Errorf("invalid gain table entry %d: got %v-%v Hz, want FreqLow < FreqHigh", i, e.FreqLow, e.FreqHigh)
+		}
+	}
+	return nil
+}
+
+// LookupGain returns the first entry in entries whose range contains
+// freq. It returns false if no entry matches.
+func LookupGain(entries []GainTableEntry, freq float64) (GainTableEntry, bool) {
+	for _, e := range entries {
+		if freq >= e.FreqLow && freq < e.FreqHigh {
+			return e, true
+		}
+	}
+	return GainTableEntry{}, false
+}
+
+// SetGainFromTable sets the LNA state and gain reduction of the
+// specified channel from the entry in entries that matches the RF
+// frequency currently configured in the channel. If no entry matches,
+// the gain settings are left unchanged, so the gain configured by any
+// other means (e.g. WithLNAState) serves as the default. It returns an
+// error if the table is invalid or the LNA state of the matching entry
+// is not valid for the device.
+func SetGainFromTable(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, entries []GainTableEntry) error {
+	if c == nil {
+		return errors.New("cannot configure nil channel")
+	}
+	if err := checkGainTable(entries); err != nil {
+		return err
+	}
+	e, ok := LookupGain(entries, c.TunerParams.RfFreq.RfHz)
+	if !ok {
+		return nil
+	}
+	if err := SetLNAState(d, p, c, e.LNAState); err != nil {
+		return err
+	}
+	return SetGainReduction(d, p, c, e.GRdB)
+}
+
+// WithGainTable creates a function that sets the gain of a channel
+// from a gain table based on its tuned frequency. See SetGainFromTable.
+// Because the frequency is read from the channel params, it must be
+// applied after WithTuneFreq. Use RetuneWithGainTable or
+// WithFastScanGainTable to apply the same table when retuning a running
+// device.
+func WithGainTable(entries []GainTableEntry) ChanConfigFn {
+	return func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+		return SetGainFromTable(d, p, c, entries)
+	}
+}
+
+// RetuneWithGainTable is like Retune, but also applies the gain from
+// entries for the new frequency, as with SetGainFromTable. The frequency
+// and gain changes are issued in a single Update with both
+// Update_Tuner_Frf and Update_Tuner_Gr.
+func RetuneWithGainTable(d *api.DeviceT, a api.API, tuner api.TunerSelectT, freq float64, entries []GainTableEntry) error {
+	return updateRuntime(
+		d, a, tuner, api.Update_Tuner_Frf|api.Update_Tuner_Gr, api.Update_Ext1_None,
+		func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+			if err := SetTuneFreq(d, p, c, freq); err != nil {
+				return err
+			}
+			return SetGainFromTable(d, p, c, entries)
+		},
+	)
+}
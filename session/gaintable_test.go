@@ -0,0 +1,146 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+var testGainTable = []GainTableEntry{
+	{FreqLow: 0, FreqHigh: 30e6, LNAState: 1, GRdB: 30},
+	{FreqLow: 87.5e6, FreqHigh: 108e6, LNAState: 6, GRdB: 50},
+	{FreqLow: 108e6, FreqHigh: 137e6, LNAState: 2, GRdB: 40},
+	// Overlaps the previous entry. The first match wins.
+	{FreqLow: 100e6, FreqHigh: 200e6, LNAState: 3, GRdB: 45},
+}
+
+func TestGainTable(t *testing.T) {
+	t.Parallel()
+
+	const (
+		defLNA  = 4
+		defGRdB = 35
+	)
+	specs := []struct {
+		freq    float64
+		match   bool
+		wantLNA uint8
+		wantGR  int32
+	}{
+		{1e6, true, 1, 30},
+		{29.999e6, true, 1, 30},
+		{30e6, false, defLNA, defGRdB},
+		{50e6, false, defLNA, defGRdB},
+		{87.5e6, true, 6, 50},
+		{100e6, true, 6, 50},
+		{108e6, true, 2, 40},
+		{150e6, true, 3, 45},
+		{200e6, false, defLNA, defGRdB},
+		{1e9, false, defLNA, defGRdB},
+	}
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	for _, spec := range specs {
+		_, ok := LookupGain(testGainTable, spec.freq)
+		if ok != spec.match {
+			t.Errorf("wrong match for %v Hz: got %v, want %v", spec.freq, ok, spec.match)
+		}
+
+		p := &api.DeviceParamsT{RxChannelA: &api.RxChannelParamsT{}}
+		c := p.RxChannelA
+		c.TunerParams.Gain.LNAstate = defLNA
+		c.TunerParams.Gain.GRdB = defGRdB
+		fns := []ChanConfigFn{WithTuneFreq(spec.freq), WithGainTable(testGainTable)}
+		for _, fn := range fns {
+			if err := fn(d, p, c); err != nil {
+				t.Fatalf("unexpected error for %v Hz: %v", spec.freq, err)
+			}
+		}
+		if got := c.TunerParams.Gain.LNAstate; got != spec.wantLNA {
+			t.Errorf("wrong LNA state for %v Hz: got %d, want %d", spec.freq, got, spec.wantLNA)
+		}
+		if got := c.TunerParams.Gain.GRdB; got != spec.wantGR {
+			t.Errorf("wrong GRdB for %v Hz: got %d, want %d", spec.freq, got, spec.wantGR)
+		}
+	}
+}
+
+func TestGainTableInvalid(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	p := &api.DeviceParamsT{RxChannelA: &api.RxChannelParamsT{}}
+	p.RxChannelA.TunerParams.RfFreq.RfHz = 100e6
+
+	specs := [][]GainTableEntry{
+		{{FreqLow: 200e6, FreqHigh: 100e6}},
+		{{FreqLow: 100e6, FreqHigh: 100e6}},
+		// LNA state out of range for an RSP1A.
+		{{FreqLow: 0, FreqHigh: 1e9, LNAState: 20, GRdB: 40}},
+	}
+	for _, entries := range specs {
+		if err := SetGainFromTable(d, p, p.RxChannelA, entries); err == nil {
+			t.Errorf("unexpected success for %+v", entries)
+		}
+	}
+	if err := SetGainFromTable(d, p, nil, testGainTable); err == nil {
+		t.Error("unexpected success for nil channel")
+	}
+	if _, err := NewSession(WithFastScanGainTable([]float64{100e6}, 0, specs[0], nil)); err == nil {
+		t.Error("unexpected success for scan with invalid table")
+	}
+}
+
+func TestWithFastScanGainTable(t *testing.T) {
+	t.Parallel()
+
+	freqs := []float64{10e6, 95e6, 50e6, 150e6}
+	want := []struct {
+		lna uint8
+		gr  int32
+	}{
+		{1, 30},
+		{6, 50},
+		{6, 50}, // no match, keeps previous gain
+		{3, 45},
+	}
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	m := apitest.NewMock(d)
+	errDone := errors.New("done")
+	var step int
+	err := Run(
+		context.Background(),
+		WithImplementation(m),
+		WithFastScanGainTable(freqs, 0, testGainTable, func(ctx context.Context, d *api.DeviceT, a api.API, freq float64) error {
+			p, err := a.LoadDeviceParams(d.Dev)
+			if err != nil {
+				return err
+			}
+			gain := p.RxChannelA.TunerParams.Gain
+			if gain.LNAstate != want[step].lna || gain.GRdB != want[step].gr {
+				t.Errorf("wrong gain at %v Hz: got %d/%d, want %d/%d", freq, gain.LNAstate, gain.GRdB, want[step].lna, want[step].gr)
+			}
+			step++
+			if step == len(freqs) {
+				return errDone
+			}
+			return nil
+		}),
+	)
+	if err != errDone {
+		t.Fatalf("wrong error: got %v, want %v", err, errDone)
+	}
+	for _, u := range m.Updates {
+		if u.Reason != api.Update_Tuner_Frf|api.Update_Tuner_Gr {
+			t.Errorf("wrong update reason: got %v, want %v", u.Reason, api.Update_Tuner_Frf|api.Update_Tuner_Gr)
+		}
+	}
+}
@@ -105,6 +105,43 @@ func WithLNAState(val uint8) ChanConfigFn {
 	}
 }
 
+// LNAStateToPercent returns the approximate percent of available LNA
+// gain that corresponds to the provided LNA state for the provided device
+// and channel configuration. The result value is a percentage represented
+// as a floating-point value from 0 to 1. A state of 0 is maximum gain
+// (1) and the state reported by GetMaxLNAState is minimum gain (0). A
+// state greater than the maximum state is treated as the maximum state.
+// If the maximum state is unknown (i.e. 0), the result is 0.
+func LNAStateToPercent(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, state uint8) float64 {
+	max := GetMaxLNAState(d, p, c)
+	if max == 0 {
+		return 0
+	}
+	if state > max {
+		state = max
+	}
+	return float64(max-state) / float64(max)
+}
+
+// PercentToLNAState returns the LNA state that provides approximately
+// the requested percent of available LNA gain for the provided device and
+// channel configuration. The percentage is a floating-point value from 0
+// to 1. Values outside of that range are clamped. Because the LNA state
+// is discrete, the percent is rounded toward less gain.
+func PercentToLNAState(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, pct float64) uint8 {
+	switch {
+	case pct < 0:
+		pct = 0
+	case pct > 1:
+		pct = 1
+	}
+	// The small offset compensates for floating-point error so that a
+	// percent produced by LNAStateToPercent maps back to the same state.
+	const eps = 1e-9
+	max := GetMaxLNAState(d, p, c)
+	return max - uint8(float64(max)*pct+eps)
+}
+
 // GetLNAPercent returns the approximate percent of available LNA gain
 // currently configured for the provided device and channel. The result
 // value is percentage represented as a floating-point value from 0 to 1.
@@ -112,12 +149,12 @@ func GetLNAPercent(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT
 	if c == nil {
 		return 0, errors.New("cannot inspect nil channel")
 	}
-	max := GetMaxLNAState(d, p, p.RxChannelA)
+	max := GetMaxLNAState(d, p, c)
 	val := c.TunerParams.Gain.LNAstate
 	if val > max {
 		return 0, fmt.Errorf("invalid LNA state: got %d, want <= %d", val, max)
 	}
-	return float64(max-val) / float64(max), nil
+	return LNAStateToPercent(d, p, c, val), nil
 }
 
 // SetLNAPercent sets the LNAstate value in the given RxChannelParamsT to the
@@ -131,9 +168,7 @@ func SetLNAPercent(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT
 	if pct < 0 || pct > 1 {
 		return fmt.Errorf("invalid LNA percent: got %f, want 0 <= pct <= 1", pct)
 	}
-	max := GetMaxLNAState(d, p, p.RxChannelA)
-	val := max - uint8(float64(max)*pct)
-	c.TunerParams.Gain.LNAstate = val
+	c.TunerParams.Gain.LNAstate = PercentToLNAState(d, p, c, pct)
 	return nil
 }
 
@@ -0,0 +1,109 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func newLNATestParams(hwVer api.HWVersion, freq float64) (*api.DeviceT, *api.DeviceParamsT, *api.RxChannelParamsT) {
+	d := &api.DeviceT{HWVer: hwVer}
+	c := &api.RxChannelParamsT{}
+	c.TunerParams.RfFreq.RfHz = freq
+	p := &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelA: c,
+		RxChannelB: &api.RxChannelParamsT{},
+	}
+	return d, p, c
+}
+
+func TestLNAStateToPercent(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		hwVer api.HWVersion
+		freq  float64
+		state uint8
+		want  float64
+	}{
+		{api.RSP1_ID, 100e6, 0, 1},
+		{api.RSP1_ID, 100e6, 3, 0},
+		{api.RSP1_ID, 100e6, 1, 2.0 / 3},
+		{api.RSP1A_ID, 10e6, 3, 0.5},
+		{api.RSP1A_ID, 100e6, 9, 0},
+		{api.RSP1A_ID, 1.5e9, 2, 0.75},
+		{api.RSP2_ID, 500e6, 5, 0},
+		{api.RSPduo_ID, 100e6, 3, 2.0 / 3},
+		{api.RSPdx_ID, 100e6, 13, 0.5},
+		{api.RSPdx_ID, 100e6, 30, 0},
+		{api.HWVersion(0), 100e6, 1, 0},
+	}
+
+	for _, spec := range specs {
+		d, p, c := newLNATestParams(spec.hwVer, spec.freq)
+		if got := LNAStateToPercent(d, p, c, spec.state); got != spec.want {
+			t.Errorf("wrong percent for %v at %v Hz state %d: got %v, want %v", spec.hwVer, spec.freq, spec.state, got, spec.want)
+		}
+	}
+}
+
+func TestPercentToLNAState(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		hwVer api.HWVersion
+		freq  float64
+		pct   float64
+		want  uint8
+	}{
+		{api.RSP1_ID, 100e6, 1, 0},
+		{api.RSP1_ID, 100e6, 0, 3},
+		{api.RSP1_ID, 100e6, 0.5, 2},
+		{api.RSP1A_ID, 10e6, 0.5, 3},
+		{api.RSP1A_ID, 100e6, 0.5, 5},
+		{api.RSP1A_ID, 1.5e9, 0.5, 4},
+		{api.RSP2_ID, 100e6, 0.25, 6},
+		{api.RSPduo_ID, 1e9, 1.5, 0},
+		{api.RSPdx_ID, 300e6, -1, 27},
+		{api.RSPdx_ID, 300e6, 0.5, 14},
+	}
+
+	for _, spec := range specs {
+		d, p, c := newLNATestParams(spec.hwVer, spec.freq)
+		if got := PercentToLNAState(d, p, c, spec.pct); got != spec.want {
+			t.Errorf("wrong state for %v at %v Hz %v%%: got %d, want %d", spec.hwVer, spec.freq, spec.pct*100, got, spec.want)
+		}
+	}
+}
+
+func TestLNAPercentRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	hwVers := []api.HWVersion{api.RSP1_ID, api.RSP1A_ID, api.RSP2_ID, api.RSPduo_ID, api.RSPdx_ID}
+	freqs := []float64{1e6, 10e6, 50e6, 100e6, 300e6, 500e6, 1.5e9}
+	for _, hwVer := range hwVers {
+		for _, freq := range freqs {
+			d, p, c := newLNATestParams(hwVer, freq)
+			max := GetMaxLNAState(d, p, c)
+			for state := uint8(0); state <= max; state++ {
+				pct := LNAStateToPercent(d, p, c, state)
+				if got := PercentToLNAState(d, p, c, pct); got != state {
+					t.Errorf("wrong round trip for %v at %v Hz: got %d, want %d", hwVer, freq, got, state)
+				}
+				c.TunerParams.Gain.LNAstate = state
+				got, err := GetLNAPercent(d, p, c)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != pct {
+					t.Errorf("wrong GetLNAPercent for %v at %v Hz: got %v, want %v", hwVer, freq, got, pct)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// SetLoMode configures the LO mode for the specified channel.
+//
+// The LO mode only affects tuning in the range where the tuner uses
+// an up-converting first mixer with a fixed LO (roughly 250 MHz to
+// 420 MHz; see the SDRplay API specification for exact band edges).
+// In that range, the choice of the 120 MHz, 144 MHz, or 168 MHz LO
+// determines where LO harmonics and mixing products fall relative to
+// the tuned frequency. A product that lands at or near the tuned
+// frequency appears as a spur at DC in zero-IF mode. Selecting a
+// different LO moves it out of the passband. Outside of that range,
+// the LO mode has no effect.
+func SetLoMode(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, mode api.LoModeT) error {
+	if c == nil {
+		return errors.New("cannot configure nil channel")
+	}
+
+	if err := checkLoMode(mode); err != nil {
+		return err
+	}
+	c.TunerParams.LoMode = mode
+	return nil
+}
+
+// checkLoMode returns an error if mode is not a valid LO mode.
+func checkLoMode(mode api.LoModeT) error {
+	switch mode {
+	case api.LO_Auto, api.LO_120MHz, api.LO_144MHz, api.LO_168MHz:
+		return nil
+	default:
+		return fmt.Errorf("invalid LO mode: got %v", mode)
+	}
+}
+
+// WithLoMode creates a function that uses SetLoMode to configure
+// the LO mode.
+func WithLoMode(mode api.LoModeT) ChanConfigFn {
+	return func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+		return SetLoMode(d, p, c, mode)
+	}
+}
+
+// LoRule associates an LO mode with an inclusive range of tuner RF
+// frequencies in Hz. It is used by WithLoPlan to select an LO mode
+// based on the tuned frequency.
+type LoRule struct {
+	MinHz float64
+	MaxHz float64
+	Mode  api.LoModeT
+}
+
+// WithLoPlan creates a function that selects the LO mode based on the
+// RF frequency already configured in the channel params. The rules are
+// evaluated in order and the first rule with a range containing the
+// tuned frequency is applied with SetLoMode. If no rule matches, the
+// LO mode is left unchanged. The function returns an error if any rule
+// has an inverted range or an invalid LO mode, even if it does not
+// match the tuned frequency.
+//
+// Because it reads the tuned frequency, the function must be applied
+// after WithTuneFreq. It composes with WithLoMode, which can be applied
+// before it to set the LO mode used outside of the planned bands.
+//
+// Examples:
+// 		// Use the 144 MHz LO when tuned near a known spur, otherwise auto.
+// 		WithSingleChannelConfig(
+// 			WithTuneFreq(freq),
+// 			WithLoMode(api.LO_Auto),
+// 			WithLoPlan(LoRule{MinHz: 359e6, MaxHz: 361e6, Mode: api.LO_144MHz}),
+// 		)
+func WithLoPlan(rules ...LoRule) ChanConfigFn {
+	// Validate every rule up front, so that an invalid rule is reported
+	// regardless of the tuned frequency.
+	var err error
+	for _, rule := range rules {
+		if !(rule.MinHz <= rule.MaxHz) {
+			err = fmt.Errorf("invalid LO rule range: got %f Hz to %f Hz, want min <= max", rule.MinHz, rule.MaxHz)
+			break
+		}
+		if err = checkLoMode(rule.Mode); err != nil {
+			break
+		}
+	}
+	return func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return errors.New("cannot configure nil channel")
+		}
+		freq := c.TunerParams.RfFreq.RfHz
+		for _, rule := range rules {
+			if freq >= rule.MinHz && freq <= rule.MaxHz {
+				return SetLoMode(d, p, c, rule.Mode)
+			}
+		}
+		return nil
+	}
+}
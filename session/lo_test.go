@@ -0,0 +1,79 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestWithLoPlan(t *testing.T) {
+	t.Parallel()
+
+	plan := WithLoPlan(
+		LoRule{MinHz: 300e6, MaxHz: 310e6, Mode: api.LO_120MHz},
+		LoRule{MinHz: 305e6, MaxHz: 400e6, Mode: api.LO_168MHz},
+	)
+
+	specs := []struct {
+		freq float64
+		want api.LoModeT
+	}{
+		{100e6, api.LO_144MHz},
+		{300e6, api.LO_120MHz},
+		{307e6, api.LO_120MHz},
+		{310e6, api.LO_120MHz},
+		{320e6, api.LO_168MHz},
+		{400e6, api.LO_168MHz},
+		{401e6, api.LO_144MHz},
+	}
+
+	for _, spec := range specs {
+		dev := &api.DeviceT{HWVer: api.RSP1A_ID}
+		params := &api.DeviceParamsT{DevParams: &api.DevParamsT{}}
+		rx := &api.RxChannelParamsT{}
+		cfgs := []ChanConfigFn{
+			WithTuneFreq(spec.freq),
+			WithLoMode(api.LO_144MHz),
+			plan,
+		}
+		for _, cfg := range cfgs {
+			if err := cfg(dev, params, rx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if rx.TunerParams.LoMode != spec.want {
+			t.Errorf("wrong LO mode for %f Hz: got %v, want %v", spec.freq, rx.TunerParams.LoMode, spec.want)
+		}
+	}
+
+	rx := &api.RxChannelParamsT{}
+	rx.TunerParams.RfFreq.RfHz = 100e6
+	bad := WithLoPlan(LoRule{MinHz: 200e6, MaxHz: 100e6, Mode: api.LO_120MHz})
+	if err := bad(&api.DeviceT{}, &api.DeviceParamsT{}, rx); err == nil {
+		t.Error("unexpected success on invalid rule range")
+	}
+	bad = WithLoPlan(LoRule{MinHz: 0, MaxHz: 200e6, Mode: api.LO_Undefined})
+	if err := bad(&api.DeviceT{}, &api.DeviceParamsT{}, rx); err == nil {
+		t.Error("unexpected success on invalid LO mode")
+	}
+
+	// Every rule is checked, not only the first match.
+	bad = WithLoPlan(
+		LoRule{MinHz: 0, MaxHz: 200e6, Mode: api.LO_120MHz},
+		LoRule{MinHz: 400e6, MaxHz: 300e6, Mode: api.LO_120MHz},
+	)
+	if err := bad(&api.DeviceT{}, &api.DeviceParamsT{}, rx); err == nil {
+		t.Error("unexpected success on invalid rule range after match")
+	}
+	bad = WithLoPlan(
+		LoRule{MinHz: 0, MaxHz: 200e6, Mode: api.LO_120MHz},
+		LoRule{MinHz: 300e6, MaxHz: 400e6, Mode: api.LO_Undefined},
+	)
+	if err := bad(&api.DeviceT{}, &api.DeviceParamsT{}, rx); err == nil {
+		t.Error("unexpected success on invalid LO mode after match")
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// MultiStreamCallbackT is implemented by a function that receives the
+// stream callbacks of all devices in a MultiSession. The idx argument is
+// the index of the Session in MultiSession.Sessions that produced the
+// samples and tuner is Tuner_A for stream A or Tuner_B for stream B.
+// Callbacks from different devices are made concurrently from different
+// API threads.
+type MultiStreamCallbackT func(idx int, tuner api.TunerSelectT, xi, xq []int16, params *api.StreamCbParamsT, reset bool)
+
+// MultiDevice describes one device of a running MultiSession.
+type MultiDevice struct {
+	Device *api.DeviceT
+	Impl   api.API
+	// Started is the wall time immediately before Init was called for
+	// the device.
+	Started time.Time
+}
+
+// MultiControlFn is implemented by a function that is responsible for
+// run-time control of a MultiSession after all devices have been
+// initialized. The devs argument has one entry per Session in the same
+// order as MultiSession.Sessions. It has the same role as ControlFn.
+type MultiControlFn func(ctx context.Context, devs []MultiDevice) error
+
+// MultiSession coordinates a Session for each of several separate RSP
+// devices so that their streams start as close together as the API
+// allows. All devices are selected and configured before any device is
+// initialized, and then Init is called for each device back to back.
+//
+// The devices still run from independent clocks, so the streams are
+// only roughly aligned and drift apart over time. For better alignment,
+// feed all devices from a common reference clock, such as the reference
+// output of an RSP2 or RSPduo (see WithRefClockOutput), and measure the
+// remaining offset between streams with the FirstSampleNum and wall
+// time of the callbacks.
+//
+// The Selector, DevCfg, StreamACbFn, StreamBCbFn, and EventCbFn of
+// each Session are used as they would be by Session.Run. Devices that
+// have already been selected by an earlier Session are excluded from
+// selection by later sessions, so the same selector can be used for
+// identical devices. The Control, AutoTransfer, AdaptiveDec, AFC,
+// AutoGain, and AGCClamp members of each Session are not supported.
+type MultiSession struct {
+	Sessions   []*Session
+	StreamCbFn MultiStreamCallbackT
+	Control    MultiControlFn
+}
+
+// NewMultiSession creates a new MultiSession with the provided sessions.
+// It returns an error if fewer than two sessions are provided or any
+// Session has a control loop, automatic transfer mode selection,
+// adaptive decimation, AFC, automatic gain, or an AGC clamp configured.
+func NewMultiSession(sessions ...*Session) (*MultiSession, error) {
+	if len(sessions) < 2 {
+		return nil, fmt.Errorf("invalid number of sessions: got %d, want >= 2", len(sessions))
+	}
+	for i, s := range sessions {
+		if err := checkMultiSession(i, s); err != nil {
+			return nil, err
+		}
+	}
+	return &MultiSession{Sessions: sessions}, nil
+}
+
+// checkMultiSession returns a non-nil error if the Session cannot be
+// used as part of a MultiSession.
+func checkMultiSession(idx int, s *Session) error {
+	switch {
+	case s == nil:
+		return fmt.Errorf("session %d is nil", idx)
+	case s.Control != nil:
+		return fmt.Errorf("session %d has a control loop; use MultiSession.Control", idx)
+	case s.AutoTransfer != nil:
+		return fmt.Errorf("session %d has automatic transfer mode selection", idx)
+	case s.AdaptiveDec != nil:
+		return fmt.Errorf("session %d has adaptive decimation", idx)
+	case s.AFC != nil:
+		return fmt.Errorf("session %d has AFC", idx)
+	case s.AutoGain != nil:
+		return fmt.Errorf("session %d has automatic gain", idx)
+	case s.AGCClamp != nil:
+		return fmt.Errorf("session %d has an AGC clamp", idx)
+	}
+	return nil
+}
+
+// streamCallback creates a StreamCallbackT that calls the provided
+// Session callback and then the MultiSession callback with the index
+// of the Session and the tuner.
+func (m *MultiSession) streamCallback(idx int, tuner api.TunerSelectT, fn api.StreamCallbackT) api.StreamCallbackT {
+	if m.StreamCbFn == nil {
+		return fn
+	}
+	return func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		if fn != nil {
+			fn(xi, xq, params, reset)
+		}
+		m.StreamCbFn(idx, tuner, xi, xq, params, reset)
+	}
+}
+
+// Run runs the configured MultiSession. Each distinct API implementation
+// is opened once. Then every Session selects and configures its device.
+// Only after all devices are configured is Init called for each device
+// in order. If any step fails, all devices that were already initialized
+// are uninitialized, all selected devices are released, and all APIs are
+// closed before Run returns the error.
+//
+// The provided Context is passed to the control loop function if one is
+// provided. If no control loop has been provided, Run will wait on the
+// ctx.Done() channel.
+func (m *MultiSession) Run(ctx context.Context) error {
+	if len(m.Sessions) == 0 {
+		return errors.New("no sessions configured")
+	}
+	for i, s := range m.Sessions {
+		if err := checkMultiSession(i, s); err != nil {
+			return err
+		}
+	}
+
+	// Cleanup functions are called in reverse order.
+	var cleanups []func()
+	defer func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}()
+
+	devs := make([]MultiDevice, len(m.Sessions))
+	var opened []api.API
+	var selected []api.SerialNumber
+	for i, s := range m.Sessions {
+		impl := s.Impl
+		if impl == nil {
+			impl = api.GetAPI()
+		}
+		isOpen := false
+		for _, o := range opened {
+			if o == impl {
+				isOpen = true
+				break
+			}
+		}
+		if !isOpen {
+			if err := openAPI(impl); err != nil {
+				return err
+			}
+			opened = append(opened, impl)
+			cleanups = append(cleanups, func() { impl.Close() })
+		}
+
+		dev, release, err := s.acquire(impl, selected)
+		if err != nil {
+			return wrapError(fmt.Sprintf("session %d: %v", i, err), err)
+		}
+		cleanups = append(cleanups, release)
+		selected = append(selected, dev.SerNo)
+		devs[i] = MultiDevice{Device: dev, Impl: impl}
+	}
+
+	// Start all streams as close together as possible.
+	for i, s := range m.Sessions {
+		d := devs[i]
+		cbFuncs := api.CallbackFnsT{
+			StreamACbFn: m.streamCallback(i, api.Tuner_A, s.StreamACbFn),
+			StreamBCbFn: m.streamCallback(i, api.Tuner_B, s.StreamBCbFn),
+			EventCbFn:   s.EventCbFn,
+		}
+		devs[i].Started = time.Now()
+		if err := d.Impl.Init(d.Device.Dev, cbFuncs); err != nil {
+			return fmt.Errorf("session %d: init failed: %v", i, d.Impl.GetLastError(d.Device))
+		}
+		cleanups = append(cleanups, func() {
+			if err := d.Impl.Uninit(d.Device.Dev); err != nil {
+				fmt.Fprintf(os.Stderr, "Uninit failed: %v", err)
+			}
+		})
+	}
+
+	switch m.Control {
+	case nil:
+		// No control loop provided, just wait on the context.
+		<-ctx.Done()
+		return ctx.Err()
+	default:
+		return m.Control(ctx, devs)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+	"github.com/msiner/sdrplay-go/session"
+)
+
+func ExampleMultiSession() {
+	// Mock implementations stand in for two RSP1A devices. With real
+	// devices, leave the implementation unset and use WithSelector to
+	// pick each device (e.g. by serial number).
+	mocks := []*apitest.Mock{
+		apitest.NewMock(&api.DeviceT{SerNo: api.ParseSerialNumber("1000000001"), HWVer: api.RSP1A_ID}),
+		apitest.NewMock(&api.DeviceT{SerNo: api.ParseSerialNumber("1000000002"), HWVer: api.RSP1A_ID}),
+	}
+
+	var sessions []*session.Session
+	for _, m := range mocks {
+		s, err := session.NewSession(
+			session.WithImplementation(m),
+			session.WithDeviceConfig(
+				session.WithSingleChannelConfig(
+					session.WithTuneFreq(100e6),
+				),
+			),
+		)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		sessions = append(sessions, s)
+	}
+
+	ms, err := session.NewMultiSession(sessions...)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	ms.StreamCbFn = func(idx int, tuner api.TunerSelectT, xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		fmt.Println(idx, tuner, params.NumSamples)
+	}
+	ms.Control = func(ctx context.Context, devs []session.MultiDevice) error {
+		for _, d := range devs {
+			fmt.Println(d.Device.SerNo)
+		}
+		// The API would normally make the callbacks.
+		mocks[0].Callbacks.StreamACbFn(nil, nil, &api.StreamCbParamsT{NumSamples: 1008}, true)
+		mocks[1].Callbacks.StreamACbFn(nil, nil, &api.StreamCbParamsT{NumSamples: 1008}, true)
+		return nil
+	}
+
+	if err := ms.Run(context.Background()); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 1000000001
+	// 1000000002
+	// 0 Tuner_A 1008
+	// 1 Tuner_A 1008
+}
@@ -0,0 +1,279 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+// orderMock is an apitest.Mock that records StoreDeviceParams and Init
+// calls in a log shared between multiple mocks.
+type orderMock struct {
+	*apitest.Mock
+	name string
+	mu   *sync.Mutex
+	log  *[]string
+}
+
+func (m *orderMock) record(call string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*m.log = append(*m.log, fmt.Sprintf("%s:%s", m.name, call))
+}
+
+func (m *orderMock) StoreDeviceParams(dev api.Handle, params *api.DeviceParamsT) error {
+	m.record("StoreDeviceParams")
+	return m.Mock.StoreDeviceParams(dev, params)
+}
+
+func (m *orderMock) Init(dev api.Handle, callbacks api.CallbackFnsT) error {
+	m.record("Init")
+	return m.Mock.Init(dev, callbacks)
+}
+
+func newMultiTestMocks() (*orderMock, *orderMock, *[]string) {
+	var (
+		mu  sync.Mutex
+		log []string
+	)
+	m0 := &orderMock{
+		Mock: apitest.NewMock(&api.DeviceT{SerNo: api.ParseSerialNumber("1000000001"), HWVer: api.RSP1A_ID}),
+		name: "m0",
+		mu:   &mu,
+		log:  &log,
+	}
+	m1 := &orderMock{
+		Mock: apitest.NewMock(&api.DeviceT{SerNo: api.ParseSerialNumber("1000000002"), HWVer: api.RSP1A_ID}),
+		name: "m1",
+		mu:   &mu,
+		log:  &log,
+	}
+	return m0, m1, &log
+}
+
+func TestMultiSession(t *testing.T) {
+	t.Parallel()
+
+	m0, m1, log := newMultiTestMocks()
+
+	type tag struct {
+		idx   int
+		tuner api.TunerSelectT
+		first uint32
+	}
+	var tags []tag
+	var perSession [2]int
+
+	var sessions []*Session
+	for i, m := range []*orderMock{m0, m1} {
+		i := i
+		s, err := NewSession(
+			WithImplementation(m),
+			WithDeviceConfig(WithTransferMode(api.BULK)),
+			WithStreamACallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+				perSession[i]++
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	ms, err := NewMultiSession(sessions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms.StreamCbFn = func(idx int, tuner api.TunerSelectT, xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		tags = append(tags, tag{idx, tuner, params.FirstSampleNum})
+	}
+	ms.Control = func(ctx context.Context, devs []MultiDevice) error {
+		if len(devs) != 2 {
+			t.Fatalf("wrong number of devices: got %d, want 2", len(devs))
+		}
+		for i, m := range []*orderMock{m0, m1} {
+			if devs[i].Impl != m {
+				t.Errorf("wrong impl for device %d", i)
+			}
+			if devs[i].Device.SerNo != m.Devices[0].SerNo {
+				t.Errorf("wrong device %d: got %v, want %v", i, devs[i].Device.SerNo, m.Devices[0].SerNo)
+			}
+			if devs[i].Started.IsZero() {
+				t.Errorf("missing start time for device %d", i)
+			}
+		}
+		// Deliver callbacks out of order to check routing.
+		m1.Callbacks.StreamACbFn(nil, nil, &api.StreamCbParamsT{FirstSampleNum: 10}, true)
+		m0.Callbacks.StreamACbFn(nil, nil, &api.StreamCbParamsT{FirstSampleNum: 20}, true)
+		m1.Callbacks.StreamBCbFn(nil, nil, &api.StreamCbParamsT{FirstSampleNum: 30}, false)
+		return nil
+	}
+
+	if err := ms.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantLog := []string{"m0:StoreDeviceParams", "m1:StoreDeviceParams", "m0:Init", "m1:Init"}
+	if !reflect.DeepEqual(*log, wantLog) {
+		t.Errorf("wrong call order: got %v, want %v", *log, wantLog)
+	}
+
+	wantTags := []tag{{1, api.Tuner_A, 10}, {0, api.Tuner_A, 20}, {1, api.Tuner_B, 30}}
+	if !reflect.DeepEqual(tags, wantTags) {
+		t.Errorf("wrong tags: got %v, want %v", tags, wantTags)
+	}
+	if perSession != [2]int{1, 1} {
+		t.Errorf("wrong session callback counts: got %v, want [1 1]", perSession)
+	}
+
+	for _, m := range []*orderMock{m0, m1} {
+		n := len(m.Calls)
+		if n < 3 || !reflect.DeepEqual(m.Calls[n-3:], []string{"Uninit", "ReleaseDevice", "Close"}) {
+			t.Errorf("wrong cleanup for %s: got %v", m.name, m.Calls)
+		}
+		if m.Params.DevParams.Mode != api.BULK {
+			t.Errorf("wrong transfer mode for %s: got %v, want %v", m.name, m.Params.DevParams.Mode, api.BULK)
+		}
+	}
+}
+
+func TestMultiSessionSharedImpl(t *testing.T) {
+	t.Parallel()
+
+	m := apitest.NewMock(
+		&api.DeviceT{SerNo: api.ParseSerialNumber("1000000001"), HWVer: api.RSP1A_ID},
+		&api.DeviceT{SerNo: api.ParseSerialNumber("1000000002"), HWVer: api.RSP1A_ID},
+	)
+	var sessions []*Session
+	for i := 0; i < 2; i++ {
+		s, err := NewSession(WithImplementation(m), WithSelector(WithModels(api.RSP1A_ID)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+	ms, err := NewMultiSession(sessions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var serials []api.SerialNumber
+	ms.Control = func(ctx context.Context, devs []MultiDevice) error {
+		for _, d := range devs {
+			serials = append(serials, d.Device.SerNo)
+		}
+		return nil
+	}
+	if err := ms.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(serials) != 2 || serials[0] == serials[1] {
+		t.Errorf("wrong devices selected: got %v", serials)
+	}
+
+	var numOpen, numClose int
+	for _, call := range m.Calls {
+		switch call {
+		case "Open":
+			numOpen++
+		case "Close":
+			numClose++
+		}
+	}
+	if numOpen != 1 || numClose != 1 {
+		t.Errorf("wrong number of Open/Close: got %d/%d, want 1/1", numOpen, numClose)
+	}
+
+	// A third session has no device left to select.
+	s, err := NewSession(WithImplementation(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms.Sessions = append(ms.Sessions, s)
+	if err := ms.Run(context.Background()); !errors.Is(err, ErrNoDevices) {
+		t.Errorf("wrong error: got %v, want %v", err, ErrNoDevices)
+	}
+}
+
+func TestMultiSessionInitError(t *testing.T) {
+	t.Parallel()
+
+	m0, m1, _ := newMultiTestMocks()
+	m1.Errors = map[string]error{"Init": api.Fail}
+	var sessions []*Session
+	for _, m := range []*orderMock{m0, m1} {
+		s, err := NewSession(WithImplementation(m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+	ms, err := NewMultiSession(sessions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ms.Run(ctx); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	wantCalls := []string{"Init", "Uninit", "ReleaseDevice", "Close"}
+	if n := len(m0.Calls); n < 4 || !reflect.DeepEqual(m0.Calls[n-4:], wantCalls) {
+		t.Errorf("wrong calls for m0: got %v", m0.Calls)
+	}
+	wantCalls = []string{"Init", "ReleaseDevice", "Close"}
+	if n := len(m1.Calls); n < 3 || !reflect.DeepEqual(m1.Calls[n-3:], wantCalls) {
+		t.Errorf("wrong calls for m1: got %v", m1.Calls)
+	}
+}
+
+func TestNewMultiSessionInvalid(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	withControl, err := NewSession(WithControlLoop(func(ctx context.Context, d *api.DeviceT, a api.API) error { return nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withAuto, err := NewSession(WithAutoTransferMode(time.Second, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withGain, err := NewSession(WithAutoGain(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withClamp, err := NewSession(WithAGCClamp(3, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs := [][]*Session{
+		nil,
+		{s},
+		{s, nil},
+		{s, withControl},
+		{withAuto, s},
+		{s, withGain},
+		{withClamp, s},
+	}
+	for i, spec := range specs {
+		if _, err := NewMultiSession(spec...); err == nil {
+			t.Errorf("unexpected success for spec %d", i)
+		}
+	}
+}
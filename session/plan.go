@@ -0,0 +1,150 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// MaxZeroIFSampleRate is the maximum ADC sample rate in zero-IF mode.
+const MaxZeroIFSampleRate = 10e6
+
+// MinZeroIFSampleRate is the minimum ADC sample rate in zero-IF mode.
+const MinZeroIFSampleRate = 2e6
+
+// maxDecimation is the largest decimation factor supported by the API.
+const maxDecimation = 32
+
+// RatePlan is the result of PlanForOutputRate. It describes the settings
+// needed to produce an effective sample rate.
+type RatePlan struct {
+	// LowIF is true if the plan uses low-IF mode and false if it uses
+	// zero-IF mode.
+	LowIF bool
+	// Strategy is the strategy used for low-IF mode. It is only
+	// meaningful if LowIF is true.
+	Strategy LowIFStrategy
+	// Fs is the ADC sample rate in Hz.
+	Fs float64
+	// Dec is the decimation factor.
+	Dec uint8
+	// Bw is the analog bandwidth.
+	Bw api.Bw_MHzT
+	// If is the IF frequency.
+	If api.If_kHzT
+	// Rate is the resulting effective sample rate in Hz.
+	Rate float64
+}
+
+// PlanForOutputRate finds the settings whose effective sample rate best
+// matches targetRateHz for the provided device. It is the inverse of
+// GetEffectiveSampleRate for the settings made by SetLowIF and SetZeroIF.
+//
+// If a low-IF decimation produces exactly the target rate, low-IF mode
+// is chosen to avoid the DC offset of zero-IF mode. Otherwise, zero-IF
+// mode is chosen with the largest decimation factor that keeps the ADC
+// sample rate in range, which produces the target rate exactly and
+// maximizes the effective number of bits. Targets below the minimum
+// achievable rate map to the minimum rate, so the requested bandwidth is
+// still covered. An RSPduo in dual-tuner, primary, or secondary mode only
+// supports low-IF mode, so the smallest low-IF rate that is at least the
+// target is chosen.
+//
+// It returns an error if the target rate is not positive or exceeds the
+// maximum effective sample rate of the device.
+func PlanForOutputRate(d *api.DeviceT, targetRateHz float64) (RatePlan, error) {
+	var res RatePlan
+	if math.IsNaN(targetRateHz) || targetRateHz <= 0 {
+		return res, fmt.Errorf("invalid target rate: got %v Hz, want > 0", targetRateHz)
+	}
+
+	zeroIF := !(d.HWVer == api.RSPduo_ID && (d.RspDuoMode != api.RspDuoMode_Single_Tuner || d.Tuner == api.Tuner_Both))
+
+	// Prefer an exact match in low-IF mode. Otherwise, when zero-IF is
+	// unavailable, take the smallest low-IF rate that covers the target.
+	dec := uint8(0)
+	for n := uint8(1); n <= maxDecimation; n *= 2 {
+		rate := LowIFSampleRate / float64(n)
+		if rate == targetRateHz || (!zeroIF && rate >= targetRateHz) {
+			dec = n
+		}
+	}
+	if dec != 0 {
+		fs, bw, ifType, err := GetBestLowIFSettings(d, LowIFMaxBits, dec)
+		if err != nil {
+			return res, err
+		}
+		return RatePlan{
+			LowIF:    true,
+			Strategy: LowIFMaxBits,
+			Fs:       fs,
+			Dec:      dec,
+			Bw:       bw,
+			If:       ifType,
+			Rate:     LowIFSampleRate / float64(dec),
+		}, nil
+	}
+	if !zeroIF {
+		return res, fmt.Errorf("invalid target rate for %v in %v mode: got %v Hz, want <= %v Hz", d.HWVer, d.RspDuoMode, targetRateHz, LowIFSampleRate)
+	}
+
+	if targetRateHz > MaxZeroIFSampleRate {
+		return res, fmt.Errorf("invalid target rate: got %v Hz, want <= %v Hz", targetRateHz, MaxZeroIFSampleRate)
+	}
+	fs := MinZeroIFSampleRate
+	dec = maxDecimation
+	if targetRateHz*maxDecimation > MinZeroIFSampleRate {
+		for dec > 1 && targetRateHz*float64(dec) > MaxZeroIFSampleRate {
+			dec /= 2
+		}
+		fs = targetRateHz * float64(dec)
+	}
+	bw, err := GetBestZeroIFBW(fs, dec)
+	if err != nil {
+		return res, err
+	}
+	return RatePlan{
+		Fs:   fs,
+		Dec:  dec,
+		Bw:   bw,
+		If:   api.IF_Zero,
+		Rate: fs / float64(dec),
+	}, nil
+}
+
+// SetOutputRate configures the provided channel using the settings
+// determined by PlanForOutputRate.
+func SetOutputRate(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, targetRateHz float64) error {
+	if c == nil {
+		return errors.New("cannot configure nil channel")
+	}
+	plan, err := PlanForOutputRate(d, targetRateHz)
+	if err != nil {
+		return err
+	}
+	return applyRatePlan(d, p, c, plan)
+}
+
+// applyRatePlan configures the provided channel with the settings of
+// the provided plan.
+func applyRatePlan(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, plan RatePlan) error {
+	if plan.LowIF {
+		return SetLowIF(d, p, c, plan.Strategy, plan.Dec)
+	}
+	return SetZeroIF(d, p, c, plan.Fs, plan.Dec)
+}
+
+// WithOutputRate creates a function to configure the selected device to
+// produce an effective sample rate that best matches targetRateHz. It
+// uses PlanForOutputRate to determine the settings.
+func WithOutputRate(targetRateHz float64) ChanConfigFn {
+	return func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+		return SetOutputRate(d, p, c, targetRateHz)
+	}
+}
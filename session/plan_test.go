@@ -0,0 +1,104 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"math"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestPlanForOutputRate(t *testing.T) {
+	t.Parallel()
+
+	rsp1a := &api.DeviceT{HWVer: api.RSP1A_ID}
+	dualDuo := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner, RspDuoSampleFreq: 6e6}
+
+	specs := []struct {
+		d      *api.DeviceT
+		target float64
+		lowIF  bool
+		fs     float64
+		dec    uint8
+		bw     api.Bw_MHzT
+	}{
+		// Exact low-IF rates.
+		{rsp1a, 2e6, true, 6e6, 1, api.BW_1_536},
+		{rsp1a, 500e3, true, 6e6, 4, api.BW_0_300},
+		{rsp1a, 62.5e3, true, 6e6, 32, api.BW_0_200},
+		// Zero-IF with the largest decimation.
+		{rsp1a, 48e3, false, 2e6, 32, api.BW_0_200},
+		{rsp1a, 96e3, false, 3.072e6, 32, api.BW_0_200},
+		{rsp1a, 200e3, false, 6.4e6, 32, api.BW_0_200},
+		{rsp1a, 250e3, true, 6e6, 8, api.BW_0_200},
+		{rsp1a, 1.2e6, false, 9.6e6, 8, api.BW_0_600},
+		{rsp1a, 3e6, false, 6e6, 2, api.BW_1_536},
+		{rsp1a, 10e6, false, 10e6, 1, api.BW_8_000},
+		// Low-IF only for the RSPduo in dual-tuner mode.
+		{dualDuo, 2e6, true, 6e6, 1, api.BW_1_536},
+		{dualDuo, 48e3, true, 6e6, 32, api.BW_0_200},
+		{dualDuo, 300e3, true, 6e6, 4, api.BW_0_300},
+	}
+
+	for _, spec := range specs {
+		plan, err := PlanForOutputRate(spec.d, spec.target)
+		if err != nil {
+			t.Errorf("unexpected error for %v Hz: %v", spec.target, err)
+			continue
+		}
+		if plan.LowIF != spec.lowIF || plan.Fs != spec.fs || plan.Dec != spec.dec || plan.Bw != spec.bw {
+			t.Errorf(
+				"wrong plan for %v Hz: got %+v, want LowIF=%v Fs=%v Dec=%v Bw=%v",
+				spec.target, plan, spec.lowIF, spec.fs, spec.dec, spec.bw,
+			)
+		}
+		if plan.Rate < spec.target && spec.target >= 62.5e3 {
+			t.Errorf("wrong rate for %v Hz: got %v, want >= %v", spec.target, plan.Rate, spec.target)
+		}
+
+		if spec.d.HWVer == api.RSPduo_ID {
+			continue
+		}
+		// The effective rate of the applied settings must match the plan.
+		p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}}
+		c := &api.RxChannelParamsT{}
+		if err := WithOutputRate(spec.target)(spec.d, p, c); err != nil {
+			t.Errorf("unexpected error applying %v Hz: %v", spec.target, err)
+			continue
+		}
+		rate, err := GetEffectiveSampleRate(spec.d, p, c)
+		if err != nil {
+			t.Errorf("unexpected error for %v Hz: %v", spec.target, err)
+			continue
+		}
+		if math.Abs(rate-plan.Rate) > 1e-6 {
+			t.Errorf("wrong effective rate for %v Hz: got %v, want %v", spec.target, rate, plan.Rate)
+		}
+	}
+}
+
+func TestPlanForOutputRateInvalid(t *testing.T) {
+	t.Parallel()
+
+	rsp1a := &api.DeviceT{HWVer: api.RSP1A_ID}
+	dualDuo := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner, RspDuoSampleFreq: 6e6}
+
+	specs := []struct {
+		d      *api.DeviceT
+		target float64
+	}{
+		{rsp1a, 0},
+		{rsp1a, -48e3},
+		{rsp1a, math.NaN()},
+		{rsp1a, 10.5e6},
+		{dualDuo, 3e6},
+	}
+	for _, spec := range specs {
+		if plan, err := PlanForOutputRate(spec.d, spec.target); err == nil {
+			t.Errorf("unexpected success for %v Hz: got %+v", spec.target, plan)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// RateEstimate is a snapshot of the sample rate measured by a
+// RateEstimator.
+type RateEstimate struct {
+	// Elapsed is the wall time between the first and the most recent
+	// callback in the window.
+	Elapsed time.Duration
+	// Samples is the number of samples produced by the device over
+	// Elapsed according to the FirstSampleNum sequence. Dropped samples
+	// are included because they were still produced by the device.
+	Samples uint64
+	// Rate is the measured sample rate in samples per second.
+	Rate float64
+	// ExpectedRate is the configured effective sample rate.
+	ExpectedRate float64
+	// PPM is the deviation of Rate from ExpectedRate in parts per
+	// million. A positive value means the device is running fast.
+	PPM float64
+}
+
+// RateEstimator estimates the true sample rate of a stream by comparing
+// the advance of the FirstSampleNum sequence of the stream callbacks to
+// the wall clock. A deviation from the configured rate indicates that
+// the device clock, or the ppm correction applied to it, is off. The
+// accuracy of the estimate improves with the length of the window,
+// because the jitter in the arrival time of callbacks is amortized over
+// more samples. A window of at least tens of seconds is recommended to
+// resolve a few ppm.
+//
+// A RateEstimator is safe for concurrent use by a stream callback and
+// any number of readers.
+type RateEstimator struct {
+	mu       sync.Mutex
+	expected float64
+	now      func() time.Time
+	started  bool
+	start    time.Time
+	last     time.Time
+	lastNum  uint32
+	samples  uint64
+}
+
+// NewRateEstimator creates a new RateEstimator. The expectedRate
+// argument is the effective sample rate in samples per second. It is
+// typically the value returned by GetEffectiveSampleRate.
+func NewRateEstimator(expectedRate float64) *RateEstimator {
+	return &RateEstimator{
+		expected: expectedRate,
+		now:      time.Now,
+	}
+}
+
+// StreamCallback implements api.StreamCallbackT. A reset indicated by
+// the API restarts the window, because the sample number sequence is not
+// continuous across a reset.
+func (r *RateEstimator) StreamCallback(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+	if params == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.now()
+	if !r.started || reset {
+		r.started = true
+		r.start = t
+		r.samples = 0
+	} else {
+		// Unsigned subtraction handles the 32-bit wrap of the
+		// sample number.
+		r.samples += uint64(params.FirstSampleNum - r.lastNum)
+	}
+	r.last = t
+	r.lastNum = params.FirstSampleNum
+}
+
+// Wrap returns a api.StreamCallbackT that calls StreamCallback and
+// then next, if next is not nil.
+func (r *RateEstimator) Wrap(next api.StreamCallbackT) api.StreamCallbackT {
+	return func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		r.StreamCallback(xi, xq, params, reset)
+		if next != nil {
+			next(xi, xq, params, reset)
+		}
+	}
+}
+
+// Estimate returns the sample rate measured since the first callback
+// after creation, the last reset from the API, or the last call to
+// Reset. The Rate and PPM are zero until at least two callbacks have
+// been received.
+func (r *RateEstimator) Estimate() RateEstimate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := RateEstimate{
+		Samples:      r.samples,
+		ExpectedRate: r.expected,
+	}
+	if !r.started {
+		return e
+	}
+	e.Elapsed = r.last.Sub(r.start)
+	if secs := e.Elapsed.Seconds(); secs > 0 {
+		e.Rate = float64(r.samples) / secs
+		if r.expected > 0 {
+			e.PPM = (e.Rate/r.expected - 1) * 1e6
+		}
+	}
+	return e
+}
+
+// Check returns a non-nil error describing the deviation if the
+// magnitude of the current estimated ppm error exceeds maxPPM. It
+// returns nil if there is not yet enough data for an estimate. It is
+// intended to be called periodically (e.g. from a control loop) to
+// surface a warning about a miscalibrated ppm correction.
+func (r *RateEstimator) Check(maxPPM float64) error {
+	e := r.Estimate()
+	if e.Rate == 0 || math.Abs(e.PPM) <= maxPPM {
+		return nil
+	}
+	return fmt.Errorf(
+		"sample rate deviation exceeds %.1f ppm: got %.1f Hz (%+.1f ppm over %v), want %.1f Hz; check ppm correction",
+		maxPPM, e.Rate, e.PPM, e.Elapsed, e.ExpectedRate,
+	)
+}
+
+// Reset clears the window and starts a new one with the next callback.
+func (r *RateEstimator) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = false
+	r.samples = 0
+}
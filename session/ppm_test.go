@@ -0,0 +1,114 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestRateEstimator(t *testing.T) {
+	t.Parallel()
+
+	const (
+		nominal    = 2e6
+		numSamples = 1008
+		duration   = 60 * time.Second
+		jitter     = time.Millisecond
+	)
+
+	for _, ppm := range []float64{0, 12.5, -40, 250} {
+		actual := nominal * (1 + ppm*1e-6)
+		var now time.Time
+		est := NewRateEstimator(nominal)
+		est.now = func() time.Time { return now }
+
+		base := time.Unix(1000, 0)
+		// Start near the wrap point of the 32-bit sample number.
+		sampleNum := uint32(math.MaxUint32 - 10*numSamples)
+		var produced uint64
+		for i := 0; ; i++ {
+			offset := time.Duration(float64(produced) / actual * float64(time.Second))
+			if offset > duration {
+				break
+			}
+			// Callbacks arrive late by a random amount.
+			now = base.Add(offset + time.Duration(rand.Int63n(int64(jitter))))
+			if i%100 == 99 {
+				// Dropped callbacks still advance the sample number.
+				sampleNum += numSamples
+				produced += numSamples
+				continue
+			}
+			est.StreamCallback(nil, nil, &api.StreamCbParamsT{FirstSampleNum: sampleNum, NumSamples: numSamples}, i == 0)
+			sampleNum += numSamples
+			produced += numSamples
+		}
+
+		e := est.Estimate()
+		// 1 ms of jitter over 60 s bounds the error to about 17 ppm.
+		if math.Abs(e.PPM-ppm) > 20 {
+			t.Errorf("wrong ppm estimate: got %.2f, want %.2f", e.PPM, ppm)
+		}
+		if math.Abs(e.Rate-actual) > actual*20e-6 {
+			t.Errorf("wrong rate estimate: got %.1f, want %.1f", e.Rate, actual)
+		}
+		if e.ExpectedRate != nominal {
+			t.Errorf("wrong expected rate: got %v, want %v", e.ExpectedRate, nominal)
+		}
+
+		err := est.Check(100)
+		switch {
+		case math.Abs(ppm) > 100 && err == nil:
+			t.Errorf("missing warning for %v ppm", ppm)
+		case math.Abs(ppm) < 100 && err != nil:
+			t.Errorf("unexpected warning for %v ppm: %v", ppm, err)
+		}
+	}
+}
+
+func TestRateEstimatorExact(t *testing.T) {
+	t.Parallel()
+
+	const (
+		nominal    = 1e6
+		ppm        = 50
+		numSamples = 1000
+	)
+	actual := nominal * (1 + ppm*1e-6)
+
+	var now time.Time
+	est := NewRateEstimator(nominal)
+	est.now = func() time.Time { return now }
+	if e := est.Estimate(); e.Rate != 0 || est.Check(0) != nil {
+		t.Fatalf("wrong initial estimate: got %+v", e)
+	}
+
+	base := time.Unix(1000, 0)
+	var sampleNum uint32
+	for i := 0; i < 1000; i++ {
+		now = base.Add(time.Duration(float64(sampleNum) / actual * float64(time.Second)))
+		est.StreamCallback(nil, nil, &api.StreamCbParamsT{FirstSampleNum: sampleNum, NumSamples: numSamples}, i == 0)
+		sampleNum += numSamples
+	}
+	if e := est.Estimate(); math.Abs(e.PPM-ppm) > 0.01 {
+		t.Errorf("wrong ppm estimate: got %.4f, want %v", e.PPM, ppm)
+	}
+
+	// A reset from the API restarts the window.
+	est.StreamCallback(nil, nil, &api.StreamCbParamsT{FirstSampleNum: 0, NumSamples: numSamples}, true)
+	if e := est.Estimate(); e.Samples != 0 || e.Elapsed != 0 {
+		t.Errorf("wrong estimate after API reset: got %+v", e)
+	}
+
+	est.Reset()
+	if e := est.Estimate(); e.Samples != 0 || e.Elapsed != 0 || e.Rate != 0 {
+		t.Errorf("wrong estimate after reset: got %+v", e)
+	}
+}
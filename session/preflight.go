@@ -0,0 +1,75 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// Warning is a human-readable description of a channel configuration
+// that is accepted by the API, but is likely to degrade the capture.
+type Warning string
+
+// Preflight inspects a channel configuration and returns a Warning for
+// each problem that is likely to cause aliasing. It is intended to be
+// called after all configuration has been applied and before streaming
+// (see WithPreflightLog). It returns nil if no problems were found.
+//
+// The checks are:
+//
+// 1. The IF bandwidth is undefined.
+//
+// 2. The IF bandwidth is wider than the effective sample rate, so
+// signals that pass the analog filter alias into the output. This
+// typically happens when WithBandwidth overrides the bandwidth selected
+// by WithZeroIF or WithLowIF, or when the decimation is changed without
+// also changing the bandwidth. The narrowest bandwidth, BW_0_200, is
+// not reported, because there is no better choice at low sample rates.
+//
+// A channel of an RSPduo in slave mode cannot be checked, because the
+// sample rate is controlled by the master, so Preflight returns nil.
+func Preflight(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) []Warning {
+	if c == nil {
+		return []Warning{"cannot inspect nil channel"}
+	}
+	if p.DevParams == nil {
+		return nil
+	}
+	bw := c.TunerParams.BwType
+	if bw <= api.BW_Undefined {
+		return []Warning{Warning(fmt.Sprintf("IF bandwidth is undefined: got %v", bw))}
+	}
+	rate, err := GetEffectiveSampleRate(d, p, c)
+	if err != nil {
+		return []Warning{Warning(fmt.Sprintf("cannot determine effective sample rate: %v", err))}
+	}
+
+	var res []Warning
+	if bw != api.BW_0_200 && bw.Hz() > rate {
+		res = append(res, Warning(fmt.Sprintf(
+			"IF bandwidth %s exceeds effective sample rate %.0f Hz; signals outside +/-%.0f Hz will alias",
+			bw.Describe(), rate, rate/2,
+		)))
+	}
+	return res
+}
+
+// WithPreflightLog creates a function that calls Preflight and prints each
+// Warning to the specified Logger. It never returns an error, so that
+// a warning does not prevent streaming. It should be the last function
+// in the channel configuration so that it inspects the final values.
+func WithPreflightLog(prefix string, lg Logger) ChanConfigFn {
+	if prefix != "" {
+		prefix += " "
+	}
+	return func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+		for _, w := range Preflight(d, p, c) {
+			lg.Printf("%sWARNING: %s\n", prefix, w)
+		}
+		return nil
+	}
+}
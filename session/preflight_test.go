@@ -0,0 +1,101 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+type bufLogger struct {
+	strings.Builder
+}
+
+func (b *bufLogger) Printf(format string, v ...interface{}) {
+	fmt.Fprintf(b, format, v...)
+}
+
+func TestPreflight(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		fns  []ChanConfigFn
+		want string
+	}{
+		{[]ChanConfigFn{WithZeroIF(8e6, 1)}, ""},
+		{[]ChanConfigFn{WithZeroIF(2e6, 32)}, ""},
+		{[]ChanConfigFn{WithLowIF(LowIFMaxBits, 4)}, ""},
+		{[]ChanConfigFn{WithZeroIF(8e6, 8), WithBandwidth(api.BW_0_600)}, ""},
+		// Deliberate mismatch between the bandwidth and the rate.
+		{[]ChanConfigFn{WithZeroIF(8e6, 8), WithBandwidth(api.BW_5_000)}, "IF bandwidth BW_5_000 (5000000 Hz) exceeds effective sample rate 1000000 Hz"},
+		{[]ChanConfigFn{WithLowIF(LowIFMaxBits, 4), WithBandwidth(api.BW_1_536)}, "exceeds effective sample rate 500000 Hz"},
+		{[]ChanConfigFn{WithZeroIF(8e6, 1), func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+			c.TunerParams.BwType = api.BW_Undefined
+			return nil
+		}}, "IF bandwidth is undefined"},
+	}
+
+	for i, spec := range specs {
+		d := &api.DeviceT{HWVer: api.RSP1A_ID}
+		p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+		if err := WithSingleChannelConfig(spec.fns...)(d, p); err != nil {
+			t.Fatalf("unexpected error in spec %d: %v", i, err)
+		}
+		got := Preflight(d, p, p.RxChannelA)
+		switch {
+		case spec.want == "" && len(got) != 0:
+			t.Errorf("unexpected warnings in spec %d: %v", i, got)
+		case spec.want != "" && len(got) != 1:
+			t.Errorf("wrong number of warnings in spec %d: got %v, want 1", i, got)
+		case spec.want != "" && !strings.Contains(string(got[0]), spec.want):
+			t.Errorf("wrong warning in spec %d: got %q, want %q", i, got[0], spec.want)
+		}
+	}
+
+	// Nil channel and RSPduo slave.
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	if got := Preflight(d, &api.DeviceParamsT{DevParams: &api.DevParamsT{}}, nil); len(got) != 1 {
+		t.Errorf("wrong warnings for nil channel: got %v, want 1", got)
+	}
+	d = &api.DeviceT{HWVer: api.RSPduo_ID, RspDuoMode: api.RspDuoMode_Secondary}
+	if got := Preflight(d, &api.DeviceParamsT{RxChannelB: &api.RxChannelParamsT{}}, &api.RxChannelParamsT{}); got != nil {
+		t.Errorf("unexpected warnings for slave: got %v", got)
+	}
+}
+
+func TestWithPreflightLog(t *testing.T) {
+	t.Parallel()
+
+	var lg bufLogger
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+	err := WithSingleChannelConfig(
+		WithZeroIF(8e6, 8),
+		WithBandwidth(api.BW_5_000),
+		WithPreflightLog("Tuner_A", &lg),
+	)(d, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := lg.String(); !strings.HasPrefix(got, "Tuner_A WARNING: IF bandwidth BW_5_000") {
+		t.Errorf("wrong log output: got %q", got)
+	}
+
+	// The same function may be applied to both channels of an RSPduo
+	// without padding the prefix again.
+	lg = bufLogger{}
+	fn := WithPreflightLog("Tuner", &lg)
+	for i := 0; i < 2; i++ {
+		if err := fn(d, p, p.RxChannelA); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := lg.String(); strings.Contains(got, "Tuner  ") {
+		t.Errorf("wrong log output: got %q", got)
+	}
+}
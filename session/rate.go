@@ -6,6 +6,7 @@ package session
 
 import (
 	"errors"
+	"math"
 
 	"github.com/msiner/sdrplay-go/api"
 )
@@ -54,3 +55,19 @@ func GetEffectiveSampleRate(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChann
 	}
 	return fsHz / float64(dec), nil
 }
+
+// ExpectedByteRate returns the expected data rate in bytes per second of
+// the specified channel when each complex sample is output as numChannels
+// scalars of bytesPerSample bytes each. For example, interleaved 16-bit I
+// and Q components use a bytesPerSample of 2 and a numChannels of 2. It is
+// a pure calculation from the effective sample rate (see
+// GetEffectiveSampleRate) that is useful to size output buffers and
+// network batching to the actual data rate. Fractional rates are rounded
+// up.
+func ExpectedByteRate(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT, bytesPerSample, numChannels uint) (uint64, error) {
+	rate, err := GetEffectiveSampleRate(d, p, c)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(math.Ceil(rate * float64(bytesPerSample) * float64(numChannels))), nil
+}
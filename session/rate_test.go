@@ -0,0 +1,79 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestExpectedByteRate(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		name           string
+		fs             float64
+		dec            uint8
+		ifType         api.If_kHzT
+		bw             api.Bw_MHzT
+		bytesPerSample uint
+		numChannels    uint
+		want           uint64
+	}{
+		// 8 MHz of interleaved int16 I and Q.
+		{"int16 8M", 8e6, 1, api.IF_Zero, api.BW_8_000, 2, 2, 32000000},
+		// 8 MHz of interleaved float32 I and Q.
+		{"float32 8M", 8e6, 1, api.IF_Zero, api.BW_8_000, 4, 2, 64000000},
+		// 2 MHz decimated by 32 is 62.5 kHz.
+		{"int16 2M/32", 2e6, 32, api.IF_Zero, api.BW_0_200, 2, 2, 250000},
+		// I, Q, and magnitude channels.
+		{"withmag 6M/4", 6e6, 4, api.IF_Zero, api.BW_0_600, 2, 3, 9000000},
+		// 8-bit I and Q.
+		{"int8 10M", 10e6, 1, api.IF_Zero, api.BW_8_000, 1, 2, 20000000},
+		// Low-IF down-conversion to 2 MHz, then decimation by 4.
+		{"lowif 6M/4", 6e6, 4, api.IF_1_620, api.BW_1_536, 2, 2, 2000000},
+		// 2.1 MHz decimated by 32 is 65.625 kHz.
+		{"int16 2.1M/32", 2.1e6, 32, api.IF_Zero, api.BW_0_200, 2, 2, 262500},
+		// A single 8-bit channel.
+		{"int8 1 channel 3M/16", 3e6, 16, api.IF_Zero, api.BW_0_300, 1, 1, 187500},
+	}
+
+	for _, spec := range specs {
+		d := &api.DeviceT{HWVer: api.RSP1A_ID}
+		p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}}
+		p.DevParams.FsFreq.FsHz = spec.fs
+		c := &api.RxChannelParamsT{}
+		c.CtrlParams.Decimation.DecimationFactor = spec.dec
+		c.TunerParams.IfType = spec.ifType
+		c.TunerParams.BwType = spec.bw
+		got, err := ExpectedByteRate(d, p, c, spec.bytesPerSample, spec.numChannels)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", spec.name, err)
+			continue
+		}
+		if got != spec.want {
+			t.Errorf("%s: wrong byte rate: got %d, want %d", spec.name, got, spec.want)
+		}
+	}
+
+	// Fractional rates are rounded up.
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	p := &api.DeviceParamsT{DevParams: &api.DevParamsT{}}
+	p.DevParams.FsFreq.FsHz = 2000001
+	c := &api.RxChannelParamsT{}
+	c.CtrlParams.Decimation.DecimationFactor = 32
+	got, err := ExpectedByteRate(d, p, c, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 62501 {
+		t.Errorf("wrong rounded byte rate: got %d, want 62501", got)
+	}
+
+	if _, err := ExpectedByteRate(d, p, nil, 2, 2); err == nil {
+		t.Error("unexpected success with nil channel")
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// runtimeChannels returns the channel params in p that correspond to
+// the specified tuner. On devices other than the RSPduo, it always
+// returns RxChannelA. Like WithSingleChannelConfig, it also returns
+// RxChannelA for tuner B of an RSPduo unless both tuners are selected,
+// because a single selected tuner is always configured through
+// RxChannelA. The tuner is still passed to Update as is.
+func runtimeChannels(d *api.DeviceT, p *api.DeviceParamsT, tuner api.TunerSelectT) []*api.RxChannelParamsT {
+	if d.HWVer != api.RSPduo_ID {
+		return []*api.RxChannelParamsT{p.RxChannelA}
+	}
+	switch tuner {
+	case api.Tuner_A:
+		return []*api.RxChannelParamsT{p.RxChannelA}
+	case api.Tuner_B:
+		if d.Tuner != api.Tuner_Both {
+			return []*api.RxChannelParamsT{p.RxChannelA}
+		}
+		return []*api.RxChannelParamsT{p.RxChannelB}
+	case api.Tuner_Both:
+		return []*api.RxChannelParamsT{p.RxChannelA, p.RxChannelB}
+	default:
+		return nil
+	}
+}
+
+// checkChannels returns an error matching ErrMissingChannel if p does
+// not include the channel params that runtimeChannels returns for the
+// specified tuner.
+func checkChannels(d *api.DeviceT, p *api.DeviceParamsT, tuner api.TunerSelectT) error {
+	single := d.Tuner != api.Tuner_Both
+	needA := d.HWVer != api.RSPduo_ID || tuner == api.Tuner_A || tuner == api.Tuner_Both || (tuner == api.Tuner_B && single)
+	needB := d.HWVer == api.RSPduo_ID && (tuner == api.Tuner_Both || (tuner == api.Tuner_B && !single))
+	return requireChannels(d, p, tuner, needA, needB)
+}
+
+// checkConfigChannels is like checkChannels, but for the configuration
+// of the selected device. It uses the same channel mapping as
+// WithSingleChannelConfig, which configures a single selected tuner,
+// even tuner B of an RSPduo, through RxChannelA.
+func checkConfigChannels(d *api.DeviceT, p *api.DeviceParamsT) error {
+	tuner := runtimeTuner(d)
+	needB := d.HWVer == api.RSPduo_ID && tuner == api.Tuner_Both
+	return requireChannels(d, p, tuner, true, needB)
+}
+
+// requireChannels returns an error matching ErrMissingChannel if a
+// needed channel is missing from p.
+func requireChannels(d *api.DeviceT, p *api.DeviceParamsT, tuner api.TunerSelectT, needA, needB bool) error {
+	var missing []string
+	if needA && p.RxChannelA == nil {
+		missing = append(missing, "RxChannelA")
+	}
+	if needB && p.RxChannelB == nil {
+		missing = append(missing, "RxChannelB")
+	}
+	if len(missing) != 0 {
+		msg := fmt.Sprintf("%v: %s for %v %v", ErrMissingChannel, strings.Join(missing, ", "), d.HWVer, tuner)
+		return wrapError(msg, ErrMissingChannel)
+	}
+	return nil
+}
+
+// runtimeTuner returns the tuner selection to use for runtime updates
+// of the selected device. Devices other than the RSPduo only have a
+// single tuner, which the API refers to as tuner A.
+func runtimeTuner(d *api.DeviceT) api.TunerSelectT {
+	if d.HWVer != api.RSPduo_ID {
+		return api.Tuner_A
+	}
+	return d.Tuner
+}
+
+// updateRuntime loads the device params, applies fn to each channel
+// that corresponds to the specified tuner, stores the params, and
+// notifies the API of the change with the provided update reasons.
+// It must only be used after the device is initialized.
+func updateRuntime(d *api.DeviceT, a api.API, tuner api.TunerSelectT, reason api.ReasonForUpdateT, ext api.ReasonForUpdateExtension1T, fn ChanConfigFn) error {
+	p, err := a.LoadDeviceParams(d.Dev)
+	if err != nil {
+		return fmt.Errorf("failed to load device params: %v", a.GetLastError(d))
+	}
+	chans := runtimeChannels(d, p, tuner)
+	if len(chans) == 0 {
+		return fmt.Errorf("invalid tuner selection: got %v", tuner)
+	}
+	if err := checkChannels(d, p, tuner); err != nil {
+		return err
+	}
+	for _, c := range chans {
+		if err := fn(d, p, c); err != nil {
+			return err
+		}
+	}
+	if err := a.StoreDeviceParams(d.Dev, p); err != nil {
+		return fmt.Errorf("failed to store device params: %v", a.GetLastError(d))
+	}
+	if err := a.Update(d.Dev, tuner, reason, ext); err != nil {
+		return fmt.Errorf("update failed: %v", a.GetLastError(d))
+	}
+	return nil
+}
+
+// SetAGCSetPointRuntime changes the AGC set point of a running device.
+// It loads the current params, updates the set point of the channel(s)
+// selected by tuner, stores the params, and issues an Update with
+// Update_Ctrl_Agc. The other AGC settings are left unchanged. Like
+// SetAGC, it returns an error if set is greater than 0 dBFS.
+func SetAGCSetPointRuntime(d *api.DeviceT, a api.API, tuner api.TunerSelectT, set int32) error {
+	if set > 0 {
+		return fmt.Errorf("invalid AGC set point: got %d dBFS, want <= 0", set)
+	}
+	return updateRuntime(
+		d, a, tuner, api.Update_Ctrl_Agc, api.Update_Ext1_None,
+		func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+			c.CtrlParams.Agc.SetPoint_dBfs = set
+			return nil
+		},
+	)
+}
+
+// SetDCOffsetTuningRuntime changes the DC offset tracking of a running
+// device. It uses SetDCOffsetTuning on the channel(s) selected by tuner
+// and issues an Update with Update_Tuner_DcOffset.
+func SetDCOffsetTuningRuntime(d *api.DeviceT, a api.API, tuner api.TunerSelectT, trackingPeriod time.Duration, speedUp bool) error {
+	return updateRuntime(
+		d, a, tuner, api.Update_Tuner_DcOffset, api.Update_Ext1_None,
+		WithDCOffsetTuning(trackingPeriod, speedUp),
+	)
+}
+
+// Retune changes the RF frequency of a running device. It loads the
+// current params, updates the frequency of the channel(s) selected by
+// tuner using SetTuneFreq, stores the params, and issues an Update with
+// Update_Tuner_Frf. The device is not uninitialized, so streaming
+// continues and the change takes effect without the delay of a full
+// Uninit/Init cycle.
+func Retune(d *api.DeviceT, a api.API, tuner api.TunerSelectT, freq float64) error {
+	return updateRuntime(
+		d, a, tuner, api.Update_Tuner_Frf, api.Update_Ext1_None,
+		func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+			return SetTuneFreq(d, p, c, freq)
+		},
+	)
+}
+
+// SetDecimationRuntime changes the decimation factor of a running device.
+// It loads the current params, updates the decimation of the channel(s)
+// selected by tuner, stores the params, and issues an Update with
+// Update_Ctrl_Decimation. The analog bandwidth and IF settings are left
+// unchanged. It returns an error if dec is not 1, 2, 4, 8, 16, or 32.
+func SetDecimationRuntime(d *api.DeviceT, a api.API, tuner api.TunerSelectT, dec uint8) error {
+	switch dec {
+	case 1, 2, 4, 8, 16, 32:
+		// good
+	default:
+		return fmt.Errorf("invalid decimation: got %d, want 1|2|4|8|16|32", dec)
+	}
+	return updateRuntime(
+		d, a, tuner, api.Update_Ctrl_Decimation, api.Update_Ext1_None,
+		func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+			var decEnable uint8
+			if dec > 1 {
+				decEnable = 1
+			}
+			c.CtrlParams.Decimation.Enable = decEnable
+			c.CtrlParams.Decimation.DecimationFactor = dec
+			return nil
+		},
+	)
+}
+
+// SetLNAStateRuntime changes the LNA state of a running device. It
+// loads the current params, updates the LNA state of the channel(s)
+// selected by tuner using SetLNAState, stores the params, and issues an
+// Update with Update_Tuner_Gr.
+func SetLNAStateRuntime(d *api.DeviceT, a api.API, tuner api.TunerSelectT, state uint8) error {
+	return updateRuntime(
+		d, a, tuner, api.Update_Tuner_Gr, api.Update_Ext1_None,
+		WithLNAState(state),
+	)
+}
+
+// SwapDualTunerSampleRate swaps the ADC sample rate of a running RSPduo
+// in dual-tuner mode between 6 MHz and 8 MHz using
+// SwapRspDuoDualTunerModeSampleRate. It updates d.RspDuoSampleFreq with
+// the new rate and then reapplies the low-IF settings of both channels
+// with SetLowIF, keeping their decimation, so that the IF frequency and
+// analog bandwidth match the new rate. The change is made with an Update
+// with Update_Tuner_IfType and Update_Tuner_BwType. It returns the new
+// sample rate.
+//
+// The output sample rate does not change, because both rates are
+// down-converted to LowIFSampleRate before decimation, but the stream is
+// interrupted and the two tuners must be resynchronized (e.g. with
+// duo.Synchro.Reset). At 8 MHz, only the 1.536 MHz analog bandwidth is
+// available and the ADC resolution is reduced to 12 bits.
+func SwapDualTunerSampleRate(d *api.DeviceT, a api.API) (float64, error) {
+	if d.HWVer != api.RSPduo_ID || d.RspDuoMode != api.RspDuoMode_Dual_Tuner {
+		return 0, fmt.Errorf("invalid device: got %v in %v mode, want RSPduo in dual-tuner mode", d.HWVer, d.RspDuoMode)
+	}
+	switch d.RspDuoSampleFreq {
+	case 6e6, 8e6:
+		// good
+	default:
+		return 0, fmt.Errorf("invalid sample rate: got %v, want 6e6|8e6", d.RspDuoSampleFreq)
+	}
+	rate := d.RspDuoSampleFreq
+	if err := a.SwapRspDuoDualTunerModeSampleRate(d.Dev, &rate); err != nil {
+		return 0, fmt.Errorf("failed to swap sample rate: %v", a.GetLastError(d))
+	}
+	d.RspDuoSampleFreq = rate
+	err := updateRuntime(
+		d, a, api.Tuner_Both, api.Update_Tuner_IfType|api.Update_Tuner_BwType, api.Update_Ext1_None,
+		func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+			dec := c.CtrlParams.Decimation.DecimationFactor
+			if c.CtrlParams.Decimation.Enable == 0 || dec == 0 {
+				dec = 1
+			}
+			return SetLowIF(d, p, c, LowIFMaxBits, dec)
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return rate, nil
+}
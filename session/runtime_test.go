@@ -0,0 +1,276 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestSetAGCSetPointRuntime(t *testing.T) {
+	t.Parallel()
+
+	specs := []struct {
+		hwVer  api.HWVersion
+		tuner  api.TunerSelectT
+		wantA  int32
+		wantB  int32
+		setRef int32
+	}{
+		{api.RSP1A_ID, api.Tuner_A, -20, -30, -20},
+		{api.RSPdx_ID, api.Tuner_Neither, -15, -30, -15},
+		{api.RSPduo_ID, api.Tuner_A, -25, -30, -25},
+		// A single selected tuner B is configured through RxChannelA.
+		{api.RSPduo_ID, api.Tuner_B, -10, -30, -10},
+		{api.RSPduo_ID, api.Tuner_Both, 0, 0, 0},
+	}
+
+	for _, spec := range specs {
+		d := &api.DeviceT{HWVer: spec.hwVer, Tuner: spec.tuner}
+		m := apitest.NewMock(d)
+		p, _ := m.LoadDeviceParams(d.Dev)
+		p.RxChannelA.CtrlParams.Agc.SetPoint_dBfs = -30
+		p.RxChannelA.CtrlParams.Agc.Attack_ms = 500
+		p.RxChannelB.CtrlParams.Agc.SetPoint_dBfs = -30
+		if err := m.StoreDeviceParams(d.Dev, p); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := SetAGCSetPointRuntime(d, m, spec.tuner, spec.setRef); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := m.Params.RxChannelA.CtrlParams.Agc.SetPoint_dBfs; got != spec.wantA {
+			t.Errorf("wrong channel A set point for %v %v: got %d, want %d", spec.hwVer, spec.tuner, got, spec.wantA)
+		}
+		if got := m.Params.RxChannelB.CtrlParams.Agc.SetPoint_dBfs; got != spec.wantB {
+			t.Errorf("wrong channel B set point for %v %v: got %d, want %d", spec.hwVer, spec.tuner, got, spec.wantB)
+		}
+		if got := m.Params.RxChannelA.CtrlParams.Agc.Attack_ms; got != 500 {
+			t.Errorf("wrong attack: got %d, want 500", got)
+		}
+		if len(m.Updates) != 1 {
+			t.Fatalf("wrong number of updates: got %d, want 1", len(m.Updates))
+		}
+		want := apitest.UpdateCall{Dev: d.Dev, Tuner: spec.tuner, Reason: api.Update_Ctrl_Agc, ReasonExt1: api.Update_Ext1_None}
+		if m.Updates[0] != want {
+			t.Errorf("wrong update: got %+v, want %+v", m.Updates[0], want)
+		}
+	}
+}
+
+func TestSetAGCSetPointRuntimeErrors(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	m := apitest.NewMock(d)
+	if err := SetAGCSetPointRuntime(d, m, api.Tuner_A, 1); err == nil {
+		t.Error("unexpected success with positive set point")
+	}
+	if len(m.Calls) != 0 {
+		t.Errorf("unexpected API calls: %v", m.Calls)
+	}
+
+	duo := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_A}
+	m = apitest.NewMock(duo)
+	if err := SetAGCSetPointRuntime(duo, m, api.Tuner_Neither, -10); err == nil {
+		t.Error("unexpected success with no tuner")
+	}
+
+	m = apitest.NewMock(d)
+	m.Errors = map[string]error{"Update": errors.New("boom")}
+	if err := SetAGCSetPointRuntime(d, m, api.Tuner_A, -10); err == nil {
+		t.Error("unexpected success with failed update")
+	}
+}
+
+func TestSetDCOffsetTuningRuntime(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_B}
+	m := apitest.NewMock(d)
+	if err := SetDCOffsetTuningRuntime(d, m, api.Tuner_B, 90*time.Microsecond, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := api.DcOffsetTunerT{SpeedUp: 1, TrackTime: 10}
+	if got := m.Params.RxChannelA.TunerParams.DcOffsetTuner; got != want {
+		t.Errorf("wrong channel A params: got %+v, want %+v", got, want)
+	}
+	if got := m.Params.RxChannelB.TunerParams.DcOffsetTuner; got != (api.DcOffsetTunerT{}) {
+		t.Errorf("wrong channel B params: got %+v, want zero", got)
+	}
+	wantUpdate := apitest.UpdateCall{Dev: d.Dev, Tuner: api.Tuner_B, Reason: api.Update_Tuner_DcOffset, ReasonExt1: api.Update_Ext1_None}
+	if len(m.Updates) != 1 || m.Updates[0] != wantUpdate {
+		t.Errorf("wrong updates: got %+v, want %+v", m.Updates, wantUpdate)
+	}
+
+	m = apitest.NewMock(d)
+	if err := SetDCOffsetTuningRuntime(d, m, api.Tuner_B, time.Second, false); err == nil {
+		t.Error("unexpected success for invalid period")
+	}
+	if len(m.Updates) != 0 {
+		t.Errorf("unexpected updates: %+v", m.Updates)
+	}
+}
+
+func TestSetDecimationRuntime(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	m := apitest.NewMock(d)
+	for _, dec := range []uint8{4, 1} {
+		if err := SetDecimationRuntime(d, m, api.Tuner_A, dec); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := api.DecimationT{DecimationFactor: dec}
+		if dec > 1 {
+			want.Enable = 1
+		}
+		if got := m.Params.RxChannelA.CtrlParams.Decimation; got != want {
+			t.Errorf("wrong decimation: got %+v, want %+v", got, want)
+		}
+	}
+	wantUpdate := apitest.UpdateCall{Dev: d.Dev, Tuner: api.Tuner_A, Reason: api.Update_Ctrl_Decimation, ReasonExt1: api.Update_Ext1_None}
+	if len(m.Updates) != 2 || m.Updates[0] != wantUpdate {
+		t.Errorf("wrong updates: got %+v, want 2 of %+v", m.Updates, wantUpdate)
+	}
+
+	for _, dec := range []uint8{0, 3, 64} {
+		if err := SetDecimationRuntime(d, m, api.Tuner_A, dec); err == nil {
+			t.Errorf("unexpected success for decimation %d", dec)
+		}
+	}
+}
+
+func TestSetLNAStateRuntime(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	m := apitest.NewMock(d)
+	if err := SetLNAStateRuntime(d, m, api.Tuner_A, 5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := m.Params.RxChannelA.TunerParams.Gain.LNAstate; got != 5 {
+		t.Errorf("wrong LNA state: got %d, want 5", got)
+	}
+	want := apitest.UpdateCall{Dev: d.Dev, Tuner: api.Tuner_A, Reason: api.Update_Tuner_Gr, ReasonExt1: api.Update_Ext1_None}
+	if len(m.Updates) != 1 || m.Updates[0] != want {
+		t.Errorf("wrong updates: got %+v, want %+v", m.Updates, want)
+	}
+
+	// The maximum LNA state of an RSP1A below 60 MHz is 6.
+	if err := SetLNAStateRuntime(d, m, api.Tuner_A, 7); err == nil {
+		t.Error("unexpected success for LNA state 7")
+	}
+	if len(m.Updates) != 1 {
+		t.Errorf("unexpected updates: %+v", m.Updates)
+	}
+}
+
+func TestSwapDualTunerSampleRate(t *testing.T) {
+	t.Parallel()
+
+	d := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner, RspDuoSampleFreq: 6e6}
+	m := apitest.NewMock(d)
+	p, _ := m.LoadDeviceParams(d.Dev)
+	for _, c := range []*api.RxChannelParamsT{p.RxChannelA, p.RxChannelB} {
+		if err := SetLowIF(d, p, c, LowIFMaxBits, 4); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := m.StoreDeviceParams(d.Dev, p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	specs := []struct {
+		fs     float64
+		ifType api.If_kHzT
+		bwType api.Bw_MHzT
+	}{
+		{8e6, api.IF_2_048, api.BW_1_536},
+		{6e6, api.IF_1_620, api.BW_0_300},
+	}
+	for _, spec := range specs {
+		got, err := SwapDualTunerSampleRate(d, m)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != spec.fs || d.RspDuoSampleFreq != spec.fs {
+			t.Errorf("wrong sample rate: got %v (device %v), want %v", got, d.RspDuoSampleFreq, spec.fs)
+		}
+		for _, c := range []*api.RxChannelParamsT{m.Params.RxChannelA, m.Params.RxChannelB} {
+			if c.TunerParams.IfType != spec.ifType || c.TunerParams.BwType != spec.bwType {
+				t.Errorf("wrong IF and bandwidth at %v: got %v %v, want %v %v", spec.fs, c.TunerParams.IfType, c.TunerParams.BwType, spec.ifType, spec.bwType)
+			}
+			if got := c.CtrlParams.Decimation.DecimationFactor; got != 4 {
+				t.Errorf("wrong decimation at %v: got %d, want 4", spec.fs, got)
+			}
+		}
+	}
+	want := apitest.UpdateCall{Dev: d.Dev, Tuner: api.Tuner_Both, Reason: api.Update_Tuner_IfType | api.Update_Tuner_BwType, ReasonExt1: api.Update_Ext1_None}
+	if len(m.Updates) != 2 || m.Updates[0] != want || m.Updates[1] != want {
+		t.Errorf("wrong updates: got %+v, want 2 of %+v", m.Updates, want)
+	}
+
+	// Only a dual-tuner RSPduo can swap.
+	for _, bad := range []*api.DeviceT{
+		{HWVer: api.RSP1A_ID},
+		{HWVer: api.RSPduo_ID, Tuner: api.Tuner_A, RspDuoMode: api.RspDuoMode_Single_Tuner},
+		{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner},
+	} {
+		if _, err := SwapDualTunerSampleRate(bad, m); err == nil {
+			t.Errorf("unexpected success for %+v", bad)
+		}
+	}
+
+	m.Errors = map[string]error{"SwapRspDuoDualTunerModeSampleRate": errors.New("swap failed")}
+	if _, err := SwapDualTunerSampleRate(d, m); err == nil {
+		t.Error("unexpected success for failed swap")
+	}
+	if d.RspDuoSampleFreq != 6e6 {
+		t.Errorf("wrong sample rate after failed swap: got %v, want 6e6", d.RspDuoSampleFreq)
+	}
+}
+
+func TestRuntimeChannelsSingleTunerB(t *testing.T) {
+	t.Parallel()
+
+	// A single-tuner RSPduo on tuner B is configured through RxChannelA
+	// by WithSingleChannelConfig, so RxChannelB may be missing.
+	d := &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_B, RspDuoMode: api.RspDuoMode_Single_Tuner}
+	m := apitest.NewMock(d)
+	m.Params = &api.DeviceParamsT{DevParams: &api.DevParamsT{}, RxChannelA: &api.RxChannelParamsT{}}
+	if err := SetLNAStateRuntime(d, m, api.Tuner_B, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := m.Params.RxChannelA.TunerParams.Gain.LNAstate; got != 3 {
+		t.Errorf("wrong channel A LNA state: got %d, want 3", got)
+	}
+	want := apitest.UpdateCall{Dev: d.Dev, Tuner: api.Tuner_B, Reason: api.Update_Tuner_Gr, ReasonExt1: api.Update_Ext1_None}
+	if len(m.Updates) != 1 || m.Updates[0] != want {
+		t.Errorf("wrong updates: got %+v, want %+v", m.Updates, want)
+	}
+
+	// In dual-tuner mode, tuner B has its own channel params.
+	d = &api.DeviceT{HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: api.RspDuoMode_Dual_Tuner}
+	m = apitest.NewMock(d)
+	if err := SetLNAStateRuntime(d, m, api.Tuner_B, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := m.Params.RxChannelB.TunerParams.Gain.LNAstate; got != 3 {
+		t.Errorf("wrong channel B LNA state: got %d, want 3", got)
+	}
+	if got := m.Params.RxChannelA.TunerParams.Gain.LNAstate; got != 0 {
+		t.Errorf("wrong channel A LNA state: got %d, want 0", got)
+	}
+	m.Params.RxChannelB = nil
+	if err := SetLNAStateRuntime(d, m, api.Tuner_B, 3); !errors.Is(err, ErrMissingChannel) {
+		t.Errorf("wrong error without RxChannelB: got %v, want %v", err, ErrMissingChannel)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// ScanStepFn is called by the WithFastScan control loop after each
+// successful retune. The freq argument is the new RF frequency in Hz.
+// Returning a non-nil error stops the scan and ends the session with
+// that error.
+type ScanStepFn func(ctx context.Context, d *api.DeviceT, a api.API, freq float64) error
+
+// WithFastScan creates a function that configures a control loop that
+// repeatedly cycles through the provided frequencies, dwelling on each
+// for the specified duration. Each step retunes with Retune, which only
+// issues an Update_Tuner_Frf. The device is initialized once and is
+// never uninitialized or reconfigured between steps, so stepping is
+// limited only by the tuner settling time rather than a full
+// Uninit/Init cycle. Any other configuration (gain, bandwidth, etc.)
+// is applied once with WithDeviceConfig and stays in effect for the
+// entire scan.
+//
+// The step function, if not nil, is called after each retune. Samples
+// received by the stream callbacks immediately after a step may still
+// contain data from the previous frequency. Stream callbacks can use the
+// RfChanged field of the callback params to identify the first buffer
+// at the new frequency.
+//
+// The scan runs until the Context is canceled, a retune fails, or step
+// returns an error. Like WithControlLoop, it returns an error if a
+// control loop function is already set.
+func WithFastScan(freqs []float64, dwell time.Duration, step ScanStepFn) ConfigFn {
+	return fastScan(freqs, dwell, Retune, step)
+}
+
+// WithFastScanGainTable is like WithFastScan, but each step retunes
+// with RetuneWithGainTable so that the gain is set from the provided
+// table for each frequency as part of the same update. Frequencies
+// that do not match an entry keep the gain of the previous step.
+func WithFastScanGainTable(freqs []float64, dwell time.Duration, entries []GainTableEntry, step ScanStepFn) ConfigFn {
+	return func(o *Session) error {
+		if err := checkGainTable(entries); err != nil {
+			return err
+		}
+		retune := func(d *api.DeviceT, a api.API, tuner api.TunerSelectT, freq float64) error {
+			return RetuneWithGainTable(d, a, tuner, freq, entries)
+		}
+		return fastScan(freqs, dwell, retune, step)(o)
+	}
+}
+
+// fastScan implements WithFastScan using the provided retune function
+// for each step.
+func fastScan(freqs []float64, dwell time.Duration, retune func(d *api.DeviceT, a api.API, tuner api.TunerSelectT, freq float64) error, step ScanStepFn) ConfigFn {
+	return func(o *Session) error {
+		if len(freqs) == 0 {
+			return errors.New("no scan frequencies provided")
+		}
+		// Validate the frequencies up front so that a bad entry fails
+		// before the device is initialized instead of mid-scan.
+		for _, freq := range freqs {
+			if err := SetTuneFreq(nil, nil, &api.RxChannelParamsT{}, freq); err != nil {
+				return err
+			}
+		}
+		return WithControlLoop(func(ctx context.Context, d *api.DeviceT, a api.API) error {
+			tuner := runtimeTuner(d)
+			for i := 0; ; i = (i + 1) % len(freqs) {
+				freq := freqs[i]
+				if err := retune(d, a, tuner, freq); err != nil {
+					return err
+				}
+				if step != nil {
+					if err := step(ctx, d, a, freq); err != nil {
+						return err
+					}
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(dwell):
+				}
+			}
+		})(o)
+	}
+}
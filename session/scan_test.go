@@ -0,0 +1,111 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestWithFastScan(t *testing.T) {
+	t.Parallel()
+
+	freqs := []float64{100e6, 200e6, 300e6}
+	const numSteps = 7
+
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	m := apitest.NewMock(d)
+	errDone := errors.New("done")
+	var got []float64
+	err := Run(
+		context.Background(),
+		WithImplementation(m),
+		WithFastScan(freqs, 0, func(ctx context.Context, d *api.DeviceT, a api.API, freq float64) error {
+			p, err := a.LoadDeviceParams(d.Dev)
+			if err != nil {
+				return err
+			}
+			if p.RxChannelA.TunerParams.RfFreq.RfHz != freq {
+				t.Errorf("wrong stored frequency: got %v, want %v", p.RxChannelA.TunerParams.RfFreq.RfHz, freq)
+			}
+			got = append(got, freq)
+			if len(got) == numSteps {
+				return errDone
+			}
+			return nil
+		}),
+	)
+	if err != errDone {
+		t.Fatalf("wrong error: got %v, want %v", err, errDone)
+	}
+
+	for i := range got {
+		if want := freqs[i%len(freqs)]; got[i] != want {
+			t.Errorf("wrong frequency at step %d: got %v, want %v", i, got[i], want)
+		}
+	}
+
+	var numInit, numUninit int
+	for _, call := range m.Calls {
+		switch call {
+		case "Init":
+			numInit++
+		case "Uninit":
+			numUninit++
+		}
+	}
+	if numInit != 1 || numUninit != 1 {
+		t.Errorf("wrong number of Init/Uninit: got %d/%d, want 1/1", numInit, numUninit)
+	}
+	if len(m.Updates) != numSteps {
+		t.Fatalf("wrong number of updates: got %d, want %d", len(m.Updates), numSteps)
+	}
+	for _, u := range m.Updates {
+		if u.Reason != api.Update_Tuner_Frf || u.Tuner != api.Tuner_A {
+			t.Errorf("wrong update: got %+v", u)
+		}
+	}
+}
+
+func TestWithFastScanInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewSession(WithFastScan(nil, 0, nil)); err == nil {
+		t.Error("unexpected success with no frequencies")
+	}
+	if _, err := NewSession(WithFastScan([]float64{100e6, 3e9}, 0, nil)); err == nil {
+		t.Error("unexpected success with invalid frequency")
+	}
+	if _, err := NewSession(WithControlLoop(nil), WithFastScan([]float64{100e6}, 0, nil)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRetune(t *testing.T) {
+	t.Parallel()
+
+	// A retune of a running device is a single Update of the stored
+	// frequency without reinitializing the device.
+	d := &api.DeviceT{HWVer: api.RSP1A_ID}
+	m := apitest.NewMock(d)
+	if err := Retune(d, m, api.Tuner_A, 200e6); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"LoadDeviceParams", "StoreDeviceParams", "Update"}
+	if !reflect.DeepEqual(m.Calls, want) {
+		t.Errorf("wrong calls: got %v, want %v", m.Calls, want)
+	}
+	if len(m.Updates) != 1 || m.Updates[0].Reason != api.Update_Tuner_Frf || m.Updates[0].Tuner != api.Tuner_A {
+		t.Errorf("wrong updates: got %+v", m.Updates)
+	}
+	if got := m.Params.RxChannelA.TunerParams.RfFreq.RfHz; got != 200e6 {
+		t.Errorf("wrong stored frequency: got %v, want %v", got, 200e6)
+	}
+}
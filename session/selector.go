@@ -5,6 +5,9 @@
 package session
 
 import (
+	"bytes"
+	"sort"
+
 	"github.com/msiner/sdrplay-go/api"
 )
 
@@ -22,6 +25,72 @@ type DevSelectFn func(devs []*api.DeviceT) *api.DeviceT
 // first remaining device.
 type DevFilterFn func(devs []*api.DeviceT) []*api.DeviceT
 
+// SortDevices returns a new slice containing the provided devices
+// in a deterministic order with any duplicate entries removed. The
+// order of devices returned by GetDevices() depends on enumeration
+// order in the API service and can vary from run to run. Run() applies
+// SortDevices to the device list before calling the Session's
+// DevSelectFn so that selection by position is reproducible.
+//
+// Devices are sorted by hardware version and then by serial number.
+// An RSPduo can appear multiple times in the list with the same serial
+// number when it is available in multiple modes (e.g. as a secondary
+// on either tuner). Those entries are not considered duplicates. They
+// are kept and ordered by tuner, mode, and sample rate. Two entries are
+// only considered duplicates if all of those fields also match.
+func SortDevices(devs []*api.DeviceT) []*api.DeviceT {
+	res := make([]*api.DeviceT, 0, len(devs))
+	for _, dev := range devs {
+		if dev == nil {
+			continue
+		}
+		dup := false
+		for _, prev := range res {
+			if sameDevice(prev, dev) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			res = append(res, dev)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return lessDevice(res[i], res[j])
+	})
+	return res
+}
+
+// sameDevice returns true if a and b describe the same device entry.
+func sameDevice(a, b *api.DeviceT) bool {
+	if a.HWVer != b.HWVer || a.SerNo != b.SerNo {
+		return false
+	}
+	if a.HWVer != api.RSPduo_ID {
+		return true
+	}
+	return a.Tuner == b.Tuner &&
+		a.RspDuoMode == b.RspDuoMode &&
+		a.RspDuoSampleFreq == b.RspDuoSampleFreq
+}
+
+// lessDevice returns true if a should be ordered before b.
+func lessDevice(a, b *api.DeviceT) bool {
+	if a.HWVer != b.HWVer {
+		return a.HWVer < b.HWVer
+	}
+	if cmp := bytes.Compare(a.SerNo[:], b.SerNo[:]); cmp != 0 {
+		return cmp < 0
+	}
+	if a.Tuner != b.Tuner {
+		return a.Tuner < b.Tuner
+	}
+	if a.RspDuoMode != b.RspDuoMode {
+		return a.RspDuoMode < b.RspDuoMode
+	}
+	return a.RspDuoSampleFreq < b.RspDuoSampleFreq
+}
+
 // NoopDevFilter is a filter function that accepts
 // any device. It can be used as a noop or placeholder for
 // another function.
@@ -226,7 +295,7 @@ func WithDuoModeSingle() DevFilterFn {
 				res = append(res, dev)
 				continue
 			}
-			if dev.RspDuoMode&api.RspDuoMode_Single_Tuner != 0 {
+			if dev.RspDuoMode.HasSingle() {
 				dev.RspDuoMode = api.RspDuoMode_Single_Tuner
 				res = append(res, dev)
 			}
@@ -239,21 +308,29 @@ func WithDuoModeSingle() DevFilterFn {
 // RSPduo hardware that is not available for dual-tuner mode. That is,
 // if the DeviceT.RspDuoMode field has the RspDuoMode_Dual_Tuner flag
 // set, it will clear all of the other flags in order to select
-// dual-tuner mode.
+// dual-tuner mode. The RSPduo entries that remain are moved ahead of
+// all other devices, so that an RSPduo with dual-tuner mode available
+// is preferred over other hardware and the others are only a fallback.
+// An RSPduo with a tuner in use by another process (e.g. as a shared
+// primary) does not have dual-tuner mode available, so it is removed in
+// favor of an idle one. If no device remains because no RSPduo has
+// dual-tuner mode available, Run reports an error matching
+// ErrDualModeUnavailable. If other hardware remains, the first of it is
+// selected instead, so combine with WithRSPduo() to require an RSPduo.
 //
 // Note that the only devices the filter function will remove are RSPduo
 // hardware without dual-tuner mode available. Non-RSPduo hardware will
-// pass unaffected. To filter on hardware use WithModel() or one of the
-// hardware-specific wrapper functions.
+// pass unaffected, but after any RSPduo. To filter on hardware use
+// WithModel() or one of the hardware-specific wrapper functions.
 func WithDuoModeDual(maxFs bool) DevFilterFn {
 	return func(devs []*api.DeviceT) []*api.DeviceT {
-		var res []*api.DeviceT
+		var res, others []*api.DeviceT
 		for _, dev := range devs {
 			if dev.HWVer != api.RSPduo_ID {
-				res = append(res, dev)
+				others = append(others, dev)
 				continue
 			}
-			if dev.RspDuoMode&api.RspDuoMode_Dual_Tuner != 0 {
+			if dev.RspDuoMode.HasDual() {
 				dev.RspDuoMode = api.RspDuoMode_Dual_Tuner
 				switch maxFs {
 				case true:
@@ -264,7 +341,7 @@ func WithDuoModeDual(maxFs bool) DevFilterFn {
 				res = append(res, dev)
 			}
 		}
-		return res
+		return append(res, others...)
 	}
 }
 
@@ -289,7 +366,7 @@ func WithDuoModeShared(maxFs bool) DevFilterFn {
 				continue
 			}
 			switch {
-			case dev.RspDuoMode&api.RspDuoMode_Primary != 0:
+			case dev.RspDuoMode.HasPrimary():
 				dev.RspDuoMode = api.RspDuoMode_Primary
 				switch maxFs {
 				case true:
@@ -298,7 +375,7 @@ func WithDuoModeShared(maxFs bool) DevFilterFn {
 					dev.RspDuoSampleFreq = 6e6
 				}
 				res = append(res, dev)
-			case dev.RspDuoMode&api.RspDuoMode_Secondary != 0:
+			case dev.RspDuoMode.HasSecondary():
 				dev.RspDuoMode = api.RspDuoMode_Secondary
 				switch maxFs {
 				case true:
@@ -334,7 +411,7 @@ func WithDuoModePrimary(maxFs bool) DevFilterFn {
 				res = append(res, dev)
 				continue
 			}
-			if dev.RspDuoMode&api.RspDuoMode_Primary != 0 {
+			if dev.RspDuoMode.HasPrimary() {
 				dev.RspDuoMode = api.RspDuoMode_Primary
 				switch maxFs {
 				case true:
@@ -367,7 +444,7 @@ func WithDuoModeSecondary(maxFs bool) DevFilterFn {
 				res = append(res, dev)
 				continue
 			}
-			if dev.RspDuoMode&api.RspDuoMode_Secondary != 0 {
+			if dev.RspDuoMode.HasSecondary() {
 				dev.RspDuoMode = api.RspDuoMode_Secondary
 				switch maxFs {
 				case true:
@@ -0,0 +1,148 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestSortDevices(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dx      = api.ParseSerialNumber("2000000001")
+		a1      = api.ParseSerialNumber("1000000002")
+		a2      = api.ParseSerialNumber("1000000001")
+		duo     = api.ParseSerialNumber("1500000001")
+		primSec = api.RspDuoMode_Primary | api.RspDuoMode_Single_Tuner
+	)
+
+	devs := []*api.DeviceT{
+		{SerNo: dx, HWVer: api.RSPdx_ID},
+		{SerNo: duo, HWVer: api.RSPduo_ID, Tuner: api.Tuner_B, RspDuoMode: api.RspDuoMode_Secondary, RspDuoSampleFreq: 6e6},
+		{SerNo: a1, HWVer: api.RSP1A_ID},
+		{SerNo: duo, HWVer: api.RSPduo_ID, Tuner: api.Tuner_A, RspDuoMode: api.RspDuoMode_Secondary, RspDuoSampleFreq: 6e6},
+		{SerNo: a2, HWVer: api.RSP1A_ID},
+		{SerNo: a1, HWVer: api.RSP1A_ID},
+		{SerNo: duo, HWVer: api.RSPduo_ID, Tuner: api.Tuner_A, RspDuoMode: api.RspDuoMode_Secondary, RspDuoSampleFreq: 6e6},
+		{SerNo: duo, HWVer: api.RSPduo_ID, Tuner: api.Tuner_Both, RspDuoMode: primSec},
+	}
+
+	// Hardware versions sort numerically: RSPduo (3), RSPdx (4), RSP1A (255).
+	want := []api.DeviceT{
+		*devs[3],
+		*devs[1],
+		*devs[7],
+		*devs[0],
+		*devs[4],
+		*devs[2],
+	}
+
+	for i := 0; i < 2; i++ {
+		got := SortDevices(devs)
+		if len(got) != len(want) {
+			t.Fatalf("wrong number of devices: got %d, want %d", len(got), len(want))
+		}
+		for j := range got {
+			if *got[j] != want[j] {
+				t.Errorf("wrong device at %d: got %+v, want %+v", j, *got[j], want[j])
+			}
+		}
+		// Reverse the input order. The result must not change.
+		for l, r := 0, len(devs)-1; l < r; l, r = l+1, r-1 {
+			devs[l], devs[r] = devs[r], devs[l]
+		}
+	}
+}
+
+func TestWithDuoModeDualPreference(t *testing.T) {
+	t.Parallel()
+
+	var (
+		rsp2 = func() *api.DeviceT {
+			return &api.DeviceT{SerNo: api.ParseSerialNumber("1000000001"), HWVer: api.RSP2_ID}
+		}
+		// RSPduo with tuner A in use as a shared primary by another
+		// process. It sorts ahead of the free one.
+		busyDuo = func() *api.DeviceT {
+			return &api.DeviceT{SerNo: api.ParseSerialNumber("1500000001"), HWVer: api.RSPduo_ID, Tuner: api.Tuner_B, RspDuoMode: api.RspDuoMode_Secondary, RspDuoSampleFreq: 6e6}
+		}
+		freeDuo = func() *api.DeviceT {
+			return &api.DeviceT{
+				SerNo:      api.ParseSerialNumber("1500000002"),
+				HWVer:      api.RSPduo_ID,
+				Tuner:      api.Tuner_Both,
+				RspDuoMode: api.RspDuoMode_Single_Tuner | api.RspDuoMode_Dual_Tuner | api.RspDuoMode_Primary,
+			}
+		}
+	)
+
+	specs := []struct {
+		name string
+		devs []*api.DeviceT
+		fns  []DevFilterFn
+		want *api.DeviceT
+	}{
+		{"two duos", []*api.DeviceT{busyDuo(), freeDuo()}, nil, freeDuo()},
+		{"two duos reversed", []*api.DeviceT{freeDuo(), busyDuo()}, nil, freeDuo()},
+		// The RSP2 sorts first, but the free RSPduo is preferred.
+		{"other first", []*api.DeviceT{rsp2(), busyDuo(), freeDuo()}, nil, freeDuo()},
+		// Other hardware is only a fallback.
+		{"fallback", []*api.DeviceT{rsp2(), busyDuo()}, nil, rsp2()},
+		{"with tuner filter", []*api.DeviceT{busyDuo(), freeDuo()}, []DevFilterFn{WithRSPduo(), WithDuoTunerBoth()}, freeDuo()},
+	}
+	for _, spec := range specs {
+		var got *api.DeviceT
+		m := apitest.NewMock(spec.devs...)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := Run(
+			ctx,
+			WithImplementation(m),
+			WithSelector(append(spec.fns, WithDuoModeDual(false))...),
+			WithDeviceConfig(func(d *api.DeviceT, p *api.DeviceParamsT) error {
+				got = d
+				return nil
+			}),
+		)
+		if err != context.Canceled {
+			t.Errorf("%s: unexpected error: %v", spec.name, err)
+			continue
+		}
+		if got == nil || got.SerNo != spec.want.SerNo {
+			t.Errorf("%s: wrong device: got %+v, want %v", spec.name, got, spec.want.SerNo)
+			continue
+		}
+		if got.HWVer == api.RSPduo_ID && (got.RspDuoMode != api.RspDuoMode_Dual_Tuner || got.RspDuoSampleFreq != 6e6) {
+			t.Errorf("%s: wrong mode: got %v at %v Hz, want %v at 6e6 Hz", spec.name, got.RspDuoMode, got.RspDuoSampleFreq, api.RspDuoMode_Dual_Tuner)
+		}
+	}
+
+	// With no RSPduo free for dual-tuner mode, the error says so.
+	m := apitest.NewMock(busyDuo(), busyDuo())
+	err := Run(context.Background(), WithImplementation(m), WithSelector(WithRSPduo(), WithDuoModeDual(false)))
+	if !errors.Is(err, ErrDualModeUnavailable) {
+		t.Fatalf("wrong error: got %v, want %v", err, ErrDualModeUnavailable)
+	}
+	if !strings.Contains(err.Error(), ErrDualModeUnavailable.Error()) {
+		t.Errorf("wrong error message: got %q, want it to contain %q", err, ErrDualModeUnavailable)
+	}
+
+	// Without a dual-mode filter, a busy RSPduo is not the reason.
+	m = apitest.NewMock(busyDuo(), busyDuo())
+	err = Run(context.Background(), WithImplementation(m), WithSelector(WithRSPdx()))
+	if !errors.Is(err, ErrNoMatchingDevice) {
+		t.Fatalf("wrong error: got %v, want %v", err, ErrNoMatchingDevice)
+	}
+	if errors.Is(err, ErrDualModeUnavailable) {
+		t.Errorf("wrong error: got %v, want no %v", err, ErrDualModeUnavailable)
+	}
+}
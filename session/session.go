@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/msiner/sdrplay-go/api"
 )
@@ -20,7 +21,9 @@ type ConfigFn func(o *Session) error
 
 // ControlFn is implemented by a function that is responsible for
 // run-time control after Init() has been called. Using the provided
-// DeviceT and API, the device can be reconfigured as necessary.
+// DeviceT and API, the device can be reconfigured as necessary. Use
+// SwapActiveTuner and SwapDualTunerSampleRate to keep the DeviceT in
+// sync with a swap, and CurrentTuner and CurrentMode to read it.
 //
 // The function should implement some form of loop, sleep, or wait
 // and not return until the device is no longer required. When the
@@ -33,14 +36,20 @@ type ControlFn func(ctx context.Context, d *api.DeviceT, a api.API) error
 // by calling NewSession with the desired options declared using the
 // WithXYZ() functions that return a ConfigFn (e.g. WithSelector).
 type Session struct {
-	Impl        api.API
-	Selector    DevSelectFn
-	DebugEn     bool
-	DevCfg      DevConfigFn
-	StreamACbFn api.StreamCallbackT
-	StreamBCbFn api.StreamCallbackT
-	EventCbFn   api.EventCallbackT
-	Control     ControlFn
+	Impl         api.API
+	Selector     DevSelectFn
+	DebugEn      bool
+	DevCfg       DevConfigFn
+	StreamACbFn  api.StreamCallbackT
+	StreamBCbFn  api.StreamCallbackT
+	EventCbFn    api.EventCallbackT
+	Control      ControlFn
+	AutoTransfer *AutoTransfer
+	AdaptiveDec  *AdaptiveDecimation
+	AutoGain     *AutoGain
+	AFC          *AFC
+	AGCClamp     *AGCClamp
+	VerifyParams VerifyParamsFn
 }
 
 // NewSession creates a new Session and calls each given ConfigFn with
@@ -61,7 +70,8 @@ func NewSession(fns ...ConfigFn) (*Session, error) {
 // WithImplementation creates a function that sets the Impl member to the
 // specified implementation of api.API. This is not necessary, as Run()
 // will call api.GetInstance() if Impl is nil. This is available for testing
-// via dependency injection.
+// via dependency injection. It can also be used to install a decorator,
+// such as api.NewRecorder to trace the calls that change the device state.
 func WithImplementation(impl api.API) ConfigFn {
 	return func(o *Session) error {
 		if o.Impl != nil {
@@ -177,19 +187,10 @@ func WithControlLoop(fn ControlFn) ConfigFn {
 	}
 }
 
-// Run runs the configured Session. The provided Context is passed to the
-// control loop function if one is provided. If no control loop has been
-// provided, Run will wait on the ctx.Done() channel. Therfore, this
-// function will block until an error is encountered, the control loop
-// exits, and/or the Context is canceled.
-func (s *Session) Run(ctx context.Context) error {
-	impl := s.Impl
-	if impl == nil {
-		impl = api.GetAPI()
-	}
-
-	err := impl.Open()
-	if err != nil {
+// openAPI calls Open on impl and returns an error with the details of
+// the failure, if any.
+func openAPI(impl api.API) error {
+	if err := impl.Open(); err != nil {
 		switch err.(type) {
 		case api.ErrT:
 			return fmt.Errorf("failed to open API: %v", impl.GetLastError(nil))
@@ -197,64 +198,150 @@ func (s *Session) Run(ctx context.Context) error {
 			return fmt.Errorf("failed to open API: %v", err)
 		}
 	}
-	defer impl.Close()
+	return nil
+}
 
-	// Encapsulate device selection in a separate function so we can
-	// do LockDeviceApi and then defer UnlockDeviceApi to cleanup
-	// after device selection regardless of success or failure.
-	selectDevice := func() (*api.DeviceT, error) {
-		if err := impl.LockDeviceApi(); err != nil {
-			return nil, fmt.Errorf("failed to lock API: %v", impl.GetLastError(nil))
-		}
-		defer func() {
-			if err := impl.UnlockDeviceApi(); err != nil {
-				fmt.Fprintf(os.Stderr, "UnlockDeviceApi failed: %v", err)
-			}
-		}()
+// setup opens the API, selects a device, and applies and stores the
+// device configuration. If it returns a nil error, the returned function
+// must be called to release the device and close the API. Otherwise,
+// everything has already been released.
+func (s *Session) setup() (api.API, *api.DeviceT, func(), error) {
+	impl := s.Impl
+	if impl == nil {
+		impl = api.GetAPI()
+	}
+	if err := openAPI(impl); err != nil {
+		return nil, nil, nil, err
+	}
+	dev, release, err := s.acquire(impl, nil)
+	if err != nil {
+		impl.Close()
+		return nil, nil, nil, err
+	}
+	return impl, dev, func() {
+		release()
+		impl.Close()
+	}, nil
+}
 
-		devs, err := impl.GetDevices()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get device list: %v", impl.GetLastError(nil))
+// acquire selects a device with the already opened impl and applies and
+// stores the device configuration. Devices with a serial number in
+// exclude are not considered for selection. If it returns a nil error,
+// the returned function must be called to release the device.
+// Otherwise, the device has already been released.
+func (s *Session) acquire(impl api.API, exclude []api.SerialNumber) (*api.DeviceT, func(), error) {
+	dev, err := s.selectDevice(impl, exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+	release := func() {
+		if err := impl.ReleaseDevice(dev); err != nil {
+			fmt.Fprintf(os.Stderr, "ReleaseDevice failed: %v", err)
 		}
+	}
+	if err := s.configure(impl, dev); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return dev, release, nil
+}
 
-		if len(devs) == 0 {
-			return nil, errors.New("no RSP devices found")
+// selectDevice lists the available devices, applies the selector, and
+// selects the resulting device. It does LockDeviceApi and then defers
+// UnlockDeviceApi to cleanup after device selection regardless of
+// success or failure.
+func (s *Session) selectDevice(impl api.API, exclude []api.SerialNumber) (*api.DeviceT, error) {
+	if err := impl.LockDeviceApi(); err != nil {
+		return nil, fmt.Errorf("failed to lock API: %v", impl.GetLastError(nil))
+	}
+	defer func() {
+		if err := impl.UnlockDeviceApi(); err != nil {
+			fmt.Fprintf(os.Stderr, "UnlockDeviceApi failed: %v", err)
 		}
+	}()
+
+	devs, err := impl.GetDevices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device list: %v", impl.GetLastError(nil))
+	}
 
-		res := devs[0]
-		if s.Selector != nil {
-			res := s.Selector(devs)
-			if res == nil {
-				var parts []string
-				for _, dev := range devs {
-					switch dev.HWVer {
-					case api.RSPduo_ID:
-						parts = append(parts, fmt.Sprintf("(%v,%v,%v,%v,%v)", dev.HWVer, dev.SerNo, dev.Tuner, dev.RspDuoMode, dev.RspDuoSampleFreq))
-					default:
-						parts = append(parts, fmt.Sprintf("(%v,%v)", dev.HWVer, dev.SerNo))
-					}
+	if len(exclude) > 0 {
+		var keep []*api.DeviceT
+	devLoop:
+		for _, dev := range devs {
+			for _, ser := range exclude {
+				if dev != nil && dev.SerNo == ser {
+					continue devLoop
 				}
-				return nil, fmt.Errorf("no matching devices selected from: %v", parts)
 			}
+			keep = append(keep, dev)
 		}
+		devs = keep
+	}
 
-		if err := impl.SelectDevice(res); err != nil {
-			return nil, fmt.Errorf("device selection failed: %v", impl.GetLastError(nil))
-		}
+	devs = SortDevices(devs)
+	if len(devs) == 0 {
+		return nil, ErrNoDevices
+	}
 
-		return res, nil
+	res := devs[0]
+	if s.Selector != nil {
+		// Filters may modify the entries, so copy them before
+		// selection.
+		dual := withDualMode(devs)
+		res = s.Selector(devs)
+		if res == nil {
+			var parts []string
+			for _, dev := range devs {
+				switch dev.HWVer {
+				case api.RSPduo_ID:
+					parts = append(parts, fmt.Sprintf("(%v,%v,%v,%v,%v)", dev.HWVer, dev.SerNo, dev.Tuner, dev.RspDuoMode, dev.RspDuoSampleFreq))
+				default:
+					parts = append(parts, fmt.Sprintf("(%v,%v)", dev.HWVer, dev.SerNo))
+				}
+			}
+			// If the selector would select a device with dual-tuner
+			// mode available, as with WithDuoModeDual, that is the
+			// reason for the failure.
+			if dual != nil && s.Selector(dual) != nil {
+				msg := fmt.Sprintf("%v (%v) from: %v", ErrNoMatchingDevice, ErrDualModeUnavailable, parts)
+				return nil, wrapError(msg, ErrNoMatchingDevice, ErrDualModeUnavailable)
+			}
+			msg := fmt.Sprintf("%v from: %v", ErrNoMatchingDevice, parts)
+			return nil, wrapError(msg, ErrNoMatchingDevice)
+		}
 	}
 
-	dev, err := selectDevice()
-	if err != nil {
-		return err
+	if err := impl.SelectDevice(res); err != nil {
+		return nil, fmt.Errorf("device selection failed: %v", impl.GetLastError(nil))
 	}
-	defer func() {
-		if err := impl.ReleaseDevice(dev); err != nil {
-			fmt.Fprintf(os.Stderr, "ReleaseDevice failed: %v", err)
+
+	return res, nil
+}
+
+// withDualMode returns copies of the entries in devs with dual-tuner
+// mode available on every RSPduo. It returns nil if dual-tuner mode is
+// already available on every RSPduo in devs.
+func withDualMode(devs []*api.DeviceT) []*api.DeviceT {
+	var res []*api.DeviceT
+	var changed bool
+	for _, dev := range devs {
+		cp := *dev
+		if cp.HWVer == api.RSPduo_ID && !cp.RspDuoMode.HasDual() {
+			cp.RspDuoMode |= api.RspDuoMode_Dual_Tuner
+			changed = true
 		}
-	}()
+		res = append(res, &cp)
+	}
+	if !changed {
+		return nil
+	}
+	return res
+}
 
+// configure loads, configures, and stores the device parameters of the
+// selected device.
+func (s *Session) configure(impl api.API, dev *api.DeviceT) error {
 	if s.DebugEn {
 		if err := impl.DebugEnable(dev.Dev, api.DbgLvl_Message); err != nil {
 			return fmt.Errorf("debug enable failed: %v", impl.GetLastError(dev))
@@ -266,30 +353,199 @@ func (s *Session) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to load device params: %v", impl.GetLastError(dev))
 	}
 
+	// Fail before configuration instead of dereferencing missing
+	// channel params.
+	if err := checkConfigChannels(dev, params); err != nil {
+		return err
+	}
+
 	if s.DevCfg != nil {
 		if err := s.DevCfg(dev, params); err != nil {
 			return err
 		}
 	}
 
+	if s.AutoTransfer != nil {
+		if params.DevParams == nil {
+			return errors.New("cannot select transfer mode for RSPduo in slave mode")
+		}
+		params.DevParams.Mode = api.ISOCH
+	}
+
 	if err := impl.StoreDeviceParams(dev.Dev, params); err != nil {
 		return fmt.Errorf("failed to store device params: %v", impl.GetLastError(dev))
 	}
 
+	if s.VerifyParams != nil {
+		return verifyParams(impl, dev, params, s.VerifyParams)
+	}
+	return nil
+}
+
+// DryRun performs the same device selection and configuration as Run,
+// but never calls Init. It opens the API, selects a device, loads the
+// device parameters, applies the device configuration, and stores the
+// parameters. It then releases the device and closes the API. This
+// validates that a configuration is accepted without streaming. The
+// stream callbacks, event callback, and control loop are ignored.
+//
+// It returns the device parameters as loaded back from the API after
+// they were stored, or the first error encountered.
+func (s *Session) DryRun() (*api.DeviceParamsT, error) {
+	impl, dev, release, err := s.setup()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	params, err := impl.LoadDeviceParams(dev.Dev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load device params: %v", impl.GetLastError(dev))
+	}
+	return params, nil
+}
+
+// Run runs the configured Session. The provided Context is passed to the
+// control loop function if one is provided. If no control loop has been
+// provided, Run will wait on the ctx.Done() channel. Therfore, this
+// function will block until an error is encountered, the control loop
+// exits, and/or the Context is canceled.
+func (s *Session) Run(ctx context.Context) error {
+	if s.AFC != nil {
+		if s.AFC.NumSamples <= 0 {
+			return fmt.Errorf("invalid AFC sample count: got %d, want > 0", s.AFC.NumSamples)
+		}
+		if s.AFC.Interval <= 0 {
+			return fmt.Errorf("invalid AFC interval: got %v, want > 0", s.AFC.Interval)
+		}
+	}
+
+	impl, dev, release, err := s.setup()
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	cbFuncs := api.CallbackFnsT{
 		StreamACbFn: s.StreamACbFn,
 		StreamBCbFn: s.StreamBCbFn,
 		EventCbFn:   s.EventCbFn,
 	}
+	var meter *levelMeter
+	if s.AutoGain != nil {
+		meter = &levelMeter{}
+		cbFuncs.StreamACbFn = meter.wrapStream(cbFuncs.StreamACbFn)
+		if cbFuncs.StreamBCbFn != nil {
+			cbFuncs.StreamBCbFn = meter.holdStream(cbFuncs.StreamBCbFn)
+		}
+		cbFuncs.EventCbFn = meter.wrapEvent(cbFuncs.EventCbFn)
+	}
+	var stats *TransferStats
+	if s.AutoTransfer != nil {
+		stats = NewTransferStats(0)
+		cbFuncs.StreamACbFn = stats.Wrap(cbFuncs.StreamACbFn)
+	}
+	var adaptStats *TransferStats
+	if s.AdaptiveDec != nil {
+		adaptStats = NewTransferStats(0)
+		cbFuncs.StreamACbFn = adaptStats.Wrap(cbFuncs.StreamACbFn)
+	}
+	var afcRing *sampleRing
+	if s.AFC != nil {
+		afcRing = newSampleRing(s.AFC.NumSamples)
+		cbFuncs.StreamACbFn = afcRing.wrap(cbFuncs.StreamACbFn)
+	}
+	var clips *clipDetector
+	if s.AGCClamp != nil {
+		clips = newClipDetector(s.AGCClamp.ClipDBFS)
+		cbFuncs.StreamACbFn = clips.wrapStream(cbFuncs.StreamACbFn)
+		cbFuncs.EventCbFn = clips.wrapEvent(cbFuncs.EventCbFn)
+	}
 	if err := impl.Init(dev.Dev, cbFuncs); err != nil {
 		return fmt.Errorf("init failed: %v", impl.GetLastError(dev))
 	}
+	initialized := true
 	defer func() {
+		if !initialized {
+			return
+		}
 		if err := impl.Uninit(dev.Dev); err != nil {
 			fmt.Fprintf(os.Stderr, "Uninit failed: %v", err)
 		}
 	}()
 
+	if stats != nil {
+		mode, err := s.AutoTransfer.warmup(ctx, stats)
+		if err != nil {
+			return err
+		}
+		if mode != api.ISOCH {
+			if err := impl.Uninit(dev.Dev); err != nil {
+				return fmt.Errorf("uninit failed: %v", impl.GetLastError(dev))
+			}
+			initialized = false
+			if err := initTransferMode(dev, impl, cbFuncs, mode); err != nil {
+				return err
+			}
+			initialized = true
+		}
+	}
+
+	if meter != nil {
+		if err := s.AutoGain.sweep(ctx, dev, impl, meter); err != nil {
+			return err
+		}
+	}
+
+	var controllers []func(ctx context.Context) error
+	if adaptStats != nil {
+		controllers = append(controllers, func(ctx context.Context) error {
+			return s.AdaptiveDec.run(ctx, dev, impl, adaptStats)
+		})
+	}
+	if afcRing != nil {
+		controllers = append(controllers, func(ctx context.Context) error {
+			return s.AFC.run(ctx, dev, impl, afcRing)
+		})
+	}
+	if clips != nil {
+		controllers = append(controllers, func(ctx context.Context) error {
+			return s.AGCClamp.run(ctx, dev, impl, clips)
+		})
+	}
+	if len(controllers) == 0 {
+		return s.control(ctx, dev, impl)
+	}
+
+	// Run the controllers alongside the control loop. If a controller
+	// fails, the control loop and the other controllers are canceled
+	// and the first controller error is returned.
+	ctx, cancel := context.WithCancel(ctx)
+	errs := make([]error, len(controllers))
+	var wg sync.WaitGroup
+	for i, fn := range controllers {
+		wg.Add(1)
+		go func(i int, fn func(ctx context.Context) error) {
+			defer wg.Done()
+			if err := fn(ctx); err != nil {
+				errs[i] = err
+				cancel()
+			}
+		}(i, fn)
+	}
+	err = s.control(ctx, dev, impl)
+	cancel()
+	wg.Wait()
+	for _, cerr := range errs {
+		if cerr != nil {
+			return cerr
+		}
+	}
+	return err
+}
+
+// control runs the control loop, if any, or waits on the context.
+func (s *Session) control(ctx context.Context, dev *api.DeviceT, impl api.API) error {
 	switch s.Control {
 	case nil:
 		// No control loop provided, just wait on the context.
@@ -309,3 +565,13 @@ func Run(ctx context.Context, fns ...ConfigFn) error {
 	}
 	return s.Run(ctx)
 }
+
+// DryRun is a simplified wrapper around calling NewSession, checking for
+// an error, and then calling Session.DryRun.
+func DryRun(fns ...ConfigFn) (*api.DeviceParamsT, error) {
+	s, err := NewSession(fns...)
+	if err != nil {
+		return nil, err
+	}
+	return s.DryRun()
+}
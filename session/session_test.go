@@ -0,0 +1,181 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	m := apitest.NewMock(&api.DeviceT{HWVer: api.RSP1A_ID})
+	p, err := DryRun(
+		WithImplementation(m),
+		WithDeviceConfig(
+			WithTransferMode(api.BULK),
+			WithSingleChannelConfig(
+				WithTuneFreq(100e6),
+				WithZeroIF(8e6, 4),
+			),
+		),
+		WithStreamACallback(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+			t.Error("unexpected stream callback")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.DevParams.Mode != api.BULK {
+		t.Errorf("wrong transfer mode: got %v, want %v", p.DevParams.Mode, api.BULK)
+	}
+	if p.DevParams.FsFreq.FsHz != 8e6 {
+		t.Errorf("wrong sample rate: got %v, want %v", p.DevParams.FsFreq.FsHz, 8e6)
+	}
+	if p.RxChannelA.TunerParams.RfFreq.RfHz != 100e6 {
+		t.Errorf("wrong frequency: got %v, want %v", p.RxChannelA.TunerParams.RfFreq.RfHz, 100e6)
+	}
+
+	want := []string{
+		"Open",
+		"LockDeviceApi",
+		"GetDevices",
+		"SelectDevice",
+		"UnlockDeviceApi",
+		"LoadDeviceParams",
+		"StoreDeviceParams",
+		"LoadDeviceParams",
+		"ReleaseDevice",
+		"Close",
+	}
+	if !reflect.DeepEqual(m.Calls, want) {
+		t.Errorf("wrong calls: got %v, want %v", m.Calls, want)
+	}
+}
+
+func TestDryRunError(t *testing.T) {
+	t.Parallel()
+
+	// A configuration error is caught before storing.
+	m := apitest.NewMock(&api.DeviceT{HWVer: api.RSP1A_ID})
+	_, err := DryRun(
+		WithImplementation(m),
+		WithDeviceConfig(
+			WithSingleChannelConfig(
+				WithZeroIF(1e6, 1),
+			),
+		),
+	)
+	if err == nil {
+		t.Fatal("unexpected success for invalid sample rate")
+	}
+	for _, call := range m.Calls {
+		if call == "StoreDeviceParams" || call == "Init" {
+			t.Errorf("unexpected call to %s", call)
+		}
+	}
+	if n := len(m.Calls); n < 2 || m.Calls[n-2] != "ReleaseDevice" || m.Calls[n-1] != "Close" {
+		t.Errorf("device not released: got %v", m.Calls)
+	}
+
+	// An error from the API is reported and everything is released.
+	m = apitest.NewMock(&api.DeviceT{HWVer: api.RSP1A_ID})
+	m.Errors = map[string]error{"StoreDeviceParams": errors.New("rejected")}
+	if _, err := DryRun(WithImplementation(m)); err == nil {
+		t.Fatal("unexpected success for rejected params")
+	}
+	if n := len(m.Calls); n < 2 || m.Calls[n-2] != "ReleaseDevice" || m.Calls[n-1] != "Close" {
+		t.Errorf("device not released: got %v", m.Calls)
+	}
+}
+
+func TestDryRunMissingChannel(t *testing.T) {
+	t.Parallel()
+
+	m := apitest.NewMock(&api.DeviceT{
+		HWVer:      api.RSPduo_ID,
+		Tuner:      api.Tuner_Both,
+		RspDuoMode: api.RspDuoMode_Dual_Tuner,
+	})
+	m.Params = &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelA: &api.RxChannelParamsT{},
+	}
+	configured := false
+	_, err := DryRun(
+		WithImplementation(m),
+		WithDeviceConfig(
+			func(d *api.DeviceT, p *api.DeviceParamsT) error {
+				configured = true
+				return nil
+			},
+			WithDuoChannelBConfig(WithTuneFreq(100e6)),
+		),
+	)
+	if !errors.Is(err, ErrMissingChannel) {
+		t.Fatalf("wrong error: got %v, want %v", err, ErrMissingChannel)
+	}
+	if configured {
+		t.Error("unexpected device config with missing channel")
+	}
+	for _, call := range m.Calls {
+		if call == "StoreDeviceParams" {
+			t.Errorf("unexpected call to %s", call)
+		}
+	}
+	if n := len(m.Calls); n < 2 || m.Calls[n-2] != "ReleaseDevice" || m.Calls[n-1] != "Close" {
+		t.Errorf("device not released: got %v", m.Calls)
+	}
+
+	// The same params are accepted with only tuner A selected.
+	m = apitest.NewMock(&api.DeviceT{
+		HWVer:      api.RSPduo_ID,
+		Tuner:      api.Tuner_A,
+		RspDuoMode: api.RspDuoMode_Single_Tuner,
+	})
+	m.Params = &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelA: &api.RxChannelParamsT{},
+	}
+	if _, err := DryRun(WithImplementation(m)); err != nil {
+		t.Errorf("unexpected error for tuner A: %v", err)
+	}
+
+	// With only tuner B selected in single tuner mode, the single
+	// channel config uses RxChannelA, so it must be present.
+	singleB := &api.DeviceT{
+		HWVer:      api.RSPduo_ID,
+		Tuner:      api.Tuner_B,
+		RspDuoMode: api.RspDuoMode_Single_Tuner,
+	}
+	m = apitest.NewMock(singleB)
+	m.Params = &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelA: &api.RxChannelParamsT{},
+	}
+	if _, err := DryRun(
+		WithImplementation(m),
+		WithDeviceConfig(WithSingleChannelConfig(WithTuneFreq(100e6))),
+	); err != nil {
+		t.Errorf("unexpected error for single tuner B: %v", err)
+	}
+	m = apitest.NewMock(singleB)
+	m.Params = &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelB: &api.RxChannelParamsT{},
+	}
+	_, err = DryRun(
+		WithImplementation(m),
+		WithDeviceConfig(WithSingleChannelConfig(WithTuneFreq(100e6))),
+	)
+	if !errors.Is(err, ErrMissingChannel) {
+		t.Errorf("wrong error for single tuner B without RxChannelA: got %v, want %v", err, ErrMissingChannel)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"sync"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// StartSkewProbe measures the offset between the start of stream A and
+// stream B when an RSPduo is used in dual-tuner mode. It records the
+// FirstSampleNum of the first callback received on each stream and
+// reports the difference. This is the offset that must be corrected to
+// align samples from the two tuners (e.g. by duo.Synchro).
+//
+// A StartSkewProbe is safe for concurrent use by both stream callbacks
+// and any number of readers.
+type StartSkewProbe struct {
+	mu     sync.Mutex
+	firstA uint32
+	firstB uint32
+	validA bool
+	validB bool
+}
+
+// NewStartSkewProbe creates a new StartSkewProbe. Use StreamACallback
+// and StreamBCallback as the stream callbacks directly or use WrapStreamA
+// and WrapStreamB to insert the probe in front of existing callbacks.
+//
+// Examples:
+// 		probe := NewStartSkewProbe()
+// 		Run(
+// 			ctx,
+// 			WithStreamACallback(probe.WrapStreamA(handleA)),
+// 			WithStreamBCallback(probe.WrapStreamB(handleB)),
+// 			...
+// 		)
+func NewStartSkewProbe() *StartSkewProbe {
+	return &StartSkewProbe{}
+}
+
+// StreamACallback implements api.StreamCallbackT for stream A. Only the
+// first call since creation or the last call to Reset is recorded.
+func (p *StartSkewProbe) StreamACallback(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+	if params == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.validA {
+		p.firstA = params.FirstSampleNum
+		p.validA = true
+	}
+	p.mu.Unlock()
+}
+
+// StreamBCallback implements api.StreamCallbackT for stream B. Only the
+// first call since creation or the last call to Reset is recorded.
+func (p *StartSkewProbe) StreamBCallback(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+	if params == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.validB {
+		p.firstB = params.FirstSampleNum
+		p.validB = true
+	}
+	p.mu.Unlock()
+}
+
+// WrapStreamA returns a api.StreamCallbackT that calls StreamACallback
+// and then next, if next is not nil.
+func (p *StartSkewProbe) WrapStreamA(next api.StreamCallbackT) api.StreamCallbackT {
+	return func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		p.StreamACallback(xi, xq, params, reset)
+		if next != nil {
+			next(xi, xq, params, reset)
+		}
+	}
+}
+
+// WrapStreamB returns a api.StreamCallbackT that calls StreamBCallback
+// and then next, if next is not nil.
+func (p *StartSkewProbe) WrapStreamB(next api.StreamCallbackT) api.StreamCallbackT {
+	return func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		p.StreamBCallback(xi, xq, params, reset)
+		if next != nil {
+			next(xi, xq, params, reset)
+		}
+	}
+}
+
+// Skew returns the start offset in samples of stream B relative to
+// stream A. That is, a positive value means the first B callback started
+// at a later sample number than the first A callback. The second return
+// value is false if a callback has not yet been received on both streams.
+// The offset is computed modulo 2^32 to handle a sample counter wrap
+// between the two first callbacks.
+func (p *StartSkewProbe) Skew() (int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.validA || !p.validB {
+		return 0, false
+	}
+	return int64(int32(p.firstB - p.firstA)), true
+}
+
+// Reset clears the recorded state so that the next callback on each
+// stream is recorded. It can be used to measure the skew again after
+// a reconfiguration that restarts the streams.
+func (p *StartSkewProbe) Reset() {
+	p.mu.Lock()
+	p.validA = false
+	p.validB = false
+	p.mu.Unlock()
+}
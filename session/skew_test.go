@@ -0,0 +1,84 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"math"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestStartSkewProbe(t *testing.T) {
+	t.Parallel()
+
+	const numSamples = 1000
+
+	specs := []struct {
+		firstA uint32
+		firstB uint32
+		aFirst bool
+		want   int64
+	}{
+		{5000, 5000, true, 0},
+		{5000, 5123, true, 123},
+		{5000, 5123, false, 123},
+		{5123, 5000, true, -123},
+		{math.MaxUint32 - 10, 10, true, 21},
+		{10, math.MaxUint32 - 10, false, -21},
+	}
+
+	for _, spec := range specs {
+		var calledA, calledB int
+		probe := NewStartSkewProbe()
+		cbA := probe.WrapStreamA(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) { calledA++ })
+		cbB := probe.WrapStreamB(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) { calledB++ })
+
+		pa := &api.StreamCbParamsT{FirstSampleNum: spec.firstA, NumSamples: numSamples}
+		pb := &api.StreamCbParamsT{FirstSampleNum: spec.firstB, NumSamples: numSamples}
+
+		if _, ok := probe.Skew(); ok {
+			t.Fatal("unexpected valid skew before callbacks")
+		}
+		first, second := func() { cbA(nil, nil, pa, true) }, func() { cbB(nil, nil, pb, true) }
+		if !spec.aFirst {
+			first, second = second, first
+		}
+		first()
+		if _, ok := probe.Skew(); ok {
+			t.Fatal("unexpected valid skew after one stream")
+		}
+		second()
+
+		// Later callbacks must not change the measurement.
+		for i := 0; i < 3; i++ {
+			pa.FirstSampleNum += numSamples
+			pb.FirstSampleNum += 2 * numSamples
+			cbA(nil, nil, pa, false)
+			cbB(nil, nil, pb, false)
+		}
+
+		got, ok := probe.Skew()
+		if !ok {
+			t.Fatal("skew not valid after both streams")
+		}
+		if got != spec.want {
+			t.Errorf("wrong skew for A=%d B=%d: got %d, want %d", spec.firstA, spec.firstB, got, spec.want)
+		}
+		if calledA != 4 || calledB != 4 {
+			t.Errorf("wrong number of wrapped calls: got A=%d B=%d, want 4", calledA, calledB)
+		}
+
+		probe.Reset()
+		if _, ok := probe.Skew(); ok {
+			t.Fatal("unexpected valid skew after reset")
+		}
+		cbA(nil, nil, pa, false)
+		cbB(nil, nil, pb, false)
+		if got, _ := probe.Skew(); got != int64(int32(pb.FirstSampleNum-pa.FirstSampleNum)) {
+			t.Errorf("wrong skew after reset: got %d, want %d", got, int64(int32(pb.FirstSampleNum-pa.FirstSampleNum)))
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/helpers/callback"
+)
+
+// TransferReport is a snapshot of the statistics collected by a
+// TransferStats.
+type TransferReport struct {
+	// Elapsed is the wall time since the first callback after
+	// creation or the last call to Reset.
+	Elapsed time.Duration
+	// Callbacks is the number of stream callbacks received.
+	Callbacks uint64
+	// Samples is the number of samples received.
+	Samples uint64
+	// Dropped is the number of samples reported missing by the
+	// FirstSampleNum sequence of the stream callbacks.
+	Dropped uint64
+	// CallbacksPerSec is the rate of stream callbacks.
+	CallbacksPerSec float64
+	// SamplesPerSec is the rate of received samples.
+	SamplesPerSec float64
+	// ExpectedSamplesPerSec is the configured effective sample rate.
+	ExpectedSamplesPerSec float64
+	// DropPercent is the percentage of samples that were dropped. That
+	// is, 100 * Dropped / (Samples + Dropped).
+	DropPercent float64
+}
+
+// TransferStats collects statistics about stream callbacks to quantify
+// the rate of dropped samples (e.g. to compare isochronous and bulk
+// transfer modes). Unlike a single DropDetectFn, it accumulates counts
+// over a window that starts with the first callback after creation or
+// the last call to Reset and compares the measured sample rate to the
+// expected effective sample rate.
+//
+// A TransferStats is safe for concurrent use by a stream callback and
+// any number of readers.
+type TransferStats struct {
+	mu        sync.Mutex
+	expected  float64
+	now       func() time.Time
+	detect    callback.DropDetectFn
+	started   bool
+	start     time.Time
+	last      time.Time
+	callbacks uint64
+	samples   uint64
+	dropped   uint64
+	// rateSamples is the number of samples received after the first
+	// callback in the window. The first callback marks the start of
+	// the window, so its samples are not included in rates.
+	rateSamples uint64
+}
+
+// NewTransferStats creates a new TransferStats. The expectedRate
+// argument is the effective sample rate in samples per second. It
+// is typically the value returned by GetEffectiveSampleRate. A gap in
+// the sample numbers of more than one second at that rate is taken as
+// a reset of the sample counter rather than a drop.
+func NewTransferStats(expectedRate float64) *TransferStats {
+	return &TransferStats{
+		expected: expectedRate,
+		now:      time.Now,
+		detect:   newDropDetect(expectedRate),
+	}
+}
+
+// newDropDetect creates a DropDetectFn that treats a gap of more than
+// one second worth of samples at rate as a reset of the sample counter,
+// so that a counter reset without the reset flag is not counted as a
+// huge drop. Without a valid rate, every gap is counted.
+func newDropDetect(rate float64) callback.DropDetectFn {
+	threshold := uint32(math.MaxUint32)
+	if rate >= 1 && rate < math.MaxUint32 {
+		threshold = uint32(rate)
+	}
+	return callback.NewDropDetectFnWithThreshold(threshold)
+}
+
+// StreamCallback implements api.StreamCallbackT. It must be called for
+// every callback on the stream to keep the drop detection valid. A reset
+// indicated by the API does not restart the window, but the sample
+// number discontinuity at the reset is not counted as a drop.
+func (s *TransferStats) StreamCallback(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+	if params == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.now()
+	dropped := s.detect(params, reset)
+	if !s.started {
+		s.started = true
+		s.start = t
+	} else {
+		s.rateSamples += uint64(params.NumSamples)
+		s.dropped += uint64(dropped)
+	}
+	s.last = t
+	s.callbacks++
+	s.samples += uint64(params.NumSamples)
+}
+
+// Wrap returns a api.StreamCallbackT that calls StreamCallback and
+// then next, if next is not nil.
+func (s *TransferStats) Wrap(next api.StreamCallbackT) api.StreamCallbackT {
+	return func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) {
+		s.StreamCallback(xi, xq, params, reset)
+		if next != nil {
+			next(xi, xq, params, reset)
+		}
+	}
+}
+
+// Report returns a snapshot of the statistics collected since the
+// first callback after creation or the last call to Reset. Rates are
+// measured over the wall time between the first and the most recent
+// callback, so they are zero until at least two callbacks have been
+// received.
+func (s *TransferStats) Report() TransferReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := TransferReport{
+		Callbacks:             s.callbacks,
+		Samples:               s.samples,
+		Dropped:               s.dropped,
+		ExpectedSamplesPerSec: s.expected,
+	}
+	if !s.started {
+		return r
+	}
+	r.Elapsed = s.last.Sub(s.start)
+	if secs := r.Elapsed.Seconds(); secs > 0 {
+		r.CallbacksPerSec = float64(s.callbacks-1) / secs
+		r.SamplesPerSec = float64(s.rateSamples) / secs
+	}
+	if total := s.rateSamples + s.dropped; total > 0 {
+		r.DropPercent = 100 * float64(s.dropped) / float64(total)
+	}
+	return r
+}
+
+// Reset clears all collected statistics and starts a new window with
+// the next callback.
+func (s *TransferStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detect = newDropDetect(s.expected)
+	s.started = false
+	s.callbacks = 0
+	s.samples = 0
+	s.dropped = 0
+	s.rateSamples = 0
+}
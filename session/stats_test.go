@@ -0,0 +1,113 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+func TestTransferStats(t *testing.T) {
+	t.Parallel()
+
+	const (
+		rate         = 1e6
+		numSamples   = 1000
+		numCallbacks = 1000
+		dropEvery    = 10
+	)
+
+	var now time.Time
+	var calls int
+	stats := NewTransferStats(rate)
+	stats.now = func() time.Time { return now }
+	cb := stats.Wrap(func(xi, xq []int16, params *api.StreamCbParamsT, reset bool) { calls++ })
+
+	if r := stats.Report(); r.Callbacks != 0 || r.SamplesPerSec != 0 || r.DropPercent != 0 {
+		t.Fatalf("wrong initial report: got %+v", r)
+	}
+
+	base := time.Unix(1000, 0)
+	var sampleNum uint32
+	var wantDropped uint64
+	for i := 0; i < numCallbacks; i++ {
+		if i > 0 && i%dropEvery == 0 {
+			// Skip one callback worth of samples.
+			sampleNum += numSamples
+			wantDropped += numSamples
+		}
+		now = base.Add(time.Duration(float64(sampleNum) / rate * float64(time.Second)))
+		cb(nil, nil, &api.StreamCbParamsT{FirstSampleNum: sampleNum, NumSamples: numSamples}, i == 0)
+		sampleNum += numSamples
+	}
+
+	if calls != numCallbacks {
+		t.Errorf("wrong number of wrapped calls: got %d, want %d", calls, numCallbacks)
+	}
+
+	r := stats.Report()
+	if r.Callbacks != numCallbacks {
+		t.Errorf("wrong callbacks: got %d, want %d", r.Callbacks, numCallbacks)
+	}
+	if r.Samples != numCallbacks*numSamples {
+		t.Errorf("wrong samples: got %d, want %d", r.Samples, numCallbacks*numSamples)
+	}
+	if r.Dropped != wantDropped {
+		t.Errorf("wrong dropped: got %d, want %d", r.Dropped, wantDropped)
+	}
+	if r.ExpectedSamplesPerSec != rate {
+		t.Errorf("wrong expected rate: got %v, want %v", r.ExpectedSamplesPerSec, rate)
+	}
+
+	received := float64((numCallbacks - 1) * numSamples)
+	wantPct := 100 * float64(wantDropped) / (received + float64(wantDropped))
+	if math.Abs(r.DropPercent-wantPct) > 1e-9 {
+		t.Errorf("wrong drop percent: got %v, want %v", r.DropPercent, wantPct)
+	}
+	// Received plus dropped samples account for the full expected rate.
+	wantRate := rate * (100 - wantPct) / 100
+	if math.Abs(r.SamplesPerSec-wantRate) > 1 {
+		t.Errorf("wrong sample rate: got %v, want %v", r.SamplesPerSec, wantRate)
+	}
+	wantCbRate := wantRate / numSamples
+	if math.Abs(r.CallbacksPerSec-wantCbRate) > 1e-3 {
+		t.Errorf("wrong callback rate: got %v, want %v", r.CallbacksPerSec, wantCbRate)
+	}
+
+	stats.Reset()
+	if r := stats.Report(); r.Callbacks != 0 || r.Dropped != 0 || r.Elapsed != 0 {
+		t.Errorf("wrong report after reset: got %+v", r)
+	}
+}
+
+func TestTransferStatsCounterReset(t *testing.T) {
+	t.Parallel()
+
+	const (
+		rate       = 1e6
+		numSamples = 1000
+	)
+	stats := NewTransferStats(rate)
+	// The API restarts the sample counter, without the reset flag, at
+	// a lower number. That is not a drop.
+	for _, first := range []uint32{500000, 501000, 0, 1000} {
+		stats.StreamCallback(nil, nil, &api.StreamCbParamsT{FirstSampleNum: first, NumSamples: numSamples}, false)
+	}
+	if r := stats.Report(); r.Dropped != 0 {
+		t.Errorf("wrong dropped after counter reset: got %d, want 0", r.Dropped)
+	}
+
+	// The threshold also applies after Reset.
+	stats.Reset()
+	for _, first := range []uint32{500000, 501000, 0, 1000} {
+		stats.StreamCallback(nil, nil, &api.StreamCbParamsT{FirstSampleNum: first, NumSamples: numSamples}, false)
+	}
+	if r := stats.Report(); r.Dropped != 0 {
+		t.Errorf("wrong dropped after counter reset: got %d, want 0", r.Dropped)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// ParamDiff describes a single device parameter whose value loaded back
+// from the API after StoreDeviceParams differs from the value that was
+// stored.
+type ParamDiff struct {
+	// Field is the path of the field from DeviceParamsT
+	// (e.g. "RxChannelA.TunerParams.Gain.GRdB").
+	Field string
+	// Requested is the stored value.
+	Requested interface{}
+	// Actual is the value loaded back from the API.
+	Actual interface{}
+}
+
+func (d ParamDiff) String() string {
+	return fmt.Sprintf("%s: requested %v, got %v", d.Field, d.Requested, d.Actual)
+}
+
+// VerifyParamsFn is implemented by a function that receives the
+// differences between the device parameters stored by a Session and the
+// parameters loaded back from the API. It is only called if there is
+// at least one difference. If it returns a non-nil error, the Session
+// fails with that error.
+type VerifyParamsFn func(d *api.DeviceT, diffs []ParamDiff) error
+
+// DiffDeviceParams compares every field of requested and actual and
+// returns the fields that differ, in field order. A channel or device
+// parameter struct that is present in only one of them is reported as
+// a single difference.
+func DiffDeviceParams(requested, actual *api.DeviceParamsT) []ParamDiff {
+	var res []ParamDiff
+	diffValues("", reflect.ValueOf(requested), reflect.ValueOf(actual), &res)
+	return res
+}
+
+// diffValues recursively compares a and b, which have the same type,
+// and appends any differences to res.
+func diffValues(path string, a, b reflect.Value, res *[]ParamDiff) {
+	switch a.Kind() {
+	case reflect.Ptr:
+		switch {
+		case a.IsNil() && b.IsNil():
+		case a.IsNil() || b.IsNil():
+			*res = append(*res, ParamDiff{Field: path, Requested: nilOrPresent(a), Actual: nilOrPresent(b)})
+		default:
+			diffValues(path, a.Elem(), b.Elem(), res)
+		}
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			f := a.Type().Field(i)
+			if f.PkgPath != "" {
+				// Unexported
+				continue
+			}
+			name := f.Name
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValues(name, a.Field(i), b.Field(i), res)
+		}
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i), res)
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*res = append(*res, ParamDiff{Field: path, Requested: a.Interface(), Actual: b.Interface()})
+		}
+	}
+}
+
+// nilOrPresent describes a pointer for a ParamDiff.
+func nilOrPresent(v reflect.Value) string {
+	if v.IsNil() {
+		return "nil"
+	}
+	return "present"
+}
+
+// LogParamDiffs creates a VerifyParamsFn that logs each difference to
+// the specified Logger and never returns an error.
+func LogParamDiffs(lg Logger) VerifyParamsFn {
+	return func(d *api.DeviceT, diffs []ParamDiff) error {
+		for _, diff := range diffs {
+			lg.Printf("WARNING: device param changed by API: %v\n", diff)
+		}
+		return nil
+	}
+}
+
+// WithVerifyParams creates a ConfigFn that sets the VerifyParams member
+// of the Session. After the device parameters are stored, they are
+// loaded back from the API and compared with the stored values. The
+// provided function receives any differences, such as a sample rate
+// snapped to a supported value or a silently clamped gain.
+func WithVerifyParams(fn VerifyParamsFn) ConfigFn {
+	return func(o *Session) error {
+		if o.VerifyParams != nil {
+			return errors.New("verify params function already set")
+		}
+		o.VerifyParams = fn
+		return nil
+	}
+}
+
+// verifyParams loads the device parameters back from the API, compares
+// them with the stored params, and calls fn with any differences.
+func verifyParams(impl api.API, dev *api.DeviceT, stored *api.DeviceParamsT, fn VerifyParamsFn) error {
+	loaded, err := impl.LoadDeviceParams(dev.Dev)
+	if err != nil {
+		return fmt.Errorf("failed to load device params for verification: %v", impl.GetLastError(dev))
+	}
+	diffs := DiffDeviceParams(stored, loaded)
+	if len(diffs) == 0 {
+		return nil
+	}
+	return fn(dev, diffs)
+}
@@ -0,0 +1,154 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+// clampMock is a Mock that clamps the gain reduction of channel A to
+// 59 dB when the parameters are stored, like an API that silently
+// limits a setting.
+type clampMock struct {
+	*apitest.Mock
+}
+
+func (m clampMock) StoreDeviceParams(dev api.Handle, p *api.DeviceParamsT) error {
+	cpy := *p
+	if p.RxChannelA != nil {
+		ch := *p.RxChannelA
+		if ch.TunerParams.Gain.GRdB > 59 {
+			ch.TunerParams.Gain.GRdB = 59
+		}
+		cpy.RxChannelA = &ch
+	}
+	return m.Mock.StoreDeviceParams(dev, &cpy)
+}
+
+// captureLogger records every message printed to it.
+type captureLogger struct {
+	msgs []string
+}
+
+func (l *captureLogger) Printf(format string, args ...interface{}) {
+	l.msgs = append(l.msgs, fmt.Sprintf(format, args...))
+}
+
+func TestVerifyParams(t *testing.T) {
+	t.Parallel()
+
+	var got []ParamDiff
+	m := clampMock{apitest.NewMock(&api.DeviceT{HWVer: api.RSP1A_ID})}
+	_, err := DryRun(
+		WithImplementation(m),
+		WithDeviceConfig(
+			WithSingleChannelConfig(
+				WithZeroIF(8e6, 1),
+				func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+					c.TunerParams.Gain.GRdB = 70
+					return nil
+				},
+			),
+		),
+		WithVerifyParams(func(d *api.DeviceT, diffs []ParamDiff) error {
+			got = diffs
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []ParamDiff{{Field: "RxChannelA.TunerParams.Gain.GRdB", Requested: int32(70), Actual: int32(59)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong diffs: got %v, want %v", got, want)
+	}
+
+	// No differences means no call.
+	called := false
+	_, err = DryRun(
+		WithImplementation(m),
+		WithDeviceConfig(
+			WithSingleChannelConfig(
+				WithZeroIF(8e6, 1),
+			),
+		),
+		WithVerifyParams(func(d *api.DeviceT, diffs []ParamDiff) error {
+			called = true
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if called {
+		t.Error("unexpected call without differences")
+	}
+}
+
+func TestVerifyParamsError(t *testing.T) {
+	t.Parallel()
+
+	lg := &captureLogger{}
+	errClamp := errors.New("clamped")
+	m := clampMock{apitest.NewMock(&api.DeviceT{HWVer: api.RSP1A_ID})}
+	_, err := DryRun(
+		WithImplementation(m),
+		WithDeviceConfig(
+			WithSingleChannelConfig(
+				func(d *api.DeviceT, p *api.DeviceParamsT, c *api.RxChannelParamsT) error {
+					c.TunerParams.Gain.GRdB = 80
+					return nil
+				},
+			),
+		),
+		WithVerifyParams(func(d *api.DeviceT, diffs []ParamDiff) error {
+			if err := LogParamDiffs(lg)(d, diffs); err != nil {
+				return err
+			}
+			return errClamp
+		}),
+	)
+	if err != errClamp {
+		t.Errorf("wrong error: got %v, want %v", err, errClamp)
+	}
+	want := []string{"WARNING: device param changed by API: RxChannelA.TunerParams.Gain.GRdB: requested 80, got 59\n"}
+	if !reflect.DeepEqual(lg.msgs, want) {
+		t.Errorf("wrong log: got %q, want %q", lg.msgs, want)
+	}
+}
+
+func TestDiffDeviceParams(t *testing.T) {
+	t.Parallel()
+
+	a := &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelA: &api.RxChannelParamsT{},
+	}
+	b := &api.DeviceParamsT{
+		DevParams:  &api.DevParamsT{},
+		RxChannelA: &api.RxChannelParamsT{},
+		RxChannelB: &api.RxChannelParamsT{},
+	}
+	b.DevParams.FsFreq.FsHz = 8e6
+	a.DevParams.FsFreq.FsHz = 8.1e6
+
+	got := DiffDeviceParams(a, b)
+	want := []ParamDiff{
+		{Field: "DevParams.FsFreq.FsHz", Requested: 8.1e6, Actual: 8e6},
+		{Field: "RxChannelB", Requested: "nil", Actual: "present"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong diffs: got %v, want %v", got, want)
+	}
+	if got := DiffDeviceParams(a, a); len(got) != 0 {
+		t.Errorf("wrong diffs for identical params: got %v, want none", got)
+	}
+}
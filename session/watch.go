@@ -0,0 +1,206 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+)
+
+// DeviceEventKind specifies the type of a DeviceEvent.
+type DeviceEventKind int
+
+const (
+	// DeviceAdded specifies that a device has appeared in the device list.
+	DeviceAdded DeviceEventKind = iota
+	// DeviceRemoved specifies that a device has disappeared from the
+	// device list.
+	DeviceRemoved
+)
+
+func (k DeviceEventKind) String() string {
+	switch k {
+	case DeviceAdded:
+		return "DeviceAdded"
+	case DeviceRemoved:
+		return "DeviceRemoved"
+	default:
+		return fmt.Sprintf("DeviceEventKind(%d)", int(k))
+	}
+}
+
+// DeviceEvent is emitted by WatchDevices when a device is added to or
+// removed from the device list.
+type DeviceEvent struct {
+	Kind DeviceEventKind
+	// Device is the device entry as last listed by GetDevices. For a
+	// removed device, it is the entry from before the removal.
+	Device *api.DeviceT
+}
+
+// deviceWatcher tracks the presence of devices, identified by serial
+// number, across successive device lists.
+type deviceWatcher struct {
+	debounce int
+	started  bool
+	// present holds the devices that have been reported as added and
+	// not yet reported as removed. order holds their serial numbers in
+	// the order they were added.
+	present map[api.SerialNumber]*api.DeviceT
+	order   []api.SerialNumber
+	// pending counts the consecutive device lists in which the presence
+	// of a device disagreed with its reported state.
+	pending map[api.SerialNumber]int
+}
+
+func newDeviceWatcher(debounce int) *deviceWatcher {
+	if debounce < 1 {
+		debounce = 1
+	}
+	return &deviceWatcher{
+		debounce: debounce,
+		present:  make(map[api.SerialNumber]*api.DeviceT),
+		pending:  make(map[api.SerialNumber]int),
+	}
+}
+
+// update compares the provided device list with the reported state
+// and returns the resulting events. The first list is taken as the
+// initial state and every device in it is reported as added without
+// debouncing. After that, a device is only reported as added or removed
+// once the change has been seen in debounce consecutive lists.
+func (w *deviceWatcher) update(devs []*api.DeviceT) []DeviceEvent {
+	// An RSPduo may be listed more than once with the same serial
+	// number, so only keep the first entry.
+	seen := make(map[api.SerialNumber]*api.DeviceT)
+	var seenOrder []api.SerialNumber
+	for _, dev := range SortDevices(devs) {
+		if dev == nil {
+			continue
+		}
+		if _, ok := seen[dev.SerNo]; !ok {
+			seen[dev.SerNo] = dev
+			seenOrder = append(seenOrder, dev.SerNo)
+		}
+	}
+
+	var res []DeviceEvent
+	if !w.started {
+		w.started = true
+		for _, ser := range seenOrder {
+			w.present[ser] = seen[ser]
+			w.order = append(w.order, ser)
+			res = append(res, DeviceEvent{Kind: DeviceAdded, Device: seen[ser]})
+		}
+		return res
+	}
+
+	var order []api.SerialNumber
+	for _, ser := range w.order {
+		if _, ok := seen[ser]; ok {
+			delete(w.pending, ser)
+			// Keep the latest entry for a device that is still present.
+			w.present[ser] = seen[ser]
+			order = append(order, ser)
+			continue
+		}
+		w.pending[ser]++
+		if w.pending[ser] < w.debounce {
+			order = append(order, ser)
+			continue
+		}
+		res = append(res, DeviceEvent{Kind: DeviceRemoved, Device: w.present[ser]})
+		delete(w.present, ser)
+		delete(w.pending, ser)
+	}
+	for _, ser := range seenOrder {
+		if _, ok := w.present[ser]; ok {
+			continue
+		}
+		w.pending[ser]++
+		if w.pending[ser] < w.debounce {
+			continue
+		}
+		res = append(res, DeviceEvent{Kind: DeviceAdded, Device: seen[ser]})
+		w.present[ser] = seen[ser]
+		order = append(order, ser)
+		delete(w.pending, ser)
+	}
+	// A device that was pending addition but is absent again starts over.
+	for ser := range w.pending {
+		_, isPresent := w.present[ser]
+		_, isSeen := seen[ser]
+		if !isPresent && !isSeen {
+			delete(w.pending, ser)
+		}
+	}
+	w.order = order
+	return res
+}
+
+// WatchDevices polls the device list every interval and emits an event
+// on the returned channel each time a device is added or removed. The
+// API does not provide a notification for devices that are not
+// streaming, so this is implemented with periodic calls to GetDevices.
+// If impl is nil, api.GetAPI() is used.
+//
+// Every device listed by the first poll, which happens before
+// WatchDevices returns, is reported as added. After that, a change is
+// only reported once it has been seen in debounce consecutive polls,
+// which suppresses events from a device that briefly disappears (e.g.
+// during USB re-enumeration). A debounce of 1 reports every change.
+// Devices are identified by serial number. A device that is opened by
+// another process is not listed by the API and is reported as removed.
+//
+// An error from the first poll is returned. Errors from later polls
+// are ignored and the poll is retried at the next interval. The channel
+// is closed after ctx is canceled. Events are not dropped, so the
+// channel must be drained to keep polling.
+func WatchDevices(ctx context.Context, impl api.API, interval time.Duration, debounce int) (<-chan DeviceEvent, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval: got %v, want > 0", interval)
+	}
+	if impl == nil {
+		impl = api.GetAPI()
+	}
+	w := newDeviceWatcher(debounce)
+	devs, err := listDevices(impl)
+	if err != nil {
+		return nil, err
+	}
+	initial := w.update(devs)
+
+	ch := make(chan DeviceEvent, len(initial))
+	for _, evt := range initial {
+		ch <- evt
+	}
+	go func() {
+		defer close(ch)
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+			devs, err := listDevices(impl)
+			if err != nil {
+				continue
+			}
+			for _, evt := range w.update(devs) {
+				select {
+				case <-ctx.Done():
+					return
+				case ch <- evt:
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 Mark Siner. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/msiner/sdrplay-go/api"
+	"github.com/msiner/sdrplay-go/api/apitest"
+)
+
+func TestDeviceWatcher(t *testing.T) {
+	t.Parallel()
+
+	var (
+		a   = &api.DeviceT{SerNo: api.ParseSerialNumber("1000000001"), HWVer: api.RSP1A_ID}
+		b   = &api.DeviceT{SerNo: api.ParseSerialNumber("1000000002"), HWVer: api.RSPdx_ID}
+		dA  = &api.DeviceT{SerNo: api.ParseSerialNumber("1500000001"), HWVer: api.RSPduo_ID, Tuner: api.Tuner_A}
+		dB  = &api.DeviceT{SerNo: api.ParseSerialNumber("1500000001"), HWVer: api.RSPduo_ID, Tuner: api.Tuner_B}
+		add = func(d *api.DeviceT) string { return fmt.Sprintf("+%v", d.SerNo) }
+		rem = func(d *api.DeviceT) string { return fmt.Sprintf("-%v", d.SerNo) }
+	)
+
+	specs := []struct {
+		debounce int
+		lists    [][]*api.DeviceT
+		want     [][]string
+	}{
+		{
+			1,
+			[][]*api.DeviceT{{a}, {a, b}, {b}, {}, {a, nil}},
+			[][]string{{add(a)}, {add(b)}, {rem(a)}, {rem(b)}, {add(a)}},
+		},
+		{
+			// The initial list is not debounced.
+			2,
+			[][]*api.DeviceT{{a, b}, {a, b}, {a}, {a}, {a}},
+			// Devices are reported in the order of SortDevices.
+			[][]string{{add(b), add(a)}, nil, nil, {rem(b)}, nil},
+		},
+		{
+			// A device that briefly disappears or appears is ignored.
+			2,
+			[][]*api.DeviceT{{a}, {}, {a}, {a, b}, {a}, {a, b}, {a, b}, {}, {}},
+			[][]string{{add(a)}, nil, nil, nil, nil, nil, {add(b)}, nil, {rem(a), rem(b)}},
+		},
+		{
+			// Both tuners of an RSPduo are a single device.
+			1,
+			[][]*api.DeviceT{{}, {dA, dB}, {dB}, {}},
+			[][]string{nil, {add(dA)}, nil, {rem(dA)}},
+		},
+	}
+
+	for i, spec := range specs {
+		w := newDeviceWatcher(spec.debounce)
+		for j, devs := range spec.lists {
+			var got []string
+			for _, evt := range w.update(devs) {
+				switch evt.Kind {
+				case DeviceAdded:
+					got = append(got, add(evt.Device))
+				case DeviceRemoved:
+					got = append(got, rem(evt.Device))
+				}
+			}
+			if !reflect.DeepEqual(got, spec.want[j]) {
+				t.Errorf("wrong events in spec %d at list %d: got %v, want %v", i, j, got, spec.want[j])
+			}
+		}
+	}
+}
+
+func TestWatchDevices(t *testing.T) {
+	t.Parallel()
+
+	a := &api.DeviceT{SerNo: api.ParseSerialNumber("1000000001"), HWVer: api.RSP1A_ID}
+	m := apitest.NewMock(a)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := WatchDevices(ctx, m, time.Millisecond, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	evt := <-ch
+	if evt.Kind != DeviceAdded || evt.Device.SerNo != a.SerNo {
+		t.Errorf("wrong initial event: got %v %v", evt.Kind, evt.Device.SerNo)
+	}
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	for evt := range ch {
+		t.Errorf("unexpected event: %v %v", evt.Kind, evt.Device.SerNo)
+	}
+
+	if _, err := WatchDevices(context.Background(), m, 0, 1); err == nil {
+		t.Error("unexpected success for zero interval")
+	}
+}